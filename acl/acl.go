@@ -0,0 +1,234 @@
+// Package acl implements users and their permissions of redis ACL
+//
+// A user has passwords, allowed commands (by names, subcommands and categories), key patterns with read/write
+// permissions and channel patterns. Rules are the same as ACL SETUSER of redis, e.g.
+//
+//	user analytics on >secret ~* -@all +@read
+//	user orders on #<sha256 of password> ~orders:* %R~users:* &orders:* +@all -@dangerous
+//
+// Users can be loaded from and saved to an ACL file (aclfile in redis.conf) of such lines
+package acl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultUser is the user of new connections, it has the password of requirepass
+const DefaultUser = "default"
+
+var (
+	// ErrNoFile is returned by Load and Save if there is no ACL file configured
+	ErrNoFile = errors.New("This Redis instance is not configured to use an ACL file. " +
+		"You may want to specify users via the ACL SETUSER command and then issue a CONFIG REWRITE " +
+		"(assuming you have a Redis configuration file set) in order to store users in the Redis configuration.")
+	// ErrDeleteDefault is returned if ACL DELUSER tries to delete the default user
+	ErrDeleteDefault = errors.New("The 'default' user cannot be removed")
+)
+
+// ACL holds all users of a server
+type ACL struct {
+	mu    sync.RWMutex
+	users map[string]*User
+
+	log *denialLog
+	// path of the ACL file, empty if not configured
+	file string
+}
+
+// New creates ACL with the default user, whose password is requirePass if it's not empty.
+// Users are loaded from file if it's not empty
+func New(requirePass string, file string) (*ACL, error) {
+	a := &ACL{
+		users: map[string]*User{DefaultUser: newDefaultUser(requirePass)},
+		log:   makeDenialLog(),
+		file:  file,
+	}
+	if file != "" {
+		if err := a.Load(); err != nil {
+			return a, err
+		}
+	}
+	return a, nil
+}
+
+// GetUser returns the user of name, or nil if not exists. The returned user must not be modified
+func (a *ACL) GetUser(name string) *User {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.users[name]
+}
+
+// Usernames returns names of all users, sorted
+func (a *ACL) Usernames() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, 0, len(a.users))
+	for name := range a.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetUser creates the user or modifies it by rules. Nothing is changed if any rule is wrong
+func (a *ACL) SetUser(name string, rules []string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	user := newUser(name)
+	if existing := a.users[name]; existing != nil {
+		user = existing.clone()
+	}
+	if err := user.applyRules(rules); err != nil {
+		return err
+	}
+	a.users[name] = user
+	return nil
+}
+
+// DeleteUsers deletes users and returns the number of users deleted
+func (a *ACL) DeleteUsers(names []string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, name := range names {
+		if name == DefaultUser {
+			return 0, ErrDeleteDefault
+		}
+	}
+	deleted := 0
+	for _, name := range names {
+		if _, ok := a.users[name]; ok {
+			delete(a.users, name)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Authenticate returns the user if it's enabled and the password is correct
+func (a *ACL) Authenticate(name string, password string) (*User, bool) {
+	user := a.GetUser(name)
+	if user == nil || !user.enabled {
+		// compare anyway, so that the time doesn't tell whether the user exists
+		newUser("").checkPassword(password)
+		return nil, false
+	}
+	if !user.checkPassword(password) {
+		return nil, false
+	}
+	return user, true
+}
+
+// List returns rules of all users, the same as lines of the ACL file
+func (a *ACL) List() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, 0, len(a.users))
+	for name := range a.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = a.users[name].Describe()
+	}
+	return lines
+}
+
+// LogDenial adds an entry to ACL LOG
+func (a *ACL) LogDenial(reason, context, object, username, clientInfo string) {
+	a.log.add(reason, context, object, username, clientInfo)
+}
+
+// LogEntries returns at most count latest entries of ACL LOG, count < 0 means all
+func (a *ACL) LogEntries(count int) []LogEntry {
+	return a.log.latest(count)
+}
+
+// ResetLog clears ACL LOG
+func (a *ACL) ResetLog() {
+	a.log.reset()
+}
+
+// Load replaces all users by users of the ACL file.
+// Nothing is changed if the file has any error. The default user is kept if the file doesn't define it
+func (a *ACL) Load() error {
+	if a.file == "" {
+		return ErrNoFile
+	}
+	f, err := os.Open(a.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	users := make(map[string]*User)
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "user" {
+			return fmt.Errorf("%s:%d: line should start with user keyword", a.file, lineNum)
+		}
+		name := fields[1]
+		if _, ok := users[name]; ok {
+			return fmt.Errorf("%s:%d: duplicate user '%s' found", a.file, lineNum, name)
+		}
+		user := newUser(name)
+		if err := user.applyRules(fields[2:]); err != nil {
+			return fmt.Errorf("%s:%d: %s", a.file, lineNum, err.(*RuleError).Err.Error())
+		}
+		users[name] = user
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := users[DefaultUser]; !ok {
+		users[DefaultUser] = a.users[DefaultUser]
+	}
+	a.users = users
+	return nil
+}
+
+// Save writes all users to the ACL file. It writes a temporary file first and renames it,
+// so the ACL file is never left half written
+func (a *ACL) Save() error {
+	if a.file == "" {
+		return ErrNoFile
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(a.file), filepath.Base(a.file)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	for _, line := range a.List() {
+		_, _ = w.WriteString(line + "\n")
+	}
+	if err := w.Flush(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), a.file)
+}
@@ -0,0 +1,156 @@
+package acl
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Category is a set of command categories like @read or @list, used by rules like +@read
+type Category uint32
+
+const (
+	CategoryKeyspace Category = 1 << iota
+	CategoryRead
+	CategoryWrite
+	CategoryString
+	CategoryBitmap
+	CategoryHyperLogLog
+	CategoryList
+	CategoryHash
+	CategorySet
+	CategorySortedSet
+	CategoryGeo
+	CategoryStream
+	CategoryPubSub
+	CategoryAdmin
+	CategoryBlocking
+	CategoryDangerous
+	CategoryConnection
+	CategoryTransaction
+)
+
+// categoryNames are names of categories in the order of ACL CAT
+var categoryNames = []struct {
+	name     string
+	category Category
+}{
+	{"keyspace", CategoryKeyspace},
+	{"read", CategoryRead},
+	{"write", CategoryWrite},
+	{"string", CategoryString},
+	{"bitmap", CategoryBitmap},
+	{"hyperloglog", CategoryHyperLogLog},
+	{"list", CategoryList},
+	{"hash", CategoryHash},
+	{"set", CategorySet},
+	{"sortedset", CategorySortedSet},
+	{"geo", CategoryGeo},
+	{"stream", CategoryStream},
+	{"pubsub", CategoryPubSub},
+	{"admin", CategoryAdmin},
+	{"blocking", CategoryBlocking},
+	{"dangerous", CategoryDangerous},
+	{"connection", CategoryConnection},
+	{"transaction", CategoryTransaction},
+}
+
+// lookupCategory returns the category of name, "all" means all categories
+func lookupCategory(name string) (Category, bool) {
+	name = strings.ToLower(name)
+	if name == "all" {
+		return ^Category(0), true
+	}
+	for _, c := range categoryNames {
+		if c.name == name {
+			return c.category, true
+		}
+	}
+	return 0, false
+}
+
+var (
+	// command name -> categories of all commands, a subcommand is registered as "command|subcommand"
+	commands   = make(map[string]Category)
+	commandsMu sync.RWMutex
+)
+
+// RegisterCommand registers the categories of a command, so that rules like +@read and ACL CAT know it.
+// A subcommand like "client|id" is registered if its categories differ from the command
+func RegisterCommand(name string, categories Category) {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	commands[strings.ToLower(name)] |= categories
+}
+
+// UnregisterCommand removes a command registered by RegisterCommand, like a command only registered by a test
+func UnregisterCommand(name string) {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	delete(commands, strings.ToLower(name))
+}
+
+// CommandExists returns whether the command is registered
+func CommandExists(name string) bool {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+	_, ok := commands[strings.ToLower(name)]
+	return ok
+}
+
+// subcommandsOf returns names of subcommands of the command registered apart, like "client|unblock"
+func subcommandsOf(command string) []string {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+	var names []string
+	for name := range commands {
+		if strings.HasPrefix(name, command+"|") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// commandsIn returns names of commands in any of categories, sorted
+func commandsIn(categories Category) []string {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+	var names []string
+	for name, c := range commands {
+		if c&categories > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// allCommands returns names of all registered commands, sorted
+func allCommands() []string {
+	commandsMu.RLock()
+	defer commandsMu.RUnlock()
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CategoryNames returns names of all categories, for ACL CAT
+func CategoryNames() []string {
+	names := make([]string, len(categoryNames))
+	for i, c := range categoryNames {
+		names[i] = c.name
+	}
+	return names
+}
+
+// CommandsInCategory returns names of commands in the category, for ACL CAT category
+func CommandsInCategory(name string) ([]string, bool) {
+	category, ok := lookupCategory(name)
+	if !ok {
+		return nil, false
+	}
+	return commandsIn(category), true
+}
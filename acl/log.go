@@ -0,0 +1,95 @@
+package acl
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// reasons of ACL LOG entries
+const (
+	ReasonAuth    = "auth"
+	ReasonCommand = "command"
+	ReasonKey     = "key"
+	ReasonChannel = "channel"
+)
+
+// logMaxLen is the max number of ACL LOG entries, acllog-max-len of redis
+const logMaxLen = 128
+
+// LogEntry records denials of the same reason, context, object and user, like an entry of ACL LOG
+type LogEntry struct {
+	Count int
+	// ReasonAuth, ReasonCommand, ReasonKey or ReasonChannel
+	Reason string
+	// toplevel or multi
+	Context string
+	// the command, key or channel denied, AUTH for failed authentications
+	Object     string
+	Username   string
+	ClientInfo string
+	EntryID    int64
+	Created    time.Time
+	Updated    time.Time
+}
+
+// denialLog keeps the latest entries, the newest first
+type denialLog struct {
+	mu      sync.Mutex
+	entries *list.List // *LogEntry
+	nextID  int64
+}
+
+func makeDenialLog() *denialLog {
+	return &denialLog{entries: list.New()}
+}
+
+// add records a denial, it's merged into a recent entry of the same denial if any
+func (l *denialLog) add(reason, context, object, username, clientInfo string) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for node := l.entries.Front(); node != nil; node = node.Next() {
+		e := node.Value.(*LogEntry)
+		if e.Reason == reason && e.Context == context && e.Object == object && e.Username == username {
+			e.Count++
+			e.ClientInfo = clientInfo
+			e.Updated = now
+			l.entries.MoveToFront(node)
+			return
+		}
+	}
+	l.entries.PushFront(&LogEntry{
+		Count:      1,
+		Reason:     reason,
+		Context:    context,
+		Object:     object,
+		Username:   username,
+		ClientInfo: clientInfo,
+		EntryID:    l.nextID,
+		Created:    now,
+		Updated:    now,
+	})
+	l.nextID++
+	if l.entries.Len() > logMaxLen {
+		l.entries.Remove(l.entries.Back())
+	}
+}
+
+// latest returns copies of at most count latest entries, count < 0 means all
+func (l *denialLog) latest(count int) []LogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var entries []LogEntry
+	for node := l.entries.Front(); node != nil && count != 0; node = node.Next() {
+		entries = append(entries, *node.Value.(*LogEntry))
+		count--
+	}
+	return entries
+}
+
+func (l *denialLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries.Init()
+}
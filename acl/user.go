@@ -0,0 +1,461 @@
+package acl
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/tonge3199/redis_go/lib/wildcard"
+)
+
+// User is an ACL user. A user is never modified after it's published by ACL,
+// SETUSER replaces it with a modified copy, so permissions are checked without locks
+type User struct {
+	Name    string
+	enabled bool
+	noPass  bool
+	// SHA-256 of passwords in hex
+	passwords []string
+
+	// command name or "command|subcommand" -> allowed, a command absent is not allowed.
+	// A subcommand registered with its own categories is not allowed if absent, other subcommands absent follow their command
+	commands map[string]bool
+
+	keys     []keyPattern
+	channels []string
+}
+
+// keyPattern is a key pattern with its permissions, ~pattern allows both reading and writing
+type keyPattern struct {
+	pattern string
+	read    bool
+	write   bool
+}
+
+var (
+	errSyntax          = errors.New("Syntax error")
+	errUnknownCommand  = errors.New("Unknown command or category name in ACL")
+	errBadPasswordHash = errors.New("The password hash must be exactly 64 characters and contain only " +
+		"lowercase hexadecimal characters")
+	errNoSuchPassword = errors.New("The password you are trying to remove from the user does not exist")
+)
+
+// newUser creates a user without any permission, the same as redis: off resetpass resetkeys resetchannels -@all
+func newUser(name string) *User {
+	return &User{
+		Name:     name,
+		commands: make(map[string]bool),
+	}
+}
+
+// newDefaultUser creates the default user which can do anything, with the password if it's not empty
+func newDefaultUser(password string) *User {
+	user := newUser(DefaultUser)
+	rules := []string{"on", "~*", "&*", "+@all"}
+	if password == "" {
+		rules = append(rules, "nopass")
+	} else {
+		rules = append(rules, ">"+password)
+	}
+	for _, rule := range rules {
+		_ = user.applyRule(rule)
+	}
+	return user
+}
+
+// clone returns a deep copy of u to be modified
+func (u *User) clone() *User {
+	c := *u
+	c.passwords = append([]string(nil), u.passwords...)
+	c.keys = append([]keyPattern(nil), u.keys...)
+	c.channels = append([]string(nil), u.channels...)
+	c.commands = make(map[string]bool, len(u.commands))
+	for name, allowed := range u.commands {
+		c.commands[name] = allowed
+	}
+	return &c
+}
+
+// RuleError tells which rule is wrong
+type RuleError struct {
+	Rule string
+	Err  error
+}
+
+func (e *RuleError) Error() string {
+	return "Error in ACL SETUSER modifier '" + e.Rule + "': " + e.Err.Error()
+}
+
+// applyRules applies rules in order, it stops at the first wrong rule
+func (u *User) applyRules(rules []string) error {
+	for _, rule := range rules {
+		if err := u.applyRule(rule); err != nil {
+			return &RuleError{Rule: rule, Err: err}
+		}
+	}
+	return nil
+}
+
+// applyRule applies a rule of ACL SETUSER.
+// Selectors like (~key +get) are not supported, a user has only its root permissions
+func (u *User) applyRule(rule string) error {
+	switch strings.ToLower(rule) {
+	case "on":
+		u.enabled = true
+		return nil
+	case "off":
+		u.enabled = false
+		return nil
+	case "nopass":
+		u.noPass = true
+		u.passwords = nil
+		return nil
+	case "resetpass":
+		u.noPass = false
+		u.passwords = nil
+		return nil
+	case "allkeys":
+		u.keys = []keyPattern{{pattern: "*", read: true, write: true}}
+		return nil
+	case "resetkeys":
+		u.keys = nil
+		return nil
+	case "allchannels":
+		u.channels = []string{"*"}
+		return nil
+	case "resetchannels":
+		u.channels = nil
+		return nil
+	case "allcommands":
+		return u.applyRule("+@all")
+	case "nocommands":
+		return u.applyRule("-@all")
+	case "reset":
+		*u = *newUser(u.Name)
+		return nil
+	}
+	if rule == "" {
+		return errSyntax
+	}
+	switch rule[0] {
+	case '>':
+		return u.addPassword(hashPassword(rule[1:]))
+	case '#':
+		if !isPasswordHash(rule[1:]) {
+			return errBadPasswordHash
+		}
+		return u.addPassword(rule[1:])
+	case '<':
+		return u.removePassword(hashPassword(rule[1:]))
+	case '!':
+		if !isPasswordHash(rule[1:]) {
+			return errBadPasswordHash
+		}
+		return u.removePassword(rule[1:])
+	case '~':
+		u.addKeyPattern(keyPattern{pattern: rule[1:], read: true, write: true})
+		return nil
+	case '%':
+		return u.applyKeyPermissionRule(rule)
+	case '&':
+		u.addChannelPattern(rule[1:])
+		return nil
+	case '+', '-':
+		return u.applyCommandRule(rule[0] == '+', strings.ToLower(rule[1:]))
+	}
+	return errSyntax
+}
+
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func isPasswordHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !(s[i] >= '0' && s[i] <= '9' || s[i] >= 'a' && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func (u *User) addPassword(hash string) error {
+	u.noPass = false
+	for _, p := range u.passwords {
+		if p == hash {
+			return nil
+		}
+	}
+	u.passwords = append(u.passwords, hash)
+	return nil
+}
+
+func (u *User) removePassword(hash string) error {
+	for i, p := range u.passwords {
+		if p == hash {
+			u.passwords = append(u.passwords[:i], u.passwords[i+1:]...)
+			return nil
+		}
+	}
+	return errNoSuchPassword
+}
+
+// applyKeyPermissionRule applies %R~pattern, %W~pattern or %RW~pattern
+func (u *User) applyKeyPermissionRule(rule string) error {
+	tilde := strings.IndexByte(rule, '~')
+	if tilde < 2 {
+		return errSyntax
+	}
+	p := keyPattern{pattern: rule[tilde+1:]}
+	for _, flag := range strings.ToUpper(rule[1:tilde]) {
+		switch flag {
+		case 'R':
+			p.read = true
+		case 'W':
+			p.write = true
+		default:
+			return errSyntax
+		}
+	}
+	u.addKeyPattern(p)
+	return nil
+}
+
+func (u *User) addKeyPattern(p keyPattern) {
+	for i, existing := range u.keys {
+		if existing.pattern == p.pattern {
+			u.keys[i].read = existing.read || p.read
+			u.keys[i].write = existing.write || p.write
+			return
+		}
+	}
+	u.keys = append(u.keys, p)
+}
+
+func (u *User) addChannelPattern(pattern string) {
+	for _, existing := range u.channels {
+		if existing == pattern {
+			return
+		}
+	}
+	u.channels = append(u.channels, pattern)
+}
+
+// applyCommandRule applies +command, +command|subcommand or +@category, and - of them
+func (u *User) applyCommandRule(allow bool, name string) error {
+	if category, isCategory := strings.CutPrefix(name, "@"); isCategory {
+		var names []string
+		if category == "all" {
+			names = allCommands()
+		} else if c, ok := lookupCategory(category); ok {
+			names = commandsIn(c)
+		} else {
+			return errUnknownCommand
+		}
+		// subcommands registered apart are set by their own categories, not by categories of their commands
+		for _, name := range names {
+			u.commands[name] = allow
+		}
+		return nil
+	}
+	command, subcommand, isSubcommand := strings.Cut(name, "|")
+	if !CommandExists(command) || (isSubcommand && (subcommand == "" || strings.Contains(subcommand, "|"))) {
+		return errUnknownCommand
+	}
+	u.setCommand(name, allow)
+	return nil
+}
+
+// setCommand allows or disallows the command, a command overrides the rules of all its subcommands
+func (u *User) setCommand(name string, allow bool) {
+	if !strings.Contains(name, "|") {
+		for existing := range u.commands {
+			if strings.HasPrefix(existing, name+"|") {
+				delete(u.commands, existing)
+			}
+		}
+		for _, subcommand := range subcommandsOf(name) {
+			u.commands[subcommand] = allow
+		}
+	}
+	u.commands[name] = allow
+}
+
+// Enabled returns whether the user can authenticate
+func (u *User) Enabled() bool {
+	return u.enabled
+}
+
+// NoPass returns whether any password is accepted
+func (u *User) NoPass() bool {
+	return u.noPass
+}
+
+// checkPassword compares the password with passwords of the user in constant time
+func (u *User) checkPassword(password string) bool {
+	if u.noPass {
+		return true
+	}
+	hash := []byte(hashPassword(password))
+	matched := 0
+	for _, p := range u.passwords {
+		matched |= subtle.ConstantTimeCompare(hash, []byte(p))
+	}
+	return matched == 1
+}
+
+// CanExecute returns whether the user may execute the command, subcommand is the first argument or empty
+func (u *User) CanExecute(command string, subcommand string) bool {
+	if subcommand != "" {
+		name := command + "|" + subcommand
+		if allowed, ok := u.commands[name]; ok {
+			return allowed
+		}
+		if CommandExists(name) {
+			// it's in its own categories, so a category allowing its command doesn't allow it
+			return false
+		}
+	}
+	return u.commands[command]
+}
+
+// CanAccessKey returns whether the user may write the key if write is true, or read it
+func (u *User) CanAccessKey(key string, write bool) bool {
+	for _, p := range u.keys {
+		if (write && !p.write) || (!write && !p.read) {
+			continue
+		}
+		if p.pattern == "*" || wildcard.Match(p.pattern, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessChannel returns whether the user may publish or subscribe the channel.
+// A pattern subscribed by PSUBSCRIBE must be one of channel patterns of the user literally, the same as redis
+func (u *User) CanAccessChannel(channel string, isPattern bool) bool {
+	for _, pattern := range u.channels {
+		if pattern == "*" || pattern == channel || (!isPattern && wildcard.Match(pattern, channel)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Flags returns flags of the user for ACL GETUSER
+func (u *User) Flags() []string {
+	flags := []string{"off"}
+	if u.enabled {
+		flags[0] = "on"
+	}
+	if u.noPass {
+		flags = append(flags, "nopass")
+	}
+	return flags
+}
+
+// Passwords returns SHA-256 of passwords in hex
+func (u *User) Passwords() []string {
+	return append([]string(nil), u.passwords...)
+}
+
+// DescribeCommands returns command rules which recreate the command permissions of the user, like "+@all -flushdb"
+func (u *User) DescribeCommands() string {
+	var base []string
+	allowed := 0
+	for _, name := range allCommands() {
+		if !strings.Contains(name, "|") {
+			base = append(base, name)
+			if u.commands[name] {
+				allowed++
+			}
+		}
+	}
+	allowAll := allowed*2 > len(base)
+	rules := []string{"-@all"}
+	if allowAll {
+		rules[0] = "+@all"
+	}
+	for _, name := range base {
+		if u.commands[name] != allowAll {
+			rules = append(rules, commandRule(u.commands[name], name))
+		}
+	}
+	// subcommands which differ from their commands, a registered subcommand absent is not allowed
+	var subcommands []string
+	for _, name := range allCommands() {
+		if command, _, ok := strings.Cut(name, "|"); ok && u.commands[name] != u.commands[command] {
+			subcommands = append(subcommands, name)
+		}
+	}
+	for name, allowed := range u.commands {
+		if command, _, ok := strings.Cut(name, "|"); ok && !CommandExists(name) && allowed != u.commands[command] {
+			subcommands = append(subcommands, name)
+		}
+	}
+	sort.Strings(subcommands)
+	for _, name := range subcommands {
+		rules = append(rules, commandRule(u.commands[name], name))
+	}
+	return strings.Join(rules, " ")
+}
+
+func commandRule(allow bool, name string) string {
+	if allow {
+		return "+" + name
+	}
+	return "-" + name
+}
+
+// DescribeKeys returns key rules like "~* %R~cache:*", empty if no key is allowed
+func (u *User) DescribeKeys() string {
+	rules := make([]string, 0, len(u.keys))
+	for _, p := range u.keys {
+		switch {
+		case p.read && p.write:
+			rules = append(rules, "~"+p.pattern)
+		case p.read:
+			rules = append(rules, "%R~"+p.pattern)
+		default:
+			rules = append(rules, "%W~"+p.pattern)
+		}
+	}
+	return strings.Join(rules, " ")
+}
+
+// DescribeChannels returns channel rules like "&*", empty if no channel is allowed
+func (u *User) DescribeChannels() string {
+	rules := make([]string, len(u.channels))
+	for i, pattern := range u.channels {
+		rules[i] = "&" + pattern
+	}
+	return strings.Join(rules, " ")
+}
+
+// Describe returns rules which recreate the user, for ACL LIST and the ACL file
+func (u *User) Describe() string {
+	rules := append([]string{"user", u.Name}, u.Flags()...)
+	for _, p := range u.passwords {
+		rules = append(rules, "#"+p)
+	}
+	if keys := u.DescribeKeys(); keys != "" {
+		rules = append(rules, keys)
+	} else {
+		rules = append(rules, "resetkeys")
+	}
+	if channels := u.DescribeChannels(); channels != "" {
+		rules = append(rules, channels)
+	} else {
+		rules = append(rules, "resetchannels")
+	}
+	rules = append(rules, u.DescribeCommands())
+	return strings.Join(rules, " ")
+}
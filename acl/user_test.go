@@ -0,0 +1,172 @@
+package acl
+
+import (
+	"strings"
+	"testing"
+)
+
+// commands of the tests, normally they are registered by the command table of database
+func init() {
+	RegisterCommand("get", CategoryRead|CategoryString)
+	RegisterCommand("set", CategoryWrite|CategoryString)
+	RegisterCommand("del", CategoryWrite|CategoryKeyspace)
+	RegisterCommand("client", CategoryAdmin|CategoryConnection)
+	RegisterCommand("client|id", CategoryConnection)
+}
+
+func makeUser(t *testing.T, rules string) *User {
+	t.Helper()
+	u := newUser("test")
+	if err := u.applyRules(strings.Fields(rules)); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestCanExecute(t *testing.T) {
+	tests := []struct {
+		rules      string
+		command    string
+		subcommand string
+		allowed    bool
+	}{
+		{"+@all", "get", "", true},
+		{"+@read", "get", "key", true},
+		{"+@read", "set", "key", false},
+		{"+@all -set", "set", "", false},
+		{"-@all +set", "set", "", true},
+		{"+@write -@string", "del", "", true},
+		{"+@write -@string", "set", "", false},
+		// a subcommand registered apart follows its own categories
+		{"+@admin", "client", "list", true},
+		{"+@admin", "client", "id", false},
+		{"+@connection", "client", "id", true},
+		{"+@connection", "client", "list", true},
+		// rules of subcommands
+		{"+client -client|list", "client", "list", false},
+		{"+client -client|list", "client", "kill", true},
+		{"+client|list", "client", "list", true},
+		{"+client|list", "client", "kill", false},
+		{"+client|list +client", "client", "id", true},
+		{"+client|list -client", "client", "list", false},
+		{"nocommands", "get", "", false},
+		{"allcommands", "client", "id", true},
+	}
+	for _, tt := range tests {
+		u := makeUser(t, tt.rules)
+		if got := u.CanExecute(tt.command, tt.subcommand); got != tt.allowed {
+			t.Errorf("%q: CanExecute(%s, %s) is %v, expected %v", tt.rules, tt.command, tt.subcommand, got, tt.allowed)
+		}
+	}
+}
+
+func TestCanAccessKey(t *testing.T) {
+	u := makeUser(t, "~rw:* %R~r:* %W~w:* %RW~both:*")
+	tests := []struct {
+		key         string
+		read, write bool
+	}{
+		{"rw:1", true, true},
+		{"r:1", true, false},
+		{"w:1", false, true},
+		{"both:1", true, true},
+		{"other", false, false},
+	}
+	for _, tt := range tests {
+		if u.CanAccessKey(tt.key, false) != tt.read || u.CanAccessKey(tt.key, true) != tt.write {
+			t.Errorf("permissions of %s are wrong", tt.key)
+		}
+	}
+	// a pattern granted twice gets the permissions of both
+	u = makeUser(t, "%R~k %W~k")
+	if !u.CanAccessKey("k", false) || !u.CanAccessKey("k", true) || u.DescribeKeys() != "~k" {
+		t.Errorf("%%R~k %%W~k should be the same as ~k, got %s", u.DescribeKeys())
+	}
+	if makeUser(t, "allkeys resetkeys").CanAccessKey("k", false) {
+		t.Error("resetkeys should remove all key patterns")
+	}
+}
+
+func TestCanAccessChannel(t *testing.T) {
+	u := makeUser(t, "&news.* &chat")
+	if !u.CanAccessChannel("news.1", false) || !u.CanAccessChannel("chat", false) || u.CanAccessChannel("other", false) {
+		t.Error("channel permissions are wrong")
+	}
+	// patterns must be granted literally
+	if u.CanAccessChannel("news.1*", true) || !u.CanAccessChannel("news.*", true) {
+		t.Error("pattern permissions are wrong")
+	}
+	if !makeUser(t, "allchannels").CanAccessChannel("news.*", true) {
+		t.Error("allchannels should allow any pattern")
+	}
+}
+
+func TestPasswords(t *testing.T) {
+	u := makeUser(t, "on >a >b")
+	if !u.checkPassword("a") || !u.checkPassword("b") || u.checkPassword("c") {
+		t.Fatal("passwords are wrong")
+	}
+	if err := u.applyRules([]string{"<a"}); err != nil || u.checkPassword("a") {
+		t.Fatal("<a should remove the password")
+	}
+	if err := u.applyRules([]string{"<a"}); err == nil {
+		t.Fatal("removing a missing password should fail")
+	}
+	if err := u.applyRules([]string{"#" + hashPassword("c")}); err != nil || !u.checkPassword("c") {
+		t.Fatal("#hash should add the password")
+	}
+	if err := u.applyRules([]string{"#abc"}); err == nil {
+		t.Fatal("a malformed hash should fail")
+	}
+	if err := u.applyRules([]string{"nopass"}); err != nil || !u.checkPassword("anything") || len(u.Passwords()) != 0 {
+		t.Fatal("nopass should accept any password")
+	}
+	if err := u.applyRules([]string{"resetpass"}); err != nil || u.checkPassword("anything") {
+		t.Fatal("resetpass should accept no password")
+	}
+}
+
+func TestRuleErrors(t *testing.T) {
+	for _, rule := range []string{"", "+nosuchcmd", "+@nosuchcategory", "+client|", "+client|a|b", "%X~k", "%~k", "bad"} {
+		u := newUser("test")
+		err := u.applyRules([]string{"on", rule})
+		if err == nil {
+			t.Errorf("rule %q should fail", rule)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), "Error in ACL SETUSER modifier '"+rule+"'") {
+			t.Errorf("error of %q is %s", rule, err)
+		}
+	}
+}
+
+// TestDescribe checks that applying the description of a user recreates it
+func TestDescribe(t *testing.T) {
+	for _, rules := range []string{
+		"on nopass ~* &* +@all",
+		"off >pass %R~r:* %W~w:* ~rw:* +@read -get",
+		"on >a >b &news.* -@all +set +client|id",
+		"on +@all -client|list -@connection",
+		"on +client -client|list +@connection",
+		"reset",
+	} {
+		u := makeUser(t, rules)
+		description := u.Describe()
+		fields := strings.Fields(description)
+		if fields[0] != "user" || fields[1] != "test" {
+			t.Fatalf("description %q should start with user test", description)
+		}
+		recreated := makeUser(t, strings.Join(fields[2:], " "))
+		if recreated.Describe() != description {
+			t.Errorf("%q is described as %q, which is recreated as %q", rules, description, recreated.Describe())
+		}
+		for _, command := range allCommands() {
+			name, subcommand, _ := strings.Cut(command, "|")
+			for _, sub := range []string{subcommand, "list"} {
+				if u.CanExecute(name, sub) != recreated.CanExecute(name, sub) {
+					t.Errorf("%q: permission of %s %s is changed by %q", rules, name, sub, description)
+				}
+			}
+		}
+	}
+}
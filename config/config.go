@@ -0,0 +1,183 @@
+// Package config loads server properties from a redis.conf style file
+//
+// 配置文件格式与 redis.conf 相同：每行一个 "name value"，以 # 开头的行为注释
+//
+// Example:
+//
+//	bind 0.0.0.0
+//	port 6399
+//	databases 16
+package config
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/lib/logger"
+)
+
+// ServerProperties defines global config properties
+type ServerProperties struct {
+	Bind       string `cfg:"bind"`
+	Port       int    `cfg:"port"`
+	MaxClients int    `cfg:"maxclients"`
+	Databases  int    `cfg:"databases"`
+
+	// ListMaxListpackSize limits the size of every quicklist node.
+	// A positive value is the max entry count of a node,
+	// -1..-5 means a node holds at most 4kb/8kb/16kb/32kb/64kb of data, the same as redis.
+	ListMaxListpackSize int `cfg:"list-max-listpack-size"`
+
+	// a hash is stored as listpack until it has more than HashMaxListpackEntries fields
+	// or any field or value is longer than HashMaxListpackValue
+	HashMaxListpackEntries int `cfg:"hash-max-listpack-entries"`
+	HashMaxListpackValue   int `cfg:"hash-max-listpack-value"`
+
+	// a set containing only integers is stored as intset until it has more than SetMaxIntsetEntries members
+	SetMaxIntsetEntries int `cfg:"set-max-intset-entries"`
+
+	// a HyperLogLog is stored in sparse encoding until it is longer than HllSparseMaxBytes (including the 16 bytes header)
+	HllSparseMaxBytes int `cfg:"hll-sparse-max-bytes"`
+
+	// a stream node (listpack) holds at most StreamNodeMaxEntries entries or StreamNodeMaxBytes bytes, 0 means unlimited
+	StreamNodeMaxBytes   int `cfg:"stream-node-max-bytes"`
+	StreamNodeMaxEntries int `cfg:"stream-node-max-entries"`
+
+	// TransactionRollback is the default of CLIENT TX-ROLLBACK for new connections:
+	// whether EXEC rolls back the whole transaction if any command fails at runtime
+	TransactionRollback bool `cfg:"transaction-rollback"`
+
+	// ExecutorMode is "concurrent" (default) or "single".
+	// In concurrent mode commands are executed by connection goroutines and commands on disjoint keys run in parallel,
+	// in single mode all commands are executed one by one by a single goroutine like redis
+	ExecutorMode string `cfg:"executor-mode"`
+
+	// ClientOutputBufferLimit limits output buffers of the client classes normal, replica and pubsub,
+	// "<class> <hard limit> <soft limit> <soft seconds>" for each class, the same as redis.
+	// A client is disconnected once its pending output exceeds the hard limit,
+	// or stays above the soft limit for soft seconds. 0 means no limit. See OutputBufferLimits
+	ClientOutputBufferLimit []string `cfg:"client-output-buffer-limit"`
+
+	// ClientCloseTimeout is how long a closing connection keeps sending its pending output, in milliseconds.
+	// The rest of the output is dropped, so a client not reading its replies can't delay closing. 0 drops it at once
+	ClientCloseTimeout int `cfg:"client-close-timeout"`
+
+	// NotifyKeyspaceEvents selects keyspace events published through pub/sub, e.g. "KEA", empty disables them.
+	// The flags are the same as redis: K, E, g, $, l, s, h, z, x, e, t, m, n and the alias A
+	NotifyKeyspaceEvents string `cfg:"notify-keyspace-events"`
+
+	// TrackingTableMaxKeys limits the number of keys remembered for client side caching in default mode,
+	// keys beyond it are forgotten and invalidated as if they were modified, the same as redis. 0 means no limit
+	TrackingTableMaxKeys int `cfg:"tracking-table-max-keys"`
+
+	// RequirePass is the password of the default user, clients must AUTH before other commands if it's not empty
+	RequirePass string `cfg:"requirepass"`
+
+	// ACLFile is the path of the file of ACL users, loaded on start and by ACL LOAD, written by ACL SAVE
+	ACLFile string `cfg:"aclfile"`
+}
+
+// Properties holds global config properties
+var Properties *ServerProperties
+
+func init() {
+	// default config
+	Properties = &ServerProperties{
+		Bind:                   "127.0.0.1",
+		Port:                   6379,
+		Databases:              16,
+		ListMaxListpackSize:    -2,
+		HashMaxListpackEntries: 128,
+		HashMaxListpackValue:   64,
+		SetMaxIntsetEntries:    512,
+		HllSparseMaxBytes:      3000,
+		StreamNodeMaxBytes:     4096,
+		StreamNodeMaxEntries:   100,
+		ExecutorMode:           "concurrent",
+		ClientCloseTimeout:     100,
+		TrackingTableMaxKeys:   1000000,
+	}
+}
+
+func parse(src io.Reader) *ServerProperties {
+	config := *Properties
+
+	// read config file, a directive may be repeated like client-output-buffer-limit,
+	// repeated values of a list are joined and the last one wins for others
+	rawMap := make(map[string][]string)
+	scanner := bufio.NewScanner(src)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		pivot := strings.IndexAny(line, " \t")
+		if pivot <= 0 {
+			continue
+		}
+		key := strings.ToLower(line[:pivot])
+		value := strings.TrimSpace(line[pivot+1:])
+		rawMap[key] = append(rawMap[key], value)
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Fatal(err)
+	}
+
+	// parse format
+	t := reflect.TypeOf(&config).Elem()
+	v := reflect.ValueOf(&config).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, ok := field.Tag.Lookup("cfg")
+		if !ok || strings.TrimSpace(key) == "" {
+			key = field.Name
+		}
+		values, ok := rawMap[strings.ToLower(key)]
+		if !ok {
+			continue
+		}
+		value := values[len(values)-1]
+		if field.Type.Kind() == reflect.Slice {
+			value = strings.Join(values, " ")
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			logger.Warn("illegal config " + key + ": " + err.Error())
+		}
+	}
+	return &config
+}
+
+// setField assigns a raw config value to a struct field according to its kind
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		intValue, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(intValue)
+	case reflect.Bool:
+		field.SetBool(value == "yes")
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.String {
+			field.Set(reflect.ValueOf(strings.Fields(value)))
+		}
+	}
+	return nil
+}
+
+// SetupConfig read config file and store properties into Properties
+func SetupConfig(configFilename string) {
+	file, err := os.Open(configFilename)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	Properties = parse(file)
+}
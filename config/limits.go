@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tonge3199/redis_go/lib/logger"
+)
+
+// Client classes of output buffer limits
+const (
+	ClientClassNormal  = "normal"
+	ClientClassReplica = "replica"
+	ClientClassPubSub  = "pubsub"
+)
+
+// OutputBufferLimit is the output buffer limit of a client class, 0 means no limit
+type OutputBufferLimit struct {
+	HardBytes int64
+	SoftBytes int64
+	// the client is disconnected if its output buffer stays above SoftBytes for SoftDuration
+	SoftDuration time.Duration
+}
+
+// defaultOutputBufferLimits are the defaults of redis
+var defaultOutputBufferLimits = map[string]OutputBufferLimit{
+	ClientClassNormal:  {},
+	ClientClassReplica: {HardBytes: 256 << 20, SoftBytes: 64 << 20, SoftDuration: 60 * time.Second},
+	ClientClassPubSub:  {HardBytes: 32 << 20, SoftBytes: 8 << 20, SoftDuration: 60 * time.Second},
+}
+
+// OutputBufferLimits returns limits of all client classes, classes not configured keep their defaults.
+// Illegal settings are logged and ignored
+func (p *ServerProperties) OutputBufferLimits() map[string]OutputBufferLimit {
+	limits := make(map[string]OutputBufferLimit, len(defaultOutputBufferLimits))
+	for class, limit := range defaultOutputBufferLimits {
+		limits[class] = limit
+	}
+	spec := p.ClientOutputBufferLimit
+	if len(spec)%4 != 0 {
+		logger.Warn("illegal config client-output-buffer-limit: wrong number of arguments")
+		return limits
+	}
+	for i := 0; i < len(spec); i += 4 {
+		class := strings.ToLower(spec[i])
+		if class == "slave" {
+			class = ClientClassReplica
+		}
+		if _, ok := limits[class]; !ok {
+			logger.Warn("illegal config client-output-buffer-limit: unknown class " + spec[i])
+			continue
+		}
+		hard, err := parseMemory(spec[i+1])
+		if err != nil {
+			logger.Warn("illegal config client-output-buffer-limit: " + err.Error())
+			continue
+		}
+		soft, err := parseMemory(spec[i+2])
+		if err != nil {
+			logger.Warn("illegal config client-output-buffer-limit: " + err.Error())
+			continue
+		}
+		seconds, err := strconv.ParseInt(spec[i+3], 10, 64)
+		if err != nil || seconds < 0 {
+			logger.Warn("illegal config client-output-buffer-limit: illegal soft seconds " + spec[i+3])
+			continue
+		}
+		limits[class] = OutputBufferLimit{
+			HardBytes:    hard,
+			SoftBytes:    soft,
+			SoftDuration: time.Duration(seconds) * time.Second,
+		}
+	}
+	return limits
+}
+
+// memoryUnits are units of memory settings like redis.conf, "k" is 1000 bytes while "kb" is 1024 bytes
+var memoryUnits = []struct {
+	suffix string
+	unit   int64
+}{
+	{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+	{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+// parseMemory parses memory settings like "32mb", "1gb" or "4096"
+func parseMemory(s string) (int64, error) {
+	lower := strings.ToLower(s)
+	unit := int64(1)
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(lower, u.suffix) {
+			lower = strings.TrimSuffix(lower, u.suffix)
+			unit = u.unit
+			break
+		}
+	}
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("illegal memory size " + s)
+	}
+	return n * unit, nil
+}
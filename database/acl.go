@@ -0,0 +1,396 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// Permissions of ACL users are checked by Server.Exec before a command is executed or queued:
+// the command (or its subcommand), keys it reads or writes and channels it publishes or subscribes.
+// Keys are found by the same key specs locking them, a written key needs the write permission
+// and a read key needs the read permission. A written key the command also reads, like the list popped by LPOP,
+// needs both of them, see command.accessedKeys.
+
+func init() {
+	// categories of commands executed by Server.Exec rather than a single db
+	serverCommands := map[string]acl.Category{
+		"multi":          acl.CategoryTransaction,
+		"exec":           acl.CategoryTransaction,
+		"discard":        acl.CategoryTransaction,
+		"watch":          acl.CategoryTransaction,
+		"unwatch":        acl.CategoryTransaction,
+		"select":         acl.CategoryConnection,
+		"client":         acl.CategoryConnection,
+		"client|unblock": acl.CategoryAdmin | acl.CategoryDangerous,
+		"hello":          acl.CategoryConnection,
+		"auth":           acl.CategoryConnection,
+		"quit":           acl.CategoryConnection,
+		"flushall":       acl.CategoryKeyspace | acl.CategoryWrite | acl.CategoryDangerous,
+		"subscribe":      acl.CategoryPubSub,
+		"unsubscribe":    acl.CategoryPubSub,
+		"psubscribe":     acl.CategoryPubSub,
+		"punsubscribe":   acl.CategoryPubSub,
+		"ssubscribe":     acl.CategoryPubSub,
+		"sunsubscribe":   acl.CategoryPubSub,
+		"acl":            acl.CategoryAdmin | acl.CategoryDangerous,
+		// unlike other subcommands of ACL they are not admin commands, so +@all -@admin allows them
+		// while -@all +@admin doesn't, the same as redis
+		"acl|whoami": 0,
+		"acl|cat":    0,
+	}
+	for name, categories := range serverCommands {
+		acl.RegisterCommand(name, categories)
+	}
+}
+
+// denial tells why a command is not permitted
+type denial struct {
+	// acl.ReasonCommand, acl.ReasonKey or acl.ReasonChannel
+	reason string
+	// the command, key or channel denied
+	object string
+}
+
+// errReply returns the error replied to the client
+func (d *denial) errReply(user *acl.User) protocol.ErrorReply {
+	switch d.reason {
+	case acl.ReasonKey:
+		return protocol.MakeErrReply("NOPERM No permissions to access a key")
+	case acl.ReasonChannel:
+		return protocol.MakeErrReply("NOPERM No permissions to access a channel")
+	}
+	return protocol.MakeErrReply("NOPERM User " + user.Name + " has no permissions to run the '" + d.object + "' command")
+}
+
+// String returns the reason of ACL DRYRUN
+func (d *denial) String() string {
+	switch d.reason {
+	case acl.ReasonKey:
+		return "This user has no permissions to access the '" + d.object + "' key"
+	case acl.ReasonChannel:
+		return "This user has no permissions to access the '" + d.object + "' channel"
+	}
+	return "This user has no permissions to run the '" + d.object + "' command"
+}
+
+// checkPermission returns why the user can't execute the command line, or nil if it can
+func checkPermission(user *acl.User, cmdName string, cmdLine [][]byte) *denial {
+	subcommand := ""
+	if len(cmdLine) > 1 {
+		subcommand = strings.ToLower(string(cmdLine[1]))
+	}
+	if !user.CanExecute(cmdName, subcommand) {
+		object := cmdName
+		if subcommand != "" && user.CanExecute(cmdName, "") {
+			object = cmdName + "|" + subcommand
+		}
+		return &denial{reason: acl.ReasonCommand, object: object}
+	}
+
+	writeKeys, readKeys := keysToCheck(cmdName, cmdLine)
+	for _, key := range writeKeys {
+		if !user.CanAccessKey(key, true) {
+			return &denial{reason: acl.ReasonKey, object: key}
+		}
+	}
+	for _, key := range readKeys {
+		if !user.CanAccessKey(key, false) {
+			return &denial{reason: acl.ReasonKey, object: key}
+		}
+	}
+
+	var channels [][]byte
+	isPattern := false
+	switch cmdName {
+	case "publish", "spublish":
+		if len(cmdLine) > 1 {
+			channels = cmdLine[1:2]
+		}
+	case "subscribe", "ssubscribe":
+		channels = cmdLine[1:]
+	case "psubscribe":
+		channels = cmdLine[1:]
+		isPattern = true
+	}
+	for _, channel := range channels {
+		if !user.CanAccessChannel(string(channel), isPattern) {
+			return &denial{reason: acl.ReasonChannel, object: string(channel)}
+		}
+	}
+	return nil
+}
+
+// keysToCheck returns keys written and read by the command line, nothing if the command doesn't exist
+// or has a wrong number of arguments, since it will be rejected anyway
+func keysToCheck(cmdName string, cmdLine [][]byte) (writeKeys []string, readKeys []string) {
+	if cmdName == "watch" {
+		return nil, toKeys(cmdLine[1:])
+	}
+	cmd, ok := cmdTable[cmdName]
+	if !ok || !validateArity(cmd.arity, cmdLine) {
+		return nil, nil
+	}
+	writeKeys, readKeys = cmd.keysOf(cmdLine)
+	if cmd.accessedKeys != nil {
+		readKeys = append(append([]string(nil), readKeys...), cmd.accessedKeys(cmdLine)...)
+	}
+	return writeKeys, readKeys
+}
+
+// userOf returns the user c authenticated as, or nil if c is not authenticated or the user was deleted
+func (server *Server) userOf(c redis.Connection) *acl.User {
+	name := c.GetUser()
+	if name == "" {
+		return nil
+	}
+	return server.acl.GetUser(name)
+}
+
+// checkACL returns an error reply if c is not authenticated or has no permission to execute the command line
+func (server *Server) checkACL(c redis.Connection, cmdName string, cmdLine [][]byte) redis.Reply {
+	if noAuthCommands[cmdName] {
+		return nil
+	}
+	user := server.userOf(c)
+	if user == nil {
+		return errNoAuth
+	}
+	d := checkPermission(user, cmdName, cmdLine)
+	if d == nil {
+		return nil
+	}
+	switch d.reason {
+	case acl.ReasonCommand:
+		server.stats.commandDenials.Add(1)
+	case acl.ReasonKey:
+		server.stats.keyDenials.Add(1)
+	case acl.ReasonChannel:
+		server.stats.channelDenials.Add(1)
+	}
+	server.acl.LogDenial(d.reason, logContext(c), d.object, user.Name, clientInfo(c))
+	errReply := d.errReply(user)
+	if c.InMultiState() {
+		// the transaction is discarded by EXEC, like a command rejected when queued
+		c.AddTxError(errReply)
+	}
+	return errReply
+}
+
+func logContext(c redis.Connection) string {
+	if c.InMultiState() {
+		return "multi"
+	}
+	return "toplevel"
+}
+
+// clientInfo describes the client for ACL LOG
+func clientInfo(c redis.Connection) string {
+	return fmt.Sprintf("id=%d addr=%s name=%s db=%d user=%s", c.ID(), c.RemoteAddr(), c.Name(), c.GetDBIndex(), c.GetUser())
+}
+
+// disconnectRemovedUsers closes clients authenticated as users which no longer exist
+func (server *Server) disconnectRemovedUsers() {
+	server.clients.Range(func(_, value any) bool {
+		c := value.(redis.Connection)
+		if name := c.GetUser(); name != "" && server.acl.GetUser(name) == nil {
+			// Close waits for pending output, so it doesn't block the command
+			go func() { _ = c.Close() }()
+		}
+		return true
+	})
+}
+
+// execACL dispatches the ACL sub commands
+//
+//	ACL SETUSER username [rule [rule ...]]
+//	ACL GETUSER username
+//	ACL DELUSER username [username ...]
+//	ACL LIST
+//	ACL USERS
+//	ACL WHOAMI
+//	ACL CAT [category]
+//	ACL LOG [count | RESET]
+//	ACL DRYRUN username command [arg [arg ...]]
+//	ACL SAVE
+//	ACL LOAD
+func execACL(server *Server, c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("acl")
+	}
+	rawSubCmd := string(args[0])
+	subCmd := strings.ToUpper(rawSubCmd)
+	args = args[1:]
+	switch subCmd {
+	case "SETUSER":
+		if len(args) < 1 {
+			return protocol.MakeArgNumErrReply("acl|setuser")
+		}
+		rules := make([]string, len(args)-1)
+		for i, arg := range args[1:] {
+			rules[i] = string(arg)
+		}
+		if err := server.acl.SetUser(string(args[0]), rules); err != nil {
+			return protocol.MakeErrReply("ERR " + err.Error())
+		}
+		return protocol.MakeOKReply()
+	case "GETUSER":
+		if len(args) != 1 {
+			return protocol.MakeArgNumErrReply("acl|getuser")
+		}
+		return execACLGetUser(server, string(args[0]))
+	case "DELUSER":
+		if len(args) < 1 {
+			return protocol.MakeArgNumErrReply("acl|deluser")
+		}
+		deleted, err := server.acl.DeleteUsers(toKeys(args))
+		if err != nil {
+			return protocol.MakeErrReply("ERR " + err.Error())
+		}
+		server.disconnectRemovedUsers()
+		return protocol.MakeIntReply(int64(deleted))
+	case "LIST":
+		if len(args) != 0 {
+			return protocol.MakeArgNumErrReply("acl|list")
+		}
+		return makeStringsReply(server.acl.List())
+	case "USERS":
+		if len(args) != 0 {
+			return protocol.MakeArgNumErrReply("acl|users")
+		}
+		return makeStringsReply(server.acl.Usernames())
+	case "WHOAMI":
+		if len(args) != 0 {
+			return protocol.MakeArgNumErrReply("acl|whoami")
+		}
+		return protocol.MakeBulkReply([]byte(c.GetUser()))
+	case "CAT":
+		if len(args) > 1 {
+			return protocol.MakeArgNumErrReply("acl|cat")
+		}
+		if len(args) == 0 {
+			return makeStringsReply(acl.CategoryNames())
+		}
+		names, ok := acl.CommandsInCategory(string(args[0]))
+		if !ok {
+			return protocol.MakeErrReply("ERR Unknown category '" + string(args[0]) + "'")
+		}
+		return makeStringsReply(names)
+	case "LOG":
+		return execACLLog(server, args)
+	case "DRYRUN":
+		return execACLDryRun(server, args)
+	case "SAVE", "LOAD":
+		if len(args) != 0 {
+			return protocol.MakeArgNumErrReply("acl|" + strings.ToLower(subCmd))
+		}
+		if subCmd == "SAVE" {
+			if err := server.acl.Save(); err != nil {
+				return protocol.MakeErrReply("ERR There was an error trying to save the ACLs. " +
+					"Please check the server logs for more information: " + err.Error())
+			}
+			return protocol.MakeOKReply()
+		}
+		if err := server.acl.Load(); err != nil {
+			return protocol.MakeErrReply("ERR " + err.Error() +
+				". WARNING: ACL errors detected, no change to the currently active ACL rules was performed")
+		}
+		server.disconnectRemovedUsers()
+		return protocol.MakeOKReply()
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + rawSubCmd + "'. Try ACL HELP.")
+}
+
+func makeStringsReply(strs []string) redis.Reply {
+	args := make([][]byte, len(strs))
+	for i, s := range strs {
+		args[i] = []byte(s)
+	}
+	return protocol.MakeMultiBulkReply(args)
+}
+
+// execACLGetUser returns flags, passwords, commands, keys and channels of the user
+func execACLGetUser(server *Server, name string) redis.Reply {
+	user := server.acl.GetUser(name)
+	if user == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("flags")), makeStringsReply(user.Flags()),
+		protocol.MakeBulkReply([]byte("passwords")), makeStringsReply(user.Passwords()),
+		protocol.MakeBulkReply([]byte("commands")), protocol.MakeBulkReply([]byte(user.DescribeCommands())),
+		protocol.MakeBulkReply([]byte("keys")), protocol.MakeBulkReply([]byte(user.DescribeKeys())),
+		protocol.MakeBulkReply([]byte("channels")), protocol.MakeBulkReply([]byte(user.DescribeChannels())),
+		protocol.MakeBulkReply([]byte("selectors")), protocol.MakeEmptyMultiBulkReply(),
+	})
+}
+
+// execACLLog returns the latest denials, or clears them
+//
+//	ACL LOG [count | RESET]
+func execACLLog(server *Server, args [][]byte) redis.Reply {
+	if len(args) > 1 {
+		return protocol.MakeArgNumErrReply("acl|log")
+	}
+	count := -1
+	if len(args) == 1 {
+		if strings.EqualFold(string(args[0]), "RESET") {
+			server.acl.ResetLog()
+			return protocol.MakeOKReply()
+		}
+		n, err := strconv.Atoi(string(args[0]))
+		if err != nil || n < 0 {
+			return protocol.MakeErrReply("ERR value is out of range, must be positive")
+		}
+		count = n
+	}
+	now := time.Now()
+	entries := server.acl.LogEntries(count)
+	replies := make([]redis.Reply, len(entries))
+	for i, e := range entries {
+		age := now.Sub(e.Created).Seconds()
+		replies[i] = protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte("count")), protocol.MakeIntReply(int64(e.Count)),
+			protocol.MakeBulkReply([]byte("reason")), protocol.MakeBulkReply([]byte(e.Reason)),
+			protocol.MakeBulkReply([]byte("context")), protocol.MakeBulkReply([]byte(e.Context)),
+			protocol.MakeBulkReply([]byte("object")), protocol.MakeBulkReply([]byte(e.Object)),
+			protocol.MakeBulkReply([]byte("username")), protocol.MakeBulkReply([]byte(e.Username)),
+			protocol.MakeBulkReply([]byte("age-seconds")), protocol.MakeBulkReply([]byte(strconv.FormatFloat(age, 'f', 3, 64))),
+			protocol.MakeBulkReply([]byte("client-info")), protocol.MakeBulkReply([]byte(e.ClientInfo)),
+			protocol.MakeBulkReply([]byte("entry-id")), protocol.MakeIntReply(e.EntryID),
+			protocol.MakeBulkReply([]byte("timestamp-created")), protocol.MakeIntReply(e.Created.UnixMilli()),
+			protocol.MakeBulkReply([]byte("timestamp-last-updated")), protocol.MakeIntReply(e.Updated.UnixMilli()),
+		})
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// execACLDryRun tells whether the user could execute the command without executing it
+//
+//	ACL DRYRUN username command [arg [arg ...]]
+func execACLDryRun(server *Server, args [][]byte) redis.Reply {
+	if len(args) < 2 {
+		return protocol.MakeArgNumErrReply("acl|dryrun")
+	}
+	user := server.acl.GetUser(string(args[0]))
+	if user == nil {
+		return protocol.MakeErrReply("ERR User '" + string(args[0]) + "' not found")
+	}
+	cmdLine := args[1:]
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	if !acl.CommandExists(cmdName) {
+		return protocol.MakeErrReply("ERR Command '" + cmdName + "' not found")
+	}
+	if cmd, ok := cmdTable[cmdName]; ok && !validateArity(cmd.arity, cmdLine) {
+		return protocol.MakeArgNumErrReply(cmdName)
+	}
+	if d := checkPermission(user, cmdName, cmdLine); d != nil {
+		return protocol.MakeBulkReply([]byte(d.String()))
+	}
+	return protocol.MakeOKReply()
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// connectAs creates a user with rules and returns a client authenticated as it
+func connectAs(t *testing.T, server *Server, name string, rules ...string) redis.Connection {
+	t.Helper()
+	admin := connect(server)
+	args := append([]string{"acl", "setuser", name, "reset", "on", ">pass"}, rules...)
+	assertReply(t, execCmd(server, admin, args...), protocol.MakeOKReply())
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "auth", name, "pass"), protocol.MakeOKReply())
+	return c
+}
+
+func TestACLCommands(t *testing.T) {
+	server := makeTestServer(t)
+	c := connectAs(t, server, "alice", "~*", "+@read", "+@transaction", "-hget", "+client|id")
+
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeNullBulkReply())
+	assertErr(t, execCmd(server, c, "set", "a", "1"), "NOPERM User alice has no permissions to run the 'set' command")
+	assertErr(t, execCmd(server, c, "hget", "h", "f"), "NOPERM User alice has no permissions to run the 'hget' command")
+	// subcommands are permitted one by one
+	if _, ok := execCmd(server, c, "client", "id").(*protocol.IntReply); !ok {
+		t.Fatal("client|id should be permitted")
+	}
+	assertErr(t, execCmd(server, c, "client", "list"), "NOPERM User alice has no permissions to run the 'client' command")
+	other := connectAs(t, server, "carol", "+client", "-client|list")
+	assertErr(t, execCmd(server, other, "client", "list"), "NOPERM User carol has no permissions to run the 'client|list' command")
+
+	// a denied command discards the transaction
+	execCmd(server, c, "multi")
+	execCmd(server, c, "get", "a")
+	assertErr(t, execCmd(server, c, "set", "a", "1"), "NOPERM")
+	assertErr(t, execCmd(server, c, "exec"), "EXECABORT")
+
+	// changes of the user apply to the authenticated client at once
+	admin := connect(server)
+	execCmd(server, admin, "acl", "setuser", "alice", "+set")
+	assertReply(t, execCmd(server, c, "set", "a", "1"), protocol.MakeOKReply())
+	execCmd(server, admin, "acl", "setuser", "alice", "-@all")
+	assertErr(t, execCmd(server, c, "get", "a"), "NOPERM")
+
+	assertErr(t, execCmd(server, connect(server), "auth", "alice", "wrong"), "WRONGPASS")
+	execCmd(server, admin, "acl", "setuser", "alice", "off")
+	assertErr(t, execCmd(server, connect(server), "auth", "alice", "pass"), "WRONGPASS")
+}
+
+func TestACLKeys(t *testing.T) {
+	server := makeTestServer(t)
+	c := connectAs(t, server, "bob", "+@all", "~rw:*", "%R~r:*", "%W~w:*")
+
+	for _, allowed := range [][]string{
+		{"set", "rw:1", "v"},
+		{"get", "rw:1"},
+		{"get", "r:1"},
+		{"exists", "r:1", "rw:1"},
+		{"set", "w:1", "v"},
+		{"rpush", "w:list", "a"},
+		{"del", "w:1"},
+		{"copy", "r:1", "w:2"},
+		{"lmove", "rw:src", "w:dst", "left", "right"},
+	} {
+		if reply, ok := execCmd(server, c, allowed...).(protocol.ErrorReply); ok {
+			t.Fatalf("%v should be permitted, got %s", allowed, reply.Error())
+		}
+	}
+	for _, denied := range [][]string{
+		{"get", "other"},
+		{"set", "r:1", "v"},
+		{"get", "w:1"},
+		{"set", "w:1", "v", "get"}, // GET reads the old value
+		{"lpop", "w:list"},         // popping returns the element
+		{"rename", "w:1", "w:2"},
+		{"copy", "w:1", "r:2"},
+		{"lmove", "r:src", "w:dst", "left", "right"},
+		{"watch", "w:1"},
+	} {
+		assertErr(t, execCmd(server, c, denied...), "NOPERM No permissions to access a key")
+	}
+
+	admin := connect(server)
+	assertReply(t, execCmd(server, admin, "acl", "dryrun", "bob", "get", "w:1"),
+		protocol.MakeBulkReply([]byte("This user has no permissions to access the 'w:1' key")))
+	assertReply(t, execCmd(server, admin, "acl", "dryrun", "bob", "set", "w:1", "v"), protocol.MakeOKReply())
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// Authentication, the same as redis: a client authenticates as an ACL user by AUTH or HELLO AUTH.
+// New clients are authenticated as the default user if it's enabled and has nopass,
+// otherwise they must authenticate before any command other than AUTH, HELLO and QUIT.
+// requirepass is the password of the default user.
+// The connection keeps the name of its user, so changes of the user apply to it at once,
+// and it's disconnected once the user is deleted
+
+var (
+	errNoAuth    = protocol.MakeErrReply("NOAUTH Authentication required.")
+	errWrongPass = protocol.MakeErrReply("WRONGPASS invalid username-password pair or user is disabled.")
+)
+
+// noAuthCommands can be executed before authentication. QUIT is handled by the connection handler
+var noAuthCommands = map[string]bool{
+	"auth":  true,
+	"hello": true,
+}
+
+// authenticate checks the username and password, and authenticates the connection as the user if they are correct
+func (server *Server) authenticate(c redis.Connection, username, password string) redis.Reply {
+	if _, ok := server.acl.Authenticate(username, password); !ok {
+		server.stats.authFailures.Add(1)
+		server.acl.LogDenial(acl.ReasonAuth, logContext(c), "AUTH", username, clientInfo(c))
+		return errWrongPass
+	}
+	c.SetUser(username)
+	return nil
+}
+
+// execAuth authenticates the connection, the user is "default" if username is omitted
+//
+//	AUTH [username] password
+func execAuth(server *Server, c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) != 1 && len(args) != 2 {
+		return protocol.MakeArgNumErrReply("auth")
+	}
+	if len(args) == 1 {
+		if defaultUser := server.acl.GetUser(acl.DefaultUser); defaultUser != nil && defaultUser.NoPass() {
+			return protocol.MakeErrReply("ERR AUTH <password> called without any password configured " +
+				"for the default user. Are you sure your configuration is correct?")
+		}
+		args = [][]byte{[]byte(acl.DefaultUser), args[0]}
+	}
+	if errReply := server.authenticate(c, string(args[0]), string(args[1])); errReply != nil {
+		return errReply
+	}
+	return protocol.MakeOKReply()
+}
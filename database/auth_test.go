@@ -0,0 +1,64 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// makeAuthServer returns a server whose default user has the password requirepass
+func makeAuthServer(t *testing.T, requirepass string) *Server {
+	t.Helper()
+	saved := config.Properties.RequirePass
+	config.Properties.RequirePass = requirepass
+	server := makeTestServer(t)
+	config.Properties.RequirePass = saved
+	return server
+}
+
+func TestRequirePass(t *testing.T) {
+	server := makeAuthServer(t, "secret")
+	c := connect(server)
+	assertErr(t, execCmd(server, c, "get", "a"), "NOAUTH Authentication required.")
+	assertErr(t, execCmd(server, c, "ping"), "NOAUTH Authentication required.")
+	// an unknown command is rejected before authentication
+	assertErr(t, execCmd(server, c, "nosuch"), "ERR unknown command 'nosuch'")
+	assertErr(t, execCmd(server, c, "hello", "3"), "NOAUTH HELLO must be called with the client already authenticated")
+
+	assertErr(t, execCmd(server, c, "auth", "wrong"), "WRONGPASS invalid username-password pair")
+	assertErr(t, execCmd(server, c, "auth", "default", "wrong"), "WRONGPASS invalid username-password pair")
+	assertErr(t, execCmd(server, c, "get", "a"), "NOAUTH Authentication required.")
+	assertReply(t, execCmd(server, c, "auth", "secret"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeNullBulkReply())
+
+	// HELLO authenticates and switches the protocol at the same time
+	c = connect(server)
+	assertErr(t, execCmd(server, c, "hello", "3", "auth", "default", "wrong"), "WRONGPASS")
+	if c.GetProtocolVersion() != 2 {
+		t.Fatal("a failed HELLO should not switch the protocol")
+	}
+	if _, ok := execCmd(server, c, "hello", "3", "auth", "default", "secret").(protocol.ErrorReply); ok {
+		t.Fatal("HELLO AUTH with the right password should succeed")
+	}
+	if c.GetProtocolVersion() != 3 {
+		t.Fatal("HELLO should switch the protocol")
+	}
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeNullBulkReply())
+
+	info := string(execCmd(server, c, "info", "stats").(*protocol.BulkReply).Arg)
+	if !strings.Contains(info, "acl_access_denied_auth:3\r\n") {
+		t.Fatalf("INFO should count 3 failed authentications:\n%s", info)
+	}
+}
+
+func TestAuthWithoutRequirePass(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	// new clients are authenticated as the default user with nopass
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeNullBulkReply())
+	assertErr(t, execCmd(server, c, "auth", "secret"), "ERR AUTH <password> called without any password configured")
+	assertErr(t, execCmd(server, c, "auth", "nobody", "secret"), "WRONGPASS")
+	assertErr(t, execCmd(server, c, "auth", "a", "b", "c"), "ERR wrong number of arguments for 'auth' command")
+}
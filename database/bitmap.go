@@ -0,0 +1,547 @@
+package database
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/datastruct/bitmap"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+var (
+	errBitOffset = protocol.MakeErrReply("ERR bit offset is not an integer or out of range")
+	errBitValue  = protocol.MakeErrReply("ERR bit is not an integer or out of range")
+)
+
+// parseBitOffset parses a bit offset, a string can hold at most maxStringSize*8 bits
+func parseBitOffset(arg []byte) (int64, protocol.ErrorReply) {
+	offset, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil || offset < 0 || offset>>3 >= maxStringSize {
+		return 0, errBitOffset
+	}
+	return offset, nil
+}
+
+// execSetBit sets the bit at offset and returns the old bit, the string grows as needed
+//
+//	SETBIT key offset value
+func execSetBit(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	offset, errReply := parseBitOffset(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	var val byte
+	switch string(args[2]) {
+	case "0":
+	case "1":
+		val = 1
+	default:
+		return errBitValue
+	}
+	str, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	bm := bitmap.BitMap(str).Grow(offset>>3 + 1)
+	old := bm.GetBit(offset)
+	bm.SetBit(offset, val)
+	db.putString(key, bm)
+	db.notifyKeyspaceEvent(notifyString, "setbit", key)
+	return protocol.MakeIntReply(int64(old))
+}
+
+// execGetBit returns the bit at offset, bits beyond the end of string are 0
+//
+//	GETBIT key offset
+func execGetBit(db *DB, args [][]byte) redis.Reply {
+	offset, errReply := parseBitOffset(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	str, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return protocol.MakeIntReply(int64(bitmap.BitMap(str).GetBit(offset)))
+}
+
+// parseBitRange parses `start end [BYTE | BIT]` of BITCOUNT and BITPOS and converts it to a bit range [start, end].
+// Negative offsets count from the end of the string, ok is false if the range is empty
+func parseBitRange(args [][]byte, bm bitmap.BitMap) (start int64, end int64, ok bool, errReply protocol.ErrorReply) {
+	start, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return 0, 0, false, errNotInteger
+	}
+	end, err = strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return 0, 0, false, errNotInteger
+	}
+	isBit := false
+	if len(args) == 3 {
+		switch strings.ToUpper(string(args[2])) {
+		case "BYTE":
+		case "BIT":
+			isBit = true
+		default:
+			return 0, 0, false, protocol.MakeSyntaxErrReply()
+		}
+	}
+
+	size := int64(len(bm))
+	if isBit {
+		size = bm.BitLen()
+	}
+	if start < 0 {
+		start = max(start+size, 0)
+	}
+	if end < 0 {
+		end = max(end+size, 0)
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if start > end {
+		return 0, 0, false, nil
+	}
+	if !isBit {
+		start, end = start*8, end*8+7
+	}
+	return start, end, true, nil
+}
+
+// execBitCount counts the 1 bits of the string, or of the byte or bit range
+//
+//	BITCOUNT key [start end [BYTE | BIT]]
+func execBitCount(db *DB, args [][]byte) redis.Reply {
+	if len(args) == 2 || len(args) > 4 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	str, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	bm := bitmap.BitMap(str)
+	start, end := int64(0), bm.BitLen()-1
+	if len(args) > 1 {
+		var ok bool
+		start, end, ok, errReply = parseBitRange(args[1:], bm)
+		if errReply != nil {
+			return errReply
+		}
+		if !ok {
+			return protocol.MakeIntReply(0)
+		}
+	}
+	return protocol.MakeIntReply(bm.Count(start, end))
+}
+
+// execBitPos returns the offset of the first bit equal to bit
+//
+//	BITPOS key bit [start [end [BYTE | BIT]]]
+//
+// Looking for a 0 bit without an explicit end treats the string as padded with zeros on the right,
+// so the first bit after the string is returned if all the bits are 1
+func execBitPos(db *DB, args [][]byte) redis.Reply {
+	if len(args) > 5 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	var bit byte
+	switch string(args[1]) {
+	case "0":
+	case "1":
+		bit = 1
+	default:
+		return protocol.MakeErrReply("ERR The bit argument must be 1 or 0.")
+	}
+	str, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if str == nil {
+		if bit == 1 {
+			return protocol.MakeIntReply(-1)
+		}
+		return protocol.MakeIntReply(0)
+	}
+
+	bm := bitmap.BitMap(str)
+	start, end := int64(0), bm.BitLen()-1
+	endGiven := len(args) > 3
+	if len(args) > 2 {
+		rangeArgs := [][]byte{args[2], []byte("-1")}
+		if endGiven {
+			rangeArgs = args[2:]
+		}
+		var ok bool
+		start, end, ok, errReply = parseBitRange(rangeArgs, bm)
+		if errReply != nil {
+			return errReply
+		}
+		if !ok {
+			return protocol.MakeIntReply(-1)
+		}
+	}
+	pos := bm.Find(bit, start, end)
+	if pos < 0 && bit == 0 && !endGiven {
+		pos = end + 1
+	}
+	return protocol.MakeIntReply(pos)
+}
+
+// bitopKeys returns keys of BITOP, the first argument is the operation
+func bitopKeys(args [][]byte) ([]string, []string) {
+	return writeFirstReadOthers(args[1:])
+}
+
+// execBitOp performs a bitwise operation between strings and stores the result in destkey.
+// Missing keys and shorter strings are treated as padded with zero bytes
+//
+//	BITOP AND | OR | XOR | NOT destkey key [key ...]
+func execBitOp(db *DB, args [][]byte) redis.Reply {
+	op := strings.ToUpper(string(args[0]))
+	dest := string(args[1])
+	keys := args[2:]
+	switch op {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(keys) != 1 {
+			return protocol.MakeErrReply("ERR BITOP NOT must be called with a single source key.")
+		}
+	default:
+		return protocol.MakeSyntaxErrReply()
+	}
+
+	sources := make([][]byte, len(keys))
+	maxLen := 0
+	for i, key := range keys {
+		str, errReply := db.getAsString(string(key))
+		if errReply != nil {
+			return errReply
+		}
+		sources[i] = str
+		maxLen = max(maxLen, len(str))
+	}
+	if maxLen == 0 {
+		db.deleteKey(dest)
+		return protocol.MakeIntReply(0)
+	}
+
+	result := make([]byte, maxLen)
+	for i := range result {
+		var b byte
+		for j, src := range sources {
+			var v byte
+			if i < len(src) {
+				v = src[i]
+			}
+			if j == 0 {
+				b = v
+				continue
+			}
+			switch op {
+			case "AND":
+				b &= v
+			case "OR":
+				b |= v
+			case "XOR":
+				b ^= v
+			}
+		}
+		if op == "NOT" {
+			b = ^b
+		}
+		result[i] = b
+	}
+	db.untrackFieldTTL(dest)
+	db.putString(dest, result)
+	db.notifyKeyspaceEvent(notifyString, "set", dest)
+	return protocol.MakeIntReply(int64(maxLen))
+}
+
+/* ---- BITFIELD ---- */
+
+// overflow behaviors of BITFIELD
+const (
+	overflowWrap = iota
+	overflowSat
+	overflowFail
+)
+
+// bitfield operations
+const (
+	bitfieldGet = iota
+	bitfieldSet
+	bitfieldIncrBy
+)
+
+// bitfieldOp is a GET, SET or INCRBY sub command of BITFIELD
+type bitfieldOp struct {
+	op       int
+	signed   bool
+	width    int
+	offset   int64
+	value    int64 // the value of SET or the increment of INCRBY
+	overflow int
+}
+
+var errBitfieldType = protocol.MakeErrReply("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+
+// parseBitfieldType parses types like i8 or u16
+func parseBitfieldType(arg []byte) (signed bool, width int, errReply protocol.ErrorReply) {
+	if len(arg) < 2 {
+		return false, 0, errBitfieldType
+	}
+	switch arg[0] {
+	case 'i', 'I':
+		signed = true
+	case 'u', 'U':
+	default:
+		return false, 0, errBitfieldType
+	}
+	w, err := strconv.Atoi(string(arg[1:]))
+	if err != nil || w < 1 || (signed && w > 64) || (!signed && w > 63) {
+		return false, 0, errBitfieldType
+	}
+	return signed, w, nil
+}
+
+// parseBitfieldOffset parses an offset, #N means N times the width of the type
+func parseBitfieldOffset(arg []byte, width int) (int64, protocol.ErrorReply) {
+	multiply := len(arg) > 0 && arg[0] == '#'
+	if multiply {
+		arg = arg[1:]
+	}
+	offset, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, errBitOffset
+	}
+	if multiply {
+		if offset > math.MaxInt64/int64(width) {
+			return 0, errBitOffset
+		}
+		offset *= int64(width)
+	}
+	if (offset+int64(width)-1)>>3 >= maxStringSize {
+		return 0, errBitOffset
+	}
+	return offset, nil
+}
+
+// parseBitfieldOps parses sub commands of BITFIELD, readOnly allows GET only
+func parseBitfieldOps(args [][]byte, readOnly bool) ([]*bitfieldOp, protocol.ErrorReply) {
+	var ops []*bitfieldOp
+	overflow := overflowWrap
+	for i := 0; i < len(args); {
+		sub := strings.ToUpper(string(args[i]))
+		if sub == "OVERFLOW" {
+			if i+1 >= len(args) {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			switch strings.ToUpper(string(args[i+1])) {
+			case "WRAP":
+				overflow = overflowWrap
+			case "SAT":
+				overflow = overflowSat
+			case "FAIL":
+				overflow = overflowFail
+			default:
+				return nil, protocol.MakeErrReply("ERR Invalid OVERFLOW type specified")
+			}
+			i += 2
+			continue
+		}
+
+		op := &bitfieldOp{overflow: overflow}
+		argNum := 3
+		switch sub {
+		case "GET":
+			op.op = bitfieldGet
+		case "SET":
+			op.op = bitfieldSet
+			argNum = 4
+		case "INCRBY":
+			op.op = bitfieldIncrBy
+			argNum = 4
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+		if i+argNum > len(args) {
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+		if readOnly && op.op != bitfieldGet {
+			return nil, protocol.MakeErrReply("ERR BITFIELD_RO only supports the GET subcommand")
+		}
+		var errReply protocol.ErrorReply
+		op.signed, op.width, errReply = parseBitfieldType(args[i+1])
+		if errReply != nil {
+			return nil, errReply
+		}
+		op.offset, errReply = parseBitfieldOffset(args[i+2], op.width)
+		if errReply != nil {
+			return nil, errReply
+		}
+		if argNum == 4 {
+			value, err := strconv.ParseInt(string(args[i+3]), 10, 64)
+			if err != nil {
+				return nil, errNotInteger
+			}
+			op.value = value
+		}
+		ops = append(ops, op)
+		i += argNum
+	}
+	return ops, nil
+}
+
+// unsignedOverflow returns the result of value+incr for a width bits unsigned integer,
+// ok is false if it overflows under FAIL
+func unsignedOverflow(value uint64, incr int64, width int, overflow int) (result uint64, ok bool) {
+	maxValue := uint64(1)<<width - 1
+	maxIncr := int64(maxValue - value)
+	minIncr := -int64(value)
+	if value > maxValue || incr > maxIncr || incr < minIncr {
+		switch overflow {
+		case overflowFail:
+			return 0, false
+		case overflowSat:
+			if value > maxValue || incr > maxIncr {
+				return maxValue, true
+			}
+			return 0, true
+		}
+	}
+	// WRAP, or no overflow at all
+	return (value + uint64(incr)) & maxValue, true
+}
+
+// signedOverflow returns the result of value+incr for a width bits signed integer,
+// ok is false if it overflows under FAIL
+func signedOverflow(value int64, incr int64, width int, overflow int) (result int64, ok bool) {
+	maxValue := int64(math.MaxInt64)
+	if width < 64 {
+		maxValue = int64(1)<<(width-1) - 1
+	}
+	minValue := -maxValue - 1
+	maxIncr := maxValue - value
+	minIncr := minValue - value
+	var overflowed, underflowed bool
+	if value > maxValue || (width != 64 && incr > maxIncr) || (value >= 0 && incr > 0 && incr > maxIncr) {
+		overflowed = true
+	} else if value < minValue || (width != 64 && incr < minIncr) || (value < 0 && incr < 0 && incr < minIncr) {
+		underflowed = true
+	}
+	if overflowed || underflowed {
+		switch overflow {
+		case overflowFail:
+			return 0, false
+		case overflowSat:
+			if overflowed {
+				return maxValue, true
+			}
+			return minValue, true
+		}
+	}
+	// WRAP, or no overflow at all
+	sum := uint64(value) + uint64(incr)
+	if width < 64 {
+		mask := ^uint64(0) << width
+		if sum&(1<<(width-1)) != 0 {
+			sum |= mask
+		} else {
+			sum &^= mask
+		}
+	}
+	return int64(sum), true
+}
+
+// execBitfieldGeneric runs sub commands of BITFIELD in order and replies their results
+//
+//	BITFIELD key [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP | SAT | FAIL]
+//	BITFIELD_RO key [GET type offset ...]
+//
+// type is i1 to i64 or u1 to u63, offset #N means N times the width of type.
+// OVERFLOW affects the SET and INCRBY after it, FAIL makes the operation do nothing and reply nil
+func execBitfieldGeneric(db *DB, args [][]byte, readOnly bool) redis.Reply {
+	key := string(args[0])
+	ops, errReply := parseBitfieldOps(args[1:], readOnly)
+	if errReply != nil {
+		return errReply
+	}
+	str, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	bm := bitmap.BitMap(str)
+	written := false
+	replies := make([]redis.Reply, len(ops))
+	for i, op := range ops {
+		if op.op == bitfieldGet {
+			if op.signed {
+				replies[i] = protocol.MakeIntReply(bm.GetSigned(op.offset, op.width))
+			} else {
+				replies[i] = protocol.MakeIntReply(int64(bm.GetUnsigned(op.offset, op.width)))
+			}
+			continue
+		}
+
+		bm = bm.Grow((op.offset+int64(op.width)-1)>>3 + 1)
+		written = true
+		var old, result int64
+		var ok bool
+		if op.signed {
+			old = bm.GetSigned(op.offset, op.width)
+			if op.op == bitfieldSet {
+				result, ok = signedOverflow(op.value, 0, op.width, op.overflow)
+			} else {
+				result, ok = signedOverflow(old, op.value, op.width, op.overflow)
+			}
+		} else {
+			old = int64(bm.GetUnsigned(op.offset, op.width))
+			var uresult uint64
+			if op.op == bitfieldSet {
+				uresult, ok = unsignedOverflow(uint64(op.value), 0, op.width, op.overflow)
+			} else {
+				uresult, ok = unsignedOverflow(uint64(old), op.value, op.width, op.overflow)
+			}
+			result = int64(uresult)
+		}
+		if !ok {
+			replies[i] = protocol.MakeNullBulkReply()
+			continue
+		}
+		bm.SetUnsigned(op.offset, op.width, uint64(result))
+		if op.op == bitfieldSet {
+			replies[i] = protocol.MakeIntReply(old)
+		} else {
+			replies[i] = protocol.MakeIntReply(result)
+		}
+	}
+	if written {
+		db.putString(key, bm)
+		db.notifyKeyspaceEvent(notifyString, "setbit", key)
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+func execBitfield(db *DB, args [][]byte) redis.Reply {
+	return execBitfieldGeneric(db, args, false)
+}
+
+func execBitfieldRO(db *DB, args [][]byte) redis.Reply {
+	return execBitfieldGeneric(db, args, true)
+}
+
+func init() {
+	registerCommand("SetBit", execSetBit, 4, flagWrite, acl.CategoryWrite|acl.CategoryBitmap).setAccessWritten()
+	registerCommand("GetBit", execGetBit, 3, flagReadOnly, acl.CategoryRead|acl.CategoryBitmap)
+	registerCommand("BitCount", execBitCount, -2, flagReadOnly, acl.CategoryRead|acl.CategoryBitmap)
+	registerCommand("BitPos", execBitPos, -3, flagReadOnly, acl.CategoryRead|acl.CategoryBitmap)
+	registerCommand("BitOp", execBitOp, -4, flagWrite, acl.CategoryWrite|acl.CategoryBitmap).setKeysFunc(bitopKeys)
+	registerCommand("BitField", execBitfield, -2, flagWrite, acl.CategoryWrite|acl.CategoryBitmap).setAccessWritten()
+	registerCommand("BitField_RO", execBitfieldRO, -2, flagReadOnly, acl.CategoryRead|acl.CategoryBitmap)
+}
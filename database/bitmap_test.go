@@ -0,0 +1,157 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func TestSetBit(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "setbit", "k", "7", "1"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "setbit", "k", "7", "1"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "get", "k"), protocol.MakeBulkReply([]byte{0x01}))
+	// the string grows with zero bytes
+	assertReply(t, execCmd(server, c, "setbit", "k", "16", "1"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "get", "k"), protocol.MakeBulkReply([]byte{0x01, 0x00, 0x80}))
+	assertReply(t, execCmd(server, c, "getbit", "k", "16"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "getbit", "k", "15"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "getbit", "k", "1000"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "getbit", "nokey", "0"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "setbit", "k", "7", "0"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "getbit", "k", "7"), protocol.MakeIntReply(0))
+
+	assertErr(t, execCmd(server, c, "setbit", "k", "-1", "1"), "ERR bit offset is not an integer or out of range")
+	assertErr(t, execCmd(server, c, "setbit", "k", "4294967296", "1"), "ERR bit offset is not an integer or out of range")
+	assertErr(t, execCmd(server, c, "setbit", "k", "0", "2"), "ERR bit is not an integer or out of range")
+	execCmd(server, c, "rpush", "list", "a")
+	assertReply(t, execCmd(server, c, "setbit", "list", "0", "1"), protocol.MakeWrongTypeErrReply())
+	assertReply(t, execCmd(server, c, "getbit", "list", "0"), protocol.MakeWrongTypeErrReply())
+}
+
+func TestBitCount(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "set", "k", "foobar")
+	assertReply(t, execCmd(server, c, "bitcount", "k"), protocol.MakeIntReply(26))
+	assertReply(t, execCmd(server, c, "bitcount", "k", "0", "0"), protocol.MakeIntReply(4))
+	assertReply(t, execCmd(server, c, "bitcount", "k", "1", "1"), protocol.MakeIntReply(6))
+	assertReply(t, execCmd(server, c, "bitcount", "k", "1", "1", "byte"), protocol.MakeIntReply(6))
+	assertReply(t, execCmd(server, c, "bitcount", "k", "5", "30", "bit"), protocol.MakeIntReply(17))
+	assertReply(t, execCmd(server, c, "bitcount", "k", "-2", "-1"), protocol.MakeIntReply(7))
+	assertReply(t, execCmd(server, c, "bitcount", "k", "4", "2"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "bitcount", "k", "0", "100"), protocol.MakeIntReply(26))
+	assertReply(t, execCmd(server, c, "bitcount", "nokey"), protocol.MakeIntReply(0))
+	assertErr(t, execCmd(server, c, "bitcount", "k", "0"), "ERR syntax error")
+	assertErr(t, execCmd(server, c, "bitcount", "k", "0", "1", "word"), "ERR syntax error")
+}
+
+func TestBitPos(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "set", "k", "\xff\xf0\x00")
+	assertReply(t, execCmd(server, c, "bitpos", "k", "0"), protocol.MakeIntReply(12))
+	execCmd(server, c, "set", "k", "\x00\xff\xf0")
+	assertReply(t, execCmd(server, c, "bitpos", "k", "1", "0"), protocol.MakeIntReply(8))
+	assertReply(t, execCmd(server, c, "bitpos", "k", "1", "2"), protocol.MakeIntReply(16))
+	assertReply(t, execCmd(server, c, "bitpos", "k", "1", "2", "-1", "byte"), protocol.MakeIntReply(16))
+	assertReply(t, execCmd(server, c, "bitpos", "k", "1", "7", "15", "bit"), protocol.MakeIntReply(8))
+
+	// looking for 0 pads the string with zeros, unless the range has an explicit end
+	execCmd(server, c, "set", "k", "\xff\xff")
+	assertReply(t, execCmd(server, c, "bitpos", "k", "0"), protocol.MakeIntReply(16))
+	assertReply(t, execCmd(server, c, "bitpos", "k", "0", "1"), protocol.MakeIntReply(16))
+	assertReply(t, execCmd(server, c, "bitpos", "k", "0", "0", "-1"), protocol.MakeIntReply(-1))
+	assertReply(t, execCmd(server, c, "bitpos", "nokey", "0"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "bitpos", "nokey", "1"), protocol.MakeIntReply(-1))
+	assertErr(t, execCmd(server, c, "bitpos", "k", "2"), "ERR The bit argument must be 1 or 0.")
+}
+
+func TestBitOp(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "set", "a", "foobar")
+	execCmd(server, c, "set", "b", "abcdef")
+
+	for _, tt := range []struct {
+		op       string
+		expected string
+	}{
+		{"and", "`bc`ab"},
+		{"or", "goofev"},
+		{"xor", "\x07\r\x0c\x06\x04\x14"},
+	} {
+		assertReply(t, execCmd(server, c, "bitop", tt.op, "dest", "a", "b"), protocol.MakeIntReply(6))
+		assertReply(t, execCmd(server, c, "get", "dest"), protocol.MakeBulkReply([]byte(tt.expected)))
+	}
+	// shorter strings and missing keys are padded with zero bytes
+	execCmd(server, c, "set", "short", "\xff")
+	assertReply(t, execCmd(server, c, "bitop", "or", "dest", "short", "nokey", "a"), protocol.MakeIntReply(6))
+	assertReply(t, execCmd(server, c, "get", "dest"), protocol.MakeBulkReply([]byte("\xffoobar")))
+	assertReply(t, execCmd(server, c, "bitop", "and", "dest", "short", "a"), protocol.MakeIntReply(6))
+	assertReply(t, execCmd(server, c, "get", "dest"), protocol.MakeBulkReply([]byte("f\x00\x00\x00\x00\x00")))
+	assertReply(t, execCmd(server, c, "bitop", "not", "dest", "short"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "get", "dest"), protocol.MakeBulkReply([]byte{0x00}))
+	// the destination is deleted if all sources are empty
+	assertReply(t, execCmd(server, c, "bitop", "or", "dest", "nokey"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "exists", "dest"), protocol.MakeIntReply(0))
+
+	assertErr(t, execCmd(server, c, "bitop", "not", "dest", "a", "b"), "ERR BITOP NOT must be called with a single source key.")
+	assertErr(t, execCmd(server, c, "bitop", "nand", "dest", "a"), "ERR syntax error")
+	execCmd(server, c, "rpush", "list", "a")
+	assertReply(t, execCmd(server, c, "bitop", "and", "dest", "a", "list"), protocol.MakeWrongTypeErrReply())
+}
+
+// nilOrInts returns an array of integers like replies of BITFIELD, nil values are null replies
+func nilOrInts(values ...any) redis.Reply {
+	replies := make([]redis.Reply, len(values))
+	for i, value := range values {
+		if value == nil {
+			replies[i] = protocol.MakeNullBulkReply()
+		} else {
+			replies[i] = protocol.MakeIntReply(int64(value.(int)))
+		}
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+func TestBitfield(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "bitfield", "k", "incrby", "i5", "100", "1", "get", "u4", "0"), nilOrInts(1, 0))
+	assertReply(t, execCmd(server, c, "bitfield", "k", "get", "i5", "100"), nilOrInts(1))
+
+	// SET returns the old value, #N offsets are multiplied by the width
+	assertReply(t, execCmd(server, c, "bitfield", "b", "set", "u8", "#1", "200", "get", "u8", "8"), nilOrInts(0, 200))
+	assertReply(t, execCmd(server, c, "get", "b"), protocol.MakeBulkReply([]byte{0x00, 200}))
+	assertReply(t, execCmd(server, c, "bitfield", "b", "get", "i8", "8", "get", "u4", "8", "get", "i4", "8"), nilOrInts(-56, 12, -4))
+	assertReply(t, execCmd(server, c, "bitfield", "b", "set", "i64", "0", "-1", "get", "i64", "0"), nilOrInts(200<<48, -1))
+
+	// overflows of unsigned integers
+	execCmd(server, c, "del", "u")
+	for _, expected := range []redis.Reply{nilOrInts(1, 1), nilOrInts(2, 2), nilOrInts(3, 3), nilOrInts(0, 3)} {
+		assertReply(t, execCmd(server, c, "bitfield", "u", "incrby", "u2", "100", "1", "overflow", "sat", "incrby", "u2", "102", "1"), expected)
+	}
+	assertReply(t, execCmd(server, c, "bitfield", "u", "overflow", "fail", "incrby", "u2", "102", "1"), nilOrInts(nil))
+	assertReply(t, execCmd(server, c, "bitfield", "u", "overflow", "sat", "incrby", "u2", "102", "-5"), nilOrInts(0))
+	assertReply(t, execCmd(server, c, "bitfield", "u", "set", "u8", "0", "-1", "get", "u8", "0"), nilOrInts(0, 255))
+
+	// overflows of signed integers
+	assertReply(t, execCmd(server, c, "bitfield", "s", "set", "i8", "0", "127", "incrby", "i8", "0", "1"), nilOrInts(0, -128))
+	assertReply(t, execCmd(server, c, "bitfield", "s", "overflow", "sat", "incrby", "i8", "0", "-1", "incrby", "i8", "0", "300"), nilOrInts(-128, 127))
+	assertReply(t, execCmd(server, c, "bitfield", "s", "overflow", "fail", "incrby", "i8", "0", "1", "get", "i8", "0"), nilOrInts(nil, 127))
+	assertReply(t, execCmd(server, c, "bitfield", "s", "overflow", "sat", "set", "i8", "0", "1000", "get", "i8", "0"), nilOrInts(127, 127))
+	assertReply(t, execCmd(server, c, "bitfield", "s", "set", "i64", "0", "9223372036854775807", "incrby", "i64", "0", "1"),
+		nilOrInts(127<<56, -9223372036854775808))
+	assertReply(t, execCmd(server, c, "bitfield", "s", "overflow", "sat", "incrby", "i64", "0", "-1"), nilOrInts(-9223372036854775808))
+
+	assertReply(t, execCmd(server, c, "bitfield_ro", "s", "get", "u8", "0"), nilOrInts(128))
+	assertErr(t, execCmd(server, c, "bitfield_ro", "s", "set", "u8", "0", "1"), "ERR BITFIELD_RO only supports the GET subcommand")
+	assertErr(t, execCmd(server, c, "bitfield", "s", "get", "u64", "0"), "ERR Invalid bitfield type")
+	assertErr(t, execCmd(server, c, "bitfield", "s", "get", "x8", "0"), "ERR Invalid bitfield type")
+	assertErr(t, execCmd(server, c, "bitfield", "s", "get", "u8", "-1"), "ERR bit offset is not an integer or out of range")
+	assertErr(t, execCmd(server, c, "bitfield", "s", "overflow", "x"), "ERR Invalid OVERFLOW type specified")
+	assertErr(t, execCmd(server, c, "bitfield", "s", "get", "u8"), "ERR syntax error")
+}
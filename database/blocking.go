@@ -0,0 +1,230 @@
+package database
+
+import (
+	"container/list"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// Blocking commands (BLPOP, BLMOVE ...) work as below:
+//
+//  1. the executor tries to serve immediately, if there is nothing to pop it returns a *waiter
+//  2. DB.Exec enqueues the waiter into the FIFO queue of every key it is waiting for
+//  3. the connection goroutine calls waiter.Wait outside the db lock, so a blocked client costs no cpu
+//  4. when a write command creates a key someone waits for, the key is marked as ready.
+//     After the command (or the whole transaction) finished and released its key locks,
+//     serveReadyKeys takes the write lock of db and serves the waiters of ready keys in the order they were blocked.
+//     A command running in between may take the pushed elements first, then the waiters just keep waiting.
+//
+// The waiter is enqueued before the blocking command releases its key locks,
+// so a push to the key always happens after the waiter is enqueued and never gets missed.
+
+// waiter is a client blocked by a blocking command, it is also the reply returned by the executor
+type waiter struct {
+	db       *DB
+	clientID int64
+	keys     []string
+	timeout  time.Duration // 0 means blocking forever
+	// serve tries to execute the command on the given ready key, returns nil if it still has to wait.
+	// serve is called with the write lock of db.mu held
+	serve func(key string) redis.Reply
+	// timeoutReply is returned when timed out or the command is called within a transaction
+	timeoutReply redis.Reply
+	// keys written by the command, their versions are increased when the waiter is served
+	writeKeys []string
+
+	// fields below are modified with the write lock of db.mu held, except that block initializes them
+	done  bool
+	nodes map[string]*list.Element // key -> position in the queue of key
+	ch    chan redis.Reply         // receives exactly one reply
+}
+
+// makeWaiter creates a waiter, duplicated keys will be waited only once
+func makeWaiter(keys []string, timeout time.Duration, timeoutReply redis.Reply, serve func(key string) redis.Reply) *waiter {
+	distinct := make([]string, 0, len(keys))
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		distinct = append(distinct, key)
+	}
+	return &waiter{
+		keys:         distinct,
+		timeout:      timeout,
+		serve:        serve,
+		timeoutReply: timeoutReply,
+		ch:           make(chan redis.Reply, 1),
+	}
+}
+
+// skipWrongType wraps serve of a waiter which only accesses the ready key.
+// A key holding another type is not ready, so the client keeps blocking the same as redis
+// instead of being woken up by WRONGTYPE
+func skipWrongType(serve func(key string) redis.Reply) func(key string) redis.Reply {
+	return func(key string) redis.Reply {
+		result := serve(key)
+		if _, ok := result.(*protocol.WrongTypeErrReply); ok {
+			return nil
+		}
+		return result
+	}
+}
+
+// ToBytes marshal redis.Reply, a waiter is never sent to client directly
+func (w *waiter) ToBytes() []byte {
+	return w.timeoutReply.ToBytes()
+}
+
+// Wait blocks until the waiter is served, timed out, unblocked or cancelled
+func (w *waiter) Wait(cancel <-chan struct{}) redis.Reply {
+	var timeoutCh <-chan time.Time
+	if w.timeout > 0 {
+		timer := time.NewTimer(w.timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+	select {
+	case reply := <-w.ch:
+		return reply
+	case <-timeoutCh:
+	case <-cancel:
+	}
+	// the waiter may be served just before we unblock it, in that case the served reply is in ch
+	w.db.unblock(w, w.timeoutReply)
+	return <-w.ch
+}
+
+// block enqueues the waiter of client c, must be called with locks of the waiting keys held
+func (db *DB) block(c redis.Connection, w *waiter) {
+	w.db = db
+	w.clientID = c.ID()
+	w.nodes = make(map[string]*list.Element, len(w.keys))
+	db.auxMu.Lock()
+	defer db.auxMu.Unlock()
+	for _, key := range w.keys {
+		queue := db.blockingKeys[key]
+		if queue == nil {
+			queue = list.New()
+			db.blockingKeys[key] = queue
+		}
+		w.nodes[key] = queue.PushBack(w)
+	}
+	db.blockedClients.Store(w.clientID, w)
+}
+
+// finishWaiter dequeues the waiter and sends the reply to it, must be called with the write lock of db.mu held
+func (db *DB) finishWaiter(w *waiter, reply redis.Reply) {
+	w.done = true
+	db.auxMu.Lock()
+	for key, node := range w.nodes {
+		queue := db.blockingKeys[key]
+		queue.Remove(node)
+		if queue.Len() == 0 {
+			delete(db.blockingKeys, key)
+		}
+	}
+	db.auxMu.Unlock()
+	w.nodes = nil
+	db.blockedClients.CompareAndDelete(w.clientID, w)
+	w.ch <- reply
+}
+
+// unblock finishes the waiter with the given reply, returns false if the waiter has finished already
+func (db *DB) unblock(w *waiter, reply redis.Reply) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if w.done {
+		return false
+	}
+	db.finishWaiter(w, reply)
+	return true
+}
+
+// signalKeyAsReady marks a new created key as ready if some clients are waiting for it
+func (db *DB) signalKeyAsReady(key string) {
+	db.auxMu.Lock()
+	defer db.auxMu.Unlock()
+	if _, ok := db.blockingKeys[key]; !ok {
+		return
+	}
+	if _, ok := db.readyKeySet[key]; ok {
+		return
+	}
+	db.readyKeySet[key] = struct{}{}
+	db.readyKeys = append(db.readyKeys, key)
+}
+
+// serveReadyKeys serves clients blocked on ready keys if there is any,
+// it must be called without holding any lock of db
+func (db *DB) serveReadyKeys() {
+	db.auxMu.Lock()
+	ready := len(db.readyKeys) > 0
+	db.auxMu.Unlock()
+	if !ready {
+		return
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.handleReadyKeys()
+}
+
+// takeReadyKeys returns and clears ready keys
+func (db *DB) takeReadyKeys() []string {
+	db.auxMu.Lock()
+	defer db.auxMu.Unlock()
+	keys := db.readyKeys
+	db.readyKeys = nil
+	db.readyKeySet = make(map[string]struct{})
+	return keys
+}
+
+// handleReadyKeys serves clients blocked on ready keys in FIFO order, must be called with the write lock of db.mu held.
+// Serving a client may make more keys ready (e.g. BLMOVE), so it loops until there is no ready key
+func (db *DB) handleReadyKeys() {
+	for keys := db.takeReadyKeys(); len(keys) > 0; keys = db.takeReadyKeys() {
+		for _, key := range keys {
+			// queues are only modified with the write lock of db.mu or auxMu held, so it is safe to iterate here
+			queue := db.blockingKeys[key]
+			if queue == nil {
+				continue
+			}
+			for node := queue.Front(); node != nil; {
+				next := node.Next()
+				if _, exists := db.GetEntity(key); !exists {
+					break
+				}
+				w := node.Value.(*waiter)
+				if reply := w.serve(key); reply != nil {
+					db.signalWrittenKeys(w.clientID, w.writeKeys...)
+					db.finishWaiter(w, db.tracking.withSelfInvalidations(w.clientID, reply))
+				}
+				node = next
+			}
+		}
+	}
+}
+
+// parseBlockTimeout parses timeout in seconds, fractional timeout like 0.1 is allowed
+func parseBlockTimeout(arg []byte) (time.Duration, protocol.ErrorReply) {
+	seconds, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, protocol.MakeErrReply("ERR timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return 0, protocol.MakeErrReply("ERR timeout is negative")
+	}
+	if seconds*float64(time.Second) >= math.MaxInt64 {
+		return 0, protocol.MakeErrReply("ERR timeout is out of range")
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout == 0 && seconds > 0 {
+		timeout = 1 // a tiny positive timeout must not turn into blocking forever
+	}
+	return timeout, nil
+}
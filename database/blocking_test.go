@@ -0,0 +1,120 @@
+package database
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// block executes a blocking command which has to wait
+func block(t *testing.T, server *Server, c redis.Connection, args ...string) *waiter {
+	t.Helper()
+	w, ok := execCmd(server, c, args...).(*waiter)
+	if !ok {
+		t.Fatalf("%v should block", args)
+	}
+	return w
+}
+
+// servedReply returns the reply of a served waiter, waiters are served before the write command returns
+func servedReply(t *testing.T, w *waiter) redis.Reply {
+	t.Helper()
+	if len(w.ch) == 0 {
+		t.Fatal("the client should be served")
+	}
+	return <-w.ch
+}
+
+func assertBlocked(t *testing.T, w *waiter) {
+	t.Helper()
+	if len(w.ch) > 0 {
+		t.Fatalf("the client should keep blocking, but it is served %q", (<-w.ch).ToBytes())
+	}
+}
+
+func TestBlockingWakeupOrder(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	w1 := block(t, server, connect(server), "blpop", "list", "0")
+	w2 := block(t, server, connect(server), "blpop", "other", "list", "0")
+	w3 := block(t, server, connect(server), "blpop", "list", "0")
+
+	assertReply(t, execCmd(server, c, "rpush", "list", "a"), protocol.MakeIntReply(1))
+	assertReply(t, servedReply(t, w1), bulks("list", "a"))
+	assertBlocked(t, w2)
+	assertBlocked(t, w3)
+
+	// clients are served in the order they were blocked, no matter which keys they wait for
+	execCmd(server, c, "rpush", "list", "b", "c", "d")
+	assertReply(t, servedReply(t, w2), bulks("list", "b"))
+	assertReply(t, servedReply(t, w3), bulks("list", "c"))
+	assertReply(t, execCmd(server, c, "lrange", "list", "0", "-1"), bulks("d"))
+}
+
+func TestBlockingAfterTransaction(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	w := block(t, server, connect(server), "blpop", "list", "0")
+
+	// the element pushed and popped within a transaction is never seen by the blocked client
+	execCmd(server, c, "multi")
+	execCmd(server, c, "rpush", "list", "a")
+	execCmd(server, c, "lpop", "list")
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeIntReply(1),
+		protocol.MakeBulkReply([]byte("a")),
+	}))
+	assertBlocked(t, w)
+
+	// a blocking command within a transaction returns its timeout reply at once
+	execCmd(server, c, "multi")
+	execCmd(server, c, "blpop", "empty", "0")
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeNullMultiBulkReply(),
+	}))
+
+	execCmd(server, c, "rpush", "list", "b")
+	assertReply(t, servedReply(t, w), bulks("list", "b"))
+}
+
+func TestBlockingWrongType(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	wList := block(t, server, connect(server), "blpop", "key", "0")
+	wZSet := block(t, server, connect(server), "bzpopmin", "key", "0")
+
+	// a key of another type doesn't wake up the clients with WRONGTYPE
+	execCmd(server, c, "set", "key", "value")
+	assertBlocked(t, wList)
+	assertBlocked(t, wZSet)
+
+	execCmd(server, c, "del", "key")
+	execCmd(server, c, "zadd", "key", "1", "m")
+	assertBlocked(t, wList)
+	assertReply(t, servedReply(t, wZSet), bulks("key", "m", "1"))
+
+	execCmd(server, c, "rpush", "key", "v") // WRONGTYPE, the key is still a zset
+	execCmd(server, c, "del", "key")
+	execCmd(server, c, "rpush", "key", "v")
+	assertReply(t, servedReply(t, wList), bulks("key", "v"))
+}
+
+func TestBlockingTimeoutAndUnblock(t *testing.T) {
+	server := makeTestServer(t)
+	w := block(t, server, connect(server), "blpop", "key", "0.01")
+	assertReply(t, w.Wait(nil), protocol.MakeNullMultiBulkReply())
+
+	blocked := connect(server)
+	w = block(t, server, blocked, "blpop", "key", "0")
+	c := connect(server)
+	id := strconv.FormatInt(blocked.ID(), 10)
+	assertReply(t, execCmd(server, c, "client", "unblock", id, "error"), protocol.MakeIntReply(1))
+	assertErr(t, servedReply(t, w), "UNBLOCKED")
+	assertReply(t, execCmd(server, c, "client", "unblock", id), protocol.MakeIntReply(0))
+
+	// the timed out and unblocked clients are no longer waiting
+	execCmd(server, c, "rpush", "key", "v")
+	assertReply(t, execCmd(server, c, "llen", "key"), protocol.MakeIntReply(1))
+}
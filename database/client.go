@@ -0,0 +1,157 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// execClient dispatches the CLIENT sub commands
+//
+//	CLIENT ID
+//	CLIENT UNBLOCK client-id [TIMEOUT|ERROR]
+//	CLIENT TX-ROLLBACK ON|OFF
+//	CLIENT TRACKING ON|OFF [REDIRECT client-id] [PREFIX prefix ...] [BCAST] [OPTIN] [OPTOUT] [NOLOOP]
+//	CLIENT CACHING YES|NO
+//	CLIENT GETREDIR
+func execClient(server *Server, c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("client")
+	}
+	subCmd := strings.ToUpper(string(args[0]))
+	switch subCmd {
+	case "ID":
+		if len(args) != 1 {
+			return protocol.MakeArgNumErrReply("client|id")
+		}
+		return protocol.MakeIntReply(c.ID())
+	case "UNBLOCK":
+		return execClientUnblock(server, args[1:])
+	case "TX-ROLLBACK":
+		return execClientTxRollback(c, args[1:])
+	case "TRACKING":
+		return execClientTracking(server, c, args[1:])
+	case "CACHING":
+		return execClientCaching(server, c, args[1:])
+	case "GETREDIR":
+		return execClientGetRedir(server, c, args[1:])
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try CLIENT HELP.")
+}
+
+// execClientUnblock unblocks a client blocked by a blocking command.
+// With TIMEOUT (default) the client gets the same reply as timed out, with ERROR it gets an UNBLOCKED error
+func execClientUnblock(server *Server, args [][]byte) redis.Reply {
+	if len(args) != 1 && len(args) != 2 {
+		return protocol.MakeArgNumErrReply("client|unblock")
+	}
+	clientID, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	withError := false
+	if len(args) == 2 {
+		switch strings.ToUpper(string(args[1])) {
+		case "TIMEOUT":
+		case "ERROR":
+			withError = true
+		default:
+			return protocol.MakeErrReply("ERR CLIENT UNBLOCK reason should be TIMEOUT or ERROR")
+		}
+	}
+
+	raw, ok := server.blockedClients.Load(clientID)
+	if !ok {
+		return protocol.MakeIntReply(0)
+	}
+	w := raw.(*waiter)
+	var reply redis.Reply = w.timeoutReply
+	if withError {
+		reply = protocol.MakeErrReply("UNBLOCKED client unblocked via CLIENT UNBLOCK")
+	}
+	if !w.db.unblock(w, reply) {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(1)
+}
+
+// execClientTxRollback turns the rollback mode of transactions on or off for the connection, see execTransaction
+func execClientTxRollback(c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) != 1 {
+		return protocol.MakeArgNumErrReply("client|tx-rollback")
+	}
+	switch strings.ToUpper(string(args[0])) {
+	case "ON":
+		c.SetTxRollback(true)
+	case "OFF":
+		c.SetTxRollback(false)
+	default:
+		return protocol.MakeSyntaxErrReply()
+	}
+	return protocol.MakeOKReply()
+}
+
+// redisVersion is the version of redis whose commands are implemented, reported by HELLO
+const redisVersion = "7.4.0"
+
+// execHello switches the protocol of the connection and returns information of the server,
+// it may authenticate the connection at the same time.
+// Only out-of-band pushes differ in RESP3: invalidations of CLIENT TRACKING, pub/sub messages and confirmations
+// of (un)subscribing. Other replies keep RESP2 types
+//
+//	HELLO [protover [AUTH username password] [SETNAME clientname]]
+func execHello(server *Server, c redis.Connection, args [][]byte) redis.Reply {
+	version := c.GetProtocolVersion()
+	var username, password, name []byte
+	if len(args) > 0 {
+		v, err := strconv.Atoi(string(args[0]))
+		if err != nil {
+			return protocol.MakeErrReply("ERR Protocol version is not an integer or out of range")
+		}
+		version = v
+		for i := 1; i < len(args); i++ {
+			option := strings.ToUpper(string(args[i]))
+			if option == "AUTH" && i+2 < len(args) {
+				username, password = args[i+1], args[i+2]
+				i += 2
+			} else if option == "SETNAME" && i+1 < len(args) {
+				name = args[i+1]
+				i++
+			} else {
+				return protocol.MakeErrReply("ERR Syntax error in HELLO option '" + string(args[i]) + "'")
+			}
+		}
+	}
+	if version < 2 || version > 3 {
+		return protocol.MakeErrReply("NOPROTO unsupported protocol version")
+	}
+	if username != nil {
+		if errReply := server.authenticate(c, string(username), string(password)); errReply != nil {
+			return errReply
+		}
+	} else if server.userOf(c) == nil {
+		return protocol.MakeErrReply("NOAUTH HELLO must be called with the client already authenticated, " +
+			"otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client " +
+			"and select the RESP protocol version at the same time")
+	}
+	if name != nil {
+		c.SetName(string(name))
+	}
+	c.SetProtocolVersion(version)
+
+	pairs := []redis.Reply{
+		protocol.MakeBulkReply([]byte("server")), protocol.MakeBulkReply([]byte("redis")),
+		protocol.MakeBulkReply([]byte("version")), protocol.MakeBulkReply([]byte(redisVersion)),
+		protocol.MakeBulkReply([]byte("proto")), protocol.MakeIntReply(int64(version)),
+		protocol.MakeBulkReply([]byte("id")), protocol.MakeIntReply(c.ID()),
+		protocol.MakeBulkReply([]byte("mode")), protocol.MakeBulkReply([]byte("standalone")),
+		protocol.MakeBulkReply([]byte("role")), protocol.MakeBulkReply([]byte("master")),
+		protocol.MakeBulkReply([]byte("modules")), protocol.MakeEmptyMultiBulkReply(),
+	}
+	if version >= 3 {
+		return protocol.MakeMapReply(pairs)
+	}
+	return protocol.MakeMultiRawReply(pairs)
+}
@@ -0,0 +1,243 @@
+// Package database is the storage engine of redis_go, it holds the keyspace and executes commands
+package database
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+
+	"github.com/tonge3199/redis_go/datastruct/dict"
+	"github.com/tonge3199/redis_go/datastruct/lock"
+	"github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+const (
+	dataDictSize = 1 << 16
+	lockerSize   = 1024
+)
+
+// DB stores data and execute user's commands
+type DB struct {
+	index int
+	// key -> DataEntity
+	data *dict.ConcurrentDict
+
+	// mu is the db level lock: a command with keys holds the read lock of mu and the locks of its keys in locks,
+	// so that a command touching several keys is atomic, while commands on disjoint keys run in parallel.
+	// Commands without keys (KEYS, SCAN, FLUSHDB ...) and serving blocked clients hold the write lock of mu,
+	// which excludes all other commands
+	mu    sync.RWMutex
+	locks *lock.Locks
+
+	// auxMu protects the bookkeeping fields below, which are shared by commands running in parallel.
+	// It is always the last lock acquired
+	auxMu sync.Mutex
+
+	// key -> FIFO queue of clients blocked on it
+	blockingKeys map[string]*list.List
+	// keys which were created while some clients are waiting for them
+	readyKeys   []string
+	readyKeySet map[string]struct{}
+	// client id -> *waiter, shared by all dbs of a server
+	blockedClients *sync.Map
+
+	// keys of hashes which have fields with ttl
+	fieldTTLKeys map[string]struct{}
+
+	// key -> version, only keys watched by some clients have versions, see watch.go
+	versions map[string]uint32
+	// key -> number of clients watching it
+	watchers map[string]int
+	// keys modified by running commands and not signaled yet, see markModified
+	modified map[string]struct{}
+	// side effects deferred by transactions in rollback mode, by the keys they write. See txEffects
+	deferred map[string]*txEffects
+	// side effects deferred by a transaction in rollback mode which locks the whole db
+	deferredAll *txEffects
+
+	// publishes keyspace events, nil if they are disabled. See notify.go
+	notifier *keyspaceNotifier
+
+	// client side caching shared by all dbs of the server, nil for a db without server. See tracking.go
+	tracking *trackingTable
+
+	// the server holding the db, nil for a db without server.
+	// Commands queued by transactions run within the db, the ones executed by the server reach it here
+	server *Server
+}
+
+// ExecFunc is interface for command executor
+// args don't include cmd line
+type ExecFunc func(db *DB, args [][]byte) redis.Reply
+
+// CmdLine is alias for [][]byte, represents a command line
+type CmdLine = [][]byte
+
+// makeDB create DB instance
+func makeDB() *DB {
+	db := &DB{
+		data:           dict.MakeConcurrent(dataDictSize),
+		locks:          lock.Make(lockerSize),
+		blockingKeys:   make(map[string]*list.List),
+		readyKeySet:    make(map[string]struct{}),
+		blockedClients: &sync.Map{},
+		fieldTTLKeys:   make(map[string]struct{}),
+		versions:       make(map[string]uint32),
+		watchers:       make(map[string]int),
+		modified:       make(map[string]struct{}),
+		deferred:       make(map[string]*txEffects),
+	}
+	return db
+}
+
+// Exec executes command within one database
+func (db *DB) Exec(c redis.Connection, cmdLine [][]byte) redis.Reply {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		return protocol.MakeErrReply("ERR unknown command '" + cmdName + "'")
+	}
+	if !validateArity(cmd.arity, cmdLine) {
+		return protocol.MakeArgNumErrReply(cmdName)
+	}
+	result := db.execLocked(c, cmd, cmdLine)
+	db.serveReadyKeys()
+	return result
+}
+
+// execLocked executes the command with its keys locked.
+// Keys are unlocked by defer, so a panic of the executor doesn't leave them locked
+func (db *DB) execLocked(c redis.Connection, cmd *command, cmdLine [][]byte) redis.Reply {
+	writeKeys, readKeys := cmd.keysOf(cmdLine)
+	unlock := db.lockKeys(writeKeys, readKeys)
+	defer unlock()
+	var result redis.Reply
+	if cmd.flags&flagReadOnly > 0 {
+		result = cmd.executor(db, cmdLine[1:])
+		db.notifyKeyMiss(cmd, readKeys)
+		db.tracking.rememberKeys(c, readKeys)
+	} else {
+		result = db.execWrite(c, cmd, cmdLine, writeKeys)
+	}
+	// the waiter is enqueued before keys are unlocked, so it won't miss any push.
+	// A readonly command like XREAD may block too, the read locks of its keys exclude pushes as well
+	if w, ok := result.(*waiter); ok {
+		db.block(c, w)
+	}
+	return result
+}
+
+// lockKeys locks keys of a command and returns the function to unlock them.
+// A command without any key locks the whole db since it may touch any key
+func (db *DB) lockKeys(writeKeys []string, readKeys []string) (unlock func()) {
+	if len(writeKeys) == 0 && len(readKeys) == 0 {
+		db.mu.Lock()
+		return db.mu.Unlock
+	}
+	db.mu.RLock()
+	db.locks.RWLocks(writeKeys, readKeys)
+	return func() {
+		db.locks.RWUnLocks(writeKeys, readKeys)
+		db.mu.RUnlock()
+	}
+}
+
+// execWrite executes a write command of c with its keys locked, then signals written keys it modified.
+// handleReadyKeys signals if the command blocks
+func (db *DB) execWrite(c redis.Connection, cmd *command, cmdLine [][]byte, writeKeys []string) redis.Reply {
+	result := cmd.executor(db, cmdLine[1:])
+	if w, blocking := result.(*waiter); blocking {
+		w.writeKeys = writeKeys
+	} else {
+		db.signalWrittenKeys(c.ID(), writeKeys...)
+	}
+	return result
+}
+
+/* ---- Data Access ----- */
+
+// GetEntity returns DataEntity bind to given key
+func (db *DB) GetEntity(key string) (*database.DataEntity, bool) {
+	raw, ok := db.data.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entity, _ := raw.(*database.DataEntity)
+	return entity, true
+}
+
+// PutEntity a DataEntity into DB
+func (db *DB) PutEntity(key string, entity *database.DataEntity) int {
+	result := db.data.Put(key, entity)
+	if result > 0 {
+		db.signalKeyAsReady(key)
+		db.notifyKeyspaceEvent(notifyNew, "new", key)
+	}
+	return result
+}
+
+// PutIfExists edit an existing DataEntity
+func (db *DB) PutIfExists(key string, entity *database.DataEntity) int {
+	return db.data.PutIfExists(key, entity)
+}
+
+// PutIfAbsent insert an DataEntity only if the key not exists
+func (db *DB) PutIfAbsent(key string, entity *database.DataEntity) int {
+	result := db.data.PutIfAbsent(key, entity)
+	if result > 0 {
+		db.signalKeyAsReady(key)
+		db.notifyKeyspaceEvent(notifyNew, "new", key)
+	}
+	return result
+}
+
+// Remove the given key from db
+func (db *DB) Remove(key string) {
+	db.data.Remove(key)
+	db.untrackFieldTTL(key)
+}
+
+// Removes the given keys from db, returns the number of deleted keys
+func (db *DB) Removes(keys ...string) (deleted int) {
+	deleted = 0
+	for _, key := range keys {
+		_, exists := db.data.Get(key)
+		if exists {
+			db.Remove(key)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// deleteKey removes key like DEL, it notifies "del" if key exists
+func (db *DB) deleteKey(key string) bool {
+	if _, exists := db.GetEntity(key); !exists {
+		return false
+	}
+	db.Remove(key)
+	db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	return true
+}
+
+// Flush clean database
+func (db *DB) Flush() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.flush()
+}
+
+// flush removes all keys, must be called with the write lock of db.mu held
+func (db *DB) flush() {
+	db.auxMu.Lock()
+	for key := range db.watchers {
+		if _, exists := db.GetEntity(key); exists {
+			db.versions[key]++
+		}
+	}
+	db.fieldTTLKeys = make(map[string]struct{})
+	db.auxMu.Unlock()
+	db.data.Clear()
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// execWithin fails the test if the command doesn't return within a second, like waiting for a lock never released
+func execWithin(t *testing.T, server *Server, c redis.Connection, args ...string) redis.Reply {
+	t.Helper()
+	done := make(chan redis.Reply, 1)
+	go func() {
+		done <- execCmd(server, c, args...)
+	}()
+	select {
+	case reply := <-done:
+		return reply
+	case <-time.After(time.Second):
+		t.Fatalf("%v hangs", args)
+		return nil
+	}
+}
+
+// registerTestCommand registers a command for the test only. registerCommand adds it to acl as well,
+// so it's removed from both after the test, otherwise later tests see it in ACL CAT and COMMAND
+func registerTestCommand(t *testing.T, name string, executor ExecFunc, arity int, flags int, categories acl.Category) {
+	registerCommand(name, executor, arity, flags, categories)
+	t.Cleanup(func() {
+		delete(cmdTable, name)
+		acl.UnregisterCommand(name)
+	})
+}
+
+func TestRegisterTestCommand(t *testing.T) {
+	t.Run("registered", func(t *testing.T) {
+		registerTestCommand(t, "testcmd", execGet, 2, flagReadOnly, acl.CategoryRead)
+		server := makeTestServer(t)
+		assertReply(t, execCmd(server, connect(server), "testcmd", "key"), protocol.MakeNullBulkReply())
+	})
+	if _, ok := cmdTable["testcmd"]; ok || commandExists("testcmd") {
+		t.Fatal("the test command is still registered after the test")
+	}
+	server := makeTestServer(t)
+	for _, name := range execCmd(server, connect(server), "acl", "cat", "read").(*protocol.MultiBulkReply).Args {
+		if string(name) == "testcmd" {
+			t.Fatal("ACL CAT lists the test command after the test")
+		}
+	}
+}
+
+func TestExecPanicUnlocksKeys(t *testing.T) {
+	registerTestCommand(t, "testpanic", func(db *DB, args [][]byte) redis.Reply {
+		panic("testpanic")
+	}, 2, flagWrite, acl.CategoryWrite)
+	server := makeTestServer(t)
+	c := connect(server)
+
+	assertReply(t, execCmd(server, c, "testpanic", "key"), &protocol.UnknownErrReply{})
+	assertReply(t, execWithin(t, server, c, "set", "key", "v"), protocol.MakeOKReply())
+	assertReply(t, execWithin(t, server, c, "keys", "*"), bulks("key"))
+
+	execCmd(server, c, "multi")
+	execCmd(server, c, "set", "key", "v2")
+	execCmd(server, c, "testpanic", "key")
+	assertReply(t, execCmd(server, c, "exec"), &protocol.UnknownErrReply{})
+	assertReply(t, execWithin(t, server, c, "get", "key"), protocol.MakeBulkReply([]byte("v2")))
+	assertReply(t, execWithin(t, server, c, "flushdb"), protocol.MakeOKReply())
+}
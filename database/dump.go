@@ -0,0 +1,175 @@
+package database
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	Hash "github.com/tonge3199/redis_go/datastruct/hash"
+	List "github.com/tonge3199/redis_go/datastruct/list"
+	Set "github.com/tonge3199/redis_go/datastruct/set"
+	SortedSet "github.com/tonge3199/redis_go/datastruct/sortedset"
+	"github.com/tonge3199/redis_go/datastruct/stream"
+	"github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/lib/utils"
+)
+
+// A key is dumped as the command lines which rebuild it on an empty db, the same as the AOF rewrite of redis.
+// This is the hook for an AOF rewriter or the full sync of a replica, neither exists yet.
+//
+// Everything that is not visible from the data itself is dumped too: field ttls are dumped as HPEXPIREAT with
+// their absolute unix milliseconds, so they expire at the same moment after the key is loaded, and the
+// ids, consumer groups and pending entries of a stream are dumped with XSETID, XGROUP and XCLAIM.
+
+// dumpItemsPerCmd limits the number of items in one dumped command, so loading a big key doesn't need a huge buffer
+const dumpItemsPerCmd = 64
+
+// DumpKey returns the command lines to rebuild key, nil if the key doesn't exist
+func (db *DB) DumpKey(key string) []CmdLine {
+	unlock := db.lockKeys(nil, []string{key})
+	defer unlock()
+	entity, exists := db.lookupEntity(key)
+	if !exists {
+		return nil
+	}
+	return entityToCmdLines(key, entity, time.Now().UnixMilli())
+}
+
+// entityToCmdLines dumps entity of key, hash fields expired before now are skipped
+func entityToCmdLines(key string, entity *database.DataEntity, now int64) []CmdLine {
+	switch value := entity.Data.(type) {
+	case []byte:
+		return []CmdLine{utils.ToCmdLine3("SET", []byte(key), value)}
+	case *List.QuickList:
+		return listToCmdLines(key, value)
+	case *Hash.Hash:
+		return hashToCmdLines(key, value, now)
+	case *Set.Set:
+		return setToCmdLines(key, value)
+	case *SortedSet.SortedSet:
+		return zsetToCmdLines(key, value)
+	case *stream.Stream:
+		return streamToCmdLines(key, value)
+	}
+	return nil
+}
+
+// batchCmdLines appends items to commands made of prefix, at most dumpItemsPerCmd items in one command
+type batchCmdLines struct {
+	prefix   CmdLine
+	cmdLines []CmdLine
+	items    int
+}
+
+func (b *batchCmdLines) add(args ...[]byte) {
+	if b.items%dumpItemsPerCmd == 0 {
+		b.cmdLines = append(b.cmdLines, slices.Clone(b.prefix))
+	}
+	last := len(b.cmdLines) - 1
+	b.cmdLines[last] = append(b.cmdLines[last], args...)
+	b.items++
+}
+
+func listToCmdLines(key string, list *List.QuickList) []CmdLine {
+	batch := &batchCmdLines{prefix: utils.ToCmdLine("RPUSH", key)}
+	list.ForEach(func(i int, v []byte) bool {
+		batch.add(slices.Clone(v))
+		return true
+	})
+	return batch.cmdLines
+}
+
+func hashToCmdLines(key string, hash *Hash.Hash, now int64) []CmdLine {
+	batch := &batchCmdLines{prefix: utils.ToCmdLine("HSET", key)}
+	hash.ForEach(func(field string, val []byte) bool {
+		batch.add([]byte(field), slices.Clone(val))
+		return true
+	})
+	cmdLines := batch.cmdLines
+
+	// fields sharing the same expire time are expired by one command, which is the usual case after HEXPIRE
+	fieldsByTime := make(map[int64][]string)
+	hash.ForEachExpire(func(field string, when int64) bool {
+		if when > now {
+			fieldsByTime[when] = append(fieldsByTime[when], field)
+		}
+		return true
+	})
+	times := make([]int64, 0, len(fieldsByTime))
+	for when := range fieldsByTime {
+		times = append(times, when)
+	}
+	slices.Sort(times)
+	for _, when := range times {
+		fields := fieldsByTime[when]
+		slices.Sort(fields)
+		for len(fields) > 0 {
+			n := min(len(fields), dumpItemsPerCmd)
+			cmdLine := utils.ToCmdLine("HPEXPIREAT", key, strconv.FormatInt(when, 10), "FIELDS", strconv.Itoa(n))
+			for _, field := range fields[:n] {
+				cmdLine = append(cmdLine, []byte(field))
+			}
+			cmdLines = append(cmdLines, cmdLine)
+			fields = fields[n:]
+		}
+	}
+	return cmdLines
+}
+
+func setToCmdLines(key string, set *Set.Set) []CmdLine {
+	batch := &batchCmdLines{prefix: utils.ToCmdLine("SADD", key)}
+	set.ForEach(func(member string) bool {
+		batch.add([]byte(member))
+		return true
+	})
+	return batch.cmdLines
+}
+
+func zsetToCmdLines(key string, zset *SortedSet.SortedSet) []CmdLine {
+	batch := &batchCmdLines{prefix: utils.ToCmdLine("ZADD", key)}
+	zset.ForEachByRank(0, zset.Len(), false, func(element *SortedSet.Element) bool {
+		batch.add([]byte(utils.FormatDouble(element.Score)), []byte(element.Member))
+		return true
+	})
+	return batch.cmdLines
+}
+
+// streamToCmdLines dumps a stream the same as redis:
+// an empty stream is created by adding an entry and trimming it at once,
+// then XSETID restores ids the entries can't tell, such as the id of the last deleted entry.
+// Pending entries are claimed with FORCE, which creates them in the PEL with their delivery time and count
+func streamToCmdLines(key string, s *stream.Stream) []CmdLine {
+	var cmdLines []CmdLine
+	s.Range(stream.ID{}, stream.MaxID, false, func(entry *stream.Entry) bool {
+		cmdLine := utils.ToCmdLine("XADD", key, entry.ID.String())
+		for _, field := range entry.Fields {
+			cmdLine = append(cmdLine, slices.Clone(field))
+		}
+		cmdLines = append(cmdLines, cmdLine)
+		return true
+	})
+	if len(cmdLines) == 0 {
+		cmdLines = append(cmdLines, utils.ToCmdLine("XADD", key, "MAXLEN", "0", "0-1", "x", "y"))
+	}
+	cmdLines = append(cmdLines, utils.ToCmdLine("XSETID", key, s.LastID().String(),
+		"ENTRIESADDED", strconv.FormatInt(s.EntriesAdded(), 10),
+		"MAXDELETEDID", s.MaxDeletedID().String()))
+
+	s.ForEachGroup(func(group *stream.Group) bool {
+		cmdLines = append(cmdLines, utils.ToCmdLine("XGROUP", "CREATE", key, group.Name, group.LastID.String(),
+			"ENTRIESREAD", strconv.FormatInt(group.EntriesRead, 10)))
+		group.ForEachConsumer(func(consumer *stream.Consumer) bool {
+			cmdLines = append(cmdLines, utils.ToCmdLine("XGROUP", "CREATECONSUMER", key, group.Name, consumer.Name))
+			return true
+		})
+		group.ForEachPending(stream.ID{}, func(id stream.ID, nack *stream.NACK) bool {
+			cmdLines = append(cmdLines, utils.ToCmdLine("XCLAIM", key, group.Name, nack.Consumer.Name, "0", id.String(),
+				"TIME", strconv.FormatInt(nack.DeliveryTime, 10),
+				"RETRYCOUNT", strconv.FormatInt(nack.DeliveryCount, 10),
+				"JUSTID", "FORCE"))
+			return true
+		})
+		return true
+	})
+	return cmdLines
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/tonge3199/redis_go/datastruct/stream"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// loadDump replays the dump of keys in src on dst
+func loadDump(t *testing.T, src *Server, dst *Server, keys ...string) {
+	t.Helper()
+	c := connect(dst)
+	for _, key := range keys {
+		cmdLines := src.dbSet[0].DumpKey(key)
+		if len(cmdLines) == 0 {
+			t.Fatalf("key %s is not dumped", key)
+		}
+		for _, cmdLine := range cmdLines {
+			if reply, isErr := dst.Exec(c, cmdLine).(protocol.ErrorReply); isErr {
+				t.Fatalf("loading %q: %q", cmdLine, reply.ToBytes())
+			}
+		}
+	}
+}
+
+func TestDumpKey(t *testing.T) {
+	src, dst := makeTestServer(t), makeTestServer(t)
+	c := connect(src)
+	execCmd(src, c, "set", "str", "hello")
+	// more items than dumpItemsPerCmd, so they are split into several commands
+	for i := 0; i < 2*dumpItemsPerCmd+1; i++ {
+		n := strconv.Itoa(i)
+		execCmd(src, c, "rpush", "list", n)
+		execCmd(src, c, "sadd", "set", "m"+n)
+		execCmd(src, c, "zadd", "zset", strconv.Itoa(i%7)+".5", "m"+n)
+		execCmd(src, c, "hset", "hash", "f"+n, n)
+	}
+	execCmd(src, c, "zadd", "zset", "-inf", "min", "1e+300", "max")
+	execCmd(src, c, "hpexpireat", "hash", "9999999999000", "fields", "2", "f1", "f2")
+	execCmd(src, c, "hpexpireat", "hash", "9999999999999", "fields", "1", "f3")
+	loadDump(t, src, dst, "str", "list", "set", "zset", "hash")
+
+	d := connect(dst)
+	if missing := src.dbSet[0].DumpKey("missing"); missing != nil {
+		t.Fatalf("dump of a missing key is %q", missing)
+	}
+	for _, cmd := range [][]string{
+		{"get", "str"},
+		{"lrange", "list", "0", "-1"},
+		{"scard", "set"},
+		{"sismember", "set", "m128"},
+		{"zrange", "zset", "0", "-1", "withscores"},
+		{"hlen", "hash"},
+		{"hmget", "hash", "f0", "f3", "f128"},
+		{"hpexpiretime", "hash", "fields", "4", "f0", "f1", "f2", "f3"},
+	} {
+		assertReply(t, execCmd(dst, d, cmd...), execCmd(src, c, cmd...))
+	}
+	assertReply(t, execCmd(dst, d, "hpexpiretime", "hash", "fields", "3", "f1", "f2", "f3"), ints(9999999999000, 9999999999000, 9999999999999))
+}
+
+func TestDumpStream(t *testing.T) {
+	src, dst := makeTestServer(t), makeTestServer(t)
+	c := connect(src)
+	execCmd(src, c, "xadd", "s", "1-1", "f", "v1")
+	execCmd(src, c, "xadd", "s", "2-1", "f", "v2", "g", "w")
+	execCmd(src, c, "xadd", "s", "3-1", "f", "v3")
+	execCmd(src, c, "xgroup", "create", "s", "g1", "0")
+	execCmd(src, c, "xgroup", "create", "s", "g2", "$")
+	execCmd(src, c, "xgroup", "createconsumer", "s", "g1", "idle")
+	execCmd(src, c, "xreadgroup", "group", "g1", "alice", "count", "2", "streams", "s", ">")
+	execCmd(src, c, "xclaim", "s", "g1", "bob", "0", "2-1", "retrycount", "5")
+	execCmd(src, c, "xdel", "s", "3-1")
+	// an empty stream keeps its ids and groups
+	execCmd(src, c, "xgroup", "create", "empty", "g", "$", "mkstream")
+	execCmd(src, c, "xadd", "empty", "7-7", "f", "v")
+	execCmd(src, c, "xdel", "empty", "7-7")
+	loadDump(t, src, dst, "s", "empty")
+
+	d := connect(dst)
+	for _, cmd := range [][]string{
+		{"xrange", "s", "-", "+"},
+		{"xinfo", "stream", "s"},
+		{"xinfo", "groups", "s"},
+		{"xpending", "s", "g1"},
+		{"xinfo", "stream", "empty"},
+		{"xinfo", "groups", "empty"},
+	} {
+		assertReply(t, execCmd(dst, d, cmd...), execCmd(src, c, cmd...))
+	}
+
+	// delivery times and counts are carried over exactly, XPENDING would show them as idle times
+	srcGroup, _ := mustGetStream(t, src, "s").GetGroup("g1")
+	dstGroup, _ := mustGetStream(t, dst, "s").GetGroup("g1")
+	srcGroup.ForEachPending(stream.ID{}, func(id stream.ID, expected *stream.NACK) bool {
+		got, ok := dstGroup.GetPending(id)
+		if !ok || got.DeliveryTime != expected.DeliveryTime || got.DeliveryCount != expected.DeliveryCount ||
+			got.Consumer.Name != expected.Consumer.Name {
+			t.Fatalf("pending entry %s is %+v, expected %+v", id, got, expected)
+		}
+		return true
+	})
+	if _, ok := dstGroup.GetConsumer("idle"); !ok {
+		t.Fatal("consumer without pending entries is not dumped")
+	}
+}
+
+func mustGetStream(t *testing.T, server *Server, key string) *stream.Stream {
+	t.Helper()
+	s, errReply := server.dbSet[0].getAsStream(key)
+	if errReply != nil || s == nil {
+		t.Fatalf("%s is not a stream", key)
+	}
+	return s
+}
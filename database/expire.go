@@ -0,0 +1,107 @@
+package database
+
+import (
+	"time"
+
+	Hash "github.com/tonge3199/redis_go/datastruct/hash"
+)
+
+// Expired hash fields are removed in two ways, the same as redis:
+//
+//   - lazily: write commands call expireHashFields before touching a hash,
+//     readonly commands just skip expired fields since they can't modify data under read lock
+//   - actively: activeExpireCycle runs every activeExpireInterval and samples hashes which have
+//     fields with ttl, it keeps sampling while more than 1/4 of the sampled hashes had expired fields
+//
+// Field ttls are stored as absolute unix milliseconds in the hash value, DumpKey dumps them as HPEXPIREAT,
+// so a hash loaded from its dump expires its fields at the same moment (see dump.go).
+
+const (
+	activeExpireInterval     = 100 * time.Millisecond
+	activeExpireKeysPerLoop  = 20
+	activeExpireCycleTimeout = 25 * time.Millisecond
+)
+
+// trackFieldTTL records that the hash of key has fields with ttl
+func (db *DB) trackFieldTTL(key string) {
+	db.auxMu.Lock()
+	defer db.auxMu.Unlock()
+	db.fieldTTLKeys[key] = struct{}{}
+}
+
+// untrackFieldTTL forgets key after its hash has no field with ttl
+func (db *DB) untrackFieldTTL(key string) {
+	db.auxMu.Lock()
+	defer db.auxMu.Unlock()
+	delete(db.fieldTTLKeys, key)
+}
+
+// hasFieldTTL returns whether the hash of key may have fields with ttl
+func (db *DB) hasFieldTTL(key string) bool {
+	db.auxMu.Lock()
+	defer db.auxMu.Unlock()
+	_, ok := db.fieldTTLKeys[key]
+	return ok
+}
+
+// expireHashFields deletes expired fields of the hash of key, and deletes the key if the hash becomes empty.
+// It returns the number of deleted fields, must be called with the write lock of key held
+func (db *DB) expireHashFields(key string) int {
+	if !db.hasFieldTTL(key) {
+		return 0
+	}
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		db.untrackFieldTTL(key)
+		return 0
+	}
+	hash, ok := entity.Data.(*Hash.Hash)
+	if !ok {
+		db.untrackFieldTTL(key)
+		return 0
+	}
+	removed := hash.RemoveExpired(time.Now().UnixMilli())
+	if len(removed) > 0 {
+		db.notifyKeyspaceEvent(notifyHash, "hexpired", key)
+	}
+	if hash.Len() == 0 {
+		db.deleteKey(key)
+	} else if !hash.HasExpires() {
+		db.untrackFieldTTL(key)
+	}
+	db.signalWrittenKeys(0, key)
+	return len(removed)
+}
+
+// activeExpireCycle removes expired fields of sampled hashes until expired ones become rare
+func (db *DB) activeExpireCycle() {
+	start := time.Now()
+	for time.Since(start) < activeExpireCycleTimeout {
+		sampled, expired := db.activeExpireLoop()
+		if sampled == 0 || expired*4 <= sampled {
+			return
+		}
+	}
+}
+
+func (db *DB) activeExpireLoop() (sampled int, expired int) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	keys := make([]string, 0, activeExpireKeysPerLoop)
+	db.auxMu.Lock()
+	for key := range db.fieldTTLKeys { // map iteration order is random
+		keys = append(keys, key)
+		if len(keys) == activeExpireKeysPerLoop {
+			break
+		}
+	}
+	db.auxMu.Unlock()
+	for _, key := range keys {
+		db.locks.Lock(key)
+		if db.expireHashFields(key) > 0 {
+			expired++
+		}
+		db.locks.Unlock(key)
+	}
+	return len(keys), expired
+}
@@ -0,0 +1,465 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/acl"
+	SortedSet "github.com/tonge3199/redis_go/datastruct/sortedset"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/geohash"
+	"github.com/tonge3199/redis_go/lib/utils"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// geo commands are built on sorted sets, the score of a member is the 52 bits geohash of its position
+
+var (
+	errGeoAddSyntax     = protocol.MakeErrReply("ERR syntax error. Try GEOADD key [x1] [y1] [name1] [x2] [y2] [name2] ... ")
+	errUnsupportedUnit  = protocol.MakeErrReply("ERR unsupported unit provided. please use M, KM, FT, MI")
+	errDecodeGeoMember  = protocol.MakeErrReply("ERR could not decode requested zset member")
+	errNegativeRadius   = protocol.MakeErrReply("ERR radius cannot be negative")
+	errNegativeBoxSize  = protocol.MakeErrReply("ERR height or width cannot be negative")
+	errGeoCountPositive = protocol.MakeErrReply("ERR COUNT must be > 0")
+	errGeoAnyNeedsCount = protocol.MakeErrReply("ERR the ANY argument requires COUNT argument")
+)
+
+// parseLongLat parses a position, which must be in the range that can be indexed
+func parseLongLat(longArg []byte, latArg []byte) (float64, float64, protocol.ErrorReply) {
+	longitude, err := strconv.ParseFloat(string(longArg), 64)
+	if err != nil || math.IsNaN(longitude) {
+		return 0, 0, errNotFloat
+	}
+	latitude, err := strconv.ParseFloat(string(latArg), 64)
+	if err != nil || math.IsNaN(latitude) {
+		return 0, 0, errNotFloat
+	}
+	if longitude < geohash.LongMin || longitude > geohash.LongMax ||
+		latitude < geohash.LatMin || latitude > geohash.LatMax {
+		return 0, 0, protocol.MakeErrReply(fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", longitude, latitude))
+	}
+	return longitude, latitude, nil
+}
+
+// parseUnit returns how many meters a unit is
+func parseUnit(arg []byte) (float64, bool) {
+	switch strings.ToLower(string(arg)) {
+	case "m":
+		return 1, true
+	case "km":
+		return 1000, true
+	case "ft":
+		return 0.3048, true
+	case "mi":
+		return 1609.34, true
+	}
+	return 0, false
+}
+
+// makeDistanceReply formats a distance with 4 decimal places like redis
+func makeDistanceReply(distance float64) redis.Reply {
+	return protocol.MakeBulkReply([]byte(strconv.FormatFloat(distance, 'f', 4, 64)))
+}
+
+func makeCoordReply(longitude float64, latitude float64) redis.Reply {
+	return protocol.MakeMultiBulkReply([][]byte{
+		[]byte(utils.FormatHumanFloat(longitude)),
+		[]byte(utils.FormatHumanFloat(latitude)),
+	})
+}
+
+// getGeoPosition returns the position of member, ok is false if the key or member doesn't exist
+func getGeoPosition(sortedSet *SortedSet.SortedSet, member string) (longitude float64, latitude float64, ok bool) {
+	if sortedSet == nil {
+		return 0, 0, false
+	}
+	element, exists := sortedSet.Get(member)
+	if !exists {
+		return 0, 0, false
+	}
+	longitude, latitude = geohash.Decode(uint64(element.Score))
+	return longitude, latitude, true
+}
+
+// execGeoAdd adds members with positions, it's a ZADD with geohash as score
+//
+//	GEOADD key [NX | XX] [CH] longitude latitude member [longitude latitude member ...]
+func execGeoAdd(db *DB, args [][]byte) redis.Reply {
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX", "XX", "CH":
+		default:
+			break options
+		}
+	}
+	triples := args[i:]
+	if len(triples) == 0 || len(triples)%3 != 0 {
+		return errGeoAddSyntax
+	}
+
+	zaddArgs := make([][]byte, 0, i+len(triples)/3*2)
+	zaddArgs = append(zaddArgs, args[:i]...)
+	for j := 0; j < len(triples); j += 3 {
+		longitude, latitude, errReply := parseLongLat(triples[j], triples[j+1])
+		if errReply != nil {
+			return errReply
+		}
+		hash, _ := geohash.Encode(longitude, latitude)
+		zaddArgs = append(zaddArgs, []byte(strconv.FormatUint(hash, 10)), triples[j+2])
+	}
+	return execZAdd(db, zaddArgs)
+}
+
+// execGeoPos returns the positions of members, a missing member gets a nil array
+//
+//	GEOPOS key [member [member ...]]
+func execGeoPos(db *DB, args [][]byte) redis.Reply {
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]redis.Reply, len(args)-1)
+	for i, member := range args[1:] {
+		longitude, latitude, ok := getGeoPosition(sortedSet, string(member))
+		if !ok {
+			result[i] = protocol.MakeNullMultiBulkReply()
+			continue
+		}
+		result[i] = makeCoordReply(longitude, latitude)
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// execGeoDist returns the distance between two members, or nil if any of them doesn't exist
+//
+//	GEODIST key member1 member2 [M | KM | FT | MI]
+func execGeoDist(db *DB, args [][]byte) redis.Reply {
+	if len(args) > 4 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	toMeters := 1.0
+	if len(args) == 4 {
+		var ok bool
+		toMeters, ok = parseUnit(args[3])
+		if !ok {
+			return errUnsupportedUnit
+		}
+	}
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	long1, lat1, ok1 := getGeoPosition(sortedSet, string(args[1]))
+	long2, lat2, ok2 := getGeoPosition(sortedSet, string(args[2]))
+	if !ok1 || !ok2 {
+		return protocol.MakeNullBulkReply()
+	}
+	return makeDistanceReply(geohash.Distance(long1, lat1, long2, lat2) / toMeters)
+}
+
+// execGeoHash returns the standard geohash strings of members, a missing member gets nil
+//
+//	GEOHASH key [member [member ...]]
+func execGeoHash(db *DB, args [][]byte) redis.Reply {
+	sortedSet, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, len(args)-1)
+	for i, member := range args[1:] {
+		longitude, latitude, ok := getGeoPosition(sortedSet, string(member))
+		if ok {
+			result[i] = []byte(geohash.ToString(longitude, latitude))
+		}
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+const (
+	geoSortNone = iota
+	geoSortAsc
+	geoSortDesc
+)
+
+// geoSearchSpec is the parsed arguments of GEOSEARCH and GEOSEARCHSTORE
+type geoSearchSpec struct {
+	fromMember []byte // nil if FROMLONLAT is used
+	shape      geohash.Shape
+	toMeters   float64
+	sort       int
+	count      int64 // 0 means unlimited
+	any        bool
+	withCoord  bool
+	withDist   bool
+	withHash   bool
+	storeDist  bool
+}
+
+func parseGeoSearchSpec(cmd string, args [][]byte, store bool) (*geoSearchSpec, protocol.ErrorReply) {
+	spec := &geoSearchSpec{}
+	var fromLonLat, byRadius, byBox bool
+	for i := 0; i < len(args); i++ {
+		remaining := len(args) - i - 1
+		switch strings.ToUpper(string(args[i])) {
+		case "FROMMEMBER":
+			if remaining < 1 {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			spec.fromMember = args[i+1]
+			i++
+		case "FROMLONLAT":
+			if remaining < 2 {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			longitude, latitude, errReply := parseLongLat(args[i+1], args[i+2])
+			if errReply != nil {
+				return nil, errReply
+			}
+			spec.shape.Longitude, spec.shape.Latitude = longitude, latitude
+			fromLonLat = true
+			i += 2
+		case "BYRADIUS":
+			if remaining < 2 {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			radius, err := strconv.ParseFloat(string(args[i+1]), 64)
+			if err != nil || math.IsNaN(radius) {
+				return nil, protocol.MakeErrReply("ERR need numeric radius")
+			}
+			if radius < 0 {
+				return nil, errNegativeRadius
+			}
+			toMeters, ok := parseUnit(args[i+2])
+			if !ok {
+				return nil, errUnsupportedUnit
+			}
+			spec.shape.Radius = radius * toMeters
+			spec.toMeters = toMeters
+			byRadius = true
+			i += 2
+		case "BYBOX":
+			if remaining < 3 {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			width, err := strconv.ParseFloat(string(args[i+1]), 64)
+			if err != nil || math.IsNaN(width) {
+				return nil, protocol.MakeErrReply("ERR need numeric width")
+			}
+			height, err := strconv.ParseFloat(string(args[i+2]), 64)
+			if err != nil || math.IsNaN(height) {
+				return nil, protocol.MakeErrReply("ERR need numeric height")
+			}
+			if width < 0 || height < 0 {
+				return nil, errNegativeBoxSize
+			}
+			toMeters, ok := parseUnit(args[i+3])
+			if !ok {
+				return nil, errUnsupportedUnit
+			}
+			spec.shape.Width = width * toMeters
+			spec.shape.Height = height * toMeters
+			spec.shape.IsBox = true
+			spec.toMeters = toMeters
+			byBox = true
+			i += 3
+		case "ASC":
+			spec.sort = geoSortAsc
+		case "DESC":
+			spec.sort = geoSortDesc
+		case "COUNT":
+			if remaining < 1 {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return nil, errNotInteger
+			}
+			if count <= 0 {
+				return nil, errGeoCountPositive
+			}
+			spec.count = count
+			i++
+		case "ANY":
+			spec.any = true
+		case "WITHCOORD":
+			spec.withCoord = true
+		case "WITHDIST":
+			spec.withDist = true
+		case "WITHHASH":
+			spec.withHash = true
+		case "STOREDIST":
+			if !store {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			spec.storeDist = true
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+
+	if store && (spec.withCoord || spec.withDist || spec.withHash) {
+		return nil, protocol.MakeErrReply("ERR " + cmd + " is not compatible with WITHDIST, WITHHASH and WITHCOORD options")
+	}
+	if (spec.fromMember != nil) == fromLonLat {
+		return nil, protocol.MakeErrReply("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for " + cmd)
+	}
+	if byRadius == byBox {
+		return nil, protocol.MakeErrReply("ERR exactly one of BYRADIUS and BYBOX can be specified for " + cmd)
+	}
+	if spec.any && spec.count == 0 {
+		return nil, errGeoAnyNeedsCount
+	}
+	// COUNT without ordering does not make much sense, since the nearest N members are wanted.
+	// Not needed for ANY, which returns as soon as enough members are found
+	if spec.count > 0 && spec.sort == geoSortNone && !spec.any {
+		spec.sort = geoSortAsc
+	}
+	return spec, nil
+}
+
+// geoPoint is a member found by GEOSEARCH
+type geoPoint struct {
+	member    string
+	hash      uint64
+	distance  float64 // in meters
+	longitude float64
+	latitude  float64
+}
+
+// geoSearch returns the members within the shape, scanning the geohash boxes covering the shape
+func geoSearch(sortedSet *SortedSet.SortedSet, spec *geoSearchSpec) []*geoPoint {
+	var points []*geoPoint
+	limited := spec.any && spec.count > 0
+	for _, scoreRange := range geohash.SearchRanges(&spec.shape) {
+		min := &SortedSet.ScoreBorder{Value: float64(scoreRange.Min)}
+		max := &SortedSet.ScoreBorder{Value: float64(scoreRange.Max), Exclude: true}
+		sortedSet.ForEach(min, max, 0, -1, false, func(element *SortedSet.Element) bool {
+			hash := uint64(element.Score)
+			longitude, latitude := geohash.Decode(hash)
+			distance, ok := spec.shape.Contains(longitude, latitude)
+			if !ok {
+				return true
+			}
+			points = append(points, &geoPoint{
+				member:    element.Member,
+				hash:      hash,
+				distance:  distance,
+				longitude: longitude,
+				latitude:  latitude,
+			})
+			return !limited || int64(len(points)) < spec.count
+		})
+		if limited && int64(len(points)) >= spec.count {
+			break
+		}
+	}
+
+	switch spec.sort {
+	case geoSortAsc:
+		sort.SliceStable(points, func(i, j int) bool {
+			return points[i].distance < points[j].distance
+		})
+	case geoSortDesc:
+		sort.SliceStable(points, func(i, j int) bool {
+			return points[i].distance > points[j].distance
+		})
+	}
+	if spec.count > 0 && int64(len(points)) > spec.count {
+		points = points[:spec.count]
+	}
+	return points
+}
+
+// searchGeo parses the arguments and searches the source key, a nil result means the key doesn't exist
+func (db *DB) searchGeo(cmd string, src string, args [][]byte, store bool) ([]*geoPoint, *geoSearchSpec, protocol.ErrorReply) {
+	sortedSet, errReply := db.getAsSortedSet(src)
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+	spec, errReply := parseGeoSearchSpec(cmd, args, store)
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+	if spec.fromMember != nil {
+		longitude, latitude, ok := getGeoPosition(sortedSet, string(spec.fromMember))
+		if !ok {
+			return nil, nil, errDecodeGeoMember
+		}
+		spec.shape.Longitude, spec.shape.Latitude = longitude, latitude
+	}
+	if sortedSet == nil {
+		return nil, spec, nil
+	}
+	return geoSearch(sortedSet, spec), spec, nil
+}
+
+// execGeoSearch returns the members within the area of a circle or a box
+//
+//	GEOSEARCH key <FROMMEMBER member | FROMLONLAT longitude latitude>
+//	  <BYRADIUS radius <M | KM | FT | MI> | BYBOX width height <M | KM | FT | MI>>
+//	  [ASC | DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+func execGeoSearch(db *DB, args [][]byte) redis.Reply {
+	points, spec, errReply := db.searchGeo("GEOSEARCH", string(args[0]), args[1:], false)
+	if errReply != nil {
+		return errReply
+	}
+	withOptions := spec.withCoord || spec.withDist || spec.withHash
+	result := make([]redis.Reply, len(points))
+	for i, point := range points {
+		member := protocol.MakeBulkReply([]byte(point.member))
+		if !withOptions {
+			result[i] = member
+			continue
+		}
+		// [member, distance, hash, [longitude, latitude]]
+		item := []redis.Reply{member}
+		if spec.withDist {
+			item = append(item, makeDistanceReply(point.distance/spec.toMeters))
+		}
+		if spec.withHash {
+			item = append(item, protocol.MakeIntReply(int64(point.hash)))
+		}
+		if spec.withCoord {
+			item = append(item, makeCoordReply(point.longitude, point.latitude))
+		}
+		result[i] = protocol.MakeMultiRawReply(item)
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// execGeoSearchStore stores the result of GEOSEARCH into destination, with geohash as score
+// or distance as score if STOREDIST is given
+//
+//	GEOSEARCHSTORE destination source <FROMMEMBER member | FROMLONLAT longitude latitude>
+//	  <BYRADIUS radius <M | KM | FT | MI> | BYBOX width height <M | KM | FT | MI>>
+//	  [ASC | DESC] [COUNT count [ANY]] [STOREDIST]
+func execGeoSearchStore(db *DB, args [][]byte) redis.Reply {
+	dest := string(args[0])
+	points, spec, errReply := db.searchGeo("GEOSEARCHSTORE", string(args[1]), args[2:], true)
+	if errReply != nil {
+		return errReply
+	}
+	result := SortedSet.Make()
+	for _, point := range points {
+		score := float64(point.hash)
+		if spec.storeDist {
+			score = point.distance / spec.toMeters
+		}
+		result.Add(point.member, score)
+	}
+	return db.storeSortedSet(dest, result, "geosearchstore")
+}
+
+func init() {
+	registerCommand("GeoAdd", execGeoAdd, -5, flagWrite, acl.CategoryWrite|acl.CategoryGeo)
+	registerCommand("GeoPos", execGeoPos, -2, flagReadOnly, acl.CategoryRead|acl.CategoryGeo)
+	registerCommand("GeoDist", execGeoDist, -4, flagReadOnly, acl.CategoryRead|acl.CategoryGeo)
+	registerCommand("GeoHash", execGeoHash, -2, flagReadOnly, acl.CategoryRead|acl.CategoryGeo)
+	registerCommand("GeoSearch", execGeoSearch, -7, flagReadOnly, acl.CategoryRead|acl.CategoryGeo)
+	registerCommand("GeoSearchStore", execGeoSearchStore, -8, flagWrite, acl.CategoryWrite|acl.CategoryGeo).setKeysFunc(writeFirstReadSecond)
+}
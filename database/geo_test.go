@@ -0,0 +1,159 @@
+package database
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// positions and distances below are replied by redis for the same commands
+
+func makeSicily(t *testing.T) (*Server, redis.Connection) {
+	t.Helper()
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "geoadd", "sicily", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania")
+	execCmd(server, c, "geoadd", "sicily", "12.758489", "38.788135", "edge1", "17.241510", "38.788135", "edge2")
+	return server, c
+}
+
+func geoItem(member string, dist string, hash int64, longitude string, latitude string) redis.Reply {
+	item := []redis.Reply{protocol.MakeBulkReply([]byte(member))}
+	if dist != "" {
+		item = append(item, protocol.MakeBulkReply([]byte(dist)))
+	}
+	if hash != 0 {
+		item = append(item, protocol.MakeIntReply(hash))
+	}
+	if longitude != "" {
+		item = append(item, bulks(longitude, latitude))
+	}
+	return protocol.MakeMultiRawReply(item)
+}
+
+func TestGeoAdd(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "geoadd", "g", "13.361389", "38.115556", "Palermo"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "geoadd", "g", "xx", "15.087269", "37.502669", "Catania"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "geoadd", "g", "nx", "15.087269", "37.502669", "Catania", "0", "0", "Palermo"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "geopos", "g", "Palermo"), protocol.MakeMultiRawReply([]redis.Reply{
+		bulks("13.36138933897018433", "38.11555639549629859"),
+	}))
+	// CH counts moved members as well
+	assertReply(t, execCmd(server, c, "geoadd", "g", "ch", "13.361389", "38.115556", "Palermo", "1", "1", "Null"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "geoadd", "g", "xx", "ch", "2", "2", "Palermo", "1", "1", "Null"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "zcard", "g"), protocol.MakeIntReply(3))
+
+	// the longitude is within [-180, 180] and the latitude within [-85.05112878, 85.05112878]
+	assertErr(t, execCmd(server, c, "geoadd", "g", "181", "0", "m"), "ERR invalid longitude,latitude pair 181.000000,0.000000")
+	assertErr(t, execCmd(server, c, "geoadd", "g", "0", "86", "m"), "ERR invalid longitude,latitude pair 0.000000,86.000000")
+	assertErr(t, execCmd(server, c, "geoadd", "g", "1", "1", "ok", "0", "-86", "m"), "ERR invalid longitude,latitude pair")
+	assertErr(t, execCmd(server, c, "geoadd", "g", "x", "0", "m"), "ERR value is not a valid float")
+	assertErr(t, execCmd(server, c, "geoadd", "g", "1", "1"), "ERR wrong number of arguments")
+	assertErr(t, execCmd(server, c, "geoadd", "g", "1", "1", "m", "2"), "ERR syntax error. Try GEOADD")
+	assertErr(t, execCmd(server, c, "geoadd", "g", "nx", "xx", "1", "1", "m"), "ERR XX and NX options at the same time are not compatible")
+	// nothing is added if any position is invalid
+	assertReply(t, execCmd(server, c, "zcard", "g"), protocol.MakeIntReply(3))
+	assertReply(t, execCmd(server, c, "geoadd", "g", "180", "85.05112878", "corner"), protocol.MakeIntReply(1))
+}
+
+func TestGeoPosAndDist(t *testing.T) {
+	server, c := makeSicily(t)
+	assertReply(t, execCmd(server, c, "geopos", "sicily", "Palermo", "Catania", "NonExisting"), protocol.MakeMultiRawReply([]redis.Reply{
+		bulks("13.36138933897018433", "38.11555639549629859"),
+		bulks("15.08726745843887329", "37.50266842333162032"),
+		protocol.MakeNullMultiBulkReply(),
+	}))
+	assertReply(t, execCmd(server, c, "geopos", "none", "Palermo"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeNullMultiBulkReply(),
+	}))
+	assertReply(t, execCmd(server, c, "geodist", "sicily", "Palermo", "Catania"), protocol.MakeBulkReply([]byte("166274.1516")))
+	assertReply(t, execCmd(server, c, "geodist", "sicily", "Palermo", "Catania", "km"), protocol.MakeBulkReply([]byte("166.2742")))
+	assertReply(t, execCmd(server, c, "geodist", "sicily", "Palermo", "Catania", "MI"), protocol.MakeBulkReply([]byte("103.3182")))
+	assertReply(t, execCmd(server, c, "geodist", "sicily", "Palermo", "Catania", "ft"), protocol.MakeBulkReply([]byte("545518.8700")))
+	assertReply(t, execCmd(server, c, "geodist", "sicily", "Palermo", "Palermo"), protocol.MakeBulkReply([]byte("0.0000")))
+	assertReply(t, execCmd(server, c, "geodist", "sicily", "Foo", "Bar"), protocol.MakeNullBulkReply())
+	assertErr(t, execCmd(server, c, "geodist", "sicily", "Palermo", "Catania", "yd"), "ERR unsupported unit provided. please use M, KM, FT, MI")
+	assertReply(t, execCmd(server, c, "geohash", "sicily", "Palermo", "Catania", "none"),
+		protocol.MakeMultiBulkReply([][]byte{[]byte("sqc8b49rny0"), []byte("sqdtr74hyu0"), nil}))
+}
+
+func TestGeoSearch(t *testing.T) {
+	server, c := makeSicily(t)
+	assertReply(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "byradius", "200", "km", "asc"), bulks("Catania", "Palermo"))
+	assertReply(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "byradius", "200", "km", "desc"), bulks("Palermo", "Catania"))
+	assertReply(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "bybox", "400", "400", "km", "asc", "withcoord", "withdist"),
+		protocol.MakeMultiRawReply([]redis.Reply{
+			geoItem("Catania", "56.4413", 0, "15.08726745843887329", "37.50266842333162032"),
+			geoItem("Palermo", "190.4424", 0, "13.36138933897018433", "38.11555639549629859"),
+			geoItem("edge2", "279.7403", 0, "17.24151045083999634", "38.78813451624225195"),
+			geoItem("edge1", "279.7405", 0, "12.7584877610206604", "38.78813451624225195"),
+		}))
+	assertReply(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "byradius", "200", "km", "asc", "withhash"),
+		protocol.MakeMultiRawReply([]redis.Reply{
+			geoItem("Catania", "", 3479447370796909, "", ""),
+			geoItem("Palermo", "", 3479099956230698, "", ""),
+		}))
+	// the distance is in the unit of the shape
+	assertReply(t, execCmd(server, c, "geosearch", "sicily", "frommember", "Catania", "byradius", "200", "km", "asc", "withdist"),
+		protocol.MakeMultiRawReply([]redis.Reply{
+			geoItem("Catania", "0.0000", 0, "", ""),
+			geoItem("Palermo", "166.2742", 0, "", ""),
+		}))
+	assertReply(t, execCmd(server, c, "geosearch", "sicily", "frommember", "Palermo", "bybox", "200", "200", "mi", "desc", "count", "2"),
+		bulks("Catania", "edge1"))
+	// the box is 400km wide but only 150km high, edges are out of it
+	assertReply(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37.5", "bybox", "400", "150", "km", "asc"),
+		bulks("Catania", "Palermo"))
+
+	// COUNT returns the nearest members, COUNT ANY returns as soon as enough members are found
+	assertReply(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "byradius", "500", "km", "count", "1"), bulks("Catania"))
+	anyReply := execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "byradius", "500", "km", "count", "3", "any")
+	if got, ok := anyReply.(*protocol.MultiRawReply); !ok || len(got.Replies) != 3 {
+		t.Fatalf("COUNT 3 ANY returns %q", anyReply.ToBytes())
+	}
+	assertReply(t, execCmd(server, c, "geosearch", "none", "fromlonlat", "15", "37", "byradius", "1", "km"), protocol.MakeEmptyMultiBulkReply())
+
+	assertErr(t, execCmd(server, c, "geosearch", "sicily", "frommember", "none", "byradius", "1", "km"), "ERR could not decode requested zset member")
+	assertErr(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "frommember", "Palermo", "byradius", "1", "km"),
+		"ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH")
+	assertErr(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "byradius", "1", "km", "bybox", "1", "1", "km"),
+		"ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH")
+	assertErr(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "byradius", "1", "km", "any"), "ERR the ANY argument requires COUNT argument")
+	assertErr(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "byradius", "1", "km", "count", "0"), "ERR COUNT must be > 0")
+	assertErr(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "byradius", "-1", "km"), "ERR radius cannot be negative")
+	assertErr(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "bybox", "1", "-1", "km"), "ERR height or width cannot be negative")
+	assertErr(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "byradius", "1", "yd"), "ERR unsupported unit provided")
+	assertErr(t, execCmd(server, c, "geosearch", "sicily", "fromlonlat", "15", "37", "byradius", "1", "km", "storedist"), "ERR syntax error")
+}
+
+func TestGeoSearchStore(t *testing.T) {
+	server, c := makeSicily(t)
+	assertReply(t, execCmd(server, c, "geosearchstore", "dst", "sicily", "fromlonlat", "15", "37", "bybox", "400", "400", "km", "asc", "count", "3"),
+		protocol.MakeIntReply(3))
+	// without STOREDIST the members are stored with their geohashes, so the destination is a geo index too
+	assertReply(t, execCmd(server, c, "geopos", "dst", "Catania"), protocol.MakeMultiRawReply([]redis.Reply{
+		bulks("15.08726745843887329", "37.50266842333162032"),
+	}))
+	assertReply(t, execCmd(server, c, "zscore", "dst", "Palermo"), protocol.MakeBulkReply([]byte("3479099956230698")))
+
+	assertReply(t, execCmd(server, c, "geosearchstore", "dst", "sicily", "fromlonlat", "15", "37", "bybox", "400", "400", "km", "asc", "count", "3", "storedist"),
+		protocol.MakeIntReply(3))
+	assertReply(t, execCmd(server, c, "zrange", "dst", "0", "-1"), bulks("Catania", "Palermo", "edge2"))
+	for member, expected := range map[string]string{"Catania": "56.4413", "Palermo": "190.4424", "edge2": "279.7403"} {
+		reply := execCmd(server, c, "zscore", "dst", member).(*protocol.BulkReply)
+		score, err := strconv.ParseFloat(string(reply.Arg), 64)
+		if err != nil || strconv.FormatFloat(score, 'f', 4, 64) != expected {
+			t.Fatalf("distance of %s is %s, expected %s", member, reply.Arg, expected)
+		}
+	}
+
+	// an empty result deletes the destination
+	assertReply(t, execCmd(server, c, "geosearchstore", "dst", "sicily", "fromlonlat", "0", "0", "byradius", "1", "km"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "exists", "dst"), protocol.MakeIntReply(0))
+	assertErr(t, execCmd(server, c, "geosearchstore", "dst", "sicily", "fromlonlat", "15", "37", "byradius", "1", "km", "withdist"),
+		"ERR GEOSEARCHSTORE is not compatible with WITHDIST, WITHHASH and WITHCOORD options")
+}
@@ -0,0 +1,468 @@
+package database
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/config"
+	Hash "github.com/tonge3199/redis_go/datastruct/hash"
+	"github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func (db *DB) getAsHash(key string) (*Hash.Hash, protocol.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	hash, ok := entity.Data.(*Hash.Hash)
+	if !ok {
+		return nil, protocol.MakeWrongTypeErrReply()
+	}
+	if hash.HasExpires() && hash.Len() == 0 {
+		// all fields expired, the key is waiting to be deleted
+		return nil, nil
+	}
+	return hash, nil
+}
+
+// getAsHashForWrite is getAsHash for write commands, it deletes expired fields before returning
+func (db *DB) getAsHashForWrite(key string) (*Hash.Hash, protocol.ErrorReply) {
+	db.expireHashFields(key)
+	return db.getAsHash(key)
+}
+
+func (db *DB) getOrInitHash(key string) (hash *Hash.Hash, inited bool, errReply protocol.ErrorReply) {
+	hash, errReply = db.getAsHashForWrite(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if hash == nil {
+		hash = Hash.Make(config.Properties.HashMaxListpackEntries, config.Properties.HashMaxListpackValue)
+		db.PutEntity(key, &database.DataEntity{
+			Data: hash,
+		})
+		inited = true
+	}
+	return hash, inited, nil
+}
+
+// execHSet sets fields of hash
+//
+//	HSET key field value [field value ...]
+func execHSet(db *DB, args [][]byte) redis.Reply {
+	if len(args)%2 != 1 {
+		return protocol.MakeArgNumErrReply("hset")
+	}
+	key := string(args[0])
+
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	added := 0
+	for i := 1; i < len(args); i += 2 {
+		added += hash.Set(string(args[i]), args[i+1])
+	}
+	db.notifyKeyspaceEvent(notifyHash, "hset", key)
+	return protocol.MakeIntReply(int64(added))
+}
+
+// execHSetNX sets field only if the field doesn't exist
+//
+//	HSETNX key field value
+func execHSetNX(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	value := args[2]
+
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	if _, exists := hash.Get(field); exists {
+		return protocol.MakeIntReply(0)
+	}
+	hash.Set(field, value)
+	db.notifyKeyspaceEvent(notifyHash, "hset", key)
+	return protocol.MakeIntReply(1)
+}
+
+// execHGet gets value of field
+//
+//	HGET key field
+func execHGet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeNullBulkReply()
+	}
+
+	value, exists := hash.Get(field)
+	if !exists {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeBulkReply(value)
+}
+
+// execHMGet gets values of fields
+//
+//	HMGET key field [field ...]
+func execHMGet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	result := make([][]byte, len(args)-1)
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeMultiBulkReply(result)
+	}
+
+	for i, field := range args[1:] {
+		value, exists := hash.Get(string(field))
+		if exists {
+			result[i] = value
+		}
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// execHExists checks whether field exists
+//
+//	HEXISTS key field
+func execHExists(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeIntReply(0)
+	}
+
+	if _, exists := hash.Get(field); exists {
+		return protocol.MakeIntReply(1)
+	}
+	return protocol.MakeIntReply(0)
+}
+
+// execHDel deletes fields, the key is removed once its hash becomes empty
+//
+//	HDEL key field [field ...]
+func execHDel(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	hash, errReply := db.getAsHashForWrite(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeIntReply(0)
+	}
+
+	deleted := 0
+	for _, field := range args[1:] {
+		deleted += hash.Remove(string(field))
+	}
+	if deleted > 0 {
+		db.notifyKeyspaceEvent(notifyHash, "hdel", key)
+	}
+	if hash.Len() == 0 {
+		db.deleteKey(key)
+	}
+	return protocol.MakeIntReply(int64(deleted))
+}
+
+// execHLen gets number of fields
+//
+//	HLEN key
+func execHLen(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(int64(hash.Len()))
+}
+
+// execHStrlen gets string length of the value of field
+//
+//	HSTRLEN key field
+func execHStrlen(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeIntReply(0)
+	}
+
+	value, exists := hash.Get(field)
+	if !exists {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(int64(len(value)))
+}
+
+// execHGetAll gets all field-value pairs as a flat array [f1, v1, f2, v2 ...]
+//
+//	HGETALL key
+func execHGetAll(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+
+	result := make([][]byte, 0, hash.Len()*2)
+	hash.ForEach(func(field string, val []byte) bool {
+		result = append(result, []byte(field), val)
+		return true
+	})
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// execHKeys gets all fields
+//
+//	HKEYS key
+func execHKeys(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+
+	fields := make([][]byte, 0, hash.Len())
+	hash.ForEach(func(field string, val []byte) bool {
+		fields = append(fields, []byte(field))
+		return true
+	})
+	return protocol.MakeMultiBulkReply(fields)
+}
+
+// execHVals gets all values
+//
+//	HVALS key
+func execHVals(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+
+	values := make([][]byte, 0, hash.Len())
+	hash.ForEach(func(field string, val []byte) bool {
+		values = append(values, val)
+		return true
+	})
+	return protocol.MakeMultiBulkReply(values)
+}
+
+// execHIncrBy increments the integer value of field
+//
+//	HINCRBY key field increment
+func execHIncrBy(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	delta, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	var current int64
+	if value, exists := hash.Get(field); exists {
+		current, err = strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR hash value is not an integer")
+		}
+	}
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return protocol.MakeErrReply("ERR increment or decrement would overflow")
+	}
+	result := current + delta
+	hash.SetKeepTTL(field, []byte(strconv.FormatInt(result, 10)))
+	db.notifyKeyspaceEvent(notifyHash, "hincrby", key)
+	return protocol.MakeIntReply(result)
+}
+
+// execHIncrByFloat increments the float value of field
+//
+//	HINCRBYFLOAT key field increment
+func execHIncrByFloat(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	delta, err := strconv.ParseFloat(string(args[2]), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return protocol.MakeErrReply("ERR value is not a valid float")
+	}
+
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	var current float64
+	if value, exists := hash.Get(field); exists {
+		current, err = strconv.ParseFloat(string(value), 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR hash value is not a float")
+		}
+	}
+	result := current + delta
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return protocol.MakeErrReply("ERR increment would produce NaN or Infinity")
+	}
+	value := []byte(strconv.FormatFloat(result, 'f', -1, 64))
+	hash.SetKeepTTL(field, value)
+	db.notifyKeyspaceEvent(notifyHash, "hincrbyfloat", key)
+	return protocol.MakeBulkReply(value)
+}
+
+// execHRandField returns random fields
+//
+//	HRANDFIELD key [count [WITHVALUES]]
+//
+// a positive count returns at most count distinct fields,
+// a negative count returns exactly -count fields which may be duplicated
+func execHRandField(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	withCount := len(args) >= 2
+	count := int64(1)
+	withValues := false
+	if withCount {
+		var err error
+		count, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		if len(args) == 3 {
+			if strings.ToUpper(string(args[2])) != "WITHVALUES" {
+				return protocol.MakeSyntaxErrReply()
+			}
+			withValues = true
+		} else if len(args) > 3 {
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		if withCount {
+			return protocol.MakeEmptyMultiBulkReply()
+		}
+		return protocol.MakeNullBulkReply()
+	}
+
+	if !withCount {
+		field := hash.RandomFields(1)[0]
+		return protocol.MakeBulkReply([]byte(field))
+	}
+	var fields []string
+	if count >= 0 {
+		fields = hash.RandomDistinctFields(int(count))
+	} else {
+		if -count > math.MaxInt32 {
+			return protocol.MakeErrReply("ERR value is out of range")
+		}
+		fields = hash.RandomFields(int(-count))
+	}
+	result := make([][]byte, 0, len(fields)*2)
+	for _, field := range fields {
+		result = append(result, []byte(field))
+		if withValues {
+			value, _ := hash.Get(field)
+			result = append(result, value)
+		}
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// execHScan iterates fields of hash
+//
+//	HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES]
+func execHScan(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	scan, errReply := parseScanArgs("hscan", args[1:])
+	if errReply != nil {
+		return errReply
+	}
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, 0)
+	if hash == nil {
+		return makeScanReply(0, result)
+	}
+	cursor := hash.Scan(scan.cursor, scan.count, func(field string, val []byte) {
+		if !scan.match(field) {
+			return
+		}
+		result = append(result, []byte(field))
+		if !scan.noValues {
+			result = append(result, val)
+		}
+	})
+	return makeScanReply(cursor, result)
+}
+
+func init() {
+	registerCommand("HSet", execHSet, -4, flagWrite, acl.CategoryWrite|acl.CategoryHash)
+	registerCommand("HSetNX", execHSetNX, 4, flagWrite, acl.CategoryWrite|acl.CategoryHash)
+	registerCommand("HGet", execHGet, 3, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HMGet", execHMGet, -3, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HExists", execHExists, 3, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HDel", execHDel, -3, flagWrite, acl.CategoryWrite|acl.CategoryHash)
+	registerCommand("HLen", execHLen, 2, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HStrlen", execHStrlen, 3, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HGetAll", execHGetAll, 2, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HKeys", execHKeys, 2, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HVals", execHVals, 2, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HIncrBy", execHIncrBy, 4, flagWrite, acl.CategoryWrite|acl.CategoryHash).setAccessWritten()
+	registerCommand("HIncrByFloat", execHIncrByFloat, 4, flagWrite, acl.CategoryWrite|acl.CategoryHash).setAccessWritten()
+	registerCommand("HRandField", execHRandField, -2, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HScan", execHScan, -3, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+}
@@ -0,0 +1,271 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// maxFieldExpireTime is the max expire time of hash fields in unix milliseconds, the same as redis (2^48-1)
+const maxFieldExpireTime = 1<<48 - 1
+
+// replies of HEXPIRE family for each field
+const (
+	fieldNotExists       = -2
+	fieldNoTTL           = -1
+	fieldConditionNotMet = 0
+	fieldTTLUpdated      = 1
+	fieldDeleted         = 2
+)
+
+// expire conditions of HEXPIRE family
+const (
+	expireAlways = iota
+	expireNX     // only if the field has no ttl
+	expireXX     // only if the field has ttl
+	expireGT     // only if the new ttl is greater than the current one
+	expireLT     // only if the new ttl is less than the current one
+)
+
+var (
+	errFieldsMissing = protocol.MakeErrReply("ERR Mandatory argument FIELDS is missing or not at the right position")
+	errNumFields     = protocol.MakeErrReply("ERR Parameter `numFields` should be greater than 0")
+	errFieldsCount   = protocol.MakeErrReply("ERR The `numfields` parameter must match the number of arguments")
+	errExpireTime    = protocol.MakeErrReply("ERR invalid expire time, must be >= 0 and <= 281474976710655")
+)
+
+// parseFieldsArg parses `FIELDS numfields field [field ...]` at the tail of args
+func parseFieldsArg(args [][]byte) ([]string, protocol.ErrorReply) {
+	if len(args) < 2 || strings.ToUpper(string(args[0])) != "FIELDS" {
+		return nil, errFieldsMissing
+	}
+	numFields, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return nil, errNotInteger
+	}
+	if numFields <= 0 {
+		return nil, errNumFields
+	}
+	if numFields != int64(len(args)-2) {
+		return nil, errFieldsCount
+	}
+	fields := make([]string, numFields)
+	for i, arg := range args[2:] {
+		fields[i] = string(arg)
+	}
+	return fields, nil
+}
+
+// makeFieldsReply returns an array with the same code for each field
+func makeFieldsReply(n int, code int64) redis.Reply {
+	replies := make([]redis.Reply, n)
+	for i := range replies {
+		replies[i] = protocol.MakeIntReply(code)
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// makeHExpire returns the executor of HEXPIRE family.
+// unit is the unit of time argument, absolute means the time argument is a unix timestamp
+//
+//	HEXPIRE key seconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func makeHExpire(unit time.Duration, absolute bool) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		key := string(args[0])
+		raw, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		factor := int64(unit / time.Millisecond)
+		if raw < 0 || raw > maxFieldExpireTime/factor {
+			return errExpireTime
+		}
+		when := raw * factor
+		now := time.Now().UnixMilli()
+		if !absolute {
+			when += now
+		}
+		if when > maxFieldExpireTime {
+			return errExpireTime
+		}
+
+		condition := expireAlways
+		rest := args[2:]
+		switch strings.ToUpper(string(rest[0])) {
+		case "NX":
+			condition = expireNX
+		case "XX":
+			condition = expireXX
+		case "GT":
+			condition = expireGT
+		case "LT":
+			condition = expireLT
+		}
+		if condition != expireAlways {
+			rest = rest[1:]
+		}
+		fields, errReply := parseFieldsArg(rest)
+		if errReply != nil {
+			return errReply
+		}
+
+		hash, errReply := db.getAsHashForWrite(key)
+		if errReply != nil {
+			return errReply
+		}
+		if hash == nil {
+			return makeFieldsReply(len(fields), fieldNotExists)
+		}
+
+		replies := make([]redis.Reply, len(fields))
+		updated, deleted := false, false
+		for i, field := range fields {
+			code := setFieldExpire(db, key, field, when, now, condition)
+			replies[i] = protocol.MakeIntReply(code)
+			updated = updated || code == fieldTTLUpdated
+			deleted = deleted || code == fieldDeleted
+		}
+		if updated {
+			db.notifyKeyspaceEvent(notifyHash, "hexpire", key)
+		}
+		if deleted {
+			db.notifyKeyspaceEvent(notifyHash, "hdel", key)
+		}
+		if hash.Len() == 0 {
+			db.deleteKey(key)
+		}
+		return protocol.MakeMultiRawReply(replies)
+	}
+}
+
+// setFieldExpire sets the expire time of a single field and returns the reply code of HEXPIRE
+func setFieldExpire(db *DB, key string, field string, when int64, now int64, condition int) int64 {
+	hash, _ := db.getAsHash(key)
+	if _, ok := hash.Get(field); !ok {
+		return fieldNotExists
+	}
+	current, hasTTL := hash.GetExpire(field)
+	switch condition {
+	case expireNX:
+		if hasTTL {
+			return fieldConditionNotMet
+		}
+	case expireXX:
+		if !hasTTL {
+			return fieldConditionNotMet
+		}
+	case expireGT:
+		// a field without ttl is treated as having infinite ttl
+		if !hasTTL || when <= current {
+			return fieldConditionNotMet
+		}
+	case expireLT:
+		if hasTTL && when >= current {
+			return fieldConditionNotMet
+		}
+	}
+	if when <= now {
+		hash.Remove(field)
+		return fieldDeleted
+	}
+	hash.SetExpire(field, when)
+	db.trackFieldTTL(key)
+	return fieldTTLUpdated
+}
+
+// makeHTTL returns the executor of HTTL family,
+// absolute means returning the unix timestamp instead of the remaining time
+//
+//	HTTL key FIELDS numfields field [field ...]
+func makeHTTL(unit time.Duration, absolute bool) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		key := string(args[0])
+		fields, errReply := parseFieldsArg(args[1:])
+		if errReply != nil {
+			return errReply
+		}
+		hash, errReply := db.getAsHash(key)
+		if errReply != nil {
+			return errReply
+		}
+		if hash == nil {
+			return makeFieldsReply(len(fields), fieldNotExists)
+		}
+
+		factor := int64(unit / time.Millisecond)
+		now := time.Now().UnixMilli()
+		replies := make([]redis.Reply, len(fields))
+		for i, field := range fields {
+			if _, ok := hash.Get(field); !ok {
+				replies[i] = protocol.MakeIntReply(fieldNotExists)
+				continue
+			}
+			when, ok := hash.GetExpire(field)
+			if !ok {
+				replies[i] = protocol.MakeIntReply(fieldNoTTL)
+				continue
+			}
+			if absolute {
+				replies[i] = protocol.MakeIntReply(when / factor)
+			} else {
+				// round to the nearest unit, the same as TTL
+				replies[i] = protocol.MakeIntReply((when - now + factor/2) / factor)
+			}
+		}
+		return protocol.MakeMultiRawReply(replies)
+	}
+}
+
+// execHPersist removes the ttl of fields
+//
+//	HPERSIST key FIELDS numfields field [field ...]
+func execHPersist(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	fields, errReply := parseFieldsArg(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	hash, errReply := db.getAsHashForWrite(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return makeFieldsReply(len(fields), fieldNotExists)
+	}
+
+	replies := make([]redis.Reply, len(fields))
+	persisted := false
+	for i, field := range fields {
+		if _, ok := hash.Get(field); !ok {
+			replies[i] = protocol.MakeIntReply(fieldNotExists)
+		} else if hash.Persist(field) {
+			replies[i] = protocol.MakeIntReply(1)
+			persisted = true
+		} else {
+			replies[i] = protocol.MakeIntReply(fieldNoTTL)
+		}
+	}
+	if !hash.HasExpires() {
+		db.untrackFieldTTL(key)
+	}
+	if persisted {
+		db.notifyKeyspaceEvent(notifyHash, "hpersist", key)
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+func init() {
+	registerCommand("HExpire", makeHExpire(time.Second, false), -6, flagWrite, acl.CategoryWrite|acl.CategoryHash)
+	registerCommand("HPExpire", makeHExpire(time.Millisecond, false), -6, flagWrite, acl.CategoryWrite|acl.CategoryHash)
+	registerCommand("HExpireAt", makeHExpire(time.Second, true), -6, flagWrite, acl.CategoryWrite|acl.CategoryHash)
+	registerCommand("HPExpireAt", makeHExpire(time.Millisecond, true), -6, flagWrite, acl.CategoryWrite|acl.CategoryHash)
+	registerCommand("HTTL", makeHTTL(time.Second, false), -5, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HPTTL", makeHTTL(time.Millisecond, false), -5, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HExpireTime", makeHTTL(time.Second, true), -5, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HPExpireTime", makeHTTL(time.Millisecond, true), -5, flagReadOnly, acl.CategoryRead|acl.CategoryHash)
+	registerCommand("HPersist", execHPersist, -5, flagWrite, acl.CategoryWrite|acl.CategoryHash)
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// ints returns an array of integers, like replies of HEXPIRE family
+func ints(values ...int64) redis.Reply {
+	replies := make([]redis.Reply, len(values))
+	for i, value := range values {
+		replies[i] = protocol.MakeIntReply(value)
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+func TestHExpire(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "hexpire", "h", "100", "fields", "2", "a", "b"), ints(-2, -2))
+	execCmd(server, c, "hset", "h", "a", "1", "b", "2", "c", "3")
+
+	assertReply(t, execCmd(server, c, "hexpire", "h", "100", "fields", "2", "a", "x"), ints(1, -2))
+	assertReply(t, execCmd(server, c, "httl", "h", "fields", "3", "a", "b", "x"), ints(100, -1, -2))
+	pttl := execCmd(server, c, "hpttl", "h", "fields", "1", "a").(*protocol.MultiRawReply).Replies[0].(*protocol.IntReply).Code
+	if pttl <= 99000 || pttl > 100000 {
+		t.Fatalf("pttl is %d, expected about 100000", pttl)
+	}
+
+	// conditions, a field without ttl has an infinite ttl
+	assertReply(t, execCmd(server, c, "hexpire", "h", "200", "nx", "fields", "2", "a", "b"), ints(0, 1))
+	assertReply(t, execCmd(server, c, "hexpire", "h", "300", "xx", "fields", "2", "a", "c"), ints(1, 0))
+	assertReply(t, execCmd(server, c, "hexpire", "h", "250", "gt", "fields", "2", "a", "c"), ints(0, 0))
+	assertReply(t, execCmd(server, c, "hexpire", "h", "250", "lt", "fields", "2", "a", "c"), ints(1, 1))
+	assertReply(t, execCmd(server, c, "httl", "h", "fields", "3", "a", "b", "c"), ints(250, 200, 250))
+
+	// absolute times
+	at := time.Now().Add(time.Hour).Unix()
+	assertReply(t, execCmd(server, c, "hexpireat", "h", strconv.FormatInt(at, 10), "fields", "1", "a"), ints(1))
+	assertReply(t, execCmd(server, c, "hexpiretime", "h", "fields", "2", "a", "x"), ints(at, -2))
+	assertReply(t, execCmd(server, c, "hpexpiretime", "h", "fields", "1", "a"), ints(at*1000))
+	assertReply(t, execCmd(server, c, "hpexpireat", "h", strconv.FormatInt(at*1000+1, 10), "fields", "1", "a"), ints(1))
+	assertReply(t, execCmd(server, c, "hpexpiretime", "h", "fields", "1", "a"), ints(at*1000+1))
+
+	assertReply(t, execCmd(server, c, "hpersist", "h", "fields", "3", "a", "a", "x"), ints(1, -1, -2))
+	assertReply(t, execCmd(server, c, "httl", "h", "fields", "1", "a"), ints(-1))
+
+	// a time in the past deletes fields, and the key once it's empty
+	assertReply(t, execCmd(server, c, "hexpire", "h", "0", "fields", "2", "a", "b"), ints(2, 2))
+	assertReply(t, execCmd(server, c, "hkeys", "h"), bulks("c"))
+	assertReply(t, execCmd(server, c, "hexpireat", "h", "1", "fields", "1", "c"), ints(2))
+	assertReply(t, execCmd(server, c, "exists", "h"), protocol.MakeIntReply(0))
+
+	execCmd(server, c, "hset", "h", "a", "1")
+	assertErr(t, execCmd(server, c, "hexpire", "h", "100", "nx", "a", "b"), "ERR Mandatory argument FIELDS is missing")
+	assertErr(t, execCmd(server, c, "hexpire", "h", "100", "fields", "2", "a"), "ERR The `numfields` parameter must match")
+	assertErr(t, execCmd(server, c, "hexpire", "h", "100", "fields", "0", "a"), "ERR Parameter `numFields` should be greater than 0")
+	assertErr(t, execCmd(server, c, "hexpire", "h", "-1", "fields", "1", "a"), "ERR invalid expire time")
+	assertErr(t, execCmd(server, c, "hpexpireat", "h", "281474976710656", "fields", "1", "a"), "ERR invalid expire time")
+	execCmd(server, c, "set", "str", "v")
+	assertReply(t, execCmd(server, c, "hexpire", "str", "100", "fields", "1", "a"), protocol.MakeWrongTypeErrReply())
+}
+
+func TestHashFieldExpiration(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "hset", "h", "a", "1", "b", "2", "c", "3")
+	execCmd(server, c, "hpexpire", "h", "10", "fields", "1", "a")
+	time.Sleep(20 * time.Millisecond)
+
+	// readonly commands skip the expired field before it's deleted
+	assertReply(t, execCmd(server, c, "hget", "h", "a"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "hlen", "h"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "hgetall", "h"), bulks("b", "2", "c", "3"))
+	assertReply(t, execCmd(server, c, "httl", "h", "fields", "1", "a"), ints(-2))
+
+	// HSET removes the ttl of a field, HINCRBY keeps it
+	execCmd(server, c, "hexpire", "h", "100", "fields", "2", "b", "c")
+	assertReply(t, execCmd(server, c, "hset", "h", "b", "20"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "hincrby", "h", "c", "1"), protocol.MakeIntReply(4))
+	assertReply(t, execCmd(server, c, "httl", "h", "fields", "2", "b", "c"), ints(-1, 100))
+
+	// the active expiry deletes the key once all fields expired, without any command touching it
+	execCmd(server, c, "hpexpire", "h", "10", "fields", "2", "b", "c")
+	db, _ := server.selectDB(0)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, exists := db.GetEntity("h"); !exists {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the hash of expired fields is not deleted by the active expiry")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func TestHashCommands(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+
+	assertReply(t, execCmd(server, c, "hset", "h", "a", "1", "b", "2"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "hset", "h", "a", "10", "c", "3"), protocol.MakeIntReply(1))
+	assertErr(t, execCmd(server, c, "hset", "h", "a"), "ERR wrong number of arguments for 'hset' command")
+	assertReply(t, execCmd(server, c, "hsetnx", "h", "a", "x"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "hsetnx", "h", "d", "4"), protocol.MakeIntReply(1))
+
+	assertReply(t, execCmd(server, c, "hget", "h", "a"), protocol.MakeBulkReply([]byte("10")))
+	assertReply(t, execCmd(server, c, "hget", "h", "x"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "hget", "nohash", "a"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "hmget", "h", "a", "x", "b"), protocol.MakeMultiBulkReply([][]byte{
+		[]byte("10"), nil, []byte("2"),
+	}))
+	assertReply(t, execCmd(server, c, "hexists", "h", "b"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "hexists", "h", "x"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "hlen", "h"), protocol.MakeIntReply(4))
+	assertReply(t, execCmd(server, c, "hstrlen", "h", "a"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "hstrlen", "h", "x"), protocol.MakeIntReply(0))
+
+	// a small hash keeps the insertion order of fields
+	assertReply(t, execCmd(server, c, "hgetall", "h"), bulks("a", "10", "b", "2", "c", "3", "d", "4"))
+	assertReply(t, execCmd(server, c, "hkeys", "h"), bulks("a", "b", "c", "d"))
+	assertReply(t, execCmd(server, c, "hvals", "h"), bulks("10", "2", "3", "4"))
+	assertReply(t, execCmd(server, c, "hgetall", "nohash"), protocol.MakeEmptyMultiBulkReply())
+
+	assertReply(t, execCmd(server, c, "hdel", "h", "a", "x", "b"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "hdel", "h", "c", "d"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "keys", "*"), protocol.MakeEmptyMultiBulkReply())
+
+	execCmd(server, c, "set", "str", "v")
+	for _, cmdLine := range [][]string{
+		{"hset", "str", "a", "1"}, {"hget", "str", "a"}, {"hgetall", "str"}, {"hdel", "str", "a"},
+		{"hincrby", "str", "a", "1"}, {"hscan", "str", "0"},
+	} {
+		assertReply(t, execCmd(server, c, cmdLine...), protocol.MakeWrongTypeErrReply())
+	}
+}
+
+func TestHashIncr(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+
+	assertReply(t, execCmd(server, c, "hincrby", "h", "n", "5"), protocol.MakeIntReply(5))
+	assertReply(t, execCmd(server, c, "hincrby", "h", "n", "-7"), protocol.MakeIntReply(-2))
+	assertErr(t, execCmd(server, c, "hincrby", "h", "n", "x"), "ERR value is not an integer")
+	execCmd(server, c, "hset", "h", "max", "9223372036854775807", "s", "abc")
+	assertErr(t, execCmd(server, c, "hincrby", "h", "max", "1"), "ERR increment or decrement would overflow")
+	assertErr(t, execCmd(server, c, "hincrby", "h", "s", "1"), "ERR hash value is not an integer")
+
+	assertReply(t, execCmd(server, c, "hincrbyfloat", "h", "f", "1.5"), protocol.MakeBulkReply([]byte("1.5")))
+	assertReply(t, execCmd(server, c, "hincrbyfloat", "h", "f", "-0.25"), protocol.MakeBulkReply([]byte("1.25")))
+	assertReply(t, execCmd(server, c, "hincrbyfloat", "h", "n", "0.5"), protocol.MakeBulkReply([]byte("-1.5")))
+	assertErr(t, execCmd(server, c, "hincrbyfloat", "h", "f", "inf"), "ERR value is not a valid float")
+	assertErr(t, execCmd(server, c, "hincrbyfloat", "h", "s", "1"), "ERR hash value is not a float")
+}
+
+func TestHRandField(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "hrandfield", "h"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "hrandfield", "h", "3"), protocol.MakeEmptyMultiBulkReply())
+	execCmd(server, c, "hset", "h", "a", "1", "b", "2", "c", "3")
+
+	values := map[string]string{"a": "1", "b": "2", "c": "3"}
+	field := string(execCmd(server, c, "hrandfield", "h").(*protocol.BulkReply).Arg)
+	if _, ok := values[field]; !ok {
+		t.Fatalf("random field %q doesn't exist", field)
+	}
+	// a positive count returns distinct fields, at most all of them
+	distinct := execCmd(server, c, "hrandfield", "h", "10").(*protocol.MultiBulkReply).Args
+	seen := make(map[string]bool)
+	for _, field := range distinct {
+		seen[string(field)] = true
+	}
+	if len(distinct) != 3 || len(seen) != 3 {
+		t.Fatalf("got fields %q, expected a, b and c", distinct)
+	}
+	// a negative count returns exactly -count fields, possibly duplicated
+	withValues := execCmd(server, c, "hrandfield", "h", "-5", "withvalues").(*protocol.MultiBulkReply).Args
+	if len(withValues) != 10 {
+		t.Fatalf("got %d fields and values, expected 5 pairs", len(withValues)/2)
+	}
+	for i := 0; i < len(withValues); i += 2 {
+		if values[string(withValues[i])] != string(withValues[i+1]) {
+			t.Fatalf("field %q has value %q", withValues[i], withValues[i+1])
+		}
+	}
+	assertErr(t, execCmd(server, c, "hrandfield", "h", "1", "x"), "ERR syntax error")
+}
+
+func TestHScan(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	// 200 fields exceed hash-max-listpack-entries, so the hash is scanned in several steps
+	args := []string{"hset", "h"}
+	for i := 0; i < 200; i++ {
+		args = append(args, "f"+strconv.Itoa(i), strconv.Itoa(i))
+	}
+	execCmd(server, c, args...)
+
+	scanned := make(map[string]string)
+	cursor := "0"
+	for {
+		reply := execCmd(server, c, "hscan", "h", cursor, "count", "20").(*protocol.MultiRawReply)
+		cursor = string(reply.Replies[0].(*protocol.BulkReply).Arg)
+		items := reply.Replies[1].(*protocol.MultiBulkReply).Args
+		for i := 0; i < len(items); i += 2 {
+			scanned[string(items[i])] = string(items[i+1])
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	if len(scanned) != 200 || scanned["f42"] != "42" {
+		t.Fatalf("scanned %d fields, f42 is %q", len(scanned), scanned["f42"])
+	}
+
+	execCmd(server, c, "hset", "small", "a1", "1", "b1", "2", "a2", "3")
+	assertReply(t, execCmd(server, c, "hscan", "small", "0", "match", "a*", "novalues"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("0")),
+		bulks("a1", "a2"),
+	}))
+}
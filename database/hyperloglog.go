@@ -0,0 +1,136 @@
+package database
+
+import (
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/datastruct/hyperloglog"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// A HyperLogLog is a string value with the same bytes as redis, GET and SET on it work as on any string
+
+var (
+	errNotHLL       = protocol.MakeErrReply("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	errCorruptedHLL = protocol.MakeErrReply(hyperloglog.ErrCorrupted.Error())
+)
+
+func (db *DB) getAsHLL(key string) (hyperloglog.HLL, protocol.ErrorReply) {
+	str, errReply := db.getAsString(key)
+	if errReply != nil || str == nil {
+		return nil, errReply
+	}
+	if !hyperloglog.IsHLL(str) {
+		return nil, errNotHLL
+	}
+	return str, nil
+}
+
+// execPFAdd adds elements into the HyperLogLog, returns 1 if the approximated cardinality may be changed
+//
+//	PFADD key [element [element ...]]
+func execPFAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	hll, errReply := db.getAsHLL(key)
+	if errReply != nil {
+		return errReply
+	}
+	updated := 0
+	if hll == nil {
+		hll = hyperloglog.New()
+		updated = 1
+	}
+	for _, element := range args[1:] {
+		var result int
+		var err error
+		hll, result, err = hll.Add(element, config.Properties.HllSparseMaxBytes)
+		if err != nil {
+			return errCorruptedHLL
+		}
+		updated |= result
+	}
+	db.putString(key, hll)
+	if updated > 0 {
+		db.notifyKeyspaceEvent(notifyString, "pfadd", key)
+	}
+	return protocol.MakeIntReply(int64(updated))
+}
+
+// execPFCount returns the approximated cardinality of the HyperLogLog, or of the union of HyperLogLogs
+//
+//	PFCOUNT key [key ...]
+//
+// The cardinality of a single key is cached in the header of the value, so PFCOUNT locks its keys like a write
+// command, although ACL classes it as @read like redis does
+func execPFCount(db *DB, args [][]byte) redis.Reply {
+	if len(args) == 1 {
+		hll, errReply := db.getAsHLL(string(args[0]))
+		if errReply != nil {
+			return errReply
+		}
+		if hll == nil {
+			return protocol.MakeIntReply(0)
+		}
+		card, _, err := hll.Count()
+		if err != nil {
+			return errCorruptedHLL
+		}
+		return protocol.MakeIntReply(int64(card))
+	}
+
+	var registers [hyperloglog.Registers]uint8
+	for _, key := range args {
+		hll, errReply := db.getAsHLL(string(key))
+		if errReply != nil {
+			return errReply
+		}
+		if hll == nil {
+			continue
+		}
+		if err := hll.Merge(&registers); err != nil {
+			return errCorruptedHLL
+		}
+	}
+	return protocol.MakeIntReply(int64(hyperloglog.CountRegisters(&registers)))
+}
+
+// execPFMerge merges source HyperLogLogs into destkey, the existing destkey is merged as a source too.
+// The result is dense if any source is dense, otherwise it stays sparse as long as possible
+//
+//	PFMERGE destkey [sourcekey [sourcekey ...]]
+func execPFMerge(db *DB, args [][]byte) redis.Reply {
+	dest := string(args[0])
+	var registers [hyperloglog.Registers]uint8
+	dense := false
+	for _, key := range args {
+		hll, errReply := db.getAsHLL(string(key))
+		if errReply != nil {
+			return errReply
+		}
+		if hll == nil {
+			continue
+		}
+		dense = dense || hll.IsDense()
+		if err := hll.Merge(&registers); err != nil {
+			return errCorruptedHLL
+		}
+	}
+
+	hll, _ := db.getAsHLL(dest)
+	if hll == nil {
+		hll = hyperloglog.New()
+	}
+	hll, err := hll.SetRegisters(&registers, dense, config.Properties.HllSparseMaxBytes)
+	if err != nil {
+		return errCorruptedHLL
+	}
+	db.putString(dest, hll)
+	db.notifyKeyspaceEvent(notifyString, "pfadd", dest)
+	return protocol.MakeOKReply()
+}
+
+func init() {
+	registerCommand("PFAdd", execPFAdd, -2, flagWrite, acl.CategoryWrite|acl.CategoryHyperLogLog)
+	registerCommand("PFCount", execPFCount, -2, flagWrite, acl.CategoryRead|acl.CategoryHyperLogLog).setKeys(1, -1, 1).setAccessWritten()
+	registerCommand("PFMerge", execPFMerge, -2, flagWrite, acl.CategoryWrite|acl.CategoryHyperLogLog).setKeysFunc(writeFirstReadOthers).setAccessWritten()
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func TestPFCountIsRead(t *testing.T) {
+	server := makeTestServer(t)
+	admin := connect(server)
+	execCmd(server, admin, "pfadd", "h", "a", "b", "c")
+
+	// PFCOUNT writes the cached cardinality, but a user with read permissions only may run it
+	c := connectAs(t, server, "reader", "~*", "+@read")
+	assertReply(t, execCmd(server, c, "pfcount", "h"), protocol.MakeIntReply(3))
+	assertReply(t, execCmd(server, c, "pfcount", "h", "missing"), protocol.MakeIntReply(3))
+	assertErr(t, execCmd(server, c, "pfadd", "h", "d"), "NOPERM")
+}
+
+// The fixtures are the values redis stores for the same PFADD commands. Redis can't run in the test
+// environment, they are encoded by a port of hyperloglog.c of redis which shares no code with this package:
+// murmurhash64a with seed 0xadc83b19, 14 bits of index, sparse opcodes as sparse updates leave them
+// and 6 bits dense registers. The cached cardinality is invalidated by PFADD, i.e. the MSB of the last header byte.
+const sparseHLLFixture = "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80" +
+	"\x5c\x7b\x80\x44\x76\x80\x50\xb1\x84\x4b\xfb\x80\x42\x5a"
+
+func TestPFAddSparseFixture(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "pfadd", "h", "a", "b", "c", "d"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "get", "h"), protocol.MakeBulkReply([]byte(sparseHLLFixture)))
+
+	execCmd(server, c, "set", "fixture", sparseHLLFixture)
+	assertReply(t, execCmd(server, c, "pfcount", "fixture"), protocol.MakeIntReply(4))
+	// PFCOUNT caches the cardinality in little endian
+	cached := "HYLL\x01\x00\x00\x00\x04\x00\x00\x00\x00\x00\x00\x00" + sparseHLLFixture[16:]
+	assertReply(t, execCmd(server, c, "get", "fixture"), protocol.MakeBulkReply([]byte(cached)))
+	assertReply(t, execCmd(server, c, "pfadd", "fixture", "a"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "get", "fixture"), protocol.MakeBulkReply([]byte(cached)))
+}
+
+func TestPFAddDenseFixture(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/hll-dense.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := makeTestServer(t)
+	c := connect(server)
+	// the sparse encoding grows past hll-sparse-max-bytes, so it's converted into dense
+	args := []string{"pfadd", "h"}
+	for i := 0; i < 5000; i++ {
+		args = append(args, "elem-"+strconv.Itoa(i))
+	}
+	assertReply(t, execCmd(server, c, args...), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "get", "h"), protocol.MakeBulkReply(fixture))
+
+	execCmd(server, c, "set", "fixture", string(fixture))
+	assertReply(t, execCmd(server, c, "pfcount", "fixture"), protocol.MakeIntReply(5051))
+	assertReply(t, execCmd(server, c, "pfcount", "fixture", "h"), protocol.MakeIntReply(5051))
+}
+
+func TestPFCountAndMerge(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "pfcount", "none"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "pfadd", "empty"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "pfadd", "empty"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "pfcount", "empty"), protocol.MakeIntReply(0))
+
+	execCmd(server, c, "pfadd", "h1", "a", "b", "c")
+	execCmd(server, c, "pfadd", "h2", "c", "d")
+	assertReply(t, execCmd(server, c, "pfadd", "h2", "d"), protocol.MakeIntReply(0))
+	// the cardinality of several keys is the cardinality of their union
+	assertReply(t, execCmd(server, c, "pfcount", "h1", "h2"), protocol.MakeIntReply(4))
+	assertReply(t, execCmd(server, c, "pfcount", "h1", "none", "h1"), protocol.MakeIntReply(3))
+
+	// the destination is merged as a source as well
+	execCmd(server, c, "pfadd", "dst", "e")
+	assertReply(t, execCmd(server, c, "pfmerge", "dst", "h1", "h2", "none"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "pfcount", "dst"), protocol.MakeIntReply(5))
+	assertReply(t, execCmd(server, c, "pfmerge", "new"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "pfcount", "new"), protocol.MakeIntReply(0))
+	// merging sparse values gives the same bytes as adding all elements
+	execCmd(server, c, "pfadd", "all", "a", "b", "c", "d")
+	assertReply(t, execCmd(server, c, "pfmerge", "merged", "h1", "h2"), protocol.MakeOKReply())
+	merged := execCmd(server, c, "get", "merged").(*protocol.BulkReply).Arg
+	all := execCmd(server, c, "get", "all").(*protocol.BulkReply).Arg
+	if string(merged[16:]) != string(all[16:]) || string(all) != sparseHLLFixture {
+		t.Fatalf("merged registers are %q, expected %q", merged, all)
+	}
+}
+
+func TestHLLWrongType(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "set", "str", "not a hll")
+	execCmd(server, c, "set", "short", "HYLL")
+	// a dense value must have all registers
+	execCmd(server, c, "set", "truncated", "HYLL\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	execCmd(server, c, "set", "encoding", "HYLL\x02\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x7f\xff")
+	execCmd(server, c, "rpush", "list", "a")
+	execCmd(server, c, "pfadd", "h", "a")
+	for _, key := range []string{"str", "short", "truncated", "encoding"} {
+		assertErr(t, execCmd(server, c, "pfadd", key, "a"), "WRONGTYPE Key is not a valid HyperLogLog string value.")
+		assertErr(t, execCmd(server, c, "pfcount", key), "WRONGTYPE Key is not a valid HyperLogLog string value.")
+		assertErr(t, execCmd(server, c, "pfcount", "h", key), "WRONGTYPE Key is not a valid HyperLogLog string value.")
+		assertErr(t, execCmd(server, c, "pfmerge", "h", key), "WRONGTYPE Key is not a valid HyperLogLog string value.")
+		assertErr(t, execCmd(server, c, "pfmerge", key, "h"), "WRONGTYPE Key is not a valid HyperLogLog string value.")
+	}
+	assertErr(t, execCmd(server, c, "pfadd", "list", "a"), "WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	// sparse opcodes covering more registers than there are is a corrupted value instead
+	execCmd(server, c, "set", "corrupted", "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x7f\xff\x7f\xff")
+	assertErr(t, execCmd(server, c, "pfcount", "corrupted"), "INVALIDOBJ Corrupted HLL object detected")
+	assertErr(t, execCmd(server, c, "pfcount", "h", "corrupted"), "INVALIDOBJ Corrupted HLL object detected")
+	assertErr(t, execCmd(server, c, "pfmerge", "h", "corrupted"), "INVALIDOBJ Corrupted HLL object detected")
+	assertReply(t, execCmd(server, c, "pfcount", "h"), protocol.MakeIntReply(1))
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// serverStats holds counters of the server shown by INFO
+type serverStats struct {
+	startTime           time.Time
+	connectionsReceived atomic.Int64
+	// failed AUTH and HELLO AUTH, acl_access_denied_auth of redis
+	authFailures atomic.Int64
+	// commands rejected by ACL for commands, keys and channels they access
+	commandDenials atomic.Int64
+	keyDenials     atomic.Int64
+	channelDenials atomic.Int64
+}
+
+// infoSections are sections of INFO in order, with functions writing their fields
+var infoSections = []struct {
+	name   string
+	writer func(server *Server, b *strings.Builder)
+}{
+	{"server", writeServerInfo},
+	{"clients", writeClientsInfo},
+	{"stats", writeStatsInfo},
+	{"keyspace", writeKeyspaceInfo},
+}
+
+// execInfo returns information and statistics of the server
+//
+//	INFO [section [section ...]]
+func execInfo(server *Server, args [][]byte) redis.Reply {
+	selected := make(map[string]bool)
+	for _, arg := range args {
+		selected[strings.ToLower(string(arg))] = true
+	}
+	all := len(selected) == 0 || selected["default"] || selected["all"] || selected["everything"]
+	var b strings.Builder
+	for _, section := range infoSections {
+		if !all && !selected[section.name] {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString("# " + strings.ToUpper(section.name[:1]) + section.name[1:] + "\r\n")
+		section.writer(server, &b)
+	}
+	return protocol.MakeBulkReply([]byte(b.String()))
+}
+
+// execLocalInfo is INFO queued by a transaction, which runs within the selected db
+func execLocalInfo(db *DB, args [][]byte) redis.Reply {
+	return execInfo(db.server, args)
+}
+
+func writeInfoField(b *strings.Builder, name string, value string) {
+	b.WriteString(name + ":" + value + "\r\n")
+}
+
+func writeServerInfo(server *Server, b *strings.Builder) {
+	uptime := time.Since(server.stats.startTime)
+	writeInfoField(b, "redis_version", redisVersion)
+	writeInfoField(b, "redis_mode", "standalone")
+	writeInfoField(b, "executor_mode", config.Properties.ExecutorMode)
+	writeInfoField(b, "process_id", strconv.Itoa(os.Getpid()))
+	writeInfoField(b, "tcp_port", strconv.Itoa(config.Properties.Port))
+	writeInfoField(b, "uptime_in_seconds", strconv.FormatInt(int64(uptime/time.Second), 10))
+	writeInfoField(b, "uptime_in_days", strconv.FormatInt(int64(uptime/(24*time.Hour)), 10))
+}
+
+func writeClientsInfo(server *Server, b *strings.Builder) {
+	connected := 0
+	server.clients.Range(func(_, _ any) bool {
+		connected++
+		return true
+	})
+	blocked := 0
+	server.blockedClients.Range(func(_, _ any) bool {
+		blocked++
+		return true
+	})
+	writeInfoField(b, "connected_clients", strconv.Itoa(connected))
+	writeInfoField(b, "blocked_clients", strconv.Itoa(blocked))
+	writeInfoField(b, "tracking_clients", strconv.Itoa(int(server.tracking.enabled.Load())))
+}
+
+func writeStatsInfo(server *Server, b *strings.Builder) {
+	writeInfoField(b, "total_connections_received", strconv.FormatInt(server.stats.connectionsReceived.Load(), 10))
+	writeInfoField(b, "acl_access_denied_auth", strconv.FormatInt(server.stats.authFailures.Load(), 10))
+	writeInfoField(b, "acl_access_denied_cmd", strconv.FormatInt(server.stats.commandDenials.Load(), 10))
+	writeInfoField(b, "acl_access_denied_key", strconv.FormatInt(server.stats.keyDenials.Load(), 10))
+	writeInfoField(b, "acl_access_denied_channel", strconv.FormatInt(server.stats.channelDenials.Load(), 10))
+}
+
+func writeKeyspaceInfo(server *Server, b *strings.Builder) {
+	for _, db := range server.dbSet {
+		keys := db.data.Len()
+		if keys == 0 {
+			continue
+		}
+		writeInfoField(b, "db"+strconv.Itoa(db.index), "keys="+strconv.Itoa(keys)+",expires=0")
+	}
+}
+
+func init() {
+	// INFO is executed by Server.Exec, it's registered here to be queued by transactions
+	registerCommand("Info", execLocalInfo, -1, flagReadOnly, acl.CategoryDangerous).setKeysFunc(noKeys)
+}
@@ -0,0 +1,381 @@
+package database
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/acl"
+	Hash "github.com/tonge3199/redis_go/datastruct/hash"
+	List "github.com/tonge3199/redis_go/datastruct/list"
+	Set "github.com/tonge3199/redis_go/datastruct/set"
+	SortedSet "github.com/tonge3199/redis_go/datastruct/sortedset"
+	"github.com/tonge3199/redis_go/datastruct/stream"
+	"github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/wildcard"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// lookupEntity is GetEntity for keyspace commands,
+// a hash whose fields are all expired is treated as missing since it is waiting to be deleted
+func (db *DB) lookupEntity(key string) (*database.DataEntity, bool) {
+	entity, exists := db.GetEntity(key)
+	if !exists || isWaitingDeletion(entity) {
+		return nil, false
+	}
+	return entity, true
+}
+
+func isWaitingDeletion(entity *database.DataEntity) bool {
+	hash, ok := entity.Data.(*Hash.Hash)
+	return ok && hash.HasExpires() && hash.Len() == 0
+}
+
+// typeName returns the redis type name of data, HyperLogLogs are strings and geo indexes are sorted sets
+func typeName(data any) string {
+	switch data.(type) {
+	case []byte:
+		return "string"
+	case *List.QuickList:
+		return "list"
+	case *Hash.Hash:
+		return "hash"
+	case *Set.Set:
+		return "set"
+	case *SortedSet.SortedSet:
+		return "zset"
+	case *stream.Stream:
+		return "stream"
+	}
+	return "none"
+}
+
+// cloneData returns a deep copy of a value stored in db
+func cloneData(data any) any {
+	switch value := data.(type) {
+	case []byte:
+		return bytes.Clone(value)
+	case *List.QuickList:
+		return value.Clone()
+	case *Hash.Hash:
+		return value.Clone()
+	case *Set.Set:
+		return value.Clone()
+	case *SortedSet.SortedSet:
+		return value.Clone()
+	case *stream.Stream:
+		return value.Clone()
+	}
+	panic("unknown data type")
+}
+
+// putCopy stores a copy of entity at key, it also tracks field ttls of a copied hash
+func (db *DB) putCopy(key string, entity *database.DataEntity) {
+	data := cloneData(entity.Data)
+	db.PutEntity(key, &database.DataEntity{
+		Data: data,
+	})
+	if hash, ok := data.(*Hash.Hash); ok && hash.HasExpires() {
+		db.trackFieldTTL(key)
+	}
+}
+
+// execDel removes keys, returns the number of removed keys
+//
+//	DEL key [key ...]
+func execDel(db *DB, args [][]byte) redis.Reply {
+	deleted := 0
+	for _, arg := range args {
+		key := string(arg)
+		db.expireHashFields(key)
+		if db.deleteKey(key) {
+			deleted++
+		}
+	}
+	return protocol.MakeIntReply(int64(deleted))
+}
+
+// execExists returns the number of existing keys, a key mentioned several times is counted several times
+//
+//	EXISTS key [key ...]
+func execExists(db *DB, args [][]byte) redis.Reply {
+	count := int64(0)
+	for _, arg := range args {
+		if _, exists := db.lookupEntity(string(arg)); exists {
+			count++
+		}
+	}
+	return protocol.MakeIntReply(count)
+}
+
+// execType returns the type of value stored at key
+//
+//	TYPE key
+func execType(db *DB, args [][]byte) redis.Reply {
+	entity, exists := db.lookupEntity(string(args[0]))
+	if !exists {
+		return protocol.MakeStatusReply("none")
+	}
+	return protocol.MakeStatusReply(typeName(entity.Data))
+}
+
+// rename moves the value of src to dest, returns false if nx is set and dest exists
+func (db *DB) rename(src string, dest string, nx bool) (bool, protocol.ErrorReply) {
+	db.expireHashFields(src)
+	entity, exists := db.GetEntity(src)
+	if !exists {
+		return false, protocol.MakeErrReply("ERR no such key")
+	}
+	if src == dest {
+		return !nx, nil
+	}
+	db.expireHashFields(dest)
+	if _, exists := db.GetEntity(dest); exists {
+		if nx {
+			return false, nil
+		}
+		db.Remove(dest)
+	}
+	hasFieldTTL := db.hasFieldTTL(src)
+	db.Remove(src)
+	db.PutEntity(dest, entity)
+	if hasFieldTTL {
+		db.trackFieldTTL(dest)
+	}
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_from", src)
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_to", dest)
+	return true, nil
+}
+
+// execRename renames key to newkey, overwriting newkey if it exists
+//
+//	RENAME key newkey
+func execRename(db *DB, args [][]byte) redis.Reply {
+	if _, errReply := db.rename(string(args[0]), string(args[1]), false); errReply != nil {
+		return errReply
+	}
+	return protocol.MakeOKReply()
+}
+
+// execRenameNx renames key to newkey only if newkey doesn't exist
+//
+//	RENAMENX key newkey
+func execRenameNx(db *DB, args [][]byte) redis.Reply {
+	ok, errReply := db.rename(string(args[0]), string(args[1]), true)
+	if errReply != nil {
+		return errReply
+	}
+	if !ok {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(1)
+}
+
+// copyArgs is the parsed form of `source destination [DB destination-db] [REPLACE]`
+type copyArgs struct {
+	src     string
+	dest    string
+	destDB  int // -1 means the selected db
+	replace bool
+}
+
+func parseCopyArgs(args [][]byte) (*copyArgs, protocol.ErrorReply) {
+	result := &copyArgs{
+		src:    string(args[0]),
+		dest:   string(args[1]),
+		destDB: -1,
+	}
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "REPLACE":
+			result.replace = true
+		case "DB":
+			if i+1 >= len(args) {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			i++
+			dbIndex, err := strconv.Atoi(string(args[i]))
+			if err != nil {
+				return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			result.destDB = dbIndex
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	return result, nil
+}
+
+// copyKey copies the value of src in srcDB to dest in destDB, the caller must hold locks of both keys
+func copyKey(srcDB *DB, destDB *DB, args *copyArgs) redis.Reply {
+	if srcDB == destDB && args.src == args.dest {
+		return protocol.MakeErrReply("ERR source and destination objects are the same")
+	}
+	entity, exists := srcDB.lookupEntity(args.src)
+	if !exists {
+		return protocol.MakeIntReply(0)
+	}
+	destDB.expireHashFields(args.dest)
+	if _, exists := destDB.GetEntity(args.dest); exists {
+		if !args.replace {
+			return protocol.MakeIntReply(0)
+		}
+		destDB.Remove(args.dest)
+	}
+	destDB.putCopy(args.dest, entity)
+	destDB.notifyKeyspaceEvent(notifyGeneric, "copy_to", args.dest)
+	return protocol.MakeIntReply(1)
+}
+
+// execCopy copies the value of source to destination, possibly in another db.
+// It is executed by the server rather than a single db since it may touch two dbs
+//
+//	COPY source destination [DB destination-db] [REPLACE]
+func execCopy(server *Server, c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) < 2 {
+		return protocol.MakeArgNumErrReply("copy")
+	}
+	copyArgs, parseErr := parseCopyArgs(args)
+	if parseErr != nil {
+		return parseErr
+	}
+	srcDB, errReply := server.selectDB(c.GetDBIndex())
+	if errReply != nil {
+		return errReply
+	}
+	destDB := srcDB
+	if copyArgs.destDB >= 0 {
+		destDB, errReply = server.selectDB(copyArgs.destDB)
+		if errReply != nil {
+			return errReply
+		}
+	}
+
+	srcKeys := []string{copyArgs.src}
+	destKeys := []string{copyArgs.dest}
+	var unlock func()
+	if srcDB == destDB {
+		unlock = srcDB.lockKeys(destKeys, srcKeys)
+	} else {
+		// dbs are locked in the order of db index to avoid dead lock
+		var unlockSrc, unlockDest func()
+		if srcDB.index < destDB.index {
+			unlockSrc = srcDB.lockKeys(nil, srcKeys)
+			unlockDest = destDB.lockKeys(destKeys, nil)
+		} else {
+			unlockDest = destDB.lockKeys(destKeys, nil)
+			unlockSrc = srcDB.lockKeys(nil, srcKeys)
+		}
+		unlock = func() {
+			unlockDest()
+			unlockSrc()
+		}
+	}
+	result := func() redis.Reply {
+		defer unlock()
+		result := copyKey(srcDB, destDB, copyArgs)
+		destDB.signalWrittenKeys(c.ID(), copyArgs.dest)
+		return result
+	}()
+	destDB.serveReadyKeys()
+	return result
+}
+
+// execLocalCopy is COPY executed within a single db, which is used in transactions.
+// Copying to another db is not supported since the transaction only holds the lock of the selected db
+func execLocalCopy(db *DB, args [][]byte) redis.Reply {
+	copyArgs, errReply := parseCopyArgs(args)
+	if errReply != nil {
+		return errReply
+	}
+	if copyArgs.destDB >= 0 && copyArgs.destDB != db.index {
+		return protocol.MakeErrReply("ERR COPY to another db is not supported within a transaction")
+	}
+	return copyKey(db, db, copyArgs)
+}
+
+// execTouch returns the number of existing keys.
+// There is no LRU/LFU eviction yet, so touching a key doesn't change anything else
+//
+//	TOUCH key [key ...]
+func execTouch(db *DB, args [][]byte) redis.Reply {
+	return execExists(db, args)
+}
+
+// execKeys returns all keys matching the glob-style pattern
+//
+//	KEYS pattern
+func execKeys(db *DB, args [][]byte) redis.Reply {
+	pattern := string(args[0])
+	matchAll := pattern == "*"
+	var result [][]byte
+	db.data.ForEach(func(key string, val interface{}) bool {
+		if !matchAll && !wildcard.Match(pattern, key) {
+			return true
+		}
+		if entity, ok := val.(*database.DataEntity); ok && !isWaitingDeletion(entity) {
+			result = append(result, []byte(key))
+		}
+		return true
+	})
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// execRandomKey returns a random key, or nil if the db is empty
+//
+//	RANDOMKEY
+func execRandomKey(db *DB, args [][]byte) redis.Reply {
+	// a few tries to skip hashes waiting to be deleted
+	for i := 0; i < 10; i++ {
+		keys := db.data.RandomKeys(1)
+		if len(keys) == 0 {
+			break
+		}
+		if _, exists := db.lookupEntity(keys[0]); exists {
+			return protocol.MakeBulkReply([]byte(keys[0]))
+		}
+	}
+	return protocol.MakeNullBulkReply()
+}
+
+// parseFlushMode checks the optional flush mode, flushing is always synchronous
+// since dropping the dicts is cheap and memory is released by gc in background anyway
+func parseFlushMode(args [][]byte) protocol.ErrorReply {
+	if len(args) > 1 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	if len(args) == 1 {
+		mode := strings.ToUpper(string(args[0]))
+		if mode != "ASYNC" && mode != "SYNC" {
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+	return nil
+}
+
+// execFlushDB removes all keys of db
+//
+//	FLUSHDB [ASYNC | SYNC]
+func execFlushDB(db *DB, args [][]byte) redis.Reply {
+	if errReply := parseFlushMode(args); errReply != nil {
+		return errReply
+	}
+	db.flush()
+	db.signalFlushed()
+	return protocol.MakeOKReply()
+}
+
+func init() {
+	registerCommand("Del", execDel, -2, flagWrite, acl.CategoryWrite|acl.CategoryKeyspace).setKeys(1, -1, 1)
+	registerCommand("Unlink", execDel, -2, flagWrite, acl.CategoryWrite|acl.CategoryKeyspace).setKeys(1, -1, 1)
+	registerCommand("Exists", execExists, -2, flagReadOnly, acl.CategoryRead|acl.CategoryKeyspace).setKeys(1, -1, 1)
+	registerCommand("Type", execType, 2, flagReadOnly, acl.CategoryRead|acl.CategoryKeyspace)
+	registerCommand("Rename", execRename, 3, flagWrite, acl.CategoryWrite|acl.CategoryKeyspace).setKeys(1, 2, 1).setAccessKeys(1, 1, 1)
+	registerCommand("RenameNx", execRenameNx, 3, flagWrite, acl.CategoryWrite|acl.CategoryKeyspace).setKeys(1, 2, 1).setAccessKeys(1, 1, 1)
+	registerCommand("Copy", execLocalCopy, -3, flagWrite, acl.CategoryWrite|acl.CategoryKeyspace).setKeysFunc(readFirstWriteSecond)
+	registerCommand("Touch", execTouch, -2, flagReadOnly, acl.CategoryRead|acl.CategoryKeyspace).setKeys(1, -1, 1)
+	registerCommand("Keys", execKeys, 2, flagReadOnly, acl.CategoryRead|acl.CategoryKeyspace|acl.CategoryDangerous).setKeysFunc(noKeys)
+	registerCommand("RandomKey", execRandomKey, 1, flagReadOnly, acl.CategoryRead|acl.CategoryKeyspace).setKeysFunc(noKeys)
+	registerCommand("FlushDB", execFlushDB, -1, flagWrite, acl.CategoryWrite|acl.CategoryKeyspace|acl.CategoryDangerous).setKeysFunc(noKeys).setUndo(undoFlushDB)
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func TestRename(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertErr(t, execCmd(server, c, "rename", "none", "dst"), "ERR no such key")
+	assertErr(t, execCmd(server, c, "renamenx", "none", "dst"), "ERR no such key")
+	// renaming a missing key onto itself fails as well
+	assertErr(t, execCmd(server, c, "rename", "none", "none"), "ERR no such key")
+
+	execCmd(server, c, "set", "a", "1")
+	execCmd(server, c, "rpush", "b", "x")
+	assertReply(t, execCmd(server, c, "rename", "a", "a"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeBulkReply([]byte("1")))
+	assertReply(t, execCmd(server, c, "renamenx", "a", "a"), protocol.MakeIntReply(0))
+
+	// RENAME overwrites the destination whatever its type is, RENAMENX doesn't
+	assertReply(t, execCmd(server, c, "renamenx", "a", "b"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "rename", "a", "b"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "exists", "a"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "get", "b"), protocol.MakeBulkReply([]byte("1")))
+	assertReply(t, execCmd(server, c, "renamenx", "b", "c"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "exists", "b", "c"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "type", "c"), protocol.MakeStatusReply("string"))
+}
+
+func TestRenameKeepsFieldTTL(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "hset", "h", "f1", "v", "f2", "v")
+	execCmd(server, c, "hpexpireat", "h", "9999999999000", "fields", "1", "f1")
+	execCmd(server, c, "set", "dst", "v")
+	assertReply(t, execCmd(server, c, "rename", "h", "dst"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "hpexpiretime", "dst", "fields", "2", "f1", "f2"), ints(9999999999000, -1))
+	// the new key is sampled by active expiration instead of the old one
+	if !server.dbSet[0].hasFieldTTL("dst") || server.dbSet[0].hasFieldTTL("h") {
+		t.Fatal("field ttls are not tracked under the new name")
+	}
+	assertReply(t, execCmd(server, c, "renamenx", "dst", "h"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "hpexpiretime", "h", "fields", "1", "f1"), ints(9999999999000))
+	if !server.dbSet[0].hasFieldTTL("h") {
+		t.Fatal("field ttls are not tracked after RENAMENX")
+	}
+
+	// a hash whose fields are all expired is missing
+	execCmd(server, c, "hset", "expired", "f", "v")
+	execCmd(server, c, "hpexpireat", "expired", "1", "fields", "1", "f")
+	assertErr(t, execCmd(server, c, "rename", "expired", "other"), "ERR no such key")
+}
+
+func TestCopy(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "copy", "none", "dst"), protocol.MakeIntReply(0))
+	execCmd(server, c, "sadd", "src", "a", "b")
+	assertErr(t, execCmd(server, c, "copy", "src", "src"), "ERR source and destination objects are the same")
+	assertReply(t, execCmd(server, c, "copy", "src", "dst"), protocol.MakeIntReply(1))
+	// the copy is independent of the source
+	execCmd(server, c, "sadd", "dst", "c")
+	assertReply(t, execCmd(server, c, "scard", "src"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "scard", "dst"), protocol.MakeIntReply(3))
+
+	// an existing destination is only overwritten with REPLACE
+	execCmd(server, c, "set", "str", "v")
+	assertReply(t, execCmd(server, c, "copy", "src", "str"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "get", "str"), protocol.MakeBulkReply([]byte("v")))
+	assertReply(t, execCmd(server, c, "copy", "src", "str", "replace"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "type", "str"), protocol.MakeStatusReply("set"))
+	// the same key in the same db is rejected even with DB of the selected db
+	assertErr(t, execCmd(server, c, "copy", "src", "src", "db", "0"), "ERR source and destination objects are the same")
+
+	assertErr(t, execCmd(server, c, "copy", "src", "dst", "db"), "ERR syntax error")
+	assertErr(t, execCmd(server, c, "copy", "src", "dst", "db", "x"), "ERR value is not an integer or out of range")
+	assertErr(t, execCmd(server, c, "copy", "src", "dst", "db", "99999"), "ERR DB index is out of range")
+	assertErr(t, execCmd(server, c, "copy", "src", "dst", "force"), "ERR syntax error")
+}
+
+func TestCopyToAnotherDB(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	other := connect(server)
+	execCmd(server, other, "select", "1")
+	execCmd(server, c, "hset", "h", "f1", "v", "f2", "v")
+	execCmd(server, c, "hpexpireat", "h", "9999999999000", "fields", "1", "f1")
+
+	// the same key may be copied to another db, with its field ttls
+	assertReply(t, execCmd(server, c, "copy", "h", "h", "db", "1"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, other, "hlen", "h"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, other, "hpexpiretime", "h", "fields", "2", "f1", "f2"), ints(9999999999000, -1))
+	if !server.dbSet[1].hasFieldTTL("h") {
+		t.Fatal("field ttls of the copy are not tracked")
+	}
+	execCmd(server, other, "hdel", "h", "f2")
+	assertReply(t, execCmd(server, c, "hlen", "h"), protocol.MakeIntReply(2))
+
+	assertReply(t, execCmd(server, c, "copy", "h", "h", "db", "1"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "copy", "h", "h", "db", "1", "replace"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, other, "hlen", "h"), protocol.MakeIntReply(2))
+	// the destination db is given explicitly, not the selected db of the client
+	assertReply(t, execCmd(server, other, "copy", "h", "back", "db", "0"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "hpexpiretime", "back", "fields", "1", "f1"), ints(9999999999000))
+	assertReply(t, execCmd(server, other, "exists", "back"), protocol.MakeIntReply(0))
+}
+
+func TestRandomKeyTouchUnlink(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "randomkey"), protocol.MakeNullBulkReply())
+	execCmd(server, c, "set", "a", "1")
+	assertReply(t, execCmd(server, c, "randomkey"), protocol.MakeBulkReply([]byte("a")))
+	execCmd(server, c, "set", "b", "1")
+	for i := 0; i < 10; i++ {
+		key := string(execCmd(server, c, "randomkey").(*protocol.BulkReply).Arg)
+		if key != "a" && key != "b" {
+			t.Fatalf("random key is %s", key)
+		}
+	}
+	// a hash whose fields are all expired is never returned
+	execCmd(server, c, "del", "a", "b")
+	execCmd(server, c, "hset", "h", "f", "v")
+	execCmd(server, c, "hpexpireat", "h", "1", "fields", "1", "f")
+	assertReply(t, execCmd(server, c, "randomkey"), protocol.MakeNullBulkReply())
+
+	execCmd(server, c, "set", "a", "1")
+	execCmd(server, c, "rpush", "l", "x")
+	// TOUCH counts existing keys, a key given twice is counted twice
+	assertReply(t, execCmd(server, c, "touch", "a", "l", "none", "a"), protocol.MakeIntReply(3))
+	assertReply(t, execCmd(server, c, "touch", "h"), protocol.MakeIntReply(0))
+	// UNLINK counts removed keys only once
+	assertReply(t, execCmd(server, c, "unlink", "a", "l", "none", "a"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "exists", "a", "l"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "unlink", "h"), protocol.MakeIntReply(0))
+}
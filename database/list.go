@@ -0,0 +1,811 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/config"
+	List "github.com/tonge3199/redis_go/datastruct/list"
+	"github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/utils"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func (db *DB) getAsList(key string) (*List.QuickList, protocol.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	list, ok := entity.Data.(*List.QuickList)
+	if !ok {
+		return nil, protocol.MakeWrongTypeErrReply()
+	}
+	return list, nil
+}
+
+func (db *DB) getOrInitList(key string) (list *List.QuickList, isNew bool, errReply protocol.ErrorReply) {
+	list, errReply = db.getAsList(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	isNew = false
+	if list == nil {
+		list = List.NewQuickList(config.Properties.ListMaxListpackSize)
+		db.PutEntity(key, &database.DataEntity{
+			Data: list,
+		})
+		isNew = true
+	}
+	return list, isNew, nil
+}
+
+var (
+	errNotInteger   = protocol.MakeErrReply("ERR value is not an integer or out of range")
+	errNotPositive  = protocol.MakeErrReply("ERR value is out of range, must be positive")
+	errNoSuchKey    = protocol.MakeErrReply("ERR no such key")
+	errIndexOutList = protocol.MakeErrReply("ERR index out of range")
+)
+
+// parseDirection parses LEFT or RIGHT, returns whether the direction is left
+func parseDirection(arg []byte) (left bool, ok bool) {
+	switch strings.ToUpper(string(arg)) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+// popFromList pops at most count elements from the given side, and removes the key if list becomes empty
+func (db *DB) popFromList(key string, list *List.QuickList, left bool, count int) [][]byte {
+	event := "rpop"
+	if left {
+		event = "lpop"
+	}
+	if count > list.Len() {
+		count = list.Len()
+	}
+	result := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		if left {
+			result = append(result, list.PopFront())
+		} else {
+			result = append(result, list.PopBack())
+		}
+	}
+	if len(result) > 0 {
+		db.notifyKeyspaceEvent(notifyList, event, key)
+	}
+	if list.Len() == 0 {
+		db.deleteKey(key)
+	}
+	return result
+}
+
+func execPush(db *DB, args [][]byte, left bool) redis.Reply {
+	key := string(args[0])
+	values := args[1:]
+
+	// get or init entity
+	list, _, errReply := db.getOrInitList(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	// insert
+	for _, value := range values {
+		if left {
+			list.PushFront(value)
+		} else {
+			list.PushBack(value)
+		}
+	}
+	db.notifyKeyspaceEvent(notifyList, pushEvent(left), key)
+	return protocol.MakeIntReply(int64(list.Len()))
+}
+
+func pushEvent(left bool) string {
+	if left {
+		return "lpush"
+	}
+	return "rpush"
+}
+
+func execPushX(db *DB, args [][]byte, left bool) redis.Reply {
+	key := string(args[0])
+	values := args[1:]
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return protocol.MakeIntReply(0)
+	}
+
+	for _, value := range values {
+		if left {
+			list.PushFront(value)
+		} else {
+			list.PushBack(value)
+		}
+	}
+	db.notifyKeyspaceEvent(notifyList, pushEvent(left), key)
+	return protocol.MakeIntReply(int64(list.Len()))
+}
+
+// execLPush inserts element at head of list
+//
+//	LPUSH key element [element ...]
+func execLPush(db *DB, args [][]byte) redis.Reply {
+	return execPush(db, args, true)
+}
+
+// execRPush inserts element at last of list
+//
+//	RPUSH key element [element ...]
+func execRPush(db *DB, args [][]byte) redis.Reply {
+	return execPush(db, args, false)
+}
+
+// execLPushX inserts element at head of list, only if list exists
+//
+//	LPUSHX key element [element ...]
+func execLPushX(db *DB, args [][]byte) redis.Reply {
+	return execPushX(db, args, true)
+}
+
+// execRPushX inserts element at last of list, only if list exists
+//
+//	RPUSHX key element [element ...]
+func execRPushX(db *DB, args [][]byte) redis.Reply {
+	return execPushX(db, args, false)
+}
+
+func execPop(db *DB, args [][]byte, left bool) redis.Reply {
+	key := string(args[0])
+	withCount := len(args) == 2
+	count := 1
+	if withCount {
+		n, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return errNotPositive
+		}
+		if n < 0 {
+			return errNotPositive
+		}
+		count = int(n)
+	} else if len(args) > 2 {
+		if left {
+			return protocol.MakeArgNumErrReply("lpop")
+		}
+		return protocol.MakeArgNumErrReply("rpop")
+	}
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		if withCount {
+			return protocol.MakeNullMultiBulkReply()
+		}
+		return protocol.MakeNullBulkReply()
+	}
+
+	values := db.popFromList(key, list, left, count)
+	if !withCount {
+		return protocol.MakeBulkReply(values[0])
+	}
+	if len(values) == 0 {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	return protocol.MakeMultiBulkReply(values)
+}
+
+// execLPop removes the first elements of list
+//
+//	LPOP key [count]
+func execLPop(db *DB, args [][]byte) redis.Reply {
+	return execPop(db, args, true)
+}
+
+// execRPop removes the last elements of list
+//
+//	RPOP key [count]
+func execRPop(db *DB, args [][]byte) redis.Reply {
+	return execPop(db, args, false)
+}
+
+// execLRange gets elements of list in given range
+//
+//	LRANGE key start stop
+func execLRange(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	start64, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	stop64, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+
+	start, stop := utils.ConvertRange(start64, stop64, int64(list.Len()))
+	if start < 0 {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	return protocol.MakeMultiBulkReply(list.Range(start, stop))
+}
+
+// normalizeIndex converts a possibly negative index to index counted from head,
+// returns false if it's out of range
+func normalizeIndex(index int64, size int) (int, bool) {
+	if index < 0 {
+		index = int64(size) + index
+	}
+	if index < 0 || index >= int64(size) {
+		return 0, false
+	}
+	return int(index), true
+}
+
+// execLIndex gets element of list at given index
+//
+//	LINDEX key index
+func execLIndex(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	index64, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return protocol.MakeNullBulkReply()
+	}
+
+	index, ok := normalizeIndex(index64, list.Len())
+	if !ok {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeBulkReply(list.Get(index))
+}
+
+// execLSet puts element at given index of list
+//
+//	LSET key index element
+func execLSet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	index64, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	value := args[2]
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return errNoSuchKey
+	}
+
+	index, ok := normalizeIndex(index64, list.Len())
+	if !ok {
+		return errIndexOutList
+	}
+	list.Set(index, value)
+	db.notifyKeyspaceEvent(notifyList, "lset", key)
+	return protocol.MakeOKReply()
+}
+
+// execLInsert inserts element before or after the pivot
+//
+//	LINSERT key BEFORE|AFTER pivot element
+func execLInsert(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	var before bool
+	switch strings.ToUpper(string(args[1])) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		return protocol.MakeSyntaxErrReply()
+	}
+	pivot := args[2]
+	value := args[3]
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return protocol.MakeIntReply(0)
+	}
+
+	pivotIndex := -1
+	list.ForEach(func(i int, v []byte) bool {
+		if utils.BytesEquals(v, pivot) {
+			pivotIndex = i
+			return false
+		}
+		return true
+	})
+	if pivotIndex < 0 {
+		return protocol.MakeIntReply(-1)
+	}
+	if before {
+		list.Insert(pivotIndex, value)
+	} else {
+		list.Insert(pivotIndex+1, value)
+	}
+	db.notifyKeyspaceEvent(notifyList, "linsert", key)
+	return protocol.MakeIntReply(int64(list.Len()))
+}
+
+// execLRem removes element of list
+//
+//	LREM key count element
+//
+// count > 0: remove count elements from head to tail.
+// count < 0: remove -count elements from tail to head.
+// count = 0: remove all matched elements.
+func execLRem(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	count64, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	count := int(count64)
+	value := args[2]
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return protocol.MakeIntReply(0)
+	}
+
+	expected := func(a []byte) bool {
+		return utils.BytesEquals(a, value)
+	}
+	var removed int
+	if count >= 0 {
+		removed = list.RemoveByVal(expected, count)
+	} else {
+		removed = list.ReverseRemoveByVal(expected, -count)
+	}
+	if removed > 0 {
+		db.notifyKeyspaceEvent(notifyList, "lrem", key)
+	}
+	if list.Len() == 0 {
+		db.deleteKey(key)
+	}
+	return protocol.MakeIntReply(int64(removed))
+}
+
+// execLTrim removes elements out of the given range
+//
+//	LTRIM key start stop
+func execLTrim(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	start64, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	stop64, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return protocol.MakeOKReply()
+	}
+
+	start, stop := utils.ConvertRange(start64, stop64, int64(list.Len()))
+	db.notifyKeyspaceEvent(notifyList, "ltrim", key)
+	if start < 0 {
+		db.deleteKey(key)
+		return protocol.MakeOKReply()
+	}
+	list.Trim(start, stop)
+	return protocol.MakeOKReply()
+}
+
+// execLLen gets length of list
+//
+//	LLEN key
+func execLLen(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(int64(list.Len()))
+}
+
+// execLPos returns the index of matching elements
+//
+//	LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+func execLPos(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	value := args[1]
+	rank := int64(1)
+	count := int64(-1) // -1 means COUNT is absent
+	maxLen := int64(0)
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return protocol.MakeSyntaxErrReply()
+		}
+		n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "RANK":
+			if n == 0 {
+				return protocol.MakeErrReply("ERR RANK can't be zero: use 1 to start from the first match, " +
+					"2 from the second ... or use negative to start from the end of the list")
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return protocol.MakeErrReply("ERR COUNT can't be negative")
+			}
+			count = n
+		case "MAXLEN":
+			if n < 0 {
+				return protocol.MakeErrReply("ERR MAXLEN can't be negative")
+			}
+			maxLen = n
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+
+	list, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if list == nil {
+		if count >= 0 {
+			return protocol.MakeEmptyMultiBulkReply()
+		}
+		return protocol.MakeNullBulkReply()
+	}
+
+	// skip the first (|rank| - 1) matches, then collect matches until count is reached
+	skip := rank - 1
+	if rank < 0 {
+		skip = -rank - 1
+	}
+	want := count
+	if want < 0 {
+		want = 1
+	}
+	matches := make([]int, 0)
+	var scanned int64
+	consumer := func(i int, v []byte) bool {
+		if maxLen > 0 && scanned >= maxLen {
+			return false
+		}
+		scanned++
+		if !utils.BytesEquals(v, value) {
+			return true
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		matches = append(matches, i)
+		return want == 0 || int64(len(matches)) < want
+	}
+	if rank > 0 {
+		list.ForEach(consumer)
+	} else {
+		list.ReverseForEach(consumer)
+	}
+
+	if count < 0 {
+		if len(matches) == 0 {
+			return protocol.MakeNullBulkReply()
+		}
+		return protocol.MakeIntReply(int64(matches[0]))
+	}
+	replies := make([]redis.Reply, len(matches))
+	for i, index := range matches {
+		replies[i] = protocol.MakeIntReply(int64(index))
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// lmove moves an element from source to destination, returns nil if source doesn't exist
+func (db *DB) lmove(src, dest string, srcLeft, destLeft bool) ([]byte, protocol.ErrorReply) {
+	srcList, errReply := db.getAsList(src)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if srcList == nil {
+		return nil, nil
+	}
+	// check type of destination before popping, so that a failed command changes nothing
+	if _, errReply = db.getAsList(dest); errReply != nil {
+		return nil, errReply
+	}
+
+	val := db.popFromList(src, srcList, srcLeft, 1)[0]
+	destList, _, _ := db.getOrInitList(dest)
+	if destLeft {
+		destList.PushFront(val)
+	} else {
+		destList.PushBack(val)
+	}
+	db.notifyKeyspaceEvent(notifyList, pushEvent(destLeft), dest)
+	return val, nil
+}
+
+// execLMove atomically pops an element from source and pushes it to destination
+//
+//	LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+func execLMove(db *DB, args [][]byte) redis.Reply {
+	src := string(args[0])
+	dest := string(args[1])
+	srcLeft, ok := parseDirection(args[2])
+	if !ok {
+		return protocol.MakeSyntaxErrReply()
+	}
+	destLeft, ok := parseDirection(args[3])
+	if !ok {
+		return protocol.MakeSyntaxErrReply()
+	}
+
+	val, errReply := db.lmove(src, dest, srcLeft, destLeft)
+	if errReply != nil {
+		return errReply
+	}
+	if val == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeBulkReply(val)
+}
+
+// mpopArgs is the parsed form of `numkeys key [key ...] <where> [COUNT count]`
+type mpopArgs struct {
+	keys  []string
+	where string // upper-cased LEFT/RIGHT or MIN/MAX
+	count int
+}
+
+// parseMPopArgs parses args of *MPOP commands, validWhere checks the <where> argument
+func parseMPopArgs(args [][]byte, validWhere func(string) bool) (*mpopArgs, protocol.ErrorReply) {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, errNotInteger
+	}
+	if numKeys <= 0 {
+		return nil, protocol.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args))-2 {
+		return nil, protocol.MakeSyntaxErrReply()
+	}
+	result := &mpopArgs{
+		keys:  make([]string, numKeys),
+		count: 1,
+	}
+	for i := range result.keys {
+		result.keys[i] = string(args[i+1])
+	}
+	result.where = strings.ToUpper(string(args[numKeys+1]))
+	if !validWhere(result.where) {
+		return nil, protocol.MakeSyntaxErrReply()
+	}
+	rest := args[numKeys+2:]
+	if len(rest) == 0 {
+		return result, nil
+	}
+	if len(rest) != 2 || strings.ToUpper(string(rest[0])) != "COUNT" {
+		return nil, protocol.MakeSyntaxErrReply()
+	}
+	count, err := strconv.ParseInt(string(rest[1]), 10, 64)
+	if err != nil || count <= 0 {
+		return nil, protocol.MakeErrReply("ERR count should be greater than 0")
+	}
+	result.count = int(count)
+	return result, nil
+}
+
+func isListDirection(where string) bool {
+	return where == "LEFT" || where == "RIGHT"
+}
+
+// lmpop pops elements from the first non-empty list, returns nil if all lists are empty
+func (db *DB) lmpop(args *mpopArgs) redis.Reply {
+	for _, key := range args.keys {
+		list, errReply := db.getAsList(key)
+		if errReply != nil {
+			return errReply
+		}
+		if list == nil {
+			continue
+		}
+		values := db.popFromList(key, list, args.where == "LEFT", args.count)
+		return protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte(key)),
+			protocol.MakeMultiBulkReply(values),
+		})
+	}
+	return nil
+}
+
+// execLMPop pops elements from the first non-empty list
+//
+//	LMPOP numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func execLMPop(db *DB, args [][]byte) redis.Reply {
+	mpop, errReply := parseMPopArgs(args, isListDirection)
+	if errReply != nil {
+		return errReply
+	}
+	result := db.lmpop(mpop)
+	if result == nil {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	return result
+}
+
+// execBPop pops an element from the first non-empty list, blocks if all lists are empty
+func execBPop(db *DB, args [][]byte, left bool) redis.Reply {
+	keys := make([]string, len(args)-1)
+	for i := range keys {
+		keys[i] = string(args[i])
+	}
+	timeout, errReply := parseBlockTimeout(args[len(args)-1])
+	if errReply != nil {
+		return errReply
+	}
+
+	pop := func(key string) redis.Reply {
+		list, errReply := db.getAsList(key)
+		if errReply != nil {
+			return errReply
+		}
+		if list == nil {
+			return nil
+		}
+		val := db.popFromList(key, list, left, 1)[0]
+		return protocol.MakeMultiBulkReply([][]byte{[]byte(key), val})
+	}
+	for _, key := range keys {
+		if result := pop(key); result != nil {
+			return result
+		}
+	}
+	return makeWaiter(keys, timeout, protocol.MakeNullMultiBulkReply(), skipWrongType(pop))
+}
+
+// execBLPop is the blocking version of LPOP
+//
+//	BLPOP key [key ...] timeout
+func execBLPop(db *DB, args [][]byte) redis.Reply {
+	return execBPop(db, args, true)
+}
+
+// execBRPop is the blocking version of RPOP
+//
+//	BRPOP key [key ...] timeout
+func execBRPop(db *DB, args [][]byte) redis.Reply {
+	return execBPop(db, args, false)
+}
+
+// execBLMove is the blocking version of LMOVE
+//
+//	BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+func execBLMove(db *DB, args [][]byte) redis.Reply {
+	src := string(args[0])
+	dest := string(args[1])
+	srcLeft, ok := parseDirection(args[2])
+	if !ok {
+		return protocol.MakeSyntaxErrReply()
+	}
+	destLeft, ok := parseDirection(args[3])
+	if !ok {
+		return protocol.MakeSyntaxErrReply()
+	}
+	timeout, errReply := parseBlockTimeout(args[4])
+	if errReply != nil {
+		return errReply
+	}
+
+	move := func(string) redis.Reply {
+		val, errReply := db.lmove(src, dest, srcLeft, destLeft)
+		if errReply != nil {
+			return errReply
+		}
+		if val == nil {
+			return nil
+		}
+		return protocol.MakeBulkReply(val)
+	}
+	if result := move(src); result != nil {
+		return result
+	}
+	return makeWaiter([]string{src}, timeout, protocol.MakeNullBulkReply(), func(string) redis.Reply {
+		// a source of another type is not ready, but a destination of another type still fails the command
+		if _, errReply := db.getAsList(src); errReply != nil {
+			return nil
+		}
+		return move(src)
+	})
+}
+
+// execBLMPop is the blocking version of LMPOP
+//
+//	BLMPOP timeout numkeys key [key ...] LEFT|RIGHT [COUNT count]
+func execBLMPop(db *DB, args [][]byte) redis.Reply {
+	timeout, errReply := parseBlockTimeout(args[0])
+	if errReply != nil {
+		return errReply
+	}
+	mpop, errReply := parseMPopArgs(args[1:], isListDirection)
+	if errReply != nil {
+		return errReply
+	}
+	if result := db.lmpop(mpop); result != nil {
+		return result
+	}
+	return makeWaiter(mpop.keys, timeout, protocol.MakeNullMultiBulkReply(), skipWrongType(func(key string) redis.Reply {
+		// only pop from the ready key, the keys before it are still empty
+		return db.lmpop(&mpopArgs{
+			keys:  []string{key},
+			where: mpop.where,
+			count: mpop.count,
+		})
+	}))
+}
+
+func init() {
+	registerCommand("LPush", execLPush, -3, flagWrite, acl.CategoryWrite|acl.CategoryList)
+	registerCommand("RPush", execRPush, -3, flagWrite, acl.CategoryWrite|acl.CategoryList)
+	registerCommand("LPushX", execLPushX, -3, flagWrite, acl.CategoryWrite|acl.CategoryList)
+	registerCommand("RPushX", execRPushX, -3, flagWrite, acl.CategoryWrite|acl.CategoryList)
+	registerCommand("LPop", execLPop, -2, flagWrite, acl.CategoryWrite|acl.CategoryList).setAccessWritten()
+	registerCommand("RPop", execRPop, -2, flagWrite, acl.CategoryWrite|acl.CategoryList).setAccessWritten()
+	registerCommand("LRange", execLRange, 4, flagReadOnly, acl.CategoryRead|acl.CategoryList)
+	registerCommand("LIndex", execLIndex, 3, flagReadOnly, acl.CategoryRead|acl.CategoryList)
+	registerCommand("LSet", execLSet, 4, flagWrite, acl.CategoryWrite|acl.CategoryList)
+	registerCommand("LInsert", execLInsert, 5, flagWrite, acl.CategoryWrite|acl.CategoryList)
+	registerCommand("LRem", execLRem, 4, flagWrite, acl.CategoryWrite|acl.CategoryList)
+	registerCommand("LTrim", execLTrim, 4, flagWrite, acl.CategoryWrite|acl.CategoryList)
+	registerCommand("LLen", execLLen, 2, flagReadOnly, acl.CategoryRead|acl.CategoryList)
+	registerCommand("LPos", execLPos, -3, flagReadOnly, acl.CategoryRead|acl.CategoryList)
+	registerCommand("LMove", execLMove, 5, flagWrite, acl.CategoryWrite|acl.CategoryList).setKeys(1, 2, 1).setAccessKeys(1, 1, 1)
+	registerCommand("LMPop", execLMPop, -4, flagWrite, acl.CategoryWrite|acl.CategoryList).setKeysFunc(numKeysFunc(0, false, true)).setAccessWritten()
+	registerCommand("BLPop", execBLPop, -3, flagWrite, acl.CategoryWrite|acl.CategoryList|acl.CategoryBlocking).setKeys(1, -2, 1).setAccessWritten()
+	registerCommand("BRPop", execBRPop, -3, flagWrite, acl.CategoryWrite|acl.CategoryList|acl.CategoryBlocking).setKeys(1, -2, 1).setAccessWritten()
+	registerCommand("BLMove", execBLMove, 6, flagWrite, acl.CategoryWrite|acl.CategoryList|acl.CategoryBlocking).setKeys(1, 2, 1).setAccessKeys(1, 1, 1)
+	registerCommand("BLMPop", execBLMPop, -5, flagWrite, acl.CategoryWrite|acl.CategoryList|acl.CategoryBlocking).setKeysFunc(numKeysFunc(1, false, true)).setAccessWritten()
+}
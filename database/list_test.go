@@ -0,0 +1,186 @@
+package database
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func TestMPopNumKeysOverflow(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	maxInt := strconv.FormatInt(math.MaxInt64, 10)
+	for _, cmdLine := range [][]string{
+		{"lmpop", maxInt, "a", "left"},
+		{"blmpop", "0", maxInt, "a", "left"},
+		{"zmpop", maxInt, "a", "min"},
+		{"bzmpop", "0", maxInt, "a", "min"},
+	} {
+		assertErr(t, execCmd(server, c, cmdLine...), "ERR syntax error")
+	}
+}
+
+func TestLPos(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "rpush", "list", "a", "b", "c", "1", "2", "3", "c", "c")
+	assertReply(t, execCmd(server, c, "lpos", "list", "c"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "lpos", "list", "x"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "lpos", "list", "c", "rank", "2"), protocol.MakeIntReply(6))
+	assertReply(t, execCmd(server, c, "lpos", "list", "c", "rank", "-1"), protocol.MakeIntReply(7))
+	assertReply(t, execCmd(server, c, "lpos", "list", "c", "rank", "-3"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "lpos", "list", "c", "rank", "4"), protocol.MakeNullBulkReply())
+
+	// COUNT 0 returns all matches
+	assertReply(t, execCmd(server, c, "lpos", "list", "c", "count", "2"), ints(2, 6))
+	assertReply(t, execCmd(server, c, "lpos", "list", "c", "count", "0"), ints(2, 6, 7))
+	assertReply(t, execCmd(server, c, "lpos", "list", "c", "rank", "-1", "count", "2"), ints(7, 6))
+	assertReply(t, execCmd(server, c, "lpos", "list", "c", "rank", "2", "count", "0"), ints(6, 7))
+	assertReply(t, execCmd(server, c, "lpos", "list", "x", "count", "1"), protocol.MakeEmptyMultiBulkReply())
+	assertReply(t, execCmd(server, c, "lpos", "nolist", "c", "count", "1"), protocol.MakeEmptyMultiBulkReply())
+
+	// MAXLEN limits the number of compared elements, from the head or from the tail
+	assertReply(t, execCmd(server, c, "lpos", "list", "c", "count", "0", "maxlen", "3"), ints(2))
+	assertReply(t, execCmd(server, c, "lpos", "list", "c", "count", "0", "maxlen", "2"), protocol.MakeEmptyMultiBulkReply())
+	assertReply(t, execCmd(server, c, "lpos", "list", "a", "rank", "-1", "maxlen", "7"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "lpos", "list", "c", "maxlen", "0", "count", "0"), ints(2, 6, 7))
+
+	assertErr(t, execCmd(server, c, "lpos", "list", "c", "rank", "0"), "ERR RANK can't be zero")
+	assertErr(t, execCmd(server, c, "lpos", "list", "c", "count", "-1"), "ERR COUNT can't be negative")
+	assertErr(t, execCmd(server, c, "lpos", "list", "c", "maxlen", "-1"), "ERR MAXLEN can't be negative")
+	assertErr(t, execCmd(server, c, "lpos", "list", "c", "rank"), "ERR syntax error")
+}
+
+func TestLInsertAndLRem(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "linsert", "list", "before", "a", "x"), protocol.MakeIntReply(0))
+	execCmd(server, c, "rpush", "list", "a", "b", "a", "c", "a")
+	assertReply(t, execCmd(server, c, "linsert", "list", "before", "a", "x"), protocol.MakeIntReply(6))
+	assertReply(t, execCmd(server, c, "linsert", "list", "after", "c", "y"), protocol.MakeIntReply(7))
+	assertReply(t, execCmd(server, c, "linsert", "list", "after", "none", "y"), protocol.MakeIntReply(-1))
+	assertErr(t, execCmd(server, c, "linsert", "list", "middle", "a", "y"), "ERR syntax error")
+	assertReply(t, execCmd(server, c, "lrange", "list", "0", "-1"), bulks("x", "a", "b", "a", "c", "y", "a"))
+
+	// a negative count removes from the tail
+	assertReply(t, execCmd(server, c, "lrem", "list", "-2", "a"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "lrange", "list", "0", "-1"), bulks("x", "a", "b", "c", "y"))
+	execCmd(server, c, "rpush", "list", "a", "a")
+	assertReply(t, execCmd(server, c, "lrem", "list", "1", "a"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "lrange", "list", "0", "-1"), bulks("x", "b", "c", "y", "a", "a"))
+	assertReply(t, execCmd(server, c, "lrem", "list", "0", "a"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "lrem", "list", "0", "none"), protocol.MakeIntReply(0))
+	// removing all elements deletes the key
+	for _, val := range []string{"x", "b", "c", "y"} {
+		execCmd(server, c, "lrem", "list", "0", val)
+	}
+	assertReply(t, execCmd(server, c, "exists", "list"), protocol.MakeIntReply(0))
+	assertErr(t, execCmd(server, c, "lrem", "list", "x", "a"), "ERR value is not an integer")
+}
+
+func TestLTrim(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "rpush", "list", "a", "b", "c", "d", "e")
+	assertReply(t, execCmd(server, c, "ltrim", "list", "1", "-2"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "lrange", "list", "0", "-1"), bulks("b", "c", "d"))
+	assertReply(t, execCmd(server, c, "ltrim", "list", "-100", "100"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "lrange", "list", "0", "-1"), bulks("b", "c", "d"))
+	// an empty range deletes the key
+	assertReply(t, execCmd(server, c, "ltrim", "list", "2", "1"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "exists", "list"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "ltrim", "nolist", "0", "1"), protocol.MakeOKReply())
+}
+
+func TestLPopCount(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "rpush", "list", "a", "b", "c", "d", "e")
+	assertReply(t, execCmd(server, c, "lpop", "list"), protocol.MakeBulkReply([]byte("a")))
+	assertReply(t, execCmd(server, c, "lpop", "list", "2"), bulks("b", "c"))
+	assertReply(t, execCmd(server, c, "rpop", "list", "1"), bulks("e"))
+	assertReply(t, execCmd(server, c, "lpop", "list", "0"), protocol.MakeEmptyMultiBulkReply())
+	// a count beyond the length pops all elements and deletes the key
+	assertReply(t, execCmd(server, c, "rpop", "list", "10"), bulks("d"))
+	assertReply(t, execCmd(server, c, "exists", "list"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "lpop", "list"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "lpop", "list", "2"), protocol.MakeNullMultiBulkReply())
+	assertErr(t, execCmd(server, c, "lpop", "list", "-1"), "ERR value is out of range, must be positive")
+}
+
+func TestLMove(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "rpush", "src", "a", "b", "c")
+	assertReply(t, execCmd(server, c, "lmove", "src", "dest", "left", "right"), protocol.MakeBulkReply([]byte("a")))
+	assertReply(t, execCmd(server, c, "lmove", "src", "dest", "right", "left"), protocol.MakeBulkReply([]byte("c")))
+	assertReply(t, execCmd(server, c, "lrange", "dest", "0", "-1"), bulks("c", "a"))
+	// source and destination may be the same list, which rotates it
+	execCmd(server, c, "rpush", "src", "x", "y")
+	assertReply(t, execCmd(server, c, "lmove", "src", "src", "left", "right"), protocol.MakeBulkReply([]byte("b")))
+	assertReply(t, execCmd(server, c, "lrange", "src", "0", "-1"), bulks("x", "y", "b"))
+	assertReply(t, execCmd(server, c, "lmove", "nolist", "dest", "left", "left"), protocol.MakeNullBulkReply())
+	assertErr(t, execCmd(server, c, "lmove", "src", "dest", "up", "left"), "ERR syntax error")
+
+	// a destination of another type fails the command without popping
+	execCmd(server, c, "set", "str", "v")
+	assertReply(t, execCmd(server, c, "lmove", "src", "str", "left", "left"), protocol.MakeWrongTypeErrReply())
+	assertReply(t, execCmd(server, c, "llen", "src"), protocol.MakeIntReply(3))
+
+	// the last element moved deletes the source
+	execCmd(server, c, "rpush", "one", "v")
+	execCmd(server, c, "lmove", "one", "dest", "left", "left")
+	assertReply(t, execCmd(server, c, "exists", "one"), protocol.MakeIntReply(0))
+}
+
+func TestBLMove(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "rpush", "src", "a")
+	assertReply(t, execCmd(server, c, "blmove", "src", "dest", "left", "left", "0"), protocol.MakeBulkReply([]byte("a")))
+
+	// a blocked client moves the pushed element as soon as it's pushed
+	w := block(t, server, connect(server), "blmove", "src", "dest", "right", "left", "0")
+	execCmd(server, c, "rpush", "src", "b", "c")
+	assertReply(t, servedReply(t, w), protocol.MakeBulkReply([]byte("c")))
+	assertReply(t, execCmd(server, c, "lrange", "src", "0", "-1"), bulks("b"))
+	assertReply(t, execCmd(server, c, "lrange", "dest", "0", "-1"), bulks("c", "a"))
+
+	// within a transaction it returns the timeout reply at once
+	execCmd(server, c, "multi")
+	execCmd(server, c, "blmove", "empty", "dest", "left", "left", "0")
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeMultiRawReply([]redis.Reply{protocol.MakeNullBulkReply()}))
+}
+
+func TestListPages(t *testing.T) {
+	size := config.Properties.ListMaxListpackSize
+	config.Properties.ListMaxListpackSize = 4
+	server := makeTestServer(t)
+	config.Properties.ListMaxListpackSize = size
+	c := connect(server)
+
+	// LINSERT into full pages splits them, LREM merges them, the elements are kept in order
+	var expected []string
+	args := []string{"rpush", "list"}
+	for i := 0; i < 16; i++ {
+		args = append(args, strconv.Itoa(i))
+		expected = append(expected, strconv.Itoa(i))
+	}
+	execCmd(server, c, args...)
+	for i := 0; i < 16; i += 3 {
+		execCmd(server, c, "linsert", "list", "before", strconv.Itoa(i), "x")
+		index := slices.Index(expected, strconv.Itoa(i))
+		expected = slices.Insert(expected, index, "x")
+	}
+	assertReply(t, execCmd(server, c, "lrange", "list", "0", "-1"), bulks(expected...))
+	assertReply(t, execCmd(server, c, "lindex", "list", "-1"), protocol.MakeBulkReply([]byte("15")))
+	assertReply(t, execCmd(server, c, "lrem", "list", "0", "x"), protocol.MakeIntReply(6))
+	assertReply(t, execCmd(server, c, "lrange", "list", "0", "-1"), bulks(args[2:]...))
+	execCmd(server, c, "lset", "list", "5", "five")
+	assertReply(t, execCmd(server, c, "lrange", "list", "4", "6"), bulks("4", "five", "6"))
+}
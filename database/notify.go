@@ -0,0 +1,143 @@
+package database
+
+import (
+	"strconv"
+
+	"github.com/tonge3199/redis_go/pubsub"
+)
+
+// Keyspace notifications publish changes of keys through pub/sub, the same as redis:
+//
+//   - __keyspace@<db>__:<key> receives the name of the event, e.g. "del", if K is enabled
+//   - __keyevent@<db>__:<event> receives the name of the key, if E is enabled
+//
+// Keys have no ttl and there is no maxmemory eviction yet, so classes x and e are accepted but never fire,
+// expired hash fields are notified as "hexpired" of class h like redis.
+//
+// Events are published when they happen within the command, except within a transaction in rollback mode
+// (see CLIENT TX-ROLLBACK): its events are published when it commits and dropped if it's rolled back, see txEffects.
+
+// classes of keyspace events, see parseKeyspaceEvents
+const (
+	notifyKeyspace = 1 << iota // K
+	notifyKeyevent             // E
+	notifyGeneric              // g: DEL, RENAME, COPY ...
+	notifyString               // $
+	notifyList                 // l
+	notifySet                  // s
+	notifyHash                 // h
+	notifyZSet                 // z
+	notifyExpired              // x: keys expired
+	notifyEvicted              // e: keys evicted for maxmemory
+	notifyStream               // t
+	notifyKeyMiss              // m: keys missed by readonly commands
+	notifyNew                  // n: keys created
+
+	// notifyAll is the alias "A", it excludes m and n like redis
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet | notifyHash |
+		notifyZSet | notifyExpired | notifyEvicted | notifyStream
+)
+
+// parseKeyspaceEvents parses the flags of notify-keyspace-events, like "KEA" or "Elg"
+func parseKeyspaceEvents(flags string) (int, bool) {
+	classes := 0
+	for _, flag := range flags {
+		switch flag {
+		case 'A':
+			classes |= notifyAll
+		case 'g':
+			classes |= notifyGeneric
+		case '$':
+			classes |= notifyString
+		case 'l':
+			classes |= notifyList
+		case 's':
+			classes |= notifySet
+		case 'h':
+			classes |= notifyHash
+		case 'z':
+			classes |= notifyZSet
+		case 'x':
+			classes |= notifyExpired
+		case 'e':
+			classes |= notifyEvicted
+		case 'K':
+			classes |= notifyKeyspace
+		case 'E':
+			classes |= notifyKeyevent
+		case 't':
+			classes |= notifyStream
+		case 'm':
+			classes |= notifyKeyMiss
+		case 'n':
+			classes |= notifyNew
+		default:
+			return 0, false
+		}
+	}
+	return classes, true
+}
+
+// keyspaceNotifier publishes keyspace events of all dbs of a server
+type keyspaceNotifier struct {
+	hub     *pubsub.Hub
+	classes int
+}
+
+// makeKeyspaceNotifier returns nil if no event is going to be delivered
+func makeKeyspaceNotifier(hub *pubsub.Hub, classes int) *keyspaceNotifier {
+	if classes&(notifyKeyspace|notifyKeyevent) == 0 || classes&^(notifyKeyspace|notifyKeyevent) == 0 {
+		return nil
+	}
+	return &keyspaceNotifier{
+		hub:     hub,
+		classes: classes,
+	}
+}
+
+// notifyKeyspaceEvent publishes event of key if the class of event is enabled.
+// Any event but keymiss means key is modified, so it also marks the key for signalWrittenKeys
+func (db *DB) notifyKeyspaceEvent(class int, event string, key string) {
+	if class != notifyKeyMiss {
+		if effects := db.markModified(key); effects != nil {
+			if db.notifyEnabled(class) {
+				effects.events = append(effects.events, keyspaceEvent{class: class, event: event, key: key})
+			}
+			return
+		}
+	}
+	db.publishKeyspaceEvent(class, event, key)
+}
+
+// publishKeyspaceEvent publishes event of key if the class of event is enabled
+func (db *DB) publishKeyspaceEvent(class int, event string, key string) {
+	notifier := db.notifier
+	if notifier == nil || notifier.classes&class == 0 {
+		return
+	}
+	prefix := "@" + strconv.Itoa(db.index) + "__:"
+	if notifier.classes&notifyKeyspace > 0 {
+		notifier.hub.PublishMessage("__keyspace"+prefix+key, []byte(event))
+	}
+	if notifier.classes&notifyKeyevent > 0 {
+		notifier.hub.PublishMessage("__keyevent"+prefix+event, []byte(key))
+	}
+}
+
+// notifyEnabled returns whether events of class are delivered, so callers may skip preparing events
+func (db *DB) notifyEnabled(class int) bool {
+	return db.notifier != nil && db.notifier.classes&class > 0
+}
+
+// notifyKeyMiss publishes "keymiss" for read keys missing after a readonly command,
+// EXISTS just tests keys so it doesn't count as a miss, the same as redis
+func (db *DB) notifyKeyMiss(cmd *command, readKeys []string) {
+	if !db.notifyEnabled(notifyKeyMiss) || cmd.name == "exists" {
+		return
+	}
+	for _, key := range readKeys {
+		if _, exists := db.lookupEntity(key); !exists {
+			db.notifyKeyspaceEvent(notifyKeyMiss, "keymiss", key)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/utils"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// makeNotifyingServer returns a server publishing keyspace events selected by flags, like notify-keyspace-events
+func makeNotifyingServer(t *testing.T, flags string) *Server {
+	t.Helper()
+	events := config.Properties.NotifyKeyspaceEvents
+	config.Properties.NotifyKeyspaceEvents = flags
+	server := makeTestServer(t)
+	config.Properties.NotifyKeyspaceEvents = events
+	return server
+}
+
+// subscribe returns a client subscribed to channel, whose messages are read from the returned pipeClient
+func subscribe(t *testing.T, server *Server, channel string) *pipeClient {
+	t.Helper()
+	c, out := connectPipe(t, server)
+	execCmd(server, c, "subscribe", channel)
+	out.expect(t, protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("subscribe")),
+		protocol.MakeBulkReply([]byte(channel)),
+		protocol.MakeIntReply(1),
+	}))
+	return out
+}
+
+func makeMessage(channel string, message string) redis.Reply {
+	return protocol.MakeMultiBulkReply(utils.ToCmdLine("message", channel, message))
+}
+
+func TestParseKeyspaceEvents(t *testing.T) {
+	classes, ok := parseKeyspaceEvents("KEA")
+	if !ok || classes != notifyKeyspace|notifyKeyevent|notifyAll {
+		t.Fatalf("KEA is parsed as %b", classes)
+	}
+	if classes&(notifyKeyMiss|notifyNew) != 0 {
+		t.Fatal("A should not include m and n")
+	}
+	if _, ok := parseKeyspaceEvents("Kq"); ok {
+		t.Fatal("an unknown flag should be rejected")
+	}
+	// events are not delivered without K or E, or without any class
+	for _, flags := range []string{"", "A", "KE"} {
+		classes, _ := parseKeyspaceEvents(flags)
+		if makeKeyspaceNotifier(nil, classes) != nil {
+			t.Fatalf("%q should not deliver any event", flags)
+		}
+	}
+}
+
+func TestKeyspaceEvents(t *testing.T) {
+	server := makeNotifyingServer(t, "KEA")
+	c := connect(server)
+	keyspace := subscribe(t, server, "__keyspace@0__:k")
+	keyevent := subscribe(t, server, "__keyevent@0__:del")
+
+	execCmd(server, c, "set", "k", "v")
+	keyspace.expect(t, makeMessage("__keyspace@0__:k", "set"))
+	execCmd(server, c, "del", "k", "other")
+	keyspace.expect(t, makeMessage("__keyspace@0__:k", "del"))
+	keyevent.expect(t, makeMessage("__keyevent@0__:del", "k"))
+	// nothing is modified, so nothing is published
+	execCmd(server, c, "del", "k")
+	execCmd(server, c, "get", "k")
+	keyspace.expectNothing(t)
+	keyevent.expectNothing(t)
+
+	// the channel includes the index of the db
+	execCmd(server, c, "select", "1")
+	execCmd(server, c, "set", "k", "v")
+	keyspace.expectNothing(t)
+}
+
+func TestKeyspaceEventClasses(t *testing.T) {
+	// only keyevent channels of lists and generic commands
+	server := makeNotifyingServer(t, "Elg")
+	c := connect(server)
+	keyspace := subscribe(t, server, "__keyspace@0__:list")
+	events := subscribe(t, server, "__keyevent@0__:rpush")
+	sets := subscribe(t, server, "__keyevent@0__:sadd")
+	renames := subscribe(t, server, "__keyevent@0__:rename_to")
+
+	execCmd(server, c, "rpush", "list", "a")
+	events.expect(t, makeMessage("__keyevent@0__:rpush", "list"))
+	execCmd(server, c, "sadd", "set", "a")
+	execCmd(server, c, "rename", "list", "list2")
+	renames.expect(t, makeMessage("__keyevent@0__:rename_to", "list2"))
+	keyspace.expectNothing(t)
+	sets.expectNothing(t)
+}
+
+func TestKeyMissAndNewEvents(t *testing.T) {
+	// m and n are not included in A
+	server := makeNotifyingServer(t, "KA")
+	c := connect(server)
+	sub := subscribe(t, server, "__keyspace@0__:k")
+	execCmd(server, c, "get", "k")
+	execCmd(server, c, "set", "k", "v")
+	sub.expect(t, makeMessage("__keyspace@0__:k", "set"))
+	sub.expectNothing(t)
+
+	server = makeNotifyingServer(t, "Kmn")
+	c = connect(server)
+	sub = subscribe(t, server, "__keyspace@0__:k")
+	execCmd(server, c, "get", "k")
+	sub.expect(t, makeMessage("__keyspace@0__:k", "keymiss"))
+	// EXISTS only tests the key, it's not a miss
+	execCmd(server, c, "exists", "k")
+	execCmd(server, c, "set", "k", "v")
+	sub.expect(t, makeMessage("__keyspace@0__:k", "new"))
+	// modifying an existing key doesn't create it
+	execCmd(server, c, "set", "k", "v2")
+	execCmd(server, c, "get", "k")
+	sub.expectNothing(t)
+}
+
+func TestHashFieldExpiredEvent(t *testing.T) {
+	server := makeNotifyingServer(t, "Eh")
+	c := connect(server)
+	sub := subscribe(t, server, "__keyevent@0__:hexpired")
+	execCmd(server, c, "hset", "h", "a", "1", "b", "2")
+	execCmd(server, c, "hpexpire", "h", "10", "fields", "1", "a")
+	// the active expiry deletes the field without any command touching the hash
+	sub.expect(t, makeMessage("__keyevent@0__:hexpired", "h"))
+	assertReply(t, execCmd(server, c, "hkeys", "h"), bulks("b"))
+}
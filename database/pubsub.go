@@ -0,0 +1,32 @@
+package database
+
+import (
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/interface/redis"
+)
+
+// PUBLISH, SPUBLISH and PUBSUB are executed by Server.Exec, since they don't belong to any db.
+// They are registered as commands of db too, so that transactions queue them like redis does.
+// Channels are not keys, a transaction queuing them locks the whole selected db like KEYS does.
+// Messages are sent at once, a transaction in rollback mode can't take them back once they are published
+
+// execLocalPublish is PUBLISH queued by a transaction
+func execLocalPublish(db *DB, args [][]byte) redis.Reply {
+	return db.server.hub.Publish(args)
+}
+
+// execLocalSPublish is SPUBLISH queued by a transaction
+func execLocalSPublish(db *DB, args [][]byte) redis.Reply {
+	return db.server.hub.SPublish(args)
+}
+
+// execLocalPubSub is PUBSUB queued by a transaction
+func execLocalPubSub(db *DB, args [][]byte) redis.Reply {
+	return db.server.hub.PubSub(args)
+}
+
+func init() {
+	registerCommand("Publish", execLocalPublish, 3, flagReadOnly, acl.CategoryPubSub).setKeysFunc(noKeys)
+	registerCommand("SPublish", execLocalSPublish, 3, flagReadOnly, acl.CategoryPubSub).setKeysFunc(noKeys)
+	registerCommand("PubSub", execLocalPubSub, -2, flagReadOnly, acl.CategoryPubSub).setKeysFunc(noKeys)
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func TestSubscribeMode(t *testing.T) {
+	server := makeTestServer(t)
+	c, out := connectPipe(t, server)
+	other := connect(server)
+	execCmd(server, c, "subscribe", "ch")
+	out.expect(t, protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("subscribe")),
+		protocol.MakeBulkReply([]byte("ch")),
+		protocol.MakeIntReply(1),
+	}))
+
+	// only subscription commands and PING are allowed in subscribe mode
+	assertErr(t, execCmd(server, c, "get", "a"),
+		"ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING are allowed in this context")
+	assertReply(t, execCmd(server, c, "ping"), bulks("pong", ""))
+	assertReply(t, execCmd(server, c, "ping", "hi"), bulks("pong", "hi"))
+	assertReply(t, execCmd(server, other, "publish", "ch", "hello"), protocol.MakeIntReply(1))
+	out.expect(t, bulks("message", "ch", "hello"))
+
+	execCmd(server, c, "unsubscribe")
+	out.expect(t, protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("unsubscribe")),
+		protocol.MakeBulkReply([]byte("ch")),
+		protocol.MakeIntReply(0),
+	}))
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "ping"), &protocol.PongReply{})
+}
+
+func TestCloseUnsubscribes(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	other := connect(server)
+	execCmd(server, c, "subscribe", "ch")
+	execCmd(server, c, "psubscribe", "c*")
+	assertReply(t, execCmd(server, other, "pubsub", "numsub", "ch"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("ch")), protocol.MakeIntReply(1),
+	}))
+
+	server.AfterClientClose(c)
+	assertReply(t, execCmd(server, other, "pubsub", "numsub", "ch"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("ch")), protocol.MakeIntReply(0),
+	}))
+	assertReply(t, execCmd(server, other, "pubsub", "numpat"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, other, "publish", "ch", "hello"), protocol.MakeIntReply(0))
+}
+
+func TestPublishInMulti(t *testing.T) {
+	server := makeTestServer(t)
+	out := subscribe(t, server, "ch")
+	c := connect(server)
+
+	// PUBLISH, SPUBLISH, PUBSUB and INFO are queued, messages are sent by EXEC
+	execCmd(server, c, "multi")
+	assertReply(t, execCmd(server, c, "publish", "ch", "hello"), protocol.MakeQueuedReply())
+	assertReply(t, execCmd(server, c, "spublish", "ch", "sharded"), protocol.MakeQueuedReply())
+	assertReply(t, execCmd(server, c, "pubsub", "numsub", "ch"), protocol.MakeQueuedReply())
+	assertReply(t, execCmd(server, c, "info", "keyspace"), protocol.MakeQueuedReply())
+	out.expectNothing(t)
+	replies, ok := execCmd(server, c, "exec").(*protocol.MultiRawReply)
+	if !ok || len(replies.Replies) != 4 {
+		t.Fatalf("exec reply is %+v", replies)
+	}
+	assertReply(t, replies.Replies[0], protocol.MakeIntReply(1))
+	assertReply(t, replies.Replies[1], protocol.MakeIntReply(0))
+	assertReply(t, replies.Replies[2], protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("ch")), protocol.MakeIntReply(1),
+	}))
+	if _, ok := replies.Replies[3].(*protocol.BulkReply); !ok {
+		t.Fatalf("info reply is %q", replies.Replies[3].ToBytes())
+	}
+	out.expect(t, makeMessage("ch", "hello"))
+}
+
+func TestSubscribeResp3(t *testing.T) {
+	server := makeTestServer(t)
+	c, out := connectPipe(t, server)
+	other := connect(server)
+	execCmd(server, c, "hello", "3")
+	execCmd(server, c, "subscribe", "ch")
+	out.expect(t, protocol.MakePushReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("subscribe")),
+		protocol.MakeBulkReply([]byte("ch")),
+		protocol.MakeIntReply(1),
+	}))
+
+	// pushes are told from replies in RESP3, so any command is allowed in subscribe mode
+	assertReply(t, execCmd(server, c, "set", "a", "1"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "ping"), &protocol.PongReply{})
+	assertReply(t, execCmd(server, other, "publish", "ch", "hello"), protocol.MakeIntReply(1))
+	out.expect(t, protocol.MakePushReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("message")),
+		protocol.MakeBulkReply([]byte("ch")),
+		protocol.MakeBulkReply([]byte("hello")),
+	}))
+}
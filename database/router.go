@@ -0,0 +1,202 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/acl"
+)
+
+// cmdTable holds all commands registered by init() of command files
+var cmdTable = make(map[string]*command)
+
+// command describes an executable redis command
+type command struct {
+	name     string
+	executor ExecFunc
+	// arity means allowed number of cmdArgs, arity < 0 means len(args) >= -arity.
+	// for example: the arity of `get` is 2, `mget` is -2
+	arity int
+	flags int
+
+	// positions of keys, used when getKeys is nil
+	keys keySpec
+	// getKeys returns keys of commands whose keys can't be described by a keySpec
+	getKeys keysFunc
+	// undo records the undo log for transaction rollback, nil means snapshotting written keys
+	undo undoFunc
+	// accessedKeys returns written keys whose values are also read, like elements popped by LPOP are replied.
+	// ACL requires the read permission of them besides the write permission, nil means none
+	accessedKeys func(cmdLine [][]byte) []string
+}
+
+// keySpec locates keys in a command line, the same as first key, last key and step of COMMAND INFO:
+// keys are at firstKey, firstKey+step, ... until lastKey.
+// Keys of a write command are all written keys, keys of a readonly command are all read keys
+type keySpec struct {
+	firstKey int // position of the first key, the command name is at 0. 0 means no keys
+	lastKey  int // position of the last key, a negative value counts from the end, -1 is the last argument
+	step     int
+}
+
+// keysFunc returns keys written and read by the command, args don't include the command name
+type keysFunc func(args [][]byte) (writeKeys []string, readKeys []string)
+
+const (
+	flagWrite = 1 << iota
+	flagReadOnly
+)
+
+// registerCommand registers a normal command, which only read or modify a limited number of keys.
+// The only key of the command is the first argument by default, use setKeys or setKeysFunc to change it.
+// categories are ACL categories of the command, like acl.CategoryRead|acl.CategoryList
+func registerCommand(name string, executor ExecFunc, arity int, flags int, categories acl.Category) *command {
+	name = strings.ToLower(name)
+	acl.RegisterCommand(name, categories)
+	cmd := &command{
+		name:     name,
+		executor: executor,
+		arity:    arity,
+		flags:    flags,
+		keys:     keySpec{firstKey: 1, lastKey: 1, step: 1},
+	}
+	cmdTable[name] = cmd
+	return cmd
+}
+
+// commandExists returns whether the command is implemented, by cmdTable or by Server.Exec.
+// All of them are registered to acl, and subcommands registered there are not commands
+func commandExists(name string) bool {
+	return !strings.Contains(name, "|") && acl.CommandExists(name)
+}
+
+// setKeys sets the key positions of command, see keySpec
+func (cmd *command) setKeys(firstKey int, lastKey int, step int) *command {
+	cmd.keys = keySpec{firstKey: firstKey, lastKey: lastKey, step: step}
+	return cmd
+}
+
+// setKeysFunc sets the function to find keys of command
+func (cmd *command) setKeysFunc(getKeys keysFunc) *command {
+	cmd.getKeys = getKeys
+	return cmd
+}
+
+// setAccessWritten marks that command reads all keys it writes, see accessedKeys
+func (cmd *command) setAccessWritten() *command {
+	cmd.accessedKeys = func(cmdLine [][]byte) []string {
+		writeKeys, _ := cmd.keysOf(cmdLine)
+		return writeKeys
+	}
+	return cmd
+}
+
+// setAccessKeys marks that command reads the written keys located by the key spec, see keySpec and accessedKeys
+func (cmd *command) setAccessKeys(firstKey int, lastKey int, step int) *command {
+	spec := keySpec{firstKey: firstKey, lastKey: lastKey, step: step}
+	cmd.accessedKeys = spec.keysOf
+	return cmd
+}
+
+// setAccessKeysFunc sets the function returning written keys which command also reads, see accessedKeys
+func (cmd *command) setAccessKeysFunc(accessedKeys func(cmdLine [][]byte) []string) *command {
+	cmd.accessedKeys = accessedKeys
+	return cmd
+}
+
+// setUndo sets the function to record undo logs of command, see undoFunc
+func (cmd *command) setUndo(undo undoFunc) *command {
+	cmd.undo = undo
+	return cmd
+}
+
+// keysOf returns keys written and read by the command line, which includes the command name
+func (cmd *command) keysOf(cmdLine [][]byte) (writeKeys []string, readKeys []string) {
+	if cmd.getKeys != nil {
+		return cmd.getKeys(cmdLine[1:])
+	}
+	keys := cmd.keys.keysOf(cmdLine)
+	if cmd.flags&flagWrite > 0 {
+		return keys, nil
+	}
+	return nil, keys
+}
+
+// keysOf returns keys located by spec in the command line
+func (spec keySpec) keysOf(cmdLine [][]byte) []string {
+	if spec.firstKey == 0 {
+		return nil
+	}
+	last := spec.lastKey
+	if last < 0 {
+		last += len(cmdLine)
+	}
+	var keys []string
+	for i := spec.firstKey; i <= last && i < len(cmdLine); i += spec.step {
+		keys = append(keys, string(cmdLine[i]))
+	}
+	return keys
+}
+
+// keysFunc helpers for common key layouts
+
+// noKeys is for commands without keys
+func noKeys(args [][]byte) ([]string, []string) {
+	return nil, nil
+}
+
+// writeFirstReadOthers is for commands like SINTERSTORE destination key [key ...]
+func writeFirstReadOthers(args [][]byte) ([]string, []string) {
+	return []string{string(args[0])}, toKeys(args[1:])
+}
+
+// writeFirstReadSecond is for commands like ZRANGESTORE dst src ...
+func writeFirstReadSecond(args [][]byte) ([]string, []string) {
+	return []string{string(args[0])}, []string{string(args[1])}
+}
+
+// readFirstWriteSecond is for commands like COPY source destination
+func readFirstWriteSecond(args [][]byte) ([]string, []string) {
+	return []string{string(args[1])}, []string{string(args[0])}
+}
+
+// numKeysFunc is for commands like `ZUNION numkeys key [key ...]`, numKeysPos is the index of numkeys in args.
+// If hasDest, args[0] is a written destination key and other keys are read,
+// otherwise other keys are written if write is true or read if false
+func numKeysFunc(numKeysPos int, hasDest bool, write bool) keysFunc {
+	return func(args [][]byte) ([]string, []string) {
+		numKeys, err := strconv.Atoi(string(args[numKeysPos]))
+		start := numKeysPos + 1
+		if err != nil || numKeys < 0 || numKeys > len(args)-start {
+			// the executor will reply the error
+			numKeys = 0
+		}
+		keys := toKeys(args[start : start+numKeys])
+		if hasDest {
+			return []string{string(args[0])}, keys
+		}
+		if write {
+			return keys, nil
+		}
+		return nil, keys
+	}
+}
+
+func toKeys(args [][]byte) []string {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = string(arg)
+	}
+	return keys
+}
+
+// validateArity checks the number of arguments (including command name)
+//
+// Example: arity 3 requires exactly 3 args, arity -3 requires at least 3 args
+func validateArity(arity int, cmdArgs [][]byte) bool {
+	argNum := len(cmdArgs)
+	if arity >= 0 {
+		return argNum == arity
+	}
+	return argNum >= -arity
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestNumKeysOverflow(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	maxInt := strconv.Itoa(math.MaxInt)
+	tooMany := "ERR Number of keys can't be greater than number of args"
+	// a numkeys greater than the number of args is rejected by the executor instead of panicking
+	for _, tt := range []struct {
+		cmdLine []string
+		err     string
+	}{
+		{[]string{"sintercard", maxInt, "a"}, tooMany},
+		{[]string{"zintercard", maxInt, "a"}, tooMany},
+		{[]string{"zunion", maxInt, "a"}, "ERR syntax error"},
+		{[]string{"zinter", maxInt, "a"}, "ERR syntax error"},
+		{[]string{"zunionstore", "dest", maxInt, "a"}, "ERR syntax error"},
+		{[]string{"zinterstore", "dest", maxInt, "a"}, "ERR syntax error"},
+	} {
+		assertErr(t, execCmd(server, c, tt.cmdLine...), tt.err)
+	}
+}
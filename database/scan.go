@@ -0,0 +1,126 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/wildcard"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// scanArgs is the parsed form of `cursor [MATCH pattern] [COUNT count] [TYPE type] [NOVALUES]`
+type scanArgs struct {
+	cursor   uint64
+	pattern  string // empty means matching everything
+	count    int
+	typeName string // empty means any type, only for SCAN
+	noValues bool   // only for HSCAN
+}
+
+const defaultScanCount = 10
+
+// typeNames are the valid arguments of SCAN TYPE
+var typeNames = map[string]struct{}{
+	"string": {},
+	"list":   {},
+	"hash":   {},
+	"set":    {},
+	"zset":   {},
+	"stream": {},
+}
+
+// parseScanArgs parses arguments shared by the SCAN family, cmd is the lower case command name
+func parseScanArgs(cmd string, args [][]byte) (*scanArgs, protocol.ErrorReply) {
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, protocol.MakeErrReply("ERR invalid cursor")
+	}
+	result := &scanArgs{
+		cursor: cursor,
+		count:  defaultScanCount,
+	}
+	for i := 1; i < len(args); i += 2 {
+		option := strings.ToUpper(string(args[i]))
+		if option == "NOVALUES" {
+			if cmd != "hscan" {
+				return nil, protocol.MakeErrReply("ERR NOVALUES option can only be used in HSCAN")
+			}
+			result.noValues = true
+			i-- // NOVALUES takes no argument
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+		switch option {
+		case "MATCH":
+			result.pattern = string(args[i+1])
+			if result.pattern == "*" {
+				result.pattern = ""
+			}
+		case "COUNT":
+			count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return nil, errNotInteger
+			}
+			if count < 1 {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			result.count = int(count)
+		case "TYPE":
+			if cmd != "scan" {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			result.typeName = strings.ToLower(string(args[i+1]))
+			if _, ok := typeNames[result.typeName]; !ok {
+				return nil, protocol.MakeErrReply("ERR unknown type name '" + string(args[i+1]) + "'")
+			}
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	return result, nil
+}
+
+func (scan *scanArgs) match(s string) bool {
+	return scan.pattern == "" || wildcard.Match(scan.pattern, s)
+}
+
+// makeScanReply returns [cursor, [element ...]]
+func makeScanReply(cursor uint64, result [][]byte) redis.Reply {
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte(strconv.FormatUint(cursor, 10))),
+		protocol.MakeMultiBulkReply(result),
+	})
+}
+
+// execScan iterates keys of db.
+// Keys present during the whole iteration are returned at least once, a key may be returned several times
+//
+//	SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+func execScan(db *DB, args [][]byte) redis.Reply {
+	scan, errReply := parseScanArgs("scan", args)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, 0)
+	cursor := db.data.Scan(scan.cursor, scan.count, func(key string, val interface{}) bool {
+		entity, ok := val.(*database.DataEntity)
+		if !ok || isWaitingDeletion(entity) || !scan.match(key) {
+			return true
+		}
+		if scan.typeName != "" && typeName(entity.Data) != scan.typeName {
+			return true
+		}
+		result = append(result, []byte(key))
+		return true
+	})
+	return makeScanReply(cursor, result)
+}
+
+func init() {
+	registerCommand("Scan", execScan, -2, flagReadOnly, acl.CategoryRead|acl.CategoryKeyspace).setKeysFunc(noKeys)
+}
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/logger"
+	"github.com/tonge3199/redis_go/pubsub"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// Server is a redis-server with full capabilities including multiple database
+type Server struct {
+	dbSet []*DB
+
+	// client id -> *waiter of blocked clients in all dbs
+	blockedClients *sync.Map
+
+	// publish/subscribe
+	hub *pubsub.Hub
+
+	// client id -> redis.Connection of all connected clients
+	clients *sync.Map
+	// client side caching
+	tracking *trackingTable
+
+	// users and their permissions
+	acl *acl.ACL
+
+	// statistics shown by INFO
+	stats serverStats
+
+	// closed to stop background jobs
+	stopCh chan struct{}
+}
+
+// NewStandaloneServer creates a standalone redis server, with multi database and all other funtions
+func NewStandaloneServer() *Server {
+	server := &Server{
+		blockedClients: &sync.Map{},
+		hub:            pubsub.MakeHub(),
+		clients:        &sync.Map{},
+		stopCh:         make(chan struct{}),
+	}
+	server.stats.startTime = time.Now()
+	server.tracking = makeTrackingTable(server.clients)
+	users, err := acl.New(config.Properties.RequirePass, config.Properties.ACLFile)
+	if err != nil {
+		logger.Error("failed to load aclfile: " + err.Error())
+	}
+	server.acl = users
+	if config.Properties.Databases == 0 {
+		config.Properties.Databases = 16
+	}
+	classes, ok := parseKeyspaceEvents(config.Properties.NotifyKeyspaceEvents)
+	if !ok {
+		logger.Warn("illegal config notify-keyspace-events: " + config.Properties.NotifyKeyspaceEvents)
+	}
+	notifier := makeKeyspaceNotifier(server.hub, classes)
+	server.dbSet = make([]*DB, config.Properties.Databases)
+	for i := range server.dbSet {
+		singleDB := makeDB()
+		singleDB.index = i
+		singleDB.blockedClients = server.blockedClients
+		singleDB.notifier = notifier
+		singleDB.tracking = server.tracking
+		singleDB.server = server
+		server.dbSet[i] = singleDB
+	}
+	go server.cron()
+	return server
+}
+
+// cron runs background jobs like active expiration until the server is closed
+func (server *Server) cron() {
+	ticker := time.NewTicker(activeExpireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-server.stopCh:
+			return
+		case <-ticker.C:
+			for _, db := range server.dbSet {
+				db.activeExpireCycle()
+			}
+		}
+	}
+}
+
+// Exec executes command
+// parameter `cmdLine` contains command and its arguments, for example: "set key value"
+func (server *Server) Exec(c redis.Connection, cmdLine [][]byte) (result redis.Reply) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Warn(fmt.Sprintf("error occurs: %v\n%s", err, string(debug.Stack())))
+			result = &protocol.UnknownErrReply{}
+		}
+	}()
+
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	if !commandExists(cmdName) {
+		// an unknown command is rejected before authentication and permission checks, the same as redis
+		errReply := protocol.MakeErrReply("ERR unknown command '" + cmdName + "'")
+		if c.InMultiState() {
+			c.AddTxError(errReply)
+		}
+		return errReply
+	}
+	if errReply := server.checkACL(c, cmdName, cmdLine); errReply != nil {
+		return errReply
+	}
+	if server.tracking.isTracking() && !isClientCaching(cmdName, cmdLine) {
+		// CLIENT CACHING only applies to the next command
+		defer server.tracking.afterCommand(c)
+	}
+	if server.tracking.isTracking() {
+		// invalidations of keys modified by c itself are sent after the reply
+		defer func() {
+			result = server.tracking.withSelfInvalidations(c.ID(), result)
+		}()
+	}
+	// a RESP3 client tells pushed messages from replies, so it may run any command in subscribe mode like redis
+	if pubsub.InSubscribeMode(c) && c.GetProtocolVersion() < 3 && !subscribeModeCommands[cmdName] {
+		return protocol.MakeErrReply("ERR Can't execute '" + cmdName +
+			"': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING are allowed in this context")
+	}
+	// transaction control commands
+	switch cmdName {
+	case "multi":
+		return execMulti(c, cmdLine[1:])
+	case "discard":
+		return server.execDiscard(c, cmdLine[1:])
+	case "exec":
+		return server.execTransaction(c, cmdLine[1:])
+	case "watch":
+		return server.execWatch(c, cmdLine[1:])
+	}
+	if c.InMultiState() {
+		switch cmdName {
+		case "select", "client", "hello", "auth", "acl", "unwatch", "flushall",
+			"subscribe", "unsubscribe", "psubscribe", "punsubscribe", "ssubscribe", "sunsubscribe":
+			// they change the state of the connection or touch other dbs, so they can't be queued.
+			// PUBLISH, SPUBLISH, PUBSUB and INFO don't, they are queued like COPY, see pubsub.go and execLocalInfo
+			c.AddTxError(errNotAllowedInMulti)
+			return errNotAllowedInMulti
+		}
+		return enqueueCmd(c, cmdLine)
+	}
+
+	// special commands which cannot execute within a single db
+	switch cmdName {
+	case "ping":
+		return Ping(c, cmdLine[1:])
+	case "select":
+		if len(cmdLine) != 2 {
+			return protocol.MakeArgNumErrReply("select")
+		}
+		return execSelect(c, server, cmdLine[1:])
+	case "client":
+		return execClient(server, c, cmdLine[1:])
+	case "hello":
+		return execHello(server, c, cmdLine[1:])
+	case "auth":
+		return execAuth(server, c, cmdLine[1:])
+	case "info":
+		return execInfo(server, cmdLine[1:])
+	case "acl":
+		return execACL(server, c, cmdLine[1:])
+	case "unwatch":
+		return server.execUnwatch(c, cmdLine[1:])
+	case "flushall":
+		return server.execFlushAll(cmdLine[1:])
+	case "copy":
+		return execCopy(server, c, cmdLine[1:])
+	case "subscribe":
+		return server.hub.Subscribe(c, cmdLine[1:])
+	case "unsubscribe":
+		return server.hub.Unsubscribe(c, cmdLine[1:])
+	case "psubscribe":
+		return server.hub.PSubscribe(c, cmdLine[1:])
+	case "punsubscribe":
+		return server.hub.PUnsubscribe(c, cmdLine[1:])
+	case "ssubscribe":
+		return server.hub.SSubscribe(c, cmdLine[1:])
+	case "sunsubscribe":
+		return server.hub.SUnsubscribe(c, cmdLine[1:])
+	case "spublish":
+		return server.hub.SPublish(cmdLine[1:])
+	case "publish":
+		return server.hub.Publish(cmdLine[1:])
+	case "pubsub":
+		return server.hub.PubSub(cmdLine[1:])
+	}
+
+	// normal commands
+	dbIndex := c.GetDBIndex()
+	selectedDB, errReply := server.selectDB(dbIndex)
+	if errReply != nil {
+		return errReply
+	}
+	return selectedDB.Exec(c, cmdLine)
+}
+
+// subscribeModeCommands are commands allowed when the connection subscribes any channel
+var subscribeModeCommands = map[string]bool{
+	"subscribe":    true,
+	"unsubscribe":  true,
+	"psubscribe":   true,
+	"punsubscribe": true,
+	"ssubscribe":   true,
+	"sunsubscribe": true,
+	"ping":         true,
+}
+
+// AfterClientClose does some clean after client close connection
+func (server *Server) AfterClientClose(c redis.Connection) {
+	server.releaseWatching(c, nil)
+	server.hub.UnsubscribeAll(c)
+	server.tracking.disable(c.ID())
+	server.clients.Delete(c.ID())
+}
+
+// AfterClientConnect registers a new client, so that it can be found by id
+func (server *Server) AfterClientConnect(c redis.Connection) {
+	server.clients.Store(c.ID(), c)
+	server.stats.connectionsReceived.Add(1)
+	if user := server.acl.GetUser(acl.DefaultUser); user.Enabled() && user.NoPass() {
+		c.SetUser(acl.DefaultUser)
+	}
+}
+
+// Close graceful shutdown database
+func (server *Server) Close() {
+	close(server.stopCh)
+}
+
+func (server *Server) selectDB(dbIndex int) (*DB, *protocol.StandardErrReply) {
+	if dbIndex >= len(server.dbSet) || dbIndex < 0 {
+		return nil, protocol.MakeErrReply("ERR DB index is out of range")
+	}
+	return server.dbSet[dbIndex], nil
+}
+
+// execFlushAll removes all keys of all dbs
+//
+//	FLUSHALL [ASYNC | SYNC]
+func (server *Server) execFlushAll(args [][]byte) redis.Reply {
+	if errReply := parseFlushMode(args); errReply != nil {
+		return errReply
+	}
+	for _, db := range server.dbSet {
+		db.Flush()
+	}
+	server.tracking.invalidateAll()
+	return protocol.MakeOKReply()
+}
+
+func execSelect(c redis.Connection, mdb *Server, args [][]byte) redis.Reply {
+	dbIndex, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if dbIndex >= len(mdb.dbSet) || dbIndex < 0 {
+		return protocol.MakeErrReply("ERR DB index is out of range")
+	}
+	c.SelectDB(dbIndex)
+	return protocol.MakeOKReply()
+}
+
+var errNotAllowedInMulti = protocol.MakeErrReply("ERR Command not allowed inside a transaction")
+
+// Ping the server
+func Ping(c redis.Connection, args [][]byte) redis.Reply {
+	if c != nil && pubsub.InSubscribeMode(c) && c.GetProtocolVersion() < 3 {
+		// a RESP2 client in subscribe mode can't tell +PONG from pushed messages, so it gets a push-like array
+		if len(args) > 1 {
+			return protocol.MakeArgNumErrReply("ping")
+		}
+		message := []byte("")
+		if len(args) == 1 {
+			message = args[0]
+		}
+		return protocol.MakeMultiBulkReply([][]byte{[]byte("pong"), message})
+	}
+	if len(args) == 0 {
+		return &protocol.PongReply{}
+	} else if len(args) == 1 {
+		return protocol.MakeBulkReply(args[0])
+	}
+	return protocol.MakeArgNumErrReply("ping")
+}
+
+// execPing is PING queued in a transaction
+func execPing(db *DB, args [][]byte) redis.Reply {
+	return Ping(nil, args)
+}
+
+func init() {
+	registerCommand("Ping", execPing, -1, flagReadOnly, acl.CategoryConnection).setKeysFunc(noKeys)
+}
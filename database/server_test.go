@@ -0,0 +1,110 @@
+package database
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/utils"
+	"github.com/tonge3199/redis_go/redis/connection"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// makeTestServer creates a server which is closed when the test ends
+func makeTestServer(t *testing.T) *Server {
+	t.Helper()
+	server := NewStandaloneServer()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// connect returns a new client of server, replies are returned by Exec instead of being sent
+func connect(server *Server) redis.Connection {
+	c := connection.NewConn(nil)
+	server.AfterClientConnect(c)
+	return c
+}
+
+// pipeClient is the client side of a connection created by connectPipe
+type pipeClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// connectPipe returns a new client of server whose output written to the connection, like pub/sub messages
+// and invalidations, is read by the returned pipeClient. Replies of execCmd are still returned by Exec
+func connectPipe(t *testing.T, server *Server) (redis.Connection, *pipeClient) {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	c := connection.NewConn(serverSide)
+	server.AfterClientConnect(c)
+	t.Cleanup(func() {
+		_ = clientSide.Close()
+		_ = c.Close()
+		server.AfterClientClose(c)
+	})
+	return c, &pipeClient{conn: clientSide, reader: bufio.NewReader(clientSide)}
+}
+
+// expect reads the next output of the connection and compares it with expected
+func (pc *pipeClient) expect(t *testing.T, expected redis.Reply) {
+	t.Helper()
+	_ = pc.conn.SetReadDeadline(time.Now().Add(time.Second))
+	got := make([]byte, len(expected.ToBytes()))
+	if n, err := io.ReadFull(pc.reader, got); err != nil {
+		t.Fatalf("output is %q, expected %q: %v", got[:n], expected.ToBytes(), err)
+	}
+	if string(got) != string(expected.ToBytes()) {
+		t.Fatalf("output is %q, expected %q", got, expected.ToBytes())
+	}
+}
+
+// expectNothing checks that nothing is written to the connection
+func (pc *pipeClient) expectNothing(t *testing.T) {
+	t.Helper()
+	_ = pc.conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if b, err := pc.reader.ReadByte(); err == nil {
+		rest, _ := pc.reader.Peek(pc.reader.Buffered())
+		t.Fatalf("unexpected output %q", append([]byte{b}, rest...))
+	}
+}
+
+func execCmd(server *Server, c redis.Connection, args ...string) redis.Reply {
+	return server.Exec(c, utils.ToCmdLine(args...))
+}
+
+func assertReply(t *testing.T, got redis.Reply, expected redis.Reply) {
+	t.Helper()
+	if string(got.ToBytes()) != string(expected.ToBytes()) {
+		t.Fatalf("reply is %q, expected %q", got.ToBytes(), expected.ToBytes())
+	}
+}
+
+// assertErr checks that got is an error reply starting with prefix
+func assertErr(t *testing.T, got redis.Reply, prefix string) {
+	t.Helper()
+	errReply, ok := got.(protocol.ErrorReply)
+	if !ok || !strings.HasPrefix(errReply.Error(), prefix) {
+		t.Fatalf("reply is %q, expected an error starting with %q", got.ToBytes(), prefix)
+	}
+}
+
+func bulks(args ...string) redis.Reply {
+	return protocol.MakeMultiBulkReply(utils.ToCmdLine(args...))
+}
+
+func TestUnknownCommand(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertErr(t, execCmd(server, c, "nosuchcmd", "a"), "ERR unknown command 'nosuchcmd'")
+
+	// unknown commands are checked before authentication
+	execCmd(server, c, "acl", "setuser", "default", "resetpass", ">secret")
+	other := connect(server)
+	assertErr(t, execCmd(server, other, "nosuchcmd"), "ERR unknown command")
+	assertReply(t, execCmd(server, other, "get", "a"), errNoAuth)
+}
@@ -0,0 +1,493 @@
+package database
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/config"
+	Set "github.com/tonge3199/redis_go/datastruct/set"
+	"github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func (db *DB) getAsSet(key string) (*Set.Set, protocol.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	set, ok := entity.Data.(*Set.Set)
+	if !ok {
+		return nil, protocol.MakeWrongTypeErrReply()
+	}
+	return set, nil
+}
+
+func (db *DB) getOrInitSet(key string) (set *Set.Set, inited bool, errReply protocol.ErrorReply) {
+	set, errReply = db.getAsSet(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if set == nil {
+		set = Set.Make(config.Properties.SetMaxIntsetEntries)
+		db.PutEntity(key, &database.DataEntity{
+			Data: set,
+		})
+		inited = true
+	}
+	return set, inited, nil
+}
+
+// execSAdd adds members into set
+//
+//	SADD key member [member ...]
+func execSAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	set, _, errReply := db.getOrInitSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	added := 0
+	for _, member := range args[1:] {
+		added += set.Add(string(member))
+	}
+	if added > 0 {
+		db.notifyKeyspaceEvent(notifySet, "sadd", key)
+	}
+	return protocol.MakeIntReply(int64(added))
+}
+
+// execSRem removes members from set, the key is deleted once the set becomes empty
+//
+//	SREM key member [member ...]
+func execSRem(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return protocol.MakeIntReply(0)
+	}
+	removed := 0
+	for _, member := range args[1:] {
+		removed += set.Remove(string(member))
+	}
+	if removed > 0 {
+		db.notifyKeyspaceEvent(notifySet, "srem", key)
+	}
+	if set.Len() == 0 {
+		db.deleteKey(key)
+	}
+	return protocol.MakeIntReply(int64(removed))
+}
+
+// execSIsMember checks whether member is in set
+//
+//	SISMEMBER key member
+func execSIsMember(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set != nil && set.Has(string(args[1])) {
+		return protocol.MakeIntReply(1)
+	}
+	return protocol.MakeIntReply(0)
+}
+
+// execSMIsMember checks whether each member is in set
+//
+//	SMISMEMBER key member [member ...]
+func execSMIsMember(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	replies := make([]redis.Reply, len(args)-1)
+	for i, member := range args[1:] {
+		if set != nil && set.Has(string(member)) {
+			replies[i] = protocol.MakeIntReply(1)
+		} else {
+			replies[i] = protocol.MakeIntReply(0)
+		}
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// execSMembers returns all members of set
+//
+//	SMEMBERS key
+func execSMembers(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	return makeMembersReply(set.Members())
+}
+
+func makeMembersReply(members []string) redis.Reply {
+	result := make([][]byte, len(members))
+	for i, member := range members {
+		result[i] = []byte(member)
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// execSCard returns the number of members
+//
+//	SCARD key
+func execSCard(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(int64(set.Len()))
+}
+
+// execSPop removes and returns random members
+//
+//	SPOP key [count]
+func execSPop(db *DB, args [][]byte) redis.Reply {
+	if len(args) > 2 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	key := string(args[0])
+	withCount := len(args) == 2
+	count := int64(1)
+	if withCount {
+		var err error
+		count, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		if count < 0 {
+			return errNotPositive
+		}
+	}
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		if withCount {
+			return protocol.MakeEmptyMultiBulkReply()
+		}
+		return protocol.MakeNullBulkReply()
+	}
+	if count > int64(set.Len()) {
+		count = int64(set.Len())
+	}
+	members := set.RandomDistinctMembers(int(count))
+	for _, member := range members {
+		set.Remove(member)
+	}
+	if len(members) > 0 {
+		db.notifyKeyspaceEvent(notifySet, "spop", key)
+	}
+	if set.Len() == 0 {
+		db.deleteKey(key)
+	}
+	if !withCount {
+		return protocol.MakeBulkReply([]byte(members[0]))
+	}
+	return makeMembersReply(members)
+}
+
+// execSRandMember returns random members without removing them
+//
+//	SRANDMEMBER key [count]
+//
+// a positive count returns at most count distinct members,
+// a negative count returns exactly -count members which may be duplicated
+func execSRandMember(db *DB, args [][]byte) redis.Reply {
+	if len(args) > 2 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	key := string(args[0])
+	withCount := len(args) == 2
+	count := int64(1)
+	if withCount {
+		var err error
+		count, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+	}
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		if withCount {
+			return protocol.MakeEmptyMultiBulkReply()
+		}
+		return protocol.MakeNullBulkReply()
+	}
+	if !withCount {
+		return protocol.MakeBulkReply([]byte(set.RandomMembers(1)[0]))
+	}
+	if count >= 0 {
+		return makeMembersReply(set.RandomDistinctMembers(int(count)))
+	}
+	if -count > math.MaxInt32 {
+		return protocol.MakeErrReply("ERR value is out of range")
+	}
+	return makeMembersReply(set.RandomMembers(int(-count)))
+}
+
+// execSMove moves member from source set to destination set
+//
+//	SMOVE source destination member
+func execSMove(db *DB, args [][]byte) redis.Reply {
+	srcKey := string(args[0])
+	destKey := string(args[1])
+	member := string(args[2])
+
+	src, errReply := db.getAsSet(srcKey)
+	if errReply != nil {
+		return errReply
+	}
+	// check the type of destination even if nothing will be moved, the same as redis
+	if _, errReply = db.getAsSet(destKey); errReply != nil {
+		return errReply
+	}
+	if src == nil || !src.Has(member) {
+		return protocol.MakeIntReply(0)
+	}
+	if srcKey == destKey {
+		return protocol.MakeIntReply(1)
+	}
+
+	src.Remove(member)
+	db.notifyKeyspaceEvent(notifySet, "srem", srcKey)
+	if src.Len() == 0 {
+		db.deleteKey(srcKey)
+	}
+	dest, _, _ := db.getOrInitSet(destKey)
+	if dest.Add(member) > 0 {
+		db.notifyKeyspaceEvent(notifySet, "sadd", destKey)
+	}
+	return protocol.MakeIntReply(1)
+}
+
+/* ---- Set Algebra ---- */
+
+// Commands of set algebra read all source sets and write the destination in one executor,
+// which runs with all these keys locked, so concurrent writers never see or produce a partial result.
+
+// getSets returns sets of keys, a missing key is represented by nil
+func (db *DB) getSets(keys [][]byte) ([]*Set.Set, protocol.ErrorReply) {
+	sets := make([]*Set.Set, len(keys))
+	for i, key := range keys {
+		set, errReply := db.getAsSet(string(key))
+		if errReply != nil {
+			return nil, errReply
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
+
+func makeSet() *Set.Set {
+	return Set.Make(config.Properties.SetMaxIntsetEntries)
+}
+
+// intersect returns the intersection of sets, limit > 0 stops the computation once the result has limit members
+func intersect(sets []*Set.Set, limit int) *Set.Set {
+	result := makeSet()
+	for _, set := range sets {
+		if set == nil {
+			return result
+		}
+	}
+	// iterate the smallest set and check whether its members are in all the others
+	sorted := make([]*Set.Set, len(sets))
+	copy(sorted, sets)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Len() < sorted[j].Len()
+	})
+	sorted[0].ForEach(func(member string) bool {
+		for _, set := range sorted[1:] {
+			if !set.Has(member) {
+				return true
+			}
+		}
+		result.Add(member)
+		return limit <= 0 || result.Len() < limit
+	})
+	return result
+}
+
+// union returns the union of sets
+func union(sets []*Set.Set) *Set.Set {
+	result := makeSet()
+	for _, set := range sets {
+		if set == nil {
+			continue
+		}
+		set.ForEach(func(member string) bool {
+			result.Add(member)
+			return true
+		})
+	}
+	return result
+}
+
+// diff returns members of the first set which are not in any of the others
+func diff(sets []*Set.Set) *Set.Set {
+	result := makeSet()
+	if sets[0] == nil {
+		return result
+	}
+	sets[0].ForEach(func(member string) bool {
+		for _, set := range sets[1:] {
+			if set != nil && set.Has(member) {
+				return true
+			}
+		}
+		result.Add(member)
+		return true
+	})
+	return result
+}
+
+// storeSet puts result into dest key and notifies event, an empty result deletes dest
+func (db *DB) storeSet(dest string, result *Set.Set, event string) redis.Reply {
+	if result.Len() == 0 {
+		db.deleteKey(dest)
+	} else {
+		db.PutEntity(dest, &database.DataEntity{
+			Data: result,
+		})
+		db.notifyKeyspaceEvent(notifySet, event, dest)
+	}
+	return protocol.MakeIntReply(int64(result.Len()))
+}
+
+// makeSetAlgebra returns the executor of SINTER/SUNION/SDIFF and their STORE variants.
+// storeEvent is the keyspace event of a STORE variant, whose first argument is the destination key,
+// it is empty for the others
+//
+//	SINTER key [key ...]
+//	SINTERSTORE destination key [key ...]
+func makeSetAlgebra(operation func(sets []*Set.Set) *Set.Set, storeEvent string) ExecFunc {
+	store := storeEvent != ""
+	return func(db *DB, args [][]byte) redis.Reply {
+		keys := args
+		if store {
+			keys = args[1:]
+		}
+		sets, errReply := db.getSets(keys)
+		if errReply != nil {
+			return errReply
+		}
+		result := operation(sets)
+		if store {
+			return db.storeSet(string(args[0]), result, storeEvent)
+		}
+		return makeMembersReply(result.Members())
+	}
+}
+
+func intersectAll(sets []*Set.Set) *Set.Set {
+	return intersect(sets, 0)
+}
+
+// execSInterCard returns the cardinality of the intersection
+//
+//	SINTERCARD numkeys key [key ...] [LIMIT limit]
+func execSInterCard(db *DB, args [][]byte) redis.Reply {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	if numKeys <= 0 {
+		return protocol.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-1) {
+		return protocol.MakeErrReply("ERR Number of keys can't be greater than number of args")
+	}
+	limit := int64(0)
+	rest := args[numKeys+1:]
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToUpper(string(rest[0])) != "LIMIT" {
+			return protocol.MakeSyntaxErrReply()
+		}
+		limit, err = strconv.ParseInt(string(rest[1]), 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		if limit < 0 {
+			return protocol.MakeErrReply("ERR LIMIT can't be negative")
+		}
+	}
+
+	sets, errReply := db.getSets(args[1 : numKeys+1])
+	if errReply != nil {
+		return errReply
+	}
+	return protocol.MakeIntReply(int64(intersect(sets, int(limit)).Len()))
+}
+
+// execSScan iterates members of set
+//
+//	SSCAN key cursor [MATCH pattern] [COUNT count]
+func execSScan(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	scan, errReply := parseScanArgs("sscan", args[1:])
+	if errReply != nil {
+		return errReply
+	}
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, 0)
+	if set == nil {
+		return makeScanReply(0, result)
+	}
+	cursor := set.Scan(scan.cursor, scan.count, func(member string) {
+		if scan.match(member) {
+			result = append(result, []byte(member))
+		}
+	})
+	return makeScanReply(cursor, result)
+}
+
+func init() {
+	registerCommand("SAdd", execSAdd, -3, flagWrite, acl.CategoryWrite|acl.CategorySet)
+	registerCommand("SRem", execSRem, -3, flagWrite, acl.CategoryWrite|acl.CategorySet)
+	registerCommand("SIsMember", execSIsMember, 3, flagReadOnly, acl.CategoryRead|acl.CategorySet)
+	registerCommand("SMIsMember", execSMIsMember, -3, flagReadOnly, acl.CategoryRead|acl.CategorySet)
+	registerCommand("SMembers", execSMembers, 2, flagReadOnly, acl.CategoryRead|acl.CategorySet)
+	registerCommand("SCard", execSCard, 2, flagReadOnly, acl.CategoryRead|acl.CategorySet)
+	registerCommand("SPop", execSPop, -2, flagWrite, acl.CategoryWrite|acl.CategorySet).setAccessWritten()
+	registerCommand("SRandMember", execSRandMember, -2, flagReadOnly, acl.CategoryRead|acl.CategorySet)
+	registerCommand("SMove", execSMove, 4, flagWrite, acl.CategoryWrite|acl.CategorySet).setKeys(1, 2, 1)
+	registerCommand("SInter", makeSetAlgebra(intersectAll, ""), -2, flagReadOnly, acl.CategoryRead|acl.CategorySet).setKeys(1, -1, 1)
+	registerCommand("SInterStore", makeSetAlgebra(intersectAll, "sinterstore"), -3, flagWrite, acl.CategoryWrite|acl.CategorySet).setKeysFunc(writeFirstReadOthers)
+	registerCommand("SUnion", makeSetAlgebra(union, ""), -2, flagReadOnly, acl.CategoryRead|acl.CategorySet).setKeys(1, -1, 1)
+	registerCommand("SUnionStore", makeSetAlgebra(union, "sunionstore"), -3, flagWrite, acl.CategoryWrite|acl.CategorySet).setKeysFunc(writeFirstReadOthers)
+	registerCommand("SDiff", makeSetAlgebra(diff, ""), -2, flagReadOnly, acl.CategoryRead|acl.CategorySet).setKeys(1, -1, 1)
+	registerCommand("SDiffStore", makeSetAlgebra(diff, "sdiffstore"), -3, flagWrite, acl.CategoryWrite|acl.CategorySet).setKeysFunc(writeFirstReadOthers)
+	registerCommand("SInterCard", execSInterCard, -3, flagReadOnly, acl.CategoryRead|acl.CategorySet).setKeysFunc(numKeysFunc(0, false, false))
+	registerCommand("SScan", execSScan, -3, flagReadOnly, acl.CategoryRead|acl.CategorySet)
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/tonge3199/redis_go/config"
+	Set "github.com/tonge3199/redis_go/datastruct/set"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// members returns the sorted members in a multi bulk reply, since members of a set are unordered
+func members(t *testing.T, reply redis.Reply) []string {
+	t.Helper()
+	multi, ok := reply.(*protocol.MultiBulkReply)
+	if !ok {
+		t.Fatalf("reply %q is not a multi bulk", reply.ToBytes())
+	}
+	result := make([]string, len(multi.Args))
+	for i, arg := range multi.Args {
+		result[i] = string(arg)
+	}
+	slices.Sort(result)
+	return result
+}
+
+func assertMembers(t *testing.T, reply redis.Reply, expected ...string) {
+	t.Helper()
+	if got := members(t, reply); !slices.Equal(got, expected) {
+		t.Fatalf("members are %q, expected %q", got, expected)
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "sadd", "s1", "a", "b", "c", "d")
+	execCmd(server, c, "sadd", "s2", "c", "d", "e")
+	execCmd(server, c, "sadd", "s3", "a", "c", "e")
+
+	assertMembers(t, execCmd(server, c, "sinter", "s1", "s2", "s3"), "c")
+	assertMembers(t, execCmd(server, c, "sunion", "s1", "s2", "s3"), "a", "b", "c", "d", "e")
+	assertMembers(t, execCmd(server, c, "sdiff", "s1", "s2", "s3"), "b")
+	// a missing key is an empty set
+	assertMembers(t, execCmd(server, c, "sinter", "s1", "none"))
+	assertMembers(t, execCmd(server, c, "sunion", "none", "s2"), "c", "d", "e")
+	assertMembers(t, execCmd(server, c, "sdiff", "s1", "none"), "a", "b", "c", "d")
+	assertMembers(t, execCmd(server, c, "sdiff", "none", "s1"))
+
+	assertReply(t, execCmd(server, c, "sinterstore", "dst", "s1", "s2"), protocol.MakeIntReply(2))
+	assertMembers(t, execCmd(server, c, "smembers", "dst"), "c", "d")
+	assertReply(t, execCmd(server, c, "sunionstore", "dst", "s2", "s3"), protocol.MakeIntReply(4))
+	assertMembers(t, execCmd(server, c, "smembers", "dst"), "a", "c", "d", "e")
+	assertReply(t, execCmd(server, c, "sdiffstore", "dst", "s1", "s3"), protocol.MakeIntReply(2))
+	assertMembers(t, execCmd(server, c, "smembers", "dst"), "b", "d")
+	// an empty result deletes the destination
+	assertReply(t, execCmd(server, c, "sinterstore", "dst", "s1", "none"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "exists", "dst"), protocol.MakeIntReply(0))
+
+	// the destination may be one of the sources, it's overwritten after all sources are read
+	assertReply(t, execCmd(server, c, "sdiffstore", "s1", "s1", "s2"), protocol.MakeIntReply(2))
+	assertMembers(t, execCmd(server, c, "smembers", "s1"), "a", "b")
+	assertReply(t, execCmd(server, c, "sunionstore", "s1", "s1", "s2"), protocol.MakeIntReply(5))
+	assertMembers(t, execCmd(server, c, "smembers", "s1"), "a", "b", "c", "d", "e")
+	assertReply(t, execCmd(server, c, "sinterstore", "s3", "s3", "s2"), protocol.MakeIntReply(2))
+	assertMembers(t, execCmd(server, c, "smembers", "s3"), "c", "e")
+
+	execCmd(server, c, "set", "str", "v")
+	assertErr(t, execCmd(server, c, "sunion", "s1", "str"), "WRONGTYPE")
+	assertErr(t, execCmd(server, c, "sinterstore", "dst", "none", "str"), "WRONGTYPE")
+	// the destination is overwritten whatever its type is
+	assertReply(t, execCmd(server, c, "sunionstore", "str", "s2"), protocol.MakeIntReply(3))
+	assertReply(t, execCmd(server, c, "type", "str"), protocol.MakeStatusReply("set"))
+}
+
+func TestSInterCard(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "sadd", "s1", "a", "b", "c", "d")
+	execCmd(server, c, "sadd", "s2", "b", "c", "d", "e")
+	assertReply(t, execCmd(server, c, "sintercard", "2", "s1", "s2"), protocol.MakeIntReply(3))
+	assertReply(t, execCmd(server, c, "sintercard", "2", "s1", "s2", "limit", "2"), protocol.MakeIntReply(2))
+	// LIMIT 0 means unlimited
+	assertReply(t, execCmd(server, c, "sintercard", "2", "s1", "s2", "limit", "0"), protocol.MakeIntReply(3))
+	assertReply(t, execCmd(server, c, "sintercard", "2", "s1", "s2", "limit", "10"), protocol.MakeIntReply(3))
+	assertReply(t, execCmd(server, c, "sintercard", "2", "s1", "none"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "sintercard", "1", "s1"), protocol.MakeIntReply(4))
+
+	assertErr(t, execCmd(server, c, "sintercard", "0", "s1"), "ERR numkeys should be greater than 0")
+	assertErr(t, execCmd(server, c, "sintercard", "3", "s1", "s2"), "ERR Number of keys can't be greater than number of args")
+	assertErr(t, execCmd(server, c, "sintercard", "2", "s1", "s2", "limit", "-1"), "ERR LIMIT can't be negative")
+	assertErr(t, execCmd(server, c, "sintercard", "2", "s1", "s2", "limit"), "ERR syntax error")
+	assertErr(t, execCmd(server, c, "sintercard", "1", "s1", "s2"), "ERR syntax error")
+}
+
+func TestSMove(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "sadd", "src", "a", "b")
+	execCmd(server, c, "sadd", "dst", "b")
+	assertReply(t, execCmd(server, c, "smove", "src", "dst", "a"), protocol.MakeIntReply(1))
+	assertMembers(t, execCmd(server, c, "smembers", "src"), "b")
+	assertMembers(t, execCmd(server, c, "smembers", "dst"), "a", "b")
+	assertReply(t, execCmd(server, c, "smove", "src", "dst", "none"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "smove", "none", "dst", "a"), protocol.MakeIntReply(0))
+	// moving a member onto the same set is a no-op
+	assertReply(t, execCmd(server, c, "smove", "src", "src", "b"), protocol.MakeIntReply(1))
+	assertMembers(t, execCmd(server, c, "smembers", "src"), "b")
+	// moving a member which is already in the destination just removes it from the source
+	assertReply(t, execCmd(server, c, "smove", "src", "dst", "b"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "exists", "src"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "scard", "dst"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "smove", "dst", "new", "a"), protocol.MakeIntReply(1))
+	assertMembers(t, execCmd(server, c, "smembers", "new"), "a")
+
+	execCmd(server, c, "set", "str", "v")
+	assertErr(t, execCmd(server, c, "smove", "dst", "str", "b"), "WRONGTYPE")
+	assertErr(t, execCmd(server, c, "smove", "dst", "str", "none"), "WRONGTYPE")
+	assertErr(t, execCmd(server, c, "smove", "str", "dst", "b"), "WRONGTYPE")
+}
+
+func TestSPopAndSRandMember(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "sadd", "s", "a", "b", "c")
+
+	// a positive count returns distinct members, at most all of them
+	if got := members(t, execCmd(server, c, "srandmember", "s", "2")); len(got) != 2 || got[0] == got[1] {
+		t.Fatalf("srandmember s 2 returns %q", got)
+	}
+	assertMembers(t, execCmd(server, c, "srandmember", "s", "5"), "a", "b", "c")
+	// a negative count returns exactly -count members which may repeat
+	got := members(t, execCmd(server, c, "srandmember", "s", "-10"))
+	if len(got) != 10 || len(slices.Compact(got)) > 3 {
+		t.Fatalf("srandmember s -10 returns %q", got)
+	}
+	assertReply(t, execCmd(server, c, "srandmember", "s", "0"), protocol.MakeEmptyMultiBulkReply())
+	assertReply(t, execCmd(server, c, "srandmember", "none", "-3"), protocol.MakeEmptyMultiBulkReply())
+	assertReply(t, execCmd(server, c, "srandmember", "none"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "scard", "s"), protocol.MakeIntReply(3))
+
+	assertErr(t, execCmd(server, c, "spop", "s", "-1"), "ERR value is out of range, must be positive")
+	assertReply(t, execCmd(server, c, "spop", "s", "0"), protocol.MakeEmptyMultiBulkReply())
+	popped := members(t, execCmd(server, c, "spop", "s", "2"))
+	rest := execCmd(server, c, "spop", "s", "5")
+	if all := slices.Sorted(slices.Values(append(popped, members(t, rest)...))); !slices.Equal(all, []string{"a", "b", "c"}) {
+		t.Fatalf("popped members are %q", all)
+	}
+	assertReply(t, execCmd(server, c, "exists", "s"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "spop", "s"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "spop", "s", "1"), protocol.MakeEmptyMultiBulkReply())
+}
+
+func TestSetIntsetConversion(t *testing.T) {
+	maxEntries := config.Properties.SetMaxIntsetEntries
+	config.Properties.SetMaxIntsetEntries = 4
+	t.Cleanup(func() { config.Properties.SetMaxIntsetEntries = maxEntries })
+	server := makeTestServer(t)
+	c := connect(server)
+	encoding := func(key string) string {
+		set, _ := server.dbSet[0].getAsSet(key)
+		return set.Encoding()
+	}
+
+	execCmd(server, c, "sadd", "s", "1", "2", "3", "4")
+	if encoding("s") != Set.EncodingIntset {
+		t.Fatalf("a set of %d integers is %s", 4, encoding("s"))
+	}
+	// the set is converted once it has more integers than set-max-intset-entries
+	execCmd(server, c, "sadd", "s", "5")
+	if encoding("s") != Set.EncodingHashtable {
+		t.Fatalf("a set of %d integers is %s", 5, encoding("s"))
+	}
+	execCmd(server, c, "srem", "s", "5", "4")
+	if encoding("s") != Set.EncodingHashtable {
+		t.Fatal("a set is never converted back to intset")
+	}
+	assertMembers(t, execCmd(server, c, "smembers", "s"), "1", "2", "3")
+
+	// a member which is not an integer converts the set as well, so does an integer out of int64
+	execCmd(server, c, "sadd", "str", "1", "a")
+	execCmd(server, c, "sadd", "big", "1", "9223372036854775808")
+	execCmd(server, c, "sadd", "padded", "1", "01")
+	for _, key := range []string{"str", "big", "padded"} {
+		if encoding(key) != Set.EncodingHashtable {
+			t.Fatalf("%s is %s", key, encoding(key))
+		}
+	}
+	assertReply(t, execCmd(server, c, "scard", "padded"), protocol.MakeIntReply(2))
+
+	// the result of set algebra is encoded in the same way
+	for i := 0; i < 4; i++ {
+		execCmd(server, c, "sadd", "i1", strconv.Itoa(i))
+		execCmd(server, c, "sadd", "i2", strconv.Itoa(i+4))
+	}
+	execCmd(server, c, "sunionstore", "u", "i1", "i2")
+	execCmd(server, c, "sinterstore", "i", "i1", "s")
+	if encoding("u") != Set.EncodingHashtable || encoding("i") != Set.EncodingIntset {
+		t.Fatalf("union of 8 integers is %s, intersection of 3 integers is %s", encoding("u"), encoding("i"))
+	}
+}
@@ -0,0 +1,1165 @@
+package database
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/acl"
+	SortedSet "github.com/tonge3199/redis_go/datastruct/sortedset"
+	"github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/utils"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func (db *DB) getAsSortedSet(key string) (*SortedSet.SortedSet, protocol.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	sortedSet, ok := entity.Data.(*SortedSet.SortedSet)
+	if !ok {
+		return nil, protocol.MakeWrongTypeErrReply()
+	}
+	return sortedSet, nil
+}
+
+func (db *DB) getOrInitSortedSet(key string) (sortedSet *SortedSet.SortedSet, inited bool, errReply protocol.ErrorReply) {
+	sortedSet, errReply = db.getAsSortedSet(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if sortedSet == nil {
+		sortedSet = SortedSet.Make()
+		db.PutEntity(key, &database.DataEntity{
+			Data: sortedSet,
+		})
+		inited = true
+	}
+	return sortedSet, inited, nil
+}
+
+var (
+	errNotFloat   = protocol.MakeErrReply("ERR value is not a valid float")
+	errScoreIsNaN = protocol.MakeErrReply("ERR resulting score is not a number (NaN)")
+)
+
+// parseScore parses a score, inf and -inf are allowed but NaN is not
+func parseScore(arg []byte) (float64, bool) {
+	score, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, false
+	}
+	return score, true
+}
+
+func makeScoreReply(score float64) redis.Reply {
+	return protocol.MakeBulkReply([]byte(utils.FormatDouble(score)))
+}
+
+// makeElementsReply returns [member1, member2 ...] or [member1, score1, member2, score2 ...] if withScores
+func makeElementsReply(elements []*SortedSet.Element, withScores bool) redis.Reply {
+	size := len(elements)
+	if withScores {
+		size *= 2
+	}
+	result := make([][]byte, 0, size)
+	for _, element := range elements {
+		result = append(result, []byte(element.Member))
+		if withScores {
+			result = append(result, []byte(utils.FormatDouble(element.Score)))
+		}
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// execZAdd adds members with scores, or updates the scores of existing members
+//
+//	ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member ...]
+//
+// NX only adds new members, XX only updates existing members,
+// GT/LT only updates existing members if the new score is greater/less than the current one.
+// CH makes the reply count changed members as well as added members,
+// INCR makes ZADD act like ZINCRBY and reply the new score
+func execZAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	var nx, xx, gt, lt, ch, incr bool
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break options
+		}
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	if nx && xx {
+		return protocol.MakeErrReply("ERR XX and NX options at the same time are not compatible")
+	}
+	if (gt && lt) || (gt && nx) || (lt && nx) {
+		return protocol.MakeErrReply("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if incr && len(pairs) > 2 {
+		return protocol.MakeErrReply("ERR INCR option supports a single increment-element pair")
+	}
+	elements := make([]*SortedSet.Element, len(pairs)/2)
+	for j := range elements {
+		score, ok := parseScore(pairs[2*j])
+		if !ok {
+			return errNotFloat
+		}
+		elements[j] = &SortedSet.Element{
+			Member: string(pairs[2*j+1]),
+			Score:  score,
+		}
+	}
+
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		if xx {
+			// nothing to update, don't create an empty key
+			if incr {
+				return protocol.MakeNullBulkReply()
+			}
+			return protocol.MakeIntReply(0)
+		}
+		sortedSet, _, _ = db.getOrInitSortedSet(key)
+	}
+
+	added, changed := 0, 0
+	updated := false // for INCR, whether the member was added or updated
+	var score float64
+	for _, element := range elements {
+		score = element.Score
+		current, exists := sortedSet.Get(element.Member)
+		if !exists {
+			if xx {
+				continue
+			}
+			sortedSet.Add(element.Member, score)
+			added++
+			updated = true
+			continue
+		}
+		if nx {
+			continue
+		}
+		if incr {
+			score += current.Score
+			if math.IsNaN(score) {
+				return errScoreIsNaN
+			}
+		}
+		if (gt && score <= current.Score) || (lt && score >= current.Score) {
+			continue
+		}
+		updated = true
+		if score != current.Score {
+			sortedSet.Add(element.Member, score)
+			changed++
+		}
+	}
+
+	if added+changed > 0 {
+		if incr {
+			db.notifyKeyspaceEvent(notifyZSet, "zincr", key)
+		} else {
+			db.notifyKeyspaceEvent(notifyZSet, "zadd", key)
+		}
+	}
+	if incr {
+		if !updated {
+			return protocol.MakeNullBulkReply()
+		}
+		return makeScoreReply(score)
+	}
+	if ch {
+		return protocol.MakeIntReply(int64(added + changed))
+	}
+	return protocol.MakeIntReply(int64(added))
+}
+
+// execZIncrBy increases the score of member, a missing member is added with score increment
+//
+//	ZINCRBY key increment member
+func execZIncrBy(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	increment, ok := parseScore(args[1])
+	if !ok {
+		return errNotFloat
+	}
+	member := string(args[2])
+
+	sortedSet, _, errReply := db.getOrInitSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	score := increment
+	if current, exists := sortedSet.Get(member); exists {
+		score += current.Score
+		if math.IsNaN(score) {
+			return errScoreIsNaN
+		}
+	}
+	sortedSet.Add(member, score)
+	db.notifyKeyspaceEvent(notifyZSet, "zincr", key)
+	return makeScoreReply(score)
+}
+
+// execZRem removes members, the key is deleted once the sorted set becomes empty
+//
+//	ZREM key member [member ...]
+func execZRem(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return protocol.MakeIntReply(0)
+	}
+	removed := 0
+	for _, member := range args[1:] {
+		if sortedSet.Remove(string(member)) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		db.notifyKeyspaceEvent(notifyZSet, "zrem", key)
+	}
+	if sortedSet.Len() == 0 {
+		db.deleteKey(key)
+	}
+	return protocol.MakeIntReply(int64(removed))
+}
+
+// execZScore returns the score of member
+//
+//	ZSCORE key member
+func execZScore(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	element, exists := sortedSet.Get(string(args[1]))
+	if !exists {
+		return protocol.MakeNullBulkReply()
+	}
+	return makeScoreReply(element.Score)
+}
+
+// execZMScore returns the scores of members, nil for missing members
+//
+//	ZMSCORE key member [member ...]
+func execZMScore(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, len(args)-1)
+	if sortedSet != nil {
+		for i, member := range args[1:] {
+			if element, exists := sortedSet.Get(string(member)); exists {
+				result[i] = []byte(utils.FormatDouble(element.Score))
+			}
+		}
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// execZCard returns the number of members
+//
+//	ZCARD key
+func execZCard(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(sortedSet.Len())
+}
+
+// execZRankGeneric returns the 0-based rank of member
+//
+//	ZRANK key member [WITHSCORE]
+func execZRankGeneric(db *DB, args [][]byte, desc bool) redis.Reply {
+	key := string(args[0])
+	member := string(args[1])
+	withScore := false
+	if len(args) == 3 {
+		if strings.ToUpper(string(args[2])) != "WITHSCORE" {
+			return protocol.MakeSyntaxErrReply()
+		}
+		withScore = true
+	} else if len(args) > 3 {
+		return protocol.MakeSyntaxErrReply()
+	}
+
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	var rank int64
+	exists := false
+	if sortedSet != nil {
+		rank, exists = sortedSet.GetRank(member, desc)
+	}
+	if !exists {
+		if withScore {
+			return protocol.MakeNullMultiBulkReply()
+		}
+		return protocol.MakeNullBulkReply()
+	}
+	if !withScore {
+		return protocol.MakeIntReply(rank)
+	}
+	element, _ := sortedSet.Get(member)
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeIntReply(rank),
+		makeScoreReply(element.Score),
+	})
+}
+
+func execZRank(db *DB, args [][]byte) redis.Reply {
+	return execZRankGeneric(db, args, false)
+}
+
+func execZRevRank(db *DB, args [][]byte) redis.Reply {
+	return execZRankGeneric(db, args, true)
+}
+
+/* ---- Range ---- */
+
+// range types of ZRANGE
+const (
+	zrangeAuto = iota // not decided yet, by rank if no BYSCORE or BYLEX is given
+	zrangeByRank
+	zrangeByScore
+	zrangeByLex
+)
+
+// zrangeSpec is the parsed form of `start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]`
+type zrangeSpec struct {
+	by         int
+	rev        bool
+	start      []byte // min for BYSCORE and BYLEX, even if REV is given
+	stop       []byte // max for BYSCORE and BYLEX
+	offset     int64
+	limit      int64 // negative means no limit
+	withScores bool
+}
+
+// parseZRangeSpec parses args of ZRANGE and the legacy range commands.
+// by and rev are preset by the legacy commands like ZREVRANGEBYSCORE, which don't accept BYSCORE, BYLEX or REV,
+// store means the command is ZRANGESTORE which doesn't accept WITHSCORES
+func parseZRangeSpec(args [][]byte, by int, rev bool, store bool) (*zrangeSpec, protocol.ErrorReply) {
+	spec := &zrangeSpec{
+		by:    by,
+		rev:   rev,
+		start: args[0],
+		stop:  args[1],
+		limit: -1,
+	}
+	auto := by == zrangeAuto
+	hasLimit := false
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "WITHSCORES" && !store:
+			spec.withScores = true
+		case opt == "LIMIT" && i+2 < len(args):
+			offset, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return nil, errNotInteger
+			}
+			limit, err := strconv.ParseInt(string(args[i+2]), 10, 64)
+			if err != nil {
+				return nil, errNotInteger
+			}
+			spec.offset, spec.limit = offset, limit
+			hasLimit = true
+			i += 2
+		case opt == "REV" && auto:
+			spec.rev = true
+		case opt == "BYSCORE" && auto && spec.by == zrangeAuto:
+			spec.by = zrangeByScore
+		case opt == "BYLEX" && auto && spec.by == zrangeAuto:
+			spec.by = zrangeByLex
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	if spec.by == zrangeAuto {
+		spec.by = zrangeByRank
+	}
+	if hasLimit && spec.by == zrangeByRank {
+		return nil, protocol.MakeErrReply("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if spec.withScores && spec.by == zrangeByLex {
+		return nil, protocol.MakeErrReply("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	if spec.rev && spec.by != zrangeByRank {
+		// the reversed range is given as `max min`
+		spec.start, spec.stop = spec.stop, spec.start
+	}
+	return spec, nil
+}
+
+// parseBorders parses min and max of BYSCORE or BYLEX
+func parseBorders(min []byte, max []byte, byLex bool) (SortedSet.Border, SortedSet.Border, protocol.ErrorReply) {
+	if byLex {
+		minBorder, err := SortedSet.ParseLexBorder(string(min))
+		if err != nil {
+			return nil, nil, protocol.MakeErrReply(err.Error())
+		}
+		maxBorder, err := SortedSet.ParseLexBorder(string(max))
+		if err != nil {
+			return nil, nil, protocol.MakeErrReply(err.Error())
+		}
+		return minBorder, maxBorder, nil
+	}
+	minBorder, err := SortedSet.ParseScoreBorder(string(min))
+	if err != nil {
+		return nil, nil, protocol.MakeErrReply(err.Error())
+	}
+	maxBorder, err := SortedSet.ParseScoreBorder(string(max))
+	if err != nil {
+		return nil, nil, protocol.MakeErrReply(err.Error())
+	}
+	return minBorder, maxBorder, nil
+}
+
+// zrange returns elements of the sorted set of key in range spec
+func (db *DB) zrange(key string, spec *zrangeSpec) ([]*SortedSet.Element, protocol.ErrorReply) {
+	var start, stop int64
+	var min, max SortedSet.Border
+	if spec.by == zrangeByRank {
+		var err error
+		start, err = strconv.ParseInt(string(spec.start), 10, 64)
+		if err != nil {
+			return nil, errNotInteger
+		}
+		stop, err = strconv.ParseInt(string(spec.stop), 10, 64)
+		if err != nil {
+			return nil, errNotInteger
+		}
+	} else {
+		var errReply protocol.ErrorReply
+		min, max, errReply = parseBorders(spec.start, spec.stop, spec.by == zrangeByLex)
+		if errReply != nil {
+			return nil, errReply
+		}
+	}
+
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil || sortedSet == nil {
+		return nil, errReply
+	}
+	if spec.by == zrangeByRank {
+		from, to := utils.ConvertRange(start, stop, sortedSet.Len())
+		if from < 0 {
+			return nil, nil
+		}
+		return sortedSet.RangeByRank(int64(from), int64(to), spec.rev), nil
+	}
+	if spec.offset < 0 {
+		return nil, nil
+	}
+	return sortedSet.Range(min, max, spec.offset, spec.limit, spec.rev), nil
+}
+
+// makeZRange returns the executor of ZRANGE and the legacy range commands
+//
+//	ZRANGE key start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+//	ZREVRANGE key start stop [WITHSCORES]
+//	ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+//	ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count]
+//	ZRANGEBYLEX key min max [LIMIT offset count]
+//	ZREVRANGEBYLEX key max min [LIMIT offset count]
+func makeZRange(by int, rev bool) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		spec, errReply := parseZRangeSpec(args[1:], by, rev, false)
+		if errReply != nil {
+			return errReply
+		}
+		elements, errReply := db.zrange(string(args[0]), spec)
+		if errReply != nil {
+			return errReply
+		}
+		return makeElementsReply(elements, spec.withScores)
+	}
+}
+
+// execZRangeStore stores the result of ZRANGE into destination
+//
+//	ZRANGESTORE dst src min max [BYSCORE | BYLEX] [REV] [LIMIT offset count]
+func execZRangeStore(db *DB, args [][]byte) redis.Reply {
+	dest := string(args[0])
+	spec, errReply := parseZRangeSpec(args[2:], zrangeAuto, false, true)
+	if errReply != nil {
+		return errReply
+	}
+	elements, errReply := db.zrange(string(args[1]), spec)
+	if errReply != nil {
+		return errReply
+	}
+	result := SortedSet.Make()
+	for _, element := range elements {
+		result.Add(element.Member, element.Score)
+	}
+	return db.storeSortedSet(dest, result, "zrangestore")
+}
+
+// storeSortedSet puts result into dest key and notifies event, an empty result deletes dest
+func (db *DB) storeSortedSet(dest string, result *SortedSet.SortedSet, event string) redis.Reply {
+	if result.Len() == 0 {
+		db.deleteKey(dest)
+	} else {
+		db.PutEntity(dest, &database.DataEntity{
+			Data: result,
+		})
+		db.notifyKeyspaceEvent(notifyZSet, event, dest)
+	}
+	return protocol.MakeIntReply(result.Len())
+}
+
+// makeZCount returns the executor of ZCOUNT and ZLEXCOUNT
+//
+//	ZCOUNT key min max
+//	ZLEXCOUNT key min max
+func makeZCount(byLex bool) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		key := string(args[0])
+		min, max, errReply := parseBorders(args[1], args[2], byLex)
+		if errReply != nil {
+			return errReply
+		}
+		sortedSet, errReply := db.getAsSortedSet(key)
+		if errReply != nil {
+			return errReply
+		}
+		if sortedSet == nil {
+			return protocol.MakeIntReply(0)
+		}
+		return protocol.MakeIntReply(sortedSet.RangeCount(min, max))
+	}
+}
+
+// execZRemRangeByRank removes members within the rank range
+//
+//	ZREMRANGEBYRANK key start stop
+func execZRemRangeByRank(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	start, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	stop, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return protocol.MakeIntReply(0)
+	}
+	from, to := utils.ConvertRange(start, stop, sortedSet.Len())
+	if from < 0 {
+		return protocol.MakeIntReply(0)
+	}
+	removed := sortedSet.RemoveByRank(int64(from), int64(to))
+	if len(removed) > 0 {
+		db.notifyKeyspaceEvent(notifyZSet, "zremrangebyrank", key)
+	}
+	if sortedSet.Len() == 0 {
+		db.deleteKey(key)
+	}
+	return protocol.MakeIntReply(int64(len(removed)))
+}
+
+// makeZRemRange returns the executor of ZREMRANGEBYSCORE and ZREMRANGEBYLEX
+//
+//	ZREMRANGEBYSCORE key min max
+//	ZREMRANGEBYLEX key min max
+func makeZRemRange(byLex bool) ExecFunc {
+	event := "zremrangebyscore"
+	if byLex {
+		event = "zremrangebylex"
+	}
+	return func(db *DB, args [][]byte) redis.Reply {
+		key := string(args[0])
+		min, max, errReply := parseBorders(args[1], args[2], byLex)
+		if errReply != nil {
+			return errReply
+		}
+		sortedSet, errReply := db.getAsSortedSet(key)
+		if errReply != nil {
+			return errReply
+		}
+		if sortedSet == nil {
+			return protocol.MakeIntReply(0)
+		}
+		removed := sortedSet.RemoveRange(min, max)
+		if removed > 0 {
+			db.notifyKeyspaceEvent(notifyZSet, event, key)
+		}
+		if sortedSet.Len() == 0 {
+			db.deleteKey(key)
+		}
+		return protocol.MakeIntReply(removed)
+	}
+}
+
+/* ---- Pop ---- */
+
+// popFromSortedSet removes count elements with the lowest (min) or highest scores,
+// the key is deleted once the sorted set becomes empty
+func (db *DB) popFromSortedSet(key string, sortedSet *SortedSet.SortedSet, min bool, count int64) []*SortedSet.Element {
+	var elements []*SortedSet.Element
+	event := "zpopmax"
+	if min {
+		elements = sortedSet.PopMin(count)
+		event = "zpopmin"
+	} else {
+		elements = sortedSet.PopMax(count)
+	}
+	if len(elements) > 0 {
+		db.notifyKeyspaceEvent(notifyZSet, event, key)
+	}
+	if sortedSet.Len() == 0 {
+		db.deleteKey(key)
+	}
+	return elements
+}
+
+// execZPop removes and returns members with the lowest or highest scores
+//
+//	ZPOPMIN key [count]
+//	ZPOPMAX key [count]
+func execZPop(db *DB, args [][]byte, min bool) redis.Reply {
+	if len(args) > 2 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	key := string(args[0])
+	count := int64(1)
+	if len(args) == 2 {
+		var err error
+		count, err = strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		if count < 0 {
+			return errNotPositive
+		}
+	}
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	return makeElementsReply(db.popFromSortedSet(key, sortedSet, min, count), true)
+}
+
+func execZPopMin(db *DB, args [][]byte) redis.Reply {
+	return execZPop(db, args, true)
+}
+
+func execZPopMax(db *DB, args [][]byte) redis.Reply {
+	return execZPop(db, args, false)
+}
+
+func isSortedSetWhere(where string) bool {
+	return where == "MIN" || where == "MAX"
+}
+
+// zmpop pops elements from the first non-empty sorted set, returns nil if all sorted sets are empty
+func (db *DB) zmpop(args *mpopArgs) redis.Reply {
+	for _, key := range args.keys {
+		sortedSet, errReply := db.getAsSortedSet(key)
+		if errReply != nil {
+			return errReply
+		}
+		if sortedSet == nil {
+			continue
+		}
+		elements := db.popFromSortedSet(key, sortedSet, args.where == "MIN", int64(args.count))
+		replies := make([]redis.Reply, len(elements))
+		for i, element := range elements {
+			replies[i] = protocol.MakeMultiBulkReply([][]byte{
+				[]byte(element.Member),
+				[]byte(utils.FormatDouble(element.Score)),
+			})
+		}
+		return protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte(key)),
+			protocol.MakeMultiRawReply(replies),
+		})
+	}
+	return nil
+}
+
+// execZMPop pops elements from the first non-empty sorted set
+//
+//	ZMPOP numkeys key [key ...] MIN|MAX [COUNT count]
+func execZMPop(db *DB, args [][]byte) redis.Reply {
+	mpop, errReply := parseMPopArgs(args, isSortedSetWhere)
+	if errReply != nil {
+		return errReply
+	}
+	result := db.zmpop(mpop)
+	if result == nil {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	return result
+}
+
+// execBZPop pops an element from the first non-empty sorted set, blocks if all sorted sets are empty
+//
+//	BZPOPMIN key [key ...] timeout
+//	BZPOPMAX key [key ...] timeout
+func execBZPop(db *DB, args [][]byte, min bool) redis.Reply {
+	keys := make([]string, len(args)-1)
+	for i := range keys {
+		keys[i] = string(args[i])
+	}
+	timeout, errReply := parseBlockTimeout(args[len(args)-1])
+	if errReply != nil {
+		return errReply
+	}
+
+	pop := func(key string) redis.Reply {
+		sortedSet, errReply := db.getAsSortedSet(key)
+		if errReply != nil {
+			return errReply
+		}
+		if sortedSet == nil {
+			return nil
+		}
+		element := db.popFromSortedSet(key, sortedSet, min, 1)[0]
+		return protocol.MakeMultiBulkReply([][]byte{
+			[]byte(key),
+			[]byte(element.Member),
+			[]byte(utils.FormatDouble(element.Score)),
+		})
+	}
+	for _, key := range keys {
+		if result := pop(key); result != nil {
+			return result
+		}
+	}
+	return makeWaiter(keys, timeout, protocol.MakeNullMultiBulkReply(), skipWrongType(pop))
+}
+
+func execBZPopMin(db *DB, args [][]byte) redis.Reply {
+	return execBZPop(db, args, true)
+}
+
+func execBZPopMax(db *DB, args [][]byte) redis.Reply {
+	return execBZPop(db, args, false)
+}
+
+// execBZMPop is the blocking version of ZMPOP
+//
+//	BZMPOP timeout numkeys key [key ...] MIN|MAX [COUNT count]
+func execBZMPop(db *DB, args [][]byte) redis.Reply {
+	timeout, errReply := parseBlockTimeout(args[0])
+	if errReply != nil {
+		return errReply
+	}
+	mpop, errReply := parseMPopArgs(args[1:], isSortedSetWhere)
+	if errReply != nil {
+		return errReply
+	}
+	if result := db.zmpop(mpop); result != nil {
+		return result
+	}
+	return makeWaiter(mpop.keys, timeout, protocol.MakeNullMultiBulkReply(), skipWrongType(func(key string) redis.Reply {
+		// only pop from the ready key, the keys before it are still empty
+		return db.zmpop(&mpopArgs{
+			keys:  []string{key},
+			where: mpop.where,
+			count: mpop.count,
+		})
+	}))
+}
+
+/* ---- Set Algebra ---- */
+
+// aggregate functions of ZUNIONSTORE and ZINTERSTORE
+const (
+	aggregateSum = iota
+	aggregateMin
+	aggregateMax
+)
+
+// zsetOpSpec is the parsed form of `numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]`
+type zsetOpSpec struct {
+	keys       []string
+	weights    []float64
+	aggregate  int
+	withScores bool
+}
+
+// parseZSetOpSpec parses args of ZUNION, ZINTER and ZDIFF family, cmd is used in error messages.
+// ZDIFF doesn't accept WEIGHTS or AGGREGATE, the STORE variants don't accept WITHSCORES
+func parseZSetOpSpec(cmd string, args [][]byte, allowWeights bool, store bool) (*zsetOpSpec, protocol.ErrorReply) {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, errNotInteger
+	}
+	if numKeys < 1 {
+		return nil, protocol.MakeErrReply("ERR at least 1 input key is needed for '" + cmd + "' command")
+	}
+	if numKeys > int64(len(args)-1) {
+		return nil, protocol.MakeSyntaxErrReply()
+	}
+	spec := &zsetOpSpec{
+		keys:    make([]string, numKeys),
+		weights: make([]float64, numKeys),
+	}
+	for i := range spec.keys {
+		spec.keys[i] = string(args[i+1])
+		spec.weights[i] = 1
+	}
+	for i := int(numKeys) + 1; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "WEIGHTS" && allowWeights && i+int(numKeys) < len(args):
+			for j := range spec.weights {
+				weight, ok := parseScore(args[i+1+j])
+				if !ok {
+					return nil, protocol.MakeErrReply("ERR weight value is not a float")
+				}
+				spec.weights[j] = weight
+			}
+			i += int(numKeys)
+		case opt == "AGGREGATE" && allowWeights && i+1 < len(args):
+			switch strings.ToUpper(string(args[i+1])) {
+			case "SUM":
+				spec.aggregate = aggregateSum
+			case "MIN":
+				spec.aggregate = aggregateMin
+			case "MAX":
+				spec.aggregate = aggregateMax
+			default:
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			i++
+		case opt == "WITHSCORES" && !store:
+			spec.withScores = true
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	return spec, nil
+}
+
+// getZSetOpInputs returns the inputs of ZUNION family, a missing key is represented by nil.
+// A set is accepted as a sorted set whose scores are all 1, the same as redis
+func (db *DB) getZSetOpInputs(keys []string) ([]*SortedSet.SortedSet, protocol.ErrorReply) {
+	inputs := make([]*SortedSet.SortedSet, len(keys))
+	for i, key := range keys {
+		sortedSet, errReply := db.getAsSortedSet(key)
+		if errReply == nil {
+			inputs[i] = sortedSet
+			continue
+		}
+		set, errReply := db.getAsSet(key)
+		if errReply != nil {
+			return nil, errReply
+		}
+		sortedSet = SortedSet.Make()
+		set.ForEach(func(member string) bool {
+			sortedSet.Add(member, 1)
+			return true
+		})
+		inputs[i] = sortedSet
+	}
+	return inputs, nil
+}
+
+// weightedScore multiplies score by weight, NaN (e.g. inf * 0) is treated as 0
+func weightedScore(score float64, weight float64) float64 {
+	result := score * weight
+	if math.IsNaN(result) {
+		return 0
+	}
+	return result
+}
+
+func aggregateScore(aggregate int, acc float64, score float64) float64 {
+	switch aggregate {
+	case aggregateMin:
+		return math.Min(acc, score)
+	case aggregateMax:
+		return math.Max(acc, score)
+	}
+	result := acc + score
+	if math.IsNaN(result) { // inf + -inf
+		return 0
+	}
+	return result
+}
+
+func forEachElement(sortedSet *SortedSet.SortedSet, consumer func(element *SortedSet.Element) bool) {
+	sortedSet.ForEachByRank(0, sortedSet.Len(), false, consumer)
+}
+
+// zunion returns the union of inputs
+func zunion(inputs []*SortedSet.SortedSet, spec *zsetOpSpec) *SortedSet.SortedSet {
+	result := SortedSet.Make()
+	scores := make(map[string]float64)
+	for i, input := range inputs {
+		if input == nil {
+			continue
+		}
+		forEachElement(input, func(element *SortedSet.Element) bool {
+			score := weightedScore(element.Score, spec.weights[i])
+			if acc, ok := scores[element.Member]; ok {
+				score = aggregateScore(spec.aggregate, acc, score)
+			}
+			scores[element.Member] = score
+			return true
+		})
+	}
+	for member, score := range scores {
+		result.Add(member, score)
+	}
+	return result
+}
+
+// zinter returns the intersection of inputs
+func zinter(inputs []*SortedSet.SortedSet, spec *zsetOpSpec) *SortedSet.SortedSet {
+	result := SortedSet.Make()
+	smallest := -1
+	for i, input := range inputs {
+		if input == nil {
+			return result
+		}
+		if smallest < 0 || input.Len() < inputs[smallest].Len() {
+			smallest = i
+		}
+	}
+	forEachElement(inputs[smallest], func(element *SortedSet.Element) bool {
+		var score float64
+		for i, input := range inputs {
+			other, ok := input.Get(element.Member)
+			if !ok {
+				return true
+			}
+			weighted := weightedScore(other.Score, spec.weights[i])
+			if i == 0 {
+				score = weighted
+			} else {
+				score = aggregateScore(spec.aggregate, score, weighted)
+			}
+		}
+		result.Add(element.Member, score)
+		return true
+	})
+	return result
+}
+
+// zdiff returns members of the first input which are not in any of the others
+func zdiff(inputs []*SortedSet.SortedSet, spec *zsetOpSpec) *SortedSet.SortedSet {
+	result := SortedSet.Make()
+	if inputs[0] == nil {
+		return result
+	}
+	forEachElement(inputs[0], func(element *SortedSet.Element) bool {
+		for _, input := range inputs[1:] {
+			if input == nil {
+				continue
+			}
+			if _, ok := input.Get(element.Member); ok {
+				return true
+			}
+		}
+		result.Add(element.Member, element.Score)
+		return true
+	})
+	return result
+}
+
+// makeZSetOp returns the executor of ZUNION, ZINTER, ZDIFF and their STORE variants
+//
+//	ZUNION numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+//	ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]
+//	ZDIFF numkeys key [key ...] [WITHSCORES]
+//	ZDIFFSTORE destination numkeys key [key ...]
+//
+// All inputs are read and the destination is written in one executor with all keys locked, so the operation is atomic
+func makeZSetOp(cmd string, operation func([]*SortedSet.SortedSet, *zsetOpSpec) *SortedSet.SortedSet,
+	allowWeights bool, store bool) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		opArgs := args
+		if store {
+			opArgs = args[1:]
+		}
+		spec, errReply := parseZSetOpSpec(cmd, opArgs, allowWeights, store)
+		if errReply != nil {
+			return errReply
+		}
+		inputs, errReply := db.getZSetOpInputs(spec.keys)
+		if errReply != nil {
+			return errReply
+		}
+		result := operation(inputs, spec)
+		if store {
+			return db.storeSortedSet(string(args[0]), result, cmd)
+		}
+		return makeElementsReply(result.RangeByRank(0, result.Len(), false), spec.withScores)
+	}
+}
+
+// execZInterCard returns the cardinality of the intersection
+//
+//	ZINTERCARD numkeys key [key ...] [LIMIT limit]
+func execZInterCard(db *DB, args [][]byte) redis.Reply {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	if numKeys <= 0 {
+		return protocol.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-1) {
+		return protocol.MakeErrReply("ERR Number of keys can't be greater than number of args")
+	}
+	limit := int64(0)
+	rest := args[numKeys+1:]
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToUpper(string(rest[0])) != "LIMIT" {
+			return protocol.MakeSyntaxErrReply()
+		}
+		limit, err = strconv.ParseInt(string(rest[1]), 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		if limit < 0 {
+			return protocol.MakeErrReply("ERR LIMIT can't be negative")
+		}
+	}
+
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = string(args[i+1])
+	}
+	inputs, errReply := db.getZSetOpInputs(keys)
+	if errReply != nil {
+		return errReply
+	}
+	for _, input := range inputs {
+		if input == nil {
+			return protocol.MakeIntReply(0)
+		}
+	}
+	sort.Slice(inputs, func(i, j int) bool {
+		return inputs[i].Len() < inputs[j].Len()
+	})
+	count := int64(0)
+	forEachElement(inputs[0], func(element *SortedSet.Element) bool {
+		for _, input := range inputs[1:] {
+			if _, ok := input.Get(element.Member); !ok {
+				return true
+			}
+		}
+		count++
+		return limit == 0 || count < limit
+	})
+	return protocol.MakeIntReply(count)
+}
+
+// execZScan iterates members of sorted set
+//
+//	ZSCAN key cursor [MATCH pattern] [COUNT count]
+func execZScan(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	scan, errReply := parseScanArgs("zscan", args[1:])
+	if errReply != nil {
+		return errReply
+	}
+
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, 0)
+	if sortedSet == nil {
+		return makeScanReply(0, result)
+	}
+	cursor := sortedSet.Scan(scan.cursor, scan.count, func(element *SortedSet.Element) {
+		if scan.match(element.Member) {
+			result = append(result, []byte(element.Member), []byte(utils.FormatDouble(element.Score)))
+		}
+	})
+	return makeScanReply(cursor, result)
+}
+
+func init() {
+	registerCommand("ZAdd", execZAdd, -4, flagWrite, acl.CategoryWrite|acl.CategorySortedSet)
+	registerCommand("ZIncrBy", execZIncrBy, 4, flagWrite, acl.CategoryWrite|acl.CategorySortedSet).setAccessWritten()
+	registerCommand("ZRem", execZRem, -3, flagWrite, acl.CategoryWrite|acl.CategorySortedSet)
+	registerCommand("ZScore", execZScore, 3, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZMScore", execZMScore, -3, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZCard", execZCard, 2, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZRank", execZRank, -3, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZRevRank", execZRevRank, -3, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZRange", makeZRange(zrangeAuto, false), -4, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZRevRange", makeZRange(zrangeByRank, true), -4, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZRangeByScore", makeZRange(zrangeByScore, false), -4, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZRevRangeByScore", makeZRange(zrangeByScore, true), -4, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZRangeByLex", makeZRange(zrangeByLex, false), -4, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZRevRangeByLex", makeZRange(zrangeByLex, true), -4, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZRangeStore", execZRangeStore, -5, flagWrite, acl.CategoryWrite|acl.CategorySortedSet).setKeysFunc(writeFirstReadSecond)
+	registerCommand("ZCount", makeZCount(false), 4, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZLexCount", makeZCount(true), 4, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+	registerCommand("ZRemRangeByRank", execZRemRangeByRank, 4, flagWrite, acl.CategoryWrite|acl.CategorySortedSet)
+	registerCommand("ZRemRangeByScore", makeZRemRange(false), 4, flagWrite, acl.CategoryWrite|acl.CategorySortedSet)
+	registerCommand("ZRemRangeByLex", makeZRemRange(true), 4, flagWrite, acl.CategoryWrite|acl.CategorySortedSet)
+	registerCommand("ZPopMin", execZPopMin, -2, flagWrite, acl.CategoryWrite|acl.CategorySortedSet).setAccessWritten()
+	registerCommand("ZPopMax", execZPopMax, -2, flagWrite, acl.CategoryWrite|acl.CategorySortedSet).setAccessWritten()
+	registerCommand("ZMPop", execZMPop, -4, flagWrite, acl.CategoryWrite|acl.CategorySortedSet).setKeysFunc(numKeysFunc(0, false, true)).setAccessWritten()
+	registerCommand("BZPopMin", execBZPopMin, -3, flagWrite, acl.CategoryWrite|acl.CategorySortedSet|acl.CategoryBlocking).setKeys(1, -2, 1).setAccessWritten()
+	registerCommand("BZPopMax", execBZPopMax, -3, flagWrite, acl.CategoryWrite|acl.CategorySortedSet|acl.CategoryBlocking).setKeys(1, -2, 1).setAccessWritten()
+	registerCommand("BZMPop", execBZMPop, -5, flagWrite, acl.CategoryWrite|acl.CategorySortedSet|acl.CategoryBlocking).setKeysFunc(numKeysFunc(1, false, true)).setAccessWritten()
+	registerCommand("ZUnion", makeZSetOp("zunion", zunion, true, false), -3, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet).setKeysFunc(numKeysFunc(0, false, false))
+	registerCommand("ZUnionStore", makeZSetOp("zunionstore", zunion, true, true), -4, flagWrite, acl.CategoryWrite|acl.CategorySortedSet).setKeysFunc(numKeysFunc(1, true, true))
+	registerCommand("ZInter", makeZSetOp("zinter", zinter, true, false), -3, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet).setKeysFunc(numKeysFunc(0, false, false))
+	registerCommand("ZInterStore", makeZSetOp("zinterstore", zinter, true, true), -4, flagWrite, acl.CategoryWrite|acl.CategorySortedSet).setKeysFunc(numKeysFunc(1, true, true))
+	registerCommand("ZDiff", makeZSetOp("zdiff", zdiff, false, false), -3, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet).setKeysFunc(numKeysFunc(0, false, false))
+	registerCommand("ZDiffStore", makeZSetOp("zdiffstore", zdiff, false, true), -4, flagWrite, acl.CategoryWrite|acl.CategorySortedSet).setKeysFunc(numKeysFunc(1, true, true))
+	registerCommand("ZInterCard", execZInterCard, -3, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet).setKeysFunc(numKeysFunc(0, false, false))
+	registerCommand("ZScan", execZScan, -3, flagReadOnly, acl.CategoryRead|acl.CategorySortedSet)
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func TestZAddOptions(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertErr(t, execCmd(server, c, "zadd", "z", "nx", "xx", "1", "a"), "ERR XX and NX options at the same time are not compatible")
+	assertErr(t, execCmd(server, c, "zadd", "z", "nx", "gt", "1", "a"), "ERR GT, LT, and/or NX options at the same time are not compatible")
+	assertErr(t, execCmd(server, c, "zadd", "z", "gt", "lt", "1", "a"), "ERR GT, LT, and/or NX options at the same time are not compatible")
+	assertErr(t, execCmd(server, c, "zadd", "z", "incr", "1", "a", "2", "b"), "ERR INCR option supports a single increment-element pair")
+	assertErr(t, execCmd(server, c, "zadd", "z", "1", "a", "2"), "ERR syntax error")
+	assertErr(t, execCmd(server, c, "zadd", "z", "one", "a"), "ERR value is not a valid float")
+	assertErr(t, execCmd(server, c, "zadd", "z", "nan", "a"), "ERR value is not a valid float")
+	assertReply(t, execCmd(server, c, "exists", "z"), protocol.MakeIntReply(0))
+
+	assertReply(t, execCmd(server, c, "zadd", "z", "xx", "1", "a"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "exists", "z"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "zadd", "z", "1", "a", "2", "b"), protocol.MakeIntReply(2))
+	// NX only adds new members, XX only updates existing ones
+	assertReply(t, execCmd(server, c, "zadd", "z", "nx", "5", "a", "3", "c"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "zadd", "z", "xx", "5", "a", "4", "d"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "zrange", "z", "0", "-1", "withscores"), bulks("b", "2", "c", "3", "a", "5"))
+
+	// GT and LT update existing members only to a greater or a lower score, but still add new members
+	assertReply(t, execCmd(server, c, "zadd", "z", "gt", "ch", "4", "a", "6", "b", "1", "e"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "zadd", "z", "lt", "ch", "9", "a", "1", "c"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "zadd", "z", "xx", "gt", "ch", "1", "a", "8", "b", "1", "f"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "zrange", "z", "0", "-1", "withscores"), bulks("c", "1", "e", "1", "a", "5", "b", "8"))
+	// CH counts changed members besides added ones, setting the same score is not a change
+	assertReply(t, execCmd(server, c, "zadd", "z", "ch", "1", "c", "2", "e", "1", "g"), protocol.MakeIntReply(2))
+
+	// INCR replies the new score, or nil if the member is not updated because of the options
+	assertReply(t, execCmd(server, c, "zadd", "z", "incr", "2.5", "a"), protocol.MakeBulkReply([]byte("7.5")))
+	assertReply(t, execCmd(server, c, "zadd", "z", "nx", "incr", "1", "a"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "zadd", "z", "xx", "incr", "1", "new"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "zadd", "z", "gt", "incr", "-1", "a"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "zadd", "z", "lt", "incr", "-1", "a"), protocol.MakeBulkReply([]byte("6.5")))
+	assertReply(t, execCmd(server, c, "zadd", "z", "incr", "1", "new"), protocol.MakeBulkReply([]byte("1")))
+	execCmd(server, c, "zadd", "inf", "inf", "a")
+	assertErr(t, execCmd(server, c, "zadd", "inf", "incr", "-inf", "a"), "ERR resulting score is not a number (NaN)")
+	assertReply(t, execCmd(server, c, "zscore", "inf", "a"), protocol.MakeBulkReply([]byte("inf")))
+}
+
+func TestZScoreFormat(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "zadd", "z", "2.1", "a", "1e300", "b", "-inf", "c", "+inf", "d", "3.0", "e", "0.1", "f")
+	execCmd(server, c, "zincrby", "z", "0.2", "f")
+	assertReply(t, execCmd(server, c, "zmscore", "z", "a", "b", "c", "d", "e", "f", "none"),
+		protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte("2.1")),
+			protocol.MakeBulkReply([]byte("1e+300")),
+			protocol.MakeBulkReply([]byte("-inf")),
+			protocol.MakeBulkReply([]byte("inf")),
+			protocol.MakeBulkReply([]byte("3")),
+			protocol.MakeBulkReply([]byte("0.30000000000000004")),
+			protocol.MakeNullBulkReply(),
+		}))
+	assertReply(t, execCmd(server, c, "zincrby", "z", "1e300", "b"), protocol.MakeBulkReply([]byte("2e+300")))
+}
+
+func TestZRangeBy(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "zadd", "z", "1", "a", "2", "b", "3", "c", "4", "d", "5", "e")
+	assertReply(t, execCmd(server, c, "zrange", "z", "2", "4", "byscore"), bulks("b", "c", "d"))
+	assertReply(t, execCmd(server, c, "zrange", "z", "(2", "(4", "byscore"), bulks("c"))
+	assertReply(t, execCmd(server, c, "zrange", "z", "-inf", "(3", "byscore", "withscores"), bulks("a", "1", "b", "2"))
+	assertReply(t, execCmd(server, c, "zrange", "z", "(4", "+inf", "byscore"), bulks("e"))
+	assertReply(t, execCmd(server, c, "zrange", "z", "+inf", "-inf", "byscore", "rev"), bulks("e", "d", "c", "b", "a"))
+	assertReply(t, execCmd(server, c, "zrange", "z", "(5", "(1", "byscore", "rev", "limit", "1", "2"), bulks("c", "b"))
+	assertReply(t, execCmd(server, c, "zrange", "z", "-inf", "+inf", "byscore", "limit", "3", "-1"), bulks("d", "e"))
+	assertReply(t, execCmd(server, c, "zrange", "z", "-inf", "+inf", "byscore", "limit", "9", "1"), protocol.MakeEmptyMultiBulkReply())
+	assertReply(t, execCmd(server, c, "zrange", "z", "3", "1", "byscore"), protocol.MakeEmptyMultiBulkReply())
+	assertReply(t, execCmd(server, c, "zrange", "z", "-2", "-1", "rev"), bulks("b", "a"))
+	assertReply(t, execCmd(server, c, "zrangebyscore", "z", "(1", "3", "withscores", "limit", "1", "1"), bulks("c", "3"))
+	assertReply(t, execCmd(server, c, "zrevrangebyscore", "z", "3", "(1"), bulks("c", "b"))
+	assertReply(t, execCmd(server, c, "zcount", "z", "(1", "+inf"), protocol.MakeIntReply(4))
+	assertErr(t, execCmd(server, c, "zrange", "z", "x", "2", "byscore"), "ERR min or max is not a float")
+	assertErr(t, execCmd(server, c, "zrange", "z", "0", "1", "limit", "0", "1"), "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+
+	execCmd(server, c, "zadd", "lex", "0", "a", "0", "b", "0", "c", "0", "d")
+	assertReply(t, execCmd(server, c, "zrange", "lex", "[b", "(d", "bylex"), bulks("b", "c"))
+	assertReply(t, execCmd(server, c, "zrange", "lex", "-", "+", "bylex", "limit", "1", "2"), bulks("b", "c"))
+	assertReply(t, execCmd(server, c, "zrange", "lex", "+", "(b", "bylex", "rev"), bulks("d", "c"))
+	assertReply(t, execCmd(server, c, "zrangebylex", "lex", "(a", "[c"), bulks("b", "c"))
+	assertReply(t, execCmd(server, c, "zlexcount", "lex", "[b", "+"), protocol.MakeIntReply(3))
+	assertErr(t, execCmd(server, c, "zrange", "lex", "b", "d", "bylex"), "ERR min or max not valid string range item")
+	assertErr(t, execCmd(server, c, "zrange", "lex", "-", "+", "bylex", "withscores"), "ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+}
+
+func TestZRangeStore(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "zadd", "z", "1", "a", "2", "b", "3", "c")
+	assertReply(t, execCmd(server, c, "zrangestore", "dst", "z", "(1", "+inf", "byscore"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "zrange", "dst", "0", "-1", "withscores"), bulks("b", "2", "c", "3"))
+	assertReply(t, execCmd(server, c, "zrangestore", "dst", "z", "0", "0", "rev"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "zrange", "dst", "0", "-1", "withscores"), bulks("c", "3"))
+	// an empty result deletes the destination
+	assertReply(t, execCmd(server, c, "zrangestore", "dst", "z", "5", "9", "byscore"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "exists", "dst"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "zrangestore", "dst", "none", "0", "-1"), protocol.MakeIntReply(0))
+	// the source may be the destination
+	assertReply(t, execCmd(server, c, "zrangestore", "z", "z", "1", "-1"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "zrange", "z", "0", "-1"), bulks("b", "c"))
+	assertErr(t, execCmd(server, c, "zrangestore", "dst", "z", "0", "-1", "withscores"), "ERR syntax error")
+}
+
+func TestZUnionInterStore(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "zadd", "z1", "1", "a", "2", "b", "3", "c")
+	execCmd(server, c, "zadd", "z2", "10", "b", "20", "c", "30", "d")
+
+	assertReply(t, execCmd(server, c, "zunionstore", "dst", "2", "z1", "z2"), protocol.MakeIntReply(4))
+	assertReply(t, execCmd(server, c, "zrange", "dst", "0", "-1", "withscores"), bulks("a", "1", "b", "12", "c", "23", "d", "30"))
+	assertReply(t, execCmd(server, c, "zunionstore", "dst", "2", "z1", "z2", "weights", "2", "0.5"), protocol.MakeIntReply(4))
+	assertReply(t, execCmd(server, c, "zrange", "dst", "0", "-1", "withscores"), bulks("a", "2", "b", "9", "d", "15", "c", "16"))
+	assertReply(t, execCmd(server, c, "zunionstore", "dst", "2", "z1", "z2", "aggregate", "max"), protocol.MakeIntReply(4))
+	assertReply(t, execCmd(server, c, "zrange", "dst", "0", "-1", "withscores"), bulks("a", "1", "b", "10", "c", "20", "d", "30"))
+	assertReply(t, execCmd(server, c, "zunionstore", "dst", "3", "z1", "z2", "none", "weights", "1", "-1", "5", "aggregate", "min"), protocol.MakeIntReply(4))
+	assertReply(t, execCmd(server, c, "zrange", "dst", "0", "-1", "withscores"), bulks("d", "-30", "c", "-20", "b", "-10", "a", "1"))
+
+	assertReply(t, execCmd(server, c, "zinterstore", "dst", "2", "z1", "z2"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "zrange", "dst", "0", "-1", "withscores"), bulks("b", "12", "c", "23"))
+	assertReply(t, execCmd(server, c, "zinterstore", "dst", "2", "z1", "z2", "weights", "3", "1", "aggregate", "min"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "zrange", "dst", "0", "-1", "withscores"), bulks("b", "6", "c", "9"))
+	// a plain set is a sorted set whose scores are 1
+	execCmd(server, c, "sadd", "s", "a", "b")
+	assertReply(t, execCmd(server, c, "zinterstore", "dst", "2", "z1", "s", "aggregate", "sum"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "zrange", "dst", "0", "-1", "withscores"), bulks("a", "2", "b", "3"))
+	// an empty result deletes the destination, which may be a source as well
+	assertReply(t, execCmd(server, c, "zinterstore", "dst", "2", "z1", "none"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "exists", "dst"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "zunionstore", "z1", "2", "z1", "z2", "aggregate", "max"), protocol.MakeIntReply(4))
+	assertReply(t, execCmd(server, c, "zrange", "z1", "0", "-1"), bulks("a", "b", "c", "d"))
+	// inf * 0 is 0 in a weighted sum, not NaN
+	execCmd(server, c, "zadd", "inf", "inf", "a")
+	assertReply(t, execCmd(server, c, "zunionstore", "dst", "1", "inf", "weights", "0"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "zscore", "dst", "a"), protocol.MakeBulkReply([]byte("0")))
+
+	assertErr(t, execCmd(server, c, "zunionstore", "dst", "0", "z1"), "ERR at least 1 input key is needed for 'zunionstore' command")
+	assertErr(t, execCmd(server, c, "zunionstore", "dst", "2", "z1", "z2", "weights", "1"), "ERR syntax error")
+	assertErr(t, execCmd(server, c, "zunionstore", "dst", "2", "z1", "z2", "weights", "1", "x"), "ERR weight value is not a float")
+	assertErr(t, execCmd(server, c, "zunionstore", "dst", "2", "z1", "z2", "aggregate", "avg"), "ERR syntax error")
+	assertErr(t, execCmd(server, c, "zunionstore", "dst", "1", "z1", "withscores"), "ERR syntax error")
+	execCmd(server, c, "set", "str", "v")
+	assertErr(t, execCmd(server, c, "zunionstore", "dst", "2", "z1", "str"), "WRONGTYPE")
+}
+
+func TestZMPop(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	pops := func(key string, pairs ...string) redis.Reply {
+		elements := make([]redis.Reply, 0, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			elements = append(elements, bulks(pairs[i], pairs[i+1]))
+		}
+		return protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte(key)),
+			protocol.MakeMultiRawReply(elements),
+		})
+	}
+	assertReply(t, execCmd(server, c, "zmpop", "2", "z1", "z2", "min"), protocol.MakeNullMultiBulkReply())
+	execCmd(server, c, "zadd", "z2", "1", "a", "2", "b", "3", "c")
+	// the first non-empty key is popped
+	assertReply(t, execCmd(server, c, "zmpop", "2", "z1", "z2", "min"), pops("z2", "a", "1"))
+	assertReply(t, execCmd(server, c, "zmpop", "2", "z1", "z2", "max", "count", "5"), pops("z2", "c", "3", "b", "2"))
+	assertReply(t, execCmd(server, c, "exists", "z2"), protocol.MakeIntReply(0))
+	assertErr(t, execCmd(server, c, "zmpop", "0", "z1", "min"), "ERR numkeys should be greater than 0")
+	assertErr(t, execCmd(server, c, "zmpop", "1", "z1", "middle"), "ERR syntax error")
+	assertErr(t, execCmd(server, c, "zmpop", "1", "z1", "min", "count", "0"), "ERR count should be greater than 0")
+
+	w := block(t, server, connect(server), "bzmpop", "0", "2", "z1", "z2", "max", "count", "2")
+	execCmd(server, c, "zadd", "z2", "1", "x", "2", "y", "3", "z")
+	assertReply(t, servedReply(t, w), pops("z2", "z", "3", "y", "2"))
+	assertReply(t, execCmd(server, c, "bzmpop", "0", "1", "z2", "min"), pops("z2", "x", "1"))
+	assertErr(t, execCmd(server, c, "bzmpop", "-1", "1", "z2", "min"), "ERR timeout is negative")
+}
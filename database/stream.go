@@ -0,0 +1,692 @@
+package database
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/datastruct/stream"
+	"github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// unlike other containers, an empty stream is not removed, because it still holds the last ID and consumer groups
+
+func (db *DB) getAsStream(key string) (*stream.Stream, protocol.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	s, ok := entity.Data.(*stream.Stream)
+	if !ok {
+		return nil, protocol.MakeWrongTypeErrReply()
+	}
+	return s, nil
+}
+
+func makeStream() *stream.Stream {
+	return stream.Make(config.Properties.StreamNodeMaxBytes, config.Properties.StreamNodeMaxEntries)
+}
+
+var (
+	errInvalidStreamID = protocol.MakeErrReply("ERR Invalid stream ID specified as stream command argument")
+	errXAddIDTooSmall  = protocol.MakeErrReply("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+)
+
+// parseStreamID parses "<ms>-<seq>" or "<ms>", the sequence number of the latter is defaultSeq
+func parseStreamID(arg []byte, defaultSeq uint64) (stream.ID, protocol.ErrorReply) {
+	id, ok := stream.ParseID(string(arg), defaultSeq)
+	if !ok {
+		return stream.ID{}, errInvalidStreamID
+	}
+	return id, nil
+}
+
+// parseStreamIDs parses a list of IDs
+func parseStreamIDs(args [][]byte) ([]stream.ID, protocol.ErrorReply) {
+	ids := make([]stream.ID, len(args))
+	for i, arg := range args {
+		id, errReply := parseStreamID(arg, 0)
+		if errReply != nil {
+			return nil, errReply
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// parseRangeID parses an ID of an interval: "-" and "+" are the smallest and the greatest ID,
+// an incomplete ID "<ms>" has defaultSeq, and "(" prefix means exclusive
+func parseRangeID(arg []byte, isStart bool) (stream.ID, protocol.ErrorReply) {
+	switch string(arg) {
+	case "-":
+		return stream.ID{}, nil
+	case "+":
+		return stream.MaxID, nil
+	}
+	defaultSeq := uint64(0)
+	if !isStart {
+		defaultSeq = math.MaxUint64
+	}
+	if len(arg) > 1 && arg[0] == '(' {
+		id, errReply := parseStreamID(arg[1:], defaultSeq)
+		if errReply != nil {
+			return id, errReply
+		}
+		var ok bool
+		if isStart {
+			if id, ok = id.Incr(); !ok {
+				return id, protocol.MakeErrReply("ERR invalid start ID for the interval")
+			}
+		} else if id, ok = id.Decr(); !ok {
+			return id, protocol.MakeErrReply("ERR invalid end ID for the interval")
+		}
+		return id, nil
+	}
+	return parseStreamID(arg, defaultSeq)
+}
+
+func makeStreamIDReply(id stream.ID) redis.Reply {
+	return protocol.MakeBulkReply([]byte(id.String()))
+}
+
+// makeEntryReply returns [id, [field1, value1 ...]], a deleted entry in PEL has nil fields
+func makeEntryReply(entry *stream.Entry) redis.Reply {
+	var fields redis.Reply = protocol.MakeNullMultiBulkReply()
+	if entry.Fields != nil {
+		fields = protocol.MakeMultiBulkReply(entry.Fields)
+	}
+	return protocol.MakeMultiRawReply([]redis.Reply{makeStreamIDReply(entry.ID), fields})
+}
+
+func makeEntriesReply(entries []*stream.Entry) redis.Reply {
+	result := make([]redis.Reply, len(entries))
+	for i, entry := range entries {
+		result[i] = makeEntryReply(entry)
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// streamTrimSpec is the trimming clause of XADD and XTRIM
+type streamTrimSpec struct {
+	stream.TrimArgs
+	given bool // whether MAXLEN or MINID is given
+}
+
+// parseStreamTrimOption parses one option of the trimming clause at args[i],
+// returns the number of consumed arguments, or 0 if args[i] is not a trimming option
+func parseStreamTrimOption(args [][]byte, i int, spec *streamTrimSpec, limitGiven *bool) (int, protocol.ErrorReply) {
+	opt := strings.ToUpper(string(args[i]))
+	moreArgs := len(args) - i - 1
+	switch {
+	case (opt == "MAXLEN" || opt == "MINID") && moreArgs >= 1:
+		consumed := 2
+		spec.Approx = false
+		switch string(args[i+1]) {
+		case "~":
+			spec.Approx = true
+			consumed++
+		case "=":
+			consumed++
+		}
+		if i+consumed > len(args) {
+			return 0, protocol.MakeSyntaxErrReply()
+		}
+		threshold := args[i+consumed-1]
+		if opt == "MAXLEN" {
+			maxLen, err := strconv.ParseInt(string(threshold), 10, 64)
+			if err != nil {
+				return 0, errNotInteger
+			}
+			if maxLen < 0 {
+				return 0, protocol.MakeErrReply("ERR The MAXLEN argument must be >= 0.")
+			}
+			spec.Strategy = stream.TrimByMaxLen
+			spec.MaxLen = maxLen
+		} else {
+			minID, errReply := parseStreamID(threshold, 0)
+			if errReply != nil {
+				return 0, errReply
+			}
+			spec.Strategy = stream.TrimByMinID
+			spec.MinID = minID
+		}
+		spec.given = true
+		return consumed, nil
+	case opt == "LIMIT" && moreArgs >= 1:
+		limit, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil {
+			return 0, errNotInteger
+		}
+		if limit < 0 {
+			return 0, protocol.MakeErrReply("ERR The LIMIT argument must be >= 0.")
+		}
+		spec.Limit = limit
+		*limitGiven = true
+		return 2, nil
+	}
+	return 0, nil
+}
+
+// checkStreamTrimSpec validates the trimming clause after all options are parsed
+func checkStreamTrimSpec(spec *streamTrimSpec, limitGiven bool) protocol.ErrorReply {
+	if limitGiven && !spec.given {
+		return protocol.MakeErrReply("ERR syntax error, LIMIT cannot be used without specifying a trimming strategy")
+	}
+	if limitGiven {
+		if !spec.Approx {
+			return protocol.MakeErrReply("ERR syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		return nil
+	}
+	if spec.Approx {
+		// limit the work of approximate trimming, exact trimming has no limit
+		spec.Limit = 100 * int64(config.Properties.StreamNodeMaxEntries)
+		if spec.Limit <= 0 || spec.Limit > 1000000 {
+			spec.Limit = 10000
+		}
+	}
+	return nil
+}
+
+// execXAdd appends an entry to stream, creating the stream if it doesn't exist
+//
+//	XADD key [NOMKSTREAM] [<MAXLEN | MINID> [= | ~] threshold [LIMIT count]] <* | id> field value [field value ...]
+//
+// id is "*" to be generated automatically, "<ms>-*" to generate the sequence number only, or an explicit ID
+func execXAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	noMkStream := false
+	spec := &streamTrimSpec{}
+	limitGiven := false
+	i := 1
+	for ; i < len(args); i++ {
+		if strings.ToUpper(string(args[i])) == "NOMKSTREAM" {
+			noMkStream = true
+			continue
+		}
+		consumed, errReply := parseStreamTrimOption(args, i, spec, &limitGiven)
+		if errReply != nil {
+			return errReply
+		}
+		if consumed == 0 {
+			break // it's the ID
+		}
+		i += consumed - 1
+	}
+	if errReply := checkStreamTrimSpec(spec, limitGiven); errReply != nil {
+		return errReply
+	}
+	if i >= len(args) {
+		return protocol.MakeArgNumErrReply("xadd")
+	}
+	idArg := string(args[i])
+	fields := args[i+1:]
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return protocol.MakeArgNumErrReply("xadd")
+	}
+
+	var id stream.ID
+	autoID, autoSeq := idArg == "*", false
+	if !autoID {
+		if msPart, ok := strings.CutSuffix(idArg, "-*"); ok {
+			ms, err := strconv.ParseUint(msPart, 10, 64)
+			if err != nil {
+				return errInvalidStreamID
+			}
+			id.Ms = ms
+			autoSeq = true
+		} else {
+			var errReply protocol.ErrorReply
+			if id, errReply = parseStreamID(args[i], 0); errReply != nil {
+				return errReply
+			}
+			// return ASAP if the minimal ID was given so we avoid creating a key
+			if id.IsZero() {
+				return protocol.MakeErrReply("ERR The ID specified in XADD must be greater than 0-0")
+			}
+		}
+	}
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	created := false
+	if s == nil {
+		if noMkStream {
+			return protocol.MakeNullBulkReply()
+		}
+		s = makeStream()
+		created = true
+	}
+	lastID := s.LastID()
+	if lastID == stream.MaxID {
+		return protocol.MakeErrReply("ERR The stream has exhausted the last possible ID, unable to add more items")
+	}
+	switch {
+	case autoID:
+		now := uint64(time.Now().UnixMilli())
+		if now > lastID.Ms {
+			id = stream.ID{Ms: now}
+		} else {
+			id, _ = lastID.Incr()
+		}
+	case autoSeq:
+		if id.Ms == lastID.Ms {
+			if lastID.Seq == math.MaxUint64 {
+				return errXAddIDTooSmall
+			}
+			id.Seq = lastID.Seq + 1
+		} else if id.Ms < lastID.Ms {
+			return errXAddIDTooSmall
+		}
+	default:
+		if id.Compare(lastID) <= 0 {
+			return errXAddIDTooSmall
+		}
+	}
+
+	s.Add(id, fields)
+	if created {
+		db.PutEntity(key, &database.DataEntity{
+			Data: s,
+		})
+	} else {
+		db.signalKeyAsReady(key)
+	}
+	db.notifyKeyspaceEvent(notifyStream, "xadd", key)
+	if spec.given && s.Trim(&spec.TrimArgs) > 0 {
+		db.notifyKeyspaceEvent(notifyStream, "xtrim", key)
+	}
+	return makeStreamIDReply(id)
+}
+
+// execXLen returns the number of entries
+//
+//	XLEN key
+func execXLen(db *DB, args [][]byte) redis.Reply {
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(s.Len())
+}
+
+// makeXRange returns the executor of XRANGE and XREVRANGE
+//
+//	XRANGE key start end [COUNT count]
+//	XREVRANGE key end start [COUNT count]
+func makeXRange(rev bool) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		startArg, endArg := args[1], args[2]
+		if rev {
+			startArg, endArg = endArg, startArg
+		}
+		start, errReply := parseRangeID(startArg, true)
+		if errReply != nil {
+			return errReply
+		}
+		end, errReply := parseRangeID(endArg, false)
+		if errReply != nil {
+			return errReply
+		}
+		count := int64(-1) // unlimited
+		for i := 3; i < len(args); i++ {
+			if strings.ToUpper(string(args[i])) != "COUNT" || i+1 >= len(args) {
+				return protocol.MakeSyntaxErrReply()
+			}
+			var err error
+			count, err = strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return errNotInteger
+			}
+			count = max(count, 0)
+			i++
+		}
+
+		s, errReply := db.getAsStream(string(args[0]))
+		if errReply != nil {
+			return errReply
+		}
+		if s == nil {
+			return protocol.MakeEmptyMultiBulkReply()
+		}
+		if count == 0 {
+			return protocol.MakeNullMultiBulkReply()
+		}
+		var entries []*stream.Entry
+		s.Range(start, end, rev, func(entry *stream.Entry) bool {
+			entries = append(entries, entry)
+			return count < 0 || int64(len(entries)) < count
+		})
+		return makeEntriesReply(entries)
+	}
+}
+
+// execXDel marks entries as deleted, returns the number of deleted entries
+//
+//	XDEL key id [id ...]
+func execXDel(db *DB, args [][]byte) redis.Reply {
+	// parse all IDs first, so that the command is executed in an "all or nothing" fashion
+	ids, errReply := parseStreamIDs(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	deleted := 0
+	for _, id := range ids {
+		if s.Delete(id) {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		db.notifyKeyspaceEvent(notifyStream, "xdel", string(args[0]))
+	}
+	return protocol.MakeIntReply(int64(deleted))
+}
+
+// execXSetID sets the last ID of stream, optionally with the number of added entries and the greatest deleted ID.
+// It's mostly used to rebuild a stream whose last entries were deleted
+//
+//	XSETID key last-id [ENTRIESADDED entries-added] [MAXDELETEDID max-deleted-id]
+func execXSetID(db *DB, args [][]byte) redis.Reply {
+	lastID, errReply := parseStreamID(args[1], 0)
+	if errReply != nil {
+		return errReply
+	}
+	entriesAdded := int64(-1)
+	var maxDeletedID stream.ID
+	maxDeletedGiven := false
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return protocol.MakeSyntaxErrReply()
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "ENTRIESADDED":
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return errNotInteger
+			}
+			if n < 0 {
+				return protocol.MakeErrReply("ERR entries_added must be positive")
+			}
+			entriesAdded = n
+		case "MAXDELETEDID":
+			if maxDeletedID, errReply = parseStreamID(args[i+1], 0); errReply != nil {
+				return errReply
+			}
+			if lastID.Compare(maxDeletedID) < 0 {
+				return protocol.MakeErrReply("ERR The ID specified in XSETID is smaller than the provided max_deleted_entry_id")
+			}
+			maxDeletedGiven = true
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeErrReply("ERR no such key")
+	}
+	if entriesAdded < 0 {
+		entriesAdded = s.EntriesAdded()
+	} else if entriesAdded < s.Len() {
+		return protocol.MakeErrReply("ERR The entries_added specified in XSETID is smaller than the target stream length")
+	}
+	if !maxDeletedGiven {
+		maxDeletedID = s.MaxDeletedID()
+	}
+	if last, ok := s.Last(); ok && lastID.Compare(last.ID) < 0 {
+		return protocol.MakeErrReply("ERR The ID specified in XSETID is smaller than the target stream top item")
+	}
+	s.SetID(lastID, entriesAdded, maxDeletedID)
+	db.notifyKeyspaceEvent(notifyStream, "xsetid", string(args[0]))
+	return protocol.MakeOKReply()
+}
+
+// execXTrim removes entries from the head of stream, returns the number of removed entries
+//
+//	XTRIM key <MAXLEN | MINID> [= | ~] threshold [LIMIT count]
+func execXTrim(db *DB, args [][]byte) redis.Reply {
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	spec := &streamTrimSpec{}
+	limitGiven := false
+	for i := 1; i < len(args); {
+		consumed, errReply := parseStreamTrimOption(args, i, spec, &limitGiven)
+		if errReply != nil {
+			return errReply
+		}
+		if consumed == 0 {
+			return protocol.MakeSyntaxErrReply()
+		}
+		i += consumed
+	}
+	if !spec.given {
+		return protocol.MakeErrReply("ERR syntax error, XTRIM must be called with a trimming strategy")
+	}
+	if errReply := checkStreamTrimSpec(spec, limitGiven); errReply != nil {
+		return errReply
+	}
+	removed := s.Trim(&spec.TrimArgs)
+	if removed > 0 {
+		db.notifyKeyspaceEvent(notifyStream, "xtrim", string(args[0]))
+	}
+	return protocol.MakeIntReply(removed)
+}
+
+// xreadArgs is the parsed arguments of XREAD and XREADGROUP
+type xreadArgs struct {
+	count   int // 0 means unlimited
+	block   bool
+	timeout time.Duration
+	noAck   bool
+	group   string
+	// consumer is empty for XREAD
+	consumer string
+	keys     []string
+	ids      [][]byte // raw IDs, "$" and ">" are resolved by the caller
+}
+
+// xreadKeys returns the keysFunc of XREAD and XREADGROUP, keys are written by XREADGROUP since it updates groups
+func xreadKeys(readGroup bool) keysFunc {
+	return func(args [][]byte) ([]string, []string) {
+		xread, errReply := parseXReadArgs(args, readGroup)
+		if errReply != nil {
+			return nil, nil
+		}
+		if readGroup {
+			return xread.keys, nil
+		}
+		return nil, xread.keys
+	}
+}
+
+// parseXReadArgs parses
+//
+//	XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+//	XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+func parseXReadArgs(args [][]byte, readGroup bool) (*xreadArgs, protocol.ErrorReply) {
+	result := &xreadArgs{}
+	groupGiven := false
+	for i := 0; i < len(args); i++ {
+		moreArgs := len(args) - i - 1
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "BLOCK" && moreArgs >= 1:
+			ms, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil || ms > math.MaxInt64/int64(time.Millisecond) {
+				return nil, protocol.MakeErrReply("ERR timeout is not an integer or out of range")
+			}
+			if ms < 0 {
+				return nil, protocol.MakeErrReply("ERR timeout is negative")
+			}
+			result.block = true
+			result.timeout = time.Duration(ms) * time.Millisecond
+			i++
+		case opt == "COUNT" && moreArgs >= 1:
+			count, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return nil, errNotInteger
+			}
+			result.count = int(min(max(count, 0), math.MaxInt32))
+			i++
+		case opt == "STREAMS" && moreArgs >= 1:
+			if moreArgs%2 != 0 {
+				if readGroup {
+					return nil, protocol.MakeErrReply("ERR Unbalanced 'xreadgroup' list of streams: for each stream key an ID or '>' must be specified.")
+				}
+				return nil, protocol.MakeErrReply("ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.")
+			}
+			rest := args[i+1:]
+			result.keys = make([]string, len(rest)/2)
+			for j := range result.keys {
+				result.keys[j] = string(rest[j])
+			}
+			result.ids = rest[len(rest)/2:]
+			i = len(args)
+		case opt == "GROUP" && moreArgs >= 2 && readGroup:
+			result.group = string(args[i+1])
+			result.consumer = string(args[i+2])
+			groupGiven = true
+			i += 2
+		case opt == "NOACK" && readGroup:
+			result.noAck = true
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	if result.keys == nil {
+		return nil, protocol.MakeSyntaxErrReply()
+	}
+	if readGroup && !groupGiven {
+		return nil, protocol.MakeErrReply("ERR Missing GROUP option for XREADGROUP")
+	}
+	return result, nil
+}
+
+// makeStreamsReply returns [[key1, entries1], [key2, entries2] ...]
+func makeStreamsReply(keys []string, results [][]*stream.Entry) redis.Reply {
+	replies := make([]redis.Reply, len(keys))
+	for i, key := range keys {
+		replies[i] = protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte(key)),
+			makeEntriesReply(results[i]),
+		})
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// readStream returns at most count entries after id, nil if there is none
+func readStream(s *stream.Stream, after stream.ID, count int) []*stream.Entry {
+	start, ok := after.Incr()
+	if !ok {
+		return nil
+	}
+	var entries []*stream.Entry
+	s.Range(start, stream.MaxID, false, func(entry *stream.Entry) bool {
+		entries = append(entries, entry)
+		return count == 0 || len(entries) < count
+	})
+	return entries
+}
+
+// execXRead reads entries after the given IDs from streams, blocks if BLOCK is given and there is nothing to read
+//
+//	XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+//
+// id "$" means the last ID of the stream, so that only entries added after blocking are read
+func execXRead(db *DB, args [][]byte) redis.Reply {
+	xread, errReply := parseXReadArgs(args, false)
+	if errReply != nil {
+		return errReply
+	}
+	ids := make(map[string]stream.ID, len(xread.keys))
+	for i, key := range xread.keys {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		switch string(xread.ids[i]) {
+		case "$":
+			if s != nil {
+				ids[key] = s.LastID()
+			} else {
+				ids[key] = stream.ID{}
+			}
+			continue
+		case ">":
+			return protocol.MakeErrReply("ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+		}
+		id, errReply := parseStreamID(xread.ids[i], 0)
+		if errReply != nil {
+			return errReply
+		}
+		ids[key] = id
+	}
+
+	var keys []string
+	var results [][]*stream.Entry
+	for _, key := range xread.keys {
+		s, _ := db.getAsStream(key)
+		if s == nil {
+			continue
+		}
+		if entries := readStream(s, ids[key], xread.count); len(entries) > 0 {
+			keys = append(keys, key)
+			results = append(results, entries)
+		}
+	}
+	if len(keys) > 0 {
+		return makeStreamsReply(keys, results)
+	}
+	if !xread.block {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	return makeWaiter(xread.keys, xread.timeout, protocol.MakeNullMultiBulkReply(), func(key string) redis.Reply {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		if s == nil {
+			return nil
+		}
+		entries := readStream(s, ids[key], xread.count)
+		if len(entries) == 0 {
+			return nil
+		}
+		return makeStreamsReply([]string{key}, [][]*stream.Entry{entries})
+	})
+}
+
+func init() {
+	registerCommand("XAdd", execXAdd, -5, flagWrite, acl.CategoryWrite|acl.CategoryStream)
+	registerCommand("XLen", execXLen, 2, flagReadOnly, acl.CategoryRead|acl.CategoryStream)
+	registerCommand("XRange", makeXRange(false), -4, flagReadOnly, acl.CategoryRead|acl.CategoryStream)
+	registerCommand("XRevRange", makeXRange(true), -4, flagReadOnly, acl.CategoryRead|acl.CategoryStream)
+	registerCommand("XDel", execXDel, -3, flagWrite, acl.CategoryWrite|acl.CategoryStream)
+	registerCommand("XSetID", execXSetID, -3, flagWrite, acl.CategoryWrite|acl.CategoryStream)
+	registerCommand("XTrim", execXTrim, -4, flagWrite, acl.CategoryWrite|acl.CategoryStream)
+	registerCommand("XRead", execXRead, -4, flagReadOnly, acl.CategoryRead|acl.CategoryStream|acl.CategoryBlocking).setKeysFunc(xreadKeys(false))
+}
@@ -0,0 +1,837 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/datastruct/stream"
+	"github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// consumer groups of streams: XGROUP, XREADGROUP, XACK, XPENDING, XCLAIM, XAUTOCLAIM and XINFO
+
+func makeNoGroupErr(key string, group string) protocol.ErrorReply {
+	return protocol.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + group + "'")
+}
+
+// getStreamGroup returns the stream and its consumer group, NOGROUP error if either doesn't exist
+func (db *DB) getStreamGroup(key string, groupName string) (*stream.Stream, *stream.Group, protocol.ErrorReply) {
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+	if s == nil {
+		return nil, nil, makeNoGroupErr(key, groupName)
+	}
+	group, ok := s.GetGroup(groupName)
+	if !ok {
+		return nil, nil, makeNoGroupErr(key, groupName)
+	}
+	return s, group, nil
+}
+
+// getOrCreateConsumer returns the consumer of name, creating it if it doesn't exist, and updates its seen time
+func (db *DB) getOrCreateConsumer(key string, group *stream.Group, name string, now int64) *stream.Consumer {
+	consumer, created := group.CreateConsumer(name, now)
+	if created {
+		db.notifyKeyspaceEvent(notifyStream, "xgroup-createconsumer", key)
+	}
+	consumer.SeenTime = now
+	return consumer
+}
+
+// parseGroupStartID parses the last ID of XGROUP CREATE and XGROUP SETID, "$" means the last ID of stream
+func parseGroupStartID(s *stream.Stream, arg []byte) (stream.ID, protocol.ErrorReply) {
+	if string(arg) == "$" {
+		if s == nil {
+			return stream.ID{}, nil
+		}
+		return s.LastID(), nil
+	}
+	return parseStreamID(arg, 0)
+}
+
+// execXGroup manages consumer groups
+//
+//	XGROUP CREATE key group <id | $> [MKSTREAM] [ENTRIESREAD entries-read]
+//	XGROUP SETID key group <id | $> [ENTRIESREAD entries-read]
+//	XGROUP DESTROY key group
+//	XGROUP CREATECONSUMER key group consumer
+//	XGROUP DELCONSUMER key group consumer
+func execXGroup(db *DB, args [][]byte) redis.Reply {
+	subCmd := strings.ToUpper(string(args[0]))
+	// arities are counted from the sub command
+	arities := map[string]int{
+		"CREATE":         -4,
+		"SETID":          -4,
+		"DESTROY":        3,
+		"CREATECONSUMER": 4,
+		"DELCONSUMER":    4,
+	}
+	arity, ok := arities[subCmd]
+	if !ok {
+		return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XGROUP HELP.")
+	}
+	if !validateArity(arity, args) {
+		return protocol.MakeArgNumErrReply("xgroup|" + strings.ToLower(subCmd))
+	}
+	key, groupName := string(args[1]), string(args[2])
+
+	mkStream := false
+	entriesRead := int64(stream.InvalidEntriesRead)
+	if subCmd == "CREATE" || subCmd == "SETID" {
+		for i := 4; i < len(args); i++ {
+			switch opt := strings.ToUpper(string(args[i])); {
+			case opt == "MKSTREAM" && subCmd == "CREATE":
+				mkStream = true
+			case opt == "ENTRIESREAD" && i+1 < len(args):
+				var err error
+				entriesRead, err = strconv.ParseInt(string(args[i+1]), 10, 64)
+				if err != nil {
+					return errNotInteger
+				}
+				if entriesRead < 0 && entriesRead != stream.InvalidEntriesRead {
+					return protocol.MakeErrReply("ERR value for ENTRIESREAD must be positive or -1")
+				}
+				i++
+			default:
+				return protocol.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" +
+					string(args[0]) + "'. Try XGROUP HELP.")
+			}
+		}
+	}
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil && !(subCmd == "CREATE" && mkStream) {
+		return protocol.MakeErrReply("ERR The XGROUP subcommand requires the key to exist. " +
+			"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+	}
+	var group *stream.Group
+	if s != nil {
+		group, _ = s.GetGroup(groupName)
+	}
+	if group == nil && subCmd != "CREATE" && subCmd != "DESTROY" {
+		return protocol.MakeErrReply("NOGROUP No such consumer group '" + groupName + "' for key name '" + key + "'")
+	}
+
+	switch subCmd {
+	case "CREATE":
+		id, errReply := parseGroupStartID(s, args[3])
+		if errReply != nil {
+			return errReply
+		}
+		if s == nil {
+			s = makeStream()
+			db.PutEntity(key, &database.DataEntity{
+				Data: s,
+			})
+		}
+		if _, ok := s.CreateGroup(groupName, id, entriesRead); !ok {
+			return protocol.MakeErrReply("BUSYGROUP Consumer Group name already exists")
+		}
+		db.notifyKeyspaceEvent(notifyStream, "xgroup-create", key)
+		return protocol.MakeOKReply()
+	case "SETID":
+		id, errReply := parseGroupStartID(s, args[3])
+		if errReply != nil {
+			return errReply
+		}
+		group.LastID = id
+		group.EntriesRead = entriesRead
+		db.notifyKeyspaceEvent(notifyStream, "xgroup-setid", key)
+		return protocol.MakeOKReply()
+	case "DESTROY":
+		if group == nil {
+			return protocol.MakeIntReply(0)
+		}
+		s.DestroyGroup(groupName)
+		db.notifyKeyspaceEvent(notifyStream, "xgroup-destroy", key)
+		return protocol.MakeIntReply(1)
+	case "CREATECONSUMER":
+		if _, created := group.CreateConsumer(string(args[3]), time.Now().UnixMilli()); created {
+			db.notifyKeyspaceEvent(notifyStream, "xgroup-createconsumer", key)
+			return protocol.MakeIntReply(1)
+		}
+		return protocol.MakeIntReply(0)
+	default: // DELCONSUMER
+		pending, deleted := group.DeleteConsumer(string(args[3]))
+		if deleted {
+			db.notifyKeyspaceEvent(notifyStream, "xgroup-delconsumer", key)
+		}
+		return protocol.MakeIntReply(int64(pending))
+	}
+}
+
+// execXReadGroup reads entries as a consumer of group.
+// With id ">" it reads entries never delivered to the group, which become pending until acknowledged
+// (unless NOACK), and blocks if BLOCK is given and there is nothing to read.
+// With other IDs it reads the history of pending entries of the consumer
+//
+//	XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+func execXReadGroup(db *DB, args [][]byte) redis.Reply {
+	xread, errReply := parseXReadArgs(args, true)
+	if errReply != nil {
+		return errReply
+	}
+	// validate all keys and IDs before reading anything
+	ids := make([]stream.ID, len(xread.keys))
+	readNew := make([]bool, len(xread.keys))
+	for i, key := range xread.keys {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		if s == nil {
+			return protocol.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + xread.group +
+				"' in XREADGROUP with GROUP option")
+		}
+		if _, ok := s.GetGroup(xread.group); !ok {
+			return protocol.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + xread.group +
+				"' in XREADGROUP with GROUP option")
+		}
+		switch string(xread.ids[i]) {
+		case ">":
+			readNew[i] = true
+			continue
+		case "$":
+			return protocol.MakeErrReply("ERR The $ ID is meaningless in the context of XREADGROUP: " +
+				"you want to read the history of this consumer by specifying a proper ID, or use the > ID " +
+				"to get new messages. The $ ID would just return an empty result set.")
+		}
+		id, errReply := parseStreamID(xread.ids[i], 0)
+		if errReply != nil {
+			return errReply
+		}
+		ids[i] = id
+	}
+
+	now := time.Now().UnixMilli()
+	var keys []string
+	var results [][]*stream.Entry
+	for i, key := range xread.keys {
+		s, _ := db.getAsStream(key)
+		group, _ := s.GetGroup(xread.group)
+		consumer := db.getOrCreateConsumer(key, group, xread.consumer, now)
+		if !readNew[i] {
+			// the history is always replied, even if it is empty
+			keys = append(keys, key)
+			results = append(results, s.ReadPending(consumer, ids[i], xread.count, now))
+			continue
+		}
+		if entries := s.ReadNew(group, consumer, xread.count, xread.noAck, now); len(entries) > 0 {
+			keys = append(keys, key)
+			results = append(results, entries)
+		}
+	}
+	if len(keys) > 0 {
+		return makeStreamsReply(keys, results)
+	}
+	if !xread.block {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	return makeWaiter(xread.keys, xread.timeout, protocol.MakeNullMultiBulkReply(), func(key string) redis.Reply {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		if s == nil {
+			return nil
+		}
+		group, ok := s.GetGroup(xread.group)
+		if !ok {
+			return protocol.MakeErrReply("NOGROUP the consumer group this client was blocked on no longer exists")
+		}
+		now := time.Now().UnixMilli()
+		consumer := db.getOrCreateConsumer(key, group, xread.consumer, now)
+		entries := s.ReadNew(group, consumer, xread.count, xread.noAck, now)
+		if len(entries) == 0 {
+			return nil
+		}
+		return makeStreamsReply([]string{key}, [][]*stream.Entry{entries})
+	})
+}
+
+// execXAck acknowledges pending entries, returns the number of acknowledged entries
+//
+//	XACK key group id [id ...]
+func execXAck(db *DB, args [][]byte) redis.Reply {
+	ids, errReply := parseStreamIDs(args[2:])
+	if errReply != nil {
+		return errReply
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return protocol.MakeIntReply(0)
+	}
+	group, ok := s.GetGroup(string(args[1]))
+	if !ok {
+		return protocol.MakeIntReply(0)
+	}
+	acked := 0
+	for _, id := range ids {
+		if group.Ack(id) {
+			acked++
+		}
+	}
+	return protocol.MakeIntReply(int64(acked))
+}
+
+// execXPending inspects pending entries of a group.
+// Without range it returns the summary: [count, smallest ID, greatest ID, [[consumer, count] ...]],
+// with range it returns [[id, consumer, idle milliseconds, delivery count] ...]
+//
+//	XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+func execXPending(db *DB, args [][]byte) redis.Reply {
+	if len(args) != 2 && (len(args) < 5 || len(args) > 8) {
+		return protocol.MakeSyntaxErrReply()
+	}
+	var minIdle, count int64
+	var start, end stream.ID
+	var consumerName string
+	hasConsumer := false
+	if len(args) >= 5 {
+		startIdx := 2
+		if strings.ToUpper(string(args[2])) == "IDLE" {
+			var err error
+			minIdle, err = strconv.ParseInt(string(args[3]), 10, 64)
+			if err != nil {
+				return errNotInteger
+			}
+			// if IDLE was provided we must have at least 'start end count'
+			if len(args) < 7 {
+				return protocol.MakeSyntaxErrReply()
+			}
+			startIdx += 2
+		}
+		var err error
+		count, err = strconv.ParseInt(string(args[startIdx+2]), 10, 64)
+		if err != nil {
+			return errNotInteger
+		}
+		count = max(count, 0)
+		var errReply protocol.ErrorReply
+		if start, errReply = parseRangeID(args[startIdx], true); errReply != nil {
+			return errReply
+		}
+		if end, errReply = parseRangeID(args[startIdx+1], false); errReply != nil {
+			return errReply
+		}
+		if startIdx+3 < len(args) {
+			consumerName = string(args[startIdx+3])
+			hasConsumer = true
+		}
+	}
+
+	_, group, errReply := db.getStreamGroup(string(args[0]), string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+
+	if len(args) == 2 {
+		if group.PendingCount() == 0 {
+			return protocol.MakeMultiRawReply([]redis.Reply{
+				protocol.MakeIntReply(0),
+				protocol.MakeNullBulkReply(),
+				protocol.MakeNullBulkReply(),
+				protocol.MakeNullMultiBulkReply(),
+			})
+		}
+		var first, last stream.ID
+		firstSeen := false
+		group.ForEachPending(stream.ID{}, func(id stream.ID, nack *stream.NACK) bool {
+			if !firstSeen {
+				first, firstSeen = id, true
+			}
+			last = id
+			return true
+		})
+		var consumers []redis.Reply
+		group.ForEachConsumer(func(consumer *stream.Consumer) bool {
+			if consumer.PendingCount() > 0 {
+				consumers = append(consumers, protocol.MakeMultiBulkReply([][]byte{
+					[]byte(consumer.Name),
+					[]byte(strconv.Itoa(consumer.PendingCount())),
+				}))
+			}
+			return true
+		})
+		return protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeIntReply(int64(group.PendingCount())),
+			makeStreamIDReply(first),
+			makeStreamIDReply(last),
+			protocol.MakeMultiRawReply(consumers),
+		})
+	}
+
+	forEachPending := group.ForEachPending
+	if hasConsumer {
+		consumer, ok := group.GetConsumer(consumerName)
+		if !ok {
+			// a consumer that doesn't exist has nothing pending
+			return protocol.MakeEmptyMultiBulkReply()
+		}
+		forEachPending = consumer.ForEachPending
+	}
+	now := time.Now().UnixMilli()
+	result := make([]redis.Reply, 0)
+	if count == 0 {
+		return protocol.MakeMultiRawReply(result)
+	}
+	forEachPending(start, func(id stream.ID, nack *stream.NACK) bool {
+		if id.Compare(end) > 0 {
+			return false
+		}
+		idle := max(now-nack.DeliveryTime, 0)
+		if minIdle > 0 && idle < minIdle {
+			return true
+		}
+		result = append(result, protocol.MakeMultiRawReply([]redis.Reply{
+			makeStreamIDReply(id),
+			protocol.MakeBulkReply([]byte(nack.Consumer.Name)),
+			protocol.MakeIntReply(idle),
+			protocol.MakeIntReply(nack.DeliveryCount),
+		}))
+		return int64(len(result)) < count
+	})
+	return protocol.MakeMultiRawReply(result)
+}
+
+// makeClaimedReply returns the entry or only its ID if justID
+func makeClaimedReply(s *stream.Stream, id stream.ID, justID bool) redis.Reply {
+	if justID {
+		return makeStreamIDReply(id)
+	}
+	entry, _ := s.Get(id)
+	return makeEntryReply(entry)
+}
+
+// execXClaim transfers the ownership of pending entries idle for at least min-idle-time milliseconds to consumer.
+// Entries deleted from the stream are removed from the pending list and not returned
+//
+//	XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds]
+//	  [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid]
+func execXClaim(db *DB, args [][]byte) redis.Reply {
+	s, group, errReply := db.getStreamGroup(string(args[0]), string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	minIdle, err := strconv.ParseInt(string(args[3]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR Invalid min-idle-time argument for XCLAIM")
+	}
+	minIdle = max(minIdle, 0)
+
+	// parse IDs until something that is not an ID, the rest are options
+	var ids []stream.ID
+	i := 4
+	for ; i < len(args); i++ {
+		id, ok := stream.ParseID(string(args[i]), 0)
+		if !ok {
+			break
+		}
+		ids = append(ids, id)
+	}
+	now := time.Now().UnixMilli()
+	deliveryTime, retryCount := int64(-1), int64(-1)
+	var force, justID bool
+	var lastID stream.ID
+	for ; i < len(args); i++ {
+		moreArgs := len(args) - i - 1
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "FORCE":
+			force = true
+		case opt == "JUSTID":
+			justID = true
+		case opt == "IDLE" && moreArgs > 0:
+			idle, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return protocol.MakeErrReply("ERR Invalid IDLE option argument for XCLAIM")
+			}
+			deliveryTime = now - idle
+			i++
+		case opt == "TIME" && moreArgs > 0:
+			deliveryTime, err = strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return protocol.MakeErrReply("ERR Invalid TIME option argument for XCLAIM")
+			}
+			i++
+		case opt == "RETRYCOUNT" && moreArgs > 0:
+			retryCount, err = strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return protocol.MakeErrReply("ERR Invalid RETRYCOUNT option argument for XCLAIM")
+			}
+			i++
+		case opt == "LASTID" && moreArgs > 0:
+			if lastID, errReply = parseStreamID(args[i+1], 0); errReply != nil {
+				return errReply
+			}
+			i++
+		default:
+			return protocol.MakeErrReply("ERR Unrecognized XCLAIM option '" + string(args[i]) + "'")
+		}
+	}
+	if lastID.Compare(group.LastID) > 0 {
+		group.LastID = lastID
+	}
+	// a bogus delivery time is not an error, since clients may compute it with a skewed clock
+	if deliveryTime < 0 || deliveryTime > now {
+		deliveryTime = now
+	}
+
+	consumer := db.getOrCreateConsumer(string(args[0]), group, string(args[2]), now)
+	result := make([]redis.Reply, 0, len(ids))
+	for _, id := range ids {
+		nack, pending := group.GetPending(id)
+		// the entry must exist for us to transfer it to another consumer
+		if !s.Exists(id) {
+			if pending {
+				group.Ack(id)
+			}
+			continue
+		}
+		// with FORCE, an entry which is not pending becomes pending, regardless of min-idle-time
+		if !pending && !force {
+			continue
+		}
+		if pending && minIdle > 0 && now-nack.DeliveryTime < minIdle {
+			continue
+		}
+		nack = group.Claim(id, consumer)
+		nack.DeliveryTime = deliveryTime
+		if retryCount >= 0 {
+			nack.DeliveryCount = retryCount
+		} else if !justID {
+			nack.DeliveryCount++
+		}
+		result = append(result, makeClaimedReply(s, id, justID))
+		consumer.ActiveTime = now
+	}
+	if len(result) > 0 {
+		db.notifyKeyspaceEvent(notifyStream, "xclaim", string(args[0]))
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// xautoclaimAttemptsFactor limits the number of pending entries XAUTOCLAIM scans to count * factor
+const xautoclaimAttemptsFactor = 10
+
+// execXAutoClaim is like XCLAIM, but it scans the pending list from start instead of claiming given IDs.
+// It returns [cursor, claimed entries, IDs of deleted entries], the cursor is the start of the next call or 0-0 if scan finished
+//
+//	XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+func execXAutoClaim(db *DB, args [][]byte) redis.Reply {
+	s, group, errReply := db.getStreamGroup(string(args[0]), string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	minIdle, err := strconv.ParseInt(string(args[3]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR Invalid min-idle-time argument for XAUTOCLAIM")
+	}
+	minIdle = max(minIdle, 0)
+	start, errReply := parseRangeID(args[4], true)
+	if errReply != nil {
+		return errReply
+	}
+	count := int64(100)
+	justID := false
+	for i := 5; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "COUNT" && i+1 < len(args):
+			count, err = strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return errNotInteger
+			}
+			if count < 1 || count > (1<<62)/xautoclaimAttemptsFactor {
+				return protocol.MakeErrReply("ERR COUNT must be > 0")
+			}
+			i++
+		case opt == "JUSTID":
+			justID = true
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+
+	// collect candidates first since the pending list can't be modified during traversal,
+	// one more ID is collected as the cursor of next call
+	attempts := count * xautoclaimAttemptsFactor
+	var candidates []stream.ID
+	group.ForEachPending(start, func(id stream.ID, nack *stream.NACK) bool {
+		candidates = append(candidates, id)
+		return int64(len(candidates)) <= attempts
+	})
+
+	now := time.Now().UnixMilli()
+	consumer := db.getOrCreateConsumer(string(args[0]), group, string(args[2]), now)
+	claimed := make([]redis.Reply, 0)
+	deleted := make([][]byte, 0)
+	i := 0
+	for ; attempts > 0 && count > 0 && i < len(candidates); i++ {
+		attempts--
+		id := candidates[i]
+		if !s.Exists(id) {
+			// clear this entry from the pending list, it no longer exists
+			group.Ack(id)
+			deleted = append(deleted, []byte(id.String()))
+			count--
+			continue
+		}
+		nack, _ := group.GetPending(id)
+		if minIdle > 0 && now-nack.DeliveryTime < minIdle {
+			continue
+		}
+		nack = group.Claim(id, consumer)
+		nack.DeliveryTime = now
+		if !justID {
+			nack.DeliveryCount++
+		}
+		claimed = append(claimed, makeClaimedReply(s, id, justID))
+		count--
+	}
+	if len(claimed) > 0 {
+		consumer.ActiveTime = now
+	}
+	if len(claimed) > 0 || len(deleted) > 0 {
+		db.notifyKeyspaceEvent(notifyStream, "xautoclaim", string(args[0]))
+	}
+
+	var cursor stream.ID
+	if i < len(candidates) {
+		cursor = candidates[i]
+	}
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		makeStreamIDReply(cursor),
+		protocol.MakeMultiRawReply(claimed),
+		protocol.MakeMultiBulkReply(deleted),
+	})
+}
+
+// makeInfoReply makes a flat [name1, value1, name2, value2 ...] reply
+func makeInfoReply(pairs ...any) redis.Reply {
+	result := make([]redis.Reply, 0, len(pairs))
+	for i := 0; i < len(pairs); i += 2 {
+		result = append(result, protocol.MakeBulkReply([]byte(pairs[i].(string))))
+		var value redis.Reply
+		switch v := pairs[i+1].(type) {
+		case redis.Reply:
+			value = v
+		case int64:
+			value = protocol.MakeIntReply(v)
+		case int:
+			value = protocol.MakeIntReply(int64(v))
+		case string:
+			value = protocol.MakeBulkReply([]byte(v))
+		case stream.ID:
+			value = makeStreamIDReply(v)
+		}
+		result = append(result, value)
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+func makeNullableEntryReply(entry *stream.Entry, ok bool) redis.Reply {
+	if !ok {
+		return protocol.MakeNullBulkReply()
+	}
+	return makeEntryReply(entry)
+}
+
+// makeGroupCounterReplies returns the replies of entries-read and lag, nil if unknown
+func makeGroupCounterReplies(s *stream.Stream, group *stream.Group) (redis.Reply, redis.Reply) {
+	var entriesRead, lag redis.Reply = protocol.MakeNullBulkReply(), protocol.MakeNullBulkReply()
+	if group.EntriesRead != stream.InvalidEntriesRead {
+		entriesRead = protocol.MakeIntReply(group.EntriesRead)
+	}
+	if value, ok := s.Lag(group); ok {
+		lag = protocol.MakeIntReply(value)
+	}
+	return entriesRead, lag
+}
+
+// execXInfo returns information about streams, consumer groups and consumers
+//
+//	XINFO STREAM key [FULL [COUNT count]]
+//	XINFO GROUPS key
+//	XINFO CONSUMERS key group
+func execXInfo(db *DB, args [][]byte) redis.Reply {
+	subCmd := strings.ToUpper(string(args[0]))
+	// arities are counted from the sub command
+	arities := map[string]int{
+		"STREAM":    -2,
+		"GROUPS":    2,
+		"CONSUMERS": 3,
+	}
+	arity, ok := arities[subCmd]
+	if !ok {
+		return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XINFO HELP.")
+	}
+	if !validateArity(arity, args) {
+		return protocol.MakeArgNumErrReply("xinfo|" + strings.ToLower(subCmd))
+	}
+	key := string(args[1])
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return errNoSuchKey
+	}
+	now := time.Now().UnixMilli()
+
+	switch subCmd {
+	case "CONSUMERS":
+		groupName := string(args[2])
+		group, ok := s.GetGroup(groupName)
+		if !ok {
+			return protocol.MakeErrReply("NOGROUP No such consumer group '" + groupName + "' for key name '" + key + "'")
+		}
+		result := make([]redis.Reply, 0, group.ConsumerCount())
+		group.ForEachConsumer(func(consumer *stream.Consumer) bool {
+			inactive := int64(-1)
+			if consumer.ActiveTime != -1 {
+				inactive = max(now-consumer.ActiveTime, 0)
+			}
+			result = append(result, makeInfoReply(
+				"name", consumer.Name,
+				"pending", consumer.PendingCount(),
+				"idle", max(now-consumer.SeenTime, 0),
+				"inactive", inactive,
+			))
+			return true
+		})
+		return protocol.MakeMultiRawReply(result)
+	case "GROUPS":
+		result := make([]redis.Reply, 0, s.GroupCount())
+		s.ForEachGroup(func(group *stream.Group) bool {
+			entriesRead, lag := makeGroupCounterReplies(s, group)
+			result = append(result, makeInfoReply(
+				"name", group.Name,
+				"consumers", group.ConsumerCount(),
+				"pending", group.PendingCount(),
+				"last-delivered-id", group.LastID,
+				"entries-read", entriesRead,
+				"lag", lag,
+			))
+			return true
+		})
+		return protocol.MakeMultiRawReply(result)
+	}
+
+	// XINFO STREAM
+	full := false
+	count := int64(10) // max number of entries and pending entries of FULL, 0 means all
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "FULL":
+			full = true
+		case opt == "COUNT" && full && i+1 < len(args):
+			var err error
+			count, err = strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return errNotInteger
+			}
+			count = max(count, 0)
+			i++
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+	if !full {
+		first, firstOK := s.First()
+		last, lastOK := s.Last()
+		return makeInfoReply(
+			"length", s.Len(),
+			"radix-tree-keys", s.NodeCount(),
+			"radix-tree-nodes", s.RadixNodeCount(),
+			"last-generated-id", s.LastID(),
+			"max-deleted-entry-id", s.MaxDeletedID(),
+			"entries-added", s.EntriesAdded(),
+			"recorded-first-entry-id", s.FirstID(),
+			"groups", s.GroupCount(),
+			"first-entry", makeNullableEntryReply(first, firstOK),
+			"last-entry", makeNullableEntryReply(last, lastOK),
+		)
+	}
+
+	var entries []*stream.Entry
+	s.Range(stream.ID{}, stream.MaxID, false, func(entry *stream.Entry) bool {
+		entries = append(entries, entry)
+		return count == 0 || int64(len(entries)) < count
+	})
+	groups := make([]redis.Reply, 0, s.GroupCount())
+	s.ForEachGroup(func(group *stream.Group) bool {
+		pending := make([]redis.Reply, 0)
+		group.ForEachPending(stream.ID{}, func(id stream.ID, nack *stream.NACK) bool {
+			pending = append(pending, protocol.MakeMultiRawReply([]redis.Reply{
+				makeStreamIDReply(id),
+				protocol.MakeBulkReply([]byte(nack.Consumer.Name)),
+				protocol.MakeIntReply(nack.DeliveryTime),
+				protocol.MakeIntReply(nack.DeliveryCount),
+			}))
+			return count == 0 || int64(len(pending)) < count
+		})
+		consumers := make([]redis.Reply, 0, group.ConsumerCount())
+		group.ForEachConsumer(func(consumer *stream.Consumer) bool {
+			consumerPending := make([]redis.Reply, 0)
+			consumer.ForEachPending(stream.ID{}, func(id stream.ID, nack *stream.NACK) bool {
+				consumerPending = append(consumerPending, protocol.MakeMultiRawReply([]redis.Reply{
+					makeStreamIDReply(id),
+					protocol.MakeIntReply(nack.DeliveryTime),
+					protocol.MakeIntReply(nack.DeliveryCount),
+				}))
+				return count == 0 || int64(len(consumerPending)) < count
+			})
+			consumers = append(consumers, makeInfoReply(
+				"name", consumer.Name,
+				"seen-time", consumer.SeenTime,
+				"active-time", consumer.ActiveTime,
+				"pel-count", consumer.PendingCount(),
+				"pending", protocol.MakeMultiRawReply(consumerPending),
+			))
+			return true
+		})
+		entriesRead, lag := makeGroupCounterReplies(s, group)
+		groups = append(groups, makeInfoReply(
+			"name", group.Name,
+			"last-delivered-id", group.LastID,
+			"entries-read", entriesRead,
+			"lag", lag,
+			"pel-count", group.PendingCount(),
+			"pending", protocol.MakeMultiRawReply(pending),
+			"consumers", protocol.MakeMultiRawReply(consumers),
+		))
+		return true
+	})
+	return makeInfoReply(
+		"length", s.Len(),
+		"radix-tree-keys", s.NodeCount(),
+		"radix-tree-nodes", s.RadixNodeCount(),
+		"last-generated-id", s.LastID(),
+		"max-deleted-entry-id", s.MaxDeletedID(),
+		"entries-added", s.EntriesAdded(),
+		"recorded-first-entry-id", s.FirstID(),
+		"entries", makeEntriesReply(entries),
+		"groups", protocol.MakeMultiRawReply(groups),
+	)
+}
+
+func init() {
+	registerCommand("XGroup", execXGroup, -2, flagWrite, acl.CategoryWrite|acl.CategoryStream).setKeys(2, 2, 1)
+	registerCommand("XReadGroup", execXReadGroup, -7, flagWrite, acl.CategoryWrite|acl.CategoryStream|acl.CategoryBlocking).setKeysFunc(xreadKeys(true)).setAccessWritten()
+	registerCommand("XAck", execXAck, -4, flagWrite, acl.CategoryWrite|acl.CategoryStream)
+	registerCommand("XPending", execXPending, -3, flagReadOnly, acl.CategoryRead|acl.CategoryStream)
+	registerCommand("XClaim", execXClaim, -6, flagWrite, acl.CategoryWrite|acl.CategoryStream).setAccessWritten()
+	registerCommand("XAutoClaim", execXAutoClaim, -6, flagWrite, acl.CategoryWrite|acl.CategoryStream).setAccessWritten()
+	registerCommand("XInfo", execXInfo, -2, flagReadOnly, acl.CategoryRead|acl.CategoryStream).setKeys(2, 2, 1)
+}
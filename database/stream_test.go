@@ -0,0 +1,515 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/datastruct/stream"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// makeXReadReply is the reply of XREAD reading a single entry of a single field from key
+func makeXReadReply(key string, id string, field string, value string) redis.Reply {
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte(key)),
+			protocol.MakeMultiRawReply([]redis.Reply{
+				protocol.MakeMultiRawReply([]redis.Reply{
+					protocol.MakeBulkReply([]byte(id)),
+					bulks(field, value),
+				}),
+			}),
+		}),
+	})
+}
+
+// infoFields returns the values of a flat [name1, value1, name2, value2 ...] reply of XINFO by names
+func infoFields(t *testing.T, reply redis.Reply) map[string]redis.Reply {
+	t.Helper()
+	info, ok := reply.(*protocol.MultiRawReply)
+	if !ok {
+		t.Fatalf("reply is %q, expected fields", reply.ToBytes())
+	}
+	fields := make(map[string]redis.Reply)
+	for i := 0; i+1 < len(info.Replies); i += 2 {
+		fields[string(info.Replies[i].(*protocol.BulkReply).Arg)] = info.Replies[i+1]
+	}
+	return fields
+}
+
+func TestXReadIsReadonly(t *testing.T) {
+	server := makeTestServer(t)
+	admin := connect(server)
+	execCmd(server, admin, "xadd", "s", "1-1", "f", "v")
+
+	// a user with read permissions only may XREAD, also with BLOCK
+	c := connectAs(t, server, "reader", "~*", "+@read")
+	assertReply(t, execCmd(server, c, "xread", "streams", "s", "0"), makeXReadReply("s", "1-1", "f", "v"))
+	w := block(t, server, c, "xread", "block", "0", "streams", "s", "$")
+	execCmd(server, admin, "xadd", "s", "1-2", "f", "v2")
+	assertReply(t, servedReply(t, w), makeXReadReply("s", "1-2", "f", "v2"))
+	assertErr(t, execCmd(server, c, "xadd", "s", "*", "f", "v"), "NOPERM")
+
+	// keys read by XREAD are tracked
+	tracking, out := connectPipe(t, server)
+	execCmd(server, tracking, "hello", "3")
+	execCmd(server, tracking, "client", "tracking", "on")
+	execCmd(server, tracking, "xread", "streams", "s", "0")
+	execCmd(server, admin, "xadd", "s", "1-3", "f", "v3")
+	out.expect(t, makeInvalidation("s"))
+}
+
+func TestXSetID(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertErr(t, execCmd(server, c, "xsetid", "s", "1-1"), "ERR no such key")
+	execCmd(server, c, "xadd", "s", "1-1", "f", "v")
+	execCmd(server, c, "xadd", "s", "2-1", "f", "v")
+
+	assertErr(t, execCmd(server, c, "xsetid", "s", "1-5"), "ERR The ID specified in XSETID is smaller than the target stream top item")
+	assertErr(t, execCmd(server, c, "xsetid", "s", "5-0", "entriesadded", "1"), "ERR The entries_added specified in XSETID is smaller")
+	assertErr(t, execCmd(server, c, "xsetid", "s", "5-0", "entriesadded", "-1"), "ERR entries_added must be positive")
+	assertErr(t, execCmd(server, c, "xsetid", "s", "5-0", "maxdeletedid", "6-0"), "ERR The ID specified in XSETID is smaller than the provided max_deleted_entry_id")
+	assertErr(t, execCmd(server, c, "xsetid", "s", "5-0", "entriesadded"), "ERR syntax error")
+
+	// the last ID is kept after the last entry is deleted, new IDs must be greater than it
+	assertReply(t, execCmd(server, c, "xsetid", "s", "5-0", "entriesadded", "10", "maxdeletedid", "3-0"), protocol.MakeOKReply())
+	assertErr(t, execCmd(server, c, "xadd", "s", "4-0", "f", "v"), "ERR The ID specified in XADD is equal or smaller")
+	assertReply(t, execCmd(server, c, "xadd", "s", "5-*", "f", "v"), protocol.MakeBulkReply([]byte("5-1")))
+	info := infoFields(t, execCmd(server, c, "xinfo", "stream", "s"))
+	assertReply(t, info["entries-added"], protocol.MakeIntReply(11))
+	assertReply(t, info["max-deleted-entry-id"], protocol.MakeBulkReply([]byte("3-0")))
+}
+
+// xrangeIn is the part of XREAD and XREADGROUP replies of key: [key, entries] with entries of XRANGE key start end
+func xrangeIn(server *Server, c redis.Connection, key string, start string, end string) redis.Reply {
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte(key)),
+		execCmd(server, c, "xrange", key, start, end),
+	})
+}
+
+// assertPending checks rows of the extended form of XPENDING, each row is given as "id consumer delivery-count".
+// Idle times can't be known exactly, they are checked to be at least minIdle
+func assertPending(t *testing.T, reply redis.Reply, minIdle int64, expected ...string) {
+	t.Helper()
+	rows, ok := reply.(*protocol.MultiRawReply)
+	if !ok {
+		t.Fatalf("reply is %q, expected pending entries", reply.ToBytes())
+	}
+	got := make([]string, len(rows.Replies))
+	for i, raw := range rows.Replies {
+		row := raw.(*protocol.MultiRawReply).Replies
+		idle := row[2].(*protocol.IntReply).Code
+		if idle < minIdle {
+			t.Fatalf("idle time of %s is %d, expected at least %d", row[0].(*protocol.BulkReply).Arg, idle, minIdle)
+		}
+		got[i] = string(row[0].(*protocol.BulkReply).Arg) + " " + string(row[1].(*protocol.BulkReply).Arg) + " " +
+			strconv.FormatInt(row[3].(*protocol.IntReply).Code, 10)
+	}
+	if strings.Join(got, ", ") != strings.Join(expected, ", ") {
+		t.Fatalf("pending entries are %q, expected %q", got, expected)
+	}
+}
+
+// makePendingSummary is the reply of XPENDING key group, consumers are given as name, count, name, count ...
+func makePendingSummary(count int64, first string, last string, consumers ...string) redis.Reply {
+	var consumerReplies []redis.Reply
+	for i := 0; i+1 < len(consumers); i += 2 {
+		consumerReplies = append(consumerReplies, bulks(consumers[i], consumers[i+1]))
+	}
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeIntReply(count),
+		protocol.MakeBulkReply([]byte(first)),
+		protocol.MakeBulkReply([]byte(last)),
+		protocol.MakeMultiRawReply(consumerReplies),
+	})
+}
+
+func TestXAddID(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	// the minimal ID is rejected before creating the key
+	assertErr(t, execCmd(server, c, "xadd", "s", "0-0", "f", "v"), "ERR The ID specified in XADD must be greater than 0-0")
+	assertReply(t, execCmd(server, c, "exists", "s"), protocol.MakeIntReply(0))
+	for _, id := range []string{"abc", "1-x", "-1", "x-*", "1-2-3"} {
+		assertErr(t, execCmd(server, c, "xadd", "s", id, "f", "v"), "ERR Invalid stream ID specified as stream command argument")
+	}
+	assertErr(t, execCmd(server, c, "xadd", "s", "*", "f"), "ERR wrong number of arguments for 'xadd' command")
+	assertErr(t, execCmd(server, c, "xadd", "s", "maxlen", "1", "*"), "ERR wrong number of arguments for 'xadd' command")
+
+	assertReply(t, execCmd(server, c, "xadd", "s", "1-1", "f", "v"), protocol.MakeBulkReply([]byte("1-1")))
+	for _, id := range []string{"1-1", "1-0", "0-5", "1"} {
+		assertErr(t, execCmd(server, c, "xadd", "s", id, "f", "v"), "ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	}
+	// an incomplete ID has sequence number 0, "<ms>-*" generates the sequence number
+	assertReply(t, execCmd(server, c, "xadd", "s", "1-*", "f", "v"), protocol.MakeBulkReply([]byte("1-2")))
+	assertReply(t, execCmd(server, c, "xadd", "s", "2-*", "f", "v"), protocol.MakeBulkReply([]byte("2-0")))
+	assertReply(t, execCmd(server, c, "xadd", "s", "3", "f", "v"), protocol.MakeBulkReply([]byte("3-0")))
+	assertErr(t, execCmd(server, c, "xadd", "s", "2-*", "f", "v"), "ERR The ID specified in XADD is equal or smaller")
+
+	// "*" generates an ID after the last one even if the clock is behind
+	execCmd(server, c, "xadd", "future", "99999999999999-5", "f", "v")
+	assertReply(t, execCmd(server, c, "xadd", "future", "*", "f", "v"), protocol.MakeBulkReply([]byte("99999999999999-6")))
+	id, ok := stream.ParseID(string(execCmd(server, c, "xadd", "s", "*", "f", "v").(*protocol.BulkReply).Arg), 0)
+	if !ok || id.Compare(stream.ID{Ms: 3}) <= 0 {
+		t.Fatalf("generated ID %s is not after 3-0", id)
+	}
+
+	execCmd(server, c, "xsetid", "future", "18446744073709551615-18446744073709551615")
+	assertErr(t, execCmd(server, c, "xadd", "future", "*", "f", "v"), "ERR The stream has exhausted the last possible ID")
+	execCmd(server, c, "set", "str", "v")
+	assertErr(t, execCmd(server, c, "xadd", "str", "*", "f", "v"), "WRONGTYPE")
+}
+
+func TestXAddNoMkStream(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "xadd", "s", "nomkstream", "*", "f", "v"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "exists", "s"), protocol.MakeIntReply(0))
+	execCmd(server, c, "xadd", "s", "1-1", "f", "v")
+	assertReply(t, execCmd(server, c, "xadd", "s", "nomkstream", "maxlen", "1", "2-1", "f", "v"), protocol.MakeBulkReply([]byte("2-1")))
+	assertReply(t, execCmd(server, c, "xrange", "s", "-", "+"), execCmd(server, c, "xrange", "s", "2-1", "2-1"))
+	assertReply(t, execCmd(server, c, "xlen", "s"), protocol.MakeIntReply(1))
+}
+
+func TestXTrim(t *testing.T) {
+	// approximate trimming removes whole nodes only, small nodes make it visible
+	nodeMaxEntries := config.Properties.StreamNodeMaxEntries
+	config.Properties.StreamNodeMaxEntries = 10
+	t.Cleanup(func() {
+		config.Properties.StreamNodeMaxEntries = nodeMaxEntries
+	})
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "xtrim", "s", "maxlen", "0"), protocol.MakeIntReply(0))
+	for i := 1; i <= 25; i++ {
+		execCmd(server, c, "xadd", "s", strconv.Itoa(i)+"-1", "f", "v")
+	}
+
+	// nodes hold 1..10, 11..20 and 21..25
+	assertReply(t, execCmd(server, c, "xtrim", "s", "maxlen", "20"), protocol.MakeIntReply(5))
+	assertReply(t, execCmd(server, c, "xrange", "s", "-", "+", "count", "1"), execCmd(server, c, "xrange", "s", "6-1", "6-1"))
+	// the first node has 5 entries left, removing the second one too would keep less than 12 entries
+	assertReply(t, execCmd(server, c, "xtrim", "s", "maxlen", "~", "12"), protocol.MakeIntReply(5))
+	assertReply(t, execCmd(server, c, "xlen", "s"), protocol.MakeIntReply(15))
+	assertReply(t, execCmd(server, c, "xtrim", "s", "maxlen", "~", "12"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "xtrim", "s", "maxlen", "=", "15"), protocol.MakeIntReply(0))
+
+	// MINID removes entries less than the ID, an incomplete ID has sequence number 0
+	assertReply(t, execCmd(server, c, "xtrim", "s", "minid", "18"), protocol.MakeIntReply(7))
+	assertReply(t, execCmd(server, c, "xrange", "s", "-", "+", "count", "1"), execCmd(server, c, "xrange", "s", "18-1", "18-1"))
+	// the second node ends with 20-1, the third one with 25-1
+	assertReply(t, execCmd(server, c, "xtrim", "s", "minid", "~", "22"), protocol.MakeIntReply(3))
+	assertReply(t, execCmd(server, c, "xlen", "s"), protocol.MakeIntReply(5))
+
+	// trimming by XADD happens after adding the entry
+	assertReply(t, execCmd(server, c, "xadd", "s", "maxlen", "3", "26-1", "f", "v"), protocol.MakeBulkReply([]byte("26-1")))
+	assertReply(t, execCmd(server, c, "xrange", "s", "-", "+"), execCmd(server, c, "xrange", "s", "24", "+"))
+	// LIMIT stops approximate trimming before a node with more entries
+	assertReply(t, execCmd(server, c, "xadd", "s", "maxlen", "~", "0", "limit", "1", "27-1", "f", "v"), protocol.MakeBulkReply([]byte("27-1")))
+	assertReply(t, execCmd(server, c, "xlen", "s"), protocol.MakeIntReply(4))
+	assertReply(t, execCmd(server, c, "xtrim", "s", "minid", "~", "99", "limit", "4"), protocol.MakeIntReply(4))
+	assertReply(t, execCmd(server, c, "xlen", "s"), protocol.MakeIntReply(0))
+	// an empty stream is kept with its last ID
+	assertErr(t, execCmd(server, c, "xadd", "s", "27-1", "f", "v"), "ERR The ID specified in XADD is equal or smaller")
+
+	assertErr(t, execCmd(server, c, "xtrim", "s", "maxlen", "1", "limit", "1"), "ERR syntax error, LIMIT cannot be used without the special ~ option")
+	assertErr(t, execCmd(server, c, "xtrim", "s", "limit", "1"), "ERR syntax error, XTRIM must be called with a trimming strategy")
+	assertErr(t, execCmd(server, c, "xadd", "s", "limit", "1", "*", "f", "v"), "ERR syntax error, LIMIT cannot be used without specifying a trimming strategy")
+	assertErr(t, execCmd(server, c, "xtrim", "s", "maxlen", "-1"), "ERR The MAXLEN argument must be >= 0.")
+	assertErr(t, execCmd(server, c, "xtrim", "s", "maxlen", "~", "1", "limit", "-1"), "ERR The LIMIT argument must be >= 0.")
+	assertErr(t, execCmd(server, c, "xtrim", "s", "minid", "x"), "ERR Invalid stream ID")
+	assertErr(t, execCmd(server, c, "xtrim", "s", "maxlen", "1", "foo"), "ERR syntax error")
+}
+
+func TestXGroup(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertErr(t, execCmd(server, c, "xgroup", "create", "s", "g", "$"), "ERR The XGROUP subcommand requires the key to exist")
+	assertReply(t, execCmd(server, c, "xgroup", "create", "s", "g", "$", "mkstream"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "type", "s"), protocol.MakeStatusReply("stream"))
+	assertErr(t, execCmd(server, c, "xgroup", "create", "s", "g", "$"), "BUSYGROUP Consumer Group name already exists")
+	assertErr(t, execCmd(server, c, "xgroup", "create", "s", "other", "x"), "ERR Invalid stream ID")
+	assertErr(t, execCmd(server, c, "xgroup", "create", "s", "other", "0", "entriesread", "-2"), "ERR value for ENTRIESREAD must be positive or -1")
+	assertErr(t, execCmd(server, c, "xgroup", "create", "s", "other", "0", "foo"), "ERR unknown subcommand or wrong number of arguments for 'create'")
+	assertErr(t, execCmd(server, c, "xgroup", "foo", "s", "g"), "ERR unknown subcommand 'foo'. Try XGROUP HELP.")
+	assertErr(t, execCmd(server, c, "xgroup", "destroy", "s"), "ERR wrong number of arguments for 'xgroup|destroy' command")
+
+	execCmd(server, c, "xadd", "s", "1-1", "f", "v1")
+	execCmd(server, c, "xadd", "s", "2-1", "f", "v2")
+	execCmd(server, c, "xadd", "s", "3-1", "f", "v3")
+	// SETID moves the last delivered ID, so entries after it are delivered next
+	assertReply(t, execCmd(server, c, "xgroup", "setid", "s", "g", "1-1", "entriesread", "1"), protocol.MakeOKReply())
+	group := infoFields(t, execCmd(server, c, "xinfo", "groups", "s").(*protocol.MultiRawReply).Replies[0])
+	assertReply(t, group["last-delivered-id"], protocol.MakeBulkReply([]byte("1-1")))
+	assertReply(t, group["entries-read"], protocol.MakeIntReply(1))
+	assertReply(t, group["lag"], protocol.MakeIntReply(2))
+	assertErr(t, execCmd(server, c, "xgroup", "setid", "s", "none", "0"), "NOGROUP No such consumer group 'none' for key name 's'")
+	assertErr(t, execCmd(server, c, "xgroup", "setid", "s", "g", "0", "mkstream"), "ERR unknown subcommand or wrong number of arguments")
+
+	assertReply(t, execCmd(server, c, "xgroup", "createconsumer", "s", "g", "alice"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "xgroup", "createconsumer", "s", "g", "alice"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "xreadgroup", "group", "g", "bob", "streams", "s", ">"), protocol.MakeMultiRawReply([]redis.Reply{
+		xrangeIn(server, c, "s", "2-1", "3-1"),
+	}))
+	// DELCONSUMER replies the number of pending entries of the consumer, which are dropped with it
+	assertReply(t, execCmd(server, c, "xgroup", "delconsumer", "s", "g", "bob"), protocol.MakeIntReply(2))
+	assertReply(t, execCmd(server, c, "xgroup", "delconsumer", "s", "g", "bob"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "xpending", "s", "g", "-", "+", "10"), protocol.MakeEmptyMultiBulkReply())
+	assertReply(t, execCmd(server, c, "xgroup", "delconsumer", "s", "g", "alice"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "xinfo", "consumers", "s", "g"), protocol.MakeEmptyMultiBulkReply())
+	assertErr(t, execCmd(server, c, "xgroup", "createconsumer", "s", "none", "alice"), "NOGROUP")
+
+	assertReply(t, execCmd(server, c, "xgroup", "destroy", "s", "g"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "xgroup", "destroy", "s", "g"), protocol.MakeIntReply(0))
+	assertErr(t, execCmd(server, c, "xreadgroup", "group", "g", "alice", "streams", "s", ">"), "NOGROUP")
+}
+
+func TestXReadGroupAndXAck(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "xadd", "s", "1-1", "f", "v1")
+	execCmd(server, c, "xadd", "s", "2-1", "f", "v2")
+	execCmd(server, c, "xadd", "s", "3-1", "f", "v3")
+	execCmd(server, c, "xgroup", "create", "s", "g", "0")
+	assertErr(t, execCmd(server, c, "xreadgroup", "group", "g", "alice", "streams", "s", "$"), "ERR The $ ID is meaningless")
+	assertErr(t, execCmd(server, c, "xreadgroup", "group", "none", "alice", "streams", "s", ">"),
+		"NOGROUP No such key 's' or consumer group 'none' in XREADGROUP with GROUP option")
+	assertErr(t, execCmd(server, c, "xreadgroup", "group", "g", "alice", "streams", "s", "t", ">"), "ERR Unbalanced 'xreadgroup' list of streams")
+
+	// new entries become pending entries of the consumer
+	assertReply(t, execCmd(server, c, "xreadgroup", "group", "g", "alice", "count", "2", "streams", "s", ">"), protocol.MakeMultiRawReply([]redis.Reply{
+		xrangeIn(server, c, "s", "1-1", "2-1"),
+	}))
+	assertReply(t, execCmd(server, c, "xpending", "s", "g"), makePendingSummary(2, "1-1", "2-1", "alice", "2"))
+	// NOACK delivers without adding to the pending list
+	assertReply(t, execCmd(server, c, "xreadgroup", "group", "g", "bob", "noack", "streams", "s", ">"), protocol.MakeMultiRawReply([]redis.Reply{
+		xrangeIn(server, c, "s", "3-1", "3-1"),
+	}))
+	assertReply(t, execCmd(server, c, "xpending", "s", "g"), makePendingSummary(2, "1-1", "2-1", "alice", "2"))
+	assertReply(t, execCmd(server, c, "xreadgroup", "group", "g", "bob", "streams", "s", ">"), protocol.MakeNullMultiBulkReply())
+
+	// other IDs read the history of the consumer, which is delivered again
+	assertReply(t, execCmd(server, c, "xreadgroup", "group", "g", "alice", "streams", "s", "0"), protocol.MakeMultiRawReply([]redis.Reply{
+		xrangeIn(server, c, "s", "1-1", "2-1"),
+	}))
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "-", "+", "10"), 0, "1-1 alice 2", "2-1 alice 2")
+	assertReply(t, execCmd(server, c, "xreadgroup", "group", "g", "bob", "streams", "s", "0"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeMultiRawReply([]redis.Reply{protocol.MakeBulkReply([]byte("s")), protocol.MakeEmptyMultiBulkReply()}),
+	}))
+
+	// XACK counts entries removed from the pending list
+	assertReply(t, execCmd(server, c, "xack", "s", "g", "1-1", "9-9"), protocol.MakeIntReply(1))
+	assertReply(t, execCmd(server, c, "xack", "s", "g", "1-1"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "xack", "s", "none", "2-1"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "xack", "none", "g", "2-1"), protocol.MakeIntReply(0))
+	assertErr(t, execCmd(server, c, "xack", "s", "g", "2-1", "x"), "ERR Invalid stream ID")
+	assertReply(t, execCmd(server, c, "xpending", "s", "g"), makePendingSummary(1, "2-1", "2-1", "alice", "1"))
+
+	// a pending entry deleted from the stream is read from the history without fields
+	execCmd(server, c, "xdel", "s", "2-1")
+	assertReply(t, execCmd(server, c, "xreadgroup", "group", "g", "alice", "streams", "s", "0"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte("s")),
+			protocol.MakeMultiRawReply([]redis.Reply{
+				protocol.MakeMultiRawReply([]redis.Reply{protocol.MakeBulkReply([]byte("2-1")), protocol.MakeNullMultiBulkReply()}),
+			}),
+		}),
+	}))
+}
+
+func TestXPending(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	for _, id := range []string{"1-1", "2-1", "3-1", "4-1"} {
+		execCmd(server, c, "xadd", "s", id, "f", "v")
+	}
+	execCmd(server, c, "xgroup", "create", "s", "g", "0")
+	execCmd(server, c, "xgroup", "create", "s", "empty", "$")
+	assertReply(t, execCmd(server, c, "xpending", "s", "empty"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeIntReply(0),
+		protocol.MakeNullBulkReply(),
+		protocol.MakeNullBulkReply(),
+		protocol.MakeNullMultiBulkReply(),
+	}))
+	execCmd(server, c, "xreadgroup", "group", "g", "alice", "count", "2", "streams", "s", ">")
+	execCmd(server, c, "xreadgroup", "group", "g", "bob", "streams", "s", ">")
+
+	assertReply(t, execCmd(server, c, "xpending", "s", "g"), makePendingSummary(4, "1-1", "4-1", "alice", "2", "bob", "2"))
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "-", "+", "10"), 0, "1-1 alice 1", "2-1 alice 1", "3-1 bob 1", "4-1 bob 1")
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "-", "+", "1"), 0, "1-1 alice 1")
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "-", "+", "0"), 0)
+	// an incomplete end ID includes all sequence numbers, "(" excludes the ID
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "(1-1", "3", "10"), 0, "2-1 alice 1", "3-1 bob 1")
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "-", "+", "10", "bob"), 0, "3-1 bob 1", "4-1 bob 1")
+	assertReply(t, execCmd(server, c, "xpending", "s", "g", "-", "+", "10", "nobody"), protocol.MakeEmptyMultiBulkReply())
+
+	// IDLE filters entries delivered recently
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "idle", "100000", "-", "+", "10"), 100000)
+	execCmd(server, c, "xclaim", "s", "g", "bob", "0", "2-1", "idle", "500000", "justid")
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "idle", "100000", "-", "+", "10"), 500000, "2-1 bob 1")
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "idle", "100000", "-", "+", "10", "alice"), 100000)
+
+	assertErr(t, execCmd(server, c, "xpending", "s", "g", "-", "+"), "ERR syntax error")
+	assertErr(t, execCmd(server, c, "xpending", "s", "g", "idle", "1", "-", "+"), "ERR syntax error")
+	assertErr(t, execCmd(server, c, "xpending", "s", "g", "idle", "x", "-", "+", "1"), "ERR value is not an integer or out of range")
+	assertErr(t, execCmd(server, c, "xpending", "s", "g", "-", "+", "x"), "ERR value is not an integer or out of range")
+	assertErr(t, execCmd(server, c, "xpending", "s", "none"), "NOGROUP No such key 's' or consumer group 'none'")
+	assertErr(t, execCmd(server, c, "xpending", "none", "g"), "NOGROUP")
+}
+
+func TestXClaim(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	for _, id := range []string{"1-1", "2-1", "3-1"} {
+		execCmd(server, c, "xadd", "s", id, "f", "v"+id)
+	}
+	execCmd(server, c, "xgroup", "create", "s", "g", "0")
+	execCmd(server, c, "xreadgroup", "group", "g", "alice", "streams", "s", ">")
+
+	// entries delivered recently are not claimed
+	assertReply(t, execCmd(server, c, "xclaim", "s", "g", "bob", "3600000", "1-1"), protocol.MakeEmptyMultiBulkReply())
+	// claiming delivers the entry again
+	assertReply(t, execCmd(server, c, "xclaim", "s", "g", "bob", "0", "1-1"), execCmd(server, c, "xrange", "s", "1-1", "1-1"))
+	// JUSTID doesn't count a delivery, IDLE sets the delivery time in the past
+	assertReply(t, execCmd(server, c, "xclaim", "s", "g", "bob", "0", "2-1", "idle", "60000", "justid"), bulks("2-1"))
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "-", "+", "10"), 0, "1-1 bob 2", "2-1 bob 1", "3-1 alice 1")
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "idle", "60000", "-", "+", "10"), 60000, "2-1 bob 1")
+	assertReply(t, execCmd(server, c, "xclaim", "s", "g", "alice", "30000", "2-1", "3-1", "justid"), bulks("2-1"))
+	assertReply(t, execCmd(server, c, "xclaim", "s", "g", "bob", "0", "1-1", "retrycount", "7", "justid"), bulks("1-1"))
+	assertReply(t, execCmd(server, c, "xclaim", "s", "g", "bob", "0", "3-1", "time", "1", "justid"), bulks("3-1"))
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "-", "+", "10"), 0, "1-1 bob 7", "2-1 alice 1", "3-1 bob 1")
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "idle", "1000000", "-", "+", "10"), 1000000, "3-1 bob 1")
+
+	// an entry which is not pending is only claimed with FORCE
+	execCmd(server, c, "xadd", "s", "4-1", "f", "v4")
+	assertReply(t, execCmd(server, c, "xclaim", "s", "g", "bob", "0", "4-1", "justid"), protocol.MakeEmptyMultiBulkReply())
+	assertReply(t, execCmd(server, c, "xclaim", "s", "g", "bob", "0", "4-1", "force", "justid"), bulks("4-1"))
+	// an entry deleted from the stream is dropped from the pending list instead of claimed
+	execCmd(server, c, "xdel", "s", "2-1")
+	assertReply(t, execCmd(server, c, "xclaim", "s", "g", "bob", "0", "2-1"), protocol.MakeEmptyMultiBulkReply())
+	assertReply(t, execCmd(server, c, "xpending", "s", "g"), makePendingSummary(3, "1-1", "4-1", "bob", "3"))
+	// LASTID moves the last delivered ID of the group forward only
+	execCmd(server, c, "xclaim", "s", "g", "bob", "0", "1-1", "lastid", "9-0", "justid")
+	execCmd(server, c, "xclaim", "s", "g", "bob", "0", "1-1", "lastid", "5-0", "justid")
+	group := infoFields(t, execCmd(server, c, "xinfo", "groups", "s").(*protocol.MultiRawReply).Replies[0])
+	assertReply(t, group["last-delivered-id"], protocol.MakeBulkReply([]byte("9-0")))
+
+	assertErr(t, execCmd(server, c, "xclaim", "s", "g", "bob", "x", "1-1"), "ERR Invalid min-idle-time argument for XCLAIM")
+	assertErr(t, execCmd(server, c, "xclaim", "s", "g", "bob", "0", "1-1", "foo"), "ERR Unrecognized XCLAIM option 'foo'")
+	assertErr(t, execCmd(server, c, "xclaim", "s", "g", "bob", "0", "1-1", "idle", "x"), "ERR Invalid IDLE option argument for XCLAIM")
+	assertErr(t, execCmd(server, c, "xclaim", "s", "none", "bob", "0", "1-1"), "NOGROUP")
+}
+
+func TestXAutoClaim(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	for _, id := range []string{"1-1", "2-1", "3-1", "4-1", "5-1"} {
+		execCmd(server, c, "xadd", "s", id, "f", "v"+id)
+	}
+	execCmd(server, c, "xgroup", "create", "s", "g", "0")
+	execCmd(server, c, "xreadgroup", "group", "g", "alice", "streams", "s", ">")
+	execCmd(server, c, "xdel", "s", "2-1", "4-1")
+
+	// deleted entries are dropped from the pending list and replied apart, they count as claimed
+	assertReply(t, execCmd(server, c, "xautoclaim", "s", "g", "bob", "0", "0", "count", "2"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("3-1")),
+		execCmd(server, c, "xrange", "s", "1-1", "1-1"),
+		bulks("2-1"),
+	}))
+	// the cursor is 0-0 once the pending list is scanned to the end
+	assertReply(t, execCmd(server, c, "xautoclaim", "s", "g", "bob", "0", "3-1", "justid"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("0-0")),
+		bulks("3-1", "5-1"),
+		bulks("4-1"),
+	}))
+	assertPending(t, execCmd(server, c, "xpending", "s", "g", "-", "+", "10"), 0, "1-1 bob 2", "3-1 bob 1", "5-1 bob 1")
+	assertReply(t, execCmd(server, c, "xautoclaim", "s", "g", "alice", "3600000", "-"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("0-0")),
+		protocol.MakeEmptyMultiBulkReply(),
+		protocol.MakeEmptyMultiBulkReply(),
+	}))
+
+	assertErr(t, execCmd(server, c, "xautoclaim", "s", "g", "bob", "0", "0", "count", "0"), "ERR COUNT must be > 0")
+	assertErr(t, execCmd(server, c, "xautoclaim", "s", "g", "bob", "x", "0"), "ERR Invalid min-idle-time argument for XAUTOCLAIM")
+	assertErr(t, execCmd(server, c, "xautoclaim", "s", "g", "bob", "0", "0", "foo"), "ERR syntax error")
+	assertErr(t, execCmd(server, c, "xautoclaim", "s", "none", "bob", "0", "0"), "NOGROUP")
+}
+
+func TestXInfo(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertErr(t, execCmd(server, c, "xinfo", "stream", "s"), "ERR no such key")
+	execCmd(server, c, "xadd", "s", "1-1", "a", "1")
+	execCmd(server, c, "xadd", "s", "2-1", "b", "2")
+	execCmd(server, c, "xgroup", "create", "s", "g", "0")
+	execCmd(server, c, "xgroup", "create", "s", "g2", "$")
+	execCmd(server, c, "xreadgroup", "group", "g", "alice", "count", "1", "streams", "s", ">")
+	execCmd(server, c, "xgroup", "createconsumer", "s", "g", "bob")
+
+	info := infoFields(t, execCmd(server, c, "xinfo", "stream", "s"))
+	assertReply(t, info["length"], protocol.MakeIntReply(2))
+	assertReply(t, info["last-generated-id"], protocol.MakeBulkReply([]byte("2-1")))
+	assertReply(t, info["max-deleted-entry-id"], protocol.MakeBulkReply([]byte("0-0")))
+	assertReply(t, info["entries-added"], protocol.MakeIntReply(2))
+	assertReply(t, info["recorded-first-entry-id"], protocol.MakeBulkReply([]byte("1-1")))
+	assertReply(t, info["groups"], protocol.MakeIntReply(2))
+	assertReply(t, info["first-entry"], execCmd(server, c, "xrange", "s", "1-1", "1-1").(*protocol.MultiRawReply).Replies[0])
+	assertReply(t, info["last-entry"], execCmd(server, c, "xrange", "s", "2-1", "2-1").(*protocol.MultiRawReply).Replies[0])
+
+	groups := execCmd(server, c, "xinfo", "groups", "s").(*protocol.MultiRawReply).Replies
+	if len(groups) != 2 {
+		t.Fatalf("there are %d groups, expected 2", len(groups))
+	}
+	for i, expected := range []struct {
+		name, lastID            string
+		consumers, pending, lag int64
+		entriesRead             redis.Reply
+	}{
+		{"g", "1-1", 2, 1, 1, protocol.MakeIntReply(1)},
+		// a group created at the last ID doesn't know how many entries it has read, but it has nothing to read
+		{"g2", "2-1", 0, 0, 0, protocol.MakeNullBulkReply()},
+	} {
+		group := infoFields(t, groups[i])
+		assertReply(t, group["name"], protocol.MakeBulkReply([]byte(expected.name)))
+		assertReply(t, group["last-delivered-id"], protocol.MakeBulkReply([]byte(expected.lastID)))
+		assertReply(t, group["consumers"], protocol.MakeIntReply(expected.consumers))
+		assertReply(t, group["pending"], protocol.MakeIntReply(expected.pending))
+		assertReply(t, group["entries-read"], expected.entriesRead)
+		assertReply(t, group["lag"], protocol.MakeIntReply(expected.lag))
+	}
+
+	consumers := execCmd(server, c, "xinfo", "consumers", "s", "g").(*protocol.MultiRawReply).Replies
+	if len(consumers) != 2 {
+		t.Fatalf("there are %d consumers, expected 2", len(consumers))
+	}
+	alice, bob := infoFields(t, consumers[0]), infoFields(t, consumers[1])
+	assertReply(t, alice["name"], protocol.MakeBulkReply([]byte("alice")))
+	assertReply(t, alice["pending"], protocol.MakeIntReply(1))
+	if alice["inactive"].(*protocol.IntReply).Code < 0 || alice["idle"].(*protocol.IntReply).Code < 0 {
+		t.Fatalf("alice has read an entry, but it is %q", consumers[0].ToBytes())
+	}
+	assertReply(t, bob["name"], protocol.MakeBulkReply([]byte("bob")))
+	assertReply(t, bob["pending"], protocol.MakeIntReply(0))
+	// a consumer which has never read anything has never been active
+	assertReply(t, bob["inactive"], protocol.MakeIntReply(-1))
+	assertErr(t, execCmd(server, c, "xinfo", "consumers", "s", "none"), "NOGROUP No such consumer group 'none' for key name 's'")
+
+	full := infoFields(t, execCmd(server, c, "xinfo", "stream", "s", "full", "count", "1"))
+	assertReply(t, full["entries"], execCmd(server, c, "xrange", "s", "-", "+", "count", "1"))
+	fullGroup := infoFields(t, full["groups"].(*protocol.MultiRawReply).Replies[0])
+	assertReply(t, fullGroup["pel-count"], protocol.MakeIntReply(1))
+	if len(fullGroup["consumers"].(*protocol.MultiRawReply).Replies) != 2 {
+		t.Fatalf("consumers of g are %q", fullGroup["consumers"].ToBytes())
+	}
+
+	assertErr(t, execCmd(server, c, "xinfo", "stream", "s", "count", "1"), "ERR syntax error")
+	assertErr(t, execCmd(server, c, "xinfo", "foo", "s"), "ERR unknown subcommand 'foo'. Try XINFO HELP.")
+	assertErr(t, execCmd(server, c, "xinfo", "groups"), "ERR wrong number of arguments for 'xinfo|groups' command")
+	execCmd(server, c, "set", "str", "v")
+	assertErr(t, execCmd(server, c, "xinfo", "stream", "str"), "WRONGTYPE")
+}
@@ -0,0 +1,233 @@
+package database
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/acl"
+	"github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// maxStringSize is the max length of a string value, the same as proto-max-bulk-len of redis
+const maxStringSize = 512 * 1024 * 1024
+
+var errStringTooLong = protocol.MakeErrReply("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+
+// getAsString returns the value of a string key.
+// Write commands like SETBIT modify the bytes in place, so a reply must copy them,
+// otherwise the reply may be changed while it's being sent out of the key lock
+func (db *DB) getAsString(key string) ([]byte, protocol.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	value, ok := entity.Data.([]byte)
+	if !ok {
+		return nil, protocol.MakeWrongTypeErrReply()
+	}
+	return value, nil
+}
+
+// putString stores value as the string of key. The value is modified in place by commands like SETRANGE,
+// so it must not share memory with arguments of the command, which are slices of the buffer of the parser
+func (db *DB) putString(key string, value []byte) {
+	db.PutEntity(key, &database.DataEntity{
+		Data: value,
+	})
+}
+
+// execGet returns the value of key
+//
+//	GET key
+func execGet(db *DB, args [][]byte) redis.Reply {
+	str, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if str == nil {
+		return protocol.MakeNullBulkReply()
+	}
+	return protocol.MakeBulkReply(bytes.Clone(str))
+}
+
+// execSet sets the value of key, the old value of any type is overwritten
+//
+//	SET key value [NX | XX] [GET]
+//
+// NX only sets if key doesn't exist, XX only sets if key exists, GET returns the old value
+func execSet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	value := bytes.Clone(args[1])
+	var nx, xx, get bool
+	for _, arg := range args[2:] {
+		switch strings.ToUpper(string(arg)) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+	if nx && xx {
+		return protocol.MakeSyntaxErrReply()
+	}
+
+	var old []byte
+	if get {
+		var errReply protocol.ErrorReply
+		old, errReply = db.getAsString(key)
+		if errReply != nil {
+			return errReply
+		}
+		old = bytes.Clone(old)
+	}
+	_, exists := db.GetEntity(key)
+	if (nx && exists) || (xx && !exists) {
+		if get {
+			return protocol.MakeBulkReply(old)
+		}
+		return protocol.MakeNullBulkReply()
+	}
+	// overwriting a key isn't creating a new one, only field ttls of an old hash are dropped
+	db.untrackFieldTTL(key)
+	db.putString(key, value)
+	db.notifyKeyspaceEvent(notifyString, "set", key)
+	if get {
+		return protocol.MakeBulkReply(old)
+	}
+	return protocol.MakeOKReply()
+}
+
+// execStrLen returns the length of the value
+//
+//	STRLEN key
+func execStrLen(db *DB, args [][]byte) redis.Reply {
+	str, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return protocol.MakeIntReply(int64(len(str)))
+}
+
+// execAppend appends value to the end of the string, a missing key is treated as an empty string
+//
+//	APPEND key value
+func execAppend(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	str, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if len(str)+len(args[1]) > maxStringSize {
+		return errStringTooLong
+	}
+	if str == nil {
+		// the new value must not be args[1], see putString
+		str = bytes.Clone(args[1])
+	} else {
+		str = append(str, args[1]...)
+	}
+	db.putString(key, str)
+	db.notifyKeyspaceEvent(notifyString, "append", key)
+	return protocol.MakeIntReply(int64(len(str)))
+}
+
+// execGetRange returns the substring within [start, end], negative offsets count from the end
+//
+//	GETRANGE key start end
+func execGetRange(db *DB, args [][]byte) redis.Reply {
+	start, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	end, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	str, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	size := int64(len(str))
+	if start < 0 && end < 0 && start > end {
+		return protocol.MakeBulkReply([]byte{})
+	}
+	if start < 0 {
+		start += size
+	}
+	if end < 0 {
+		end += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if start > end || size == 0 {
+		return protocol.MakeBulkReply([]byte{})
+	}
+	return protocol.MakeBulkReply(bytes.Clone(str[start : end+1]))
+}
+
+// execSetRange overwrites part of the string starting at offset, the string is padded with zero bytes if needed
+//
+//	SETRANGE key offset value
+func execSetRange(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	offset, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+	if offset < 0 {
+		return protocol.MakeErrReply("ERR offset is out of range")
+	}
+	value := args[2]
+	str, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if len(value) == 0 {
+		// nothing to write, don't create an empty key
+		return protocol.MakeIntReply(int64(len(str)))
+	}
+	if offset+int64(len(value)) > maxStringSize {
+		return errStringTooLong
+	}
+	if end := offset + int64(len(value)); end > int64(len(str)) {
+		str = append(str, make([]byte, end-int64(len(str)))...)
+	}
+	// value is copied into the string, see putString
+	copy(str[offset:], value)
+	db.putString(key, str)
+	db.notifyKeyspaceEvent(notifyString, "setrange", key)
+	return protocol.MakeIntReply(int64(len(str)))
+}
+
+// setAccessedKeys returns the key of SET if it replies the old value by GET
+func setAccessedKeys(cmdLine [][]byte) []string {
+	for _, arg := range cmdLine[3:] {
+		if strings.EqualFold(string(arg), "GET") {
+			return []string{string(cmdLine[1])}
+		}
+	}
+	return nil
+}
+
+func init() {
+	registerCommand("Get", execGet, 2, flagReadOnly, acl.CategoryRead|acl.CategoryString)
+	registerCommand("Set", execSet, -3, flagWrite, acl.CategoryWrite|acl.CategoryString).setAccessKeysFunc(setAccessedKeys)
+	registerCommand("StrLen", execStrLen, 2, flagReadOnly, acl.CategoryRead|acl.CategoryString)
+	registerCommand("Append", execAppend, 3, flagWrite, acl.CategoryWrite|acl.CategoryString)
+	registerCommand("GetRange", execGetRange, 4, flagReadOnly, acl.CategoryRead|acl.CategoryString)
+	registerCommand("SetRange", execSetRange, 4, flagWrite, acl.CategoryWrite|acl.CategoryString)
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func TestSetGet(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "set", "a", "1"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeBulkReply([]byte("1")))
+	// an empty string is a value, not a missing key
+	assertReply(t, execCmd(server, c, "set", "empty", ""), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "get", "empty"), protocol.MakeBulkReply([]byte{}))
+	assertReply(t, execCmd(server, c, "exists", "empty"), protocol.MakeIntReply(1))
+
+	// NX only sets a missing key, XX only an existing one
+	assertReply(t, execCmd(server, c, "set", "a", "2", "nx"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "set", "b", "2", "xx"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "exists", "b"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "set", "b", "2", "NX"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "set", "a", "3", "XX"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeBulkReply([]byte("3")))
+	assertErr(t, execCmd(server, c, "set", "a", "4", "nx", "xx"), "ERR syntax error")
+	assertErr(t, execCmd(server, c, "set", "a", "4", "ex"), "ERR syntax error")
+
+	// GET replies the old value whether the new one is set or not
+	assertReply(t, execCmd(server, c, "set", "a", "4", "get"), protocol.MakeBulkReply([]byte("3")))
+	assertReply(t, execCmd(server, c, "set", "c", "1", "get"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "set", "a", "5", "nx", "get"), protocol.MakeBulkReply([]byte("4")))
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeBulkReply([]byte("4")))
+	assertReply(t, execCmd(server, c, "set", "none", "1", "xx", "get"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, c, "exists", "none"), protocol.MakeIntReply(0))
+
+	// SET overwrites any type, but GET of another type is an error and sets nothing
+	execCmd(server, c, "rpush", "list", "x")
+	assertErr(t, execCmd(server, c, "get", "list"), "WRONGTYPE")
+	assertErr(t, execCmd(server, c, "set", "list", "v", "get"), "WRONGTYPE")
+	assertReply(t, execCmd(server, c, "type", "list"), protocol.MakeStatusReply("list"))
+	assertReply(t, execCmd(server, c, "set", "list", "v"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "get", "list"), protocol.MakeBulkReply([]byte("v")))
+}
+
+func TestStrLenAppend(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	assertReply(t, execCmd(server, c, "strlen", "a"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "append", "a", "hello"), protocol.MakeIntReply(5))
+	assertReply(t, execCmd(server, c, "append", "a", " world"), protocol.MakeIntReply(11))
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeBulkReply([]byte("hello world")))
+	assertReply(t, execCmd(server, c, "strlen", "a"), protocol.MakeIntReply(11))
+	// appending an empty string creates an empty key
+	assertReply(t, execCmd(server, c, "append", "empty", ""), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "get", "empty"), protocol.MakeBulkReply([]byte{}))
+
+	execCmd(server, c, "sadd", "set", "m")
+	assertErr(t, execCmd(server, c, "strlen", "set"), "WRONGTYPE")
+	assertErr(t, execCmd(server, c, "append", "set", "x"), "WRONGTYPE")
+}
+
+func TestGetRange(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "set", "a", "This is a string")
+	for _, tc := range []struct {
+		start, end string
+		expected   string
+	}{
+		{"0", "3", "This"},
+		{"-3", "-1", "ing"},
+		{"0", "-1", "This is a string"},
+		{"10", "100", "string"},
+		{"-100", "3", "This"},
+		{"5", "2", ""},
+		{"-1", "-3", ""},
+		{"100", "200", ""},
+	} {
+		assertReply(t, execCmd(server, c, "getrange", "a", tc.start, tc.end), protocol.MakeBulkReply([]byte(tc.expected)))
+	}
+	assertReply(t, execCmd(server, c, "getrange", "none", "0", "-1"), protocol.MakeBulkReply([]byte{}))
+	assertErr(t, execCmd(server, c, "getrange", "a", "x", "1"), "ERR value is not an integer or out of range")
+	execCmd(server, c, "rpush", "list", "x")
+	assertErr(t, execCmd(server, c, "getrange", "list", "0", "1"), "WRONGTYPE")
+}
+
+func TestSetRange(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "set", "a", "Hello World")
+	assertReply(t, execCmd(server, c, "setrange", "a", "6", "Redis"), protocol.MakeIntReply(11))
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeBulkReply([]byte("Hello Redis")))
+	// the string is padded with zero bytes up to offset
+	assertReply(t, execCmd(server, c, "setrange", "b", "3", "x"), protocol.MakeIntReply(4))
+	assertReply(t, execCmd(server, c, "get", "b"), protocol.MakeBulkReply([]byte("\x00\x00\x00x")))
+	assertReply(t, execCmd(server, c, "setrange", "a", "13", "!"), protocol.MakeIntReply(14))
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeBulkReply([]byte("Hello Redis\x00\x00!")))
+
+	// an empty value writes nothing and doesn't create the key
+	assertReply(t, execCmd(server, c, "setrange", "none", "5", ""), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "exists", "none"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, c, "setrange", "a", "100", ""), protocol.MakeIntReply(14))
+	assertErr(t, execCmd(server, c, "setrange", "a", "-1", "x"), "ERR offset is out of range")
+	assertErr(t, execCmd(server, c, "setrange", "a", "x", "x"), "ERR value is not an integer or out of range")
+	assertErr(t, execCmd(server, c, "setrange", "a", "536870911", "xx"), "ERR string exceeds maximum allowed size")
+	execCmd(server, c, "rpush", "list", "x")
+	assertErr(t, execCmd(server, c, "setrange", "list", "0", "x"), "WRONGTYPE")
+}
+
+func TestStringOwnsValue(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	// arguments are slices of the buffer of the parser, which has room after them for the trailing CRLF
+	buf := []byte("abc\r\n")
+	server.Exec(c, [][]byte{[]byte("set"), []byte("a"), buf[:3]})
+	server.Exec(c, [][]byte{[]byte("append"), []byte("b"), buf[:3]})
+	server.Exec(c, [][]byte{[]byte("setrange"), []byte("c"), []byte("0"), buf[:3]})
+	execCmd(server, c, "append", "a", "XY")
+	execCmd(server, c, "setrange", "b", "0", "Z")
+	if string(buf) != "abc\r\n" {
+		t.Fatalf("buffer of the arguments is modified to %q", buf)
+	}
+
+	// the buffer is reused for the next command
+	copy(buf, "xyz")
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeBulkReply([]byte("abcXY")))
+	assertReply(t, execCmd(server, c, "get", "b"), protocol.MakeBulkReply([]byte("Zbc")))
+	assertReply(t, execCmd(server, c, "get", "c"), protocol.MakeBulkReply([]byte("abc")))
+}
@@ -0,0 +1,507 @@
+package database
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/pubsub"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// Client side caching (CLIENT TRACKING), the same as redis:
+//
+//   - default mode: the server remembers keys read by readonly commands of a client,
+//     a write to a key sends an invalidation of the key to the clients which read it and forgets them,
+//     until they read the key again. With OPTIN only keys read right after CLIENT CACHING YES are remembered,
+//     with OPTOUT keys read right after CLIENT CACHING NO are not
+//   - broadcasting mode (BCAST): nothing is remembered, a write to a key is sent to all clients
+//     whose prefixes match the key, no prefix means all keys
+//
+// Keys are tracked by names regardless of dbs like redis. FLUSHDB and FLUSHALL invalidate everything with a null key list.
+//
+// An invalidation is the RESP3 push ["invalidate", [key ...]] sent to the client,
+// or to the client it redirects to. A RESP2 redirect client receives it as a message of channel __redis__:invalidate,
+// which it should subscribe. A RESP2 client without redirection can't receive anything.
+// An invalidation of keys modified by the receiver itself is sent after the reply of the command, like redis 7
+//
+// At most tracking-table-max-keys keys are remembered, once a read exceeds it other keys are evicted
+// and their clients receive invalidations as if the keys were modified, so they never cache stale values.
+
+const trackingChannel = "__redis__:invalidate"
+
+var (
+	invalidateKind          = []byte("invalidate")
+	trackingRedirBrokenKind = []byte("tracking-redir-broken")
+)
+
+// trackingClient is the tracking options of a client
+type trackingClient struct {
+	conn redis.Connection
+	// id of the client receiving invalidations, 0 means conn itself
+	redirect int64
+	bcast    bool
+	optIn    bool
+	optOut   bool
+	// noLoop means not to receive invalidations of keys modified by the client itself
+	noLoop   bool
+	prefixes []string
+	// caching is set by CLIENT CACHING and only applies to the next command (or transaction)
+	caching bool
+}
+
+// trackingTable holds all tracking clients and keys they may cache, it is shared by all dbs of a server
+type trackingTable struct {
+	// number of tracking clients, so that commands skip tracking without locking if nobody tracks
+	enabled atomic.Int32
+
+	mu sync.Mutex
+	// client id -> tracking options
+	clients map[int64]*trackingClient
+	// key -> ids of clients which may cache it, for clients in default mode
+	keys map[string]map[int64]struct{}
+	// max number of keys, 0 means no limit
+	maxKeys int
+	// prefix -> ids of clients in BCAST mode
+	prefixes map[string]map[int64]struct{}
+
+	// client id -> redis.Connection of all connected clients, to find redirect clients
+	connections *sync.Map
+	// client id -> invalidations of keys modified by the client itself, waiting for the reply of the command
+	selfInvalidations map[int64][]byte
+}
+
+func makeTrackingTable(connections *sync.Map) *trackingTable {
+	return &trackingTable{
+		clients:           make(map[int64]*trackingClient),
+		keys:              make(map[string]map[int64]struct{}),
+		maxKeys:           config.Properties.TrackingTableMaxKeys,
+		prefixes:          make(map[string]map[int64]struct{}),
+		connections:       connections,
+		selfInvalidations: make(map[int64][]byte),
+	}
+}
+
+// isTracking returns whether anybody is tracking, t may be nil for a db without server
+func (t *trackingTable) isTracking() bool {
+	return t != nil && t.enabled.Load() > 0
+}
+
+// enable turns tracking on for c, or updates the options if c is already tracking
+func (t *trackingTable) enable(c redis.Connection, options *trackingClient) protocol.ErrorReply {
+	if options.redirect > 0 {
+		if _, ok := t.connections.Load(options.redirect); !ok {
+			return protocol.MakeErrReply("ERR The client ID you want redirect to does not exist")
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prefixes := options.prefixes
+	old := t.clients[c.ID()]
+	if old != nil {
+		if old.bcast != options.bcast {
+			return protocol.MakeErrReply("ERR You can't switch BCAST mode on/off before disabling tracking " +
+				"for this client, and then re-enabling it with a different mode.")
+		}
+		if old.optIn != options.optIn || old.optOut != options.optOut {
+			return protocol.MakeErrReply("ERR You can't switch OPTIN/OPTOUT mode before disabling tracking " +
+				"for this client, and then re-enabling it with a different mode.")
+		}
+		// prefixes are added to existing ones
+		prefixes = append(append([]string(nil), old.prefixes...), options.prefixes...)
+	}
+	if options.bcast && len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	if errReply := checkPrefixOverlap(prefixes); errReply != nil {
+		return errReply
+	}
+	options.conn = c
+	options.prefixes = prefixes
+	if old == nil {
+		t.enabled.Add(1)
+	}
+	t.clients[c.ID()] = options
+	for _, prefix := range prefixes {
+		clients := t.prefixes[prefix]
+		if clients == nil {
+			clients = make(map[int64]struct{})
+			t.prefixes[prefix] = clients
+		}
+		clients[c.ID()] = struct{}{}
+	}
+	return nil
+}
+
+// checkPrefixOverlap rejects prefixes where one is a prefix of another, since a key would be sent twice
+func checkPrefixOverlap(prefixes []string) protocol.ErrorReply {
+	for i, a := range prefixes {
+		for _, b := range prefixes[i+1:] {
+			if strings.HasPrefix(a, b) || strings.HasPrefix(b, a) {
+				return protocol.MakeErrReply("ERR Prefix '" + b + "' overlaps with an existing prefix '" + a +
+					"'. Prefixes for a single client must not overlap.")
+			}
+		}
+	}
+	return nil
+}
+
+// disable turns tracking off for the client of id. Keys it read are forgotten lazily when they are invalidated
+func (t *trackingTable) disable(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tc := t.clients[id]
+	if tc == nil {
+		return
+	}
+	delete(t.clients, id)
+	delete(t.selfInvalidations, id)
+	for _, prefix := range tc.prefixes {
+		delete(t.prefixes[prefix], id)
+		if len(t.prefixes[prefix]) == 0 {
+			delete(t.prefixes, prefix)
+		}
+	}
+	if t.enabled.Add(-1) == 0 {
+		t.keys = make(map[string]map[int64]struct{})
+	}
+}
+
+// get returns the tracking options of the client of id, or nil if it's not tracking
+func (t *trackingTable) get(id int64) *trackingClient {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.clients[id]
+}
+
+// setCaching sets the flag of CLIENT CACHING for the next command
+func (t *trackingTable) setCaching(id int64, caching bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tc := t.clients[id]; tc != nil {
+		tc.caching = caching
+	}
+}
+
+// rememberKeys records keys read by c, it must be called with the keys locked,
+// so that a write after the read never misses the client
+func (t *trackingTable) rememberKeys(c redis.Connection, keys []string) {
+	if !t.isTracking() || len(keys) == 0 {
+		return
+	}
+	t.mu.Lock()
+	tc := t.clients[c.ID()]
+	if tc == nil || tc.bcast || (tc.optIn && !tc.caching) || (tc.optOut && tc.caching) {
+		t.mu.Unlock()
+		return
+	}
+	for _, key := range keys {
+		clients := t.keys[key]
+		if clients == nil {
+			clients = make(map[int64]struct{})
+			t.keys[key] = clients
+		}
+		clients[c.ID()] = struct{}{}
+	}
+	evicted := t.evictKeys(keys)
+	t.mu.Unlock()
+	for tc, keys := range evicted {
+		t.send(tc, keys, 0)
+	}
+}
+
+// evictKeys forgets keys beyond maxKeys except the keys just read, and returns the evicted keys by clients caching them.
+// It must be called with mu held
+func (t *trackingTable) evictKeys(read []string) map[*trackingClient][]string {
+	if t.maxKeys <= 0 || len(t.keys) <= t.maxKeys {
+		return nil
+	}
+	evicted := make(map[*trackingClient][]string)
+	// the iteration order of map is random, so are the evicted keys like redis
+	for key, clients := range t.keys {
+		if len(t.keys) <= t.maxKeys {
+			break
+		}
+		if slices.Contains(read, key) {
+			continue
+		}
+		for clientID := range clients {
+			if tc := t.clients[clientID]; tc != nil {
+				evicted[tc] = append(evicted[tc], key)
+			}
+		}
+		delete(t.keys, key)
+	}
+	return evicted
+}
+
+// invalidate sends invalidations of keys modified by the client of id (0 means the server itself, like expiration)
+func (t *trackingTable) invalidate(id int64, keys []string) {
+	if !t.isTracking() || len(keys) == 0 {
+		return
+	}
+	pending := make(map[*trackingClient][]string)
+	add := func(clientID int64, key string) {
+		tc := t.clients[clientID]
+		if tc == nil || (tc.noLoop && clientID == id) {
+			return
+		}
+		pending[tc] = append(pending[tc], key)
+	}
+	t.mu.Lock()
+	for _, key := range keys {
+		for clientID := range t.keys[key] {
+			add(clientID, key)
+		}
+		delete(t.keys, key)
+		for prefix, clients := range t.prefixes {
+			if strings.HasPrefix(key, prefix) {
+				for clientID := range clients {
+					add(clientID, key)
+				}
+			}
+		}
+	}
+	t.mu.Unlock()
+	for tc, keys := range pending {
+		t.send(tc, keys, id)
+	}
+}
+
+// invalidateAll tells all tracking clients to drop their whole caches, it's called on FLUSHDB and FLUSHALL
+func (t *trackingTable) invalidateAll() {
+	if !t.isTracking() {
+		return
+	}
+	t.mu.Lock()
+	clients := make([]*trackingClient, 0, len(t.clients))
+	for _, tc := range t.clients {
+		clients = append(clients, tc)
+	}
+	t.keys = make(map[string]map[int64]struct{})
+	t.mu.Unlock()
+	for _, tc := range clients {
+		t.send(tc, nil, 0)
+	}
+}
+
+// send writes an invalidation of keys modified by the client of writerID to the receiver of tc, nil keys means all keys
+func (t *trackingTable) send(tc *trackingClient, keys []string, writerID int64) {
+	target := tc.conn
+	if tc.redirect > 0 {
+		raw, ok := t.connections.Load(tc.redirect)
+		if !ok {
+			if tc.conn.GetProtocolVersion() >= 3 {
+				_, _ = tc.conn.Write(protocol.MakePushReply([]redis.Reply{
+					protocol.MakeBulkReply(trackingRedirBrokenKind),
+					protocol.MakeIntReply(tc.redirect),
+				}).ToBytes())
+			}
+			return
+		}
+		target = raw.(redis.Connection)
+	}
+	resp3 := target.GetProtocolVersion() >= 3
+	var keysReply redis.Reply
+	switch {
+	case keys != nil:
+		args := make([][]byte, len(keys))
+		for i, key := range keys {
+			args[i] = []byte(key)
+		}
+		keysReply = protocol.MakeMultiBulkReply(args)
+	case resp3:
+		keysReply = protocol.MakeNullReply()
+	default:
+		keysReply = protocol.MakeNullBulkReply()
+	}
+	if resp3 {
+		t.deliver(target, writerID, protocol.MakePushReply([]redis.Reply{
+			protocol.MakeBulkReply(invalidateKind),
+			keysReply,
+		}).ToBytes())
+		return
+	}
+	if tc.redirect > 0 && pubsub.InSubscribeMode(target) {
+		t.deliver(target, writerID, protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte("message")),
+			protocol.MakeBulkReply([]byte(trackingChannel)),
+			keysReply,
+		}).ToBytes())
+	}
+}
+
+// deliver writes the invalidation to target, or queues it if target modified the keys itself,
+// since the command is still running and its reply must come first
+func (t *trackingTable) deliver(target redis.Connection, writerID int64, invalidation []byte) {
+	if writerID == 0 || target.ID() != writerID {
+		_, _ = target.Write(invalidation)
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.selfInvalidations[writerID] = append(t.selfInvalidations[writerID], invalidation...)
+}
+
+// withSelfInvalidations returns the reply of a command of the client of id,
+// followed by invalidations queued for keys the client modified itself.
+// A blocking reply is returned as it is, its invalidations are sent once it's served
+func (t *trackingTable) withSelfInvalidations(id int64, reply redis.Reply) redis.Reply {
+	if !t.isTracking() || reply == nil {
+		return reply
+	}
+	if _, blocking := reply.(*waiter); blocking {
+		return reply
+	}
+	t.mu.Lock()
+	invalidations := t.selfInvalidations[id]
+	delete(t.selfInvalidations, id)
+	t.mu.Unlock()
+	if len(invalidations) == 0 {
+		return reply
+	}
+	return &replyWithInvalidations{reply: reply, invalidations: invalidations}
+}
+
+// replyWithInvalidations is the reply of a command followed by invalidation pushes to the same client
+type replyWithInvalidations struct {
+	reply         redis.Reply
+	invalidations []byte
+}
+
+// ToBytes marshal redis.Reply
+func (r *replyWithInvalidations) ToBytes() []byte {
+	reply := r.reply.ToBytes()
+	// the reply may be a shared constant, so it's copied rather than appended to
+	result := make([]byte, 0, len(reply)+len(r.invalidations))
+	return append(append(result, reply...), r.invalidations...)
+}
+
+// afterCommand clears the flag of CLIENT CACHING once the next command (or transaction) finished
+func (t *trackingTable) afterCommand(c redis.Connection) {
+	if c.InMultiState() {
+		return
+	}
+	t.setCaching(c.ID(), false)
+}
+
+// signalModifiedKeys is called after keys are modified by the client of id (0 means the server itself):
+// it increases versions of watched keys and invalidates keys cached by tracking clients
+func (db *DB) signalModifiedKeys(id int64, keys ...string) {
+	db.addVersion(keys...)
+	db.tracking.invalidate(id, keys)
+}
+
+// execClientTracking turns tracking on or off
+//
+//	CLIENT TRACKING <ON | OFF> [REDIRECT client-id] [PREFIX prefix [PREFIX prefix ...]] [BCAST] [OPTIN] [OPTOUT] [NOLOOP]
+func execClientTracking(server *Server, c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("client|tracking")
+	}
+	options := &trackingClient{}
+	for i := 1; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "REDIRECT":
+			if i+1 >= len(args) {
+				return protocol.MakeSyntaxErrReply()
+			}
+			i++
+			id, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				return protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			options.redirect = id
+		case "PREFIX":
+			if i+1 >= len(args) {
+				return protocol.MakeSyntaxErrReply()
+			}
+			i++
+			options.prefixes = append(options.prefixes, string(args[i]))
+		case "BCAST":
+			options.bcast = true
+		case "OPTIN":
+			options.optIn = true
+		case "OPTOUT":
+			options.optOut = true
+		case "NOLOOP":
+			options.noLoop = true
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+
+	switch strings.ToUpper(string(args[0])) {
+	case "ON":
+	case "OFF":
+		server.tracking.disable(c.ID())
+		return protocol.MakeOKReply()
+	default:
+		return protocol.MakeSyntaxErrReply()
+	}
+	if len(options.prefixes) > 0 && !options.bcast {
+		return protocol.MakeErrReply("ERR PREFIX option requires BCAST mode to be enabled")
+	}
+	if options.optIn && options.optOut {
+		return protocol.MakeErrReply("ERR You can't use both OPTIN and OPTOUT")
+	}
+	if options.bcast && (options.optIn || options.optOut) {
+		return protocol.MakeErrReply("ERR OPTIN and OPTOUT are not compatible with BCAST")
+	}
+	if errReply := server.tracking.enable(c, options); errReply != nil {
+		return errReply
+	}
+	return protocol.MakeOKReply()
+}
+
+// execClientCaching decides whether keys read by the next command are tracked, in OPTIN or OPTOUT mode
+//
+//	CLIENT CACHING <YES | NO>
+func execClientCaching(server *Server, c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) != 1 {
+		return protocol.MakeArgNumErrReply("client|caching")
+	}
+	tc := server.tracking.get(c.ID())
+	if tc == nil || (!tc.optIn && !tc.optOut) {
+		return protocol.MakeErrReply("ERR CLIENT CACHING can be called only when the client is in tracking mode " +
+			"with OPTIN or OPTOUT mode enabled")
+	}
+	switch strings.ToUpper(string(args[0])) {
+	case "YES":
+		if !tc.optIn {
+			return protocol.MakeErrReply("ERR CLIENT CACHING YES is only valid when tracking is enabled in OPTIN mode.")
+		}
+	case "NO":
+		if !tc.optOut {
+			return protocol.MakeErrReply("ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode.")
+		}
+	default:
+		return protocol.MakeSyntaxErrReply()
+	}
+	server.tracking.setCaching(c.ID(), true)
+	return protocol.MakeOKReply()
+}
+
+// execClientGetRedir returns the id of the client receiving invalidations,
+// 0 if tracking without redirection and -1 if not tracking
+//
+//	CLIENT GETREDIR
+func execClientGetRedir(server *Server, c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) != 0 {
+		return protocol.MakeArgNumErrReply("client|getredir")
+	}
+	tc := server.tracking.get(c.ID())
+	if tc == nil {
+		return protocol.MakeIntReply(-1)
+	}
+	return protocol.MakeIntReply(tc.redirect)
+}
+
+// isClientCaching returns whether the command is CLIENT CACHING, which sets the flag for the next command
+func isClientCaching(cmdName string, cmdLine [][]byte) bool {
+	return cmdName == "client" && len(cmdLine) > 1 && strings.EqualFold(string(cmdLine[1]), "caching")
+}
@@ -0,0 +1,217 @@
+package database
+
+import (
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/utils"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// makeInvalidation returns the RESP3 push invalidating keys
+func makeInvalidation(keys ...string) redis.Reply {
+	return protocol.MakePushReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("invalidate")),
+		protocol.MakeMultiBulkReply(utils.ToCmdLine(keys...)),
+	})
+}
+
+func TestTrackingNoOpWrite(t *testing.T) {
+	server := makeTestServer(t)
+	c, out := connectPipe(t, server)
+	other := connect(server)
+	execCmd(server, c, "hello", "3")
+	assertReply(t, execCmd(server, c, "client", "tracking", "on"), protocol.MakeOKReply())
+	execCmd(server, c, "lrange", "list", "0", "-1")
+
+	// popping a missing key changes nothing, so the key cached by c is still valid
+	execCmd(server, other, "lpop", "list")
+	out.expectNothing(t)
+	execCmd(server, other, "rpush", "list", "a")
+	out.expect(t, makeInvalidation("list"))
+}
+
+func TestTrackingTableMaxKeys(t *testing.T) {
+	maxKeys := config.Properties.TrackingTableMaxKeys
+	config.Properties.TrackingTableMaxKeys = 2
+	server := makeTestServer(t)
+	config.Properties.TrackingTableMaxKeys = maxKeys
+	c, out := connectPipe(t, server)
+	other := connect(server)
+	execCmd(server, c, "hello", "3")
+	execCmd(server, c, "client", "tracking", "on")
+	execCmd(server, c, "get", "a")
+	execCmd(server, c, "get", "b")
+	out.expectNothing(t)
+
+	// reading the third key evicts a or b, which is invalidated as if it was modified
+	execCmd(server, c, "get", "c")
+	_ = out.conn.SetReadDeadline(time.Now().Add(time.Second))
+	got := make([]byte, len(makeInvalidation("a").ToBytes()))
+	if _, err := io.ReadFull(out.reader, got); err != nil {
+		t.Fatal(err)
+	}
+	evicted, kept := "a", "b"
+	if string(got) == string(makeInvalidation("b").ToBytes()) {
+		evicted, kept = "b", "a"
+	} else if string(got) != string(makeInvalidation("a").ToBytes()) {
+		t.Fatalf("output is %q, expected an invalidation of a or b", got)
+	}
+
+	// the evicted key is forgotten, the others are still tracked
+	execCmd(server, other, "set", evicted, "1")
+	out.expectNothing(t)
+	execCmd(server, other, "set", kept, "1")
+	out.expect(t, makeInvalidation(kept))
+	execCmd(server, other, "set", "c", "1")
+	out.expect(t, makeInvalidation("c"))
+}
+
+func TestTrackingDefaultMode(t *testing.T) {
+	server := makeTestServer(t)
+	c, out := connectPipe(t, server)
+	other := connect(server)
+	execCmd(server, c, "hello", "3")
+	execCmd(server, c, "client", "tracking", "on")
+	for _, key := range []string{"a", "b", "d"} {
+		execCmd(server, other, "set", key, "0")
+	}
+	execCmd(server, c, "get", "a")
+	execCmd(server, c, "get", "b")
+	execCmd(server, c, "get", "c")
+
+	execCmd(server, other, "del", "a", "b", "d")
+	out.expect(t, makeInvalidation("a", "b"))
+	// invalidated keys are forgotten until they are read again
+	execCmd(server, other, "set", "a", "2")
+	out.expectNothing(t)
+
+	// an invalidation of keys modified by the client itself follows the reply
+	assertReply(t, execCmd(server, c, "set", "c", "1"), &replyWithInvalidations{
+		reply:         protocol.MakeOKReply(),
+		invalidations: makeInvalidation("c").ToBytes(),
+	})
+	out.expectNothing(t)
+
+	assertReply(t, execCmd(server, c, "client", "tracking", "off"), protocol.MakeOKReply())
+	execCmd(server, c, "get", "a")
+	execCmd(server, other, "set", "a", "3")
+	out.expectNothing(t)
+}
+
+func TestTrackingBcast(t *testing.T) {
+	server := makeTestServer(t)
+	c, out := connectPipe(t, server)
+	other := connect(server)
+	execCmd(server, c, "hello", "3")
+	assertErr(t, execCmd(server, c, "client", "tracking", "on", "prefix", "user:"), "ERR PREFIX option requires BCAST mode")
+	assertErr(t, execCmd(server, c, "client", "tracking", "on", "bcast", "prefix", "user:", "prefix", "user:1"),
+		"ERR Prefix 'user:1' overlaps with an existing prefix 'user:'")
+	assertReply(t, execCmd(server, c, "client", "tracking", "on", "bcast", "prefix", "user:", "prefix", "item:"),
+		protocol.MakeOKReply())
+	assertErr(t, execCmd(server, c, "client", "tracking", "on"), "ERR You can't switch BCAST mode on/off")
+
+	// keys matching prefixes are sent without being read, every time they are modified
+	execCmd(server, other, "set", "user:1", "a")
+	out.expect(t, makeInvalidation("user:1"))
+	execCmd(server, other, "set", "user:1", "b")
+	out.expect(t, makeInvalidation("user:1"))
+	execCmd(server, other, "del", "item:1", "other")
+	execCmd(server, other, "set", "item:1", "a")
+	out.expect(t, makeInvalidation("item:1"))
+	execCmd(server, other, "set", "other", "a")
+	out.expectNothing(t)
+}
+
+func TestTrackingOptInOptOut(t *testing.T) {
+	server := makeTestServer(t)
+	c, out := connectPipe(t, server)
+	other := connect(server)
+	execCmd(server, c, "hello", "3")
+	assertErr(t, execCmd(server, c, "client", "caching", "yes"), "ERR CLIENT CACHING can be called only")
+	assertErr(t, execCmd(server, c, "client", "tracking", "on", "optin", "optout"), "ERR You can't use both OPTIN and OPTOUT")
+	execCmd(server, c, "client", "tracking", "on", "optin")
+	assertErr(t, execCmd(server, c, "client", "caching", "no"), "ERR CLIENT CACHING NO is only valid")
+
+	// only the command right after CLIENT CACHING YES is tracked
+	execCmd(server, c, "get", "a")
+	execCmd(server, c, "client", "caching", "yes")
+	execCmd(server, c, "get", "b")
+	execCmd(server, c, "get", "c")
+	for _, key := range []string{"a", "b", "c"} {
+		execCmd(server, other, "set", key, "1")
+	}
+	out.expect(t, makeInvalidation("b"))
+	out.expectNothing(t)
+
+	c, out = connectPipe(t, server)
+	execCmd(server, c, "hello", "3")
+	execCmd(server, c, "client", "tracking", "on", "optout")
+	// the command right after CLIENT CACHING NO is not tracked
+	execCmd(server, c, "client", "caching", "no")
+	execCmd(server, c, "get", "a")
+	execCmd(server, c, "get", "b")
+	execCmd(server, other, "set", "a", "2")
+	execCmd(server, other, "set", "b", "2")
+	out.expect(t, makeInvalidation("b"))
+	out.expectNothing(t)
+}
+
+func TestTrackingNoLoop(t *testing.T) {
+	server := makeTestServer(t)
+	c, out := connectPipe(t, server)
+	other := connect(server)
+	execCmd(server, c, "hello", "3")
+	execCmd(server, c, "client", "tracking", "on", "noloop")
+	execCmd(server, c, "get", "a")
+	execCmd(server, c, "get", "b")
+
+	// keys modified by the client itself are not sent to it
+	assertReply(t, execCmd(server, c, "set", "a", "1"), protocol.MakeOKReply())
+	out.expectNothing(t)
+	execCmd(server, other, "set", "b", "1")
+	out.expect(t, makeInvalidation("b"))
+}
+
+func TestTrackingRedirect(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	other := connect(server)
+	receiver, out := connectPipe(t, server)
+	assertReply(t, execCmd(server, c, "client", "getredir"), protocol.MakeIntReply(-1))
+	assertErr(t, execCmd(server, c, "client", "tracking", "on", "redirect", "100000"),
+		"ERR The client ID you want redirect to does not exist")
+
+	// a RESP2 receiver gets invalidations as messages of __redis__:invalidate
+	execCmd(server, receiver, "subscribe", "__redis__:invalidate")
+	out.expect(t, protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("subscribe")),
+		protocol.MakeBulkReply([]byte("__redis__:invalidate")),
+		protocol.MakeIntReply(1),
+	}))
+	redirect := strconv.FormatInt(receiver.ID(), 10)
+	assertReply(t, execCmd(server, c, "client", "tracking", "on", "redirect", redirect), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "client", "getredir"), protocol.MakeIntReply(receiver.ID()))
+	execCmd(server, c, "get", "a")
+	execCmd(server, other, "set", "a", "1")
+	out.expect(t, protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("message")),
+		protocol.MakeBulkReply([]byte("__redis__:invalidate")),
+		protocol.MakeMultiBulkReply(utils.ToCmdLine("a")),
+	}))
+	// FLUSHALL invalidates everything with a null key list
+	execCmd(server, other, "flushall")
+	out.expect(t, protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("message")),
+		protocol.MakeBulkReply([]byte("__redis__:invalidate")),
+		protocol.MakeNullBulkReply(),
+	}))
+
+	execCmd(server, c, "client", "tracking", "off")
+	execCmd(server, c, "client", "tracking", "on")
+	assertReply(t, execCmd(server, c, "client", "getredir"), protocol.MakeIntReply(0))
+}
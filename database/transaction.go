@@ -0,0 +1,277 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// Transactions work as below:
+//
+//  1. MULTI marks the connection as in multi state
+//  2. following commands are validated (existence and arity) and queued instead of being executed,
+//     an invalid command is recorded as a transaction error
+//  3. EXEC discards the transaction with EXECABORT if there were errors, otherwise it locks keys of all queued
+//     commands and watched keys, then checks watched keys and runs all queued commands,
+//     so no other command touching these keys can interleave with them.
+//     Like redis, a command failing at runtime doesn't roll back the commands before it by default
+//  4. in rollback mode (CLIENT TX-ROLLBACK ON, or transaction-rollback in config), EXEC records an undo log
+//     before each write command (see undo.go), if any command fails at runtime, all executed commands are
+//     reverted in reverse order and EXEC returns an EXECABORT error instead of the replies.
+//     Keyspace events and signals of modified keys are deferred until the transaction commits (see txEffects),
+//     so neither subscribers, tracking clients nor watching clients see changes which are rolled back
+//
+// Blocking commands never block within a transaction, they return their timeout reply immediately.
+
+var errExecAbort = protocol.MakeErrReply("EXECABORT Transaction discarded because of previous errors.")
+
+// execMulti starts a transaction
+//
+//	MULTI
+func execMulti(c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) != 0 {
+		return protocol.MakeArgNumErrReply("multi")
+	}
+	if c.InMultiState() {
+		return protocol.MakeErrReply("ERR MULTI calls can not be nested")
+	}
+	c.SetMultiState(true)
+	return protocol.MakeOKReply()
+}
+
+// execDiscard drops all queued commands, forgets watched keys and leaves multi state
+//
+//	DISCARD
+func (server *Server) execDiscard(c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) != 0 {
+		return protocol.MakeArgNumErrReply("discard")
+	}
+	if !c.InMultiState() {
+		return protocol.MakeErrReply("ERR DISCARD without MULTI")
+	}
+	server.releaseWatching(c, nil)
+	c.SetMultiState(false)
+	return protocol.MakeOKReply()
+}
+
+// enqueueCmd validates and queues a command of transaction
+func enqueueCmd(c redis.Connection, cmdLine [][]byte) redis.Reply {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	var errReply protocol.ErrorReply
+	if !ok {
+		errReply = protocol.MakeErrReply("ERR unknown command '" + cmdName + "'")
+	} else if !validateArity(cmd.arity, cmdLine) {
+		errReply = protocol.MakeArgNumErrReply(cmdName)
+	}
+	if errReply != nil {
+		c.AddTxError(errReply)
+		return errReply
+	}
+	c.EnqueueCmd(cmdLine)
+	return protocol.MakeQueuedReply()
+}
+
+// execTransaction runs queued commands atomically and returns their replies.
+// It returns a null reply without running anything if any watched key was modified.
+// Watched keys are forgotten and the connection leaves multi state anyway
+//
+//	EXEC
+func (server *Server) execTransaction(c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) != 0 {
+		return protocol.MakeArgNumErrReply("exec")
+	}
+	if !c.InMultiState() {
+		return protocol.MakeErrReply("ERR EXEC without MULTI")
+	}
+	db, errReply := server.selectDB(c.GetDBIndex())
+	if errReply != nil {
+		return errReply
+	}
+	// keys watched in other dbs are checked before locking keys of the selected db,
+	// the transaction never touches other dbs so checking them earlier doesn't break atomicity
+	modified := server.releaseWatching(c, db)
+	cmdLines := c.GetQueuedCmdLine()
+	if len(c.GetTxErrors()) > 0 {
+		db.releaseWatching(c)
+		c.SetMultiState(false)
+		return errExecAbort
+	}
+
+	result := db.execLockedTransaction(c, cmdLines, modified)
+	// serve clients blocked on keys created by the transaction only after it finished
+	db.serveReadyKeys()
+	return result
+}
+
+// execLockedTransaction runs queued commands with their keys locked, unless a watched key was modified.
+// Keys are unlocked by defer, so a panic of any command doesn't leave them locked
+func (db *DB) execLockedTransaction(c redis.Connection, cmdLines []CmdLine, modified bool) redis.Reply {
+	writeKeys, readKeys := db.txKeys(c, cmdLines)
+	unlock := db.lockKeys(writeKeys, readKeys)
+	defer unlock()
+	if db.releaseWatching(c) {
+		modified = true
+	}
+	c.SetMultiState(false)
+	if modified {
+		return protocol.MakeNullMultiBulkReply()
+	}
+	if !c.IsTxRollback() {
+		return db.runTransaction(c, cmdLines)
+	}
+
+	effects := db.deferEffects(writeKeys, len(writeKeys) == 0 && len(readKeys) == 0)
+	defer db.stopDeferring(effects)
+	result := db.runTransaction(c, cmdLines)
+	if _, rolledBack := result.(protocol.ErrorReply); !rolledBack {
+		db.emitEffects(c.ID(), effects)
+	}
+	return result
+}
+
+// txKeys returns keys to lock for a transaction, which are keys of all queued commands and keys watched in db.
+// It returns no key to lock the whole db if any command has no key
+func (db *DB) txKeys(c redis.Connection, cmdLines []CmdLine) (writeKeys []string, readKeys []string) {
+	for _, cmdLine := range cmdLines {
+		cmd := cmdTable[strings.ToLower(string(cmdLine[0]))]
+		cmdWriteKeys, cmdReadKeys := cmd.keysOf(cmdLine)
+		if len(cmdWriteKeys) == 0 && len(cmdReadKeys) == 0 {
+			return nil, nil
+		}
+		writeKeys = append(writeKeys, cmdWriteKeys...)
+		readKeys = append(readKeys, cmdReadKeys...)
+	}
+	for wk := range c.GetWatching() {
+		if dbIndex, key := parseWatchingKey(wk); dbIndex == db.index {
+			readKeys = append(readKeys, key)
+		}
+	}
+	return writeKeys, readKeys
+}
+
+// runTransaction runs queued commands with their keys locked
+func (db *DB) runTransaction(c redis.Connection, cmdLines []CmdLine) redis.Reply {
+	rollback := c.IsTxRollback()
+	var undoLogs []func()
+	results := make([]redis.Reply, 0, len(cmdLines))
+	for i, cmdLine := range cmdLines {
+		cmd := cmdTable[strings.ToLower(string(cmdLine[0]))]
+		var result redis.Reply
+		if cmd.flags&flagWrite > 0 {
+			if rollback {
+				undoLogs = append(undoLogs, db.undoLogOf(cmd, cmdLine))
+			}
+			writeKeys, _ := cmd.keysOf(cmdLine)
+			result = db.execWrite(c, cmd, cmdLine, writeKeys)
+		} else {
+			result = cmd.executor(db, cmdLine[1:])
+			_, readKeys := cmd.keysOf(cmdLine)
+			db.tracking.rememberKeys(c, readKeys)
+		}
+		if w, ok := result.(*waiter); ok {
+			result = w.timeoutReply
+		}
+		if errReply, failed := result.(protocol.ErrorReply); failed && rollback {
+			for j := len(undoLogs) - 1; j >= 0; j-- {
+				undoLogs[j]()
+			}
+			return protocol.MakeErrReply("EXECABORT Transaction rolled back because command " +
+				strconv.Itoa(i+1) + " (" + cmd.name + ") failed: " + errReply.Error())
+		}
+		results = append(results, result)
+	}
+	return protocol.MakeMultiRawReply(results)
+}
+
+// txEffects holds side effects of a transaction in rollback mode: keyspace events and modified keys to signal.
+// Keys written by the transaction are locked by it, so any modification of them happens within the transaction.
+// The effects are emitted once the transaction commits, and dropped if it's rolled back
+type txEffects struct {
+	keys     []string
+	all      bool // the transaction locks the whole db, so every key modified belongs to it
+	events   []keyspaceEvent
+	modified []string
+	marked   map[string]struct{} // set of modified
+	flushed  bool
+}
+
+type keyspaceEvent struct {
+	class int
+	event string
+	key   string
+}
+
+// deferEffects starts deferring side effects of keys written by a transaction, or of all keys if all is true
+func (db *DB) deferEffects(keys []string, all bool) *txEffects {
+	effects := &txEffects{
+		keys:   keys,
+		all:    all,
+		marked: make(map[string]struct{}),
+	}
+	db.auxMu.Lock()
+	defer db.auxMu.Unlock()
+	if all {
+		db.deferredAll = effects
+		return effects
+	}
+	for _, key := range keys {
+		db.deferred[key] = effects
+	}
+	return effects
+}
+
+// stopDeferring stops deferring side effects of the transaction, which are not emitted yet
+func (db *DB) stopDeferring(effects *txEffects) {
+	db.auxMu.Lock()
+	defer db.auxMu.Unlock()
+	if effects.all {
+		db.deferredAll = nil
+		return
+	}
+	for _, key := range effects.keys {
+		delete(db.deferred, key)
+	}
+}
+
+// markModified records that key is modified by the transaction, must be called with auxMu held
+func (effects *txEffects) markModified(key string) {
+	if _, ok := effects.marked[key]; !ok {
+		effects.marked[key] = struct{}{}
+		effects.modified = append(effects.modified, key)
+	}
+}
+
+// deferringEffects returns the effects deferring side effects of key, must be called with auxMu held
+func (db *DB) deferringEffects(key string) *txEffects {
+	if db.deferredAll != nil {
+		return db.deferredAll
+	}
+	return db.deferred[key]
+}
+
+// emitEffects publishes keyspace events of a committed transaction and signals keys it modified
+func (db *DB) emitEffects(id int64, effects *txEffects) {
+	for _, e := range effects.events {
+		db.publishKeyspaceEvent(e.class, e.event, e.key)
+	}
+	db.signalModifiedKeys(id, effects.modified...)
+	if effects.flushed {
+		db.tracking.invalidateAll()
+	}
+}
+
+// signalFlushed tells tracking clients to drop their caches after db is flushed, unless a transaction defers it
+func (db *DB) signalFlushed() {
+	db.auxMu.Lock()
+	effects := db.deferredAll
+	if effects != nil {
+		effects.flushed = true
+	}
+	db.auxMu.Unlock()
+	if effects == nil {
+		db.tracking.invalidateAll()
+	}
+}
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func TestWatch(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	other := connect(server)
+	execCmd(server, c, "set", "a", "1")
+
+	// a watched key modified by another client aborts EXEC
+	assertReply(t, execCmd(server, c, "watch", "a", "missing"), protocol.MakeOKReply())
+	execCmd(server, other, "set", "a", "2")
+	execCmd(server, c, "multi")
+	assertReply(t, execCmd(server, c, "set", "a", "3"), protocol.MakeQueuedReply())
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeNullMultiBulkReply())
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeBulkReply([]byte("2")))
+
+	// EXEC forgets watched keys, so the next transaction runs
+	execCmd(server, other, "set", "a", "4")
+	execCmd(server, c, "multi")
+	execCmd(server, c, "set", "a", "3")
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeMultiRawReply([]redis.Reply{protocol.MakeOKReply()}))
+
+	// creating a missing key and modifying a key watched in another db abort EXEC too
+	execCmd(server, c, "watch", "missing")
+	execCmd(server, other, "set", "missing", "x")
+	execCmd(server, c, "multi")
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeNullMultiBulkReply())
+	execCmd(server, c, "select", "1")
+	execCmd(server, c, "watch", "b")
+	execCmd(server, c, "select", "0")
+	execCmd(server, other, "select", "1")
+	execCmd(server, other, "set", "b", "x")
+	execCmd(server, c, "multi")
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeNullMultiBulkReply())
+
+	// UNWATCH forgets watched keys
+	execCmd(server, c, "watch", "a")
+	execCmd(server, c, "unwatch")
+	execCmd(server, other, "select", "0")
+	execCmd(server, other, "set", "a", "5")
+	execCmd(server, c, "multi")
+	execCmd(server, c, "get", "a")
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("5")),
+	}))
+	assertErr(t, execCmd(server, c, "exec"), "ERR EXEC without MULTI")
+}
+
+func TestWatchDiscard(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "watch", "a")
+	execCmd(server, c, "multi")
+	execCmd(server, c, "set", "a", "1")
+	assertReply(t, execCmd(server, c, "discard"), protocol.MakeOKReply())
+	assertReply(t, execCmd(server, c, "get", "a"), protocol.MakeNullBulkReply())
+
+	// DISCARD forgets watched keys
+	execCmd(server, connect(server), "set", "a", "2")
+	execCmd(server, c, "multi")
+	execCmd(server, c, "get", "a")
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("2")),
+	}))
+}
+
+func TestExecAbort(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	for _, invalid := range [][]string{
+		{"nosuchcmd"},
+		{"get"}, // wrong number of arguments
+		{"select", "1"},
+		{"client", "id"},
+	} {
+		execCmd(server, c, "multi")
+		execCmd(server, c, "set", "a", "1")
+		if _, ok := execCmd(server, c, invalid...).(protocol.ErrorReply); !ok {
+			t.Fatalf("%v should be rejected in multi", invalid)
+		}
+		assertErr(t, execCmd(server, c, "exec"), "EXECABORT")
+		assertReply(t, execCmd(server, c, "exists", "a"), protocol.MakeIntReply(0))
+	}
+	execCmd(server, c, "multi")
+	assertErr(t, execCmd(server, c, "multi"), "ERR MULTI calls can not be nested")
+	assertErr(t, execCmd(server, c, "watch", "a"), "ERR WATCH inside MULTI is not allowed")
+	execCmd(server, c, "discard")
+
+	// syntax errors are found at runtime, they don't abort the transaction
+	execCmd(server, c, "multi")
+	execCmd(server, c, "set", "a", "1")
+	assertReply(t, execCmd(server, c, "set", "a", "b", "c"), protocol.MakeQueuedReply())
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeOKReply(),
+		protocol.MakeSyntaxErrReply(),
+	}))
+}
+
+func TestTransactionRollback(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	execCmd(server, c, "set", "str", "v")
+	execCmd(server, c, "rpush", "list", "a", "b")
+
+	// by default commands before a failed command are not rolled back
+	execCmd(server, c, "multi")
+	execCmd(server, c, "rpush", "list", "c")
+	execCmd(server, c, "lpush", "str", "x")
+	execCmd(server, c, "set", "new", "1")
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeIntReply(3),
+		protocol.MakeWrongTypeErrReply(),
+		protocol.MakeOKReply(),
+	}))
+	assertReply(t, execCmd(server, c, "lrange", "list", "0", "-1"), bulks("a", "b", "c"))
+
+	assertReply(t, execCmd(server, c, "client", "tx-rollback", "on"), protocol.MakeOKReply())
+	execCmd(server, c, "multi")
+	execCmd(server, c, "rpush", "list", "d")
+	execCmd(server, c, "del", "new")
+	execCmd(server, c, "lpop", "list")
+	execCmd(server, c, "set", "created", "1")
+	execCmd(server, c, "rename", "list", "renamed")
+	execCmd(server, c, "lpush", "str", "x") // WRONGTYPE
+	execCmd(server, c, "set", "never", "1")
+	assertErr(t, execCmd(server, c, "exec"), "EXECABORT Transaction rolled back because command 6 (lpush) failed: WRONGTYPE")
+	assertReply(t, execCmd(server, c, "lrange", "list", "0", "-1"), bulks("a", "b", "c"))
+	assertReply(t, execCmd(server, c, "get", "new"), protocol.MakeBulkReply([]byte("1")))
+	assertReply(t, execCmd(server, c, "exists", "created", "renamed", "never"), protocol.MakeIntReply(0))
+
+	// the transaction succeeds without errors
+	execCmd(server, c, "multi")
+	execCmd(server, c, "rpush", "list", "d")
+	execCmd(server, c, "del", "new")
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeIntReply(4),
+		protocol.MakeIntReply(1),
+	}))
+}
+
+func TestWatchNoOpWrite(t *testing.T) {
+	server := makeTestServer(t)
+	c := connect(server)
+	other := connect(server)
+	execCmd(server, c, "sadd", "set", "m")
+	execCmd(server, c, "hset", "hash", "f", "v")
+
+	// writes which change nothing don't abort EXEC
+	execCmd(server, c, "watch", "nokey", "set")
+	assertReply(t, execCmd(server, other, "lpop", "nokey"), protocol.MakeNullBulkReply())
+	assertReply(t, execCmd(server, other, "del", "nokey"), protocol.MakeIntReply(0))
+	assertReply(t, execCmd(server, other, "sadd", "set", "m"), protocol.MakeIntReply(0))
+	assertErr(t, execCmd(server, other, "lpush", "set", "x"), "WRONGTYPE")
+	execCmd(server, c, "multi")
+	execCmd(server, c, "get", "x")
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeNullBulkReply(),
+	}))
+
+	// a write replying 0 may still modify the key
+	execCmd(server, c, "watch", "hash")
+	assertReply(t, execCmd(server, other, "hset", "hash", "f", "v2"), protocol.MakeIntReply(0))
+	execCmd(server, c, "multi")
+	assertReply(t, execCmd(server, c, "exec"), protocol.MakeNullMultiBulkReply())
+}
+
+func TestRollbackSideEffects(t *testing.T) {
+	server := makeNotifyingServer(t, "KEA")
+	c := connect(server)
+	execCmd(server, c, "set", "str", "v")
+	execCmd(server, c, "rpush", "list", "a")
+	sub := subscribe(t, server, "__keyspace@0__:list")
+	tracking, out := connectPipe(t, server)
+	execCmd(server, tracking, "hello", "3")
+	execCmd(server, tracking, "client", "tracking", "on")
+	execCmd(server, tracking, "lrange", "list", "0", "-1")
+	watching := connect(server)
+	execCmd(server, watching, "watch", "list")
+
+	// a rolled back transaction changes nothing, so nobody is told about its writes
+	execCmd(server, c, "client", "tx-rollback", "on")
+	execCmd(server, c, "multi")
+	execCmd(server, c, "rpush", "list", "b")
+	execCmd(server, c, "lpush", "str", "x")
+	assertErr(t, execCmd(server, c, "exec"), "EXECABORT")
+	sub.expectNothing(t)
+	out.expectNothing(t)
+	execCmd(server, watching, "multi")
+	assertReply(t, execCmd(server, watching, "exec"), protocol.MakeEmptyMultiBulkReply())
+
+	// effects of a committed transaction are emitted after it
+	execCmd(server, watching, "watch", "list")
+	execCmd(server, c, "multi")
+	execCmd(server, c, "rpush", "list", "b")
+	execCmd(server, c, "lpop", "list")
+	execCmd(server, c, "exec")
+	sub.expect(t, makeMessage("__keyspace@0__:list", "rpush"))
+	sub.expect(t, makeMessage("__keyspace@0__:list", "lpop"))
+	out.expect(t, makeInvalidation("list"))
+	execCmd(server, watching, "multi")
+	assertReply(t, execCmd(server, watching, "exec"), protocol.MakeNullMultiBulkReply())
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"maps"
+
+	"github.com/tonge3199/redis_go/interface/database"
+)
+
+// Undo logs are recorded for write commands of transactions running in rollback mode (see execTransaction).
+// An undo log is recorded right before the command is executed, and reverts everything the command did.
+//
+// By default the undo log snapshots the written keys given by the key specs of the command table,
+// which deep copies their values. It is simple and works for every command, but costs O(n) for big values,
+// that's why rollback is opt-in.
+//
+// Side effects of the transaction are deferred until it commits (see txEffects), so undo logs don't signal
+// restored keys: the transaction is going to drop all its side effects anyway.
+
+// undoFunc records the undo log of a write command before it is executed, both the undoFunc and the returned
+// undo log are called with locks of the command held
+type undoFunc func(db *DB, cmdLine [][]byte) (undo func())
+
+// keySnapshot is the state of a key before a command is executed
+type keySnapshot struct {
+	key      string
+	data     any // nil means the key didn't exist
+	fieldTTL bool
+}
+
+func (db *DB) snapshotKey(key string) *keySnapshot {
+	snapshot := &keySnapshot{key: key}
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return snapshot
+	}
+	snapshot.data = cloneData(entity.Data)
+	snapshot.fieldTTL = db.hasFieldTTL(key)
+	return snapshot
+}
+
+func (db *DB) restoreKey(snapshot *keySnapshot) {
+	db.Remove(snapshot.key)
+	if snapshot.data != nil {
+		db.PutEntity(snapshot.key, &database.DataEntity{Data: snapshot.data})
+		if snapshot.fieldTTL {
+			db.trackFieldTTL(snapshot.key)
+		}
+	}
+}
+
+// undoWriteKeys is the default undo log, it snapshots all keys written by the command
+func (db *DB) undoWriteKeys(cmd *command, cmdLine [][]byte) func() {
+	writeKeys, _ := cmd.keysOf(cmdLine)
+	snapshots := make([]*keySnapshot, len(writeKeys))
+	for i, key := range writeKeys {
+		snapshots[i] = db.snapshotKey(key)
+	}
+	return func() {
+		// a key may be written several times by one command, such as RENAME k k
+		for i := len(snapshots) - 1; i >= 0; i-- {
+			db.restoreKey(snapshots[i])
+		}
+	}
+}
+
+// undoFlushDB keeps all entities instead of copying them, since they are dropped by FLUSHDB and never modified again
+func undoFlushDB(db *DB, cmdLine [][]byte) func() {
+	entities := make(map[string]*database.DataEntity, db.data.Len())
+	db.data.ForEach(func(key string, val interface{}) bool {
+		entities[key] = val.(*database.DataEntity)
+		return true
+	})
+	db.auxMu.Lock()
+	fieldTTLKeys := maps.Clone(db.fieldTTLKeys)
+	db.auxMu.Unlock()
+	return func() {
+		// undo logs are applied in reverse order, so the db is already empty again unless FLUSHDB failed
+		for key, entity := range entities {
+			if _, exists := db.GetEntity(key); exists {
+				continue
+			}
+			db.PutEntity(key, entity)
+			if _, ok := fieldTTLKeys[key]; ok {
+				db.trackFieldTTL(key)
+			}
+		}
+	}
+}
+
+// undoLogOf records the undo log of a write command
+func (db *DB) undoLogOf(cmd *command, cmdLine [][]byte) func() {
+	if cmd.undo != nil {
+		return cmd.undo(db, cmdLine)
+	}
+	return db.undoWriteKeys(cmd, cmdLine)
+}
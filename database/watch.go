@@ -0,0 +1,167 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// WATCH is implemented with key versions:
+// every write (including expiration of hash fields and flushing) increases the version of modified keys,
+// WATCH remembers versions of watched keys in the connection, and EXEC aborts if any of them changed.
+//
+// Only versions of watched keys are observable, so a db only stores versions of keys watched by
+// some clients, the version of other keys is implicitly 0. A version is dropped once nobody watches the key.
+
+// addVersion increases versions of modified keys, must be called with write locks of keys held
+func (db *DB) addVersion(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	db.auxMu.Lock()
+	defer db.auxMu.Unlock()
+	for _, key := range keys {
+		if db.watchers[key] > 0 {
+			db.versions[key]++
+		}
+	}
+}
+
+// markModified records that key is modified by the running command, signalWrittenKeys signals it after the command.
+// Every modification of a key notifies a keyspace event like redis, so notifyKeyspaceEvent marks the key.
+// A key written by a transaction in rollback mode is recorded in the returned txEffects of the transaction instead
+func (db *DB) markModified(key string) *txEffects {
+	db.auxMu.Lock()
+	defer db.auxMu.Unlock()
+	if effects := db.deferringEffects(key); effects != nil {
+		effects.markModified(key)
+		return effects
+	}
+	db.modified[key] = struct{}{}
+	return nil
+}
+
+// signalWrittenKeys signals the keys marked modified among keys written by the client of id, and forgets their marks.
+// So a write which changed nothing, like LPOP of a missing key, doesn't abort EXEC watching the key
+// or invalidate cached keys. It must be called with write locks of keys held
+func (db *DB) signalWrittenKeys(id int64, keys ...string) {
+	var modified []string
+	db.auxMu.Lock()
+	for _, key := range keys {
+		if _, ok := db.modified[key]; ok {
+			delete(db.modified, key)
+			modified = append(modified, key)
+		}
+	}
+	db.auxMu.Unlock()
+	db.signalModifiedKeys(id, modified...)
+}
+
+// watch starts watching key and returns its version, must be called with auxMu held
+func (db *DB) watch(key string) uint32 {
+	db.watchers[key]++
+	return db.versions[key]
+}
+
+// unwatch stops watching key, must be called with auxMu held
+func (db *DB) unwatch(key string) {
+	db.watchers[key]--
+	if db.watchers[key] <= 0 {
+		delete(db.watchers, key)
+		delete(db.versions, key)
+	}
+}
+
+// watchingKey is the key of Connection.GetWatching, which contains db index since a client may watch several dbs
+func watchingKey(dbIndex int, key string) string {
+	return strconv.Itoa(dbIndex) + " " + key
+}
+
+func parseWatchingKey(s string) (int, string) {
+	index, key, _ := strings.Cut(s, " ")
+	dbIndex, _ := strconv.Atoi(index)
+	return dbIndex, key
+}
+
+// releaseWatching stops watching keys of db and returns whether any of them was modified
+func (db *DB) releaseWatching(c redis.Connection) (modified bool) {
+	db.auxMu.Lock()
+	defer db.auxMu.Unlock()
+	watching := c.GetWatching()
+	for wk, version := range watching {
+		dbIndex, key := parseWatchingKey(wk)
+		if dbIndex != db.index {
+			continue
+		}
+		if db.versions[key] != version {
+			modified = true
+		}
+		db.unwatch(key)
+		delete(watching, wk)
+	}
+	return modified
+}
+
+// releaseWatching stops watching keys of all dbs except the given one, and returns whether any of them was modified
+func (server *Server) releaseWatching(c redis.Connection, except *DB) (modified bool) {
+	dbs := make(map[int]struct{})
+	for wk := range c.GetWatching() {
+		dbIndex, _ := parseWatchingKey(wk)
+		dbs[dbIndex] = struct{}{}
+	}
+	for dbIndex := range dbs {
+		db := server.dbSet[dbIndex]
+		if db == except {
+			continue
+		}
+		if db.releaseWatching(c) {
+			modified = true
+		}
+	}
+	return modified
+}
+
+// execWatch watches keys for a following transaction
+//
+//	WATCH key [key ...]
+func (server *Server) execWatch(c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("watch")
+	}
+	if c.InMultiState() {
+		return protocol.MakeErrReply("ERR WATCH inside MULTI is not allowed")
+	}
+	db, errReply := server.selectDB(c.GetDBIndex())
+	if errReply != nil {
+		return errReply
+	}
+	keys := toKeys(args)
+	// versions of keys being written are increased after the write finished,
+	// read locks make sure that WATCH never sees a half-finished write
+	unlock := db.lockKeys(nil, keys)
+	defer unlock()
+	db.auxMu.Lock()
+	defer db.auxMu.Unlock()
+	watching := c.GetWatching()
+	for _, key := range keys {
+		wk := watchingKey(db.index, key)
+		if _, ok := watching[wk]; ok {
+			continue
+		}
+		watching[wk] = db.watch(key)
+	}
+	return protocol.MakeOKReply()
+}
+
+// execUnwatch forgets all watched keys
+//
+//	UNWATCH
+func (server *Server) execUnwatch(c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) != 0 {
+		return protocol.MakeArgNumErrReply("unwatch")
+	}
+	server.releaseWatching(c, nil)
+	return protocol.MakeOKReply()
+}
@@ -0,0 +1,104 @@
+// Package bitmap provides bit operations on the raw bytes of a string value
+//
+// Bits are numbered from the most significant bit of the first byte, the same as redis:
+//
+//	bytes  0b10000000 0b00000001
+//	bits   0          15
+package bitmap
+
+import "math/bits"
+
+// BitMap is the value of a string key viewed as an array of bits
+type BitMap []byte
+
+// Grow returns a BitMap with at least size bytes, new bytes are zero
+func (b BitMap) Grow(size int64) BitMap {
+	if int64(len(b)) >= size {
+		return b
+	}
+	return append(b, make([]byte, size-int64(len(b)))...)
+}
+
+// BitLen returns the number of bits
+func (b BitMap) BitLen() int64 {
+	return int64(len(b)) * 8
+}
+
+// GetBit returns the bit at offset, bits out of range are 0
+func (b BitMap) GetBit(offset int64) byte {
+	byteIndex := offset >> 3
+	if byteIndex >= int64(len(b)) {
+		return 0
+	}
+	return (b[byteIndex] >> (7 - offset&7)) & 1
+}
+
+// SetBit sets the bit at offset, the caller must Grow the BitMap first
+func (b BitMap) SetBit(offset int64, val byte) {
+	byteIndex := offset >> 3
+	mask := byte(1) << (7 - offset&7)
+	if val > 0 {
+		b[byteIndex] |= mask
+	} else {
+		b[byteIndex] &^= mask
+	}
+}
+
+// Count returns the number of 1 bits within the bit range [start, end]
+func (b BitMap) Count(start int64, end int64) int64 {
+	var count int64
+	for offset := start; offset <= end; {
+		if offset&7 == 0 && offset+7 <= end {
+			count += int64(bits.OnesCount8(b[offset>>3]))
+			offset += 8
+			continue
+		}
+		count += int64(b.GetBit(offset))
+		offset++
+	}
+	return count
+}
+
+// Find returns the offset of the first bit equal to val within the bit range [start, end], -1 if not found
+func (b BitMap) Find(val byte, start int64, end int64) int64 {
+	skip := byte(0) // a whole byte can be skipped if it equals skip
+	if val == 0 {
+		skip = 0xff
+	}
+	for offset := start; offset <= end; {
+		if offset&7 == 0 && offset+7 <= end && b[offset>>3] == skip {
+			offset += 8
+			continue
+		}
+		if b.GetBit(offset) == val {
+			return offset
+		}
+		offset++
+	}
+	return -1
+}
+
+// GetUnsigned reads a width bits unsigned integer at offset, bits out of range are 0
+func (b BitMap) GetUnsigned(offset int64, width int) uint64 {
+	var value uint64
+	for i := 0; i < width; i++ {
+		value = value<<1 | uint64(b.GetBit(offset+int64(i)))
+	}
+	return value
+}
+
+// GetSigned reads a width bits two's complement integer at offset
+func (b BitMap) GetSigned(offset int64, width int) int64 {
+	value := b.GetUnsigned(offset, width)
+	if width < 64 && value&(1<<(width-1)) != 0 {
+		value |= ^uint64(0) << width // sign extension
+	}
+	return int64(value)
+}
+
+// SetUnsigned writes the lower width bits of value at offset, the caller must Grow the BitMap first
+func (b BitMap) SetUnsigned(offset int64, width int, value uint64) {
+	for i := 0; i < width; i++ {
+		b.SetBit(offset+int64(i), byte(value>>(width-1-i))&1)
+	}
+}
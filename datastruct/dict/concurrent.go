@@ -0,0 +1,330 @@
+package dict
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// ConcurrentDict is thread safe map using sharding lock
+//
+// 键空间被分为 2^n 个 shard，每个 shard 由独立的读写锁保护，
+// 不同 shard 上的操作可以并行执行
+type ConcurrentDict struct {
+	table      []*shard
+	count      int32
+	shardCount int
+	shardBits  int // shardCount == 1 << shardBits
+}
+
+type shard struct {
+	t     table
+	mutex sync.RWMutex
+}
+
+// computeCapacity rounds param up to the next power of 2
+//
+// Example: 10 -> 16, 16 -> 16, 17 -> 32
+func computeCapacity(param int) (size int) {
+	if param <= 16 {
+		return 16
+	}
+	n := param - 1
+	n |= n >> 1
+	n |= n >> 2
+	n |= n >> 4
+	n |= n >> 8
+	n |= n >> 16
+	if n < 0 || n >= math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return n + 1
+}
+
+// MakeConcurrent creates ConcurrentDict with the given shard count
+func MakeConcurrent(shardCount int) *ConcurrentDict {
+	shardCount = computeCapacity(shardCount)
+	table := make([]*shard, shardCount)
+	for i := 0; i < shardCount; i++ {
+		table[i] = &shard{}
+	}
+	d := &ConcurrentDict{
+		count:      0,
+		table:      table,
+		shardCount: shardCount,
+		shardBits:  bits.TrailingZeros(uint(shardCount)),
+	}
+	return d
+}
+
+const prime32 = uint32(16777619)
+
+// fnv32 is the 32-bit FNV-1 hash
+func fnv32(key string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash *= prime32
+		hash ^= uint32(key[i])
+	}
+	return hash
+}
+
+func (dict *ConcurrentDict) spread(hashCode uint32) uint32 {
+	if dict == nil {
+		panic("dict is nil")
+	}
+	tableSize := uint32(len(dict.table))
+	return (tableSize - 1) & hashCode
+}
+
+func (dict *ConcurrentDict) getShard(index uint32) *shard {
+	if dict == nil {
+		panic("dict is nil")
+	}
+	return dict.table[index]
+}
+
+// Get returns the binding value and whether the key is exist
+func (dict *ConcurrentDict) Get(key string) (val interface{}, exists bool) {
+	if dict == nil {
+		panic("dict is nil")
+	}
+	hashCode := fnv32(key)
+	index := dict.spread(hashCode)
+	s := dict.getShard(index)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.t.get(key)
+}
+
+// Len returns the number of dict
+func (dict *ConcurrentDict) Len() int {
+	if dict == nil {
+		panic("dict is nil")
+	}
+	return int(atomic.LoadInt32(&dict.count))
+}
+
+// Put puts key value into dict and returns the number of new inserted key-value
+func (dict *ConcurrentDict) Put(key string, val interface{}) (result int) {
+	if dict == nil {
+		panic("dict is nil")
+	}
+	hashCode := fnv32(key)
+	index := dict.spread(hashCode)
+	s := dict.getShard(index)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.t.put(key, val) {
+		return 0
+	}
+	dict.addCount()
+	return 1
+}
+
+// PutIfAbsent puts value if the key is not exists and returns the number of updated key-value
+func (dict *ConcurrentDict) PutIfAbsent(key string, val interface{}) (result int) {
+	if dict == nil {
+		panic("dict is nil")
+	}
+	hashCode := fnv32(key)
+	index := dict.spread(hashCode)
+	s := dict.getShard(index)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.t.find(key) != nil {
+		return 0
+	}
+	s.t.put(key, val)
+	dict.addCount()
+	return 1
+}
+
+// PutIfExists puts value if the key is exist and returns the number of inserted key-value
+func (dict *ConcurrentDict) PutIfExists(key string, val interface{}) (result int) {
+	if dict == nil {
+		panic("dict is nil")
+	}
+	hashCode := fnv32(key)
+	index := dict.spread(hashCode)
+	s := dict.getShard(index)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e := s.t.find(key); e != nil {
+		e.val = val
+		return 1
+	}
+	return 0
+}
+
+// Remove removes the key and return the number of deleted key-value
+func (dict *ConcurrentDict) Remove(key string) (val interface{}, result int) {
+	if dict == nil {
+		panic("dict is nil")
+	}
+	hashCode := fnv32(key)
+	index := dict.spread(hashCode)
+	s := dict.getShard(index)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if val, ok := s.t.remove(key); ok {
+		dict.decreaseCount()
+		return val, 1
+	}
+	return nil, 0
+}
+
+func (dict *ConcurrentDict) addCount() int32 {
+	return atomic.AddInt32(&dict.count, 1)
+}
+
+func (dict *ConcurrentDict) decreaseCount() int32 {
+	return atomic.AddInt32(&dict.count, -1)
+}
+
+// ForEach traversal the dict
+// it may not visit new entry inserted during traversal
+func (dict *ConcurrentDict) ForEach(consumer Consumer) {
+	if dict == nil {
+		panic("dict is nil")
+	}
+
+	for _, s := range dict.table {
+		s.mutex.RLock()
+		f := func() bool {
+			defer s.mutex.RUnlock()
+			return s.t.forEach(consumer)
+		}
+		if !f() {
+			break
+		}
+	}
+}
+
+// Keys returns all keys in dict
+func (dict *ConcurrentDict) Keys() []string {
+	keys := make([]string, 0, dict.Len())
+	dict.ForEach(func(key string, val interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// RandomKey returns a key randomly
+func (s *shard) RandomKey(r *rand.Rand) string {
+	if s == nil {
+		panic("shard is nil")
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	key, _ := s.t.randomKey(r)
+	return key
+}
+
+// Scan visits the dict from cursor and returns the cursor to continue with, see Dict.Scan.
+//
+// Shards are visited one by one, the low shardBits bits of cursor is the index of shard
+// and the rest bits are the cursor of the table of the shard
+func (dict *ConcurrentDict) Scan(cursor uint64, count int, consumer Consumer) uint64 {
+	if dict == nil {
+		panic("dict is nil")
+	}
+	found := 0
+	counter := func(key string, val interface{}) bool {
+		found++
+		return consumer(key, val)
+	}
+	mask := uint64(dict.shardCount - 1)
+	steps := count * scanStepsPerElement
+	for {
+		index := cursor & mask
+		s := dict.table[index]
+		s.mutex.RLock()
+		empty := s.t.size == 0
+		next := s.t.scan(cursor>>dict.shardBits, counter)
+		s.mutex.RUnlock()
+		if next != 0 {
+			cursor = next<<dict.shardBits | index
+		} else if index == mask {
+			return 0
+		} else {
+			cursor = index + 1
+		}
+		// most shards of a small keyspace are empty, skipping them costs almost nothing
+		if !empty {
+			steps--
+		}
+		if found >= count || steps <= 0 {
+			return cursor
+		}
+	}
+}
+
+// RandomKeys randomly returns keys of the given number, may contain duplicated key
+func (dict *ConcurrentDict) RandomKeys(limit int) []string {
+	size := dict.Len()
+	if limit >= size {
+		return dict.Keys()
+	}
+	shardCount := len(dict.table)
+
+	result := make([]string, limit)
+	nR := rand.New(rand.NewSource(rand.Int63()))
+	for i := 0; i < limit; {
+		s := dict.getShard(uint32(nR.Intn(shardCount)))
+		if s == nil {
+			continue
+		}
+		key := s.RandomKey(nR)
+		if key != "" {
+			result[i] = key
+			i++
+		}
+	}
+	return result
+}
+
+// RandomDistinctKeys randomly returns keys of the given number, won't contain duplicated key
+func (dict *ConcurrentDict) RandomDistinctKeys(limit int) []string {
+	size := dict.Len()
+	if limit >= size {
+		return dict.Keys()
+	}
+
+	shardCount := len(dict.table)
+	result := make(map[string]struct{})
+	nR := rand.New(rand.NewSource(rand.Int63()))
+	for len(result) < limit {
+		shardIndex := uint32(nR.Intn(shardCount))
+		s := dict.getShard(shardIndex)
+		if s == nil {
+			continue
+		}
+		key := s.RandomKey(nR)
+		if key != "" {
+			if _, exists := result[key]; !exists {
+				result[key] = struct{}{}
+			}
+		}
+	}
+	arr := make([]string, limit)
+	i := 0
+	for k := range result {
+		arr[i] = k
+		i++
+	}
+	return arr
+}
+
+// Clear removes all keys in dict
+func (dict *ConcurrentDict) Clear() {
+	*dict = *MakeConcurrent(dict.shardCount)
+}
@@ -0,0 +1,30 @@
+// Package dict provides the key-value containers used by the keyspace and the hash/set encodings
+package dict
+
+// Consumer is used to traversal dict, if it returns false the traversal will be break
+type Consumer func(key string, val interface{}) bool
+
+// scanStepsPerElement bounds the number of buckets visited by Scan to count * scanStepsPerElement,
+// so that a sparse dict doesn't block for long, the same as redis
+const scanStepsPerElement = 10
+
+// Dict is interface of a key-value data structure
+type Dict interface {
+	Get(key string) (val interface{}, exists bool)
+	Len() int
+	Put(key string, val interface{}) (result int)
+	PutIfAbsent(key string, val interface{}) (result int)
+	PutIfExists(key string, val interface{}) (result int)
+	Remove(key string) (val interface{}, result int)
+	ForEach(consumer Consumer)
+	// Scan visits entries from cursor until about count entries are visited, and returns the cursor
+	// to continue with, 0 means the iteration is done. An iteration starts with cursor 0.
+	// Entries present during the whole iteration are visited at least once, even if the dict is
+	// modified between calls, but some of them may be visited more than once.
+	// The return value of consumer is ignored
+	Scan(cursor uint64, count int, consumer Consumer) uint64
+	Keys() []string
+	RandomKeys(limit int) []string
+	RandomDistinctKeys(limit int) []string
+	Clear()
+}
@@ -0,0 +1,136 @@
+package dict
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// makeDicts returns both implementations of Dict
+func makeDicts() map[string]Dict {
+	return map[string]Dict{
+		"simple":     MakeSimple(),
+		"concurrent": MakeConcurrent(16),
+	}
+}
+
+func TestDictPut(t *testing.T) {
+	for name, d := range makeDicts() {
+		if d.PutIfExists("a", 1) != 0 || d.Len() != 0 {
+			t.Fatalf("%s: PutIfExists should not insert", name)
+		}
+		if d.Put("a", 1) != 1 || d.Put("a", 2) != 0 {
+			t.Fatalf("%s: Put should return 1 only for a new key", name)
+		}
+		if d.PutIfAbsent("a", 3) != 0 || d.PutIfAbsent("b", 3) != 1 {
+			t.Fatalf("%s: PutIfAbsent should only insert a new key", name)
+		}
+		if d.PutIfExists("a", 4) != 1 {
+			t.Fatalf("%s: PutIfExists should update an existing key", name)
+		}
+		if val, ok := d.Get("a"); !ok || val.(int) != 4 {
+			t.Fatalf("%s: Get returns %v, %v", name, val, ok)
+		}
+		if val, result := d.Remove("b"); result != 1 || val.(int) != 3 {
+			t.Fatalf("%s: Remove returns %v, %d", name, val, result)
+		}
+		if _, result := d.Remove("b"); result != 0 || d.Len() != 1 {
+			t.Fatalf("%s: Remove of a missing key should return 0", name)
+		}
+		d.Clear()
+		if _, ok := d.Get("a"); ok || d.Len() != 0 {
+			t.Fatalf("%s: Clear should remove all keys", name)
+		}
+	}
+}
+
+func TestDictIteration(t *testing.T) {
+	for name, d := range makeDicts() {
+		for i := 0; i < 1000; i++ {
+			d.Put(strconv.Itoa(i), i)
+		}
+		keys := d.Keys()
+		sort.Slice(keys, func(i, j int) bool {
+			a, _ := strconv.Atoi(keys[i])
+			b, _ := strconv.Atoi(keys[j])
+			return a < b
+		})
+		for i, key := range keys {
+			if key != strconv.Itoa(i) {
+				t.Fatalf("%s: Keys() returns %s at %d", name, key, i)
+			}
+		}
+		visited := 0
+		d.ForEach(func(key string, val interface{}) bool {
+			visited++
+			return visited < 10
+		})
+		if visited != 10 {
+			t.Fatalf("%s: ForEach visits %d keys, expected it to stop at 10", name, visited)
+		}
+
+		scanned := make(map[string]bool)
+		cursor, calls := uint64(0), 0
+		for {
+			cursor = d.Scan(cursor, 10, func(key string, val interface{}) bool {
+				scanned[key] = true
+				return true
+			})
+			calls++
+			if cursor == 0 {
+				break
+			}
+		}
+		if len(scanned) != 1000 || calls < 50 {
+			t.Fatalf("%s: Scan visits %d keys in %d calls", name, len(scanned), calls)
+		}
+
+		distinct := d.RandomDistinctKeys(100)
+		picked := make(map[string]bool)
+		for _, key := range distinct {
+			if _, ok := d.Get(key); !ok || picked[key] {
+				t.Fatalf("%s: RandomDistinctKeys returns an unknown or duplicated key %s", name, key)
+			}
+			picked[key] = true
+		}
+		if len(distinct) != 100 || len(d.RandomDistinctKeys(2000)) != 1000 || len(d.RandomKeys(50)) != 50 {
+			t.Fatalf("%s: random keys are of wrong numbers", name)
+		}
+	}
+}
+
+func TestSimpleDictClone(t *testing.T) {
+	d := MakeSimple()
+	for i := 0; i < 100; i++ {
+		d.Put(strconv.Itoa(i), i)
+	}
+	clone := d.Clone()
+	clone.Put("0", -1)
+	clone.Remove("1")
+	if val, _ := d.Get("0"); val.(int) != 0 || d.Len() != 100 || clone.Len() != 99 {
+		t.Fatal("a clone should not share entries")
+	}
+}
+
+func TestConcurrentDictParallel(t *testing.T) {
+	d := MakeConcurrent(16)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := strconv.Itoa(g*1000 + i)
+				d.Put(key, i)
+				if i%2 == 0 {
+					d.Remove(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if d.Len() != 4000 || len(d.Keys()) != 4000 {
+		t.Fatalf("Len() is %d, expected 4000", d.Len())
+	}
+}
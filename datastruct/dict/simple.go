@@ -0,0 +1,147 @@
+package dict
+
+import "math/rand"
+
+// SimpleDict is a hash table, it is not thread safe
+type SimpleDict struct {
+	t *table
+}
+
+// MakeSimple makes a new map
+func MakeSimple() *SimpleDict {
+	return &SimpleDict{
+		t: &table{},
+	}
+}
+
+// Get returns the binding value and whether the key is exist
+func (dict *SimpleDict) Get(key string) (val interface{}, exists bool) {
+	return dict.t.get(key)
+}
+
+// Len returns the number of dict
+func (dict *SimpleDict) Len() int {
+	return dict.t.size
+}
+
+// Put puts key value into dict and returns the number of new inserted key-value
+func (dict *SimpleDict) Put(key string, val interface{}) (result int) {
+	if dict.t.put(key, val) {
+		return 1
+	}
+	return 0
+}
+
+// PutIfAbsent puts value if the key is not exists and returns the number of updated key-value
+func (dict *SimpleDict) PutIfAbsent(key string, val interface{}) (result int) {
+	if dict.t.find(key) != nil {
+		return 0
+	}
+	dict.t.put(key, val)
+	return 1
+}
+
+// PutIfExists puts value if the key is exist and returns the number of inserted key-value
+func (dict *SimpleDict) PutIfExists(key string, val interface{}) (result int) {
+	e := dict.t.find(key)
+	if e == nil {
+		return 0
+	}
+	e.val = val
+	return 1
+}
+
+// Remove removes the key and return the number of deleted key-value
+func (dict *SimpleDict) Remove(key string) (val interface{}, result int) {
+	val, existed := dict.t.remove(key)
+	if existed {
+		return val, 1
+	}
+	return nil, 0
+}
+
+// Keys returns all keys in dict
+func (dict *SimpleDict) Keys() []string {
+	result := make([]string, 0, dict.t.size)
+	dict.t.forEach(func(key string, val interface{}) bool {
+		result = append(result, key)
+		return true
+	})
+	return result
+}
+
+// ForEach traversal the dict
+func (dict *SimpleDict) ForEach(consumer Consumer) {
+	dict.t.forEach(consumer)
+}
+
+// Scan visits the dict from cursor and returns the cursor to continue with, see Dict.Scan
+func (dict *SimpleDict) Scan(cursor uint64, count int, consumer Consumer) uint64 {
+	found := 0
+	counter := func(key string, val interface{}) bool {
+		found++
+		return consumer(key, val)
+	}
+	for steps := count * scanStepsPerElement; ; steps-- {
+		cursor = dict.t.scan(cursor, counter)
+		if cursor == 0 || found >= count || steps <= 1 {
+			return cursor
+		}
+	}
+}
+
+// RandomKeys randomly returns keys of the given number, may contain duplicated key
+func (dict *SimpleDict) RandomKeys(limit int) []string {
+	if dict.t.size == 0 {
+		return nil
+	}
+	r := rand.New(rand.NewSource(rand.Int63()))
+	result := make([]string, limit)
+	for i := range result {
+		result[i], _ = dict.t.randomKey(r)
+	}
+	return result
+}
+
+// RandomDistinctKeys randomly returns keys of the given number, won't contain duplicated key
+func (dict *SimpleDict) RandomDistinctKeys(limit int) []string {
+	size := dict.t.size
+	if limit >= size {
+		return dict.Keys()
+	}
+	r := rand.New(rand.NewSource(rand.Int63()))
+	if limit*3 > size {
+		// sampling would mostly hit picked keys, shuffling all keys is cheaper
+		keys := dict.Keys()
+		r.Shuffle(len(keys), func(i, j int) {
+			keys[i], keys[j] = keys[j], keys[i]
+		})
+		return keys[:limit]
+	}
+	picked := make(map[string]struct{}, limit)
+	result := make([]string, 0, limit)
+	for len(result) < limit {
+		key, _ := dict.t.randomKey(r)
+		if _, ok := picked[key]; !ok {
+			picked[key] = struct{}{}
+			result = append(result, key)
+		}
+	}
+	return result
+}
+
+// Clear removes all keys in dict
+func (dict *SimpleDict) Clear() {
+	*dict = *MakeSimple()
+}
+
+// Clone returns a copy of dict, values are shallow copied
+func (dict *SimpleDict) Clone() *SimpleDict {
+	clone := MakeSimple()
+	clone.t.resize(len(dict.t.buckets))
+	dict.t.forEach(func(key string, val interface{}) bool {
+		clone.t.put(key, val)
+		return true
+	})
+	return clone
+}
@@ -0,0 +1,187 @@
+package dict
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"math/rand"
+)
+
+// table is a chained hash table whose size is always a power of 2, it backs SimpleDict and the shards of
+// ConcurrentDict.
+//
+// Unlike a go map, a table can be iterated with a cursor across calls (see scan),
+// which is required by the SCAN family of commands.
+// It grows when the number of entries reaches the number of buckets,
+// and shrinks when less than 1/8 of the buckets are used, the same as redis
+type table struct {
+	buckets []*entry
+	size    int
+	// an upper bound of chain lengths, it never decreases until the table is resized
+	maxChain int
+}
+
+type entry struct {
+	key  string
+	val  interface{}
+	next *entry
+}
+
+const (
+	minTableSize = 4
+	minFillRatio = 8 // shrink if size * minFillRatio < len(buckets)
+)
+
+// hashSeed is randomized per process, so that clients can't craft keys colliding in the same bucket
+var hashSeed = maphash.MakeSeed()
+
+func hashKey(key string) uint64 {
+	return maphash.String(hashSeed, key)
+}
+
+func (t *table) mask() uint64 {
+	return uint64(len(t.buckets) - 1)
+}
+
+func (t *table) find(key string) *entry {
+	if t.size == 0 {
+		return nil
+	}
+	for e := t.buckets[hashKey(key)&t.mask()]; e != nil; e = e.next {
+		if e.key == key {
+			return e
+		}
+	}
+	return nil
+}
+
+func (t *table) get(key string) (interface{}, bool) {
+	e := t.find(key)
+	if e == nil {
+		return nil, false
+	}
+	return e.val, true
+}
+
+// put sets the value of key, returns true if the key is new
+func (t *table) put(key string, val interface{}) bool {
+	if e := t.find(key); e != nil {
+		e.val = val
+		return false
+	}
+	if t.size >= len(t.buckets) {
+		t.resize(max(minTableSize, len(t.buckets)*2))
+	}
+	index := hashKey(key) & t.mask()
+	t.buckets[index] = &entry{key: key, val: val, next: t.buckets[index]}
+	t.size++
+	t.maxChain = max(t.maxChain, chainLen(t.buckets[index]))
+	return true
+}
+
+// remove deletes key, returns the removed value
+func (t *table) remove(key string) (interface{}, bool) {
+	if t.size == 0 {
+		return nil, false
+	}
+	index := hashKey(key) & t.mask()
+	for p := &t.buckets[index]; *p != nil; p = &(*p).next {
+		if e := *p; e.key == key {
+			*p = e.next
+			t.size--
+			if len(t.buckets) > minTableSize && t.size*minFillRatio < len(t.buckets) {
+				t.resize(max(minTableSize, nextPowerOf2(t.size)))
+			}
+			return e.val, true
+		}
+	}
+	return nil, false
+}
+
+func nextPowerOf2(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// resize rehashes all entries into size buckets at once
+func (t *table) resize(size int) {
+	buckets := make([]*entry, size)
+	mask := uint64(size - 1)
+	for _, e := range t.buckets {
+		for e != nil {
+			next := e.next
+			index := hashKey(e.key) & mask
+			e.next = buckets[index]
+			buckets[index] = e
+			e = next
+		}
+	}
+	t.buckets = buckets
+	t.maxChain = 0
+	for _, head := range buckets {
+		t.maxChain = max(t.maxChain, chainLen(head))
+	}
+}
+
+func chainLen(head *entry) int {
+	n := 0
+	for e := head; e != nil; e = e.next {
+		n++
+	}
+	return n
+}
+
+func (t *table) forEach(consumer Consumer) bool {
+	for _, e := range t.buckets {
+		for ; e != nil; e = e.next {
+			if !consumer(e.key, e.val) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// scan visits all entries of the bucket at cursor and returns the cursor of the next bucket, 0 means done.
+//
+// Buckets are visited in the order of reversed bits of the cursor rather than 0, 1, 2 ...
+// so that entries present during the whole iteration are visited at least once even if the table
+// was resized between calls: the table of size 2^n splits bucket i into buckets i and i + 2^n when
+// growing, both of them have the same low n bits, which means the same high n bits after reversing,
+// so a bucket visited before growing never needs to be visited again, and vice versa when shrinking.
+// Some entries may be visited twice after shrinking
+func (t *table) scan(cursor uint64, consumer Consumer) uint64 {
+	if t.size == 0 {
+		return 0
+	}
+	mask := t.mask()
+	for e := t.buckets[cursor&mask]; e != nil; e = e.next {
+		consumer(e.key, e.val)
+	}
+	// increase the reversed cursor: set unmasked bits so that the carry crosses them
+	cursor |= ^mask
+	cursor = bits.Reverse64(cursor)
+	cursor++
+	return bits.Reverse64(cursor)
+}
+
+// randomKey returns a random key, every key has the same chance.
+//
+// Picking a random bucket then a random entry of its chain favors keys in short chains,
+// so a random slot of the len(buckets) * maxChain grid is picked instead, retrying on empty slots.
+// Since the table is at least 1/8 full and chains are short, it takes a few tries on average
+func (t *table) randomKey(r *rand.Rand) (string, bool) {
+	if t.size == 0 {
+		return "", false
+	}
+	for {
+		e := t.buckets[r.Intn(len(t.buckets))]
+		for i := r.Intn(t.maxChain); e != nil && i > 0; i-- {
+			e = e.next
+		}
+		if e != nil {
+			return e.key, true
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package dict
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// checkTable checks the size, the load factor and maxChain of t
+func checkTable(t *testing.T, tb *table, expected map[string]int) {
+	t.Helper()
+	if tb.size != len(expected) {
+		t.Fatalf("size is %d, expected %d", tb.size, len(expected))
+	}
+	if len(tb.buckets)&(len(tb.buckets)-1) != 0 {
+		t.Fatalf("%d buckets is not a power of 2", len(tb.buckets))
+	}
+	if tb.size > len(tb.buckets) || (len(tb.buckets) > minTableSize && tb.size*minFillRatio < len(tb.buckets)) {
+		t.Fatalf("%d entries in %d buckets", tb.size, len(tb.buckets))
+	}
+	for _, head := range tb.buckets {
+		if chainLen(head) > tb.maxChain {
+			t.Fatalf("a chain is longer than maxChain %d", tb.maxChain)
+		}
+	}
+	for key, val := range expected {
+		if got, ok := tb.get(key); !ok || got.(int) != val {
+			t.Fatalf("get(%s) returns %v, %v, expected %d", key, got, ok, val)
+		}
+	}
+}
+
+func TestTableRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tb := &table{}
+	expected := make(map[string]int)
+	for i := 0; i < 20000; i++ {
+		// grow to about 1000 keys, then shrink to empty
+		key := strconv.Itoa(r.Intn(1000))
+		if (i < 10000) == (r.Intn(4) == 0) {
+			_, existed := expected[key]
+			if _, ok := tb.remove(key); ok != existed {
+				t.Fatalf("remove(%s) returns %v", key, ok)
+			}
+			delete(expected, key)
+		} else {
+			_, existed := expected[key]
+			if tb.put(key, i) == existed {
+				t.Fatalf("put(%s) is wrong", key)
+			}
+			expected[key] = i
+		}
+		if i%500 == 0 {
+			checkTable(t, tb, expected)
+		}
+	}
+	checkTable(t, tb, expected)
+}
+
+// scanAll iterates tb by scan, calls modify between calls and returns how many times each key is visited
+func scanAll(tb *table, modify func()) map[string]int {
+	visited := make(map[string]int)
+	cursor := uint64(0)
+	for {
+		cursor = tb.scan(cursor, func(key string, val interface{}) bool {
+			visited[key]++
+			return true
+		})
+		if cursor == 0 {
+			return visited
+		}
+		modify()
+	}
+}
+
+func TestTableScan(t *testing.T) {
+	tb := &table{}
+	for i := 0; i < 100; i++ {
+		tb.put(strconv.Itoa(i), i)
+	}
+	visited := scanAll(tb, func() {})
+	for i := 0; i < 100; i++ {
+		if visited[strconv.Itoa(i)] != 1 {
+			t.Fatalf("%d is visited %d times without modifications", i, visited[strconv.Itoa(i)])
+		}
+	}
+
+	// keys present during the whole iteration are visited even if the table grows and shrinks
+	for _, grow := range []bool{true, false} {
+		tb = &table{}
+		for i := 0; i < 100; i++ {
+			tb.put(strconv.Itoa(i), i)
+		}
+		if !grow {
+			for i := 100; i < 1000; i++ {
+				tb.put(strconv.Itoa(i), i)
+			}
+		}
+		next := 1000
+		visited = scanAll(tb, func() {
+			if grow {
+				// stop growing at some point, or the iteration never ends
+				for i := 0; i < 20 && next < 3000; i++ {
+					tb.put(strconv.Itoa(next), next)
+					next++
+				}
+			} else {
+				for i := 0; i < 50 && next > 100; i++ {
+					next--
+					tb.remove(strconv.Itoa(next))
+				}
+			}
+		})
+		for i := 0; i < 100; i++ {
+			if visited[strconv.Itoa(i)] == 0 {
+				t.Fatalf("%d is not visited while the table is resized, grow %v", i, grow)
+			}
+		}
+	}
+}
+
+func TestTableRandomKey(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tb := &table{}
+	if _, ok := tb.randomKey(r); ok {
+		t.Fatal("an empty table has no random key")
+	}
+	for i := 0; i < 10; i++ {
+		tb.put(strconv.Itoa(i), i)
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		key, _ := tb.randomKey(r)
+		counts[key]++
+	}
+	// every key is expected 1000 times
+	for i := 0; i < 10; i++ {
+		if n := counts[strconv.Itoa(i)]; n < 800 || n > 1200 {
+			t.Fatalf("%d is picked %d times of 10000", i, n)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+// Package hash implements the redis hash type with two encodings
+package hash
+
+import (
+	"maps"
+	"math/rand"
+	"time"
+
+	"github.com/tonge3199/redis_go/datastruct/dict"
+	"github.com/tonge3199/redis_go/datastruct/listpack"
+)
+
+// Encoding names, the same as OBJECT ENCODING of redis
+const (
+	EncodingListpack  = "listpack"
+	EncodingHashtable = "hashtable"
+)
+
+// Hash is a field-value map.
+//
+// A small hash is stored in a listpack as [f1, v1, f2, v2 ...], lookups scan the listpack linearly.
+// Once the number of fields exceeds maxEntries, or a field or value is longer than maxValue,
+// it is converted into a hash table and never converted back, the same as redis.
+//
+// Fields may have their own expire time (HEXPIRE). An expired field is invisible to all methods
+// even before it is physically deleted by RemoveExpired.
+type Hash struct {
+	lp   *listpack.ListPack // nil if converted to hash table
+	dict *dict.SimpleDict   // field -> []byte, nil if using listpack
+
+	// field -> expire time in unix milliseconds, nil if no field has ttl
+	expires map[string]int64
+
+	maxEntries int
+	maxValue   int
+}
+
+// Make creates an empty hash, maxEntries and maxValue are the thresholds of listpack encoding
+func Make(maxEntries int, maxValue int) *Hash {
+	return &Hash{
+		lp:         listpack.New(),
+		maxEntries: maxEntries,
+		maxValue:   maxValue,
+	}
+}
+
+// Encoding returns the current encoding name
+func (h *Hash) Encoding() string {
+	if h.lp != nil {
+		return EncodingListpack
+	}
+	return EncodingHashtable
+}
+
+// Len returns the number of fields
+func (h *Hash) Len() int {
+	size := h.rawLen()
+	if len(h.expires) > 0 {
+		now := time.Now().UnixMilli()
+		for _, when := range h.expires {
+			if when <= now {
+				size--
+			}
+		}
+	}
+	return size
+}
+
+// rawLen returns the number of fields including expired ones
+func (h *Hash) rawLen() int {
+	if h.lp != nil {
+		return h.lp.Len() / 2
+	}
+	return h.dict.Len()
+}
+
+// find returns the offset of the field entry in listpack, -1 if not found
+func (h *Hash) find(field string) int {
+	for offset := h.lp.First(); offset < h.lp.End(); {
+		f, next := h.lp.Next(offset)
+		if string(f) == field {
+			return offset
+		}
+		_, offset = h.lp.Next(next) // skip value
+	}
+	return -1
+}
+
+// Get returns the value of field
+func (h *Hash) Get(field string) ([]byte, bool) {
+	if h.isExpired(field, time.Now().UnixMilli()) {
+		return nil, false
+	}
+	if h.lp != nil {
+		offset := h.find(field)
+		if offset < 0 {
+			return nil, false
+		}
+		_, valOffset := h.lp.Next(offset)
+		val, _ := h.lp.Next(valOffset)
+		// copy, so that the caller won't see changes of listpack
+		return append([]byte(nil), val...), true
+	}
+	raw, ok := h.dict.Get(field)
+	if !ok {
+		return nil, false
+	}
+	return raw.([]byte), true
+}
+
+// Set puts field-value and returns 1 if field is new, 0 if an existing field is updated.
+// The ttl of field is removed, see SetKeepTTL
+func (h *Hash) Set(field string, val []byte) int {
+	expired := h.isExpired(field, time.Now().UnixMilli())
+	delete(h.expires, field)
+	if h.rawSet(field, val) == 0 && !expired {
+		return 0
+	}
+	return 1
+}
+
+// SetKeepTTL is like Set but keeps the ttl of field, it is used by commands like HINCRBY
+func (h *Hash) SetKeepTTL(field string, val []byte) int {
+	if h.isExpired(field, time.Now().UnixMilli()) {
+		delete(h.expires, field)
+		h.rawSet(field, val)
+		return 1
+	}
+	return h.rawSet(field, val)
+}
+
+func (h *Hash) rawSet(field string, val []byte) int {
+	if h.lp != nil && (len(field) > h.maxValue || len(val) > h.maxValue) {
+		h.convert()
+	}
+	if h.lp == nil {
+		return h.dict.Put(field, val)
+	}
+	offset := h.find(field)
+	if offset >= 0 {
+		_, valOffset := h.lp.Next(offset)
+		h.lp.Replace(valOffset, val)
+		return 0
+	}
+	if h.rawLen()+1 > h.maxEntries {
+		h.convert()
+		return h.dict.Put(field, val)
+	}
+	h.lp.Append([]byte(field), val)
+	return 1
+}
+
+// Remove deletes field and returns the number of deleted fields
+func (h *Hash) Remove(field string) int {
+	expired := h.isExpired(field, time.Now().UnixMilli())
+	delete(h.expires, field)
+	if h.rawRemove(field) == 0 || expired {
+		return 0
+	}
+	return 1
+}
+
+func (h *Hash) rawRemove(field string) int {
+	if h.lp == nil {
+		_, result := h.dict.Remove(field)
+		return result
+	}
+	offset := h.find(field)
+	if offset < 0 {
+		return 0
+	}
+	h.lp.Delete(offset, 2)
+	return 1
+}
+
+// convert moves all fields from listpack into hash table
+func (h *Hash) convert() {
+	d := dict.MakeSimple()
+	h.rawForEach(func(field string, val []byte) bool {
+		d.Put(field, append([]byte(nil), val...))
+		return true
+	})
+	h.dict = d
+	h.lp = nil
+}
+
+// Consumer visits a field-value pair, returns false to stop traversal
+type Consumer func(field string, val []byte) bool
+
+// ForEach visits all fields, the consumer must not modify the hash.
+// val may share memory with the hash, copy it if it will be retained
+func (h *Hash) ForEach(consumer Consumer) {
+	if len(h.expires) == 0 {
+		h.rawForEach(consumer)
+		return
+	}
+	now := time.Now().UnixMilli()
+	h.rawForEach(func(field string, val []byte) bool {
+		if h.isExpired(field, now) {
+			return true
+		}
+		return consumer(field, val)
+	})
+}
+
+// rawForEach visits all fields including expired ones
+func (h *Hash) rawForEach(consumer Consumer) {
+	if h.lp == nil {
+		h.dict.ForEach(func(key string, val interface{}) bool {
+			return consumer(key, val.([]byte))
+		})
+		return
+	}
+	for offset := h.lp.First(); offset < h.lp.End(); {
+		var field, val []byte
+		field, offset = h.lp.Next(offset)
+		val, offset = h.lp.Next(offset)
+		if !consumer(string(field), val) {
+			return
+		}
+	}
+}
+
+// Scan visits about count fields from cursor and returns the cursor to continue with,
+// see dict.Dict.Scan for the guarantee of scanning.
+// A listpack is small, so all its fields are visited at once and 0 is returned, the same as redis
+func (h *Hash) Scan(cursor uint64, count int, consumer func(field string, val []byte)) uint64 {
+	if h.lp != nil {
+		h.ForEach(func(field string, val []byte) bool {
+			consumer(field, val)
+			return true
+		})
+		return 0
+	}
+	now := time.Now().UnixMilli()
+	return h.dict.Scan(cursor, count, func(key string, val interface{}) bool {
+		if !h.isExpired(key, now) {
+			consumer(key, val.([]byte))
+		}
+		return true
+	})
+}
+
+// Fields returns all fields
+func (h *Hash) Fields() []string {
+	fields := make([]string, 0, h.Len())
+	h.ForEach(func(field string, val []byte) bool {
+		fields = append(fields, field)
+		return true
+	})
+	return fields
+}
+
+// RandomFields returns count fields randomly, the result may contain duplicated fields
+func (h *Hash) RandomFields(count int) []string {
+	if h.lp == nil && len(h.expires) == 0 {
+		return h.dict.RandomKeys(count)
+	}
+	fields := h.Fields()
+	if len(fields) == 0 {
+		return nil
+	}
+	result := make([]string, count)
+	for i := range result {
+		result[i] = fields[rand.Intn(len(fields))]
+	}
+	return result
+}
+
+// RandomDistinctFields returns at most count distinct fields randomly
+func (h *Hash) RandomDistinctFields(count int) []string {
+	if h.lp == nil && len(h.expires) == 0 {
+		return h.dict.RandomDistinctKeys(count)
+	}
+	fields := h.Fields()
+	rand.Shuffle(len(fields), func(i, j int) {
+		fields[i], fields[j] = fields[j], fields[i]
+	})
+	if count < len(fields) {
+		fields = fields[:count]
+	}
+	return fields
+}
+
+/* ---- Field TTL ---- */
+
+func (h *Hash) isExpired(field string, now int64) bool {
+	when, ok := h.expires[field]
+	return ok && when <= now
+}
+
+// SetExpire sets the expire time (unix milliseconds) of an existing field
+func (h *Hash) SetExpire(field string, when int64) {
+	if h.expires == nil {
+		h.expires = make(map[string]int64)
+	}
+	h.expires[field] = when
+}
+
+// GetExpire returns the expire time (unix milliseconds) of field, false if the field has no ttl
+func (h *Hash) GetExpire(field string) (int64, bool) {
+	when, ok := h.expires[field]
+	return when, ok
+}
+
+// Persist removes the ttl of field, returns false if the field has no ttl
+func (h *Hash) Persist(field string) bool {
+	if _, ok := h.expires[field]; !ok {
+		return false
+	}
+	delete(h.expires, field)
+	return true
+}
+
+// HasExpires returns whether any field has ttl
+func (h *Hash) HasExpires() bool {
+	return len(h.expires) > 0
+}
+
+// ForEachExpire visits the expire time (unix milliseconds) of fields with ttl, including expired ones
+func (h *Hash) ForEachExpire(consumer func(field string, when int64) bool) {
+	for field, when := range h.expires {
+		if !consumer(field, when) {
+			return
+		}
+	}
+}
+
+// RemoveExpired deletes fields expired before now (unix milliseconds) and returns them
+func (h *Hash) RemoveExpired(now int64) []string {
+	var removed []string
+	for field, when := range h.expires {
+		if when <= now {
+			removed = append(removed, field)
+		}
+	}
+	for _, field := range removed {
+		delete(h.expires, field)
+		h.rawRemove(field)
+	}
+	return removed
+}
+
+// Clone returns a copy of h with the same encoding and field ttls
+func (h *Hash) Clone() *Hash {
+	clone := &Hash{
+		expires:    maps.Clone(h.expires),
+		maxEntries: h.maxEntries,
+		maxValue:   h.maxValue,
+	}
+	if h.lp != nil {
+		clone.lp = h.lp.Clone()
+	} else {
+		clone.dict = h.dict.Clone()
+	}
+	return clone
+}
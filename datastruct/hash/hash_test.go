@@ -0,0 +1,166 @@
+package hash
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashEncodingConversion(t *testing.T) {
+	h := Make(4, 8)
+	for i := 0; i < 4; i++ {
+		h.Set("f"+strconv.Itoa(i), []byte("v"))
+	}
+	if h.Encoding() != EncodingListpack {
+		t.Fatalf("a small hash should be a listpack, got %s", h.Encoding())
+	}
+	if h.Set("f2", []byte("v2")) != 0 || h.Len() != 4 {
+		t.Fatal("setting an existing field should update it")
+	}
+	// exceeding maxEntries converts it
+	h.Set("f4", []byte("v"))
+	if h.Encoding() != EncodingHashtable {
+		t.Fatalf("a hash exceeding max listpack entries should be a hashtable, got %s", h.Encoding())
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := h.Get("f" + strconv.Itoa(i)); !ok {
+			t.Fatalf("f%d is lost by the conversion", i)
+		}
+	}
+	if val, _ := h.Get("f2"); string(val) != "v2" {
+		t.Fatalf("f2 is %q after the conversion", val)
+	}
+	// it never converts back
+	for i := 0; i < 5; i++ {
+		h.Remove("f" + strconv.Itoa(i))
+	}
+	if h.Len() != 0 || h.Encoding() != EncodingHashtable {
+		t.Fatal("an emptied hash should keep its encoding")
+	}
+
+	// a long field or value converts it
+	for _, fv := range [][2]string{{"f", strings.Repeat("v", 9)}, {strings.Repeat("f", 9), "v"}} {
+		h = Make(4, 8)
+		h.Set("a", []byte("1"))
+		h.Set(fv[0], []byte(fv[1]))
+		if h.Encoding() != EncodingHashtable {
+			t.Fatalf("a field or value longer than max listpack value should convert the hash, %q", fv)
+		}
+		if val, ok := h.Get(fv[0]); !ok || string(val) != fv[1] {
+			t.Fatalf("%q is lost by the conversion", fv[0])
+		}
+	}
+}
+
+func TestHashFields(t *testing.T) {
+	for _, maxEntries := range []int{0, 512} {
+		h := Make(maxEntries, 64)
+		for i := 0; i < 100; i++ {
+			if h.Set(strconv.Itoa(i), []byte("v"+strconv.Itoa(i))) != 1 {
+				t.Fatal("Set should return 1 for a new field")
+			}
+		}
+		if h.Remove("x") != 0 || h.Remove("50") != 1 || h.Remove("50") != 0 {
+			t.Fatal("Remove should return 1 only for an existing field")
+		}
+		if h.Len() != 99 {
+			t.Fatalf("length is %d, expected 99", h.Len())
+		}
+
+		fields := h.Fields()
+		sort.Strings(fields)
+		if len(fields) != 99 || fields[0] != "0" || fields[len(fields)-1] != "99" {
+			t.Fatalf("fields are %v", fields)
+		}
+		scanned := make(map[string]string)
+		cursor := uint64(0)
+		for {
+			cursor = h.Scan(cursor, 10, func(field string, val []byte) {
+				scanned[field] = string(val)
+			})
+			if cursor == 0 {
+				break
+			}
+		}
+		if len(scanned) != 99 || scanned["7"] != "v7" {
+			t.Fatalf("scanned %d fields, 7 is %q", len(scanned), scanned["7"])
+		}
+
+		if distinct := h.RandomDistinctFields(200); len(distinct) != 99 {
+			t.Fatalf("got %d distinct fields, expected all 99", len(distinct))
+		}
+		for _, field := range h.RandomFields(200) {
+			if _, ok := h.Get(field); !ok {
+				t.Fatalf("random field %s doesn't exist", field)
+			}
+		}
+	}
+}
+
+func TestHashClone(t *testing.T) {
+	for _, maxEntries := range []int{0, 512} {
+		h := Make(maxEntries, 64)
+		h.Set("a", []byte("1"))
+		clone := h.Clone()
+		h.Set("a", []byte("2"))
+		h.Set("b", []byte("1"))
+		if val, _ := clone.Get("a"); string(val) != "1" || clone.Len() != 1 {
+			t.Fatal("the clone should not see changes of the original")
+		}
+		if clone.Encoding() != h.Encoding() {
+			t.Fatal("the clone should keep the encoding")
+		}
+	}
+}
+
+func TestHashFieldExpire(t *testing.T) {
+	for _, maxEntries := range []int{0, 512} {
+		h := Make(maxEntries, 64)
+		h.Set("a", []byte("1"))
+		h.Set("b", []byte("2"))
+		h.Set("c", []byte("3"))
+		now := time.Now().UnixMilli()
+		h.SetExpire("a", now-1)
+		h.SetExpire("b", now+time.Hour.Milliseconds())
+
+		// an expired field is invisible before it's removed
+		if _, ok := h.Get("a"); ok {
+			t.Fatal("an expired field should be invisible")
+		}
+		if h.Len() != 2 || len(h.Fields()) != 2 {
+			t.Fatalf("length is %d, expected 2", h.Len())
+		}
+		if h.Set("a", []byte("x")) != 1 {
+			t.Fatal("setting an expired field should return 1 like a new field")
+		}
+		if _, ok := h.GetExpire("a"); ok {
+			t.Fatal("Set should remove the ttl")
+		}
+		h.Set("d", []byte("4"))
+		h.SetExpire("d", now-1)
+		if h.Remove("d") != 0 || h.Len() != 3 {
+			t.Fatal("removing an expired field should return 0")
+		}
+
+		// SetKeepTTL keeps the ttl, Persist removes it
+		h.SetKeepTTL("b", []byte("20"))
+		if when, ok := h.GetExpire("b"); !ok || when != now+time.Hour.Milliseconds() {
+			t.Fatal("SetKeepTTL should keep the ttl")
+		}
+		clone := h.Clone()
+		if !h.Persist("b") || h.Persist("b") || h.HasExpires() {
+			t.Fatal("Persist should remove the ttl once")
+		}
+		if _, ok := clone.GetExpire("b"); !ok {
+			t.Fatal("the clone should keep its own ttls")
+		}
+
+		h.SetExpire("c", now-1)
+		removed := h.RemoveExpired(now)
+		if len(removed) != 1 || removed[0] != "c" || h.Len() != 2 || h.HasExpires() {
+			t.Fatalf("removed %v, expected only c", removed)
+		}
+	}
+}
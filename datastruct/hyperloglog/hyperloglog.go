@@ -0,0 +1,527 @@
+// Package hyperloglog implements the HyperLogLog of redis on the raw bytes of a string value.
+//
+// The layout is byte-compatible with redis, so a value can be moved between redis and this server:
+//
+//	+------+---+-----+----------+
+//	| HYLL | E | N/U | Cardin.  |
+//	+------+---+-----+----------+
+//
+// 4 bytes magic "HYLL", 1 byte encoding (0 dense, 1 sparse), 3 bytes unused
+// and 8 bytes little endian cached cardinality, the highest bit of the last byte set means the cache is invalid.
+// The header is followed by 16384 registers of 6 bits.
+//
+// Dense encoding packs registers in 12288 bytes, from the least significant bit of each byte.
+//
+// Sparse encoding is a sequence of run length opcodes:
+//
+//	ZERO  00xxxxxx          xxxxxx+1 (1-64) registers set to 0
+//	XZERO 01xxxxxx yyyyyyyy xxxxxxyyyyyyyy+1 (1-16384) registers set to 0
+//	VAL   1vvvvvxx          xx+1 (1-4) registers set to vvvvv+1 (1-32)
+//
+// A sparse HLL is converted to dense once a register exceeds 32, or it grows longer than sparseMaxBytes.
+package hyperloglog
+
+import (
+	"bytes"
+	"errors"
+	"math"
+)
+
+const (
+	precision    = 14
+	Registers    = 1 << precision // number of registers
+	registerBits = 6
+	registerMax  = 1<<registerBits - 1
+	hashBits     = 64 - precision // the number of bits used to count leading zeros, Q of the paper
+
+	headerSize = 16
+	denseSize  = headerSize + (Registers*registerBits+7)/8
+
+	encodingDense  = 0
+	encodingSparse = 1
+
+	sparseXZeroBit    = 0x40
+	sparseValBit      = 0x80
+	sparseValMaxValue = 32
+	sparseValMaxLen   = 4
+	sparseZeroMaxLen  = 64
+	sparseXZeroMaxLen = 16384
+
+	hashSeed = 0xadc83b19
+	alphaInf = 0.721347520444481703680 // 0.5/ln(2)
+)
+
+var magic = []byte("HYLL")
+
+// ErrCorrupted is returned if a HLL value can't be decoded
+var ErrCorrupted = errors.New("INVALIDOBJ Corrupted HLL object detected")
+
+// HLL is the raw bytes of a HyperLogLog value
+type HLL []byte
+
+// New returns an empty HLL in sparse encoding: a single XZERO opcode covering all registers
+func New() HLL {
+	h := make(HLL, headerSize+2)
+	copy(h, magic)
+	h[4] = encodingSparse
+	sparseSetXZero(h[headerSize:], Registers)
+	return h
+}
+
+// IsHLL checks the header of a string value, it doesn't check the sparse opcodes
+func IsHLL(b []byte) bool {
+	if len(b) < headerSize || !bytes.Equal(b[:4], magic) {
+		return false
+	}
+	switch b[4] {
+	case encodingDense:
+		return len(b) == denseSize
+	case encodingSparse:
+		return true
+	}
+	return false
+}
+
+func (h HLL) isSparse() bool {
+	return h[4] == encodingSparse
+}
+
+func (h HLL) invalidateCache() {
+	h[15] |= 1 << 7
+}
+
+func (h HLL) validCache() bool {
+	return h[15]&(1<<7) == 0
+}
+
+/* ---- Dense ---- */
+
+func denseGet(registers []byte, index int) uint8 {
+	byteIndex := index * registerBits / 8
+	fb := uint(index * registerBits & 7)
+	b0 := uint(registers[byteIndex])
+	var b1 uint
+	if byteIndex+1 < len(registers) {
+		b1 = uint(registers[byteIndex+1])
+	}
+	return uint8((b0>>fb | b1<<(8-fb)) & registerMax)
+}
+
+func denseSet(registers []byte, index int, val uint8) {
+	byteIndex := index * registerBits / 8
+	fb := uint(index * registerBits & 7)
+	fb8 := 8 - fb
+	v := uint(val)
+	registers[byteIndex] &^= byte(registerMax << fb)
+	registers[byteIndex] |= byte(v << fb)
+	if byteIndex+1 < len(registers) {
+		registers[byteIndex+1] &^= byte(registerMax >> fb8)
+		registers[byteIndex+1] |= byte(v >> fb8)
+	}
+}
+
+// denseUpdate sets the register to count if count is greater, returns 1 if updated
+func denseUpdate(registers []byte, index int, count uint8) int {
+	if count > denseGet(registers, index) {
+		denseSet(registers, index, count)
+		return 1
+	}
+	return 0
+}
+
+/* ---- Sparse ---- */
+
+func sparseIsZero(op byte) bool  { return op&0xc0 == 0 }
+func sparseIsXZero(op byte) bool { return op&0xc0 == sparseXZeroBit }
+func sparseIsVal(op byte) bool   { return op&sparseValBit != 0 }
+
+func sparseZeroLen(op byte) int        { return int(op&0x3f) + 1 }
+func sparseXZeroLen(op, next byte) int { return (int(op&0x3f)<<8 | int(next)) + 1 }
+func sparseValValue(op byte) uint8     { return (op>>2)&0x1f + 1 }
+func sparseValLen(op byte) int         { return int(op&0x3) + 1 }
+
+func sparseSetVal(p []byte, val uint8, length int) {
+	p[0] = (val-1)<<2 | byte(length-1) | sparseValBit
+}
+
+func sparseSetZero(p []byte, length int) {
+	p[0] = byte(length - 1)
+}
+
+func sparseSetXZero(p []byte, length int) {
+	l := length - 1
+	p[0] = byte(l>>8) | sparseXZeroBit
+	p[1] = byte(l & 0xff)
+}
+
+// forEachRun visits the runs of sparse opcodes, returns ErrCorrupted if they don't cover exactly all registers
+func forEachRun(sparse []byte, consumer func(first int, length int, val uint8)) error {
+	index := 0
+	for p := 0; p < len(sparse); {
+		op := sparse[p]
+		var length int
+		var val uint8
+		switch {
+		case sparseIsZero(op):
+			length = sparseZeroLen(op)
+			p++
+		case sparseIsXZero(op):
+			if p+1 >= len(sparse) {
+				return ErrCorrupted
+			}
+			length = sparseXZeroLen(op, sparse[p+1])
+			p += 2
+		default:
+			length = sparseValLen(op)
+			val = sparseValValue(op)
+			p++
+		}
+		if index+length > Registers {
+			return ErrCorrupted
+		}
+		consumer(index, length, val)
+		index += length
+	}
+	if index != Registers {
+		return ErrCorrupted
+	}
+	return nil
+}
+
+// toDense converts a sparse HLL into dense encoding, the cached cardinality is kept
+func (h HLL) toDense() (HLL, error) {
+	if !h.isSparse() {
+		return h, nil
+	}
+	dense := make(HLL, denseSize)
+	copy(dense, h[:headerSize])
+	dense[4] = encodingDense
+	registers := dense[headerSize:]
+	err := forEachRun(h[headerSize:], func(first int, length int, val uint8) {
+		if val == 0 {
+			return
+		}
+		for i := first; i < first+length; i++ {
+			denseSet(registers, i, val)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dense, nil
+}
+
+// sparseUpdate sets the register to count if count is greater, it is a port of hllSparseSet.
+// The opcode covering the register is split into at most 5 bytes, then adjacent VAL opcodes are merged,
+// so the result is exactly the same bytes as redis would produce
+func (h HLL) sparseUpdate(index int, count uint8, sparseMaxBytes int) (HLL, int, error) {
+	if count > sparseValMaxValue {
+		return h.promote(index, count)
+	}
+
+	// step 1: locate the opcode covering the register
+	sparse := h[headerSize:]
+	p, prev := 0, -1
+	first, span := 0, 0
+	for p < len(sparse) {
+		oplen := 1
+		op := sparse[p]
+		switch {
+		case sparseIsZero(op):
+			span = sparseZeroLen(op)
+		case sparseIsVal(op):
+			span = sparseValLen(op)
+		default:
+			if p+1 >= len(sparse) {
+				return h, -1, ErrCorrupted
+			}
+			span = sparseXZeroLen(op, sparse[p+1])
+			oplen = 2
+		}
+		if index <= first+span-1 {
+			break
+		}
+		prev = p
+		p += oplen
+		first += span
+	}
+	if span == 0 || p >= len(sparse) {
+		return h, -1, ErrCorrupted
+	}
+
+	op := sparse[p]
+	isZero, isXZero, isVal := sparseIsZero(op), sparseIsXZero(op), sparseIsVal(op)
+	oldLen := 1
+	if isXZero {
+		oldLen = 2
+	}
+	runLen := span
+
+	// step 2: update the register in place if trivial, otherwise build the sequence replacing the opcode
+	updated := false
+	if isVal {
+		if sparseValValue(op) >= count {
+			return h, 0, nil
+		}
+		if runLen == 1 {
+			sparseSetVal(sparse[p:], count, 1)
+			updated = true
+		}
+	}
+	if isZero && runLen == 1 {
+		sparseSetVal(sparse[p:], count, 1)
+		updated = true
+	}
+
+	if !updated {
+		seq := make([]byte, 0, 5)
+		last := first + span - 1
+		appendZeros := func(length int) {
+			if length > sparseZeroMaxLen {
+				seq = append(seq, 0, 0)
+				sparseSetXZero(seq[len(seq)-2:], length)
+			} else {
+				seq = append(seq, 0)
+				sparseSetZero(seq[len(seq)-1:], length)
+			}
+		}
+		appendVal := func(val uint8, length int) {
+			seq = append(seq, 0)
+			sparseSetVal(seq[len(seq)-1:], val, length)
+		}
+		if isZero || isXZero {
+			if index != first {
+				appendZeros(index - first)
+			}
+			appendVal(count, 1)
+			if index != last {
+				appendZeros(last - index)
+			}
+		} else {
+			curVal := sparseValValue(op)
+			if index != first {
+				appendVal(curVal, index-first)
+			}
+			appendVal(count, 1)
+			if index != last {
+				appendVal(curVal, last-index)
+			}
+		}
+
+		// step 3: substitute the opcode with the new sequence
+		delta := len(seq) - oldLen
+		if delta > 0 && len(h)+delta > sparseMaxBytes {
+			return h.promote(index, count)
+		}
+		rest := append([]byte(nil), sparse[p+oldLen:]...)
+		h = append(h[:headerSize+p], seq...)
+		h = append(h, rest...)
+		sparse = h[headerSize:]
+	}
+
+	// step 4: merge adjacent VAL opcodes with the same value, scanning up to 5 opcodes from prev
+	q := 0
+	if prev >= 0 {
+		q = prev
+	}
+	for scan := 5; q < len(sparse) && scan > 0; scan-- {
+		if sparseIsXZero(sparse[q]) {
+			q += 2
+			continue
+		} else if sparseIsZero(sparse[q]) {
+			q++
+			continue
+		}
+		if q+1 < len(sparse) && sparseIsVal(sparse[q+1]) {
+			v1, v2 := sparseValValue(sparse[q]), sparseValValue(sparse[q+1])
+			if v1 == v2 {
+				length := sparseValLen(sparse[q]) + sparseValLen(sparse[q+1])
+				if length <= sparseValMaxLen {
+					sparseSetVal(sparse[q+1:], v1, length)
+					copy(sparse[q:], sparse[q+1:])
+					h = h[:len(h)-1]
+					sparse = h[headerSize:]
+					// try to merge the merged opcode with the one on its right
+					continue
+				}
+			}
+		}
+		q++
+	}
+	h.invalidateCache()
+	return h, 1, nil
+}
+
+// promote converts h into dense encoding and sets the register
+func (h HLL) promote(index int, count uint8) (HLL, int, error) {
+	dense, err := h.toDense()
+	if err != nil {
+		return h, -1, err
+	}
+	denseUpdate(dense[headerSize:], index, count)
+	dense.invalidateCache()
+	return dense, 1, nil
+}
+
+// patLen returns the register index of element and the length of the 000..1 pattern of the rest bits of its hash
+func patLen(element []byte) (int, uint8) {
+	hash := murmurHash64A(element, hashSeed)
+	index := int(hash & (Registers - 1))
+	hash >>= precision
+	hash |= 1 << hashBits // make sure the loop terminates
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+// set sets the register to count if count is greater, returns 1 if updated
+func (h HLL) set(index int, count uint8, sparseMaxBytes int) (HLL, int, error) {
+	if h.isSparse() {
+		return h.sparseUpdate(index, count, sparseMaxBytes)
+	}
+	updated := denseUpdate(h[headerSize:], index, count)
+	if updated == 1 {
+		h.invalidateCache()
+	}
+	return h, updated, nil
+}
+
+// Add adds element into h, returns the updated HLL (it may be reallocated)
+// and 1 if any register is changed, which means the approximated cardinality may be changed
+func (h HLL) Add(element []byte, sparseMaxBytes int) (HLL, int, error) {
+	index, count := patLen(element)
+	return h.set(index, count, sparseMaxBytes)
+}
+
+// Merge puts the max of registers of h and maxRegisters into maxRegisters
+func (h HLL) Merge(maxRegisters *[Registers]uint8) error {
+	if !h.isSparse() {
+		registers := h[headerSize:]
+		for i := range maxRegisters {
+			if val := denseGet(registers, i); val > maxRegisters[i] {
+				maxRegisters[i] = val
+			}
+		}
+		return nil
+	}
+	return forEachRun(h[headerSize:], func(first int, length int, val uint8) {
+		for i := first; i < first+length; i++ {
+			if val > maxRegisters[i] {
+				maxRegisters[i] = val
+			}
+		}
+	})
+}
+
+// SetRegisters updates h with the max of its registers and the given registers, like PFMERGE
+func (h HLL) SetRegisters(registers *[Registers]uint8, dense bool, sparseMaxBytes int) (HLL, error) {
+	var err error
+	if dense {
+		if h, err = h.toDense(); err != nil {
+			return h, err
+		}
+	}
+	for i, val := range registers {
+		if val == 0 {
+			continue
+		}
+		if h, _, err = h.set(i, val, sparseMaxBytes); err != nil {
+			return h, err
+		}
+	}
+	h.invalidateCache()
+	return h, nil
+}
+
+// IsDense returns whether h is in dense encoding
+func (h HLL) IsDense() bool {
+	return !h.isSparse()
+}
+
+// Count returns the approximated cardinality, the cached value is used if valid,
+// otherwise the result is computed and cached in the header. updated means the cache is written
+func (h HLL) Count() (card uint64, updated bool, err error) {
+	if h.validCache() {
+		for i := 7; i >= 0; i-- {
+			card = card<<8 | uint64(h[8+i])
+		}
+		return card, false, nil
+	}
+	var histogram [registerMax + 1]int
+	if h.isSparse() {
+		err = forEachRun(h[headerSize:], func(first int, length int, val uint8) {
+			histogram[val] += length
+		})
+		if err != nil {
+			return 0, false, err
+		}
+	} else {
+		registers := h[headerSize:]
+		for i := 0; i < Registers; i++ {
+			histogram[denseGet(registers, i)]++
+		}
+	}
+	card = estimate(&histogram)
+	for i := 0; i < 8; i++ {
+		h[8+i] = byte(card >> (8 * i))
+	}
+	return card, true, nil
+}
+
+// CountRegisters returns the approximated cardinality of raw registers, it is used by PFCOUNT with multiple keys
+func CountRegisters(registers *[Registers]uint8) uint64 {
+	var histogram [registerMax + 1]int
+	for _, val := range registers {
+		histogram[val]++
+	}
+	return estimate(&histogram)
+}
+
+// estimate computes the cardinality from the register histogram, see
+// "New cardinality estimation algorithms for HyperLogLog sketches", Otmar Ertl, arXiv:1702.01284
+func estimate(histogram *[registerMax + 1]int) uint64 {
+	m := float64(Registers)
+	z := m * tau((m-float64(histogram[hashBits+1]))/m)
+	for j := hashBits; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * sigma(float64(histogram[0])/m)
+	return uint64(math.Round(alphaInf * m * m / z))
+}
+
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
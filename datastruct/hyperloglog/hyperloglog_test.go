@@ -0,0 +1,135 @@
+package hyperloglog
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// registersOf decodes all registers of h
+func registersOf(t *testing.T, h HLL) *[Registers]uint8 {
+	t.Helper()
+	var registers [Registers]uint8
+	if err := h.Merge(&registers); err != nil {
+		t.Fatal(err)
+	}
+	return &registers
+}
+
+func TestSparseAndDense(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sparse := New()
+	dense, err := New().toDense()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected [Registers]uint8
+	for i := 0; i < 3000; i++ {
+		// few distinct indexes and values, so that runs are split and merged
+		index := r.Intn(64)
+		if r.Intn(2) == 0 {
+			index = r.Intn(Registers)
+		}
+		count := uint8(1 + r.Intn(sparseValMaxValue))
+		var updated, denseUpdated int
+		sparse, updated, err = sparse.set(index, count, math.MaxInt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dense, denseUpdated, _ = dense.set(index, count, math.MaxInt)
+		if (updated == 1) != (count > expected[index]) || updated != denseUpdated {
+			t.Fatalf("set(%d, %d) returns %d and %d", index, count, updated, denseUpdated)
+		}
+		expected[index] = max(expected[index], count)
+	}
+	if !IsHLL(sparse) || sparse.IsDense() || !IsHLL(dense) || !dense.IsDense() {
+		t.Fatal("encodings are wrong")
+	}
+	if *registersOf(t, sparse) != expected || *registersOf(t, dense) != expected {
+		t.Fatal("registers don't match")
+	}
+	converted, err := sparse.toDense()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(converted[headerSize:]) != string(dense[headerSize:]) {
+		t.Fatal("toDense doesn't match the dense HLL")
+	}
+	sparseCard, _, _ := sparse.Count()
+	denseCard, _, _ := dense.Count()
+	if sparseCard != denseCard || sparseCard != CountRegisters(&expected) {
+		t.Fatalf("cardinalities are %d and %d", sparseCard, denseCard)
+	}
+}
+
+func TestPromote(t *testing.T) {
+	// a register greater than 32 can't be represented by VAL
+	h, updated, err := New().set(100, sparseValMaxValue+1, math.MaxInt)
+	if err != nil || updated != 1 || !h.IsDense() {
+		t.Fatal("a register greater than 32 should promote the HLL")
+	}
+	if registersOf(t, h)[100] != sparseValMaxValue+1 {
+		t.Fatal("the register is lost by the promotion")
+	}
+
+	h = New()
+	for i := 0; !h.IsDense(); i++ {
+		if i > 100 {
+			t.Fatal("the HLL should be promoted once it exceeds sparseMaxBytes")
+		}
+		h, _, _ = h.set(i*100, 1, 40)
+		if !h.IsDense() && len(h) > 40 {
+			t.Fatalf("a sparse HLL of %d bytes exceeds sparseMaxBytes", len(h))
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	h := New()
+	if card, _, _ := h.Count(); card != 0 {
+		t.Fatalf("the cardinality of an empty HLL is %d", card)
+	}
+	for _, n := range []int{100, 1000, 10000, 100000} {
+		h = New()
+		for i := 0; i < n; i++ {
+			h, _, _ = h.Add([]byte(strconv.Itoa(i)), 3000)
+		}
+		card, updated, err := h.Count()
+		if err != nil || !updated {
+			t.Fatal("Count should compute and cache the cardinality")
+		}
+		// the standard error is 0.81%
+		if e := math.Abs(float64(card)-float64(n)) / float64(n); e > 0.03 {
+			t.Errorf("the cardinality of %d elements is %d", n, card)
+		}
+		if cached, updated, _ := h.Count(); cached != card || updated {
+			t.Fatal("Count should use the cached cardinality")
+		}
+		if h, changed, _ := h.Add([]byte("0"), 3000); changed != 0 || !h.validCache() {
+			t.Fatal("adding an existing element should keep the cache")
+		}
+	}
+}
+
+func TestCorrupted(t *testing.T) {
+	if IsHLL([]byte("HYLL")) || IsHLL(make([]byte, denseSize)) {
+		t.Fatal("IsHLL should check the header")
+	}
+	h := New()
+	// XZERO covering one register less than all
+	sparseSetXZero(h[headerSize:], Registers-1)
+	h.invalidateCache()
+	if _, _, err := h.Count(); err != ErrCorrupted {
+		t.Fatal("sparse opcodes not covering all registers should be corrupted")
+	}
+	h = append(h, 0) // plus a ZERO of 1
+	if _, _, err := h.Count(); err != nil {
+		t.Fatal(err)
+	}
+	h = append(h, 0)
+	h.invalidateCache()
+	if _, _, err := h.Count(); err != ErrCorrupted {
+		t.Fatal("sparse opcodes covering more than all registers should be corrupted")
+	}
+}
@@ -0,0 +1,502 @@
+// Package list implements the quicklist which backs the redis list type
+package list
+
+import (
+	"bytes"
+
+	"github.com/tonge3199/redis_go/datastruct/listpack"
+)
+
+// QuickList is a linked list of pages, every page(node) is a listpack holding a few elements.
+//
+// Compared to a plain doubly linked list, a quicklist only pays the pointer overhead once per page
+// instead of once per element, and the elements of a page share one allocation,
+// which matters a lot when a list holds millions of short strings.
+//
+//	head                                              tail
+//	[e0 e1 e2 e3] <-> [e4 e5 e6 e7] <-> ... <-> [en-2 en-1]
+//
+// The capacity of a page is controlled by fill, which has the same meaning as list-max-listpack-size of redis:
+// a positive fill is the max element count of a page,
+// fill in -1..-5 limits the encoded bytes of a page to 4kb, 8kb, 16kb, 32kb or 64kb.
+//
+// A page is split in halves when an element is inserted into the middle of a full page,
+// and adjacent pages are merged once elements removed from the middle leave room for both in one page, like redis.
+// Pops don't merge pages, otherwise popping at the boundary of two pages would merge them back and forth.
+//
+// Elements returned by Get, Range, Remove and pops are copies,
+// elements passed to callbacks share memory with the page and must not be retained.
+type QuickList struct {
+	head *node
+	tail *node
+	size int
+	fill int
+}
+
+type node struct {
+	lp   *listpack.ListPack
+	prev *node
+	next *node
+}
+
+func newNode() *node {
+	return &node{lp: listpack.New()}
+}
+
+// offsetOf returns the offset of the i-th element of n within its listpack
+func (n *node) offsetOf(i int) int {
+	offset := n.lp.First()
+	for ; i > 0; i-- {
+		_, offset = n.lp.Next(offset)
+	}
+	return offset
+}
+
+// sizeLimits maps negative fill values to the max byte size of a page
+var sizeLimits = [...]int{4096, 8192, 16384, 32768, 65536}
+
+// sizeSafetyLimit bounds the byte size of a page when a positive fill is used,
+// so that a few huge elements don't produce a huge page
+const sizeSafetyLimit = 8192
+
+// NewQuickList creates a QuickList, see QuickList for the meaning of fill
+func NewQuickList(fill int) *QuickList {
+	if fill == 0 {
+		fill = 1
+	}
+	if fill < -len(sizeLimits) {
+		fill = -len(sizeLimits)
+	}
+	return &QuickList{fill: fill}
+}
+
+// fits returns whether a page of count elements and size encoded bytes is within page limits
+func (ql *QuickList) fits(count int, size int) bool {
+	if ql.fill > 0 {
+		return count <= ql.fill && size <= sizeSafetyLimit
+	}
+	return size <= sizeLimits[-ql.fill-1]
+}
+
+// allowInsert returns whether val can be inserted into n without exceeding page limits
+func (ql *QuickList) allowInsert(n *node, val []byte) bool {
+	if n == nil {
+		return false
+	}
+	if n.lp.Len() == 0 {
+		// a page always accepts its first element
+		return true
+	}
+	return ql.fits(n.lp.Len()+1, n.lp.Bytes()+listpack.EntrySize(val))
+}
+
+// allowMerge returns whether pages a and b fit in one page
+func (ql *QuickList) allowMerge(a *node, b *node) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	return ql.fits(a.lp.Len()+b.lp.Len(), a.lp.Bytes()+b.lp.Bytes())
+}
+
+// merge moves elements of b to the tail of a if they fit in one page, b must be the next page of a
+func (ql *QuickList) merge(a *node, b *node) {
+	if !ql.allowMerge(a, b) {
+		return
+	}
+	b.lp.ForEach(func(i int, val []byte) bool {
+		a.lp.Append(val)
+		return true
+	})
+	ql.unlink(b)
+}
+
+// Len returns the number of elements
+func (ql *QuickList) Len() int {
+	return ql.size
+}
+
+// PushFront inserts val at the head of list
+func (ql *QuickList) PushFront(val []byte) {
+	if !ql.allowInsert(ql.head, val) {
+		n := newNode()
+		n.next = ql.head
+		if ql.head != nil {
+			ql.head.prev = n
+		} else {
+			ql.tail = n
+		}
+		ql.head = n
+	}
+	ql.head.lp.Insert(ql.head.lp.First(), val)
+	ql.size++
+}
+
+// PushBack appends val to the tail of list
+func (ql *QuickList) PushBack(val []byte) {
+	if !ql.allowInsert(ql.tail, val) {
+		n := newNode()
+		n.prev = ql.tail
+		if ql.tail != nil {
+			ql.tail.next = n
+		} else {
+			ql.head = n
+		}
+		ql.tail = n
+	}
+	ql.tail.lp.Append(val)
+	ql.size++
+}
+
+// PopFront removes and returns the head element, returns nil if list is empty
+func (ql *QuickList) PopFront() []byte {
+	if ql.size == 0 {
+		return nil
+	}
+	return ql.removeAt(ql.head, ql.head.lp.First())
+}
+
+// PopBack removes and returns the tail element, returns nil if list is empty
+func (ql *QuickList) PopBack() []byte {
+	if ql.size == 0 {
+		return nil
+	}
+	return ql.removeAt(ql.tail, ql.tail.offsetOf(ql.tail.lp.Len()-1))
+}
+
+// find returns the page containing the element at index and the index within the page.
+// It walks from the closer end of the list. index must be in [0, size)
+func (ql *QuickList) find(index int) (*node, int) {
+	if index < ql.size/2 {
+		n := ql.head
+		for index >= n.lp.Len() {
+			index -= n.lp.Len()
+			n = n.next
+		}
+		return n, index
+	}
+	n := ql.tail
+	back := ql.size - 1 - index // distance from tail
+	for back >= n.lp.Len() {
+		back -= n.lp.Len()
+		n = n.prev
+	}
+	return n, n.lp.Len() - 1 - back
+}
+
+// Get returns the element at index, index must be in [0, Len())
+func (ql *QuickList) Get(index int) []byte {
+	if index < 0 || index >= ql.size {
+		panic("index out of bound")
+	}
+	n, i := ql.find(index)
+	val, _ := n.lp.Next(n.offsetOf(i))
+	return bytes.Clone(val)
+}
+
+// Set replaces the element at index, index must be in [0, Len())
+func (ql *QuickList) Set(index int, val []byte) {
+	if index < 0 || index >= ql.size {
+		panic("index out of bound")
+	}
+	n, i := ql.find(index)
+	n.lp.Replace(n.offsetOf(i), val)
+}
+
+// Insert inserts val before the element at index, so that val will be at index after insertion.
+// index must be in [0, Len()], Insert(Len(), val) equals PushBack(val)
+func (ql *QuickList) Insert(index int, val []byte) {
+	if index < 0 || index > ql.size {
+		panic("index out of bound")
+	}
+	if index == ql.size {
+		ql.PushBack(val)
+		return
+	}
+	if index == 0 {
+		ql.PushFront(val)
+		return
+	}
+	n, i := ql.find(index)
+	if !ql.allowInsert(n, val) && n.lp.Len() == 1 {
+		// a page of one element can't be split, val gets a new page before it
+		newNode := newNode()
+		newNode.lp.Append(val)
+		newNode.prev = n.prev
+		newNode.next = n
+		n.prev.next = newNode // n isn't the head, since index > 0
+		n.prev = newNode
+		ql.size++
+		return
+	}
+	if !ql.allowInsert(n, val) {
+		left, right := n, ql.split(n)
+		if mid := left.lp.Len(); i >= mid {
+			n = right
+			i -= mid
+		}
+		n.lp.Insert(n.offsetOf(i), val)
+		ql.size++
+		// the halves may fit in their neighbors
+		ql.merge(right, right.next)
+		ql.merge(left.prev, left)
+		return
+	}
+	n.lp.Insert(n.offsetOf(i), val)
+	ql.size++
+}
+
+// split moves the second half of n into a new page after it, and returns the new page
+func (ql *QuickList) split(n *node) *node {
+	mid := n.lp.Len() / 2
+	right := newNode()
+	midOffset := n.offsetOf(mid)
+	for offset := midOffset; offset < n.lp.End(); {
+		var val []byte
+		val, offset = n.lp.Next(offset)
+		right.lp.Append(val)
+	}
+	n.lp.Delete(midOffset, n.lp.Len()-mid)
+	right.prev = n
+	right.next = n.next
+	if n.next != nil {
+		n.next.prev = right
+	} else {
+		ql.tail = right
+	}
+	n.next = right
+	return right
+}
+
+// Remove removes and returns the element at index, index must be in [0, Len())
+func (ql *QuickList) Remove(index int) []byte {
+	if index < 0 || index >= ql.size {
+		panic("index out of bound")
+	}
+	n, i := ql.find(index)
+	prev, next := n.prev, n.next
+	val := ql.removeAt(n, n.offsetOf(i))
+	if n.lp.Len() > 0 {
+		ql.merge(prev, n)
+	} else {
+		ql.merge(prev, next)
+	}
+	return val
+}
+
+// removeAt removes and returns the element at offset of the page, empty page will be unlinked
+func (ql *QuickList) removeAt(n *node, offset int) []byte {
+	val, _ := n.lp.Next(offset)
+	val = bytes.Clone(val)
+	n.lp.Delete(offset, 1)
+	ql.size--
+	if n.lp.Len() == 0 {
+		ql.unlink(n)
+	}
+	return val
+}
+
+func (ql *QuickList) unlink(n *node) {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		ql.head = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		ql.tail = n.prev
+	}
+	n.prev = nil
+	n.next = nil
+}
+
+// Range returns elements whose index within [start, stop), both must be in [0, Len()]
+func (ql *QuickList) Range(start int, stop int) [][]byte {
+	if start < 0 || start > ql.size || stop < start || stop > ql.size {
+		panic("index out of bound")
+	}
+	result := make([][]byte, 0, stop-start)
+	if start == stop {
+		return result
+	}
+	n, i := ql.find(start)
+	offset := n.offsetOf(i)
+	for len(result) < stop-start {
+		if offset == n.lp.End() {
+			n = n.next
+			offset = n.lp.First()
+		}
+		var val []byte
+		val, offset = n.lp.Next(offset)
+		result = append(result, bytes.Clone(val))
+	}
+	return result
+}
+
+// Trim keeps elements whose index within [start, stop) and removes the others
+func (ql *QuickList) Trim(start int, stop int) {
+	if start < 0 || stop > ql.size || start >= stop {
+		ql.head = nil
+		ql.tail = nil
+		ql.size = 0
+		return
+	}
+	// drop from the tail
+	for removed := ql.size - stop; removed > 0; {
+		n := ql.tail
+		count := min(removed, n.lp.Len())
+		removed -= count
+		ql.size -= count
+		if count == n.lp.Len() {
+			ql.unlink(n)
+			continue
+		}
+		n.lp.Delete(n.offsetOf(n.lp.Len()-count), count)
+	}
+	// drop from the head
+	for removed := start; removed > 0; {
+		n := ql.head
+		count := min(removed, n.lp.Len())
+		removed -= count
+		ql.size -= count
+		if count == n.lp.Len() {
+			ql.unlink(n)
+			continue
+		}
+		n.lp.Delete(n.lp.First(), count)
+	}
+}
+
+// Expected checks whether the given element is the wanted one
+type Expected func(a []byte) bool
+
+// RemoveByVal removes at most count elements matching expected from head to tail,
+// count <= 0 means remove all matched elements. Returns the number of removed elements
+func (ql *QuickList) RemoveByVal(expected Expected, count int) int {
+	removed := 0
+	for n := ql.head; n != nil; {
+		next := n.next
+		touched := false
+		for offset := n.lp.First(); offset < n.lp.End(); {
+			val, nextOffset := n.lp.Next(offset)
+			if !expected(val) {
+				offset = nextOffset
+				continue
+			}
+			n.lp.Delete(offset, 1)
+			ql.size--
+			removed++
+			touched = true
+			if count > 0 && removed == count {
+				break
+			}
+		}
+		if touched {
+			// pages before n are scanned already, so n is merged into its previous page
+			if n.lp.Len() == 0 {
+				ql.unlink(n)
+			} else {
+				ql.merge(n.prev, n)
+			}
+		}
+		if count > 0 && removed == count {
+			return removed
+		}
+		n = next
+	}
+	return removed
+}
+
+// ReverseRemoveByVal removes at most count elements matching expected from tail to head,
+// count <= 0 means remove all matched elements. Returns the number of removed elements
+func (ql *QuickList) ReverseRemoveByVal(expected Expected, count int) int {
+	removed := 0
+	for n := ql.tail; n != nil; {
+		prev := n.prev
+		touched := false
+		offsets := n.offsets()
+		for i := len(offsets) - 1; i >= 0; i-- {
+			if val, _ := n.lp.Next(offsets[i]); !expected(val) {
+				continue
+			}
+			// entries after offsets[i] move, but entries before it don't
+			n.lp.Delete(offsets[i], 1)
+			ql.size--
+			removed++
+			touched = true
+			if count > 0 && removed == count {
+				break
+			}
+		}
+		if touched {
+			// pages after n are scanned already, so the next page is merged into n
+			if n.lp.Len() == 0 {
+				ql.unlink(n)
+			} else {
+				ql.merge(n, n.next)
+			}
+		}
+		if count > 0 && removed == count {
+			return removed
+		}
+		n = prev
+	}
+	return removed
+}
+
+// offsets returns offsets of all elements of n, so that they can be visited from tail to head
+func (n *node) offsets() []int {
+	offsets := make([]int, 0, n.lp.Len())
+	for offset := n.lp.First(); offset < n.lp.End(); _, offset = n.lp.Next(offset) {
+		offsets = append(offsets, offset)
+	}
+	return offsets
+}
+
+// Consumer traverses list, it returns false to break traversal.
+// v shares memory with the list, it must not be modified or retained
+type Consumer func(i int, v []byte) bool
+
+// ForEach visits every element from head to tail
+func (ql *QuickList) ForEach(consumer Consumer) {
+	i := 0
+	for n := ql.head; n != nil; n = n.next {
+		for offset := n.lp.First(); offset < n.lp.End(); i++ {
+			var v []byte
+			v, offset = n.lp.Next(offset)
+			if !consumer(i, v) {
+				return
+			}
+		}
+	}
+}
+
+// ReverseForEach visits every element from tail to head, i is still the index counted from head
+func (ql *QuickList) ReverseForEach(consumer Consumer) {
+	i := ql.size - 1
+	for n := ql.tail; n != nil; n = n.prev {
+		offsets := n.offsets()
+		for j := len(offsets) - 1; j >= 0; j-- {
+			v, _ := n.lp.Next(offsets[j])
+			if !consumer(i, v) {
+				return
+			}
+			i--
+		}
+	}
+}
+
+// Clone returns a deep copy of ql with the same page layout
+func (ql *QuickList) Clone() *QuickList {
+	clone := &QuickList{size: ql.size, fill: ql.fill}
+	for n := ql.head; n != nil; n = n.next {
+		copied := &node{lp: n.lp.Clone(), prev: clone.tail}
+		if clone.tail != nil {
+			clone.tail.next = copied
+		} else {
+			clone.head = copied
+		}
+		clone.tail = copied
+	}
+	return clone
+}
@@ -0,0 +1,287 @@
+package list
+
+import (
+	"bytes"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// checkList checks the links and counters of pages, and that ql holds exactly expected
+func checkList(t *testing.T, ql *QuickList, expected [][]byte) {
+	t.Helper()
+	size := 0
+	var prev *node
+	for n := ql.head; n != nil; n = n.next {
+		if n.prev != prev {
+			t.Fatal("broken prev link")
+		}
+		if n.lp.Len() == 0 {
+			t.Fatal("empty page is not unlinked")
+		}
+		if ql.fill > 0 && n.lp.Len() > ql.fill {
+			t.Fatalf("page has %d entries, fill is %d", n.lp.Len(), ql.fill)
+		}
+		size += n.lp.Len()
+		prev = n
+	}
+	if ql.tail != prev {
+		t.Fatal("tail is not the last page")
+	}
+	if size != ql.size || ql.Len() != len(expected) {
+		t.Fatalf("size is %d, pages hold %d, expected %d", ql.size, size, len(expected))
+	}
+	ql.ForEach(func(i int, v []byte) bool {
+		if !bytes.Equal(v, expected[i]) {
+			t.Fatalf("element %d is %q, expected %q", i, v, expected[i])
+		}
+		return true
+	})
+}
+
+func TestQuickListPushPop(t *testing.T) {
+	ql := NewQuickList(4)
+	var expected [][]byte
+	for i := 0; i < 20; i++ {
+		val := []byte(strconv.Itoa(i))
+		if i%2 == 0 {
+			ql.PushBack(val)
+			expected = append(expected, val)
+		} else {
+			ql.PushFront(val)
+			expected = append([][]byte{val}, expected...)
+		}
+		checkList(t, ql, expected)
+	}
+	for len(expected) > 0 {
+		if v := ql.PopFront(); !bytes.Equal(v, expected[0]) {
+			t.Fatalf("PopFront returns %q, expected %q", v, expected[0])
+		}
+		expected = expected[1:]
+		if len(expected) == 0 {
+			break
+		}
+		if v := ql.PopBack(); !bytes.Equal(v, expected[len(expected)-1]) {
+			t.Fatalf("PopBack returns %q, expected %q", v, expected[len(expected)-1])
+		}
+		expected = expected[:len(expected)-1]
+		checkList(t, ql, expected)
+	}
+	if ql.PopFront() != nil || ql.PopBack() != nil {
+		t.Fatal("pop of an empty list should return nil")
+	}
+	checkList(t, ql, nil)
+}
+
+func TestQuickListByteLimit(t *testing.T) {
+	ql := NewQuickList(-1) // 4kb pages
+	big := bytes.Repeat([]byte("x"), 3000)
+	ql.PushBack(big)
+	ql.PushBack(big)
+	if ql.head == ql.tail {
+		t.Fatal("a page should not exceed 4kb")
+	}
+	huge := bytes.Repeat([]byte("y"), 10000)
+	ql.PushBack(huge)
+	if ql.tail.lp.Len() != 1 {
+		t.Fatal("an element larger than a page should get its own page")
+	}
+	checkList(t, ql, [][]byte{big, big, huge})
+}
+
+// TestQuickListRandom applies random operations to a quicklist and a slice, they must stay the same
+func TestQuickListRandom(t *testing.T) {
+	for _, fill := range []int{1, 3, 8, -1} {
+		ql := NewQuickList(fill)
+		var expected [][]byte
+		r := rand.New(rand.NewSource(int64(fill)))
+		for i := 0; i < 3000; i++ {
+			val := []byte(strconv.Itoa(r.Intn(50)))
+			switch op := r.Intn(9); {
+			case op == 0:
+				ql.PushFront(val)
+				expected = append([][]byte{val}, expected...)
+			case op == 1:
+				ql.PushBack(val)
+				expected = append(expected, val)
+			case op == 2:
+				index := r.Intn(len(expected) + 1)
+				ql.Insert(index, val)
+				expected = append(expected[:index], append([][]byte{val}, expected[index:]...)...)
+			case op == 3 && len(expected) > 0:
+				index := r.Intn(len(expected))
+				if removed := ql.Remove(index); !bytes.Equal(removed, expected[index]) {
+					t.Fatalf("Remove(%d) returns %q, expected %q", index, removed, expected[index])
+				}
+				expected = append(expected[:index], expected[index+1:]...)
+			case op == 4 && len(expected) > 0:
+				index := r.Intn(len(expected))
+				ql.Set(index, val)
+				expected[index] = val
+			case op == 5 && len(expected) > 0:
+				start := r.Intn(len(expected))
+				stop := start + r.Intn(len(expected)-start+1)
+				got := ql.Range(start, stop)
+				if len(got) != stop-start {
+					t.Fatalf("Range(%d, %d) returns %d elements", start, stop, len(got))
+				}
+				for j := range got {
+					if !bytes.Equal(got[j], expected[start+j]) {
+						t.Fatalf("Range(%d, %d)[%d] is %q, expected %q", start, stop, j, got[j], expected[start+j])
+					}
+				}
+			case op == 6 && len(expected) > 0:
+				target := []byte(strconv.Itoa(r.Intn(50)))
+				count := r.Intn(3)
+				removed := ql.RemoveByVal(func(a []byte) bool { return bytes.Equal(a, target) }, count)
+				kept := expected[:0:0]
+				n := 0
+				for _, e := range expected {
+					if bytes.Equal(e, target) && (count == 0 || n < count) {
+						n++
+						continue
+					}
+					kept = append(kept, e)
+				}
+				if removed != n {
+					t.Fatalf("RemoveByVal removes %d, expected %d", removed, n)
+				}
+				expected = kept
+			case op == 7 && len(expected) > 0:
+				target := []byte(strconv.Itoa(r.Intn(50)))
+				count := r.Intn(3)
+				removed := ql.ReverseRemoveByVal(func(a []byte) bool { return bytes.Equal(a, target) }, count)
+				var kept [][]byte
+				n := 0
+				for j := len(expected) - 1; j >= 0; j-- {
+					if bytes.Equal(expected[j], target) && (count == 0 || n < count) {
+						n++
+						continue
+					}
+					kept = append([][]byte{expected[j]}, kept...)
+				}
+				if removed != n {
+					t.Fatalf("ReverseRemoveByVal removes %d, expected %d", removed, n)
+				}
+				expected = kept
+			case op == 8 && r.Intn(10) == 0:
+				start := r.Intn(len(expected) + 1)
+				stop := start + r.Intn(len(expected)-start+1)
+				ql.Trim(start, stop)
+				if start >= stop {
+					expected = nil
+				} else {
+					expected = append([][]byte(nil), expected[start:stop]...)
+				}
+			}
+			checkList(t, ql, expected)
+		}
+	}
+}
+
+func TestQuickListReverseForEach(t *testing.T) {
+	ql := NewQuickList(2)
+	for i := 0; i < 7; i++ {
+		ql.PushBack([]byte(strconv.Itoa(i)))
+	}
+	next := 6
+	ql.ReverseForEach(func(i int, v []byte) bool {
+		if i != next || string(v) != strconv.Itoa(i) {
+			t.Fatalf("visits %d:%q, expected index %d", i, v, next)
+		}
+		next--
+		return i > 3
+	})
+	if next != 2 {
+		t.Fatalf("traversal should stop at index 3, stopped before %d", next+1)
+	}
+}
+
+func TestQuickListClone(t *testing.T) {
+	ql := NewQuickList(3)
+	var expected [][]byte
+	for i := 0; i < 10; i++ {
+		val := []byte(strconv.Itoa(i))
+		ql.PushBack(val)
+		expected = append(expected, []byte(strconv.Itoa(i)))
+	}
+	clone := ql.Clone()
+	checkList(t, clone, expected)
+	clone.Set(0, []byte("x"))
+	clone.PushBack([]byte("new"))
+	clone.Remove(5)
+	checkList(t, ql, expected)
+}
+
+// pageLens returns the numbers of elements of pages from head to tail
+func pageLens(ql *QuickList) []int {
+	var lens []int
+	for n := ql.head; n != nil; n = n.next {
+		lens = append(lens, n.lp.Len())
+	}
+	return lens
+}
+
+func TestQuickListSplitAndMerge(t *testing.T) {
+	ql := NewQuickList(4)
+	var expected [][]byte
+	for i := 0; i < 8; i++ {
+		ql.PushBack([]byte(strconv.Itoa(i)))
+		expected = append(expected, []byte(strconv.Itoa(i)))
+	}
+	if lens := pageLens(ql); !slices.Equal(lens, []int{4, 4}) {
+		t.Fatalf("pages are %v, expected [4 4]", lens)
+	}
+
+	// inserting into a full page splits it in halves
+	ql.Insert(2, []byte("x"))
+	expected = slices.Insert(expected, 2, []byte("x"))
+	checkList(t, ql, expected)
+	if lens := pageLens(ql); !slices.Equal(lens, []int{2, 3, 4}) {
+		t.Fatalf("pages are %v, expected [2 3 4]", lens)
+	}
+
+	// the page is merged into its previous page once both fit in one page
+	ql.Remove(2)
+	expected = slices.Delete(expected, 2, 3)
+	checkList(t, ql, expected)
+	if lens := pageLens(ql); !slices.Equal(lens, []int{4, 4}) {
+		t.Fatalf("pages are %v, expected [4 4]", lens)
+	}
+	for _, reverse := range []bool{false, true} {
+		ql := ql.Clone()
+		even := func(a []byte) bool { return (a[0]-'0')%2 == 0 }
+		if reverse {
+			ql.ReverseRemoveByVal(even, 0)
+		} else {
+			ql.RemoveByVal(even, 0)
+		}
+		checkList(t, ql, [][]byte{[]byte("1"), []byte("3"), []byte("5"), []byte("7")})
+		if lens := pageLens(ql); !slices.Equal(lens, []int{4}) {
+			t.Fatalf("pages are %v, expected [4]", lens)
+		}
+	}
+
+	// popping doesn't merge pages
+	ql.PopFront()
+	ql.PopBack()
+	if lens := pageLens(ql); !slices.Equal(lens, []int{3, 3}) {
+		t.Fatalf("pages are %v, expected [3 3]", lens)
+	}
+}
+
+func TestQuickListPageBytes(t *testing.T) {
+	// a page is one listpack, so its size includes the length headers of elements
+	ql := NewQuickList(-1)
+	val := bytes.Repeat([]byte("x"), 1022) // 2 bytes of header, 1024 bytes per element
+	for i := 0; i < 5; i++ {
+		ql.PushBack(val)
+	}
+	if lens := pageLens(ql); !slices.Equal(lens, []int{4, 1}) {
+		t.Fatalf("pages are %v, expected [4 1]", lens)
+	}
+	if ql.head.lp.Bytes() != 4096 {
+		t.Fatalf("the first page has %d bytes, expected 4096", ql.head.lp.Bytes())
+	}
+}
@@ -0,0 +1,135 @@
+// Package listpack implements a compact sequence of strings stored in one contiguous byte slice
+package listpack
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// ListPack stores all entries in one []byte, every entry is encoded as
+//
+//	<uvarint length><data>
+//
+// For example ["f1", "hello"] is stored as "\x02f1\x05hello".
+//
+// Small collections (e.g. a hash with a dozen fields) cost only one allocation instead of a map with
+// dozens of buckets, at the price of O(n) lookup, that is why the users convert a ListPack
+// into a real hash table once it grows past a threshold.
+//
+// Entries are located by offset: First returns the offset of the first entry,
+// Next decodes the entry at an offset and returns the offset of the entry after it
+type ListPack struct {
+	buf  []byte
+	size int // number of entries
+}
+
+// New creates an empty ListPack
+func New() *ListPack {
+	return &ListPack{}
+}
+
+// Len returns the number of entries
+func (lp *ListPack) Len() int {
+	return lp.size
+}
+
+// Bytes returns the memory size of encoded entries
+func (lp *ListPack) Bytes() int {
+	return len(lp.buf)
+}
+
+// EntrySize returns the bytes val takes once it's encoded as an entry
+func EntrySize(val []byte) int {
+	var header [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(header[:], uint64(len(val))) + len(val)
+}
+
+// First returns the offset of the first entry, it equals End() if the ListPack is empty
+func (lp *ListPack) First() int {
+	return 0
+}
+
+// End returns the offset after the last entry
+func (lp *ListPack) End() int {
+	return len(lp.buf)
+}
+
+// Next decodes the entry at offset, returns the entry and the offset of next entry.
+// The returned slice shares memory with ListPack, it must not be modified or retained after the ListPack changes
+func (lp *ListPack) Next(offset int) (val []byte, next int) {
+	length, n := binary.Uvarint(lp.buf[offset:])
+	start := offset + n
+	end := start + int(length)
+	return lp.buf[start:end], end
+}
+
+// Append appends entries to the tail
+func (lp *ListPack) Append(vals ...[]byte) {
+	for _, val := range vals {
+		lp.buf = binary.AppendUvarint(lp.buf, uint64(len(val)))
+		lp.buf = append(lp.buf, val...)
+		lp.size++
+	}
+}
+
+// Insert inserts entries before the entry at offset, offset End() appends them
+func (lp *ListPack) Insert(offset int, vals ...[]byte) {
+	var encoded []byte
+	for _, val := range vals {
+		encoded = binary.AppendUvarint(encoded, uint64(len(val)))
+		encoded = append(encoded, val...)
+	}
+	lp.splice(offset, offset, encoded)
+	lp.size += len(vals)
+}
+
+// Replace replaces the entry at offset with val
+func (lp *ListPack) Replace(offset int, val []byte) {
+	_, next := lp.Next(offset)
+	encoded := binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(val)), uint64(len(val)))
+	encoded = append(encoded, val...)
+	lp.splice(offset, next, encoded)
+}
+
+// Delete removes count entries starting from offset
+func (lp *ListPack) Delete(offset int, count int) {
+	end := offset
+	for i := 0; i < count && end < len(lp.buf); i++ {
+		_, end = lp.Next(end)
+		lp.size--
+	}
+	lp.splice(offset, end, nil)
+}
+
+// splice replaces lp.buf[start:end] with data
+func (lp *ListPack) splice(start int, end int, data []byte) {
+	delta := len(data) - (end - start)
+	if delta > 0 {
+		lp.buf = append(lp.buf, make([]byte, delta)...)
+	}
+	copy(lp.buf[start+len(data):], lp.buf[end:len(lp.buf)-max(delta, 0)])
+	copy(lp.buf[start:], data)
+	if delta < 0 {
+		lp.buf = lp.buf[:len(lp.buf)+delta]
+	}
+}
+
+// ForEach visits entries from head to tail, it stops if consumer returns false
+func (lp *ListPack) ForEach(consumer func(i int, val []byte) bool) {
+	i := 0
+	for offset := 0; offset < len(lp.buf); i++ {
+		var val []byte
+		val, offset = lp.Next(offset)
+		if !consumer(i, val) {
+			return
+		}
+	}
+}
+
+// Clone returns a deep copy of lp
+func (lp *ListPack) Clone() *ListPack {
+	return &ListPack{
+		buf:  bytes.Clone(lp.buf),
+		size: lp.size,
+	}
+}
@@ -0,0 +1,133 @@
+package listpack
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// entries decodes all entries by First and Next
+func entries(lp *ListPack) [][]byte {
+	var result [][]byte
+	for offset := lp.First(); offset < lp.End(); {
+		var val []byte
+		val, offset = lp.Next(offset)
+		result = append(result, bytes.Clone(val))
+	}
+	return result
+}
+
+// offsetOf returns the offset of the i-th entry
+func offsetOf(lp *ListPack, i int) int {
+	offset := lp.First()
+	for ; i > 0; i-- {
+		_, offset = lp.Next(offset)
+	}
+	return offset
+}
+
+func checkEntries(t *testing.T, lp *ListPack, expected [][]byte) {
+	t.Helper()
+	got := entries(lp)
+	if lp.Len() != len(expected) || len(got) != len(expected) {
+		t.Fatalf("Len() is %d with %d entries, expected %d", lp.Len(), len(got), len(expected))
+	}
+	for i := range expected {
+		if !bytes.Equal(got[i], expected[i]) {
+			t.Fatalf("entry %d is %q, expected %q", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestListPackEncoding(t *testing.T) {
+	lp := New()
+	lp.Append([]byte("f1"), []byte("hello"))
+	if lp.Bytes() != 9 {
+		t.Fatalf("Bytes() is %d, expected 9", lp.Bytes())
+	}
+	checkEntries(t, lp, [][]byte{[]byte("f1"), []byte("hello")})
+	// a length of 200 takes 2 bytes of uvarint
+	long := []byte(strings.Repeat("x", 200))
+	lp.Append(long, nil)
+	if lp.Bytes() != 9+202+1 {
+		t.Fatalf("Bytes() is %d, expected %d", lp.Bytes(), 9+202+1)
+	}
+	checkEntries(t, lp, [][]byte{[]byte("f1"), []byte("hello"), long, {}})
+}
+
+func TestListPackReplaceAndDelete(t *testing.T) {
+	lp := New()
+	expected := [][]byte{[]byte("a"), []byte("bb"), []byte("ccc"), []byte("dddd")}
+	lp.Append(expected...)
+
+	// longer, shorter and a length needing more bytes
+	lp.Replace(offsetOf(lp, 1), []byte("BBBBBB"))
+	expected[1] = []byte("BBBBBB")
+	checkEntries(t, lp, expected)
+	lp.Replace(offsetOf(lp, 2), []byte("C"))
+	expected[2] = []byte("C")
+	checkEntries(t, lp, expected)
+	long := []byte(strings.Repeat("z", 300))
+	lp.Replace(offsetOf(lp, 0), long)
+	expected[0] = long
+	checkEntries(t, lp, expected)
+
+	lp.Delete(offsetOf(lp, 1), 2)
+	expected = [][]byte{long, []byte("dddd")}
+	checkEntries(t, lp, expected)
+	// count beyond the end deletes until the end
+	lp.Delete(offsetOf(lp, 1), 5)
+	checkEntries(t, lp, [][]byte{long})
+	lp.Delete(lp.First(), 1)
+	checkEntries(t, lp, nil)
+	if lp.Bytes() != 0 || lp.First() != lp.End() {
+		t.Fatal("an empty listpack should have no bytes")
+	}
+}
+
+func TestListPackRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	lp := New()
+	var expected [][]byte
+	for i := 0; i < 2000; i++ {
+		val := []byte(strings.Repeat("v", r.Intn(260)))
+		switch op := r.Intn(4); {
+		case op == 0 || len(expected) == 0:
+			lp.Append(val)
+			expected = append(expected, val)
+		case op == 1:
+			index := r.Intn(len(expected) + 1)
+			lp.Insert(offsetOf(lp, index), val, []byte("x"))
+			expected = append(expected[:index], append([][]byte{val, []byte("x")}, expected[index:]...)...)
+		case op == 2:
+			index := r.Intn(len(expected))
+			lp.Replace(offsetOf(lp, index), val)
+			expected[index] = val
+		default:
+			index := r.Intn(len(expected))
+			count := 1 + r.Intn(3)
+			lp.Delete(offsetOf(lp, index), count)
+			expected = append(expected[:index], expected[min(index+count, len(expected)):]...)
+		}
+		checkEntries(t, lp, expected)
+	}
+}
+
+func TestListPackForEachAndClone(t *testing.T) {
+	lp := New()
+	lp.Append([]byte("a"), []byte("b"), []byte("c"))
+	var visited []string
+	lp.ForEach(func(i int, val []byte) bool {
+		visited = append(visited, string(val))
+		return i < 1
+	})
+	if strings.Join(visited, ",") != "a,b" {
+		t.Fatalf("ForEach visits %v, expected it to stop after b", visited)
+	}
+
+	clone := lp.Clone()
+	clone.Replace(clone.First(), []byte("changed"))
+	checkEntries(t, lp, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	checkEntries(t, clone, [][]byte{[]byte("changed"), []byte("b"), []byte("c")})
+}
@@ -0,0 +1,118 @@
+// Package lock provides read write locks of keys for commands touching several keys
+package lock
+
+import (
+	"sort"
+	"sync"
+)
+
+// Locks is a fixed size table of read write locks, a key is guarded by the lock at hash(key) % size.
+//
+// A command locks all keys it reads or writes before execution, so it is atomic against other commands
+// sharing any key with it, while commands on disjoint keys run in parallel.
+// Keys are locked in ascending order of their lock indices and unlocked in descending order,
+// so two commands locking the same keys in different argument order never dead lock.
+//
+// 不同的 key 可能映射到同一个锁，这只会降低并行度，不影响正确性
+type Locks struct {
+	table []*sync.RWMutex
+}
+
+// Make creates a lock table with tableSize locks, tableSize is rounded up to a power of 2
+func Make(tableSize int) *Locks {
+	size := 1
+	for size < tableSize {
+		size <<= 1
+	}
+	table := make([]*sync.RWMutex, size)
+	for i := range table {
+		table[i] = &sync.RWMutex{}
+	}
+	return &Locks{
+		table: table,
+	}
+}
+
+const prime32 = uint32(16777619)
+
+// fnv32 is the 32-bit FNV-1 hash
+func fnv32(key string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash *= prime32
+		hash ^= uint32(key[i])
+	}
+	return hash
+}
+
+func (locks *Locks) spread(hashCode uint32) uint32 {
+	return uint32(len(locks.table)-1) & hashCode
+}
+
+// Lock acquires the write lock of key
+func (locks *Locks) Lock(key string) {
+	locks.table[locks.spread(fnv32(key))].Lock()
+}
+
+// Unlock releases the write lock of key
+func (locks *Locks) Unlock(key string) {
+	locks.table[locks.spread(fnv32(key))].Unlock()
+}
+
+// RLock acquires the read lock of key
+func (locks *Locks) RLock(key string) {
+	locks.table[locks.spread(fnv32(key))].RLock()
+}
+
+// RUnlock releases the read lock of key
+func (locks *Locks) RUnlock(key string) {
+	locks.table[locks.spread(fnv32(key))].RUnlock()
+}
+
+// toLockIndices returns sorted distinct lock indices of keys, and whether each index should be write locked.
+// An index shared by a write key and a read key is write locked
+func (locks *Locks) toLockIndices(writeKeys []string, readKeys []string) ([]uint32, map[uint32]bool) {
+	writeMap := make(map[uint32]bool, len(writeKeys)+len(readKeys))
+	for _, key := range writeKeys {
+		writeMap[locks.spread(fnv32(key))] = true
+	}
+	for _, key := range readKeys {
+		index := locks.spread(fnv32(key))
+		if _, ok := writeMap[index]; !ok {
+			writeMap[index] = false
+		}
+	}
+	indices := make([]uint32, 0, len(writeMap))
+	for index := range writeMap {
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return indices[i] < indices[j]
+	})
+	return indices, writeMap
+}
+
+// RWLocks write locks writeKeys and read locks readKeys, a key may appear in both of them
+func (locks *Locks) RWLocks(writeKeys []string, readKeys []string) {
+	indices, writeMap := locks.toLockIndices(writeKeys, readKeys)
+	for _, index := range indices {
+		if writeMap[index] {
+			locks.table[index].Lock()
+		} else {
+			locks.table[index].RLock()
+		}
+	}
+}
+
+// RWUnLocks releases locks acquired by RWLocks with the same keys
+func (locks *Locks) RWUnLocks(writeKeys []string, readKeys []string) {
+	indices, writeMap := locks.toLockIndices(writeKeys, readKeys)
+	for i := len(indices) - 1; i >= 0; i-- {
+		index := indices[i]
+		if writeMap[index] {
+			locks.table[index].Unlock()
+		} else {
+			locks.table[index].RUnlock()
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package lock
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestToLockIndices(t *testing.T) {
+	locks := Make(1000)
+	if len(locks.table) != 1024 {
+		t.Fatalf("table size is %d, expected 1024", len(locks.table))
+	}
+	indices, writeMap := locks.toLockIndices([]string{"a", "b", "a"}, []string{"b", "c", "c"})
+	if !sort.SliceIsSorted(indices, func(i, j int) bool { return indices[i] < indices[j] }) {
+		t.Fatal("indices should be sorted")
+	}
+	a, b, c := locks.spread(fnv32("a")), locks.spread(fnv32("b")), locks.spread(fnv32("c"))
+	if len(indices) != 3 || !writeMap[a] || !writeMap[b] || writeMap[c] {
+		t.Fatalf("indices are %v, write map is %v", indices, writeMap)
+	}
+}
+
+func TestReadLocksShare(t *testing.T) {
+	locks := Make(16)
+	locks.RWLocks(nil, []string{"a"})
+	done := make(chan struct{})
+	go func() {
+		locks.RWLocks([]string{"b"}, []string{"a"})
+		locks.RWUnLocks([]string{"b"}, []string{"a"})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("read locks of the same key should not block each other")
+	}
+	locks.RWUnLocks(nil, []string{"a"})
+}
+
+func TestWriteLockExcludes(t *testing.T) {
+	locks := Make(16)
+	locks.RWLocks([]string{"a"}, nil)
+	locked := make(chan struct{})
+	go func() {
+		locks.RWLocks(nil, []string{"a"})
+		close(locked)
+		locks.RWUnLocks(nil, []string{"a"})
+	}()
+	select {
+	case <-locked:
+		t.Fatal("a write lock should block readers of the key")
+	case <-time.After(50 * time.Millisecond):
+	}
+	locks.RWUnLocks([]string{"a"}, nil)
+	<-locked
+}
+
+// TestNoDeadLock locks random overlapping keys in random order from many goroutines,
+// each key holds a counter which is only modified under its write lock
+func TestNoDeadLock(t *testing.T) {
+	locks := Make(8) // few locks, so that keys share them
+	counters := make([]int, 20)
+	const goroutines, rounds = 8, 500
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < rounds; i++ {
+				write, read := r.Intn(len(counters)), r.Intn(len(counters))
+				writeKeys := []string{strconv.Itoa(write), strconv.Itoa(r.Intn(len(counters)))}
+				readKeys := []string{strconv.Itoa(read), writeKeys[0]}
+				locks.RWLocks(writeKeys, readKeys)
+				counters[write]++
+				_ = counters[read]
+				locks.RWUnLocks(writeKeys, readKeys)
+			}
+		}(int64(g))
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("dead lock")
+	}
+	total := 0
+	for _, n := range counters {
+		total += n
+	}
+	if total != goroutines*rounds {
+		t.Fatalf("counters sum to %d, expected %d", total, goroutines*rounds)
+	}
+}
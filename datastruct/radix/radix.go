@@ -0,0 +1,281 @@
+// Package radix implements an ordered map keyed by byte strings, on a compressed prefix tree
+package radix
+
+import "bytes"
+
+// Tree is a radix tree: keys sharing a prefix share the path from the root,
+// and a chain of nodes with a single child is compressed into one edge.
+//
+// For example keys "romane", "romanus" and "rubens" are stored as
+//
+//	(root) -r-> * -oman-> * -e-> [romane]
+//	            |           \-us-> [romanus]
+//	            \-ubens-> [rubens]
+//
+// Keys are visited in lexicographical order, which makes Tree suitable for
+// range queries on big-endian encoded integers such as stream IDs
+type Tree struct {
+	root  *node
+	size  int
+	nodes int
+}
+
+type node struct {
+	prefix   []byte  // label of the edge from parent to this node
+	children []*node // sorted by the first byte of prefix
+	isKey    bool
+	value    any
+}
+
+// New creates an empty Tree
+func New() *Tree {
+	return &Tree{
+		root:  &node{},
+		nodes: 1,
+	}
+}
+
+// Len returns the number of keys
+func (tree *Tree) Len() int {
+	return tree.size
+}
+
+// NodeCount returns the number of nodes of the tree, including the root
+func (tree *Tree) NodeCount() int {
+	return tree.nodes
+}
+
+func commonPrefixLen(a []byte, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// findChild returns the index of the child whose prefix starts with c, or the index to insert it
+func (n *node) findChild(c byte) (int, bool) {
+	lo, hi := 0, len(n.children)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if n.children[mid].prefix[0] < c {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < len(n.children) && n.children[lo].prefix[0] == c
+}
+
+// Find returns the value of key
+func (tree *Tree) Find(key []byte) (any, bool) {
+	n := tree.root
+	for len(key) > 0 {
+		i, ok := n.findChild(key[0])
+		if !ok {
+			return nil, false
+		}
+		child := n.children[i]
+		if !bytes.HasPrefix(key, child.prefix) {
+			return nil, false
+		}
+		key = key[len(child.prefix):]
+		n = child
+	}
+	if !n.isKey {
+		return nil, false
+	}
+	return n.value, true
+}
+
+// Insert puts the key, returns true if key is new or false if the value of an existing key is replaced
+func (tree *Tree) Insert(key []byte, value any) bool {
+	n := tree.root
+	for len(key) > 0 {
+		i, ok := n.findChild(key[0])
+		if !ok {
+			leaf := &node{
+				prefix: bytes.Clone(key),
+				isKey:  true,
+				value:  value,
+			}
+			n.children = append(n.children, nil)
+			copy(n.children[i+1:], n.children[i:])
+			n.children[i] = leaf
+			tree.size++
+			tree.nodes++
+			return true
+		}
+		child := n.children[i]
+		common := commonPrefixLen(key, child.prefix)
+		if common < len(child.prefix) {
+			// split the edge: n -common-> middle -rest-> child
+			middle := &node{
+				prefix:   child.prefix[:common:common],
+				children: []*node{child},
+			}
+			child.prefix = child.prefix[common:]
+			n.children[i] = middle
+			tree.nodes++
+			child = middle
+		}
+		key = key[common:]
+		n = child
+	}
+	if n.isKey {
+		n.value = value
+		return false
+	}
+	n.isKey = true
+	n.value = value
+	tree.size++
+	return true
+}
+
+// Remove deletes the key, returns the removed value
+func (tree *Tree) Remove(key []byte) (any, bool) {
+	var parent *node
+	var index int
+	n := tree.root
+	for len(key) > 0 {
+		i, ok := n.findChild(key[0])
+		if !ok {
+			return nil, false
+		}
+		child := n.children[i]
+		if !bytes.HasPrefix(key, child.prefix) {
+			return nil, false
+		}
+		key = key[len(child.prefix):]
+		parent, index, n = n, i, child
+	}
+	if !n.isKey {
+		return nil, false
+	}
+	value := n.value
+	n.isKey = false
+	n.value = nil
+	tree.size--
+
+	if n == tree.root {
+		return value, true
+	}
+	switch len(n.children) {
+	case 0:
+		parent.children = append(parent.children[:index], parent.children[index+1:]...)
+		tree.nodes--
+		// parent may become a non-key node with a single child, which can be compressed
+		if parent != tree.root && !parent.isKey && len(parent.children) == 1 {
+			tree.compress(parent)
+		}
+	case 1:
+		tree.compress(n)
+	}
+	return value, true
+}
+
+// compress merges a non-key node with its only child
+func (tree *Tree) compress(n *node) {
+	child := n.children[0]
+	prefix := make([]byte, 0, len(n.prefix)+len(child.prefix))
+	prefix = append(prefix, n.prefix...)
+	prefix = append(prefix, child.prefix...)
+	*n = node{
+		prefix:   prefix,
+		children: child.children,
+		isKey:    child.isKey,
+		value:    child.value,
+	}
+	tree.nodes--
+}
+
+// Consumer is used to traverse the tree, the key must not be modified or retained. Traversal stops if it returns false
+type Consumer func(key []byte, value any) bool
+
+// Ascend visits keys greater than or equal to start in ascending order, nil start means from the first key.
+// The tree must not be modified during traversal
+func (tree *Tree) Ascend(start []byte, consumer Consumer) {
+	ascend(tree.root, nil, start, true, consumer)
+}
+
+// ascend visits the subtree of n, whose path from root is path.
+// filter is false if all keys in the subtree are known to be greater than or equal to start
+func ascend(n *node, path []byte, start []byte, filter bool, consumer Consumer) bool {
+	if filter {
+		common := min(len(path), len(start))
+		switch cmp := bytes.Compare(path[:common], start[:common]); {
+		case cmp < 0:
+			return true // all keys are less than start
+		case cmp > 0 || len(path) >= len(start):
+			filter = false
+		}
+	}
+	if n.isKey && !filter {
+		if !consumer(path, n.value) {
+			return false
+		}
+	}
+	for _, child := range n.children {
+		if !ascend(child, append(path, child.prefix...), start, filter, consumer) {
+			return false
+		}
+	}
+	return true
+}
+
+// Descend visits keys less than or equal to start in descending order, nil start means from the last key.
+// The tree must not be modified during traversal
+func (tree *Tree) Descend(start []byte, consumer Consumer) {
+	descend(tree.root, nil, start, start != nil, consumer)
+}
+
+// descend visits the subtree of n, whose path from root is path.
+// filter is false if all keys in the subtree are known to be less than or equal to start
+func descend(n *node, path []byte, start []byte, filter bool, consumer Consumer) bool {
+	if filter {
+		common := min(len(path), len(start))
+		switch cmp := bytes.Compare(path[:common], start[:common]); {
+		case cmp > 0 || (cmp == 0 && len(path) > len(start)):
+			return true // all keys are greater than start
+		case cmp < 0:
+			filter = false
+		case len(path) == len(start):
+			// path equals start, children are greater than start
+			if n.isKey {
+				return consumer(path, n.value)
+			}
+			return true
+		}
+		// otherwise path is a proper prefix of start, so it is less than start
+	}
+	for i := len(n.children) - 1; i >= 0; i-- {
+		child := n.children[i]
+		if !descend(child, append(path, child.prefix...), start, filter, consumer) {
+			return false
+		}
+	}
+	if n.isKey {
+		return consumer(path, n.value)
+	}
+	return true
+}
+
+// First returns the smallest key
+func (tree *Tree) First() (key []byte, value any, ok bool) {
+	tree.Ascend(nil, func(k []byte, v any) bool {
+		key, value, ok = bytes.Clone(k), v, true
+		return false
+	})
+	return
+}
+
+// Last returns the greatest key
+func (tree *Tree) Last() (key []byte, value any, ok bool) {
+	tree.Descend(nil, func(k []byte, v any) bool {
+		key, value, ok = bytes.Clone(k), v, true
+		return false
+	})
+	return
+}
@@ -0,0 +1,169 @@
+package radix
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// collect returns the keys visited by traverse in order
+func collect(traverse func(start []byte, consumer Consumer), start []byte) []string {
+	var keys []string
+	traverse(start, func(key []byte, value any) bool {
+		if string(key) != value.(string) {
+			panic("value doesn't match key " + string(key))
+		}
+		keys = append(keys, string(key))
+		return true
+	})
+	return keys
+}
+
+func equalKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// randomKey returns a short key over a small alphabet, so that keys share prefixes
+func randomKey(r *rand.Rand) []byte {
+	key := make([]byte, r.Intn(6))
+	for i := range key {
+		key[i] = "abc"[r.Intn(3)]
+	}
+	return key
+}
+
+func TestTreeRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tree := New()
+	expected := make(map[string]bool)
+	for i := 0; i < 5000; i++ {
+		key := randomKey(r)
+		if r.Intn(3) == 0 {
+			value, ok := tree.Remove(key)
+			if ok != expected[string(key)] || (ok && value.(string) != string(key)) {
+				t.Fatalf("Remove(%q) returns %v, %v", key, value, ok)
+			}
+			delete(expected, string(key))
+		} else {
+			if tree.Insert(key, string(key)) == expected[string(key)] {
+				t.Fatalf("Insert(%q) is wrong", key)
+			}
+			expected[string(key)] = true
+		}
+		if tree.Len() != len(expected) {
+			t.Fatalf("Len() is %d, expected %d", tree.Len(), len(expected))
+		}
+	}
+
+	sorted := make([]string, 0, len(expected))
+	for key := range expected {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		if value, ok := tree.Find([]byte(key)); !ok || value.(string) != key {
+			t.Fatalf("Find(%q) returns %v, %v", key, value, ok)
+		}
+	}
+	if _, ok := tree.Find([]byte("abcabc")); ok {
+		t.Fatal("a key longer than all inserted keys should not be found")
+	}
+
+	if got := collect(tree.Ascend, nil); !equalKeys(got, sorted) {
+		t.Fatalf("Ascend visits %v, expected %v", got, sorted)
+	}
+	for i := 0; i < 100; i++ {
+		start := randomKey(r)
+		var ascending, descending []string
+		for _, key := range sorted {
+			if key >= string(start) {
+				ascending = append(ascending, key)
+			}
+		}
+		for j := len(sorted) - 1; j >= 0; j-- {
+			if sorted[j] <= string(start) {
+				descending = append(descending, sorted[j])
+			}
+		}
+		if got := collect(tree.Ascend, start); !equalKeys(got, ascending) {
+			t.Fatalf("Ascend(%q) visits %v, expected %v", start, got, ascending)
+		}
+		if got := collect(tree.Descend, start); !equalKeys(got, descending) {
+			t.Fatalf("Descend(%q) visits %v, expected %v", start, got, descending)
+		}
+	}
+
+	first, _, ok := tree.First()
+	last, _, _ := tree.Last()
+	if !ok || string(first) != sorted[0] || string(last) != sorted[len(sorted)-1] {
+		t.Fatalf("First and Last are %q and %q", first, last)
+	}
+
+	// removing all keys compresses the tree back to the root
+	for _, key := range sorted {
+		tree.Remove([]byte(key))
+	}
+	if tree.Len() != 0 || tree.NodeCount() != 1 {
+		t.Fatalf("an empty tree has %d keys and %d nodes", tree.Len(), tree.NodeCount())
+	}
+	if _, _, ok := tree.First(); ok {
+		t.Fatal("First of an empty tree should return nothing")
+	}
+}
+
+func TestTreeCompress(t *testing.T) {
+	tree := New()
+	tree.Insert([]byte("romane"), "romane")
+	tree.Insert([]byte("romanus"), "romanus")
+	tree.Insert([]byte("rubens"), "rubens")
+	// root, r, oman, e, us, ubens
+	if tree.NodeCount() != 6 {
+		t.Fatalf("NodeCount() is %d, expected 6", tree.NodeCount())
+	}
+	tree.Remove([]byte("romanus"))
+	// oman and e are merged
+	if tree.NodeCount() != 4 {
+		t.Fatalf("NodeCount() is %d, expected 4", tree.NodeCount())
+	}
+	if _, ok := tree.Find([]byte("roman")); ok {
+		t.Fatal("a prefix of a key should not be found")
+	}
+	tree.Insert([]byte("r"), "r")
+	tree.Remove([]byte("rubens"))
+	// r is a key, so it isn't merged with romane
+	if tree.NodeCount() != 3 {
+		t.Fatalf("NodeCount() is %d, expected 3", tree.NodeCount())
+	}
+	// the empty key is stored in the root
+	tree.Insert(nil, "")
+	if got := collect(tree.Ascend, nil); !equalKeys(got, []string{"", "r", "romane"}) {
+		t.Fatalf("Ascend visits %v", got)
+	}
+	if got := collect(tree.Descend, []byte("rz")); !equalKeys(got, []string{"romane", "r", ""}) {
+		t.Fatalf("Descend visits %v", got)
+	}
+}
+
+func TestTreeStopTraversal(t *testing.T) {
+	tree := New()
+	for _, key := range []string{"a", "b", "c", "d"} {
+		tree.Insert([]byte(key), key)
+	}
+	var visited [][]byte
+	tree.Ascend([]byte("b"), func(key []byte, value any) bool {
+		visited = append(visited, bytes.Clone(key))
+		return len(visited) < 2
+	})
+	if len(visited) != 2 || string(visited[0]) != "b" || string(visited[1]) != "c" {
+		t.Fatalf("Ascend visits %q, expected it to stop after c", visited)
+	}
+}
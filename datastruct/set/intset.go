@@ -0,0 +1,90 @@
+package set
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// IntSet is a sorted array of distinct integers, it's the compact encoding of small integer sets
+type IntSet struct {
+	values []int64
+}
+
+// NewIntSet creates an empty IntSet
+func NewIntSet() *IntSet {
+	return &IntSet{}
+}
+
+// Len returns the number of integers
+func (s *IntSet) Len() int {
+	return len(s.values)
+}
+
+// search returns the position of v, or the position to insert v if not found
+func (s *IntSet) search(v int64) (int, bool) {
+	i := sort.Search(len(s.values), func(i int) bool {
+		return s.values[i] >= v
+	})
+	return i, i < len(s.values) && s.values[i] == v
+}
+
+// Add inserts v and returns 1 if v is new
+func (s *IntSet) Add(v int64) int {
+	i, found := s.search(v)
+	if found {
+		return 0
+	}
+	s.values = append(s.values, 0)
+	copy(s.values[i+1:], s.values[i:])
+	s.values[i] = v
+	return 1
+}
+
+// Remove deletes v and returns 1 if v existed
+func (s *IntSet) Remove(v int64) int {
+	i, found := s.search(v)
+	if !found {
+		return 0
+	}
+	s.values = append(s.values[:i], s.values[i+1:]...)
+	return 1
+}
+
+// Has returns whether v is in set
+func (s *IntSet) Has(v int64) bool {
+	_, found := s.search(v)
+	return found
+}
+
+// Get returns the i-th smallest integer
+func (s *IntSet) Get(i int) int64 {
+	return s.values[i]
+}
+
+// ForEach visits integers in ascending order, returns false in consumer to stop traversal
+func (s *IntSet) ForEach(consumer func(v int64) bool) {
+	for _, v := range s.values {
+		if !consumer(v) {
+			return
+		}
+	}
+}
+
+// parseInt parses member as the integer it represents, it accepts the canonical form only,
+// so "007" or "+7" are not integers and a member always round-trips unchanged
+func parseInt(member string) (int64, bool) {
+	if len(member) == 0 || len(member) > 20 {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(member, 10, 64)
+	if err != nil || strconv.FormatInt(v, 10) != member {
+		return 0, false
+	}
+	return v, true
+}
+
+// Clone returns a copy of s
+func (s *IntSet) Clone() *IntSet {
+	return &IntSet{values: slices.Clone(s.values)}
+}
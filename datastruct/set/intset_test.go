@@ -0,0 +1,86 @@
+package set
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestIntSet(t *testing.T) {
+	s := NewIntSet()
+	expected := make(map[int64]bool)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		v := r.Int63n(100) - 50
+		if r.Intn(3) == 0 {
+			result := s.Remove(v)
+			if (result == 1) != expected[v] {
+				t.Fatalf("Remove(%d) returns %d", v, result)
+			}
+			delete(expected, v)
+		} else {
+			result := s.Add(v)
+			if (result == 1) == expected[v] {
+				t.Fatalf("Add(%d) returns %d", v, result)
+			}
+			expected[v] = true
+		}
+		if s.Len() != len(expected) {
+			t.Fatalf("Len() is %d, expected %d", s.Len(), len(expected))
+		}
+	}
+	sorted := make([]int64, 0, len(expected))
+	for v := range expected {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, v := range sorted {
+		if s.Get(i) != v || !s.Has(v) {
+			t.Fatalf("the %d-th integer is %d, expected %d", i, s.Get(i), v)
+		}
+	}
+	if s.Has(1000) {
+		t.Fatal("Has(1000) should be false")
+	}
+
+	i := 0
+	s.ForEach(func(v int64) bool {
+		if v != sorted[i] {
+			t.Fatalf("ForEach visits %d at %d, expected %d", v, i, sorted[i])
+		}
+		i++
+		return true
+	})
+
+	clone := s.Clone()
+	clone.Add(1000)
+	if s.Has(1000) || !clone.Has(1000) {
+		t.Fatal("a clone should not share integers")
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		member string
+		value  int64
+		ok     bool
+	}{
+		{"0", 0, true},
+		{"-7", -7, true},
+		{"9223372036854775807", 9223372036854775807, true},
+		{"-9223372036854775808", -9223372036854775808, true},
+		{"9223372036854775808", 0, false},
+		{"007", 0, false},
+		{"+7", 0, false},
+		{"-0", 0, false},
+		{" 1", 0, false},
+		{"1.0", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		value, ok := parseInt(tt.member)
+		if ok != tt.ok || value != tt.value {
+			t.Errorf("parseInt(%q) = %d, %v, expected %d, %v", tt.member, value, ok, tt.value, tt.ok)
+		}
+	}
+}
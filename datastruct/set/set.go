@@ -0,0 +1,184 @@
+// Package set implements the redis set type with two encodings
+package set
+
+import (
+	"math/rand"
+	"strconv"
+
+	"github.com/tonge3199/redis_go/datastruct/dict"
+)
+
+// Encoding names, the same as OBJECT ENCODING of redis
+const (
+	EncodingIntset    = "intset"
+	EncodingHashtable = "hashtable"
+)
+
+// Set is a collection of distinct strings.
+//
+// A set containing only integers is stored in a sorted IntSet.
+// Once a non-integer member is added, or the number of members exceeds maxIntsetEntries,
+// it is converted into a hash table and never converted back, the same as redis.
+type Set struct {
+	intset *IntSet          // nil if converted to hash table
+	dict   *dict.SimpleDict // member -> nil, nil if using intset
+
+	maxIntsetEntries int
+}
+
+// Make creates an empty set, maxIntsetEntries is the threshold of intset encoding
+func Make(maxIntsetEntries int) *Set {
+	return &Set{
+		intset:           NewIntSet(),
+		maxIntsetEntries: maxIntsetEntries,
+	}
+}
+
+// Encoding returns the current encoding name
+func (set *Set) Encoding() string {
+	if set.intset != nil {
+		return EncodingIntset
+	}
+	return EncodingHashtable
+}
+
+// Len returns the number of members
+func (set *Set) Len() int {
+	if set.intset != nil {
+		return set.intset.Len()
+	}
+	return set.dict.Len()
+}
+
+// Add puts member into set and returns 1 if member is new
+func (set *Set) Add(member string) int {
+	if set.intset != nil {
+		if v, ok := parseInt(member); ok {
+			if set.intset.Has(v) {
+				return 0
+			}
+			if set.intset.Len()+1 <= set.maxIntsetEntries {
+				return set.intset.Add(v)
+			}
+		}
+		set.convert()
+	}
+	return set.dict.Put(member, nil)
+}
+
+// Remove deletes member and returns 1 if member existed
+func (set *Set) Remove(member string) int {
+	if set.intset != nil {
+		v, ok := parseInt(member)
+		if !ok {
+			return 0
+		}
+		return set.intset.Remove(v)
+	}
+	_, result := set.dict.Remove(member)
+	return result
+}
+
+// Has returns whether member is in set
+func (set *Set) Has(member string) bool {
+	if set.intset != nil {
+		v, ok := parseInt(member)
+		return ok && set.intset.Has(v)
+	}
+	_, exists := set.dict.Get(member)
+	return exists
+}
+
+// convert moves all members from intset into hash table
+func (set *Set) convert() {
+	d := dict.MakeSimple()
+	set.intset.ForEach(func(v int64) bool {
+		d.Put(strconv.FormatInt(v, 10), nil)
+		return true
+	})
+	set.dict = d
+	set.intset = nil
+}
+
+// ForEach visits all members, the consumer must not modify the set.
+// Members of an intset encoded set are visited in ascending order
+func (set *Set) ForEach(consumer func(member string) bool) {
+	if set.intset != nil {
+		set.intset.ForEach(func(v int64) bool {
+			return consumer(strconv.FormatInt(v, 10))
+		})
+		return
+	}
+	set.dict.ForEach(func(key string, val interface{}) bool {
+		return consumer(key)
+	})
+}
+
+// Members returns all members
+func (set *Set) Members() []string {
+	members := make([]string, 0, set.Len())
+	set.ForEach(func(member string) bool {
+		members = append(members, member)
+		return true
+	})
+	return members
+}
+
+// RandomMembers returns count members randomly, the result may contain duplicated members
+func (set *Set) RandomMembers(count int) []string {
+	if set.Len() == 0 {
+		return nil
+	}
+	if set.intset == nil {
+		return set.dict.RandomKeys(count)
+	}
+	result := make([]string, count)
+	for i := range result {
+		result[i] = strconv.FormatInt(set.intset.Get(rand.Intn(set.intset.Len())), 10)
+	}
+	return result
+}
+
+// RandomDistinctMembers returns at most count distinct members randomly
+func (set *Set) RandomDistinctMembers(count int) []string {
+	if set.intset == nil {
+		return set.dict.RandomDistinctKeys(count)
+	}
+	size := set.intset.Len()
+	if count > size {
+		count = size
+	}
+	result := make([]string, count)
+	for i, j := range rand.Perm(size)[:count] {
+		result[i] = strconv.FormatInt(set.intset.Get(j), 10)
+	}
+	return result
+}
+
+// Clone returns a deep copy of set with the same encoding
+func (set *Set) Clone() *Set {
+	clone := &Set{maxIntsetEntries: set.maxIntsetEntries}
+	if set.intset != nil {
+		clone.intset = set.intset.Clone()
+	} else {
+		clone.dict = set.dict.Clone()
+	}
+	return clone
+}
+
+// Scan visits about count members from cursor and returns the cursor to continue with,
+// see dict.Dict.Scan for the guarantee of scanning.
+// An intset is small, so all its members are visited at once and 0 is returned, the same as redis
+func (set *Set) Scan(cursor uint64, count int, consumer func(member string)) uint64 {
+	if set.intset != nil {
+		set.ForEach(func(member string) bool {
+			consumer(member)
+			return true
+		})
+		return 0
+	}
+	return set.dict.Scan(cursor, count, func(key string, val interface{}) bool {
+		consumer(key)
+		return true
+	})
+}
@@ -0,0 +1,114 @@
+package set
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestSetEncodingConversion(t *testing.T) {
+	s := Make(4)
+	for i := 0; i < 4; i++ {
+		s.Add(strconv.Itoa(i))
+	}
+	if s.Encoding() != EncodingIntset {
+		t.Fatalf("a small set of integers should be an intset, got %s", s.Encoding())
+	}
+	if s.Add("2") != 0 || s.Len() != 4 {
+		t.Fatal("adding an existing member should change nothing")
+	}
+	// exceeding maxIntsetEntries converts it
+	s.Add("4")
+	if s.Encoding() != EncodingHashtable {
+		t.Fatalf("a set exceeding max intset entries should be a hashtable, got %s", s.Encoding())
+	}
+	for i := 0; i < 5; i++ {
+		if !s.Has(strconv.Itoa(i)) {
+			t.Fatalf("%d is lost by the conversion", i)
+		}
+	}
+	// it never converts back
+	for i := 0; i < 5; i++ {
+		s.Remove(strconv.Itoa(i))
+	}
+	if s.Len() != 0 || s.Encoding() != EncodingHashtable {
+		t.Fatal("an emptied set should keep its encoding")
+	}
+
+	s = Make(512)
+	s.Add("1")
+	s.Add("a")
+	if s.Encoding() != EncodingHashtable || !s.Has("1") || !s.Has("a") {
+		t.Fatal("a non-integer member should convert the set into a hashtable")
+	}
+	// non-canonical integers are strings
+	s = Make(512)
+	s.Add("007")
+	if s.Encoding() != EncodingHashtable || !s.Has("007") || s.Has("7") {
+		t.Fatal("007 should be kept as a string")
+	}
+}
+
+func TestSetMembers(t *testing.T) {
+	for _, maxIntset := range []int{0, 512} {
+		s := Make(maxIntset)
+		for i := 0; i < 100; i++ {
+			s.Add(strconv.Itoa(i))
+		}
+		if s.Remove("x") != 0 || s.Remove("50") != 1 || s.Remove("50") != 0 {
+			t.Fatal("Remove should return 1 only for an existing member")
+		}
+		members := s.Members()
+		if len(members) != 99 {
+			t.Fatalf("Members() returns %d members, expected 99", len(members))
+		}
+		if maxIntset > 0 && !sort.SliceIsSorted(members, func(i, j int) bool {
+			a, _ := strconv.Atoi(members[i])
+			b, _ := strconv.Atoi(members[j])
+			return a < b
+		}) {
+			t.Fatal("members of an intset should be in ascending order")
+		}
+
+		distinct := s.RandomDistinctMembers(200)
+		seen := make(map[string]bool)
+		for _, m := range distinct {
+			if seen[m] || !s.Has(m) {
+				t.Fatalf("RandomDistinctMembers returns a duplicated or unknown member %s", m)
+			}
+			seen[m] = true
+		}
+		if len(distinct) != 99 {
+			t.Fatalf("RandomDistinctMembers(200) returns %d members, expected 99", len(distinct))
+		}
+		random := s.RandomMembers(200)
+		if len(random) != 200 {
+			t.Fatalf("RandomMembers(200) returns %d members", len(random))
+		}
+		for _, m := range random {
+			if !s.Has(m) {
+				t.Fatalf("RandomMembers returns an unknown member %s", m)
+			}
+		}
+
+		scanned := make(map[string]bool)
+		cursor := uint64(0)
+		for {
+			cursor = s.Scan(cursor, 10, func(member string) {
+				scanned[member] = true
+			})
+			if cursor == 0 {
+				break
+			}
+		}
+		if len(scanned) != 99 {
+			t.Fatalf("Scan visits %d members, expected 99", len(scanned))
+		}
+
+		clone := s.Clone()
+		clone.Add("new")
+		if s.Has("new") || clone.Encoding() != EncodingHashtable {
+			t.Fatal("a clone should not share members")
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package sortedset
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// Border is the min or max of a range query: a ScoreBorder for BYSCORE or a LexBorder for BYLEX
+type Border interface {
+	// minOK returns whether element is on the right side of the border when it is used as min
+	minOK(element *Element) bool
+	// maxOK returns whether element is on the left side of the border when it is used as max
+	maxOK(element *Element) bool
+	// isEmptyRange returns whether no element can be within [border, max]
+	isEmptyRange(max Border) bool
+}
+
+/* ---- Score Border ---- */
+
+// ScoreBorder is a score range item like 1.5, (1.5, -inf or +inf
+type ScoreBorder struct {
+	Value   float64
+	Exclude bool
+}
+
+func (border *ScoreBorder) minOK(element *Element) bool {
+	if border.Exclude {
+		return element.Score > border.Value
+	}
+	return element.Score >= border.Value
+}
+
+func (border *ScoreBorder) maxOK(element *Element) bool {
+	if border.Exclude {
+		return element.Score < border.Value
+	}
+	return element.Score <= border.Value
+}
+
+func (border *ScoreBorder) isEmptyRange(max Border) bool {
+	maxBorder := max.(*ScoreBorder)
+	return border.Value > maxBorder.Value ||
+		(border.Value == maxBorder.Value && (border.Exclude || maxBorder.Exclude))
+}
+
+// ErrInvalidScoreBorder is returned if a score range item is not a float
+var ErrInvalidScoreBorder = errors.New("ERR min or max is not a float")
+
+// ParseScoreBorder parses a score range item, a leading ( means exclusive
+func ParseScoreBorder(s string) (*ScoreBorder, error) {
+	border := &ScoreBorder{}
+	if len(s) > 0 && s[0] == '(' {
+		border.Exclude = true
+		s = s[1:]
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(value) {
+		return nil, ErrInvalidScoreBorder
+	}
+	border.Value = value
+	return border, nil
+}
+
+/* ---- Lex Border ---- */
+
+// LexBorder is a lex range item like [a, (a, - or +
+type LexBorder struct {
+	Value   string
+	Exclude bool
+	Inf     int // -1 for -, 1 for +, 0 for a normal item
+}
+
+func (border *LexBorder) minOK(element *Element) bool {
+	if border.Inf != 0 {
+		return border.Inf < 0
+	}
+	if border.Exclude {
+		return element.Member > border.Value
+	}
+	return element.Member >= border.Value
+}
+
+func (border *LexBorder) maxOK(element *Element) bool {
+	if border.Inf != 0 {
+		return border.Inf > 0
+	}
+	if border.Exclude {
+		return element.Member < border.Value
+	}
+	return element.Member <= border.Value
+}
+
+func (border *LexBorder) isEmptyRange(max Border) bool {
+	maxBorder := max.(*LexBorder)
+	if border.Inf > 0 || maxBorder.Inf < 0 {
+		return true
+	}
+	if border.Inf < 0 || maxBorder.Inf > 0 {
+		return false
+	}
+	return border.Value > maxBorder.Value ||
+		(border.Value == maxBorder.Value && (border.Exclude || maxBorder.Exclude))
+}
+
+// ErrInvalidLexBorder is returned if a lex range item doesn't start with ( or [
+var ErrInvalidLexBorder = errors.New("ERR min or max not valid string range item")
+
+// ParseLexBorder parses a lex range item: - and + are infinities, [a is inclusive and (a is exclusive
+func ParseLexBorder(s string) (*LexBorder, error) {
+	switch {
+	case s == "-":
+		return &LexBorder{Inf: -1}, nil
+	case s == "+":
+		return &LexBorder{Inf: 1}, nil
+	case len(s) > 0 && s[0] == '(':
+		return &LexBorder{Value: s[1:], Exclude: true}, nil
+	case len(s) > 0 && s[0] == '[':
+		return &LexBorder{Value: s[1:]}, nil
+	}
+	return nil, ErrInvalidLexBorder
+}
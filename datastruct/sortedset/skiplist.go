@@ -0,0 +1,286 @@
+package sortedset
+
+import "math/rand"
+
+const (
+	maxLevel = 32 // the same as ZSKIPLIST_MAXLEVEL of redis
+	// a node has level n+1 with probability p^n
+	levelProbability = 0.25
+)
+
+// Element is a member with its score
+type Element struct {
+	Member string
+	Score  float64
+}
+
+// less returns whether e is ordered before (score, member): by score first, then by member
+func (e *Element) less(score float64, member string) bool {
+	return e.Score < score || (e.Score == score && e.Member < member)
+}
+
+// level is a forward pointer of a node, span is the number of nodes it skips over plus one
+type level struct {
+	forward *node
+	span    int64
+}
+
+type node struct {
+	Element
+	backward *node
+	level    []level
+}
+
+// skiplist orders elements by (score, member) and supports looking up by rank in O(log n)
+type skiplist struct {
+	header *node
+	tail   *node
+	length int64
+	level  int
+}
+
+func makeNode(lvl int, score float64, member string) *node {
+	return &node{
+		Element: Element{
+			Score:  score,
+			Member: member,
+		},
+		level: make([]level, lvl),
+	}
+}
+
+func makeSkiplist() *skiplist {
+	return &skiplist{
+		header: makeNode(maxLevel, 0, ""),
+		level:  1,
+	}
+}
+
+func randomLevel() int {
+	lvl := 1
+	for lvl < maxLevel && rand.Float64() < levelProbability {
+		lvl++
+	}
+	return lvl
+}
+
+// insert adds a new element, the caller makes sure the member doesn't exist
+func (sl *skiplist) insert(member string, score float64) *node {
+	update := make([]*node, maxLevel) // the last node before the new node in each level
+	rank := make([]int64, maxLevel)   // rank of update[i]
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.less(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	lvl := randomLevel()
+	if lvl > sl.level {
+		for i := sl.level; i < lvl; i++ {
+			rank[i] = 0
+			update[i] = sl.header
+			update[i].level[i].span = sl.length
+		}
+		sl.level = lvl
+	}
+
+	x = makeNode(lvl, score, member)
+	for i := 0; i < lvl; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		// update[i] jumps to x now, x takes over the rest of the old span
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+	// levels higher than x skip over one more node
+	for i := lvl; i < sl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != sl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		sl.tail = x
+	}
+	sl.length++
+	return x
+}
+
+// removeNode unlinks x, update[i] is the last node before x in level i
+func (sl *skiplist) removeNode(x *node, update []*node) {
+	for i := 0; i < sl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		sl.tail = x.backward
+	}
+	for sl.level > 1 && sl.header.level[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+}
+
+// remove deletes the element, returns false if not found
+func (sl *skiplist) remove(member string, score float64) bool {
+	update := make([]*node, maxLevel)
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.less(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	x = x.level[0].forward
+	if x != nil && x.Score == score && x.Member == member {
+		sl.removeNode(x, update)
+		return true
+	}
+	return false
+}
+
+// getRank returns the 1-based rank of element, 0 if not found
+func (sl *skiplist) getRank(member string, score float64) int64 {
+	var rank int64
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			(x.level[i].forward.Score < score ||
+				(x.level[i].forward.Score == score && x.level[i].forward.Member <= member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != sl.header && x.Member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// getByRank returns the node of the 1-based rank, nil if out of range
+func (sl *skiplist) getByRank(rank int64) *node {
+	var traversed int64
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank && x != sl.header {
+			return x
+		}
+	}
+	return nil
+}
+
+// hasInRange returns false if no element can be within [min, max]. It only compares the range with
+// the first and the last element, so true doesn't mean there is an element in a gap of the range
+func (sl *skiplist) hasInRange(min Border, max Border) bool {
+	if min.isEmptyRange(max) {
+		return false
+	}
+	if sl.tail == nil || !min.minOK(&sl.tail.Element) {
+		return false
+	}
+	first := sl.header.level[0].forward
+	return max.maxOK(&first.Element)
+}
+
+// getFirstInRange returns the first node within [min, max], nil if there is none
+func (sl *skiplist) getFirstInRange(min Border, max Border) *node {
+	if !sl.hasInRange(min, max) {
+		return nil
+	}
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !min.minOK(&x.level[i].forward.Element) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if !max.maxOK(&x.Element) {
+		return nil
+	}
+	return x
+}
+
+// getLastInRange returns the last node within [min, max], nil if there is none
+func (sl *skiplist) getLastInRange(min Border, max Border) *node {
+	if !sl.hasInRange(min, max) {
+		return nil
+	}
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && max.maxOK(&x.level[i].forward.Element) {
+			x = x.level[i].forward
+		}
+	}
+	if x == sl.header || !min.minOK(&x.Element) {
+		return nil
+	}
+	return x
+}
+
+// removeRange deletes all elements within [min, max] and returns them
+func (sl *skiplist) removeRange(min Border, max Border) []*Element {
+	if min.isEmptyRange(max) {
+		return nil
+	}
+	update := make([]*node, maxLevel)
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !min.minOK(&x.level[i].forward.Element) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	var removed []*Element
+	x = x.level[0].forward
+	for x != nil && max.maxOK(&x.Element) {
+		next := x.level[0].forward
+		sl.removeNode(x, update)
+		removed = append(removed, &x.Element)
+		x = next
+	}
+	return removed
+}
+
+// removeRangeByRank deletes elements whose 1-based rank is within [start, stop] and returns them
+func (sl *skiplist) removeRangeByRank(start int64, stop int64) []*Element {
+	var traversed int64
+	update := make([]*node, maxLevel)
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span < start {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	var removed []*Element
+	traversed++
+	x = x.level[0].forward
+	for x != nil && traversed <= stop {
+		next := x.level[0].forward
+		sl.removeNode(x, update)
+		removed = append(removed, &x.Element)
+		traversed++
+		x = next
+	}
+	return removed
+}
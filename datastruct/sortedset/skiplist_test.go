@@ -0,0 +1,116 @@
+package sortedset
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// checkSkiplist checks order, backward pointers and spans of every level
+func checkSkiplist(t *testing.T, sl *skiplist) {
+	t.Helper()
+	var nodes []*node
+	var prev *node
+	for x := sl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		if prev != nil && !prev.less(x.Score, x.Member) {
+			t.Fatalf("%s:%v is not before %s:%v", prev.Member, prev.Score, x.Member, x.Score)
+		}
+		if x.backward != prev {
+			t.Fatalf("backward of %s is wrong", x.Member)
+		}
+		nodes = append(nodes, x)
+		prev = x
+	}
+	if sl.tail != prev || int64(len(nodes)) != sl.length {
+		t.Fatalf("length is %d with %d nodes, or tail is wrong", sl.length, len(nodes))
+	}
+	rank := make(map[*node]int64, len(nodes)+1)
+	rank[sl.header] = 0
+	for i, x := range nodes {
+		rank[x] = int64(i + 1)
+	}
+	for lvl := 0; lvl < sl.level; lvl++ {
+		for x := sl.header; x != nil; x = x.level[lvl].forward {
+			next := x.level[lvl].forward
+			if next == nil {
+				continue
+			}
+			if x.level[lvl].span != rank[next]-rank[x] {
+				t.Fatalf("span of level %d from rank %d is %d, expected %d", lvl, rank[x], x.level[lvl].span, rank[next]-rank[x])
+			}
+		}
+	}
+}
+
+func TestSkiplist(t *testing.T) {
+	sl := makeSkiplist()
+	r := rand.New(rand.NewSource(1))
+	scores := make(map[string]float64)
+	for i := 0; i < 2000; i++ {
+		member := strconv.Itoa(r.Intn(300))
+		if score, ok := scores[member]; ok {
+			if !sl.remove(member, score) {
+				t.Fatalf("remove(%s) fails", member)
+			}
+			delete(scores, member)
+		} else {
+			// few distinct scores, so that members break ties
+			score := float64(r.Intn(10))
+			sl.insert(member, score)
+			scores[member] = score
+		}
+		if i%50 == 0 {
+			checkSkiplist(t, sl)
+		}
+	}
+	checkSkiplist(t, sl)
+	if sl.remove("missing", 0) {
+		t.Fatal("remove of a missing member should fail")
+	}
+
+	for rank := int64(1); rank <= sl.length; rank++ {
+		x := sl.getByRank(rank)
+		if got := sl.getRank(x.Member, x.Score); got != rank {
+			t.Fatalf("getRank of the element at rank %d is %d", rank, got)
+		}
+	}
+	if sl.getByRank(sl.length+1) != nil || sl.getRank("missing", 0) != 0 {
+		t.Fatal("an element out of range should not be found")
+	}
+}
+
+func TestSkiplistRemoveRange(t *testing.T) {
+	sl := makeSkiplist()
+	for i := 0; i < 100; i++ {
+		sl.insert(strconv.Itoa(i), float64(i))
+	}
+	removed := sl.removeRange(&ScoreBorder{Value: 10}, &ScoreBorder{Value: 20, Exclude: true})
+	if len(removed) != 10 || removed[0].Member != "10" || removed[9].Member != "19" {
+		t.Fatalf("removeRange removes %d elements", len(removed))
+	}
+	checkSkiplist(t, sl)
+	// ranks are 1-based and inclusive
+	removed = sl.removeRangeByRank(1, 5)
+	if len(removed) != 5 || removed[0].Member != "0" || removed[4].Member != "4" {
+		t.Fatalf("removeRangeByRank removes %d elements", len(removed))
+	}
+	checkSkiplist(t, sl)
+	if sl.length != 85 {
+		t.Fatalf("length is %d, expected 85", sl.length)
+	}
+	if !sl.hasInRange(&ScoreBorder{Value: 50}, &ScoreBorder{Value: 60}) ||
+		sl.hasInRange(&ScoreBorder{Value: 100}, &ScoreBorder{Value: 200}) ||
+		sl.hasInRange(&ScoreBorder{Value: 0}, &ScoreBorder{Value: 5, Exclude: true}) ||
+		sl.hasInRange(&ScoreBorder{Value: 60}, &ScoreBorder{Value: 50}) {
+		t.Fatal("hasInRange is wrong")
+	}
+	if sl.getFirstInRange(&ScoreBorder{Value: 12}, &ScoreBorder{Value: 18}) != nil {
+		t.Fatal("getFirstInRange should return nil for a range within a gap")
+	}
+	if x := sl.getFirstInRange(&ScoreBorder{Value: 12}, &ScoreBorder{Value: 30}); x == nil || x.Member != "20" {
+		t.Fatal("getFirstInRange should skip removed elements")
+	}
+	if x := sl.getLastInRange(&ScoreBorder{Value: 0}, &ScoreBorder{Value: 15}); x == nil || x.Member != "9" {
+		t.Fatal("getLastInRange should skip removed elements")
+	}
+}
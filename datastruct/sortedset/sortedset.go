@@ -0,0 +1,235 @@
+// Package sortedset implements the redis sorted set type with a skiplist plus a dict
+package sortedset
+
+import "github.com/tonge3199/redis_go/datastruct/dict"
+
+// SortedSet is a collection of distinct members ordered by score, members with the same score
+// are ordered lexicographically.
+//
+// The dict maps a member to its score for O(1) lookups, the skiplist keeps the order
+// and answers rank and range queries in O(log n).
+type SortedSet struct {
+	dict     *dict.SimpleDict // member -> float64 score
+	skiplist *skiplist
+}
+
+// Make creates an empty SortedSet
+func Make() *SortedSet {
+	return &SortedSet{
+		dict:     dict.MakeSimple(),
+		skiplist: makeSkiplist(),
+	}
+}
+
+// Len returns the number of members
+func (sortedSet *SortedSet) Len() int64 {
+	return int64(sortedSet.dict.Len())
+}
+
+// Add puts member into set and returns true if member is new, the score of an existing member is updated
+func (sortedSet *SortedSet) Add(member string, score float64) bool {
+	old, exists := sortedSet.getScore(member)
+	sortedSet.dict.Put(member, score)
+	if exists {
+		if old != score {
+			sortedSet.skiplist.remove(member, old)
+			sortedSet.skiplist.insert(member, score)
+		}
+		return false
+	}
+	sortedSet.skiplist.insert(member, score)
+	return true
+}
+
+func (sortedSet *SortedSet) getScore(member string) (float64, bool) {
+	raw, exists := sortedSet.dict.Get(member)
+	if !exists {
+		return 0, false
+	}
+	return raw.(float64), true
+}
+
+// Get returns the element of member
+func (sortedSet *SortedSet) Get(member string) (*Element, bool) {
+	score, exists := sortedSet.getScore(member)
+	if !exists {
+		return nil, false
+	}
+	return &Element{
+		Member: member,
+		Score:  score,
+	}, true
+}
+
+// Remove deletes member and returns true if member existed
+func (sortedSet *SortedSet) Remove(member string) bool {
+	score, exists := sortedSet.getScore(member)
+	if !exists {
+		return false
+	}
+	sortedSet.skiplist.remove(member, score)
+	sortedSet.dict.Remove(member)
+	return true
+}
+
+// GetRank returns the 0-based rank of member, desc means ranking from the highest score
+func (sortedSet *SortedSet) GetRank(member string, desc bool) (int64, bool) {
+	score, exists := sortedSet.getScore(member)
+	if !exists {
+		return -1, false
+	}
+	rank := sortedSet.skiplist.getRank(member, score)
+	if desc {
+		return sortedSet.skiplist.length - rank, true
+	}
+	return rank - 1, true
+}
+
+// ForEachByRank visits elements whose 0-based rank is within [start, stop),
+// desc means ranking from the highest score. The consumer returns false to stop traversal
+func (sortedSet *SortedSet) ForEachByRank(start int64, stop int64, desc bool, consumer func(element *Element) bool) {
+	size := sortedSet.Len()
+	if start < 0 || start >= stop || stop > size {
+		return
+	}
+	var x *node
+	if desc {
+		x = sortedSet.skiplist.getByRank(size - start)
+	} else {
+		x = sortedSet.skiplist.getByRank(start + 1)
+	}
+	for i := start; i < stop && x != nil; i++ {
+		if !consumer(&x.Element) {
+			return
+		}
+		if desc {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+}
+
+// RangeByRank returns elements whose 0-based rank is within [start, stop)
+func (sortedSet *SortedSet) RangeByRank(start int64, stop int64, desc bool) []*Element {
+	var result []*Element
+	sortedSet.ForEachByRank(start, stop, desc, func(element *Element) bool {
+		result = append(result, element)
+		return true
+	})
+	return result
+}
+
+// RangeCount returns the number of elements within [min, max]
+func (sortedSet *SortedSet) RangeCount(min Border, max Border) int64 {
+	first := sortedSet.skiplist.getFirstInRange(min, max)
+	if first == nil {
+		return 0
+	}
+	last := sortedSet.skiplist.getLastInRange(min, max)
+	return sortedSet.skiplist.getRank(last.Member, last.Score) -
+		sortedSet.skiplist.getRank(first.Member, first.Score) + 1
+}
+
+// ForEach visits elements within [min, max], skipping the first offset ones and visiting at most limit ones,
+// a negative limit means no limit. desc means visiting from max to min
+func (sortedSet *SortedSet) ForEach(min Border, max Border, offset int64, limit int64, desc bool, consumer func(element *Element) bool) {
+	var x *node
+	if desc {
+		x = sortedSet.skiplist.getLastInRange(min, max)
+	} else {
+		x = sortedSet.skiplist.getFirstInRange(min, max)
+	}
+	for x != nil && offset > 0 {
+		if desc {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+		offset--
+	}
+	for ; x != nil && limit != 0; limit-- {
+		if desc && !min.minOK(&x.Element) || !desc && !max.maxOK(&x.Element) {
+			return
+		}
+		if !consumer(&x.Element) {
+			return
+		}
+		if desc {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+}
+
+// Range returns elements within [min, max], see ForEach for offset, limit and desc
+func (sortedSet *SortedSet) Range(min Border, max Border, offset int64, limit int64, desc bool) []*Element {
+	var result []*Element
+	sortedSet.ForEach(min, max, offset, limit, desc, func(element *Element) bool {
+		result = append(result, element)
+		return true
+	})
+	return result
+}
+
+// RemoveRange deletes elements within [min, max] and returns the number of deleted elements
+func (sortedSet *SortedSet) RemoveRange(min Border, max Border) int64 {
+	removed := sortedSet.skiplist.removeRange(min, max)
+	for _, element := range removed {
+		sortedSet.dict.Remove(element.Member)
+	}
+	return int64(len(removed))
+}
+
+// RemoveByRank deletes elements whose 0-based rank is within [start, stop) and returns them
+func (sortedSet *SortedSet) RemoveByRank(start int64, stop int64) []*Element {
+	if start < 0 || start >= stop {
+		return nil
+	}
+	removed := sortedSet.skiplist.removeRangeByRank(start+1, stop)
+	for _, element := range removed {
+		sortedSet.dict.Remove(element.Member)
+	}
+	return removed
+}
+
+// PopMin removes and returns at most count elements with the lowest scores, in ascending order
+func (sortedSet *SortedSet) PopMin(count int64) []*Element {
+	return sortedSet.RemoveByRank(0, count)
+}
+
+// PopMax removes and returns at most count elements with the highest scores, in descending order
+func (sortedSet *SortedSet) PopMax(count int64) []*Element {
+	size := sortedSet.Len()
+	start := size - count
+	if start < 0 {
+		start = 0
+	}
+	removed := sortedSet.RemoveByRank(start, size)
+	for i, j := 0, len(removed)-1; i < j; i, j = i+1, j-1 {
+		removed[i], removed[j] = removed[j], removed[i]
+	}
+	return removed
+}
+
+// Clone returns a copy of sortedSet
+func (sortedSet *SortedSet) Clone() *SortedSet {
+	clone := &SortedSet{
+		dict:     sortedSet.dict.Clone(),
+		skiplist: makeSkiplist(),
+	}
+	for x := sortedSet.skiplist.header.level[0].forward; x != nil; x = x.level[0].forward {
+		clone.skiplist.insert(x.Member, x.Score)
+	}
+	return clone
+}
+
+// Scan visits about count elements from cursor in no particular order, and returns the cursor to continue with,
+// see dict.Dict.Scan for the guarantee of scanning
+func (sortedSet *SortedSet) Scan(cursor uint64, count int, consumer func(element *Element)) uint64 {
+	return sortedSet.dict.Scan(cursor, count, func(member string, val interface{}) bool {
+		consumer(&Element{Member: member, Score: val.(float64)})
+		return true
+	})
+}
@@ -0,0 +1,200 @@
+package sortedset
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// sortedElements returns elements of the model ordered by score and member
+func sortedElements(scores map[string]float64) []Element {
+	elements := make([]Element, 0, len(scores))
+	for member, score := range scores {
+		elements = append(elements, Element{Member: member, Score: score})
+	}
+	sort.Slice(elements, func(i, j int) bool {
+		return elements[i].less(elements[j].Score, elements[j].Member)
+	})
+	return elements
+}
+
+func checkElements(t *testing.T, name string, got []*Element, expected []Element) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("%s returns %d elements, expected %d", name, len(got), len(expected))
+	}
+	for i := range got {
+		if *got[i] != expected[i] {
+			t.Fatalf("%s[%d] is %v, expected %v", name, i, *got[i], expected[i])
+		}
+	}
+}
+
+func reversed(elements []Element) []Element {
+	result := make([]Element, len(elements))
+	for i, e := range elements {
+		result[len(elements)-1-i] = e
+	}
+	return result
+}
+
+func TestSortedSetRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	set := Make()
+	scores := make(map[string]float64)
+	for i := 0; i < 1000; i++ {
+		member := strconv.Itoa(r.Intn(100))
+		if r.Intn(4) == 0 {
+			_, exists := scores[member]
+			if set.Remove(member) != exists {
+				t.Fatalf("Remove(%s) is wrong", member)
+			}
+			delete(scores, member)
+			continue
+		}
+		score := float64(r.Intn(20))
+		_, exists := scores[member]
+		if set.Add(member, score) == exists {
+			t.Fatalf("Add(%s) is wrong", member)
+		}
+		scores[member] = score
+	}
+	elements := sortedElements(scores)
+	size := int64(len(elements))
+	if set.Len() != size {
+		t.Fatalf("Len() is %d, expected %d", set.Len(), size)
+	}
+	for i, e := range elements {
+		rank, ok := set.GetRank(e.Member, false)
+		descRank, _ := set.GetRank(e.Member, true)
+		if !ok || rank != int64(i) || descRank != size-1-int64(i) {
+			t.Fatalf("ranks of %s are %d and %d, expected %d", e.Member, rank, descRank, i)
+		}
+		if got, _ := set.Get(e.Member); got.Score != e.Score {
+			t.Fatalf("score of %s is %v, expected %v", e.Member, got.Score, e.Score)
+		}
+	}
+	if _, ok := set.GetRank("missing", false); ok {
+		t.Fatal("a missing member should have no rank")
+	}
+
+	checkElements(t, "RangeByRank", set.RangeByRank(3, 10, false), elements[3:10])
+	checkElements(t, "RangeByRank desc", set.RangeByRank(0, 5, true), reversed(elements)[0:5])
+	checkElements(t, "RangeByRank out of range", set.RangeByRank(0, size+1, false), nil)
+
+	// (5, 10]
+	min := &ScoreBorder{Value: 5, Exclude: true}
+	max := &ScoreBorder{Value: 10}
+	var inRange []Element
+	for _, e := range elements {
+		if e.Score > 5 && e.Score <= 10 {
+			inRange = append(inRange, e)
+		}
+	}
+	if count := set.RangeCount(min, max); count != int64(len(inRange)) {
+		t.Fatalf("RangeCount is %d, expected %d", count, len(inRange))
+	}
+	checkElements(t, "Range", set.Range(min, max, 0, -1, false), inRange)
+	checkElements(t, "Range with limit", set.Range(min, max, 2, 3, false), inRange[2:5])
+	checkElements(t, "Range desc", set.Range(min, max, 1, -1, true), reversed(inRange)[1:])
+
+	clone := set.Clone()
+	if removed := set.RemoveRange(min, max); removed != int64(len(inRange)) {
+		t.Fatalf("RemoveRange removes %d, expected %d", removed, len(inRange))
+	}
+	if set.RangeCount(min, max) != 0 || clone.RangeCount(min, max) != int64(len(inRange)) {
+		t.Fatal("RemoveRange should not change the clone")
+	}
+}
+
+func TestSortedSetPop(t *testing.T) {
+	set := Make()
+	for i := 0; i < 10; i++ {
+		set.Add(strconv.Itoa(i), float64(i))
+	}
+	set.Add("5", 100) // update the score
+	popped := set.PopMin(2)
+	if len(popped) != 2 || popped[0].Member != "0" || popped[1].Member != "1" {
+		t.Fatalf("PopMin returns %v", popped)
+	}
+	popped = set.PopMax(2)
+	if len(popped) != 2 || popped[0].Member != "5" || popped[1].Member != "9" {
+		t.Fatalf("PopMax returns %v", popped)
+	}
+	popped = set.PopMax(100)
+	if len(popped) != 6 || popped[0].Member != "8" || set.Len() != 0 {
+		t.Fatalf("PopMax returns %d elements", len(popped))
+	}
+	if set.PopMin(1) != nil {
+		t.Fatal("PopMin of an empty set should return nothing")
+	}
+}
+
+func TestSortedSetLexRange(t *testing.T) {
+	set := Make()
+	for _, member := range []string{"a", "b", "c", "d", "e"} {
+		set.Add(member, 0)
+	}
+	tests := []struct {
+		min, max string
+		expected string
+	}{
+		{"-", "+", "abcde"},
+		{"[b", "[d", "bcd"},
+		{"(b", "(d", "c"},
+		{"[c", "+", "cde"},
+		{"(e", "+", ""},
+		{"[d", "[b", ""},
+	}
+	for _, tt := range tests {
+		min, err := ParseLexBorder(tt.min)
+		if err != nil {
+			t.Fatal(err)
+		}
+		max, err := ParseLexBorder(tt.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		for _, e := range set.Range(min, max, 0, -1, false) {
+			got += e.Member
+		}
+		if got != tt.expected {
+			t.Errorf("range %s %s is %q, expected %q", tt.min, tt.max, got, tt.expected)
+		}
+	}
+	if _, err := ParseLexBorder("b"); err != ErrInvalidLexBorder {
+		t.Error("a lex border should start with ( or [")
+	}
+}
+
+func TestParseScoreBorder(t *testing.T) {
+	tests := []struct {
+		s       string
+		value   float64
+		exclude bool
+		err     bool
+	}{
+		{"1.5", 1.5, false, false},
+		{"(1.5", 1.5, true, false},
+		{"-inf", math.Inf(-1), false, false},
+		{"+inf", math.Inf(1), false, false},
+		{"(", 0, false, true},
+		{"nan", 0, false, true},
+		{"abc", 0, false, true},
+	}
+	for _, tt := range tests {
+		border, err := ParseScoreBorder(tt.s)
+		if tt.err {
+			if err != ErrInvalidScoreBorder {
+				t.Errorf("ParseScoreBorder(%q) should fail", tt.s)
+			}
+			continue
+		}
+		if err != nil || border.Value != tt.value || border.Exclude != tt.exclude {
+			t.Errorf("ParseScoreBorder(%q) = %v, %v", tt.s, border, err)
+		}
+	}
+}
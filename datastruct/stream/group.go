@@ -0,0 +1,288 @@
+package stream
+
+import (
+	"github.com/tonge3199/redis_go/datastruct/radix"
+)
+
+// Group is a consumer group, entries delivered to its consumers are pending until acknowledged
+type Group struct {
+	Name        string
+	LastID      ID          // ID of the last entry delivered to the group
+	EntriesRead int64       // logical number of entries read, InvalidEntriesRead if unknown
+	pel         *radix.Tree // ID -> *NACK, pending entries of all consumers
+	consumers   *radix.Tree // name -> *Consumer
+}
+
+// Consumer is a consumer of a group
+type Consumer struct {
+	Name       string
+	SeenTime   int64       // unix milliseconds of the last attempted interaction
+	ActiveTime int64       // unix milliseconds of the last successful interaction, -1 if never
+	pel        *radix.Tree // ID -> *NACK, pending entries delivered to this consumer
+}
+
+// NACK is a pending entry, which was delivered to a consumer but not acknowledged yet
+type NACK struct {
+	DeliveryTime  int64 // unix milliseconds of the last delivery
+	DeliveryCount int64
+	Consumer      *Consumer
+}
+
+// CreateGroup creates a consumer group, returns false if the group exists
+func (s *Stream) CreateGroup(name string, lastID ID, entriesRead int64) (*Group, bool) {
+	if _, exists := s.groups.Find([]byte(name)); exists {
+		return nil, false
+	}
+	group := &Group{
+		Name:        name,
+		LastID:      lastID,
+		EntriesRead: entriesRead,
+		pel:         radix.New(),
+		consumers:   radix.New(),
+	}
+	s.groups.Insert([]byte(name), group)
+	return group, true
+}
+
+// GetGroup returns the consumer group of name
+func (s *Stream) GetGroup(name string) (*Group, bool) {
+	raw, ok := s.groups.Find([]byte(name))
+	if !ok {
+		return nil, false
+	}
+	return raw.(*Group), true
+}
+
+// DestroyGroup removes the consumer group of name, returns false if it doesn't exist
+func (s *Stream) DestroyGroup(name string) bool {
+	_, ok := s.groups.Remove([]byte(name))
+	return ok
+}
+
+// GroupCount returns the number of consumer groups
+func (s *Stream) GroupCount() int {
+	return s.groups.Len()
+}
+
+// ForEachGroup visits consumer groups ordered by name, it stops if consumer returns false
+func (s *Stream) ForEachGroup(consumer func(group *Group) bool) {
+	s.groups.Ascend(nil, func(key []byte, value any) bool {
+		return consumer(value.(*Group))
+	})
+}
+
+// GetConsumer returns the consumer of name
+func (g *Group) GetConsumer(name string) (*Consumer, bool) {
+	raw, ok := g.consumers.Find([]byte(name))
+	if !ok {
+		return nil, false
+	}
+	return raw.(*Consumer), true
+}
+
+// CreateConsumer creates a consumer, returns false if the consumer exists
+func (g *Group) CreateConsumer(name string, now int64) (*Consumer, bool) {
+	if consumer, ok := g.GetConsumer(name); ok {
+		return consumer, false
+	}
+	consumer := &Consumer{
+		Name:       name,
+		SeenTime:   now,
+		ActiveTime: -1,
+		pel:        radix.New(),
+	}
+	g.consumers.Insert([]byte(name), consumer)
+	return consumer, true
+}
+
+// DeleteConsumer removes the consumer and its pending entries, returns the number of its pending entries
+func (g *Group) DeleteConsumer(name string) (int, bool) {
+	consumer, ok := g.GetConsumer(name)
+	if !ok {
+		return 0, false
+	}
+	pending := consumer.pel.Len()
+	consumer.pel.Ascend(nil, func(key []byte, value any) bool {
+		g.pel.Remove(key)
+		return true
+	})
+	g.consumers.Remove([]byte(name))
+	return pending, true
+}
+
+// ConsumerCount returns the number of consumers
+func (g *Group) ConsumerCount() int {
+	return g.consumers.Len()
+}
+
+// ForEachConsumer visits consumers ordered by name, it stops if fn returns false
+func (g *Group) ForEachConsumer(fn func(consumer *Consumer) bool) {
+	g.consumers.Ascend(nil, func(key []byte, value any) bool {
+		return fn(value.(*Consumer))
+	})
+}
+
+// PendingCount returns the number of pending entries of the group
+func (g *Group) PendingCount() int {
+	return g.pel.Len()
+}
+
+// PendingCount returns the number of pending entries of the consumer
+func (c *Consumer) PendingCount() int {
+	return c.pel.Len()
+}
+
+func forEachPending(pel *radix.Tree, start ID, fn func(id ID, nack *NACK) bool) {
+	pel.Ascend(start.encode(), func(key []byte, value any) bool {
+		return fn(decodeID(key), value.(*NACK))
+	})
+}
+
+// ForEachPending visits pending entries of the group whose ID >= start, it stops if fn returns false.
+// The pending entries list must not be modified during traversal
+func (g *Group) ForEachPending(start ID, fn func(id ID, nack *NACK) bool) {
+	forEachPending(g.pel, start, fn)
+}
+
+// ForEachPending visits pending entries of the consumer whose ID >= start, it stops if fn returns false.
+// The pending entries list must not be modified during traversal
+func (c *Consumer) ForEachPending(start ID, fn func(id ID, nack *NACK) bool) {
+	forEachPending(c.pel, start, fn)
+}
+
+// GetPending returns the pending entry of id
+func (g *Group) GetPending(id ID) (*NACK, bool) {
+	raw, ok := g.pel.Find(id.encode())
+	if !ok {
+		return nil, false
+	}
+	return raw.(*NACK), true
+}
+
+// Ack removes the pending entry of id, returns false if it is not pending
+func (g *Group) Ack(id ID) bool {
+	raw, ok := g.pel.Remove(id.encode())
+	if !ok {
+		return false
+	}
+	raw.(*NACK).Consumer.pel.Remove(id.encode())
+	return true
+}
+
+// Claim transfers the ownership of a pending entry to consumer, the entry becomes pending if it wasn't.
+// Delivery time and count are kept, the caller updates them
+func (g *Group) Claim(id ID, consumer *Consumer) *NACK {
+	key := id.encode()
+	nack, ok := g.GetPending(id)
+	if !ok {
+		nack = &NACK{}
+		g.pel.Insert(key, nack)
+	} else if nack.Consumer != consumer {
+		nack.Consumer.pel.Remove(key)
+	}
+	nack.Consumer = consumer
+	consumer.pel.Insert(key, nack)
+	return nack
+}
+
+// ReadNew delivers at most count (0 means unlimited) entries after the last ID of the group to consumer.
+// The delivered entries become pending unless noack
+func (s *Stream) ReadNew(g *Group, consumer *Consumer, count int, noack bool, now int64) []*Entry {
+	start, ok := g.LastID.Incr()
+	if !ok {
+		return nil
+	}
+	var entries []*Entry
+	s.Range(start, MaxID, false, func(entry *Entry) bool {
+		if g.EntriesRead != InvalidEntriesRead && !s.RangeHasTombstones(entry.ID) {
+			// a valid counter and no future tombstones mean we can increment the counter
+			g.EntriesRead++
+		} else if s.entriesAdded > 0 {
+			// the counter may be invalid, so we try to obtain it
+			g.EntriesRead = s.EstimateDistance(entry.ID)
+		}
+		g.LastID = entry.ID
+		if !noack {
+			nack := g.Claim(entry.ID, consumer)
+			nack.DeliveryTime = now
+			nack.DeliveryCount = 1
+		}
+		entries = append(entries, entry)
+		return count == 0 || len(entries) < count
+	})
+	if len(entries) > 0 {
+		consumer.ActiveTime = now
+	}
+	return entries
+}
+
+// ReadPending returns at most count (0 means unlimited) pending entries of consumer whose ID >= start,
+// an entry deleted from the stream is returned with nil Fields.
+// Delivery time and count of the returned entries are updated
+func (s *Stream) ReadPending(consumer *Consumer, start ID, count int, now int64) []*Entry {
+	var entries []*Entry
+	consumer.ForEachPending(start, func(id ID, nack *NACK) bool {
+		entry, ok := s.Get(id)
+		if ok {
+			nack.DeliveryTime = now
+			nack.DeliveryCount++
+		} else {
+			entry = &Entry{ID: id}
+		}
+		entries = append(entries, entry)
+		return count == 0 || len(entries) < count
+	})
+	return entries
+}
+
+// Lag returns the number of entries not delivered to the group yet, ok is false if it can't be known
+func (s *Stream) Lag(g *Group) (lag int64, ok bool) {
+	if s.entriesAdded == 0 {
+		return 0, true
+	}
+	if g.EntriesRead != InvalidEntriesRead && !s.RangeHasTombstones(g.LastID) {
+		// no fragmentation ahead means that the counter of the group is valid
+		return s.entriesAdded - g.EntriesRead, true
+	}
+	entriesRead := s.EstimateDistance(g.LastID)
+	if entriesRead == InvalidEntriesRead {
+		return 0, false
+	}
+	return s.entriesAdded - entriesRead, true
+}
+
+// cloneGroups returns a deep copy of consumer groups,
+// a pending entry is shared by the group and its consumer in the copy as well
+func cloneGroups(groups *radix.Tree) *radix.Tree {
+	clone := radix.New()
+	groups.Ascend(nil, func(key []byte, value any) bool {
+		g := value.(*Group)
+		copied := &Group{
+			Name:        g.Name,
+			LastID:      g.LastID,
+			EntriesRead: g.EntriesRead,
+			pel:         radix.New(),
+			consumers:   radix.New(),
+		}
+		g.ForEachConsumer(func(consumer *Consumer) bool {
+			c := &Consumer{
+				Name:       consumer.Name,
+				SeenTime:   consumer.SeenTime,
+				ActiveTime: consumer.ActiveTime,
+				pel:        radix.New(),
+			}
+			copied.consumers.Insert([]byte(c.Name), c)
+			consumer.pel.Ascend(nil, func(id []byte, value any) bool {
+				nack := *value.(*NACK)
+				nack.Consumer = c
+				copied.pel.Insert(id, &nack)
+				c.pel.Insert(id, &nack)
+				return true
+			})
+			return true
+		})
+		clone.Insert(key, copied)
+		return true
+	})
+	return clone
+}
@@ -0,0 +1,96 @@
+package stream
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ID identifies a stream entry, it is composed of a millisecond timestamp and a sequence number
+type ID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// MaxID is the greatest possible ID
+var MaxID = ID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+// String returns the ID in the form of "<ms>-<seq>"
+func (id ID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Compare returns -1, 0 or 1 if id is less than, equal to or greater than other
+func (id ID) Compare(other ID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	}
+	return 0
+}
+
+// IsZero returns whether id is 0-0
+func (id ID) IsZero() bool {
+	return id.Ms == 0 && id.Seq == 0
+}
+
+// Incr returns the next ID, ok is false if id is MaxID
+func (id ID) Incr() (ID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return ID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return ID{Ms: id.Ms + 1}, true
+	}
+	return id, false
+}
+
+// Decr returns the previous ID, ok is false if id is 0-0
+func (id ID) Decr() (ID, bool) {
+	switch {
+	case id.Seq > 0:
+		return ID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return ID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// encode returns the big-endian 128 bits form of id, so that byte order equals ID order in radix tree
+func (id ID) encode() []byte {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf, id.Ms)
+	binary.BigEndian.PutUint64(buf[8:], id.Seq)
+	return buf
+}
+
+func decodeID(buf []byte) ID {
+	return ID{
+		Ms:  binary.BigEndian.Uint64(buf),
+		Seq: binary.BigEndian.Uint64(buf[8:]),
+	}
+}
+
+// ParseID parses "<ms>-<seq>" or "<ms>", the sequence number of the latter is defaultSeq
+func ParseID(s string, defaultSeq uint64) (ID, bool) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return ID{}, false
+	}
+	if !hasSeq {
+		return ID{Ms: ms, Seq: defaultSeq}, true
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return ID{}, false
+	}
+	return ID{Ms: ms, Seq: seq}, true
+}
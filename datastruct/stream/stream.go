@@ -0,0 +1,589 @@
+// Package stream implements the stream data type: an append-only log of entries with consumer groups
+package stream
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/tonge3199/redis_go/datastruct/listpack"
+	"github.com/tonge3199/redis_go/datastruct/radix"
+)
+
+// Entry is an entry of a stream, Fields is [field1, value1, field2, value2 ...]
+type Entry struct {
+	ID     ID
+	Fields [][]byte
+}
+
+// Stream stores entries in a radix tree of listpack nodes, the same layout as redis:
+// the key of a node is the ID of its first entry (the master ID) and a node holds
+// at most nodeMaxEntries entries or nodeMaxBytes bytes.
+//
+// A node starts with the master entry, which counts the entries and stores the field names
+// of the first entry:
+//
+//	| count | deleted | num-fields | field_1 | ... | field_N | 0 |
+//
+// Every entry follows, with its ID stored as the difference to the master ID.
+// Entries having the same fields as the master entry (which is usual since producers often
+// send the same fields) only store the values:
+//
+//	| flags | ms-diff | seq-diff | num-fields | field_1 | value_1 | ... | field_N | value_N |
+//	| flags | ms-diff | seq-diff | value_1 | ... | value_N |   (flags has flagSameFields)
+//
+// Deleting an entry only sets flagDeleted, a node is removed once all its entries are deleted.
+// Unlike redis, entries don't end with a lp-count element for backward traversal,
+// since nodes are small, entries of a node are collected and reversed instead
+type Stream struct {
+	rax            *radix.Tree // master ID -> *listpack.ListPack
+	length         int64
+	lastID         ID // ID of the last added entry, even if it was deleted
+	firstID        ID // ID of the first entry, 0-0 if stream is empty
+	maxDeletedID   ID // the greatest ID deleted by XDEL
+	entriesAdded   int64
+	groups         *radix.Tree // name -> *Group
+	nodeMaxBytes   int
+	nodeMaxEntries int
+}
+
+const (
+	flagNone       = 0
+	flagDeleted    = 1
+	flagSameFields = 2
+)
+
+// maxNodeBytes is the limit of node size if nodeMaxBytes is 0
+const maxNodeBytes = 1 << 30
+
+// Make creates an empty stream, nodeMaxBytes and nodeMaxEntries of 0 mean unlimited
+func Make(nodeMaxBytes int, nodeMaxEntries int) *Stream {
+	if nodeMaxBytes <= 0 || nodeMaxBytes > maxNodeBytes {
+		nodeMaxBytes = maxNodeBytes
+	}
+	return &Stream{
+		rax:            radix.New(),
+		groups:         radix.New(),
+		nodeMaxBytes:   nodeMaxBytes,
+		nodeMaxEntries: nodeMaxEntries,
+	}
+}
+
+// Len returns the number of entries
+func (s *Stream) Len() int64 {
+	return s.length
+}
+
+// LastID returns the ID of the last added entry, it is 0-0 if no entry was added
+func (s *Stream) LastID() ID {
+	return s.lastID
+}
+
+// FirstID returns the ID of the first entry, it is 0-0 if stream is empty
+func (s *Stream) FirstID() ID {
+	return s.firstID
+}
+
+// MaxDeletedID returns the greatest ID deleted by Delete
+func (s *Stream) MaxDeletedID() ID {
+	return s.maxDeletedID
+}
+
+// EntriesAdded returns the number of entries added in the lifetime of the stream
+func (s *Stream) EntriesAdded() int64 {
+	return s.entriesAdded
+}
+
+// NodeCount returns the number of listpack nodes
+func (s *Stream) NodeCount() int {
+	return s.rax.Len()
+}
+
+// RadixNodeCount returns the number of nodes of the radix tree
+func (s *Stream) RadixNodeCount() int {
+	return s.rax.NodeCount()
+}
+
+/* ---- node encoding ---- */
+
+func formatInt(v int64) []byte {
+	return []byte(strconv.FormatInt(v, 10))
+}
+
+func parseInt(val []byte) int64 {
+	v, _ := strconv.ParseInt(string(val), 10, 64)
+	return v
+}
+
+func parseUint(val []byte) uint64 {
+	v, _ := strconv.ParseUint(string(val), 10, 64)
+	return v
+}
+
+// nodeHeader is the decoded master entry of a node
+type nodeHeader struct {
+	count         int64
+	deleted       int64
+	deletedOffset int // offset of the deleted field
+	fields        [][]byte
+	entriesOffset int // offset of the first entry
+}
+
+func readHeader(lp *listpack.ListPack) *nodeHeader {
+	header := &nodeHeader{}
+	val, offset := lp.Next(lp.First())
+	header.count = parseInt(val)
+	header.deletedOffset = offset
+	val, offset = lp.Next(offset)
+	header.deleted = parseInt(val)
+	val, offset = lp.Next(offset)
+	header.fields = make([][]byte, parseInt(val))
+	for i := range header.fields {
+		header.fields[i], offset = lp.Next(offset)
+	}
+	_, header.entriesOffset = lp.Next(offset) // skip the master terminator
+	return header
+}
+
+// setCounts updates count and deleted of the master entry
+func setCounts(lp *listpack.ListPack, header *nodeHeader, count int64, deleted int64) {
+	// replace deleted first, since replacing count moves the offset of deleted
+	lp.Replace(header.deletedOffset, formatInt(deleted))
+	lp.Replace(lp.First(), formatInt(count))
+	header.count, header.deleted = count, deleted
+}
+
+// nodeEntry is a decoded entry in a node, fields share memory with the listpack
+type nodeEntry struct {
+	offset int // offset of flags
+	flags  int64
+	id     ID
+	fields [][]byte
+}
+
+// forEachInNode visits all entries of a node including deleted ones, it stops if consumer returns false
+func forEachInNode(master ID, lp *listpack.ListPack, consumer func(entry *nodeEntry) bool) {
+	header := readHeader(lp)
+	for offset := header.entriesOffset; offset < lp.End(); {
+		entry := &nodeEntry{offset: offset}
+		var val []byte
+		val, offset = lp.Next(offset)
+		entry.flags = parseInt(val)
+		val, offset = lp.Next(offset)
+		entry.id.Ms = master.Ms + parseUint(val)
+		val, offset = lp.Next(offset)
+		entry.id.Seq = master.Seq + parseUint(val)
+		if entry.flags&flagSameFields > 0 {
+			entry.fields = make([][]byte, 2*len(header.fields))
+			for i, field := range header.fields {
+				entry.fields[2*i] = field
+				entry.fields[2*i+1], offset = lp.Next(offset)
+			}
+		} else {
+			val, offset = lp.Next(offset)
+			entry.fields = make([][]byte, 2*parseInt(val))
+			for i := range entry.fields {
+				entry.fields[i], offset = lp.Next(offset)
+			}
+		}
+		if !consumer(entry) {
+			return
+		}
+	}
+}
+
+func sameFields(masterFields [][]byte, fields [][]byte) bool {
+	if len(fields) != 2*len(masterFields) {
+		return false
+	}
+	for i, field := range masterFields {
+		if !bytes.Equal(field, fields[2*i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func entrySize(fields [][]byte) int {
+	size := 0
+	for _, field := range fields {
+		size += len(field)
+	}
+	return size
+}
+
+/* ---- read & write ---- */
+
+// Add appends an entry, id must be greater than LastID
+func (s *Stream) Add(id ID, fields [][]byte) {
+	var lp *listpack.ListPack
+	var master ID
+	if key, raw, ok := s.rax.Last(); ok {
+		lp = raw.(*listpack.ListPack)
+		master = decodeID(key)
+		if lp.Bytes()+entrySize(fields) >= s.nodeMaxBytes {
+			lp = nil
+		} else if s.nodeMaxEntries > 0 {
+			header := readHeader(lp)
+			if header.count+header.deleted >= int64(s.nodeMaxEntries) {
+				lp = nil
+			}
+		}
+	}
+	if lp == nil {
+		// create a new node, fields of this entry become the master fields
+		lp = listpack.New()
+		lp.Append(formatInt(0), formatInt(0), formatInt(int64(len(fields)/2)))
+		for i := 0; i < len(fields); i += 2 {
+			lp.Append(fields[i])
+		}
+		lp.Append(formatInt(0))
+		master = id
+		s.rax.Insert(id.encode(), lp)
+	}
+
+	header := readHeader(lp)
+	same := sameFields(header.fields, fields)
+	flags := int64(flagNone)
+	if same {
+		flags |= flagSameFields
+	}
+	lp.Append(formatInt(flags),
+		[]byte(strconv.FormatUint(id.Ms-master.Ms, 10)),
+		[]byte(strconv.FormatUint(id.Seq-master.Seq, 10)))
+	if same {
+		for i := 1; i < len(fields); i += 2 {
+			lp.Append(fields[i])
+		}
+	} else {
+		lp.Append(formatInt(int64(len(fields) / 2)))
+		lp.Append(fields...)
+	}
+	setCounts(lp, header, header.count+1, header.deleted)
+
+	s.length++
+	s.entriesAdded++
+	s.lastID = id
+	if s.length == 1 {
+		s.firstID = id
+	}
+}
+
+func cloneEntry(entry *nodeEntry) *Entry {
+	fields := make([][]byte, len(entry.fields))
+	for i, field := range entry.fields {
+		fields[i] = bytes.Clone(field)
+	}
+	return &Entry{
+		ID:     entry.id,
+		Fields: fields,
+	}
+}
+
+// floorNodeKey returns the key of the node which may contain id, nil if id is less than all nodes
+func (s *Stream) floorNodeKey(id ID) []byte {
+	var floor []byte
+	s.rax.Descend(id.encode(), func(key []byte, value any) bool {
+		floor = bytes.Clone(key)
+		return false
+	})
+	return floor
+}
+
+// Range visits entries whose ID is in [start, end], in descending order if rev.
+// It stops if consumer returns false, the stream must not be modified during traversal
+func (s *Stream) Range(start ID, end ID, rev bool, consumer func(entry *Entry) bool) {
+	if start.Compare(end) > 0 {
+		return
+	}
+	if rev {
+		s.rax.Descend(end.encode(), func(key []byte, value any) bool {
+			var entries []*nodeEntry
+			forEachInNode(decodeID(key), value.(*listpack.ListPack), func(entry *nodeEntry) bool {
+				if entry.id.Compare(end) > 0 {
+					return false
+				}
+				if entry.flags&flagDeleted == 0 {
+					entries = append(entries, entry)
+				}
+				return true
+			})
+			for i := len(entries) - 1; i >= 0; i-- {
+				if entries[i].id.Compare(start) < 0 {
+					return false
+				}
+				if !consumer(cloneEntry(entries[i])) {
+					return false
+				}
+			}
+			return true
+		})
+		return
+	}
+
+	s.rax.Ascend(s.floorNodeKey(start), func(key []byte, value any) bool {
+		master := decodeID(key)
+		if master.Compare(end) > 0 {
+			return false
+		}
+		goOn := true
+		forEachInNode(master, value.(*listpack.ListPack), func(entry *nodeEntry) bool {
+			if entry.flags&flagDeleted > 0 || entry.id.Compare(start) < 0 {
+				return true
+			}
+			if entry.id.Compare(end) > 0 {
+				goOn = false
+				return false
+			}
+			goOn = consumer(cloneEntry(entry))
+			return goOn
+		})
+		return goOn
+	})
+}
+
+// Get returns the entry of id
+func (s *Stream) Get(id ID) (*Entry, bool) {
+	var result *Entry
+	s.Range(id, id, false, func(entry *Entry) bool {
+		result = entry
+		return false
+	})
+	return result, result != nil
+}
+
+// Exists returns whether the entry of id exists
+func (s *Stream) Exists(id ID) bool {
+	key := s.floorNodeKey(id)
+	if key == nil {
+		return false
+	}
+	raw, _ := s.rax.Find(key)
+	found := false
+	forEachInNode(decodeID(key), raw.(*listpack.ListPack), func(entry *nodeEntry) bool {
+		cmp := entry.id.Compare(id)
+		found = cmp == 0 && entry.flags&flagDeleted == 0
+		return cmp < 0
+	})
+	return found
+}
+
+// First returns the first entry
+func (s *Stream) First() (*Entry, bool) {
+	var result *Entry
+	s.Range(ID{}, MaxID, false, func(entry *Entry) bool {
+		result = entry
+		return false
+	})
+	return result, result != nil
+}
+
+// Last returns the last entry
+func (s *Stream) Last() (*Entry, bool) {
+	var result *Entry
+	s.Range(ID{}, MaxID, true, func(entry *Entry) bool {
+		result = entry
+		return false
+	})
+	return result, result != nil
+}
+
+// SetID overwrites the last ID, the number of added entries and the greatest deleted ID, it's used by XSETID.
+// The caller makes sure lastID is not less than the ID of the last entry, and entriesAdded is not less than Len
+func (s *Stream) SetID(lastID ID, entriesAdded int64, maxDeletedID ID) {
+	s.lastID = lastID
+	s.entriesAdded = entriesAdded
+	s.maxDeletedID = maxDeletedID
+}
+
+// updateFirstID is called after the first entry may be removed
+func (s *Stream) updateFirstID() {
+	s.firstID = ID{}
+	if first, ok := s.First(); ok {
+		s.firstID = first.ID
+	}
+}
+
+// Delete marks the entry of id as deleted, returns false if it doesn't exist
+func (s *Stream) Delete(id ID) bool {
+	key := s.floorNodeKey(id)
+	if key == nil {
+		return false
+	}
+	raw, _ := s.rax.Find(key)
+	lp := raw.(*listpack.ListPack)
+	var target *nodeEntry
+	forEachInNode(decodeID(key), lp, func(entry *nodeEntry) bool {
+		cmp := entry.id.Compare(id)
+		if cmp == 0 && entry.flags&flagDeleted == 0 {
+			target = entry
+		}
+		return cmp < 0
+	})
+	if target == nil {
+		return false
+	}
+
+	lp.Replace(target.offset, formatInt(target.flags|flagDeleted))
+	header := readHeader(lp)
+	if header.count == 1 {
+		// the last live entry of node, remove the node
+		s.rax.Remove(key)
+	} else {
+		setCounts(lp, header, header.count-1, header.deleted+1)
+	}
+	s.length--
+	if id.Compare(s.maxDeletedID) > 0 {
+		s.maxDeletedID = id
+	}
+	if id == s.firstID {
+		s.updateFirstID()
+	}
+	return true
+}
+
+// strategies of Trim
+const (
+	TrimByMaxLen = iota
+	TrimByMinID
+)
+
+// TrimArgs is the arguments of Trim
+type TrimArgs struct {
+	Strategy int
+	MaxLen   int64 // entries to keep, for TrimByMaxLen
+	MinID    ID    // entries less than MinID are removed, for TrimByMinID
+	// Approx trims only whole nodes, so the stream may have a few more entries than expected
+	Approx bool
+	// Limit is the max number of entries to remove in the approximate mode, 0 means unlimited
+	Limit int64
+}
+
+// Trim removes entries from the head of the stream, returns the number of removed entries
+func (s *Stream) Trim(args *TrimArgs) int64 {
+	var removed int64
+	for {
+		if args.Strategy == TrimByMaxLen && s.length <= args.MaxLen {
+			break
+		}
+		key, raw, ok := s.rax.First()
+		if !ok {
+			break
+		}
+		lp := raw.(*listpack.ListPack)
+		master := decodeID(key)
+		header := readHeader(lp)
+		entries := header.count
+		// check if we exceeded the amount of work we could do
+		if args.Limit > 0 && removed+entries > args.Limit {
+			break
+		}
+
+		// check if we can remove the whole node
+		var removeNode bool
+		if args.Strategy == TrimByMaxLen {
+			removeNode = s.length-entries >= args.MaxLen
+		} else {
+			var lastID ID
+			forEachInNode(master, lp, func(entry *nodeEntry) bool {
+				lastID = entry.id
+				return true
+			})
+			removeNode = lastID.Compare(args.MinID) < 0
+		}
+		if removeNode {
+			s.rax.Remove(key)
+			s.length -= entries
+			removed += entries
+			continue
+		}
+		// if we cannot remove a whole node, and approx is true, stop here
+		if args.Approx {
+			break
+		}
+
+		// mark entries of the first node as deleted until the stream is trimmed enough
+		var targets []*nodeEntry
+		remaining := s.length
+		forEachInNode(master, lp, func(entry *nodeEntry) bool {
+			if entry.flags&flagDeleted > 0 {
+				return true
+			}
+			if args.Strategy == TrimByMaxLen && remaining <= args.MaxLen ||
+				args.Strategy == TrimByMinID && entry.id.Compare(args.MinID) >= 0 {
+				return false
+			}
+			targets = append(targets, entry)
+			remaining--
+			return true
+		})
+		// replace from back to front, so that offsets of the remaining targets are still valid
+		for i := len(targets) - 1; i >= 0; i-- {
+			lp.Replace(targets[i].offset, formatInt(targets[i].flags|flagDeleted))
+		}
+		header = readHeader(lp)
+		deleted := int64(len(targets))
+		setCounts(lp, header, header.count-deleted, header.deleted+deleted)
+		s.length -= deleted
+		removed += deleted
+		// there was enough to delete in the current node, no need to go to the next node
+		break
+	}
+	s.updateFirstID()
+	return removed
+}
+
+/* ---- consumer group counters ---- */
+
+// InvalidEntriesRead means the entries read counter of a consumer group is unknown
+const InvalidEntriesRead = -1
+
+// EstimateDistance returns the logical number of entries added up to id (the "entries read"
+// counter of a consumer group whose last ID is id), or InvalidEntriesRead if it can't be known
+func (s *Stream) EstimateDistance(id ID) int64 {
+	// the counter of any ID in an empty, never-before-used stream is 0
+	if s.entriesAdded == 0 {
+		return 0
+	}
+	// in an empty stream, an ID less than or equal to the last ID has read everything
+	if s.length == 0 && id.Compare(s.lastID) <= 0 {
+		return s.entriesAdded
+	}
+	switch cmp := id.Compare(s.lastID); {
+	case cmp == 0:
+		return s.entriesAdded
+	case cmp > 0:
+		// the counter of a future ID is unknown
+		return InvalidEntriesRead
+	}
+	if s.maxDeletedID.IsZero() || s.maxDeletedID.Compare(s.firstID) < 0 {
+		// there's definitely no fragmentation ahead
+		switch cmp := id.Compare(s.firstID); {
+		case cmp < 0:
+			return s.entriesAdded - s.length
+		case cmp == 0:
+			return s.entriesAdded - s.length + 1
+		}
+	}
+	// the ID is either before an XDEL that fragments the stream or an arbitrary ID
+	return InvalidEntriesRead
+}
+
+// RangeHasTombstones returns whether entries in [start, +inf) may have been deleted by XDEL
+func (s *Stream) RangeHasTombstones(start ID) bool {
+	if s.length == 0 || s.maxDeletedID.IsZero() {
+		return false
+	}
+	return start.Compare(s.maxDeletedID) <= 0
+}
+
+// Clone returns a deep copy of s including its consumer groups
+func (s *Stream) Clone() *Stream {
+	clone := *s
+	clone.rax = radix.New()
+	s.rax.Ascend(nil, func(key []byte, value any) bool {
+		clone.rax.Insert(key, value.(*listpack.ListPack).Clone())
+		return true
+	})
+	clone.groups = cloneGroups(s.groups)
+	return &clone
+}
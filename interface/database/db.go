@@ -0,0 +1,33 @@
+package database
+
+import (
+	"github.com/tonge3199/redis_go/interface/redis"
+)
+
+// CmdLine is alias for [][]byte, represents a command line
+type CmdLine = [][]byte
+
+// DB is the interface for redis style storage engine
+type DB interface {
+	// Exec executes a command line and returns its reply
+	Exec(client redis.Connection, cmdLine [][]byte) redis.Reply
+	// AfterClientConnect registers a new client, so that commands like CLIENT TRACKING REDIRECT can find it by id
+	AfterClientConnect(c redis.Connection)
+	// AfterClientClose cleans up the states (subscriptions, blocked commands ...) of a closed client
+	AfterClientClose(c redis.Connection)
+	Close()
+}
+
+// DataEntity stores data bound to a key, including a string, list, hash, set and so on
+type DataEntity struct {
+	Data interface{}
+}
+
+// BlockingReply is returned by blocking commands like BLPOP when there is nothing to serve yet.
+// The caller should wait for the real reply without holding any lock
+type BlockingReply interface {
+	redis.Reply
+	// Wait blocks until the command is served, timed out, unblocked by CLIENT UNBLOCK or cancelled,
+	// closing cancel means the client is gone
+	Wait(cancel <-chan struct{}) redis.Reply
+}
@@ -10,6 +10,9 @@ type Connection interface {
 	//
 	// Write 向连接写入数据，返回写入的字节数和可能的错误
 	//
+	// Write 不会阻塞在慢客户端上：数据先进入输出缓冲区，调用返回后不能再修改 data。
+	// 输出缓冲区超过客户端类别的限制时连接会被关闭
+	//
 	// 参数: data []byte - 要写入的字节数据
 	//
 	// 返回: int - 写入的字节数, error - 可能的错误
@@ -45,6 +48,18 @@ type Connection interface {
 	// 返回: string - 当前设置的密码
 	GetPassword() string
 
+	// SetUser sets the ACL user the connection authenticated as
+	//
+	// SetUser 设置连接认证的 ACL 用户名，空字符串表示未认证
+	SetUser(name string)
+
+	// GetUser returns the ACL user the connection authenticated as
+	//
+	// GetUser 返回连接认证的 ACL 用户名
+	//
+	// 返回: string - 用户名，未认证时为空字符串
+	GetUser() string
+
 	// Pub/Sub methods / 发布订阅相关方法
 	//
 	// Client should keep its subscribing channels
@@ -79,6 +94,62 @@ type Connection interface {
 	// 返回: []string - 包含所有订阅频道名称的切片
 	GetChannels() []string
 
+	// PSubscribe adds a glob-style pattern to the pattern subscription list, which is kept apart from channels
+	//
+	// PSubscribe 将模式添加到模式订阅列表，模式订阅与频道订阅分开记录
+	//
+	// 参数: pattern string - 要订阅的模式，例如 "orders.*.created"
+	PSubscribe(pattern string)
+
+	// PUnSubscribe removes a pattern from the pattern subscription list
+	//
+	// PUnSubscribe 从模式订阅列表中移除模式
+	//
+	// 参数: pattern string - 要取消订阅的模式
+	PUnSubscribe(pattern string)
+
+	// PSubsCount returns the number of subscribed patterns
+	//
+	// PSubsCount 返回已订阅模式的数量
+	//
+	// 返回: int - 订阅模式的总数
+	PSubsCount() int
+
+	// GetPatterns returns all subscribed patterns
+	//
+	// GetPatterns 返回所有已订阅的模式
+	//
+	// 返回: []string - 包含所有订阅模式的切片
+	GetPatterns() []string
+
+	// SSubscribe adds a shard channel to the shard subscription list
+	//
+	// SSubscribe 将分片频道添加到分片订阅列表，分片频道按 hash slot 归属节点
+	//
+	// 参数: channel string - 要订阅的分片频道
+	SSubscribe(channel string)
+
+	// SUnSubscribe removes a shard channel from the shard subscription list
+	//
+	// SUnSubscribe 从分片订阅列表中移除分片频道
+	//
+	// 参数: channel string - 要取消订阅的分片频道
+	SUnSubscribe(channel string)
+
+	// SSubsCount returns the number of subscribed shard channels
+	//
+	// SSubsCount 返回已订阅分片频道的数量
+	//
+	// 返回: int - 订阅分片频道的总数
+	SSubsCount() int
+
+	// GetShardChannels returns all subscribed shard channels
+	//
+	// GetShardChannels 返回所有已订阅的分片频道
+	//
+	// 返回: []string - 包含所有订阅分片频道的切片
+	GetShardChannels() []string
+
 	// Transaction methods / 事务相关方法
 
 	// InMultiState checks if the connection is in MULTI transaction state
@@ -135,6 +206,20 @@ type Connection interface {
 	// 返回: []error - 错误列表
 	GetTxErrors() []error
 
+	// SetTxRollback sets whether transactions of the connection roll back on runtime errors
+	//
+	// SetTxRollback 设置该连接的事务在执行出错时是否回滚
+	//
+	// 参数: enabled bool - true 开启回滚模式，false 关闭回滚模式
+	SetTxRollback(bool)
+
+	// IsTxRollback checks if transactions of the connection roll back on runtime errors
+	//
+	// IsTxRollback 检查该连接的事务是否处于回滚模式
+	//
+	// 返回: bool - true 表示执行出错时回滚整个事务
+	IsTxRollback() bool
+
 	// Database selection methods / 数据库选择相关方法
 
 	// GetDBIndex returns the current database index
@@ -177,10 +262,36 @@ type Connection interface {
 	// 返回: bool - true 表示是主节点，false 表示不是
 	IsMaster() bool
 
+	// ID returns the unique id of the connection, which is used by commands like CLIENT UNBLOCK
+	//
+	// ID 返回连接的唯一编号，CLIENT UNBLOCK 等命令通过编号定位客户端
+	//
+	// 返回: int64 - 从 1 开始递增的连接编号
+	ID() int64
+
 	// Name returns the connection name/identifier
 	//
 	// Name 返回连接的名称或标识符
 	//
 	// 返回: string - 连接的唯一标识符
 	Name() string
+
+	// SetName sets the connection name, e.g. by HELLO SETNAME
+	//
+	// SetName 设置连接名称
+	SetName(name string)
+
+	// Protocol methods / 协议版本相关方法
+
+	// SetProtocolVersion switches the protocol of replies, 2 (default) or 3, by HELLO
+	//
+	// SetProtocolVersion 通过 HELLO 切换协议版本，RESP3 客户端可以接收 push 类型的消息 (如缓存失效通知、订阅的消息)
+	SetProtocolVersion(version int)
+
+	// GetProtocolVersion returns the protocol version of the connection, it may be called by other goroutines
+	//
+	// GetProtocolVersion 返回连接的协议版本，可以被其他 goroutine 调用
+	//
+	// 返回: int - 2 或 3
+	GetProtocolVersion() int
 }
@@ -0,0 +1,372 @@
+// Package geohash is a port of geohash.c and geohash_helper.c of redis.
+//
+// A position is encoded into a 52 bits integer by interleaving 26 bits of latitude (even bits)
+// and 26 bits of longitude (odd bits), which is stored as the score of a sorted set member.
+// Positions close to each other share the same prefix, so a search area can be covered by
+// the score ranges of the geohash box of the center and its 8 neighbors.
+package geohash
+
+import "math"
+
+// limits of the coordinates which can be indexed, the same as EPSG:900913 / EPSG:3785 / OSGEO:41001
+const (
+	LongMin = -180.0
+	LongMax = 180.0
+	LatMin  = -85.05112878
+	LatMax  = 85.05112878
+
+	// Step is the number of bits of each coordinate
+	Step = 26
+
+	earthRadius = 6372797.560856 // in meters
+	mercatorMax = 20037726.37
+)
+
+// Range is the range of a coordinate
+type Range struct {
+	Min float64
+	Max float64
+}
+
+var (
+	longRange = Range{Min: LongMin, Max: LongMax}
+	latRange  = Range{Min: LatMin, Max: LatMax}
+)
+
+// Bits is an encoded position with step bits of each coordinate
+type Bits struct {
+	Bits uint64
+	Step uint8
+}
+
+func (h Bits) isZero() bool {
+	return h.Bits == 0 && h.Step == 0
+}
+
+// Area is the box of a geohash
+type Area struct {
+	Hash      Bits
+	Longitude Range
+	Latitude  Range
+}
+
+// spread puts the bits of v at the even positions of the result
+func spread(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000FFFF0000FFFF
+	x = (x | x<<8) & 0x00FF00FF00FF00FF
+	x = (x | x<<4) & 0x0F0F0F0F0F0F0F0F
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+// squash is the reverse of spread, it collects the even bits of v
+func squash(v uint64) uint32 {
+	x := v & 0x5555555555555555
+	x = (x | x>>1) & 0x3333333333333333
+	x = (x | x>>2) & 0x0F0F0F0F0F0F0F0F
+	x = (x | x>>4) & 0x00FF00FF00FF00FF
+	x = (x | x>>8) & 0x0000FFFF0000FFFF
+	x = (x | x>>16) & 0x00000000FFFFFFFF
+	return uint32(x)
+}
+
+// encode encodes the position with the given coordinate ranges, ok is false if the position can't be indexed
+func encode(longRange Range, latRange Range, longitude float64, latitude float64, step uint8) (Bits, bool) {
+	if longitude > LongMax || longitude < LongMin || latitude > LatMax || latitude < LatMin {
+		return Bits{}, false
+	}
+	if latitude < latRange.Min || latitude > latRange.Max || longitude < longRange.Min || longitude > longRange.Max {
+		return Bits{}, false
+	}
+	latOffset := (latitude - latRange.Min) / (latRange.Max - latRange.Min)
+	longOffset := (longitude - longRange.Min) / (longRange.Max - longRange.Min)
+	// convert to fixed point based on the step size
+	latOffset *= float64(uint64(1) << step)
+	longOffset *= float64(uint64(1) << step)
+	return Bits{
+		Bits: spread(uint32(latOffset)) | spread(uint32(longOffset))<<1,
+		Step: step,
+	}, true
+}
+
+// Encode encodes the position into a 52 bits integer, ok is false if the position can't be indexed
+func Encode(longitude float64, latitude float64) (uint64, bool) {
+	hash, ok := encode(longRange, latRange, longitude, latitude, Step)
+	return hash.Bits, ok
+}
+
+func decode(longRange Range, latRange Range, hash Bits) Area {
+	lat := squash(hash.Bits)
+	long := squash(hash.Bits >> 1)
+	latScale := latRange.Max - latRange.Min
+	longScale := longRange.Max - longRange.Min
+	cells := float64(uint64(1) << hash.Step)
+	return Area{
+		Hash: hash,
+		Latitude: Range{
+			Min: latRange.Min + float64(lat)/cells*latScale,
+			Max: latRange.Min + float64(lat+1)/cells*latScale,
+		},
+		Longitude: Range{
+			Min: longRange.Min + float64(long)/cells*longScale,
+			Max: longRange.Min + float64(long+1)/cells*longScale,
+		},
+	}
+}
+
+// Decode returns the center of the box of the 52 bits geohash
+func Decode(bits uint64) (longitude float64, latitude float64) {
+	area := decode(longRange, latRange, Bits{Bits: bits, Step: Step})
+	longitude = (area.Longitude.Min + area.Longitude.Max) / 2
+	longitude = math.Max(LongMin, math.Min(LongMax, longitude))
+	latitude = (area.Latitude.Min + area.Latitude.Max) / 2
+	latitude = math.Max(LatMin, math.Min(LatMax, latitude))
+	return longitude, latitude
+}
+
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// ToString returns the standard 11 characters geohash string of the position,
+// which uses the latitude range [-90, 90] instead of the range used by scores
+func ToString(longitude float64, latitude float64) string {
+	hash, _ := encode(Range{Min: -180, Max: 180}, Range{Min: -90, Max: 90}, longitude, latitude, Step)
+	buf := make([]byte, 11)
+	for i := range buf {
+		idx := 0
+		if i < 10 {
+			idx = int(hash.Bits>>(52-(i+1)*5)) & 0x1f
+		}
+		// there are only 52 bits, the last character is always '0' for compatibility
+		buf[i] = base32[idx]
+	}
+	return string(buf)
+}
+
+/* ---- Distance ---- */
+
+func degRad(ang float64) float64 {
+	return ang * (math.Pi / 180.0)
+}
+
+func radDeg(ang float64) float64 {
+	return ang / (math.Pi / 180.0)
+}
+
+// latDistance returns the distance between two latitudes in meters
+func latDistance(lat1 float64, lat2 float64) float64 {
+	return earthRadius * math.Abs(degRad(lat2)-degRad(lat1))
+}
+
+// Distance returns the distance between two positions in meters, using the haversine formula
+func Distance(long1 float64, lat1 float64, long2 float64, lat2 float64) float64 {
+	long1r := degRad(long1)
+	long2r := degRad(long2)
+	v := math.Sin((long2r - long1r) / 2)
+	// if v == 0 we can avoid doing expensive math when longitudes are practically the same
+	if v == 0 {
+		return latDistance(lat1, lat2)
+	}
+	lat1r := degRad(lat1)
+	lat2r := degRad(lat2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2.0 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+/* ---- Search ---- */
+
+// Shape is the area of GEOSEARCH, a circle if Radius is set, otherwise a box of Width and Height. Sizes are in meters
+type Shape struct {
+	Longitude float64
+	Latitude  float64
+	Radius    float64
+	Width     float64
+	Height    float64
+	IsBox     bool
+}
+
+// Contains returns whether the position is within the shape and its distance to the center in meters
+func (shape *Shape) Contains(longitude float64, latitude float64) (float64, bool) {
+	if !shape.IsBox {
+		distance := Distance(shape.Longitude, shape.Latitude, longitude, latitude)
+		return distance, distance <= shape.Radius
+	}
+	// latitude distance is less expensive to compute than longitude distance, so check it first
+	if latDistance(latitude, shape.Latitude) > shape.Height/2 {
+		return 0, false
+	}
+	if Distance(longitude, latitude, shape.Longitude, latitude) > shape.Width/2 {
+		return 0, false
+	}
+	return Distance(shape.Longitude, shape.Latitude, longitude, latitude), true
+}
+
+// boundingBox returns [minLong, minLat, maxLong, maxLat] of the shape
+func (shape *Shape) boundingBox() (float64, float64, float64, float64) {
+	height, width := shape.Radius, shape.Radius
+	if shape.IsBox {
+		height, width = shape.Height/2, shape.Width/2
+	}
+	latDelta := radDeg(height / earthRadius)
+	longDeltaTop := radDeg(width / earthRadius / math.Cos(degRad(shape.Latitude+latDelta)))
+	longDeltaBottom := radDeg(width / earthRadius / math.Cos(degRad(shape.Latitude-latDelta)))
+	// the directions of the northern and southern hemispheres are opposite,
+	// so we choose different points as min/max longitude
+	if shape.Latitude < 0 {
+		return shape.Longitude - longDeltaBottom, shape.Latitude - latDelta, shape.Longitude + longDeltaBottom, shape.Latitude + latDelta
+	}
+	return shape.Longitude - longDeltaTop, shape.Latitude - latDelta, shape.Longitude + longDeltaTop, shape.Latitude + latDelta
+}
+
+// estimateSteps returns the step of geohash boxes which are big enough to cover the radius
+func estimateSteps(rangeMeters float64, latitude float64) uint8 {
+	if rangeMeters == 0 {
+		return Step
+	}
+	step := 1
+	for rangeMeters < mercatorMax {
+		rangeMeters *= 2
+		step++
+	}
+	step -= 2 // make sure range is included in most of the base cases
+	// wider range towards the poles
+	if latitude > 66 || latitude < -66 {
+		step--
+		if latitude > 80 || latitude < -80 {
+			step--
+		}
+	}
+	step = max(step, 1)
+	step = min(step, Step)
+	return uint8(step)
+}
+
+func moveX(hash Bits, d int) Bits {
+	x := hash.Bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.Bits & 0x5555555555555555
+	zz := uint64(0x5555555555555555) >> (64 - uint(hash.Step)*2)
+	if d > 0 {
+		x = x + (zz + 1)
+	} else {
+		x = x | zz
+		x = x - (zz + 1)
+	}
+	x &= 0xaaaaaaaaaaaaaaaa >> (64 - uint(hash.Step)*2)
+	hash.Bits = x | y
+	return hash
+}
+
+func moveY(hash Bits, d int) Bits {
+	x := hash.Bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.Bits & 0x5555555555555555
+	zz := uint64(0xaaaaaaaaaaaaaaaa) >> (64 - uint(hash.Step)*2)
+	if d > 0 {
+		y = y + (zz + 1)
+	} else {
+		y = y | zz
+		y = y - (zz + 1)
+	}
+	y &= 0x5555555555555555 >> (64 - uint(hash.Step)*2)
+	hash.Bits = x | y
+	return hash
+}
+
+// neighbors of a geohash box, in the order of redis: center, N, S, E, W, NE, NW, SE, SW
+const (
+	center = iota
+	north
+	south
+	east
+	west
+	northEast
+	northWest
+	southEast
+	southWest
+)
+
+func neighbors(hash Bits) [9]Bits {
+	return [9]Bits{
+		center:    hash,
+		north:     moveY(hash, 1),
+		south:     moveY(hash, -1),
+		east:      moveX(hash, 1),
+		west:      moveX(hash, -1),
+		northEast: moveY(moveX(hash, 1), 1),
+		northWest: moveY(moveX(hash, -1), 1),
+		southEast: moveY(moveX(hash, 1), -1),
+		southWest: moveY(moveX(hash, -1), -1),
+	}
+}
+
+// ScoreRange is a range [Min, Max) of sorted set scores covered by a geohash box
+type ScoreRange struct {
+	Min uint64
+	Max uint64
+}
+
+// SearchRanges returns the score ranges of the geohash boxes covering the shape,
+// in the order redis scans them: the box of the center first, then its neighbors.
+// Boxes out of the bounding box of the shape and duplicated adjacent boxes are skipped
+func SearchRanges(shape *Shape) []ScoreRange {
+	minLong, minLat, maxLong, maxLat := shape.boundingBox()
+	radius := shape.Radius
+	if shape.IsBox {
+		// the distance from the center to the corner
+		radius = math.Sqrt((shape.Width/2)*(shape.Width/2) + (shape.Height/2)*(shape.Height/2))
+	}
+	steps := estimateSteps(radius, shape.Latitude)
+
+	hash, _ := encode(longRange, latRange, shape.Longitude, shape.Latitude, steps)
+	boxes := neighbors(hash)
+	area := decode(longRange, latRange, hash)
+
+	// the estimated step may be not small enough when the search area is near an edge of the box,
+	// since one of the north / south / west / east boxes is too near to cover everything
+	decreaseStep := decode(longRange, latRange, boxes[north]).Latitude.Max < maxLat ||
+		decode(longRange, latRange, boxes[south]).Latitude.Min > minLat ||
+		decode(longRange, latRange, boxes[east]).Longitude.Max < maxLong ||
+		decode(longRange, latRange, boxes[west]).Longitude.Min > minLong
+	if steps > 1 && decreaseStep {
+		steps--
+		hash, _ = encode(longRange, latRange, shape.Longitude, shape.Latitude, steps)
+		boxes = neighbors(hash)
+		area = decode(longRange, latRange, hash)
+	}
+
+	// exclude the boxes that are useless
+	if steps >= 2 {
+		if area.Latitude.Min < minLat {
+			boxes[south], boxes[southWest], boxes[southEast] = Bits{}, Bits{}, Bits{}
+		}
+		if area.Latitude.Max > maxLat {
+			boxes[north], boxes[northEast], boxes[northWest] = Bits{}, Bits{}, Bits{}
+		}
+		if area.Longitude.Min < minLong {
+			boxes[west], boxes[southWest], boxes[northWest] = Bits{}, Bits{}, Bits{}
+		}
+		if area.Longitude.Max > maxLong {
+			boxes[east], boxes[southEast], boxes[northEast] = Bits{}, Bits{}, Bits{}
+		}
+	}
+
+	var ranges []ScoreRange
+	last := -1
+	for i, box := range boxes {
+		if box.isZero() {
+			continue
+		}
+		// when a huge radius is used, adjacent boxes can be the same, leading to duplicated elements
+		if last >= 0 && box == boxes[last] {
+			continue
+		}
+		shift := 52 - uint(box.Step)*2
+		ranges = append(ranges, ScoreRange{
+			Min: box.Bits << shift,
+			Max: (box.Bits + 1) << shift,
+		})
+		last = i
+	}
+	return ranges
+}
@@ -0,0 +1,159 @@
+package geohash
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// examples of the redis documentation
+var (
+	palermo = [2]float64{13.361389, 38.115556}
+	catania = [2]float64{15.087269, 37.502669}
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		position [2]float64
+		score    uint64
+		str      string
+	}{
+		{palermo, 3479099956230698, "sqc8b49rny0"},
+		{catania, 3479447370796909, "sqdtr74hyu0"},
+	}
+	for _, tt := range tests {
+		score, ok := Encode(tt.position[0], tt.position[1])
+		if !ok || score != tt.score {
+			t.Errorf("Encode(%v) is %d, expected %d", tt.position, score, tt.score)
+		}
+		if str := ToString(tt.position[0], tt.position[1]); str != tt.str {
+			t.Errorf("ToString(%v) is %s, expected %s", tt.position, str, tt.str)
+		}
+	}
+	long, lat := Decode(3479099956230698)
+	if math.Abs(long-13.36138933897018433) > 1e-12 || math.Abs(lat-38.11555639549629859) > 1e-12 {
+		t.Errorf("Decode returns %v, %v", long, lat)
+	}
+	if _, ok := Encode(0, 86); ok {
+		t.Error("a latitude out of the mercator range can't be indexed")
+	}
+	if _, ok := Encode(181, 0); ok {
+		t.Error("a longitude out of range can't be indexed")
+	}
+}
+
+func TestSpreadAndSquash(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		v := r.Uint32()
+		spread := spread(v)
+		if spread&0xaaaaaaaaaaaaaaaa != 0 || squash(spread) != v {
+			t.Fatalf("spread(%x) is %x", v, spread)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		long := LongMin + r.Float64()*(LongMax-LongMin)
+		lat := LatMin + r.Float64()*(LatMax-LatMin)
+		score, ok := Encode(long, lat)
+		if !ok {
+			t.Fatalf("Encode(%v, %v) fails", long, lat)
+		}
+		decodedLong, decodedLat := Decode(score)
+		// a box of step 26 is less than 1 meter
+		if d := Distance(long, lat, decodedLong, decodedLat); d > 1 {
+			t.Fatalf("%v, %v is decoded %v meters away", long, lat, d)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// GEODIST computes the distance of the decoded positions
+	long1, lat1 := Decode(mustEncode(t, palermo))
+	long2, lat2 := Decode(mustEncode(t, catania))
+	if d := Distance(long1, lat1, long2, lat2); math.Abs(d-166274.1516) > 0.0001 {
+		t.Errorf("the distance of Palermo and Catania is %v, expected 166274.1516", d)
+	}
+	if d := Distance(1, 10, 1, 11); math.Abs(d-latDistance(10, 11)) > 1e-6 {
+		t.Errorf("the distance along a meridian is %v", d)
+	}
+}
+
+func mustEncode(t *testing.T, position [2]float64) uint64 {
+	t.Helper()
+	score, ok := Encode(position[0], position[1])
+	if !ok {
+		t.Fatalf("Encode(%v) fails", position)
+	}
+	return score
+}
+
+// covered checks whether the score is within one of ranges
+func covered(ranges []ScoreRange, score uint64) bool {
+	for _, r := range ranges {
+		if score >= r.Min && score < r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSearchRanges(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		shape := &Shape{
+			Longitude: -170 + r.Float64()*340,
+			Latitude:  -80 + r.Float64()*160,
+			Radius:    math.Pow(10, 1+r.Float64()*5),
+		}
+		if i%2 == 1 {
+			shape.IsBox = true
+			shape.Width = shape.Radius * (0.5 + r.Float64())
+			shape.Height = shape.Radius * (0.5 + r.Float64())
+		}
+		ranges := SearchRanges(shape)
+		if len(ranges) == 0 || len(ranges) > 9 {
+			t.Fatalf("SearchRanges(%+v) returns %d ranges", shape, len(ranges))
+		}
+		// every position within the shape should be in the ranges
+		for j := 0; j < 100; j++ {
+			long := shape.Longitude + (r.Float64()*2-1)*shape.Radius/earthRadius*180/math.Pi*3
+			lat := shape.Latitude + (r.Float64()*2-1)*shape.Radius/earthRadius*180/math.Pi*3
+			score, ok := Encode(long, lat)
+			if !ok {
+				continue
+			}
+			if _, in := shape.Contains(Decode(score)); in && !covered(ranges, score) {
+				t.Fatalf("%v, %v in %+v is not covered by %v", long, lat, shape, ranges)
+			}
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	circle := &Shape{Longitude: palermo[0], Latitude: palermo[1], Radius: 200000}
+	if d, ok := circle.Contains(catania[0], catania[1]); !ok || math.Abs(d-166274) > 1 {
+		t.Errorf("Catania should be within 200 km of Palermo, distance %v", d)
+	}
+	circle.Radius = 100000
+	if _, ok := circle.Contains(catania[0], catania[1]); ok {
+		t.Error("Catania should not be within 100 km of Palermo")
+	}
+	// Catania is about 150 km east and 68 km south of Palermo
+	box := &Shape{Longitude: palermo[0], Latitude: palermo[1], Width: 400000, Height: 150000, IsBox: true}
+	if _, ok := box.Contains(catania[0], catania[1]); !ok {
+		t.Error("Catania should be within the box")
+	}
+	box.Height = 100000
+	if _, ok := box.Contains(catania[0], catania[1]); ok {
+		t.Error("Catania should not be within a box lower than 136 km")
+	}
+	box.Height = 150000
+	box.Width = 250000
+	if _, ok := box.Contains(catania[0], catania[1]); ok {
+		t.Error("Catania should not be within a box narrower than 300 km")
+	}
+}
@@ -0,0 +1,53 @@
+// Package hashslot maps keys (and shard channels) to the 16384 hash slots of redis cluster
+package hashslot
+
+// Count is the number of hash slots
+const Count = 16384
+
+// crc16Table is the table of CRC16/XMODEM (polynomial 0x1021), which is used by redis cluster
+var crc16Table [256]uint16
+
+func init() {
+	for i := range crc16Table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		crc16Table[i] = crc
+	}
+}
+
+func crc16(s string) uint16 {
+	crc := uint16(0)
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+// Of returns the hash slot of key, the same as redis cluster:
+// if key contains a non-empty hash tag like "{user1000}.following", only the tag "user1000" is hashed,
+// so that keys with the same tag are in the same slot
+//
+// Example: Of("foo") -> 12182, Of("{foo}.bar") -> 12182
+func Of(key string) int {
+	for i := 0; i < len(key); i++ {
+		if key[i] != '{' {
+			continue
+		}
+		for j := i + 1; j < len(key); j++ {
+			if key[j] == '}' {
+				if j > i+1 {
+					key = key[i+1 : j]
+				}
+				return int(crc16(key) % Count)
+			}
+		}
+		break
+	}
+	return int(crc16(key) % Count)
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FormatDouble formats a float the same as the double replies of redis (ZSCORE, ZINCRBY ...):
+// integers in a safe range are printed as integers, others in the shortest representation
+// that round-trips, like d2string and fpconv_dtoa of redis:
+//
+//	FormatDouble(1.5)     -> "1.5"
+//	FormatDouble(1e8)     -> "100000000"
+//	FormatDouble(1e20)    -> "1e+20"
+//	FormatDouble(0.0001)  -> "0.0001"
+//	FormatDouble(1.5e-7)  -> "1.5e-7"
+//	FormatDouble(math.Inf(1)) -> "inf"
+func FormatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	case f == 0:
+		if math.Signbit(f) {
+			return "-0"
+		}
+		return "0"
+	case f == math.Trunc(f) && f >= -math.MaxInt64/2 && f <= math.MaxInt64/2:
+		return strconv.FormatInt(int64(f), 10)
+	}
+
+	// shortest digits d.ddd and exponent e, so that f = d.ddd * 10^e
+	sci := strconv.FormatFloat(f, 'e', -1, 64)
+	neg := sci[0] == '-'
+	if neg {
+		sci = sci[1:]
+	}
+	mantissa, exp10, _ := strings.Cut(sci, "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	e, _ := strconv.Atoi(exp10)
+	ndigits := len(digits)
+	k := e - ndigits + 1 // f = digits * 10^k
+	exp := e
+	if exp < 0 {
+		exp = -exp
+	}
+
+	var sb strings.Builder
+	if neg {
+		sb.WriteByte('-')
+	}
+	switch {
+	case k >= 0 && exp < ndigits+7:
+		// plain integer
+		sb.WriteString(digits)
+		sb.WriteString(strings.Repeat("0", k))
+	case k < 0 && (k > -7 || exp < 4):
+		// decimal without scientific notation
+		offset := ndigits + k
+		if offset <= 0 {
+			sb.WriteString("0.")
+			sb.WriteString(strings.Repeat("0", -offset))
+			sb.WriteString(digits)
+		} else {
+			sb.WriteString(digits[:offset])
+			sb.WriteByte('.')
+			sb.WriteString(digits[offset:])
+		}
+	default:
+		sb.WriteByte(digits[0])
+		if ndigits > 1 {
+			sb.WriteByte('.')
+			sb.WriteString(digits[1:])
+		}
+		sb.WriteByte('e')
+		if e < 0 {
+			sb.WriteByte('-')
+		} else {
+			sb.WriteByte('+')
+		}
+		sb.WriteString(strconv.Itoa(exp))
+	}
+	return sb.String()
+}
+
+// FormatHumanFloat formats a float with 17 decimal places and trailing zeros removed,
+// the same as the human readable long double replies of redis (GEOPOS, INCRBYFLOAT ...):
+//
+//	FormatHumanFloat(13.361389338970184) -> "13.36138933897018433"
+//	FormatHumanFloat(1.5)                -> "1.5"
+//	FormatHumanFloat(-0.0)               -> "0"
+func FormatHumanFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 17, 64)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
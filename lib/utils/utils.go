@@ -0,0 +1,79 @@
+// Package utils provides helpers for building and comparing command lines
+package utils
+
+// ToCmdLine convert strings to [][]byte
+//
+// Example: ToCmdLine("lpush", "list", "a") -> [][]byte{"lpush", "list", "a"}
+func ToCmdLine(cmd ...string) [][]byte {
+	args := make([][]byte, len(cmd))
+	for i, s := range cmd {
+		args[i] = []byte(s)
+	}
+	return args
+}
+
+// ToCmdLine2 convert commandName and string-type argument to [][]byte
+func ToCmdLine2(commandName string, args ...string) [][]byte {
+	result := make([][]byte, len(args)+1)
+	result[0] = []byte(commandName)
+	for i, s := range args {
+		result[i+1] = []byte(s)
+	}
+	return result
+}
+
+// ToCmdLine3 convert commandName and []byte-type argument to CmdLine
+func ToCmdLine3(commandName string, args ...[]byte) [][]byte {
+	result := make([][]byte, len(args)+1)
+	result[0] = []byte(commandName)
+	for i, s := range args {
+		result[i+1] = s
+	}
+	return result
+}
+
+// BytesEquals check whether the given bytes is equal
+func BytesEquals(a []byte, b []byte) bool {
+	if (a == nil && b != nil) || (a != nil && b == nil) {
+		return false
+	}
+	if len(a) != len(b) {
+		return false
+	}
+	size := len(a)
+	for i := 0; i < size; i++ {
+		av := a[i]
+		bv := b[i]
+		if av != bv {
+			return false
+		}
+	}
+	return true
+}
+
+// ConvertRange converts redis inclusive index range to go slice index range [start, end)
+//
+// Negative index counts from the tail and out of range index is clamped, the same as LRANGE:
+//
+//	ConvertRange(0, -1, 5)    -> 0, 5
+//	ConvertRange(-100, 1, 5)  -> 0, 2
+//	ConvertRange(3, 100, 5)   -> 3, 5
+//	ConvertRange(4, 2, 5)     -> -1, -1 (empty range)
+func ConvertRange(start int64, end int64, size int64) (int, int) {
+	if start < 0 {
+		start = size + start
+		if start < 0 {
+			start = 0
+		}
+	}
+	if end < 0 {
+		end = size + end
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if start > end || start >= size || end < 0 {
+		return -1, -1
+	}
+	return int(start), int(end + 1)
+}
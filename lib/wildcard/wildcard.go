@@ -0,0 +1,137 @@
+// Package wildcard implements the glob-style pattern matching used by KEYS, SCAN MATCH and PSUBSCRIBE
+package wildcard
+
+// Match reports whether str matches the glob-style pattern, it behaves the same as stringmatchlen of redis:
+//
+//	h?llo     matches hello, hallo and hxllo
+//	h*llo     matches hllo and heeeello
+//	h[ae]llo  matches hello and hallo, but not hillo
+//	h[^e]llo  matches hallo, hbllo, ... but not hello
+//	h[a-b]llo matches hallo and hbllo
+//	h\*llo    matches h*llo only, use \ to escape special characters
+func Match(pattern string, str string) bool {
+	skipLongerMatches := false
+	return match([]byte(pattern), []byte(str), false, &skipLongerMatches, 0)
+}
+
+// MatchNoCase is the case-insensitive version of Match
+func MatchNoCase(pattern string, str string) bool {
+	skipLongerMatches := false
+	return match([]byte(pattern), []byte(str), true, &skipLongerMatches, 0)
+}
+
+// maxNesting limits the recursion depth of '*', a pattern with too many stars is treated as not matched
+const maxNesting = 1000
+
+func toLower(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// match is a port of stringmatchlen_impl.
+// Once the rest of pattern after a '*' fails to match any suffix of str, matching a shorter suffix
+// is hopeless too, skipLongerMatches records that and cuts the exponential backtracking
+func match(pattern []byte, str []byte, nocase bool, skipLongerMatches *bool, nesting int) bool {
+	if nesting > maxNesting {
+		return false
+	}
+	for len(pattern) > 0 && len(str) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true // trailing * matches everything
+			}
+			for len(str) > 0 {
+				if match(pattern[1:], str, nocase, skipLongerMatches, nesting+1) {
+					return true
+				}
+				if *skipLongerMatches {
+					return false
+				}
+				str = str[1:]
+			}
+			*skipLongerMatches = true
+			return false // no match for the rest of pattern
+		case '?':
+			str = str[1:]
+		case '[':
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+			matched := false
+			for {
+				if len(pattern) == 0 {
+					break
+				}
+				if pattern[0] == '\\' && len(pattern) >= 2 {
+					pattern = pattern[1:]
+					if pattern[0] == str[0] {
+						matched = true
+					}
+				} else if pattern[0] == ']' {
+					break
+				} else if len(pattern) >= 3 && pattern[1] == '-' {
+					start, end := pattern[0], pattern[2]
+					c := str[0]
+					if start > end {
+						start, end = end, start
+					}
+					if nocase {
+						start, end, c = toLower(start), toLower(end), toLower(c)
+					}
+					pattern = pattern[2:]
+					if c >= start && c <= end {
+						matched = true
+					}
+				} else if nocase {
+					if toLower(pattern[0]) == toLower(str[0]) {
+						matched = true
+					}
+				} else if pattern[0] == str[0] {
+					matched = true
+				}
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				// unterminated [, the last char of pattern is treated as the end of class
+				pattern = []byte{']'}
+			}
+			if not {
+				matched = !matched
+			}
+			if !matched {
+				return false
+			}
+			str = str[1:]
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if nocase {
+				if toLower(pattern[0]) != toLower(str[0]) {
+					return false
+				}
+			} else if pattern[0] != str[0] {
+				return false
+			}
+			str = str[1:]
+		}
+		pattern = pattern[1:]
+		if len(str) == 0 {
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			break
+		}
+	}
+	return len(pattern) == 0 && len(str) == 0
+}
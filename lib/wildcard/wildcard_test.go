@@ -0,0 +1,85 @@
+package wildcard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		matched bool
+	}{
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "hllo", true},
+		{"h*llo", "heeeello", true},
+		{"h*llo", "hello!", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hallo", true}, // reversed range
+		{"h[a-b]llo", "hcllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`h[\]]llo`, "h]llo", true},
+		{"*", "anything", true},
+		{"a*", "a", true},
+		{"a**b", "ab", true},
+		{"*b*", "abc", true},
+		{"*b*", "ac", false},
+		{"a?", "a", false},
+		{"", "", true},
+		{"", "a", false},
+		{"abc", "ab", false},
+		{"h[ab", "hb", true}, // unterminated class
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.str); got != tt.matched {
+			t.Errorf("Match(%q, %q) is %v, expected %v", tt.pattern, tt.str, got, tt.matched)
+		}
+	}
+}
+
+func TestMatchNoCase(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		matched bool
+	}{
+		{"HELLO", "hello", true},
+		{"h?LLO", "Hello", true},
+		{"h[A-C]llo", "hbllo", true},
+		{"h[a-c]llo", "HBLLO", true},
+		{"h[E]llo", "hello", true},
+		{"h[^E]llo", "hello", false},
+		{"user:*", "USER:1", true},
+	}
+	for _, tt := range tests {
+		if got := MatchNoCase(tt.pattern, tt.str); got != tt.matched {
+			t.Errorf("MatchNoCase(%q, %q) is %v, expected %v", tt.pattern, tt.str, got, tt.matched)
+		}
+	}
+	if Match("HELLO", "hello") {
+		t.Error("Match should be case sensitive")
+	}
+}
+
+// TestMatchBacktracking makes sure a pattern of many stars doesn't take exponential time
+func TestMatchBacktracking(t *testing.T) {
+	pattern := strings.Repeat("a*", 30) + "b"
+	str := strings.Repeat("a", 100)
+	if Match(pattern, str) {
+		t.Fatal("a string without b should not match")
+	}
+	if !Match(pattern, str+"b") {
+		t.Fatal("a string ending with b should match")
+	}
+	// consecutive stars are merged, so they don't count as nesting
+	if !Match(strings.Repeat("*", maxNesting+10)+"?", "a") {
+		t.Fatal("consecutive stars should be merged")
+	}
+}
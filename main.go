@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/lib/logger"
+	RedisServer "github.com/tonge3199/redis_go/redis/server"
+	"github.com/tonge3199/redis_go/tcp"
+)
+
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && !info.IsDir()
+}
+
+func main() {
+	logger.Setup(&logger.Settings{
+		Path:       "logs",
+		Name:       "redis_go",
+		Ext:        "log",
+		TimeFormat: "2006-01-02",
+	})
+
+	configFilename := os.Getenv("CONFIG")
+	if configFilename == "" {
+		if fileExists("redis.conf") {
+			config.SetupConfig("redis.conf")
+		}
+	} else {
+		config.SetupConfig(configFilename)
+	}
+
+	err := tcp.ListenAndServeWithSignal(&tcp.Config{
+		Address: fmt.Sprintf("%s:%d", config.Properties.Bind, config.Properties.Port),
+	}, RedisServer.MakeHandler())
+	if err != nil {
+		logger.Error(err)
+	}
+}
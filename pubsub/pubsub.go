@@ -0,0 +1,378 @@
+// Package pubsub implements the publish/subscribe messaging of redis
+package pubsub
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/tonge3199/redis_go/datastruct/dict"
+	"github.com/tonge3199/redis_go/datastruct/lock"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/wildcard"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// Hub stores subscribers of all channels.
+//
+// A channel is guarded by its lock in locks, so publishing to different channels runs in parallel.
+// Pattern subscriptions are indexed by patterns, see patternTrie.
+// The connection keeps its own subscribing channels and patterns (see redis.Connection.Subscribe and PSubscribe),
+// which decide whether it is in subscribe mode and what to unsubscribe when it is closed
+type Hub struct {
+	// channel -> map[redis.Connection]struct{}
+	subs  dict.Dict
+	locks *lock.Locks
+
+	patterns   *patternTrie
+	patternsMu sync.RWMutex
+
+	// slot -> shard channel -> subscribers, see shard.go
+	shardSubs map[int]map[string]map[redis.Connection]struct{}
+	shardMu   sync.RWMutex
+}
+
+const lockerSize = 16
+
+// MakeHub creates an empty Hub
+func MakeHub() *Hub {
+	return &Hub{
+		subs:      dict.MakeConcurrent(lockerSize),
+		locks:     lock.Make(lockerSize),
+		patterns:  makePatternTrie(),
+		shardSubs: make(map[int]map[string]map[redis.Connection]struct{}),
+	}
+}
+
+var (
+	subscribeKind    = []byte("subscribe")
+	unsubscribeKind  = []byte("unsubscribe")
+	messageKind      = []byte("message")
+	pSubscribeKind   = []byte("psubscribe")
+	pUnsubscribeKind = []byte("punsubscribe")
+	pMessageKind     = []byte("pmessage")
+)
+
+// InSubscribeMode returns whether c subscribes any channel, pattern or shard channel
+func InSubscribeMode(c redis.Connection) bool {
+	return subscriptions(c) > 0 || c.SSubsCount() > 0
+}
+
+// subscriptions returns the number of channels and patterns subscribed by c, shard channels are counted apart
+func subscriptions(c redis.Connection) int {
+	return c.SubsCount() + c.PSubsCount()
+}
+
+// push is a message sent to subscribers out of band, an array in RESP2 and a push in RESP3
+// (see protocol.PushReply) so that a RESP3 client tells it from replies of its commands.
+// A message is published to many subscribers, each encoding is built once
+type push struct {
+	replies []redis.Reply
+	resp2   []byte
+	resp3   []byte
+}
+
+func makePush(replies ...redis.Reply) *push {
+	return &push{replies: replies}
+}
+
+// bytesFor returns p encoded in the protocol of c
+func (p *push) bytesFor(c redis.Connection) []byte {
+	if c.GetProtocolVersion() >= 3 {
+		if p.resp3 == nil {
+			p.resp3 = protocol.MakePushReply(p.replies).ToBytes()
+		}
+		return p.resp3
+	}
+	if p.resp2 == nil {
+		p.resp2 = protocol.MakeMultiRawReply(p.replies).ToBytes()
+	}
+	return p.resp2
+}
+
+// makeConfirm makes the confirmation of (un)subscribing sent to c: kind, channel (or pattern) and
+// the number of channels and patterns still subscribed. channel is nil when unsubscribing without any subscription
+func makeConfirm(c redis.Connection, kind []byte, channel []byte, count int) []byte {
+	var channelReply redis.Reply
+	switch {
+	case channel != nil:
+		channelReply = protocol.MakeBulkReply(channel)
+	case c.GetProtocolVersion() >= 3:
+		channelReply = protocol.MakeNullReply()
+	default:
+		channelReply = protocol.MakeNullBulkReply()
+	}
+	return makePush(protocol.MakeBulkReply(kind), channelReply, protocol.MakeIntReply(int64(count))).bytesFor(c)
+}
+
+// makeMessage makes the message pushed to subscribers
+func makeMessage(channel string, message []byte) *push {
+	return makePush(
+		protocol.MakeBulkReply(messageKind),
+		protocol.MakeBulkReply([]byte(channel)),
+		protocol.MakeBulkReply(message),
+	)
+}
+
+// makePMessage makes the message pushed to subscribers of pattern
+func makePMessage(pattern string, channel string, message []byte) *push {
+	return makePush(
+		protocol.MakeBulkReply(pMessageKind),
+		protocol.MakeBulkReply([]byte(pattern)),
+		protocol.MakeBulkReply([]byte(channel)),
+		protocol.MakeBulkReply(message),
+	)
+}
+
+// subscribe adds c to subscribers of channel, returns false if c has subscribed it already
+func (hub *Hub) subscribe(c redis.Connection, channel string) bool {
+	hub.locks.Lock(channel)
+	defer hub.locks.Unlock(channel)
+	raw, ok := hub.subs.Get(channel)
+	var subscribers map[redis.Connection]struct{}
+	if ok {
+		subscribers = raw.(map[redis.Connection]struct{})
+	} else {
+		subscribers = make(map[redis.Connection]struct{})
+		hub.subs.Put(channel, subscribers)
+	}
+	if _, ok := subscribers[c]; ok {
+		return false
+	}
+	subscribers[c] = struct{}{}
+	return true
+}
+
+// unsubscribe removes c from subscribers of channel, an empty channel is removed from hub
+func (hub *Hub) unsubscribe(c redis.Connection, channel string) {
+	hub.locks.Lock(channel)
+	defer hub.locks.Unlock(channel)
+	raw, ok := hub.subs.Get(channel)
+	if !ok {
+		return
+	}
+	subscribers := raw.(map[redis.Connection]struct{})
+	delete(subscribers, c)
+	if len(subscribers) == 0 {
+		hub.subs.Remove(channel)
+	}
+}
+
+// Subscribe subscribes channels and sends a confirmation for each of them
+//
+//	SUBSCRIBE channel [channel ...]
+func (hub *Hub) Subscribe(c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("subscribe")
+	}
+	for _, arg := range args {
+		channel := string(arg)
+		if hub.subscribe(c, channel) {
+			c.Subscribe(channel)
+		}
+		_, _ = c.Write(makeConfirm(c, subscribeKind, arg, subscriptions(c)))
+	}
+	return &protocol.NoReply{}
+}
+
+// Unsubscribe unsubscribes the given channels, or all channels if none is given,
+// and sends a confirmation for each of them
+//
+//	UNSUBSCRIBE [channel [channel ...]]
+func (hub *Hub) Unsubscribe(c redis.Connection, args [][]byte) redis.Reply {
+	var channels []string
+	if len(args) > 0 {
+		channels = make([]string, len(args))
+		for i, arg := range args {
+			channels[i] = string(arg)
+		}
+	} else {
+		channels = c.GetChannels()
+	}
+	if len(channels) == 0 {
+		_, _ = c.Write(makeConfirm(c, unsubscribeKind, nil, subscriptions(c)))
+		return &protocol.NoReply{}
+	}
+	for _, channel := range channels {
+		hub.unsubscribe(c, channel)
+		c.UnSubscribe(channel)
+		_, _ = c.Write(makeConfirm(c, unsubscribeKind, []byte(channel), subscriptions(c)))
+	}
+	return &protocol.NoReply{}
+}
+
+// PSubscribe subscribes glob-style patterns and sends a confirmation for each of them
+//
+//	PSUBSCRIBE pattern [pattern ...]
+func (hub *Hub) PSubscribe(c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("psubscribe")
+	}
+	for _, arg := range args {
+		pattern := string(arg)
+		hub.patternsMu.Lock()
+		added := hub.patterns.add(pattern, c)
+		hub.patternsMu.Unlock()
+		if added {
+			c.PSubscribe(pattern)
+		}
+		_, _ = c.Write(makeConfirm(c, pSubscribeKind, arg, subscriptions(c)))
+	}
+	return &protocol.NoReply{}
+}
+
+// PUnsubscribe unsubscribes the given patterns, or all patterns if none is given,
+// and sends a confirmation for each of them
+//
+//	PUNSUBSCRIBE [pattern [pattern ...]]
+func (hub *Hub) PUnsubscribe(c redis.Connection, args [][]byte) redis.Reply {
+	var patterns []string
+	if len(args) > 0 {
+		patterns = make([]string, len(args))
+		for i, arg := range args {
+			patterns[i] = string(arg)
+		}
+	} else {
+		patterns = c.GetPatterns()
+	}
+	if len(patterns) == 0 {
+		_, _ = c.Write(makeConfirm(c, pUnsubscribeKind, nil, subscriptions(c)))
+		return &protocol.NoReply{}
+	}
+	for _, pattern := range patterns {
+		hub.punsubscribe(c, pattern)
+		_, _ = c.Write(makeConfirm(c, pUnsubscribeKind, []byte(pattern), subscriptions(c)))
+	}
+	return &protocol.NoReply{}
+}
+
+func (hub *Hub) punsubscribe(c redis.Connection, pattern string) {
+	hub.patternsMu.Lock()
+	hub.patterns.remove(pattern, c)
+	hub.patternsMu.Unlock()
+	c.PUnSubscribe(pattern)
+}
+
+// UnsubscribeAll removes all subscriptions of a closed connection
+func (hub *Hub) UnsubscribeAll(c redis.Connection) {
+	for _, channel := range c.GetChannels() {
+		hub.unsubscribe(c, channel)
+		c.UnSubscribe(channel)
+	}
+	for _, pattern := range c.GetPatterns() {
+		hub.punsubscribe(c, pattern)
+	}
+	for _, channel := range c.GetShardChannels() {
+		hub.sunsubscribe(c, channel)
+	}
+}
+
+// Publish sends message to all subscribers of channel and patterns matching channel,
+// returns the number of receivers. A client subscribing several matching patterns receives the message several times
+//
+//	PUBLISH channel message
+func (hub *Hub) Publish(args [][]byte) redis.Reply {
+	if len(args) != 2 {
+		return protocol.MakeArgNumErrReply("publish")
+	}
+	return protocol.MakeIntReply(int64(hub.PublishMessage(string(args[0]), args[1])))
+}
+
+// PublishMessage sends message to all subscribers of channel and patterns matching channel,
+// returns the number of receivers. It is used by the server to publish its own messages like keyspace events
+func (hub *Hub) PublishMessage(channel string, message []byte) int {
+	receivers := 0
+	hub.locks.RLock(channel)
+	if raw, ok := hub.subs.Get(channel); ok {
+		subscribers := raw.(map[redis.Connection]struct{})
+		message := makeMessage(channel, message)
+		for c := range subscribers {
+			_, _ = c.Write(message.bytesFor(c))
+		}
+		receivers += len(subscribers)
+	}
+	hub.locks.RUnlock(channel)
+
+	hub.patternsMu.RLock()
+	hub.patterns.forEachMatch(channel, func(pattern string, subscribers map[redis.Connection]struct{}) {
+		message := makePMessage(pattern, channel, message)
+		for c := range subscribers {
+			_, _ = c.Write(message.bytesFor(c))
+		}
+		receivers += len(subscribers)
+	})
+	hub.patternsMu.RUnlock()
+	return receivers
+}
+
+// PubSub dispatches the PUBSUB sub commands
+//
+//	PUBSUB CHANNELS [pattern]
+//	PUBSUB NUMSUB [channel [channel ...]]
+//	PUBSUB NUMPAT
+//	PUBSUB SHARDCHANNELS [pattern]
+//	PUBSUB SHARDNUMSUB [shardchannel [shardchannel ...]]
+func (hub *Hub) PubSub(args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("pubsub")
+	}
+	switch strings.ToUpper(string(args[0])) {
+	case "CHANNELS":
+		if len(args) > 2 {
+			return protocol.MakeArgNumErrReply("pubsub|channels")
+		}
+		pattern := ""
+		if len(args) == 2 {
+			pattern = string(args[1])
+		}
+		return hub.channels(pattern)
+	case "NUMSUB":
+		return hub.numSub(args[1:])
+	case "NUMPAT":
+		if len(args) != 1 {
+			return protocol.MakeArgNumErrReply("pubsub|numpat")
+		}
+		hub.patternsMu.RLock()
+		defer hub.patternsMu.RUnlock()
+		return protocol.MakeIntReply(int64(hub.patterns.size))
+	case "SHARDCHANNELS":
+		if len(args) > 2 {
+			return protocol.MakeArgNumErrReply("pubsub|shardchannels")
+		}
+		pattern := ""
+		if len(args) == 2 {
+			pattern = string(args[1])
+		}
+		return hub.shardChannels(pattern)
+	case "SHARDNUMSUB":
+		return hub.shardNumSub(args[1:])
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try PUBSUB HELP.")
+}
+
+// channels returns active channels (channels with at least one subscriber) matching pattern, "" matches all
+func (hub *Hub) channels(pattern string) redis.Reply {
+	var result [][]byte
+	hub.subs.ForEach(func(channel string, val interface{}) bool {
+		if pattern == "" || wildcard.Match(pattern, channel) {
+			result = append(result, []byte(channel))
+		}
+		return true
+	})
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// numSub returns channels and their numbers of subscribers, flattened
+func (hub *Hub) numSub(args [][]byte) redis.Reply {
+	result := make([]redis.Reply, 0, len(args)*2)
+	for _, arg := range args {
+		channel := string(arg)
+		count := 0
+		hub.locks.RLock(channel)
+		if raw, ok := hub.subs.Get(channel); ok {
+			count = len(raw.(map[redis.Connection]struct{}))
+		}
+		hub.locks.RUnlock(channel)
+		result = append(result, protocol.MakeBulkReply(arg), protocol.MakeIntReply(int64(count)))
+	}
+	return protocol.MakeMultiRawReply(result)
+}
@@ -0,0 +1,175 @@
+package pubsub
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/connection"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// testClient is a connection whose output is read from the other side of a pipe
+type testClient struct {
+	*connection.Connection
+	pipe   net.Conn
+	reader *bufio.Reader
+}
+
+func makeTestClient(t *testing.T) *testClient {
+	t.Helper()
+	server, client := net.Pipe()
+	c := &testClient{
+		Connection: connection.NewConn(server),
+		pipe:       client,
+		reader:     bufio.NewReader(client),
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = c.Close()
+	})
+	return c
+}
+
+// expect checks that the next output of the client is expected
+func (c *testClient) expect(t *testing.T, expected []byte) {
+	t.Helper()
+	_ = c.pipe.SetReadDeadline(time.Now().Add(time.Second))
+	got := make([]byte, len(expected))
+	if n, err := io.ReadFull(c.reader, got); err != nil {
+		t.Fatalf("output is %q, expected %q: %v", got[:n], expected, err)
+	}
+	if string(got) != string(expected) {
+		t.Fatalf("output is %q, expected %q", got, expected)
+	}
+}
+
+// expectNothing checks that nothing is written to the client
+func (c *testClient) expectNothing(t *testing.T) {
+	t.Helper()
+	_ = c.pipe.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if b, err := c.reader.ReadByte(); err == nil {
+		t.Fatalf("unexpected output %q", b)
+	}
+}
+
+func args(values ...string) [][]byte {
+	result := make([][]byte, len(values))
+	for i, value := range values {
+		result[i] = []byte(value)
+	}
+	return result
+}
+
+func assertReply(t *testing.T, got redis.Reply, expected redis.Reply) {
+	t.Helper()
+	if string(got.ToBytes()) != string(expected.ToBytes()) {
+		t.Fatalf("reply is %q, expected %q", got.ToBytes(), expected.ToBytes())
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	hub := MakeHub()
+	c := makeTestClient(t)
+	hub.Subscribe(c, args("a", "b", "a"))
+	c.expect(t, makeConfirm(c, subscribeKind, []byte("a"), 1))
+	c.expect(t, makeConfirm(c, subscribeKind, []byte("b"), 2))
+	c.expect(t, makeConfirm(c, subscribeKind, []byte("a"), 2))
+	if !InSubscribeMode(c) {
+		t.Fatal("a client subscribing channels should be in subscribe mode")
+	}
+
+	assertReply(t, hub.Publish(args("a", "hello")), protocol.MakeIntReply(1))
+	c.expect(t, makeMessage("a", []byte("hello")).bytesFor(c))
+	assertReply(t, hub.Publish(args("c", "hello")), protocol.MakeIntReply(0))
+	c.expectNothing(t)
+
+	// the count of confirmations includes patterns
+	hub.PSubscribe(c, args("a*"))
+	c.expect(t, makeConfirm(c, pSubscribeKind, []byte("a*"), 3))
+	assertReply(t, hub.Publish(args("a", "hi")), protocol.MakeIntReply(2))
+	c.expect(t, makeMessage("a", []byte("hi")).bytesFor(c))
+	c.expect(t, makePMessage("a*", "a", []byte("hi")).bytesFor(c))
+
+	hub.Unsubscribe(c, args("b", "x"))
+	c.expect(t, makeConfirm(c, unsubscribeKind, []byte("b"), 2))
+	c.expect(t, makeConfirm(c, unsubscribeKind, []byte("x"), 2))
+	hub.Unsubscribe(c, nil)
+	c.expect(t, makeConfirm(c, unsubscribeKind, []byte("a"), 1))
+	hub.PUnsubscribe(c, nil)
+	c.expect(t, makeConfirm(c, pUnsubscribeKind, []byte("a*"), 0))
+	if InSubscribeMode(c) {
+		t.Fatal("a client without subscriptions should leave subscribe mode")
+	}
+	// unsubscribing without any subscription confirms a null channel
+	hub.Unsubscribe(c, nil)
+	c.expect(t, makeConfirm(c, unsubscribeKind, nil, 0))
+	hub.PUnsubscribe(c, nil)
+	c.expect(t, makeConfirm(c, pUnsubscribeKind, nil, 0))
+	assertReply(t, hub.Publish(args("a", "hello")), protocol.MakeIntReply(0))
+}
+
+func TestPubSubCommand(t *testing.T) {
+	hub := MakeHub()
+	c1, c2 := makeTestClient(t), makeTestClient(t)
+	hub.Subscribe(c1, args("news"))
+	hub.Subscribe(c2, args("news", "sport"))
+	hub.PSubscribe(c2, args("n*", "s*"))
+
+	assertReply(t, hub.PubSub(args("channels", "n*")), protocol.MakeMultiBulkReply(args("news")))
+	assertReply(t, hub.PubSub(args("numsub", "news", "sport", "none")), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("news")), protocol.MakeIntReply(2),
+		protocol.MakeBulkReply([]byte("sport")), protocol.MakeIntReply(1),
+		protocol.MakeBulkReply([]byte("none")), protocol.MakeIntReply(0),
+	}))
+	assertReply(t, hub.PubSub(args("numpat")), protocol.MakeIntReply(2))
+	if _, ok := hub.PubSub(args("nosuch")).(protocol.ErrorReply); !ok {
+		t.Fatal("an unknown subcommand should fail")
+	}
+
+	// a closed client is removed from all channels and patterns
+	hub.UnsubscribeAll(c2)
+	assertReply(t, hub.PubSub(args("channels")), protocol.MakeMultiBulkReply(args("news")))
+	assertReply(t, hub.PubSub(args("numsub", "news")), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("news")), protocol.MakeIntReply(1),
+	}))
+	assertReply(t, hub.PubSub(args("numpat")), protocol.MakeIntReply(0))
+	if InSubscribeMode(c2) {
+		t.Fatal("UnsubscribeAll should clear subscriptions of the connection")
+	}
+}
+
+func TestResp3Push(t *testing.T) {
+	hub := MakeHub()
+	c, old := makeTestClient(t), makeTestClient(t)
+	c.SetProtocolVersion(3)
+	hub.Subscribe(c, args("a"))
+	c.expect(t, []byte(">3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n"))
+	hub.PSubscribe(c, args("a*"))
+	c.expect(t, []byte(">3\r\n$10\r\npsubscribe\r\n$2\r\na*\r\n:2\r\n"))
+	hub.SSubscribe(c, args("a"))
+	c.expect(t, []byte(">3\r\n$10\r\nssubscribe\r\n$1\r\na\r\n:1\r\n"))
+	hub.Subscribe(old, args("a"))
+	old.expect(t, []byte("*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n"))
+
+	// each subscriber gets the message in its own protocol
+	assertReply(t, hub.Publish(args("a", "hi")), protocol.MakeIntReply(3))
+	c.expect(t, []byte(">3\r\n$7\r\nmessage\r\n$1\r\na\r\n$2\r\nhi\r\n"))
+	c.expect(t, []byte(">4\r\n$8\r\npmessage\r\n$2\r\na*\r\n$1\r\na\r\n$2\r\nhi\r\n"))
+	old.expect(t, []byte("*3\r\n$7\r\nmessage\r\n$1\r\na\r\n$2\r\nhi\r\n"))
+	assertReply(t, hub.SPublish(args("a", "hi")), protocol.MakeIntReply(1))
+	c.expect(t, []byte(">3\r\n$8\r\nsmessage\r\n$1\r\na\r\n$2\r\nhi\r\n"))
+
+	hub.Unsubscribe(c, nil)
+	c.expect(t, []byte(">3\r\n$11\r\nunsubscribe\r\n$1\r\na\r\n:1\r\n"))
+	// the null channel is the null of RESP3
+	hub.Unsubscribe(c, nil)
+	c.expect(t, []byte(">3\r\n$11\r\nunsubscribe\r\n_\r\n:1\r\n"))
+	hub.Unsubscribe(old, nil)
+	hub.Unsubscribe(old, nil)
+	old.expect(t, []byte("*3\r\n$11\r\nunsubscribe\r\n$1\r\na\r\n:0\r\n"))
+	old.expect(t, []byte("*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:0\r\n"))
+}
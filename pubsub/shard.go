@@ -0,0 +1,158 @@
+package pubsub
+
+import (
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/hashslot"
+	"github.com/tonge3199/redis_go/lib/wildcard"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+// Shard channels are bound to the hash slot of their names like keys (see hashslot.Of),
+// in a cluster a message is only propagated within the shard owning the slot instead of the whole cluster.
+//
+// The server runs standalone for now and owns all slots, so there is no ownership check and nothing to propagate.
+// Shard channels are grouped by slot in the hub so that RemoveSlot can drop all of them once a slot migrates away.
+
+var (
+	sSubscribeKind   = []byte("ssubscribe")
+	sUnsubscribeKind = []byte("sunsubscribe")
+	sMessageKind     = []byte("smessage")
+)
+
+// shardSubscribers returns subscribers of the shard channel, must be called with shardMu held
+func (hub *Hub) shardSubscribers(channel string) map[redis.Connection]struct{} {
+	return hub.shardSubs[hashslot.Of(channel)][channel]
+}
+
+// SSubscribe subscribes shard channels and sends a confirmation for each of them,
+// the count of confirmation only includes shard channels
+//
+//	SSUBSCRIBE shardchannel [shardchannel ...]
+func (hub *Hub) SSubscribe(c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("ssubscribe")
+	}
+	for _, arg := range args {
+		channel := string(arg)
+		hub.shardMu.Lock()
+		slot := hashslot.Of(channel)
+		channels := hub.shardSubs[slot]
+		if channels == nil {
+			channels = make(map[string]map[redis.Connection]struct{})
+			hub.shardSubs[slot] = channels
+		}
+		subscribers := channels[channel]
+		if subscribers == nil {
+			subscribers = make(map[redis.Connection]struct{})
+			channels[channel] = subscribers
+		}
+		subscribers[c] = struct{}{}
+		hub.shardMu.Unlock()
+		c.SSubscribe(channel)
+		_, _ = c.Write(makeConfirm(c, sSubscribeKind, arg, c.SSubsCount()))
+	}
+	return &protocol.NoReply{}
+}
+
+// sunsubscribe removes c from subscribers of the shard channel
+func (hub *Hub) sunsubscribe(c redis.Connection, channel string) {
+	hub.shardMu.Lock()
+	slot := hashslot.Of(channel)
+	if subscribers := hub.shardSubs[slot][channel]; subscribers != nil {
+		delete(subscribers, c)
+		if len(subscribers) == 0 {
+			delete(hub.shardSubs[slot], channel)
+			if len(hub.shardSubs[slot]) == 0 {
+				delete(hub.shardSubs, slot)
+			}
+		}
+	}
+	hub.shardMu.Unlock()
+	c.SUnSubscribe(channel)
+}
+
+// SUnsubscribe unsubscribes the given shard channels, or all shard channels if none is given,
+// and sends a confirmation for each of them
+//
+//	SUNSUBSCRIBE [shardchannel [shardchannel ...]]
+func (hub *Hub) SUnsubscribe(c redis.Connection, args [][]byte) redis.Reply {
+	var channels []string
+	if len(args) > 0 {
+		channels = make([]string, len(args))
+		for i, arg := range args {
+			channels[i] = string(arg)
+		}
+	} else {
+		channels = c.GetShardChannels()
+	}
+	if len(channels) == 0 {
+		_, _ = c.Write(makeConfirm(c, sUnsubscribeKind, nil, c.SSubsCount()))
+		return &protocol.NoReply{}
+	}
+	for _, channel := range channels {
+		hub.sunsubscribe(c, channel)
+		_, _ = c.Write(makeConfirm(c, sUnsubscribeKind, []byte(channel), c.SSubsCount()))
+	}
+	return &protocol.NoReply{}
+}
+
+// SPublish sends message to all subscribers of the shard channel, returns the number of receivers.
+// Shard channels never match patterns
+//
+//	SPUBLISH shardchannel message
+func (hub *Hub) SPublish(args [][]byte) redis.Reply {
+	if len(args) != 2 {
+		return protocol.MakeArgNumErrReply("spublish")
+	}
+	channel := string(args[0])
+	hub.shardMu.RLock()
+	defer hub.shardMu.RUnlock()
+	subscribers := hub.shardSubscribers(channel)
+	message := makePush(protocol.MakeBulkReply(sMessageKind), protocol.MakeBulkReply(args[0]), protocol.MakeBulkReply(args[1]))
+	for c := range subscribers {
+		_, _ = c.Write(message.bytesFor(c))
+	}
+	return protocol.MakeIntReply(int64(len(subscribers)))
+}
+
+// RemoveSlot drops all shard channels of slot, every subscriber gets a sunsubscribe push for each of its channels.
+// It is called when the slot is no longer served by this node, so that clients resubscribe to the new owner
+func (hub *Hub) RemoveSlot(slot int) {
+	hub.shardMu.Lock()
+	channels := hub.shardSubs[slot]
+	delete(hub.shardSubs, slot)
+	hub.shardMu.Unlock()
+	for channel, subscribers := range channels {
+		for c := range subscribers {
+			c.SUnSubscribe(channel)
+			_, _ = c.Write(makeConfirm(c, sUnsubscribeKind, []byte(channel), c.SSubsCount()))
+		}
+	}
+}
+
+// shardChannels returns active shard channels matching pattern, "" matches all
+func (hub *Hub) shardChannels(pattern string) redis.Reply {
+	hub.shardMu.RLock()
+	defer hub.shardMu.RUnlock()
+	var result [][]byte
+	for _, channels := range hub.shardSubs {
+		for channel := range channels {
+			if pattern == "" || wildcard.Match(pattern, channel) {
+				result = append(result, []byte(channel))
+			}
+		}
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// shardNumSub returns shard channels and their numbers of subscribers, flattened
+func (hub *Hub) shardNumSub(args [][]byte) redis.Reply {
+	hub.shardMu.RLock()
+	defer hub.shardMu.RUnlock()
+	result := make([]redis.Reply, 0, len(args)*2)
+	for _, arg := range args {
+		count := len(hub.shardSubscribers(string(arg)))
+		result = append(result, protocol.MakeBulkReply(arg), protocol.MakeIntReply(int64(count)))
+	}
+	return protocol.MakeMultiRawReply(result)
+}
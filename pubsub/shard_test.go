@@ -0,0 +1,110 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/hashslot"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+func TestSSubscribe(t *testing.T) {
+	hub := MakeHub()
+	c, other := makeTestClient(t), makeTestClient(t)
+	hub.Subscribe(c, args("a"))
+	c.expect(t, makeConfirm(c, subscribeKind, []byte("a"), 1))
+
+	// the count of confirmations only includes shard channels
+	hub.SSubscribe(c, args("a", "b"))
+	c.expect(t, makeConfirm(c, sSubscribeKind, []byte("a"), 1))
+	c.expect(t, makeConfirm(c, sSubscribeKind, []byte("b"), 2))
+	hub.Unsubscribe(c, nil)
+	c.expect(t, makeConfirm(c, unsubscribeKind, []byte("a"), 0))
+	if !InSubscribeMode(c) {
+		t.Fatal("a client subscribing shard channels should be in subscribe mode")
+	}
+
+	assertReply(t, hub.SPublish(args("a", "hello")), protocol.MakeIntReply(1))
+	c.expect(t, protocol.MakeMultiBulkReply(args("smessage", "a", "hello")).ToBytes())
+	// shard channels and normal channels are separated, and shard channels never match patterns
+	hub.PSubscribe(other, args("*"))
+	other.expect(t, makeConfirm(other, pSubscribeKind, []byte("*"), 1))
+	assertReply(t, hub.Publish(args("a", "hello")), protocol.MakeIntReply(1))
+	other.expect(t, makePMessage("*", "a", []byte("hello")).bytesFor(other))
+	assertReply(t, hub.SPublish(args("b", "hi")), protocol.MakeIntReply(1))
+	c.expect(t, protocol.MakeMultiBulkReply(args("smessage", "b", "hi")).ToBytes())
+	other.expectNothing(t)
+	c.expectNothing(t)
+
+	hub.SUnsubscribe(c, args("a"))
+	c.expect(t, makeConfirm(c, sUnsubscribeKind, []byte("a"), 1))
+	hub.SUnsubscribe(c, nil)
+	c.expect(t, makeConfirm(c, sUnsubscribeKind, []byte("b"), 0))
+	if InSubscribeMode(c) {
+		t.Fatal("a client without subscriptions should leave subscribe mode")
+	}
+	// unsubscribing without any subscription confirms a null channel
+	hub.SUnsubscribe(c, nil)
+	c.expect(t, makeConfirm(c, sUnsubscribeKind, nil, 0))
+	assertReply(t, hub.SPublish(args("a", "hello")), protocol.MakeIntReply(0))
+}
+
+func TestShardPubSubCommand(t *testing.T) {
+	hub := MakeHub()
+	c1, c2 := makeTestClient(t), makeTestClient(t)
+	hub.SSubscribe(c1, args("news"))
+	hub.SSubscribe(c2, args("news", "sport"))
+	hub.Subscribe(c2, args("weather"))
+
+	assertReply(t, hub.PubSub(args("shardchannels", "n*")), protocol.MakeMultiBulkReply(args("news")))
+	assertReply(t, hub.PubSub(args("shardnumsub", "news", "sport", "weather")), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("news")), protocol.MakeIntReply(2),
+		protocol.MakeBulkReply([]byte("sport")), protocol.MakeIntReply(1),
+		protocol.MakeBulkReply([]byte("weather")), protocol.MakeIntReply(0),
+	}))
+	assertReply(t, hub.PubSub(args("channels")), protocol.MakeMultiBulkReply(args("weather")))
+
+	// a closed client is removed from all shard channels
+	hub.UnsubscribeAll(c2)
+	assertReply(t, hub.PubSub(args("shardchannels")), protocol.MakeMultiBulkReply(args("news")))
+	assertReply(t, hub.PubSub(args("shardnumsub", "news", "sport")), protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("news")), protocol.MakeIntReply(1),
+		protocol.MakeBulkReply([]byte("sport")), protocol.MakeIntReply(0),
+	}))
+	if InSubscribeMode(c2) {
+		t.Fatal("UnsubscribeAll should clear shard subscriptions of the connection")
+	}
+}
+
+func TestRemoveSlot(t *testing.T) {
+	hub := MakeHub()
+	c, other := makeTestClient(t), makeTestClient(t)
+	// {user}a and {user}b share a slot by their hash tag, x is in another one
+	slot := hashslot.Of("{user}a")
+	if hashslot.Of("{user}b") != slot || hashslot.Of("x") == slot {
+		t.Fatal("unexpected slots of test channels")
+	}
+	hub.SSubscribe(c, args("{user}a", "x"))
+	c.expect(t, makeConfirm(c, sSubscribeKind, []byte("{user}a"), 1))
+	c.expect(t, makeConfirm(c, sSubscribeKind, []byte("x"), 2))
+	hub.SSubscribe(other, args("{user}b"))
+	other.expect(t, makeConfirm(other, sSubscribeKind, []byte("{user}b"), 1))
+
+	// subscribers of the slot are told to resubscribe, other shard channels are kept
+	hub.RemoveSlot(slot)
+	c.expect(t, makeConfirm(c, sUnsubscribeKind, []byte("{user}a"), 1))
+	other.expect(t, makeConfirm(other, sUnsubscribeKind, []byte("{user}b"), 0))
+	c.expectNothing(t)
+	if InSubscribeMode(other) || !InSubscribeMode(c) {
+		t.Fatal("subscribe mode should follow the remaining shard channels")
+	}
+	assertReply(t, hub.PubSub(args("shardchannels")), protocol.MakeMultiBulkReply(args("x")))
+	assertReply(t, hub.SPublish(args("{user}a", "hello")), protocol.MakeIntReply(0))
+	assertReply(t, hub.SPublish(args("x", "hello")), protocol.MakeIntReply(1))
+	c.expect(t, protocol.MakeMultiBulkReply(args("smessage", "x", "hello")).ToBytes())
+
+	// removing a slot without shard channels sends nothing
+	hub.RemoveSlot(slot)
+	c.expectNothing(t)
+	other.expectNothing(t)
+}
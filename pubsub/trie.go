@@ -0,0 +1,124 @@
+package pubsub
+
+import (
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/wildcard"
+)
+
+// patternTrie indexes subscribed patterns by their literal prefixes (the part before the first special character),
+// a pattern is stored at the node of its literal prefix.
+//
+// Only patterns stored along the path of a channel may match the channel, so publishing visits
+// len(channel) nodes and matches those candidates instead of every pattern.
+// For example, "orders.*.created" is stored at "orders." and is never tried for channel "users.1".
+// Patterns starting with a special character like "*" are stored at the root and always tried
+type patternTrie struct {
+	root *trieNode
+	// number of patterns in trie
+	size int
+}
+
+type trieNode struct {
+	children map[byte]*trieNode
+	// pattern -> subscribers, for patterns whose literal prefix ends at this node
+	patterns map[string]map[redis.Connection]struct{}
+}
+
+func makePatternTrie() *patternTrie {
+	return &patternTrie{
+		root: &trieNode{},
+	}
+}
+
+// literalPrefix returns the prefix of pattern before the first special character of glob
+func literalPrefix(pattern string) string {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?', '[', '\\':
+			return pattern[:i]
+		}
+	}
+	return pattern
+}
+
+// add subscribes pattern for c, returns false if c has subscribed it already
+func (t *patternTrie) add(pattern string, c redis.Connection) bool {
+	node := t.root
+	prefix := literalPrefix(pattern)
+	for i := 0; i < len(prefix); i++ {
+		if node.children == nil {
+			node.children = make(map[byte]*trieNode)
+		}
+		child := node.children[prefix[i]]
+		if child == nil {
+			child = &trieNode{}
+			node.children[prefix[i]] = child
+		}
+		node = child
+	}
+	if node.patterns == nil {
+		node.patterns = make(map[string]map[redis.Connection]struct{})
+	}
+	subscribers := node.patterns[pattern]
+	if subscribers == nil {
+		subscribers = make(map[redis.Connection]struct{})
+		node.patterns[pattern] = subscribers
+		t.size++
+	}
+	if _, ok := subscribers[c]; ok {
+		return false
+	}
+	subscribers[c] = struct{}{}
+	return true
+}
+
+// remove unsubscribes pattern for c, nodes without any pattern are pruned
+func (t *patternTrie) remove(pattern string, c redis.Connection) {
+	prefix := literalPrefix(pattern)
+	path := make([]*trieNode, 0, len(prefix)+1)
+	node := t.root
+	path = append(path, node)
+	for i := 0; i < len(prefix); i++ {
+		node = node.children[prefix[i]]
+		if node == nil {
+			return
+		}
+		path = append(path, node)
+	}
+	subscribers := node.patterns[pattern]
+	if subscribers == nil {
+		return
+	}
+	delete(subscribers, c)
+	if len(subscribers) > 0 {
+		return
+	}
+	delete(node.patterns, pattern)
+	t.size--
+	// prune from the leaf, path[i] is the child of path[i-1] by prefix[i-1]
+	for i := len(path) - 1; i > 0; i-- {
+		if len(path[i].patterns) > 0 || len(path[i].children) > 0 {
+			break
+		}
+		delete(path[i-1].children, prefix[i-1])
+	}
+}
+
+// forEachMatch visits patterns matching channel and their subscribers
+func (t *patternTrie) forEachMatch(channel string, consumer func(pattern string, subscribers map[redis.Connection]struct{})) {
+	node := t.root
+	for i := 0; ; i++ {
+		for pattern, subscribers := range node.patterns {
+			if wildcard.Match(pattern, channel) {
+				consumer(pattern, subscribers)
+			}
+		}
+		if i == len(channel) {
+			return
+		}
+		node = node.children[channel[i]]
+		if node == nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package pubsub
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/wildcard"
+)
+
+// testConn is a subscriber of the trie, which only uses connections as map keys
+type testConn struct {
+	redis.Connection
+	id int
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	tests := map[string]string{
+		"orders.*.created": "orders.",
+		"news":             "news",
+		"*":                "",
+		"a?b":              "a",
+		"a[bc]":            "a",
+		`a\*`:              "a",
+	}
+	for pattern, expected := range tests {
+		if got := literalPrefix(pattern); got != expected {
+			t.Errorf("literalPrefix(%q) is %q, expected %q", pattern, got, expected)
+		}
+	}
+}
+
+// matched returns the sorted patterns of trie matching channel
+func matched(trie *patternTrie, channel string) []string {
+	var patterns []string
+	trie.forEachMatch(channel, func(pattern string, subscribers map[redis.Connection]struct{}) {
+		patterns = append(patterns, pattern)
+	})
+	sort.Strings(patterns)
+	return patterns
+}
+
+func TestPatternTrieRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomString := func(alphabet string) string {
+		var sb strings.Builder
+		for i := r.Intn(5); i > 0; i-- {
+			sb.WriteByte(alphabet[r.Intn(len(alphabet))])
+		}
+		return sb.String()
+	}
+	conns := []redis.Connection{&testConn{id: 1}, &testConn{id: 2}}
+	trie := makePatternTrie()
+	// pattern -> subscribers
+	expected := make(map[string]map[redis.Connection]bool)
+	for i := 0; i < 3000; i++ {
+		pattern := randomString("ab.*?")
+		c := conns[r.Intn(len(conns))]
+		if r.Intn(3) == 0 {
+			trie.remove(pattern, c)
+			delete(expected[pattern], c)
+			if len(expected[pattern]) == 0 {
+				delete(expected, pattern)
+			}
+		} else {
+			if trie.add(pattern, c) == expected[pattern][c] {
+				t.Fatalf("add(%q) is wrong", pattern)
+			}
+			if expected[pattern] == nil {
+				expected[pattern] = make(map[redis.Connection]bool)
+			}
+			expected[pattern][c] = true
+		}
+		if trie.size != len(expected) {
+			t.Fatalf("size is %d, expected %d", trie.size, len(expected))
+		}
+		channel := randomString("ab.")
+		var patterns []string
+		for pattern := range expected {
+			if wildcard.Match(pattern, channel) {
+				patterns = append(patterns, pattern)
+			}
+		}
+		sort.Strings(patterns)
+		if got := matched(trie, channel); strings.Join(got, " ") != strings.Join(patterns, " ") {
+			t.Fatalf("patterns matching %q are %q, expected %q", channel, got, patterns)
+		}
+	}
+
+	// removing all patterns prunes all nodes
+	for pattern, subscribers := range expected {
+		for c := range subscribers {
+			trie.remove(pattern, c)
+		}
+	}
+	if trie.size != 0 || len(trie.root.children) != 0 || len(trie.root.patterns) != 0 {
+		t.Fatal("an empty trie should only have the root")
+	}
+}
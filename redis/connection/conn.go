@@ -0,0 +1,494 @@
+// Package connection implements redis.Connection on top of net.Conn
+package connection
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/lib/logger"
+)
+
+const (
+	// flagSlave means this a connection with slave
+	flagSlave = uint64(1 << iota)
+	// flagMaster means this a connection with master
+	flagMaster
+	// flagMulti means this connection is within a transaction
+	flagMulti
+	// flagTxRollback means transactions of this connection roll back on runtime errors
+	flagTxRollback
+)
+
+// lastClientID is used to allocate unique client id
+var lastClientID int64
+
+// Connection represents a connection with a redis-cli
+type Connection struct {
+	conn net.Conn
+	id   int64
+
+	// Replies are queued in the output buffer and sent by writeLoop, so Write never blocks on a slow client,
+	// e.g. PUBLISH is not stalled by a subscriber not reading. outCond signals writeLoop of new output or closing
+	outMu    sync.Mutex
+	outCond  *sync.Cond
+	out      [][]byte
+	outBytes int64
+	// since when outBytes has been above the soft limit, zero if it is not
+	softSince time.Time
+	closed    bool
+	// closed after writeLoop sent all output or failed
+	writerDone chan struct{}
+
+	// guards subscriptions and user
+	mu sync.Mutex
+	// flags are atomic since the client class is read by goroutines writing pushes to the connection
+	flags atomic.Uint64
+
+	// subscribing channels
+	subs map[string]bool
+	// subscribing patterns
+	psubs map[string]bool
+	// subscribing shard channels, they may be removed by other goroutines when the slot migrates
+	ssubs map[string]bool
+
+	// password may be changed by CONFIG command during runtime, so store the password
+	password string
+	// ACL user the connection authenticated as, empty if not authenticated. Guarded by mu
+	user string
+
+	// queued commands for `multi`
+	queue    [][][]byte
+	watching map[string]uint32
+	txErrors []error
+
+	// selected db
+	selectedDB int
+	name       string
+
+	// RESP version switched by HELLO, 0 means 2
+	protocolVersion atomic.Int32
+}
+
+// RemoteAddr returns the remote network address
+func (c *Connection) RemoteAddr() string {
+	if c.conn == nil {
+		return ""
+	}
+	return c.conn.RemoteAddr().String()
+}
+
+// Close disconnect with the client
+func (c *Connection) Close() error {
+	c.outMu.Lock()
+	c.closed = true
+	c.outCond.Broadcast()
+	c.outMu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	// wait for the pending output at most client-close-timeout, closing conn makes writeLoop drop the rest
+	select {
+	case <-c.writerDone:
+	case <-time.After(time.Duration(config.Properties.ClientCloseTimeout) * time.Millisecond):
+	}
+	return c.conn.Close()
+}
+
+// NewConn creates Connection instance
+func NewConn(conn net.Conn) *Connection {
+	c := &Connection{
+		conn:       conn,
+		id:         atomic.AddInt64(&lastClientID, 1),
+		writerDone: make(chan struct{}),
+	}
+	c.outCond = sync.NewCond(&c.outMu)
+	if conn != nil {
+		go c.writeLoop()
+	} else {
+		close(c.writerDone)
+	}
+	return c
+}
+
+// outputBufferLimits are limits of client classes, loaded from config at the first write
+var (
+	outputBufferLimits     map[string]config.OutputBufferLimit
+	outputBufferLimitsOnce sync.Once
+)
+
+// Write appends response to the output buffer, it is sent to client by writeLoop in order.
+// b must not be modified after Write returns.
+// If the output buffer exceeds the limit of the client class (see config.ServerProperties.ClientOutputBufferLimit),
+// the connection is closed at once without sending the pending output
+func (c *Connection) Write(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	c.outMu.Lock()
+	if c.closed {
+		c.outMu.Unlock()
+		return 0, net.ErrClosed
+	}
+	c.out = append(c.out, b)
+	c.outBytes += int64(len(b))
+	if reason := c.checkOutputLimit(); reason != "" {
+		pending := c.outBytes
+		c.dropOutput()
+		c.outMu.Unlock()
+		logger.Warn(fmt.Sprintf("client id=%d addr=%s closed for overcoming of output buffer limits: %s, %d bytes pending",
+			c.id, c.RemoteAddr(), reason, pending))
+		// the connection goroutine notices the closed connection and cleans up the client
+		if c.conn != nil {
+			_ = c.conn.Close()
+		}
+		return 0, net.ErrClosed
+	}
+	c.outCond.Signal()
+	c.outMu.Unlock()
+	return len(b), nil
+}
+
+// clientClass returns the class of output buffer limit
+func (c *Connection) clientClass() string {
+	c.mu.Lock()
+	subscribing := len(c.subs) > 0 || len(c.psubs) > 0 || len(c.ssubs) > 0
+	c.mu.Unlock()
+	if subscribing {
+		return config.ClientClassPubSub
+	}
+	if c.IsSlave() {
+		return config.ClientClassReplica
+	}
+	return config.ClientClassNormal
+}
+
+// checkOutputLimit returns why the output buffer exceeds the limit, or "" if it does not, must be called with outMu held
+func (c *Connection) checkOutputLimit() string {
+	outputBufferLimitsOnce.Do(func() {
+		outputBufferLimits = config.Properties.OutputBufferLimits()
+	})
+	class := c.clientClass()
+	limit := outputBufferLimits[class]
+	if limit.HardBytes > 0 && c.outBytes > limit.HardBytes {
+		return class + " hard limit " + fmt.Sprint(limit.HardBytes) + " bytes reached"
+	}
+	if limit.SoftBytes <= 0 || c.outBytes <= limit.SoftBytes {
+		c.softSince = time.Time{}
+		return ""
+	}
+	if c.softSince.IsZero() {
+		c.softSince = time.Now()
+		return ""
+	}
+	if time.Since(c.softSince) > limit.SoftDuration {
+		return fmt.Sprintf("%s soft limit %d bytes exceeded for %s", class, limit.SoftBytes, limit.SoftDuration)
+	}
+	return ""
+}
+
+// dropOutput discards the pending output and stops writeLoop, must be called with outMu held
+func (c *Connection) dropOutput() {
+	c.closed = true
+	c.out = nil
+	c.outBytes = 0
+	c.outCond.Broadcast()
+}
+
+// writeLoop sends the output buffer to client until the connection is closed and all output is sent
+func (c *Connection) writeLoop() {
+	defer close(c.writerDone)
+	for {
+		c.outMu.Lock()
+		for len(c.out) == 0 && !c.closed {
+			c.outCond.Wait()
+		}
+		if len(c.out) == 0 {
+			c.outMu.Unlock()
+			return
+		}
+		bufs := net.Buffers(c.out)
+		c.out = nil
+		c.outMu.Unlock()
+
+		n, err := bufs.WriteTo(c.conn)
+
+		c.outMu.Lock()
+		if err != nil {
+			c.dropOutput()
+			c.outMu.Unlock()
+			return
+		}
+		c.outBytes -= n
+		c.outMu.Unlock()
+	}
+}
+
+// ID returns the unique id of the connection
+func (c *Connection) ID() int64 {
+	return c.id
+}
+
+// Name returns the unique identifier of the connection
+func (c *Connection) Name() string {
+	if c.name == "" && c.conn != nil {
+		return c.conn.RemoteAddr().String()
+	}
+	return c.name
+}
+
+// SetName sets the connection name
+func (c *Connection) SetName(name string) {
+	c.name = name
+}
+
+// SetProtocolVersion switches the protocol of replies, 2 or 3
+func (c *Connection) SetProtocolVersion(version int) {
+	c.protocolVersion.Store(int32(version))
+}
+
+// GetProtocolVersion returns the protocol version of the connection
+func (c *Connection) GetProtocolVersion() int {
+	if version := c.protocolVersion.Load(); version > 0 {
+		return int(version)
+	}
+	return 2
+}
+
+// Subscribe add current connection into subscribers of the given channel
+func (c *Connection) Subscribe(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.subs == nil {
+		c.subs = make(map[string]bool)
+	}
+	c.subs[channel] = true
+}
+
+// UnSubscribe removes current connection into subscribers of the given channel
+func (c *Connection) UnSubscribe(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.subs) == 0 {
+		return
+	}
+	delete(c.subs, channel)
+}
+
+// SubsCount returns the number of subscribing channels
+func (c *Connection) SubsCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.subs)
+}
+
+// GetChannels returns all subscribing channels
+func (c *Connection) GetChannels() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	channels := make([]string, 0, len(c.subs))
+	for channel := range c.subs {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// PSubscribe add current connection into subscribers of the given pattern
+func (c *Connection) PSubscribe(pattern string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.psubs == nil {
+		c.psubs = make(map[string]bool)
+	}
+	c.psubs[pattern] = true
+}
+
+// PUnSubscribe removes current connection from subscribers of the given pattern
+func (c *Connection) PUnSubscribe(pattern string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.psubs) == 0 {
+		return
+	}
+	delete(c.psubs, pattern)
+}
+
+// PSubsCount returns the number of subscribing patterns
+func (c *Connection) PSubsCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.psubs)
+}
+
+// GetPatterns returns all subscribing patterns
+func (c *Connection) GetPatterns() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	patterns := make([]string, 0, len(c.psubs))
+	for pattern := range c.psubs {
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// SSubscribe add current connection into subscribers of the given shard channel
+func (c *Connection) SSubscribe(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ssubs == nil {
+		c.ssubs = make(map[string]bool)
+	}
+	c.ssubs[channel] = true
+}
+
+// SUnSubscribe removes current connection from subscribers of the given shard channel
+func (c *Connection) SUnSubscribe(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.ssubs, channel)
+}
+
+// SSubsCount returns the number of subscribing shard channels
+func (c *Connection) SSubsCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.ssubs)
+}
+
+// GetShardChannels returns all subscribing shard channels
+func (c *Connection) GetShardChannels() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	channels := make([]string, 0, len(c.ssubs))
+	for channel := range c.ssubs {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// SetPassword stores password for authentication
+func (c *Connection) SetPassword(password string) {
+	c.password = password
+}
+
+// GetPassword get password for authentication
+func (c *Connection) GetPassword() string {
+	return c.password
+}
+
+// SetUser sets the ACL user the connection authenticated as
+func (c *Connection) SetUser(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.user = name
+}
+
+// GetUser returns the ACL user the connection authenticated as, it may be called by other goroutines like ACL DELUSER
+func (c *Connection) GetUser() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.user
+}
+
+// InMultiState tells is connection in an uncommitted transaction
+func (c *Connection) InMultiState() bool {
+	return c.flags.Load()&flagMulti > 0
+}
+
+// SetMultiState sets transaction flag
+func (c *Connection) SetMultiState(state bool) {
+	if !state { // reset data when cancel multi
+		c.watching = nil
+		c.queue = nil
+		c.txErrors = nil
+		c.flags.And(^flagMulti) // clean multi flag
+		return
+	}
+	c.flags.Or(flagMulti)
+}
+
+// GetQueuedCmdLine returns queued commands of current transaction
+func (c *Connection) GetQueuedCmdLine() [][][]byte {
+	return c.queue
+}
+
+// EnqueueCmd  enqueues command of current transaction
+func (c *Connection) EnqueueCmd(cmdLine [][]byte) {
+	c.queue = append(c.queue, cmdLine)
+}
+
+// AddTxError stores syntax error within transaction
+func (c *Connection) AddTxError(err error) {
+	c.txErrors = append(c.txErrors, err)
+}
+
+// GetTxErrors returns syntax error within transaction
+func (c *Connection) GetTxErrors() []error {
+	return c.txErrors
+}
+
+// ClearQueuedCmds clears queued commands of current transaction
+func (c *Connection) ClearQueuedCmds() {
+	c.queue = nil
+}
+
+// GetWatching returns watching keys and their version code when started watching
+func (c *Connection) GetWatching() map[string]uint32 {
+	if c.watching == nil {
+		c.watching = make(map[string]uint32)
+	}
+	return c.watching
+}
+
+// SetTxRollback sets whether transactions roll back on runtime errors
+func (c *Connection) SetTxRollback(enabled bool) {
+	if enabled {
+		c.flags.Or(flagTxRollback)
+	} else {
+		c.flags.And(^flagTxRollback)
+	}
+}
+
+// IsTxRollback returns whether transactions roll back on runtime errors
+func (c *Connection) IsTxRollback() bool {
+	return c.flags.Load()&flagTxRollback > 0
+}
+
+// GetDBIndex returns selected db
+func (c *Connection) GetDBIndex() int {
+	return c.selectedDB
+}
+
+// SelectDB selects a database
+func (c *Connection) SelectDB(dbNum int) {
+	c.selectedDB = dbNum
+}
+
+// SetSlave marks this connection as a slave
+func (c *Connection) SetSlave() {
+	c.flags.Or(flagSlave)
+}
+
+// IsSlave returns whether this connection is a slave
+func (c *Connection) IsSlave() bool {
+	return c.flags.Load()&flagSlave > 0
+}
+
+// SetMaster marks this connection as a master
+func (c *Connection) SetMaster() {
+	c.flags.Or(flagMaster)
+}
+
+// IsMaster returns whether this connection is a master
+func (c *Connection) IsMaster() bool {
+	return c.flags.Load()&flagMaster > 0
+}
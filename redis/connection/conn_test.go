@@ -0,0 +1,110 @@
+package connection
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tonge3199/redis_go/config"
+)
+
+func TestSubscriptionsConcurrently(t *testing.T) {
+	c := NewConn(nil)
+	// subscriptions may be read by other goroutines while the client subscribes
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			c.Subscribe(strconv.Itoa(i))
+			c.PSubscribe(strconv.Itoa(i) + "*")
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		c.GetChannels()
+		c.GetPatterns()
+	}
+	wg.Wait()
+	if len(c.GetChannels()) != 1000 || len(c.GetPatterns()) != 1000 {
+		t.Fatalf("subscribed %d channels and %d patterns", len(c.GetChannels()), len(c.GetPatterns()))
+	}
+}
+
+func TestCloseDropsUnsentOutput(t *testing.T) {
+	timeout := config.Properties.ClientCloseTimeout
+	config.Properties.ClientCloseTimeout = 50
+	t.Cleanup(func() { config.Properties.ClientCloseTimeout = timeout })
+
+	// the pending output is sent before closing
+	server, client := net.Pipe()
+	c := NewConn(server)
+	_, _ = c.Write([]byte("+OK\r\n"))
+	go func() { _ = c.Close() }()
+	reply, err := io.ReadAll(client)
+	if err != nil || string(reply) != "+OK\r\n" {
+		t.Fatalf("read %q: %v", reply, err)
+	}
+
+	// a client not reading its output doesn't delay closing
+	server, client = net.Pipe()
+	defer client.Close()
+	c = NewConn(server)
+	_, _ = c.Write([]byte("+OK\r\n"))
+	start := time.Now()
+	_ = c.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("closing takes %s", elapsed)
+	}
+	if _, err := c.Write([]byte("+OK\r\n")); err == nil {
+		t.Fatal("write to a closed connection succeeded")
+	}
+}
+
+// setOutputBufferLimits replaces limits loaded from config during the test
+func setOutputBufferLimits(t *testing.T, limits map[string]config.OutputBufferLimit) {
+	outputBufferLimitsOnce.Do(func() {})
+	saved := outputBufferLimits
+	outputBufferLimits = limits
+	t.Cleanup(func() { outputBufferLimits = saved })
+}
+
+func TestOutputBufferLimit(t *testing.T) {
+	setOutputBufferLimits(t, map[string]config.OutputBufferLimit{
+		config.ClientClassNormal: {HardBytes: 20},
+		config.ClientClassPubSub: {SoftBytes: 10, SoftDuration: 50 * time.Millisecond},
+	})
+
+	// the client doesn't read its output, so the output buffer grows until the hard limit
+	server, client := net.Pipe()
+	defer client.Close()
+	c := NewConn(server)
+	for i := 0; i < 4; i++ {
+		if _, err := c.Write([]byte("+OK\r\n")); err != nil {
+			t.Fatalf("write %d bytes failed: %v", (i+1)*5, err)
+		}
+	}
+	if _, err := c.Write([]byte("+OK\r\n")); err != net.ErrClosed {
+		t.Fatalf("write beyond the hard limit returns %v, expected net.ErrClosed", err)
+	}
+	if _, err := c.Write([]byte("+OK\r\n")); err != net.ErrClosed {
+		t.Fatal("the connection should be closed once it exceeds the hard limit")
+	}
+
+	// a subscribing client is limited by the pubsub class, which closes it after staying above the soft limit
+	server, client = net.Pipe()
+	defer client.Close()
+	c = NewConn(server)
+	c.Subscribe("ch")
+	for i := 0; i < 10; i++ {
+		if _, err := c.Write([]byte("+OK\r\n")); err != nil {
+			t.Fatal("the soft limit should allow bursts shorter than its duration")
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := c.Write([]byte("+OK\r\n")); err != net.ErrClosed {
+		t.Fatalf("write after the soft limit duration returns %v, expected net.ErrClosed", err)
+	}
+}
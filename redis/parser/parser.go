@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"errors"
 	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/logger"
 	"github.com/tonge3199/redis_go/redis/protocol"
 	"io"
 	"runtime/debug"
@@ -173,7 +174,7 @@ func parseArray(header []byte, reader *bufio.Reader, ch chan<- *Payload) error {
 		}
 		return nil
 	}
-	lines := make([][]byte, nStrs)
+	lines := make([][]byte, 0, nStrs)
 	for i := int64(0); i < nStrs; i++ {
 		var line []byte
 		line, err = reader.ReadBytes('\n')
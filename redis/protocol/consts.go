@@ -90,3 +90,18 @@ var theQueuedReply = new(QueuedReply)
 func MakeQueuedReply() *QueuedReply {
 	return theQueuedReply
 }
+
+var nullMultiBulkBytes = []byte("*-1\r\n")
+
+// NullMultiBulkReply is a null array, e.g. LPOP key count on a missing key
+type NullMultiBulkReply struct{}
+
+// ToBytes marshal redis.Reply
+func (r *NullMultiBulkReply) ToBytes() []byte {
+	return nullMultiBulkBytes
+}
+
+// MakeNullMultiBulkReply creates a new NullMultiBulkReply
+func MakeNullMultiBulkReply() *NullMultiBulkReply {
+	return &NullMultiBulkReply{}
+}
@@ -0,0 +1,73 @@
+package protocol
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/tonge3199/redis_go/interface/redis"
+)
+
+// Replies below only exist in RESP3, they are sent to clients which switched protocol by HELLO 3
+
+/* ---- Push Reply ---- */
+
+// PushReply is an out-of-band message like an invalidation of client side caching,
+// a client tells it from replies of its commands by the first byte '>'
+type PushReply struct {
+	Replies []redis.Reply
+}
+
+// MakePushReply creates PushReply
+func MakePushReply(replies []redis.Reply) *PushReply {
+	return &PushReply{Replies: replies}
+}
+
+// ToBytes marshal redis.Reply
+func (r *PushReply) ToBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(">" + strconv.Itoa(len(r.Replies)) + CRLF)
+	for _, reply := range r.Replies {
+		buf.Write(reply.ToBytes())
+	}
+	return buf.Bytes()
+}
+
+/* ---- Map Reply ---- */
+
+// MapReply is a map of key and value pairs, the order of pairs is kept
+type MapReply struct {
+	// Pairs are key, value, key, value ...
+	Pairs []redis.Reply
+}
+
+// MakeMapReply creates MapReply from key and value pairs
+func MakeMapReply(pairs []redis.Reply) *MapReply {
+	return &MapReply{Pairs: pairs}
+}
+
+// ToBytes marshal redis.Reply
+func (r *MapReply) ToBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%" + strconv.Itoa(len(r.Pairs)/2) + CRLF)
+	for _, reply := range r.Pairs {
+		buf.Write(reply.ToBytes())
+	}
+	return buf.Bytes()
+}
+
+/* ---- Null Reply ---- */
+
+var nullBytes = []byte("_" + CRLF)
+
+// NullReply is the null of RESP3
+type NullReply struct{}
+
+// ToBytes marshal redis.Reply
+func (r *NullReply) ToBytes() []byte {
+	return nullBytes
+}
+
+// MakeNullReply creates NullReply
+func MakeNullReply() *NullReply {
+	return &NullReply{}
+}
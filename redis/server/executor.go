@@ -0,0 +1,113 @@
+package server
+
+import (
+	databaseface "github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/redis/connection"
+)
+
+// Executor modes, see config.ServerProperties.ExecutorMode
+const (
+	// executorConcurrent runs commands in connection goroutines, commands on disjoint keys run in parallel
+	executorConcurrent = "concurrent"
+	// executorSingle runs all commands in one executor goroutine like redis,
+	// connection goroutines only parse requests and wait for blocking commands
+	executorSingle = "single"
+)
+
+const (
+	// maxBatchSize is the max number of pipelined commands submitted to the executor at once
+	maxBatchSize = 64
+	jobQueueSize = 1024
+)
+
+// executor runs commands of all connections one by one in a single goroutine.
+//
+// A connection submits all its pipelined commands which have been parsed as a batch,
+// so the cost of switching goroutines is shared by the whole batch.
+// Since there is only one goroutine executing commands, commands never contend for locks
+// and their order is exactly the order they are taken from the queue.
+//
+// 阻塞命令 (BLPOP 等) 不会阻塞执行线程：执行线程遇到阻塞命令时结束本批次，
+// 由连接自己的 goroutine 等待，等到结果后再提交剩余的命令
+type executor struct {
+	db   databaseface.DB
+	jobs chan *batch
+	stop chan struct{}
+}
+
+// batch is pipelined commands of a connection
+type batch struct {
+	client   *connection.Connection
+	cmdLines [][][]byte
+	// number of executed commands, it may be less than len(cmdLines) if a command blocks
+	executed int
+	// reply of the last executed command if it blocks, nil otherwise
+	blocking redis.Reply
+	done     chan struct{}
+}
+
+func makeExecutor(db databaseface.DB) *executor {
+	e := &executor{
+		db:   db,
+		jobs: make(chan *batch, jobQueueSize),
+		stop: make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *executor) run() {
+	for {
+		select {
+		case b := <-e.jobs:
+			e.execBatch(b)
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// execBatch runs commands of batch in order, it stops after a blocking command,
+// since the commands after it must not be executed until it returns.
+// The reply of a command is written to the output buffer once it's executed, so it precedes
+// what the next command writes to the connection itself, like confirmations of SUBSCRIBE
+func (e *executor) execBatch(b *batch) {
+	defer close(b.done)
+	for _, cmdLine := range b.cmdLines {
+		result := e.db.Exec(b.client, cmdLine)
+		b.executed++
+		if _, ok := result.(databaseface.BlockingReply); ok {
+			b.blocking = result
+			return
+		}
+		_, _ = b.client.Write(replyBytes(result, nil))
+	}
+}
+
+// submit executes cmdLines in the executor goroutine and waits for them, replies are written by the executor.
+// It returns the number of executed commands, which is less than len(cmdLines) if a command blocks,
+// and the reply of the blocking command, see execBatch. It returns 0 if the executor stopped
+func (e *executor) submit(client *connection.Connection, cmdLines [][][]byte) (int, redis.Reply) {
+	b := &batch{
+		client:   client,
+		cmdLines: cmdLines,
+		done:     make(chan struct{}),
+	}
+	select {
+	case e.jobs <- b:
+	case <-e.stop:
+		return 0, nil
+	}
+	select {
+	case <-b.done:
+		return b.executed, b.blocking
+	case <-e.stop:
+		return 0, nil
+	}
+}
+
+// Close stops the executor goroutine
+func (e *executor) Close() {
+	close(e.stop)
+}
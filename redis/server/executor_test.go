@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/lib/logger"
+)
+
+// quietLogger drops logs, benchmarks open and close connections too often to log them
+type quietLogger struct{}
+
+func (quietLogger) Output(logger.LogLevel, int, string) {}
+
+// startServer serves a new Handler in executorMode on a random local port,
+// and stops it and waits for its goroutines when the test or benchmark ends
+func startServer(b testing.TB, executorMode string) string {
+	b.Helper()
+	mode := config.Properties.ExecutorMode
+	config.Properties.ExecutorMode = executorMode
+	handler := MakeHandler()
+	config.Properties.ExecutorMode = mode
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				handler.Handle(context.Background(), conn)
+			}()
+		}
+	}()
+	b.Cleanup(func() {
+		_ = listener.Close()
+		_ = handler.Close()
+		wg.Wait()
+	})
+	return listener.Addr().String()
+}
+
+func makeCmd(args ...string) []byte {
+	cmd := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		cmd = append(cmd, "$"+strconv.Itoa(len(arg))+"\r\n"+arg+"\r\n"...)
+	}
+	return cmd
+}
+
+// readReply reads a simple string, error, integer or bulk string reply
+func readReply(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if line[0] == '$' && line[1] != '-' {
+		_, err = r.ReadString('\n')
+	}
+	return err
+}
+
+// BenchmarkExecutorMode runs the same workload under executor-mode concurrent and single:
+// parallel clients each send SET and GET of random keys, pipelined by pipeline commands.
+// Compare the modes with
+//
+//	go test -run NONE -bench ExecutorMode -cpu 1,4,8 ./redis/server
+func BenchmarkExecutorMode(b *testing.B) {
+	defaultLogger := logger.DefaultLogger
+	logger.DefaultLogger = quietLogger{}
+	defer func() {
+		logger.DefaultLogger = defaultLogger
+	}()
+	for _, mode := range []string{executorConcurrent, executorSingle} {
+		for _, pipeline := range []int{1, 16} {
+			b.Run(mode+"/pipeline="+strconv.Itoa(pipeline), func(b *testing.B) {
+				benchmarkSetGet(b, startServer(b, mode), pipeline)
+			})
+		}
+	}
+}
+
+func benchmarkSetGet(b *testing.B, addr string, pipeline int) {
+	// clients like redis-benchmark -c, GOMAXPROCS * 8 of them
+	b.SetParallelism(8)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			b.Error(err)
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var out []byte
+		sent := 0
+		for {
+			// a command is an op, pb.Next stops the last batch early
+			out = out[:0]
+			n := 0
+			for ; n < pipeline && pb.Next(); n++ {
+				key := "key:" + strconv.Itoa(rand.Intn(10000))
+				if sent++; sent%2 == 1 {
+					out = append(out, makeCmd("SET", key, "value")...)
+				} else {
+					out = append(out, makeCmd("GET", key)...)
+				}
+			}
+			if n == 0 {
+				return
+			}
+			if _, err := conn.Write(out); err != nil {
+				b.Error(err)
+				return
+			}
+			for i := 0; i < n; i++ {
+				if err := readReply(r); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+			if n < pipeline {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,237 @@
+// Package server implements tcp.Handler which serves the redis protocol
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tonge3199/redis_go/config"
+	"github.com/tonge3199/redis_go/database"
+	databaseface "github.com/tonge3199/redis_go/interface/database"
+	"github.com/tonge3199/redis_go/interface/redis"
+	"github.com/tonge3199/redis_go/lib/logger"
+	"github.com/tonge3199/redis_go/redis/connection"
+	"github.com/tonge3199/redis_go/redis/parser"
+	"github.com/tonge3199/redis_go/redis/protocol"
+)
+
+var (
+	unknownErrReplyBytes = []byte("-ERR unknown\r\n")
+	quitReplyBytes       = []byte("+OK\r\n")
+)
+
+// Handler implements tcp.Handler and serves as a redis server
+type Handler struct {
+	activeConn sync.Map // *client -> placeholder
+	db         databaseface.DB
+	closing    atomic.Bool // refusing new client and new request
+	// executor runs all commands in executorSingle mode, it is nil in executorConcurrent mode
+	executor *executor
+}
+
+// MakeHandler creates a Handler instance
+func MakeHandler() *Handler {
+	h := &Handler{
+		db: database.NewStandaloneServer(),
+	}
+	switch config.Properties.ExecutorMode {
+	case executorSingle:
+		h.executor = makeExecutor(h.db)
+	case executorConcurrent, "":
+	default:
+		logger.Warn("unknown executor-mode " + config.Properties.ExecutorMode + ", use " + executorConcurrent)
+	}
+	return h
+}
+
+func (h *Handler) closeClient(client *connection.Connection) {
+	_ = client.Close()
+	h.db.AfterClientClose(client)
+	h.activeConn.Delete(client)
+}
+
+// Handle receives and executes redis commands
+//
+// 处理流程：
+//  1. parser.ParseStream 在独立 goroutine 中解析请求，通过 channel 发送 Payload
+//  2. 每个 Payload 是一条命令行 (MultiBulkReply)，交给 db.Exec 执行；
+//     单线程模式下，已解析好的多条命令作为一个批次提交给 executor 执行
+//  3. 将执行结果序列化后写回客户端
+func (h *Handler) Handle(ctx context.Context, conn net.Conn) {
+	if h.closing.Load() {
+		// closing handler refuse new connection
+		_ = conn.Close()
+		return
+	}
+
+	client := connection.NewConn(conn)
+	client.SetTxRollback(config.Properties.TransactionRollback)
+	h.activeConn.Store(client, struct{}{})
+	h.db.AfterClientConnect(client)
+
+	// payloads are forwarded by another goroutine, so that a disconnection can be noticed
+	// even while the connection is blocked by commands like BLPOP
+	closed := make(chan struct{})
+	handleDone := make(chan struct{})
+	defer close(handleDone)
+	ch := make(chan *parser.Payload)
+	if h.executor != nil {
+		// pipelined commands parsed in advance are submitted to the executor as a batch
+		ch = make(chan *parser.Payload, maxBatchSize)
+	}
+	go func() {
+		defer close(ch)
+		payloads := parser.ParseStream(conn)
+		for payload := range payloads {
+			if payload.Err != nil && isClosedErr(payload.Err) {
+				close(closed)
+			}
+			select {
+			case ch <- payload:
+			case <-handleDone:
+				// drain the parser until it notices the closed connection
+				for range payloads {
+				}
+				return
+			}
+		}
+	}()
+
+	var pending *parser.Payload
+	for {
+		payload := pending
+		pending = nil
+		if payload == nil {
+			var ok bool
+			if payload, ok = <-ch; !ok {
+				return
+			}
+		}
+		if payload.Err != nil {
+			if isClosedErr(payload.Err) {
+				// connection closed
+				h.closeClient(client)
+				logger.Info("connection closed: " + client.RemoteAddr())
+				return
+			}
+			// protocol err
+			errReply := protocol.MakeErrReply(payload.Err.Error())
+			_, err := client.Write(errReply.ToBytes())
+			if err != nil {
+				h.closeClient(client)
+				logger.Info("connection closed: " + client.RemoteAddr())
+				return
+			}
+			continue
+		}
+		if payload.Data == nil {
+			logger.Error("empty payload")
+			continue
+		}
+		r, ok := payload.Data.(*protocol.MultiBulkReply)
+		if !ok {
+			// inline empty line or '*0', nothing to execute
+			continue
+		}
+		if isQuit(r.Args) {
+			// the reply is sent before the connection is closed, since Close waits for pending output
+			_, _ = client.Write(quitReplyBytes)
+			h.closeClient(client)
+			logger.Info("connection closed: " + client.RemoteAddr())
+			return
+		}
+		if h.executor == nil {
+			result := h.db.Exec(client, r.Args)
+			_, _ = client.Write(replyBytes(result, closed))
+			continue
+		}
+		var cmdLines [][][]byte
+		cmdLines, pending = collectBatch(ch, [][][]byte{r.Args})
+		h.execBatch(client, cmdLines, closed)
+	}
+}
+
+// replyBytes serializes the reply, it waits for the final reply if the command blocks
+func replyBytes(result redis.Reply, closed <-chan struct{}) []byte {
+	if blocking, ok := result.(databaseface.BlockingReply); ok {
+		result = blocking.Wait(closed)
+	}
+	if result == nil {
+		return unknownErrReplyBytes
+	}
+	return result.ToBytes()
+}
+
+// collectBatch appends commands which have been parsed to cmdLines without waiting for more,
+// it also returns the first payload which is not a command so that the caller handles it later
+func collectBatch(ch <-chan *parser.Payload, cmdLines [][][]byte) ([][][]byte, *parser.Payload) {
+	for len(cmdLines) < maxBatchSize {
+		select {
+		case payload, ok := <-ch:
+			if !ok {
+				return cmdLines, nil
+			}
+			r, isCmd := payload.Data.(*protocol.MultiBulkReply)
+			if payload.Err != nil || !isCmd || isQuit(r.Args) {
+				return cmdLines, payload
+			}
+			cmdLines = append(cmdLines, r.Args)
+		default:
+			return cmdLines, nil
+		}
+	}
+	return cmdLines, nil
+}
+
+// execBatch executes pipelined commands by the executor, which writes their replies.
+// The reply of a blocking command is waited for and written here, then the commands after it are submitted
+func (h *Handler) execBatch(client *connection.Connection, cmdLines [][][]byte, closed <-chan struct{}) {
+	for len(cmdLines) > 0 {
+		executed, blocking := h.executor.submit(client, cmdLines)
+		if executed == 0 {
+			return
+		}
+		if blocking != nil {
+			_, _ = client.Write(replyBytes(blocking, closed))
+		}
+		cmdLines = cmdLines[executed:]
+	}
+}
+
+// isQuit returns whether the command line is QUIT, which is handled here since it closes the connection
+func isQuit(cmdLine [][]byte) bool {
+	return len(cmdLine) > 0 && strings.EqualFold(string(cmdLine[0]), "quit")
+}
+
+func isClosedErr(err error) bool {
+	return err == io.EOF ||
+		err == io.ErrUnexpectedEOF ||
+		strings.Contains(err.Error(), "use of closed network connection")
+}
+
+// Close stops handler
+func (h *Handler) Close() error {
+	logger.Info("handler shutting down...")
+	h.closing.Store(true)
+	// clients are closed in parallel, so the pending output of each client is given client-close-timeout
+	var wg sync.WaitGroup
+	h.activeConn.Range(func(key interface{}, val interface{}) bool {
+		client := key.(*connection.Connection)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = client.Close()
+		}()
+		return true
+	})
+	wg.Wait()
+	if h.executor != nil {
+		h.executor.Close()
+	}
+	h.db.Close()
+	return nil
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestPipelineOrder checks that replies follow the order of pipelined commands in both executor modes,
+// including confirmations written to the connection by the pub/sub commands themselves
+func TestPipelineOrder(t *testing.T) {
+	for _, mode := range []string{executorConcurrent, executorSingle} {
+		t.Run(mode, func(t *testing.T) {
+			conn, err := net.Dial("tcp", startServer(t, mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			var pipeline []byte
+			pipeline = append(pipeline, makeCmd("PING")...)
+			pipeline = append(pipeline, makeCmd("SUBSCRIBE", "ch")...)
+			pipeline = append(pipeline, makeCmd("PSUBSCRIBE", "p*")...)
+			pipeline = append(pipeline, makeCmd("PING")...)
+			pipeline = append(pipeline, makeCmd("SSUBSCRIBE", "sch")...)
+			if _, err := conn.Write(pipeline); err != nil {
+				t.Fatal(err)
+			}
+			expected := "+PONG\r\n" +
+				"*3\r\n$9\r\nsubscribe\r\n$2\r\nch\r\n:1\r\n" +
+				"*3\r\n$10\r\npsubscribe\r\n$2\r\np*\r\n:2\r\n" +
+				"*2\r\n$4\r\npong\r\n$0\r\n\r\n" +
+				"*3\r\n$10\r\nssubscribe\r\n$3\r\nsch\r\n:1\r\n"
+			_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+			got := make([]byte, len(expected))
+			if _, err := io.ReadFull(bufio.NewReader(conn), got); err != nil {
+				t.Fatalf("read %q: %v", got, err)
+			}
+			if string(got) != expected {
+				t.Fatalf("replies are %q, expected %q", got, expected)
+			}
+		})
+	}
+}
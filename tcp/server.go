@@ -2,6 +2,7 @@ package tcp
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"os"
 	"os/signal"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/tonge3199/redis_go/interface/tcp"
+	"github.com/tonge3199/redis_go/lib/logger"
 )
 
 // Config stores tcp server properties
@@ -40,7 +42,7 @@ var ClientCounter int32
 //	Waits for all handler goroutines to finish.
 func ListenAndServeWithSignal(cfg *Config, handler tcp.Handler) error {
 	closeChan := make(chan struct{})
-	sigCh := make(chan os.Signal)
+	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
 
 	go func() {
@@ -55,7 +57,7 @@ func ListenAndServeWithSignal(cfg *Config, handler tcp.Handler) error {
 		return err
 	}
 	// cfg.Address = listener.Addr().String()
-	// TODO:logger.Info()
+	logger.Info(fmt.Sprintf("bind: %s, start listening...", cfg.Address))
 	ListenAndServe(listener, handler, closeChan)
 	return nil
 }
@@ -81,10 +83,11 @@ func ListenAndServe(listener net.Listener, handler tcp.Handler, closeChan <-chan
 	go func() {
 		select {
 		case <-closeChan:
-			// TODO: logger.Info
+			logger.Info("get exit signal")
 		case err := <-errCh:
-			// TODO
+			logger.Info(fmt.Sprintf("accept error: %s", err.Error()))
 		}
+		logger.Info("shutting down...")
 		_ = listener.Close()
 		_ = handler.Close()
 	}()
@@ -105,7 +108,7 @@ func ListenAndServe(listener net.Listener, handler tcp.Handler, closeChan <-chan
 		}
 		// handle
 		// logger.Info("accept link")
-		ClientCounter++
+		atomic.AddInt32(&ClientCounter, 1)
 		waitDone.Add(1)
 		go func() {
 			defer func() {